@@ -0,0 +1,265 @@
+package fluxext
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/spf13/cast"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeIdABTestFilter = "abtest_filter"
+)
+
+const (
+	// Endpoint属性：A/B测试规则，可定义多个，按顺序匹配；格式：<表达式> => <ServiceId>，
+	// 例如：header.X-Group == beta && jwt.tier in [gold, silver] => com.foo.UserServiceV2:getUser
+	EndpointAttrTagABRule = "abrule"
+)
+
+const (
+	// Attribute：匹配的A/B测试规则选择的ServiceId
+	AttrKeyABTestService = "abtest.service"
+)
+
+const (
+	ABSourceHeader = "header"
+	ABSourceQuery  = "query"
+	ABSourceCookie = "cookie"
+	ABSourceJWT    = "jwt"
+)
+
+var abConditionPattern = regexp.MustCompile(`^(header|query|cookie|jwt)\.([\w\-.]+)\s*(?:(==|!=|\bin\b)\s*(.+))?$`)
+
+// ABTestConfig A/B测试配置
+type ABTestConfig struct {
+	SkipFunc flux.FilterSkipper
+}
+
+func NewABTestFilter(c ABTestConfig) *ABTestFilter {
+	return &ABTestFilter{
+		Configs: c,
+	}
+}
+
+// ABTestFilter 按Endpoint定义的A/B测试规则，从请求的Header，Query参数，Cookie及JWT声明中匹配条件，
+// 选择绑定到此Endpoint的多个服务之一；没有匹配的规则时，使用Endpoint定义的服务。
+// 表达式由 || 连接的多组 && 条件组成；条件支持：==，!=，in [a, b]；只有参数名时，判断参数值不为空。
+// 使用JWT声明时，需要在JWTFilter之后注册。
+type ABTestFilter struct {
+	Configs ABTestConfig
+	rules   sync.Map // attribute text -> abRuleEntry
+}
+
+// abRuleEntry 规则文本的解析结果；解析失败的规则同样缓存，避免每次请求重复解析
+type abRuleEntry struct {
+	rule *abRule
+	err  error
+}
+
+type abRule struct {
+	clauses   [][]abCondition
+	serviceId string
+}
+
+type abCondition struct {
+	source string
+	name   string
+	op     string
+	values []string
+}
+
+func (f *ABTestFilter) Init(_ *flux.Configuration) error {
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Info("ABTest filter initializing")
+	return nil
+}
+
+func (*ABTestFilter) FilterId() string {
+	return TypeIdABTestFilter
+}
+
+func (f *ABTestFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		for _, text := range attrTexts(ctx.Endpoint(), EndpointAttrTagABRule) {
+			rule, err := f.ruleOf(text)
+			if nil != err {
+				logger.LimitedWarnw("ABTEST:RULE:ILLEGAL", "rule", text, "error", err)
+				continue
+			}
+			if !rule.match(ctx) {
+				continue
+			}
+			service, ok := ext.TransporterServiceById(rule.serviceId)
+			if !ok {
+				logger.TraceContext(ctx).Warnw("ABTEST:SERVICE:NOT_FOUND", "service-id", rule.serviceId)
+				continue
+			}
+			// Endpoint为当前请求的副本，替换服务不影响其它请求
+			ctx.Endpoint().Service = service
+			ctx.SetAttribute(AttrKeyABTestService, rule.serviceId)
+			break
+		}
+		return next(ctx)
+	}
+}
+
+func (f *ABTestFilter) ruleOf(text string) (*abRule, error) {
+	if v, ok := f.rules.Load(text); ok {
+		entry := v.(abRuleEntry)
+		return entry.rule, entry.err
+	}
+	rule, err := ParseABRule(text)
+	f.rules.Store(text, abRuleEntry{rule: rule, err: err})
+	return rule, err
+}
+
+// attrTexts 读取Endpoint的规则文本属性；规则包含空格，以列表形式定义，不按空格分割
+func attrTexts(endpoint *flux.Endpoint, tag string) []string {
+	attr, ok := endpoint.GetAttrEx(tag)
+	if !ok {
+		return nil
+	}
+	if values, ok := attr.Value.([]interface{}); ok {
+		out := make([]string, 0, len(values))
+		for _, v := range values {
+			out = append(out, cast.ToString(v))
+		}
+		return out
+	}
+	if values, ok := attr.Value.([]string); ok {
+		return values
+	}
+	return []string{attr.GetString()}
+}
+
+// ParseABRule 解析A/B测试规则：<表达式> => <ServiceId>
+func ParseABRule(text string) (*abRule, error) {
+	idx := strings.LastIndex(text, "=>")
+	if idx < 0 {
+		return nil, fmt.Errorf("rule requires '=> <service-id>'")
+	}
+	rule := &abRule{serviceId: strings.TrimSpace(text[idx+2:])}
+	if rule.serviceId == "" {
+		return nil, fmt.Errorf("rule requires service-id")
+	}
+	for _, or := range strings.Split(text[:idx], "||") {
+		clause := make([]abCondition, 0, 2)
+		for _, and := range strings.Split(or, "&&") {
+			cond, err := parseABCondition(strings.TrimSpace(and))
+			if nil != err {
+				return nil, err
+			}
+			clause = append(clause, cond)
+		}
+		rule.clauses = append(rule.clauses, clause)
+	}
+	return rule, nil
+}
+
+func parseABCondition(expr string) (abCondition, error) {
+	m := abConditionPattern.FindStringSubmatch(expr)
+	if nil == m {
+		return abCondition{}, fmt.Errorf("illegal condition: %s", expr)
+	}
+	cond := abCondition{source: m[1], name: m[2], op: m[3]}
+	switch cond.op {
+	case "":
+	case "in":
+		list := strings.TrimSpace(m[4])
+		if !strings.HasPrefix(list, "[") || !strings.HasSuffix(list, "]") {
+			return abCondition{}, fmt.Errorf("illegal in-list: %s", expr)
+		}
+		for _, v := range strings.Split(list[1:len(list)-1], ",") {
+			cond.values = append(cond.values, abUnquote(v))
+		}
+	default:
+		cond.values = []string{abUnquote(m[4])}
+	}
+	return cond, nil
+}
+
+func abUnquote(v string) string {
+	return strings.Trim(strings.TrimSpace(v), `"'`)
+}
+
+func (r *abRule) match(ctx *flux.Context) bool {
+	for _, clause := range r.clauses {
+		matched := true
+		for _, cond := range clause {
+			if !cond.match(ctx) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+func (c abCondition) match(ctx *flux.Context) bool {
+	value, ok := c.lookup(ctx)
+	switch c.op {
+	case "==":
+		return ok && value == c.values[0]
+	case "!=":
+		return !ok || value != c.values[0]
+	case "in":
+		for _, v := range c.values {
+			if ok && v == value {
+				return true
+			}
+		}
+		return false
+	default:
+		return ok && value != ""
+	}
+}
+
+func (c abCondition) lookup(ctx *flux.Context) (string, bool) {
+	switch c.source {
+	case ABSourceHeader:
+		v := ctx.HeaderVar(c.name)
+		return v, v != ""
+	case ABSourceQuery:
+		v := ctx.QueryVar(c.name)
+		return v, v != ""
+	case ABSourceCookie:
+		cookie, err := ctx.CookieVar(c.name)
+		if nil != err {
+			return "", false
+		}
+		return cookie.Value, true
+	case ABSourceJWT:
+		claims, ok := JWTClaimsOf(ctx)
+		if !ok {
+			return "", false
+		}
+		var value interface{} = map[string]interface{}(claims)
+		for _, key := range strings.Split(c.name, ".") {
+			m, ok := value.(map[string]interface{})
+			if !ok {
+				return "", false
+			}
+			if value, ok = m[key]; !ok {
+				return "", false
+			}
+		}
+		return cast.ToString(value), true
+	}
+	return "", false
+}
@@ -0,0 +1,156 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newABTestContext(target string, headers map[string]string, claims jwt.MapClaims, rules ...string) *flux.Context {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("abtest", request, nil, nil), &flux.Endpoint{
+		HttpPattern: "/users",
+		Service:     flux.TransporterService{ServiceId: "com.foo.UserService:getUser"},
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: EndpointAttrTagABRule, Value: rules}},
+		},
+	})
+	ctx.SetResponseWriter(httptest.NewRecorder())
+	if nil != claims {
+		ctx.SetVariable(ContextKeyJWTClaims, claims)
+	}
+	return ctx
+}
+
+func TestParseABRule_Match(t *testing.T) {
+	cases := []struct {
+		name    string
+		rule    string
+		target  string
+		headers map[string]string
+		claims  jwt.MapClaims
+		match   bool
+	}{
+		// && 优先于 ||：a || (b && c)
+		{name: "or-first", rule: "header.A == 1 || header.B == 2 && header.C == 3 => svc",
+			headers: map[string]string{"A": "1"}, match: true},
+		{name: "and-partial", rule: "header.A == 1 || header.B == 2 && header.C == 3 => svc",
+			headers: map[string]string{"B": "2"}, match: false},
+		{name: "and-all", rule: "header.A == 1 || header.B == 2 && header.C == 3 => svc",
+			headers: map[string]string{"B": "2", "C": "3"}, match: true},
+		{name: "and-or", rule: "header.A == 1 && header.B == 2 || header.C == 3 => svc",
+			headers: map[string]string{"A": "1", "C": "0"}, match: false},
+		// in 列表
+		{name: "in", rule: "query.region in [cn, 'us', \"eu\"] => svc",
+			target: "http://gateway/users?region=us", match: true},
+		{name: "in-quoted", rule: "query.region in [cn, 'us', \"eu\"] => svc",
+			target: "http://gateway/users?region=eu", match: true},
+		{name: "in-absent", rule: "query.region in [cn, us] => svc",
+			target: "http://gateway/users?region=jp", match: false},
+		{name: "in-missing", rule: "query.region in [cn, us] => svc", match: false},
+		// != 在参数不存在时成立，== 不成立
+		{name: "not-equal-missing", rule: "header.X-Group != beta => svc", match: true},
+		{name: "not-equal-same", rule: "header.X-Group != beta => svc",
+			headers: map[string]string{"X-Group": "beta"}, match: false},
+		{name: "equal-missing", rule: "header.X-Group == beta => svc", match: false},
+		{name: "not-equal-cookie-missing", rule: "cookie.group != beta => svc", match: true},
+		{name: "cookie", rule: "cookie.group == beta => svc",
+			headers: map[string]string{"Cookie": "group=beta"}, match: true},
+		// 只有参数名时，判断参数值不为空
+		{name: "exists", rule: "query.debug => svc", target: "http://gateway/users?debug=1", match: true},
+		{name: "exists-empty", rule: "query.debug => svc", target: "http://gateway/users?debug=", match: false},
+		// JWT嵌套声明
+		{name: "jwt-nested", rule: "jwt.account.tier in [gold, silver] => svc",
+			claims: jwt.MapClaims{"account": map[string]interface{}{"tier": "gold"}}, match: true},
+		{name: "jwt-nested-number", rule: "jwt.account.level == 3 => svc",
+			claims: jwt.MapClaims{"account": map[string]interface{}{"level": 3.0}}, match: true},
+		{name: "jwt-nested-scalar", rule: "jwt.account.tier == gold => svc",
+			claims: jwt.MapClaims{"account": "gold"}, match: false},
+		{name: "jwt-nested-missing", rule: "jwt.account.tier != gold => svc",
+			claims: jwt.MapClaims{"account": map[string]interface{}{}}, match: true},
+		{name: "jwt-no-claims", rule: "jwt.sub == alice => svc", match: false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rule, err := ParseABRule(tc.rule)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "svc", rule.serviceId)
+			target := tc.target
+			if target == "" {
+				target = "http://gateway/users"
+			}
+			assert.Equal(t, tc.match, rule.match(newABTestContext(target, tc.headers, tc.claims)))
+		})
+	}
+}
+
+func TestParseABRule_Malformed(t *testing.T) {
+	cases := map[string]string{
+		"no-service":     "header.A == 1",
+		"empty-service":  "header.A == 1 =>  ",
+		"empty-expr":     " => svc",
+		"empty-and":      "header.A == 1 && => svc",
+		"empty-or":       "header.A == 1 || => svc",
+		"unknown-source": "body.A == 1 => svc",
+		"no-name":        "header. == 1 => svc",
+		"no-value":       "header.A == => svc",
+		"unknown-op":     "header.A > 1 => svc",
+		"in-no-list":     "jwt.tier in gold => svc",
+		"in-open-list":   "jwt.tier in [gold, silver => svc",
+	}
+	for name, text := range cases {
+		_, err := ParseABRule(text)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestABTestFilter_DoFilter(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	assert := assert.New(t)
+	ext.RegisterTransporterServiceById("com.foo.UserServiceV2:getUser", flux.TransporterService{ServiceId: "com.foo.UserServiceV2:getUser"})
+	defer ext.RemoveTransporterService("com.foo.UserServiceV2:getUser")
+	filter := NewABTestFilter(ABTestConfig{})
+	assert.NoError(filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{})))
+	illegal := "header.X-Group >= beta => com.foo.UserServiceV2:getUser"
+	rules := []string{
+		illegal,
+		"header.X-Group == beta => com.foo.UserServiceV3:getUser",
+		"header.X-Group == beta => com.foo.UserServiceV2:getUser",
+	}
+	invoke := func(headers map[string]string) *flux.Context {
+		ctx := newABTestContext("http://gateway/users", headers, nil, rules...)
+		assert.Nil(filter.DoFilter(func(_ *flux.Context) *flux.ServeError {
+			return nil
+		})(ctx))
+		return ctx
+	}
+	// 非法规则及服务不存在的规则被跳过
+	ctx := invoke(map[string]string{"X-Group": "beta"})
+	assert.Equal("com.foo.UserServiceV2:getUser", ctx.Endpoint().Service.ServiceId)
+	service, ok := ctx.GetAttribute(AttrKeyABTestService)
+	assert.True(ok)
+	assert.Equal("com.foo.UserServiceV2:getUser", service)
+	ctx = invoke(nil)
+	assert.Equal("com.foo.UserService:getUser", ctx.Endpoint().Service.ServiceId)
+	// 解析失败的规则同样缓存
+	v, ok := filter.rules.Load(illegal)
+	if assert.True(ok) {
+		entry := v.(abRuleEntry)
+		assert.Nil(entry.rule)
+		assert.Error(entry.err)
+	}
+	_, err := filter.ruleOf(illegal)
+	assert.Error(err)
+}
@@ -0,0 +1,569 @@
+package fluxext
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"html/template"
+	"math/bits"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdBotFilter = "bot_filter"
+)
+
+const (
+	ConfigKeyBotSecret         = "secret"
+	ConfigKeyBotCookieName     = "cookie_name"
+	ConfigKeyBotPassTTL        = "pass_ttl"
+	ConfigKeyBotAllowAgents    = "allow_agents"
+	ConfigKeyBotBadAgents      = "bad_agents"
+	ConfigKeyBotAllowIPs       = "allow_ips"
+	ConfigKeyBotDenyIPs        = "deny_ips"
+	ConfigKeyBotSuspiciousIPs  = "suspicious_ips"
+	ConfigKeyBotRateWindow     = "rate_window"
+	ConfigKeyBotRateThreshold  = "rate_threshold"
+	ConfigKeyBotChallengeScore = "challenge_score"
+	ConfigKeyBotBlockScore     = "block_score"
+	ConfigKeyBotChallenge      = "challenge"
+	ConfigKeyBotTarpitDelay    = "tarpit_delay"
+	ConfigKeyBotCaptchaURL     = "captcha_url"
+	ConfigKeyBotCaptchaHeader  = "captcha_header"
+	ConfigKeyBotGroups         = "groups"
+	// JS挑战的工作量：客户端计算的SHA-256结果需要的前导零比特数量
+	ConfigKeyBotJSDifficulty = "js_difficulty"
+	// JS挑战的有效时间
+	ConfigKeyBotChallengeTTL = "challenge_ttl"
+)
+
+const (
+	// Endpoint属性：机器人检测分组；未定义时使用默认分组配置
+	EndpointAttrTagBotGroup = "botgroup"
+)
+
+const (
+	// BotChallengeJS 返回执行JS脚本的挑战页面，脚本完成工作量证明计算并写入结果Cookie后重新加载，
+	// 网关校验计算结果后签发通过凭证Cookie
+	BotChallengeJS = "js"
+	// BotChallengeCaptcha 要求请求携带验证码Token，验证通过后签发通过凭证Cookie
+	BotChallengeCaptcha = "captcha"
+	// BotChallengeTarpit 延迟处理请求，降低自动化请求的速率
+	BotChallengeTarpit = "tarpit"
+	// BotChallengeBlock 直接拒绝请求
+	BotChallengeBlock = "block"
+	// BotChallengeOff 不检测请求
+	BotChallengeOff = "off"
+)
+
+const (
+	// 各项检测特征的评分
+	BotScoreEmptyAgent    = 40
+	BotScoreBadAgent      = 40
+	BotScoreMissingHeader = 10
+	BotScoreSuspiciousIP  = 50
+	BotScoreRateExceeded  = 40
+	BotScoreDenied        = 100
+)
+
+const (
+	HeaderXBotChallenge  = "X-Bot-Challenge"
+	HeaderXBotCaptchaURL = "X-Bot-Captcha-Url"
+)
+
+const (
+	botRateWindowsCapacity = 1024 * 64
+	botRateShards          = 32
+	// JS挑战计算结果的Cookie名称后缀
+	botSolutionCookieSuffix = "_pow"
+)
+
+const (
+	ErrorCodeBotBlocked   = "PERMISSION:BOT_BLOCKED"
+	ErrorCodeBotChallenge = "PERMISSION:BOT_CHALLENGE"
+)
+
+var (
+	// 挑战页面：以SHA-256计算满足前导零比特数量的计数值，写入结果Cookie后重新加载；页面不包含通过凭证
+	botJSChallengePage = template.Must(template.New("bot").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>Checking your browser</title></head>
+<body><noscript>Please enable JavaScript to continue.</noscript>
+<script>
+function sha256(m){var K=[],H=[],i,j,w=[],b=[],l=m.length*8,
+p=function(n){for(var d=2;d*d<=n;d++)if(n%d==0)return 0;return 1},f=function(x){return (x-(x|0))*4294967296|0};
+for(i=2,j=0;j<64;i++)if(p(i)){if(j<8)H[j]=f(Math.pow(i,1/2));K[j++]=f(Math.pow(i,1/3))}
+for(i=0;i<m.length;i++)b[i>>2]|=m.charCodeAt(i)<<(24-(i%4)*8);
+b[l>>5]|=0x80<<(24-l%32);b[((l+64>>9)<<4)+15]=l;
+for(i=0;i<b.length;i+=16){var a=H.slice(0);for(j=0;j<64;j++){
+if(j<16)w[j]=b[i+j]|0;else{var x=w[j-15],y=w[j-2];w[j]=((x>>>7|x<<25)^(x>>>18|x<<14)^(x>>>3))+w[j-7]+((y>>>17|y<<15)^(y>>>19|y<<13)^(y>>>10))+w[j-16]|0}
+var e=a[4],c=a[0],t1=a[7]+((e>>>6|e<<26)^(e>>>11|e<<21)^(e>>>25|e<<7))+(e&a[5]^~e&a[6])+K[j]+w[j]|0,
+t2=((c>>>2|c<<30)^(c>>>13|c<<19)^(c>>>22|c<<10))+(c&a[1]^c&a[2]^a[1]&a[2])|0;
+a=[t1+t2|0].concat(a);a[4]=a[4]+t1|0;a.pop()}
+for(j=0;j<8;j++)H[j]=H[j]+a[j]|0}
+return H}
+(function(){var c={{.Challenge}},d={{.Difficulty}},n=0,t=Math.pow(2,32-d);
+while((sha256(c+":"+n)[0]>>>0)>=t)n++;
+document.cookie={{.Cookie}}+"="+c+":"+n+"; path=/; max-age="+{{.MaxAge}};window.location.reload()})();
+</script>
+</body></html>`))
+)
+
+type (
+	// BotReputationFunc 返回客户端IP的信誉评分，评分累加到请求评分；用于对接外部IP信誉库
+	BotReputationFunc func(ip string) int
+	// BotCaptchaVerifyFunc 校验请求携带的验证码Token
+	BotCaptchaVerifyFunc func(ctx *flux.Context, token string) bool
+)
+
+// BotConfig 机器人检测配置
+type BotConfig struct {
+	SkipFunc       flux.FilterSkipper
+	ReputationFunc BotReputationFunc
+	// CaptchaVerifyFunc 使用 captcha 挑战方式时必须设置
+	CaptchaVerifyFunc BotCaptchaVerifyFunc
+}
+
+func NewBotFilter(c BotConfig) *BotFilter {
+	return &BotFilter{
+		Configs: c,
+	}
+}
+
+// BotFilter 机器人检测：按User-Agent，请求Header特征，IP名单/信誉及请求频率对请求评分；
+// 评分达到 block_score 时拒绝请求，达到 challenge_score 时按分组的挑战方式（js，captcha，tarpit，block）处理。
+// 通过挑战的客户端获得与IP绑定的签名Cookie，在 pass_ttl 内不再检测请求特征；IP名单，请求频率及IP信誉始终检测。
+// Endpoint通过属性 botgroup 选择分组，分组配置未定义的字段继承全局配置。
+type BotFilter struct {
+	Configs       BotConfig
+	secret        []byte
+	cookieName    string
+	passTTL       time.Duration
+	allowAgents   []string
+	badAgents     []string
+	allowIPs      []*net.IPNet
+	denyIPs       []*net.IPNet
+	suspiciousIPs []*net.IPNet
+	rateWindow    time.Duration
+	rateThreshold int
+	captchaURL    string
+	captchaHeader string
+	jsDifficulty  int
+	challengeTTL  time.Duration
+	defaults      botGroup
+	groups        map[string]botGroup
+	// 按IP散列分片的请求计数，减少并发请求的锁竞争
+	shards [botRateShards]botRateShard
+}
+
+type botGroup struct {
+	challenge      string
+	challengeScore int
+	blockScore     int
+	tarpitDelay    time.Duration
+}
+
+// botRateShard 固定时间窗口内的请求计数；进入新的时间窗口时整体替换，不逐个清理过期的计数
+type botRateShard struct {
+	window int64
+	counts map[string]int
+	mu     sync.Mutex
+}
+
+func (f *BotFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyBotCookieName:     "__flux_bot",
+		ConfigKeyBotPassTTL:        time.Minute * 30,
+		ConfigKeyBotBadAgents:      []string{"curl", "wget", "python-requests", "python-urllib", "go-http-client", "scrapy", "httpclient", "headlesschrome", "phantomjs", "selenium"},
+		ConfigKeyBotRateWindow:     time.Second * 10,
+		ConfigKeyBotRateThreshold:  0,
+		ConfigKeyBotChallengeScore: 50,
+		ConfigKeyBotBlockScore:     90,
+		ConfigKeyBotChallenge:      BotChallengeJS,
+		ConfigKeyBotTarpitDelay:    time.Second * 3,
+		ConfigKeyBotCaptchaHeader:  "X-Captcha-Token",
+		ConfigKeyBotJSDifficulty:   16,
+		ConfigKeyBotChallengeTTL:   time.Minute * 5,
+	})
+	secret, err := config.GetStringE(ConfigKeyBotSecret)
+	if nil != err {
+		return fmt.Errorf("BotFilter: %w", err)
+	}
+	f.secret = []byte(secret)
+	if len(f.secret) == 0 {
+		f.secret = make([]byte, 32)
+		if _, err := rand.Read(f.secret); nil != err {
+			return fmt.Errorf("BotFilter: generate secret: %w", err)
+		}
+		logger.Warn("BotFilter: <secret> not set, use random secret; pass cookies are invalid across instances")
+	}
+	for key, out := range map[string]*[]*net.IPNet{
+		ConfigKeyBotAllowIPs: &f.allowIPs, ConfigKeyBotDenyIPs: &f.denyIPs, ConfigKeyBotSuspiciousIPs: &f.suspiciousIPs,
+	} {
+		if *out, err = flux.ParseTrustedProxies(config.GetStringSlice(key)); nil != err {
+			return fmt.Errorf("BotFilter: <%s> %w", key, err)
+		}
+	}
+	f.cookieName = config.GetString(ConfigKeyBotCookieName)
+	f.passTTL = config.GetDuration(ConfigKeyBotPassTTL)
+	f.allowAgents = lowerStrings(config.GetStringSlice(ConfigKeyBotAllowAgents))
+	f.badAgents = lowerStrings(config.GetStringSlice(ConfigKeyBotBadAgents))
+	f.rateWindow = config.GetDuration(ConfigKeyBotRateWindow)
+	f.rateThreshold = config.GetInt(ConfigKeyBotRateThreshold)
+	f.captchaURL = config.GetString(ConfigKeyBotCaptchaURL)
+	f.captchaHeader = config.GetString(ConfigKeyBotCaptchaHeader)
+	f.jsDifficulty = config.GetInt(ConfigKeyBotJSDifficulty)
+	f.challengeTTL = config.GetDuration(ConfigKeyBotChallengeTTL)
+	if f.rateThreshold > 0 && f.rateWindow <= 0 {
+		return fmt.Errorf("BotFilter: <%s> must be positive", ConfigKeyBotRateWindow)
+	}
+	if f.jsDifficulty < 1 || f.jsDifficulty > 32 {
+		return fmt.Errorf("BotFilter: <%s> must be in range [1, 32]", ConfigKeyBotJSDifficulty)
+	}
+	for i := range f.shards {
+		f.shards[i].counts = make(map[string]int, 64)
+	}
+	if f.defaults, err = f.loadGroup("default", config); nil != err {
+		return err
+	}
+	f.groups = make(map[string]botGroup, 4)
+	groups := config.Sub(ConfigKeyBotGroups)
+	for name := range config.GetStringMap(ConfigKeyBotGroups) {
+		gc := groups.Sub(name)
+		gc.SetDefaults(map[string]interface{}{
+			ConfigKeyBotChallenge:      f.defaults.challenge,
+			ConfigKeyBotChallengeScore: f.defaults.challengeScore,
+			ConfigKeyBotBlockScore:     f.defaults.blockScore,
+			ConfigKeyBotTarpitDelay:    f.defaults.tarpitDelay,
+		})
+		if f.groups[name], err = f.loadGroup(name, gc); nil != err {
+			return err
+		}
+	}
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Infow("Bot filter initializing", "challenge", f.defaults.challenge, "challenge-score", f.defaults.challengeScore,
+		"block-score", f.defaults.blockScore, "groups", len(f.groups), "rate-threshold", f.rateThreshold)
+	return nil
+}
+
+func (f *BotFilter) loadGroup(name string, config *flux.Configuration) (botGroup, error) {
+	group := botGroup{
+		challenge:      strings.ToLower(config.GetString(ConfigKeyBotChallenge)),
+		challengeScore: config.GetInt(ConfigKeyBotChallengeScore),
+		blockScore:     config.GetInt(ConfigKeyBotBlockScore),
+		tarpitDelay:    config.GetDuration(ConfigKeyBotTarpitDelay),
+	}
+	switch group.challenge {
+	case BotChallengeJS, BotChallengeTarpit, BotChallengeBlock, BotChallengeOff:
+	case BotChallengeCaptcha:
+		if fluxpkg.IsNil(f.Configs.CaptchaVerifyFunc) {
+			return group, fmt.Errorf("BotFilter: <CaptchaVerifyFunc> is required by captcha challenge, group: %s", name)
+		}
+	default:
+		return group, fmt.Errorf("BotFilter: unknown challenge: %s, group: %s", group.challenge, name)
+	}
+	return group, nil
+}
+
+func (*BotFilter) FilterId() string {
+	return TypeIdBotFilter
+}
+
+func (f *BotFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		group := f.groupOf(ctx.Endpoint())
+		if group.challenge == BotChallengeOff || f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		ip := flux.RealIP(ctx)
+		passed := f.passed(ctx, ip)
+		if !passed && f.solved(ctx, ip) {
+			// JS挑战的计算结果有效，签发通过凭证并清除结果Cookie
+			http.SetCookie(ctx.ResponseWriter(), f.passCookie(ip, ctx.StartAt()))
+			http.SetCookie(ctx.ResponseWriter(), &http.Cookie{Name: f.cookieName + botSolutionCookieSuffix, Path: "/", MaxAge: -1})
+			passed = true
+		}
+		score, reasons := f.score(ctx, ip, passed)
+		switch {
+		case score >= group.blockScore:
+			logger.TraceContext(ctx).Infow("BOT:BLOCKED", "client-ip", ip, "score", score, "reasons", reasons)
+			return &flux.ServeError{
+				StatusCode: flux.StatusAccessDenied,
+				ErrorCode:  ErrorCodeBotBlocked,
+				Message:    "BOT:REQUEST_BLOCKED",
+			}
+		case score >= group.challengeScore:
+			logger.TraceContext(ctx).Infow("BOT:CHALLENGE", "client-ip", ip, "score", score, "reasons", reasons,
+				"challenge", group.challenge)
+			return f.challenge(ctx, next, group, ip)
+		default:
+			return next(ctx)
+		}
+	}
+}
+
+// score 计算请求的机器人评分及命中的特征；已通过挑战的客户端不检测请求特征，IP名单，请求频率及IP信誉始终检测
+func (f *BotFilter) score(ctx *flux.Context, ip string, passed bool) (int, []string) {
+	if f.containsIP(f.allowIPs, ip) {
+		return 0, nil
+	}
+	if f.containsIP(f.denyIPs, ip) {
+		return BotScoreDenied, []string{"deny-ip"}
+	}
+	score, reasons := 0, make([]string, 0, 4)
+	if !passed {
+		score, reasons = f.scoreFeatures(ctx, ip, score, reasons)
+	}
+	if f.rateExceeded(ip, ctx.StartAt()) {
+		score, reasons = score+BotScoreRateExceeded, append(reasons, "rate-exceeded")
+	}
+	if !fluxpkg.IsNil(f.Configs.ReputationFunc) {
+		if rep := f.Configs.ReputationFunc(ip); rep != 0 {
+			score, reasons = score+rep, append(reasons, "reputation:"+strconv.Itoa(rep))
+		}
+	}
+	return score, reasons
+}
+
+// scoreFeatures 按User-Agent，请求Header及可疑IP名单评分；白名单User-Agent不检测请求特征
+func (f *BotFilter) scoreFeatures(ctx *flux.Context, ip string, score int, reasons []string) (int, []string) {
+	agent := strings.ToLower(ctx.HeaderVar(flux.HeaderUserAgent))
+	if agent == "" {
+		score, reasons = score+BotScoreEmptyAgent, append(reasons, "empty-agent")
+	} else {
+		for _, allow := range f.allowAgents {
+			if strings.Contains(agent, allow) {
+				return score, reasons
+			}
+		}
+		for _, bad := range f.badAgents {
+			if strings.Contains(agent, bad) {
+				score, reasons = score+BotScoreBadAgent, append(reasons, "bad-agent:"+bad)
+				break
+			}
+		}
+	}
+	for _, name := range []string{flux.HeaderAccept, flux.HeaderAcceptLanguage} {
+		if ctx.HeaderVar(name) == "" {
+			score, reasons = score+BotScoreMissingHeader, append(reasons, "missing-header:"+name)
+		}
+	}
+	if f.containsIP(f.suspiciousIPs, ip) {
+		score, reasons = score+BotScoreSuspiciousIP, append(reasons, "suspicious-ip")
+	}
+	return score, reasons
+}
+
+func (f *BotFilter) challenge(ctx *flux.Context, next flux.FilterInvoker, group botGroup, ip string) *flux.ServeError {
+	switch group.challenge {
+	case BotChallengeTarpit:
+		timer := time.NewTimer(group.tarpitDelay)
+		select {
+		case <-timer.C:
+			return next(ctx)
+		case <-ctx.Context().Done():
+			timer.Stop()
+			return &flux.ServeError{
+				StatusCode: flux.StatusOK,
+				ErrorCode:  flux.ErrorCodeGatewayCanceled,
+				Message:    "BOT:TARPIT:CANCELED:BYCLIENT",
+				CauseError: ctx.Context().Err(),
+			}
+		}
+	case BotChallengeCaptcha:
+		if token := ctx.HeaderVar(f.captchaHeader); token != "" && f.Configs.CaptchaVerifyFunc(ctx, token) {
+			http.SetCookie(ctx.ResponseWriter(), f.passCookie(ip, ctx.StartAt()))
+			return next(ctx)
+		}
+		header := http.Header{HeaderXBotChallenge: []string{BotChallengeCaptcha}}
+		if f.captchaURL != "" {
+			header.Set(HeaderXBotCaptchaURL, f.captchaURL)
+		}
+		return &flux.ServeError{
+			StatusCode: flux.StatusAccessDenied,
+			ErrorCode:  ErrorCodeBotChallenge,
+			Message:    "BOT:CAPTCHA_REQUIRED",
+			Header:     header,
+		}
+	case BotChallengeJS:
+		// 页面只包含与IP绑定的挑战数据，通过凭证在校验计算结果后以HttpOnly Cookie签发
+		var page strings.Builder
+		if err := botJSChallengePage.Execute(&page, map[string]interface{}{
+			"Challenge":  f.newChallenge(ip, ctx.StartAt()),
+			"Difficulty": f.jsDifficulty,
+			"Cookie":     f.cookieName + botSolutionCookieSuffix,
+			"MaxAge":     int(f.challengeTTL / time.Second),
+		}); nil != err {
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    "BOT:CHALLENGE:RENDER",
+				CauseError: err,
+			}
+		}
+		ctx.ResponseWriter().Header().Set(HeaderXBotChallenge, BotChallengeJS)
+		if err := ctx.Write(flux.StatusAccessDenied, flux.MIMETextHTMLCharsetUTF8, []byte(page.String())); nil != err {
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessageTransportWriteResponse,
+				CauseError: err,
+			}
+		}
+		return nil
+	default:
+		return &flux.ServeError{
+			StatusCode: flux.StatusAccessDenied,
+			ErrorCode:  ErrorCodeBotBlocked,
+			Message:    "BOT:REQUEST_BLOCKED",
+		}
+	}
+}
+
+func (f *BotFilter) groupOf(endpoint *flux.Endpoint) botGroup {
+	if name := endpoint.GetAttr(EndpointAttrTagBotGroup).GetString(); name != "" {
+		if group, ok := f.groups[name]; ok {
+			return group
+		}
+	}
+	return f.defaults
+}
+
+// passCookie 签发与客户端IP绑定的通过凭证：过期时间.签名
+func (f *BotFilter) passCookie(ip string, now time.Time) *http.Cookie {
+	expires := strconv.FormatInt(now.Add(f.passTTL).Unix(), 10)
+	return &http.Cookie{
+		Name:     f.cookieName,
+		Value:    expires + "." + f.sign("pass", ip, expires),
+		Path:     "/",
+		MaxAge:   int(f.passTTL / time.Second),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func (f *BotFilter) passed(ctx *flux.Context, ip string) bool {
+	cookie, err := ctx.CookieVar(f.cookieName)
+	if nil != err || cookie.Value == "" {
+		return false
+	}
+	return f.verify("pass", ip, cookie.Value, ctx.StartAt())
+}
+
+// newChallenge 生成与客户端IP绑定的JS挑战数据：过期时间.签名
+func (f *BotFilter) newChallenge(ip string, now time.Time) string {
+	expires := strconv.FormatInt(now.Add(f.challengeTTL).Unix(), 10)
+	return expires + "." + f.sign("pow", ip, expires)
+}
+
+// solved 校验JS挑战的计算结果：挑战数据:计数值；挑战数据有效，且 SHA-256(挑战数据:计数值) 的前导零比特数量满足要求
+func (f *BotFilter) solved(ctx *flux.Context, ip string) bool {
+	cookie, err := ctx.CookieVar(f.cookieName + botSolutionCookieSuffix)
+	if nil != err || cookie.Value == "" {
+		return false
+	}
+	idx := strings.LastIndexByte(cookie.Value, ':')
+	if idx < 0 {
+		return false
+	}
+	challenge := cookie.Value[:idx]
+	if !f.verify("pow", ip, challenge, ctx.StartAt()) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(cookie.Value))
+	return bits.LeadingZeros32(binary.BigEndian.Uint32(sum[:4])) >= f.jsDifficulty
+}
+
+// verify 校验 过期时间.签名 格式的数据
+func (f *BotFilter) verify(purpose, ip, value string, now time.Time) bool {
+	parts := strings.SplitN(value, ".", 2)
+	if len(parts) != 2 {
+		return false
+	}
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if nil != err || now.Unix() > expires {
+		return false
+	}
+	return hmac.Equal([]byte(parts[1]), []byte(f.sign(purpose, ip, parts[0])))
+}
+
+func (f *BotFilter) sign(purpose, ip, expires string) string {
+	mac := hmac.New(sha256.New, f.secret)
+	_, _ = mac.Write([]byte(purpose))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(ip))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// rateExceeded 按固定时间窗口统计客户端IP的请求数量；分片的IP数量达到容量时，不再统计新的IP，直到下一个时间窗口
+func (f *BotFilter) rateExceeded(ip string, now time.Time) bool {
+	if f.rateThreshold <= 0 {
+		return false
+	}
+	window := now.UnixNano() / int64(f.rateWindow)
+	shard := &f.shards[botShardOf(ip)]
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	if shard.window != window {
+		shard.window, shard.counts = window, make(map[string]int, 64)
+	}
+	count, ok := shard.counts[ip]
+	if !ok && len(shard.counts) >= botRateWindowsCapacity/botRateShards {
+		return false
+	}
+	count++
+	shard.counts[ip] = count
+	return count > f.rateThreshold
+}
+
+// botShardOf FNV-1a散列
+func botShardOf(ip string) uint32 {
+	hash := uint32(2166136261)
+	for i := 0; i < len(ip); i++ {
+		hash ^= uint32(ip[i])
+		hash *= 16777619
+	}
+	return hash % botRateShards
+}
+
+func (f *BotFilter) containsIP(nets []*net.IPNet, ip string) bool {
+	if len(nets) == 0 {
+		return false
+	}
+	parsed := net.ParseIP(ip)
+	if nil == parsed {
+		return false
+	}
+	for _, ipnet := range nets {
+		if ipnet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerStrings(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.ToLower(strings.TrimSpace(v)); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
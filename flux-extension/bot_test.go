@@ -0,0 +1,145 @@
+package fluxext
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"math/bits"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const botTestIP = "192.0.2.1"
+
+func newBotFilter(t *testing.T, config map[string]interface{}) *BotFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	config[ConfigKeyBotSecret] = "bot-secret"
+	filter := NewBotFilter(BotConfig{})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(config)))
+	return filter
+}
+
+func newBotContext(agent string, cookies ...*http.Cookie) (*flux.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(http.MethodGet, "http://gateway/users", nil)
+	request.RemoteAddr = botTestIP + ":4321"
+	request.Header.Set(flux.HeaderUserAgent, agent)
+	request.Header.Set(flux.HeaderAccept, "text/html")
+	request.Header.Set(flux.HeaderAcceptLanguage, "en")
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("bot", request, nil, nil), &flux.Endpoint{HttpPattern: "/users"})
+	ctx.SetResponseWriter(recorder)
+	return ctx, recorder
+}
+
+func botNext(ctx *flux.Context) *flux.ServeError {
+	_ = ctx.Write(flux.StatusOK, flux.MIMETextPlain, []byte("ok"))
+	return nil
+}
+
+func cookieOf(recorder *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestBotFilter_PassCookieKeepsDenyAndRateChecks(t *testing.T) {
+	assert := assert.New(t)
+	filter := newBotFilter(t, map[string]interface{}{
+		ConfigKeyBotDenyIPs: []string{botTestIP},
+	})
+	pass := filter.passCookie(botTestIP, time.Now())
+	ctx, _ := newBotContext("Mozilla/5.0", pass)
+	serr := filter.DoFilter(botNext)(ctx)
+	assert.NotNil(serr)
+	assert.Equal(ErrorCodeBotBlocked, serr.ErrorCode)
+
+	// 通过凭证不跳过请求频率检测
+	filter = newBotFilter(t, map[string]interface{}{
+		ConfigKeyBotRateThreshold: 1,
+		ConfigKeyBotChallenge:     BotChallengeBlock,
+		ConfigKeyBotBlockScore:    BotScoreRateExceeded,
+	})
+	pass = filter.passCookie(botTestIP, time.Now())
+	ctx, _ = newBotContext("Mozilla/5.0", pass)
+	assert.Nil(filter.DoFilter(botNext)(ctx))
+	ctx, _ = newBotContext("Mozilla/5.0", pass)
+	serr = filter.DoFilter(botNext)(ctx)
+	assert.NotNil(serr)
+	assert.Equal(ErrorCodeBotBlocked, serr.ErrorCode)
+}
+
+func TestBotFilter_JSChallengeProofOfWork(t *testing.T) {
+	assert := assert.New(t)
+	filter := newBotFilter(t, map[string]interface{}{
+		ConfigKeyBotJSDifficulty:   8,
+		ConfigKeyBotChallengeScore: BotScoreEmptyAgent,
+	})
+	ctx, recorder := newBotContext("")
+	assert.Nil(filter.DoFilter(botNext)(ctx))
+	assert.Equal(flux.StatusAccessDenied, recorder.Code)
+	page := recorder.Body.String()
+	// 挑战页面不包含通过凭证
+	assert.Nil(cookieOf(recorder, filter.cookieName))
+	assert.NotContains(page, filter.passCookie(botTestIP, ctx.StartAt()).Value)
+	start := strings.Index(page, "var c=\"") + len("var c=\"")
+	challenge := page[start : start+strings.IndexByte(page[start:], '"')]
+	assert.True(filter.verify("pow", botTestIP, challenge, time.Now()))
+
+	// 无效的计算结果不签发通过凭证
+	ctx, recorder = newBotContext("", &http.Cookie{Name: filter.cookieName + botSolutionCookieSuffix, Value: challenge + ":x"})
+	assert.Nil(filter.DoFilter(botNext)(ctx))
+	assert.Nil(cookieOf(recorder, filter.cookieName))
+
+	solution := challenge
+	for n := 0; ; n++ {
+		solution = challenge + ":" + strconv.Itoa(n)
+		sum := sha256.Sum256([]byte(solution))
+		if bits.LeadingZeros32(binary.BigEndian.Uint32(sum[:4])) >= 8 {
+			break
+		}
+	}
+	ctx, recorder = newBotContext("", &http.Cookie{Name: filter.cookieName + botSolutionCookieSuffix, Value: solution})
+	assert.Nil(filter.DoFilter(botNext)(ctx))
+	assert.Equal(flux.StatusOK, recorder.Code)
+	pass := cookieOf(recorder, filter.cookieName)
+	assert.NotNil(pass)
+	assert.True(pass.HttpOnly)
+	assert.True(filter.verify("pass", botTestIP, pass.Value, time.Now()))
+	// 挑战数据不能作为通过凭证，通过凭证与IP绑定
+	assert.False(filter.verify("pass", botTestIP, challenge, time.Now()))
+	assert.False(filter.verify("pass", "192.0.2.2", pass.Value, time.Now()))
+}
+
+func TestBotFilter_RateWindow(t *testing.T) {
+	assert := assert.New(t)
+	filter := newBotFilter(t, map[string]interface{}{
+		ConfigKeyBotRateThreshold: 2,
+		ConfigKeyBotRateWindow:    time.Second,
+	})
+	now := time.Unix(1000, 0)
+	assert.False(filter.rateExceeded(botTestIP, now))
+	assert.False(filter.rateExceeded(botTestIP, now))
+	assert.True(filter.rateExceeded(botTestIP, now.Add(time.Millisecond*500)))
+	assert.False(filter.rateExceeded("192.0.2.2", now))
+	// 进入新的时间窗口后重新计数
+	assert.False(filter.rateExceeded(botTestIP, now.Add(time.Second)))
+	assert.Error(NewBotFilter(BotConfig{}).Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyBotRateThreshold: 1,
+		ConfigKeyBotRateWindow:    0,
+	})))
+}
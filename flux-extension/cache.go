@@ -0,0 +1,387 @@
+package fluxext
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdResponseCacheFilter = "response_cache_filter"
+)
+
+const (
+	ConfigKeyCacheTTL       = "ttl"
+	ConfigKeyCacheKeyParts  = "key_parts"
+	ConfigKeyCacheKeyPrefix = "key_prefix"
+	ConfigKeyCacheMaxBody   = "max_body"
+	// 每个Redis分片在哈希环上的虚拟节点数量
+	ConfigKeyCacheShardReplicas = "shard_replicas"
+	// 本地L1热点缓存的TTL；小于等于0时不启用L1缓存
+	ConfigKeyCacheL1TTL = "l1_ttl"
+	// 本地L1热点缓存的最大条目数量
+	ConfigKeyCacheL1Size = "l1_size"
+	// 在一个 l1_ttl 周期内访问次数达到此阈值的Key，判定为热点Key并写入L1缓存
+	ConfigKeyCacheHotThreshold = "hot_threshold"
+)
+
+const (
+	// Endpoint属性：响应缓存的TTL，覆盖全局配置 ttl
+	EndpointAttrTagCacheTTL = "cachettl"
+)
+
+const (
+	HeaderXCache = "X-Cache"
+	// X-Cache 取值：L1热点缓存命中，Redis缓存命中，未命中
+	CacheStatusHitLocal = "HIT-L1"
+	CacheStatusHit      = "HIT"
+	CacheStatusMiss     = "MISS"
+)
+
+const (
+	// 写入缓存的超时时间；不使用请求Context，客户端取消请求后仍能写入缓存
+	cacheStoreTimeout = time.Second * 2
+)
+
+type (
+	// ResponseCacheStore 响应缓存存储
+	ResponseCacheStore interface {
+		// Load 读取Key对应的响应记录；记录不存在时返回false
+		Load(ctx context.Context, key string) (*CacheRecord, bool, error)
+		// Save 保存响应记录
+		Save(ctx context.Context, key string, record *CacheRecord, ttl time.Duration) error
+	}
+	// CacheRedis Redis命令执行接口；transporter/redis.Client 实现此接口
+	CacheRedis interface {
+		Do(ctx context.Context, args ...interface{}) (interface{}, error)
+	}
+)
+
+// CacheRecord 缓存的响应记录
+type CacheRecord struct {
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       []byte      `json:"body,omitempty"`
+}
+
+// ResponseCacheConfig 响应缓存配置
+type ResponseCacheConfig struct {
+	SkipFunc flux.FilterSkipper
+	// KeyFunc 自定义缓存Key；未设置时按 key_parts 配置生成
+	KeyFunc CoalesceKeyFunc
+	// Store 自定义响应存储；未设置时使用 Shards 创建分片Redis存储
+	Store ResponseCacheStore
+	// Shards Redis分片节点，Key为节点名称（例如节点地址）；Key按一致性哈希分布到各个节点
+	Shards map[string]CacheRedis
+}
+
+func NewResponseCacheFilter(c ResponseCacheConfig) *ResponseCacheFilter {
+	return &ResponseCacheFilter{
+		Configs: c,
+	}
+}
+
+// ResponseCacheFilter 基于Redis的GET响应缓存：Endpoint属性 cacheable 为true时，缓存状态码为200的响应，TTL内相同Key的请求直接返回缓存的响应。
+// 集群部署时，缓存Key在客户端按一致性哈希分布到多个Redis分片；同一周期（l1_ttl）内访问次数达到 hot_threshold 的热点Key，
+// 同时缓存在本地L1缓存中，避免单个热点Endpoint的请求全部落在同一个Redis分片。L1缓存的TTL较短，数据的过期延迟不超过 l1_ttl。
+// Redis不可用时，请求直接调用后端服务。
+type ResponseCacheFilter struct {
+	Configs      ResponseCacheConfig
+	ttl          time.Duration
+	keyParts     []string
+	maxBody      int
+	l1ttl        time.Duration
+	l1size       int
+	hotThreshold int
+	l1           map[string]cacheL1Entry
+	hits         map[string]int
+	window       time.Time
+	mu           sync.Mutex
+}
+
+type cacheL1Entry struct {
+	record  *CacheRecord
+	expires time.Time
+}
+
+func (f *ResponseCacheFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCacheTTL: time.Minute,
+		ConfigKeyCacheKeyParts: []string{CoalesceKeyMethod, CoalesceKeyPath, CoalesceKeyQuery,
+			"header:" + flux.HeaderAuthorization, "header:" + flux.HeaderCookie},
+		ConfigKeyCacheKeyPrefix:     "flux:cache:",
+		ConfigKeyCacheMaxBody:       1024 * 1024,
+		ConfigKeyCacheShardReplicas: 160,
+		ConfigKeyCacheL1TTL:         time.Second,
+		ConfigKeyCacheL1Size:        1024,
+		ConfigKeyCacheHotThreshold:  10,
+	})
+	if fluxpkg.IsNil(f.Configs.Store) {
+		if len(f.Configs.Shards) == 0 {
+			return errors.New("ResponseCacheFilter: <Store> or <Shards> is required")
+		}
+		f.Configs.Store = NewShardedRedisCacheStore(f.Configs.Shards,
+			config.GetInt(ConfigKeyCacheShardReplicas), config.GetString(ConfigKeyCacheKeyPrefix))
+	}
+	f.ttl = config.GetDuration(ConfigKeyCacheTTL)
+	f.keyParts = config.GetStringSlice(ConfigKeyCacheKeyParts)
+	f.maxBody = config.GetInt(ConfigKeyCacheMaxBody)
+	f.l1ttl = config.GetDuration(ConfigKeyCacheL1TTL)
+	f.l1size = config.GetInt(ConfigKeyCacheL1Size)
+	f.hotThreshold = config.GetInt(ConfigKeyCacheHotThreshold)
+	f.l1 = make(map[string]cacheL1Entry, 64)
+	f.hits = make(map[string]int, 64)
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if fluxpkg.IsNil(f.Configs.KeyFunc) {
+		f.Configs.KeyFunc = f.cacheKey
+	}
+	logger.Infow("Response cache filter initializing", "ttl", f.ttl, "shards", len(f.Configs.Shards),
+		"l1-ttl", f.l1ttl, "l1-size", f.l1size, "hot-threshold", f.hotThreshold)
+	return nil
+}
+
+func (*ResponseCacheFilter) FilterId() string {
+	return TypeIdResponseCacheFilter
+}
+
+func (f *ResponseCacheFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if ctx.Method() != http.MethodGet || !ctx.Endpoint().GetAttr(EndpointAttrTagCacheable).GetBool() || f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		key := f.Configs.KeyFunc(ctx)
+		if key == "" {
+			return next(ctx)
+		}
+		hot := f.touch(key)
+		if record, ok := f.loadLocal(key); ok {
+			return f.replay(ctx, record, CacheStatusHitLocal)
+		}
+		record, ok, err := f.Configs.Store.Load(ctx.Context(), key)
+		if nil != err {
+			logger.TraceContext(ctx).Warnw("CACHE:LOAD:ERROR", "key", key, "error", err)
+		} else if ok {
+			if hot {
+				f.saveLocal(key, record)
+			}
+			return f.replay(ctx, record, CacheStatusHit)
+		}
+		// 在ResponseWriter层记录响应，与TransportWriter的实现无关
+		ctx.ResponseWriter().Header().Set(HeaderXCache, CacheStatusMiss)
+		recorder := newResponseRecorder(ctx.ResponseWriter(), f.maxBody)
+		ctx.SetResponseWriter(recorder)
+		serr := next(ctx)
+		ctx.SetResponseWriter(recorder.ResponseWriter)
+		status, header, body, ok := recorder.recorded()
+		if !ok || status != http.StatusOK || !cacheableResponse(header) {
+			return serr
+		}
+		header.Del(HeaderXCache)
+		record = &CacheRecord{StatusCode: status, Header: header, Body: body}
+		if hot {
+			f.saveLocal(key, record)
+		}
+		storeCtx, cancel := context.WithTimeout(context.Background(), cacheStoreTimeout)
+		defer cancel()
+		if err := f.Configs.Store.Save(storeCtx, key, record, f.endpointTTL(ctx)); nil != err {
+			logger.TraceContext(ctx).Warnw("CACHE:SAVE:ERROR", "key", key, "error", err)
+		}
+		return serr
+	}
+}
+
+// touch 记录Key在当前周期内的访问次数，返回Key是否为热点Key
+func (f *ResponseCacheFilter) touch(key string) bool {
+	if f.l1ttl <= 0 {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if now := time.Now(); now.After(f.window) {
+		f.hits = make(map[string]int, len(f.hits))
+		f.window = now.Add(f.l1ttl)
+	}
+	count, ok := f.hits[key]
+	if !ok && len(f.hits) >= f.l1size*8 {
+		// 统计的Key数量有上限；超过上限的新Key在本周期内不判定为热点
+		return false
+	}
+	count++
+	f.hits[key] = count
+	return count >= f.hotThreshold
+}
+
+func (f *ResponseCacheFilter) loadLocal(key string) (*CacheRecord, bool) {
+	if f.l1ttl <= 0 {
+		return nil, false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	entry, ok := f.l1[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(f.l1, key)
+		return nil, false
+	}
+	return entry.record, true
+}
+
+func (f *ResponseCacheFilter) saveLocal(key string, record *CacheRecord) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if _, ok := f.l1[key]; !ok && len(f.l1) >= f.l1size {
+		// 优先淘汰已过期的条目；没有过期条目时淘汰任意一个条目
+		for k, entry := range f.l1 {
+			if now.After(entry.expires) {
+				delete(f.l1, k)
+			}
+		}
+		for k := range f.l1 {
+			if len(f.l1) < f.l1size {
+				break
+			}
+			delete(f.l1, k)
+		}
+	}
+	f.l1[key] = cacheL1Entry{record: record, expires: now.Add(f.l1ttl)}
+}
+
+func (f *ResponseCacheFilter) replay(ctx *flux.Context, record *CacheRecord, status string) *flux.ServeError {
+	header := ctx.ResponseWriter().Header()
+	for name, values := range record.Header {
+		header[name] = values
+	}
+	header.Set(HeaderXCache, status)
+	contentType := record.Header.Get(flux.HeaderContentType)
+	if contentType == "" {
+		contentType = flux.MIMEApplicationJSONCharsetUTF8
+	}
+	if err := ctx.Write(record.StatusCode, contentType, record.Body); nil != err {
+		return &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageTransportWriteResponse,
+			CauseError: err,
+		}
+	}
+	return nil
+}
+
+func (f *ResponseCacheFilter) endpointTTL(ctx *flux.Context) time.Duration {
+	if attr, ok := ctx.Endpoint().GetAttrEx(EndpointAttrTagCacheTTL); ok {
+		if ttl, err := time.ParseDuration(attr.GetString()); nil == err && ttl > 0 {
+			return ttl
+		}
+	}
+	return f.ttl
+}
+
+// cacheKey 按 key_parts 生成缓存Key
+func (f *ResponseCacheFilter) cacheKey(ctx *flux.Context) string {
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, ctx.Endpoint().HttpPattern)
+	for _, part := range f.keyParts {
+		_, _ = hash.Write([]byte{0})
+		_, _ = io.WriteString(hash, coalesceKeyPart(ctx, part))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// cacheableResponse 判断响应Header是否允许共享缓存
+func cacheableResponse(header http.Header) bool {
+	for _, value := range header.Values(flux.HeaderCacheControl) {
+		for _, directive := range strings.Split(value, ",") {
+			switch strings.ToLower(strings.TrimSpace(directive)) {
+			case "no-store", "no-cache", "private":
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// ShardedRedisCacheStore 基于多个Redis分片的响应存储；Key按一致性哈希映射到分片，分片增减时只影响少量Key。记录以JSON格式保存
+type ShardedRedisCacheStore struct {
+	ring   *fluxpkg.HashRing
+	shards map[string]CacheRedis
+	prefix string
+}
+
+func NewShardedRedisCacheStore(shards map[string]CacheRedis, replicas int, prefix string) *ShardedRedisCacheStore {
+	nodes := make([]string, 0, len(shards))
+	for node := range shards {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	return &ShardedRedisCacheStore{
+		ring:   fluxpkg.NewHashRing(nodes, replicas),
+		shards: shards,
+		prefix: prefix,
+	}
+}
+
+// Shard 返回Key映射的分片节点名称
+func (s *ShardedRedisCacheStore) Shard(key string) string {
+	return s.ring.Get(key)
+}
+
+func (s *ShardedRedisCacheStore) Load(ctx context.Context, key string) (*CacheRecord, bool, error) {
+	client, err := s.client(key)
+	if nil != err {
+		return nil, false, err
+	}
+	reply, err := client.Do(ctx, "GET", s.prefix+key)
+	if nil != err {
+		return nil, false, err
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return nil, false, nil
+	}
+	record := new(CacheRecord)
+	if err := ext.JSONUnmarshal([]byte(data), record); nil != err {
+		return nil, false, fmt.Errorf("decode cache record: %w", err)
+	}
+	return record, true, nil
+}
+
+func (s *ShardedRedisCacheStore) Save(ctx context.Context, key string, record *CacheRecord, ttl time.Duration) error {
+	client, err := s.client(key)
+	if nil != err {
+		return err
+	}
+	data, err := ext.JSONMarshal(record)
+	if nil != err {
+		return err
+	}
+	_, err = client.Do(ctx, "SET", s.prefix+key, string(data), "PX", ttl.Milliseconds())
+	return err
+}
+
+func (s *ShardedRedisCacheStore) client(key string) (CacheRedis, error) {
+	node := s.ring.Get(key)
+	client, ok := s.shards[node]
+	if !ok || fluxpkg.IsNil(client) {
+		return nil, fmt.Errorf("no redis shard for key: %s", key)
+	}
+	return client, nil
+}
@@ -0,0 +1,213 @@
+package fluxext
+
+import (
+	"context"
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mapRedis 以内存Map模拟 GET/SET 命令的Redis节点
+type mapRedis struct {
+	values map[string]string
+	gets   int
+	down   bool
+	mu     sync.Mutex
+}
+
+func newMapRedis() *mapRedis {
+	return &mapRedis{values: make(map[string]string)}
+}
+
+func (r *mapRedis) Do(_ context.Context, args ...interface{}) (interface{}, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.down {
+		return nil, errors.New("connection refused")
+	}
+	switch args[0] {
+	case "GET":
+		r.gets++
+		if value, ok := r.values[args[1].(string)]; ok {
+			return value, nil
+		}
+		return nil, nil
+	case "SET":
+		r.values[args[1].(string)] = args[2].(string)
+		return "OK", nil
+	}
+	return nil, errors.New("unsupported command")
+}
+
+func newResponseCacheFilter(t *testing.T, shards map[string]CacheRedis, config map[string]interface{}) *ResponseCacheFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	filter := NewResponseCacheFilter(ResponseCacheConfig{Shards: shards})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(config)))
+	return filter
+}
+
+func cacheInvoke(filter *ResponseCacheFilter, target string, next flux.FilterInvoker) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("cache", httptest.NewRequest(http.MethodGet, target, nil), nil, nil), &flux.Endpoint{
+		HttpMethod:  http.MethodGet,
+		HttpPattern: "/users",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: EndpointAttrTagCacheable, Value: true}},
+		},
+	})
+	ctx.SetResponseWriter(recorder)
+	_ = filter.DoFilter(next)(ctx)
+	return recorder
+}
+
+func cacheBackend(calls *int, status int, header http.Header) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		*calls++
+		for name, values := range header {
+			ctx.ResponseWriter().Header()[name] = values
+		}
+		_ = ctx.Write(status, flux.MIMEApplicationJSONCharsetUTF8, []byte(`{"call":`+strconv.Itoa(*calls)+`}`))
+		return nil
+	}
+}
+
+func TestShardedRedisCacheStore(t *testing.T) {
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	assert := assert.New(t)
+	shards := map[string]*mapRedis{"10.0.0.1:6379": newMapRedis(), "10.0.0.2:6379": newMapRedis(), "10.0.0.3:6379": newMapRedis()}
+	clients := make(map[string]CacheRedis, len(shards))
+	for node, shard := range shards {
+		clients[node] = shard
+	}
+	store := NewShardedRedisCacheStore(clients, 160, "flux:cache:")
+	ctx := context.Background()
+	for i := 0; i < 300; i++ {
+		key := "key-" + strconv.Itoa(i)
+		assert.NoError(store.Save(ctx, key, &CacheRecord{StatusCode: http.StatusOK, Body: []byte(key)}, time.Minute))
+		// Key只写入映射的分片
+		_, ok := shards[store.Shard(key)].values["flux:cache:"+key]
+		assert.True(ok, key)
+	}
+	// Key分布到全部分片
+	for node, shard := range shards {
+		assert.True(len(shard.values) > 50, node)
+	}
+	record, ok, err := store.Load(ctx, "key-1")
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("key-1", string(record.Body))
+	_, ok, err = store.Load(ctx, "absent")
+	assert.NoError(err)
+	assert.False(ok)
+}
+
+func TestResponseCacheFilter_Cache(t *testing.T) {
+	assert := assert.New(t)
+	shard := newMapRedis()
+	filter := newResponseCacheFilter(t, map[string]CacheRedis{"redis": shard}, map[string]interface{}{
+		ConfigKeyCacheL1TTL: "0s",
+	})
+	calls := 0
+	next := cacheBackend(&calls, http.StatusOK, http.Header{"X-Users": []string{"1"}})
+	recorder := cacheInvoke(filter, "http://gateway/users?page=1", next)
+	assert.Equal(CacheStatusMiss, recorder.Header().Get(HeaderXCache))
+	recorder = cacheInvoke(filter, "http://gateway/users?page=1", next)
+	assert.Equal(1, calls)
+	assert.Equal(CacheStatusHit, recorder.Header().Get(HeaderXCache))
+	assert.Equal("1", recorder.Header().Get("X-Users"))
+	assert.Equal(`{"call":1}`, recorder.Body.String())
+	// 不同的Query参数使用不同的缓存Key
+	cacheInvoke(filter, "http://gateway/users?page=2", next)
+	assert.Equal(2, calls)
+	// Redis不可用时调用后端服务
+	shard.down = true
+	recorder = cacheInvoke(filter, "http://gateway/users?page=1", next)
+	assert.Equal(3, calls)
+	assert.Equal(http.StatusOK, recorder.Code)
+}
+
+func TestResponseCacheFilter_SkipResponses(t *testing.T) {
+	assert := assert.New(t)
+	filter := newResponseCacheFilter(t, map[string]CacheRedis{"redis": newMapRedis()}, map[string]interface{}{})
+	calls := 0
+	for _, next := range []flux.FilterInvoker{
+		cacheBackend(&calls, http.StatusNotFound, nil),
+		cacheBackend(&calls, http.StatusOK, http.Header{flux.HeaderCacheControl: []string{"max-age=0, no-store"}}),
+		cacheBackend(&calls, http.StatusOK, http.Header{flux.HeaderCacheControl: []string{"private"}}),
+	} {
+		calls = 0
+		cacheInvoke(filter, "http://gateway/users", next)
+		cacheInvoke(filter, "http://gateway/users", next)
+		assert.Equal(2, calls)
+	}
+	// 非 cacheable 的Endpoint不缓存
+	calls = 0
+	next := cacheBackend(&calls, http.StatusOK, nil)
+	for i := 0; i < 2; i++ {
+		ctx := flux.NewContext()
+		ctx.Reset(common.MockRequestContext("cache", httptest.NewRequest(http.MethodGet, "http://gateway/users", nil), nil, nil),
+			&flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/users"})
+		ctx.SetResponseWriter(httptest.NewRecorder())
+		assert.Nil(filter.DoFilter(next)(ctx))
+	}
+	assert.Equal(2, calls)
+}
+
+func TestResponseCacheFilter_HotKeyL1(t *testing.T) {
+	assert := assert.New(t)
+	shard := newMapRedis()
+	filter := newResponseCacheFilter(t, map[string]CacheRedis{"redis": shard}, map[string]interface{}{
+		ConfigKeyCacheL1TTL:        "200ms",
+		ConfigKeyCacheHotThreshold: 3,
+	})
+	calls := 0
+	next := cacheBackend(&calls, http.StatusOK, nil)
+	cacheInvoke(filter, "http://gateway/users", next)
+	assert.Equal(CacheStatusHit, cacheInvoke(filter, "http://gateway/users", next).Header().Get(HeaderXCache))
+	// 第3次访问达到热点阈值，写入L1缓存；之后的请求不再访问Redis
+	assert.Equal(CacheStatusHit, cacheInvoke(filter, "http://gateway/users", next).Header().Get(HeaderXCache))
+	gets := shard.gets
+	for i := 0; i < 5; i++ {
+		recorder := cacheInvoke(filter, "http://gateway/users", next)
+		assert.Equal(CacheStatusHitLocal, recorder.Header().Get(HeaderXCache))
+		assert.Equal(`{"call":1}`, recorder.Body.String())
+	}
+	assert.Equal(gets, shard.gets)
+	assert.Equal(1, calls)
+	// 非热点Key不写入L1缓存
+	cacheInvoke(filter, "http://gateway/users?page=2", next)
+	assert.Equal(CacheStatusHit, cacheInvoke(filter, "http://gateway/users?page=2", next).Header().Get(HeaderXCache))
+	// L1缓存过期后重新读取Redis
+	time.Sleep(time.Millisecond * 250)
+	assert.Equal(CacheStatusHit, cacheInvoke(filter, "http://gateway/users", next).Header().Get(HeaderXCache))
+}
+
+func TestResponseCacheFilter_L1Capacity(t *testing.T) {
+	assert := assert.New(t)
+	filter := newResponseCacheFilter(t, map[string]CacheRedis{"redis": newMapRedis()}, map[string]interface{}{
+		ConfigKeyCacheL1Size: 2,
+	})
+	for i := 0; i < 5; i++ {
+		filter.saveLocal("key-"+strconv.Itoa(i), &CacheRecord{StatusCode: http.StatusOK})
+		assert.True(len(filter.l1) <= 2)
+	}
+	// 新写入的Key总是可以进入L1缓存
+	_, ok := filter.loadLocal("key-4")
+	assert.True(ok)
+}
+
+func TestResponseCacheFilter_InitRequiresStore(t *testing.T) {
+	filter := NewResponseCacheFilter(ResponseCacheConfig{})
+	assert.Error(t, filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{})))
+}
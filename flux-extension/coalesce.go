@@ -0,0 +1,248 @@
+package fluxext
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdCoalesceFilter = "coalesce_filter"
+)
+
+const (
+	ConfigKeyCoalesceKeyParts    = "key_parts"
+	ConfigKeyCoalesceWaitTimeout = "wait_timeout"
+	ConfigKeyCoalesceMaxBody     = "max_body"
+)
+
+const (
+	// Endpoint属性：是否可缓存；可缓存的GET请求启用请求合并
+	EndpointAttrTagCacheable = "cacheable"
+	// Endpoint属性：请求合并Key的组成部分，覆盖全局配置 key_parts
+	EndpointAttrTagCoalesceKey = "coalescekey"
+)
+
+const (
+	// 请求合并Key的组成部分；header:<name>，query:<name>，cookie:<name> 读取指定的请求参数
+	CoalesceKeyMethod = "method"
+	CoalesceKeyPath   = "path"
+	CoalesceKeyQuery  = "query"
+	CoalesceKeyHost   = "host"
+)
+
+const (
+	HeaderXCoalesced = "X-Coalesced"
+)
+
+type (
+	// CoalesceKeyFunc 返回请求合并的Key；返回空字符串时不合并请求
+	CoalesceKeyFunc func(ctx *flux.Context) string
+)
+
+// CoalesceConfig 请求合并配置
+type CoalesceConfig struct {
+	SkipFunc flux.FilterSkipper
+	// KeyFunc 自定义请求合并Key；未设置时按 key_parts 配置生成
+	KeyFunc CoalesceKeyFunc
+}
+
+func NewCoalesceFilter(c CoalesceConfig) *CoalesceFilter {
+	return &CoalesceFilter{
+		Configs: c,
+	}
+}
+
+// CoalesceFilter 热点GET请求合并（singleflight）：Endpoint属性 cacheable 为true时，Key相同的并发GET请求只调用一次后端服务，
+// 其它请求等待并共享首个请求的响应（状态码，Header及数据），在流量突增时保护响应缓慢的后端服务。
+// 请求合并Key默认由请求方法，路径，排序后的Query参数，以及调用方身份（Authorization，Cookie）组成，不同用户的请求不会共享响应；
+// 可通过 key_parts 或Endpoint属性 coalescekey 配置。等待超过 wait_timeout 时，等待的请求独立调用后端服务；
+// 首个请求的响应无法共享时（例如响应数据超过 max_body），由等待的请求中的一个重新调用后端服务，其它请求继续等待。
+type CoalesceFilter struct {
+	Configs     CoalesceConfig
+	keyParts    []string
+	waitTimeout time.Duration
+	maxBody     int
+	calls       map[string]*coalesceCall
+	mu          sync.Mutex
+}
+
+type coalesceCall struct {
+	done     chan struct{}
+	response *coalesceResponse
+	serr     *flux.ServeError
+	waiters  int
+}
+
+type coalesceResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+func (f *CoalesceFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCoalesceKeyParts: []string{CoalesceKeyMethod, CoalesceKeyPath, CoalesceKeyQuery,
+			"header:" + flux.HeaderAuthorization, "header:" + flux.HeaderCookie},
+		ConfigKeyCoalesceWaitTimeout: time.Second * 5,
+		ConfigKeyCoalesceMaxBody:     1024 * 1024 * 4,
+	})
+	f.keyParts = config.GetStringSlice(ConfigKeyCoalesceKeyParts)
+	f.waitTimeout = config.GetDuration(ConfigKeyCoalesceWaitTimeout)
+	f.maxBody = config.GetInt(ConfigKeyCoalesceMaxBody)
+	f.calls = make(map[string]*coalesceCall, 64)
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if fluxpkg.IsNil(f.Configs.KeyFunc) {
+		f.Configs.KeyFunc = f.coalesceKey
+	}
+	logger.Infow("Coalesce filter initializing", "key-parts", f.keyParts, "wait-timeout", f.waitTimeout, "max-body", f.maxBody)
+	return nil
+}
+
+func (*CoalesceFilter) FilterId() string {
+	return TypeIdCoalesceFilter
+}
+
+func (f *CoalesceFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if ctx.Method() != http.MethodGet || !ctx.Endpoint().GetAttr(EndpointAttrTagCacheable).GetBool() || f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		key := f.Configs.KeyFunc(ctx)
+		if key == "" {
+			return next(ctx)
+		}
+		timer := time.NewTimer(f.waitTimeout)
+		defer timer.Stop()
+		for {
+			f.mu.Lock()
+			call, ok := f.calls[key]
+			if !ok {
+				call = &coalesceCall{done: make(chan struct{})}
+				f.calls[key] = call
+				f.mu.Unlock()
+				return f.lead(ctx, next, key, call)
+			}
+			call.waiters++
+			f.mu.Unlock()
+			select {
+			case <-call.done:
+				if nil != call.response {
+					return f.replay(ctx, call.response)
+				}
+				if nil != call.serr {
+					shared := *call.serr
+					return &shared
+				}
+				// 首个请求的响应无法共享，重新选择调用后端服务的请求
+			case <-timer.C:
+				logger.TraceContext(ctx).Infow("COALESCE:WAIT_TIMEOUT", "timeout", f.waitTimeout)
+				return next(ctx)
+			case <-ctx.Context().Done():
+				return &flux.ServeError{
+					StatusCode: flux.StatusOK,
+					ErrorCode:  flux.ErrorCodeGatewayCanceled,
+					Message:    "COALESCE:CANCELED:BYCLIENT",
+					CauseError: ctx.Context().Err(),
+				}
+			}
+		}
+	}
+}
+
+// lead 调用后端服务，并记录响应供等待的请求共享
+func (f *CoalesceFilter) lead(ctx *flux.Context, next flux.FilterInvoker, key string, call *coalesceCall) (serr *flux.ServeError) {
+	recorder := newResponseRecorder(ctx.ResponseWriter(), f.maxBody)
+	ctx.SetResponseWriter(recorder)
+	defer func() {
+		ctx.SetResponseWriter(recorder.ResponseWriter)
+		if status, header, body, ok := recorder.recorded(); ok {
+			call.response = &coalesceResponse{status: status, header: header, body: body}
+		} else if nil != serr && serr.ErrorCode != flux.ErrorCodeGatewayCanceled {
+			// 未写入响应的错误由错误处理函数输出，等待的请求返回相同的错误
+			call.serr = serr
+		}
+		f.mu.Lock()
+		delete(f.calls, key)
+		waiters := call.waiters
+		f.mu.Unlock()
+		close(call.done)
+		if waiters > 0 {
+			logger.TraceContext(ctx).Infow("COALESCE:SHARED", "waiters", waiters, "shared", nil != call.response || nil != call.serr)
+		}
+	}()
+	return next(ctx)
+}
+
+func (f *CoalesceFilter) replay(ctx *flux.Context, response *coalesceResponse) *flux.ServeError {
+	header := ctx.ResponseWriter().Header()
+	for name, values := range response.header {
+		header[name] = values
+	}
+	header.Set(HeaderXCoalesced, "true")
+	contentType := response.header.Get(flux.HeaderContentType)
+	if contentType == "" {
+		contentType = flux.MIMEApplicationJSONCharsetUTF8
+	}
+	if err := ctx.Write(response.status, contentType, response.body); nil != err {
+		return &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageTransportWriteResponse,
+			CauseError: err,
+		}
+	}
+	return nil
+}
+
+// coalesceKey 按 key_parts 或Endpoint属性 coalescekey 生成请求合并Key
+func (f *CoalesceFilter) coalesceKey(ctx *flux.Context) string {
+	parts := f.keyParts
+	if attr, ok := ctx.Endpoint().GetAttrEx(EndpointAttrTagCoalesceKey); ok {
+		parts = attr.GetStringSlice()
+	}
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, ctx.Endpoint().HttpPattern)
+	for _, part := range parts {
+		_, _ = hash.Write([]byte{0})
+		_, _ = io.WriteString(hash, coalesceKeyPart(ctx, part))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func coalesceKeyPart(ctx *flux.Context, part string) string {
+	switch {
+	case part == CoalesceKeyMethod:
+		return ctx.Method()
+	case part == CoalesceKeyPath:
+		return ctx.URL().Path
+	case part == CoalesceKeyQuery:
+		// Encode 按参数名排序
+		return ctx.QueryVars().Encode()
+	case part == CoalesceKeyHost:
+		return ctx.Host()
+	case strings.HasPrefix(part, "header:"):
+		return strings.Join(ctx.HeaderVars().Values(part[len("header:"):]), ",")
+	case strings.HasPrefix(part, "query:"):
+		return strings.Join(ctx.QueryVars()[part[len("query:"):]], ",")
+	case strings.HasPrefix(part, "cookie:"):
+		if cookie, err := ctx.CookieVar(part[len("cookie:"):]); nil == err {
+			return cookie.Value
+		}
+		return ""
+	default:
+		logger.LimitedWarnw("COALESCE:KEY_PART:UNKNOWN", "part", part)
+		return ""
+	}
+}
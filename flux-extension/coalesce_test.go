@@ -0,0 +1,186 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newCoalesceFilter(t *testing.T) *CoalesceFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	filter := NewCoalesceFilter(CoalesceConfig{})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfNS("coalesce_test")))
+	return filter
+}
+
+func newCoalesceContext(id string, headers map[string]string) (*flux.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(http.MethodGet, "http://gateway/users?id=1", nil)
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext(id, request, nil, nil), &flux.Endpoint{
+		HttpPattern: "/users",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: EndpointAttrTagCacheable, Value: true}},
+		},
+	})
+	ctx.SetResponseWriter(recorder)
+	return ctx, recorder
+}
+
+func TestCoalesceFilter_KeyByIdentity(t *testing.T) {
+	filter := newCoalesceFilter(t)
+	alice, _ := newCoalesceContext("alice", map[string]string{flux.HeaderAuthorization: "Bearer alice"})
+	bob, _ := newCoalesceContext("bob", map[string]string{flux.HeaderAuthorization: "Bearer bob"})
+	again, _ := newCoalesceContext("again", map[string]string{flux.HeaderAuthorization: "Bearer alice"})
+	assert.NotEqual(t, filter.coalesceKey(alice), filter.coalesceKey(bob))
+	assert.Equal(t, filter.coalesceKey(alice), filter.coalesceKey(again))
+	withCookie, _ := newCoalesceContext("cookie", map[string]string{flux.HeaderAuthorization: "Bearer alice", flux.HeaderCookie: "sid=1"})
+	assert.NotEqual(t, filter.coalesceKey(alice), filter.coalesceKey(withCookie))
+}
+
+func TestCoalesceFilter_ReplayRecordedResponse(t *testing.T) {
+	filter := newCoalesceFilter(t)
+	var calls int32
+	release := make(chan struct{})
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		ctx.ResponseWriter().Header().Set("X-Upstream", "users")
+		_ = ctx.Write(http.StatusAccepted, "text/plain; charset=utf-8", []byte("hello"))
+		return nil
+	})
+	recorders := make([]*httptest.ResponseRecorder, 4)
+	var wg sync.WaitGroup
+	for i := range recorders {
+		ctx, recorder := newCoalesceContext("replay", nil)
+		recorders[i] = recorder
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Nil(t, invoker(ctx))
+		}()
+	}
+	waitCoalesceWaiters(t, filter, len(recorders)-1)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	coalesced := 0
+	for _, recorder := range recorders {
+		assert.Equal(t, http.StatusAccepted, recorder.Code)
+		assert.Equal(t, "text/plain; charset=utf-8", recorder.Header().Get(flux.HeaderContentType))
+		assert.Equal(t, "users", recorder.Header().Get("X-Upstream"))
+		assert.Equal(t, "hello", recorder.Body.String())
+		if recorder.Header().Get(HeaderXCoalesced) == "true" {
+			coalesced++
+		}
+	}
+	assert.Equal(t, len(recorders)-1, coalesced)
+}
+
+func TestCoalesceFilter_NoResponseElectsNewLeader(t *testing.T) {
+	filter := newCoalesceFilter(t)
+	var calls int32
+	release := make(chan struct{})
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			// 首个请求未写入响应
+			<-release
+			return nil
+		}
+		// 等待其余请求加入重新选择的调用
+		for deadline := time.Now().Add(time.Second * 3); coalesceWaiters(filter) < 2 && time.Now().Before(deadline); {
+			time.Sleep(time.Millisecond)
+		}
+		_ = ctx.Write(http.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, []byte(`{}`))
+		return nil
+	})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		ctx, _ := newCoalesceContext("leader", nil)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = invoker(ctx)
+		}()
+	}
+	waitCoalesceWaiters(t, filter, 3)
+	close(release)
+	wg.Wait()
+	// 首个请求，以及重新选择的一个请求调用后端服务
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestCoalesceFilter_SharedError(t *testing.T) {
+	filter := newCoalesceFilter(t)
+	release := make(chan struct{})
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		<-release
+		return &flux.ServeError{StatusCode: flux.StatusBadGateway, ErrorCode: flux.ErrorCodeGatewayInternal, Message: "upstream"}
+	})
+	errs := make([]*flux.ServeError, 3)
+	var wg sync.WaitGroup
+	for i := range errs {
+		ctx, _ := newCoalesceContext("error", nil)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = invoker(ctx)
+		}(i)
+	}
+	waitCoalesceWaiters(t, filter, 2)
+	close(release)
+	wg.Wait()
+	for i, serr := range errs {
+		assert.NotNil(t, serr)
+		assert.Equal(t, "upstream", serr.Message)
+		for _, other := range errs[i+1:] {
+			assert.True(t, serr != other, "waiters must not share the same error instance")
+		}
+	}
+}
+
+func TestCoalesceFilter_SkipNonCacheable(t *testing.T) {
+	filter := newCoalesceFilter(t)
+	ctx, _ := newCoalesceContext("skip", nil)
+	ctx.Endpoint().Attributes = nil
+	var calls int32
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	})
+	assert.Nil(t, invoker(ctx))
+	assert.Equal(t, int32(1), calls)
+	assert.Empty(t, filter.calls)
+}
+
+func waitCoalesceWaiters(t *testing.T, filter *CoalesceFilter, waiters int) {
+	deadline := time.Now().Add(time.Second * 3)
+	for time.Now().Before(deadline) {
+		if coalesceWaiters(filter) >= waiters {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("coalesce waiters not reached: %d", waiters)
+}
+
+func coalesceWaiters(filter *CoalesceFilter) int {
+	filter.mu.Lock()
+	defer filter.mu.Unlock()
+	count := 0
+	for _, call := range filter.calls {
+		count += call.waiters
+	}
+	return count
+}
@@ -0,0 +1,302 @@
+package fluxext
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	TypeIdCSRFFilter = "csrf_filter"
+)
+
+const (
+	ConfigKeyCSRFMode          = "mode"
+	ConfigKeyCSRFSecret        = "secret"
+	ConfigKeyCSRFApplications  = "applications"
+	ConfigKeyCSRFCookieName    = "cookie_name"
+	ConfigKeyCSRFCookiePath    = "cookie_path"
+	ConfigKeyCSRFCookieDomain  = "cookie_domain"
+	ConfigKeyCSRFCookieMaxAge  = "cookie_max_age"
+	ConfigKeyCSRFSameSite      = "same_site"
+	ConfigKeyCSRFSecure        = "secure"
+	ConfigKeyCSRFHeaderName    = "header_name"
+	ConfigKeyCSRFFormField     = "form_field"
+	ConfigKeyCSRFSessionCookie = "session_cookie"
+	ConfigKeyCSRFTokenPath     = "token_path"
+	ConfigKeyCSRFTokenListener = "token_listeners"
+)
+
+const (
+	// Endpoint属性：是否启用CSRF校验
+	EndpointAttrTagCSRF = "csrf"
+)
+
+const (
+	// CSRFModeDoubleSubmit 双重提交Cookie：请求Header（或表单字段）的Token必须与Cookie中的Token一致
+	CSRFModeDoubleSubmit = "double_submit"
+	// CSRFModeSynchronizer 同步器Token：Token与会话Cookie绑定签名，校验签名，不依赖Token Cookie
+	CSRFModeSynchronizer = "synchronizer"
+)
+
+const (
+	ErrorCodeCSRFTokenInvalid = "PERMISSION:CSRF_TOKEN_INVALID"
+)
+
+// CSRFConfig CSRF校验配置
+type CSRFConfig struct {
+	SkipFunc flux.FilterSkipper
+}
+
+func NewCSRFFilter(c CSRFConfig) *CSRFFilter {
+	return &CSRFFilter{
+		Configs: c,
+	}
+}
+
+var (
+	_ flux.WebHandlerRegistrar = new(CSRFFilter)
+)
+
+// CSRFFilter 面向浏览器的Endpoint的跨站请求伪造（CSRF）校验；对Endpoint属性 csrf 为true，或者所属应用在 applications 列表中的
+// 非安全方法（POST，PUT，DELETE，PATCH）请求，校验请求Header或表单字段携带的Token。
+// Token 通过 TokenHandler 签发，注册到 token_listeners 的 GET token_path 路径：双重提交模式下写入Cookie并返回Token；
+// 配置密钥时，Token与会话Cookie绑定签名；同步器模式下必须存在会话Cookie。
+type CSRFFilter struct {
+	Configs       CSRFConfig
+	mode          string
+	secret        []byte
+	applications  []string
+	cookieName    string
+	cookiePath    string
+	cookieDomain  string
+	cookieMaxAge  time.Duration
+	sameSite      http.SameSite
+	secure        bool
+	headerName    string
+	formField     string
+	sessionCookie string
+	tokenPath     string
+	tokenServers  []string
+}
+
+func (f *CSRFFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCSRFMode:          CSRFModeDoubleSubmit,
+		ConfigKeyCSRFCookieName:    "XSRF-TOKEN",
+		ConfigKeyCSRFCookiePath:    "/",
+		ConfigKeyCSRFCookieMaxAge:  time.Hour * 12,
+		ConfigKeyCSRFSameSite:      "lax",
+		ConfigKeyCSRFSecure:        true,
+		ConfigKeyCSRFHeaderName:    flux.HeaderXCSRFToken,
+		ConfigKeyCSRFFormField:     "_csrf",
+		ConfigKeyCSRFSessionCookie: "SESSION",
+		ConfigKeyCSRFTokenPath:     "/csrf/token",
+		ConfigKeyCSRFTokenListener: []string{"default"},
+	})
+	f.mode = strings.ToLower(config.GetString(ConfigKeyCSRFMode))
+	secret, err := config.GetStringE(ConfigKeyCSRFSecret)
+	if nil != err {
+		return fmt.Errorf("CSRFFilter: %w", err)
+	}
+	f.secret = []byte(secret)
+	switch f.mode {
+	case CSRFModeDoubleSubmit:
+	case CSRFModeSynchronizer:
+		if len(f.secret) == 0 {
+			return fmt.Errorf("CSRFFilter: <secret> is required by mode: %s", f.mode)
+		}
+	default:
+		return fmt.Errorf("CSRFFilter: unknown mode: %s", f.mode)
+	}
+	switch strings.ToLower(config.GetString(ConfigKeyCSRFSameSite)) {
+	case "strict":
+		f.sameSite = http.SameSiteStrictMode
+	case "none":
+		f.sameSite = http.SameSiteNoneMode
+	case "lax":
+		f.sameSite = http.SameSiteLaxMode
+	default:
+		return fmt.Errorf("CSRFFilter: unknown same_site: %s", config.GetString(ConfigKeyCSRFSameSite))
+	}
+	f.applications = config.GetStringSlice(ConfigKeyCSRFApplications)
+	f.cookieName = config.GetString(ConfigKeyCSRFCookieName)
+	f.cookiePath = config.GetString(ConfigKeyCSRFCookiePath)
+	f.cookieDomain = config.GetString(ConfigKeyCSRFCookieDomain)
+	f.cookieMaxAge = config.GetDuration(ConfigKeyCSRFCookieMaxAge)
+	f.secure = config.GetBool(ConfigKeyCSRFSecure)
+	f.headerName = config.GetString(ConfigKeyCSRFHeaderName)
+	f.formField = config.GetString(ConfigKeyCSRFFormField)
+	f.sessionCookie = config.GetString(ConfigKeyCSRFSessionCookie)
+	f.tokenPath = config.GetString(ConfigKeyCSRFTokenPath)
+	f.tokenServers = config.GetStringSlice(ConfigKeyCSRFTokenListener)
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Infow("CSRF filter initializing", "mode", f.mode, "applications", f.applications,
+		"cookie-name", f.cookieName, "same-site", config.GetString(ConfigKeyCSRFSameSite), "header-name", f.headerName,
+		"token-path", f.tokenPath, "token-listeners", f.tokenServers)
+	return nil
+}
+
+func (*CSRFFilter) FilterId() string {
+	return TypeIdCSRFFilter
+}
+
+func (f *CSRFFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) || !f.enabled(ctx.Endpoint()) || isSafeMethod(ctx.Method()) {
+			return next(ctx)
+		}
+		if err := f.verify(ctx); nil != err {
+			logger.TraceContext(ctx).Infow("CSRF:VERIFY:REJECTED", "mode", f.mode, "error", err)
+			return &flux.ServeError{
+				StatusCode: flux.StatusAccessDenied,
+				ErrorCode:  ErrorCodeCSRFTokenInvalid,
+				Message:    "CSRF:TOKEN_INVALID",
+				CauseError: err,
+			}
+		}
+		return next(ctx)
+	}
+}
+
+// RegisterWebHandlers 向 token_listeners 中的WebListener注册签发Token的接口：GET token_path
+func (f *CSRFFilter) RegisterWebHandlers(listenerId string, server flux.WebListener) {
+	if f.tokenPath == "" || !fluxpkg.StringSliceContains(f.tokenServers, listenerId) {
+		return
+	}
+	server.AddHandler(http.MethodGet, f.tokenPath, f.TokenHandler)
+}
+
+// TokenHandler 签发CSRF Token的处理接口；双重提交模式下同时写入Token Cookie；
+// 响应：{"token": "...", "header": "X-CSRF-Token"}
+func (f *CSRFFilter) TokenHandler(webex flux.ServerWebContext) error {
+	binding := f.sessionOf(webex)
+	if f.mode == CSRFModeSynchronizer && binding == "" {
+		return webex.Write(flux.StatusUnauthorized, flux.MIMEApplicationJSONCharsetUTF8,
+			[]byte(`{"error":"session required"}`))
+	}
+	token, err := f.newToken(binding)
+	if nil != err {
+		return err
+	}
+	if f.mode == CSRFModeDoubleSubmit {
+		http.SetCookie(webex.ResponseWriter(), &http.Cookie{
+			Name:     f.cookieName,
+			Value:    token,
+			Path:     f.cookiePath,
+			Domain:   f.cookieDomain,
+			MaxAge:   int(f.cookieMaxAge / time.Second),
+			Secure:   f.secure,
+			SameSite: f.sameSite,
+			// 前端脚本需要读取Cookie并设置到请求Header
+			HttpOnly: false,
+		})
+	}
+	data, _ := json.Marshal(map[string]string{"token": token, "header": f.headerName})
+	return webex.Write(flux.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, data)
+}
+
+func (f *CSRFFilter) enabled(endpoint *flux.Endpoint) bool {
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagCSRF); ok {
+		return attr.GetBool()
+	}
+	return fluxpkg.StringSliceContains(f.applications, endpoint.Application)
+}
+
+func (f *CSRFFilter) verify(ctx *flux.Context) error {
+	token := ctx.HeaderVar(f.headerName)
+	if token == "" && f.formField != "" && isFormRequest(ctx.HeaderVar(flux.HeaderContentType)) {
+		token = ctx.FormVar(f.formField)
+	}
+	if token == "" {
+		return fmt.Errorf("token not found")
+	}
+	binding := f.sessionOf(ctx)
+	switch f.mode {
+	case CSRFModeSynchronizer:
+		if binding == "" {
+			return fmt.Errorf("session cookie not found: %s", f.sessionCookie)
+		}
+		return f.verifyToken(token, binding)
+	default:
+		cookie, err := ctx.CookieVar(f.cookieName)
+		if nil != err || cookie.Value == "" {
+			return fmt.Errorf("token cookie not found: %s", f.cookieName)
+		}
+		if subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(token)) != 1 {
+			return fmt.Errorf("token mismatch with cookie")
+		}
+		if len(f.secret) == 0 {
+			return nil
+		}
+		// 配置密钥时，Token与会话绑定，其它会话的Token无效
+		return f.verifyToken(token, binding)
+	}
+}
+
+// sessionOf 返回请求的会话Cookie值；不存在时返回空字符串
+func (f *CSRFFilter) sessionOf(webex flux.ServerWebContext) string {
+	if session, err := webex.CookieVar(f.sessionCookie); nil == err {
+		return session.Value
+	}
+	return ""
+}
+
+// newToken 生成Token：随机数.签名；未配置密钥时只包含随机数
+func (f *CSRFFilter) newToken(binding string) (string, error) {
+	nonce := make([]byte, 24)
+	if _, err := rand.Read(nonce); nil != err {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	if len(f.secret) == 0 {
+		return encoded, nil
+	}
+	return encoded + "." + f.sign(encoded, binding), nil
+}
+
+func (f *CSRFFilter) verifyToken(token, binding string) error {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("token malformed")
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(f.sign(parts[0], binding))) {
+		return fmt.Errorf("token signature invalid")
+	}
+	return nil
+}
+
+func (f *CSRFFilter) sign(nonce, binding string) string {
+	mac := hmac.New(sha256.New, f.secret)
+	_, _ = mac.Write([]byte(binding))
+	_, _ = mac.Write([]byte{0})
+	_, _ = mac.Write([]byte(nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
+
+func isFormRequest(contentType string) bool {
+	return strings.HasPrefix(contentType, flux.MIMEApplicationForm) || strings.HasPrefix(contentType, flux.MIMEMultipartForm)
+}
@@ -0,0 +1,111 @@
+package fluxext
+
+import (
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newCSRFFilter(t *testing.T, config map[string]interface{}) *CSRFFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	filter := NewCSRFFilter(CSRFConfig{})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(config)))
+	return filter
+}
+
+func newCSRFContext(method string, cookies map[string]string, headers map[string]string) (*flux.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(method, "http://gateway/orders", nil)
+	for name, value := range cookies {
+		request.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("csrf", request, nil, nil), &flux.Endpoint{
+		HttpPattern: "/orders",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: EndpointAttrTagCSRF, Value: true}},
+		},
+	})
+	ctx.SetResponseWriter(recorder)
+	return ctx, recorder
+}
+
+// issueCSRFToken 通过TokenHandler签发Token
+func issueCSRFToken(t *testing.T, filter *CSRFFilter, cookies map[string]string) (string, *httptest.ResponseRecorder) {
+	ctx, recorder := newCSRFContext(http.MethodGet, cookies, nil)
+	assert.NoError(t, filter.TokenHandler(ctx))
+	var out map[string]string
+	if recorder.Code == http.StatusOK {
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &out))
+	}
+	return out["token"], recorder
+}
+
+func csrfVerify(filter *CSRFFilter, cookies map[string]string, token string) *flux.ServeError {
+	ctx, _ := newCSRFContext(http.MethodPost, cookies, map[string]string{flux.HeaderXCSRFToken: token})
+	return filter.DoFilter(func(_ *flux.Context) *flux.ServeError {
+		return nil
+	})(ctx)
+}
+
+func TestCSRFFilter_DoubleSubmit(t *testing.T) {
+	assert := assert.New(t)
+	filter := newCSRFFilter(t, map[string]interface{}{})
+	token, recorder := issueCSRFToken(t, filter, nil)
+	assert.NotEmpty(token)
+	cookies := recorder.Result().Cookies()
+	if assert.Len(cookies, 1) {
+		assert.Equal("XSRF-TOKEN", cookies[0].Name)
+		assert.Equal(token, cookies[0].Value)
+	}
+	assert.Nil(csrfVerify(filter, map[string]string{"XSRF-TOKEN": token}, token))
+	serr := csrfVerify(filter, map[string]string{"XSRF-TOKEN": token}, token+"x")
+	if assert.NotNil(serr) {
+		assert.Equal(ErrorCodeCSRFTokenInvalid, serr.ErrorCode)
+	}
+	assert.NotNil(csrfVerify(filter, nil, token))
+	// 安全方法不校验
+	ctx, _ := newCSRFContext(http.MethodGet, nil, nil)
+	assert.Nil(filter.DoFilter(func(_ *flux.Context) *flux.ServeError { return nil })(ctx))
+}
+
+func TestCSRFFilter_DoubleSubmitSessionBinding(t *testing.T) {
+	assert := assert.New(t)
+	filter := newCSRFFilter(t, map[string]interface{}{ConfigKeyCSRFSecret: "s3cret"})
+	token, _ := issueCSRFToken(t, filter, map[string]string{"SESSION": "alice"})
+	assert.Nil(csrfVerify(filter, map[string]string{"SESSION": "alice", "XSRF-TOKEN": token}, token))
+	// 其它会话不能使用此Token
+	assert.NotNil(csrfVerify(filter, map[string]string{"SESSION": "mallory", "XSRF-TOKEN": token}, token))
+	assert.NotNil(csrfVerify(filter, map[string]string{"XSRF-TOKEN": token}, token))
+}
+
+func TestCSRFFilter_Synchronizer(t *testing.T) {
+	assert := assert.New(t)
+	filter := newCSRFFilter(t, map[string]interface{}{
+		ConfigKeyCSRFMode:   CSRFModeSynchronizer,
+		ConfigKeyCSRFSecret: "s3cret",
+	})
+	_, recorder := issueCSRFToken(t, filter, nil)
+	assert.Equal(http.StatusUnauthorized, recorder.Code)
+	token, recorder := issueCSRFToken(t, filter, map[string]string{"SESSION": "alice"})
+	assert.Empty(recorder.Result().Cookies())
+	assert.Nil(csrfVerify(filter, map[string]string{"SESSION": "alice"}, token))
+	assert.NotNil(csrfVerify(filter, map[string]string{"SESSION": "bob"}, token))
+	assert.NotNil(csrfVerify(filter, nil, token))
+}
+
+func TestCSRFFilter_InitRequiresSecret(t *testing.T) {
+	filter := NewCSRFFilter(CSRFConfig{})
+	assert.Error(t, filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyCSRFMode: CSRFModeSynchronizer,
+	})))
+}
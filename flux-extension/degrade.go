@@ -0,0 +1,244 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+const (
+	TypeIdDegradeFilter = "degrade_filter"
+)
+
+const (
+	ConfigKeyDegradeTier           = "tier"
+	ConfigKeyOverloadReduced       = "overload_reduced"
+	ConfigKeyOverloadEssential     = "overload_essential"
+	ConfigKeyDegradeAdminPath      = "admin_path"
+	ConfigKeyDegradeAdminListeners = "admin_listeners"
+)
+
+const (
+	// Endpoint属性：所属降级层级，默认为 full
+	EndpointAttrTagDegradeTier = "degradetier"
+	// Endpoint属性：降级时返回的Fallback响应数据(JSON)
+	EndpointAttrTagDegradeFallback = "degradefallback"
+)
+
+// DegradeTier 服务降级层级；网关处于某一层级时，只服务层级不低于此层级的Endpoint
+type DegradeTier int32
+
+const (
+	DegradeTierFull      DegradeTier = iota // 全量服务
+	DegradeTierReduced                      // 降级服务：只服务 reduced, essential 的Endpoint
+	DegradeTierEssential                    // 核心服务：只服务 essential 的Endpoint
+)
+
+var degradeTierNames = []string{"full", "reduced", "essential"}
+
+var (
+	_ flux.WebHandlerRegistrar = new(DegradeFilter)
+)
+
+func (t DegradeTier) String() string {
+	if t < DegradeTierFull || t > DegradeTierEssential {
+		return "unknown"
+	}
+	return degradeTierNames[t]
+}
+
+// ParseDegradeTier 解析降级层级名称
+func ParseDegradeTier(name string) (DegradeTier, bool) {
+	for i, n := range degradeTierNames {
+		if strings.EqualFold(n, strings.TrimSpace(name)) {
+			return DegradeTier(i), true
+		}
+	}
+	return DegradeTierFull, false
+}
+
+type (
+	// DegradeFallbackFunc 降级处理函数
+	DegradeFallbackFunc func(ctx *flux.Context, tier DegradeTier) *flux.ServeError
+	// DegradeOverloadFunc 自动过载信号函数，返回当前过载状态建议的降级层级
+	DegradeOverloadFunc func(inflight int64) DegradeTier
+)
+
+// DegradeConfig 服务降级配置
+type DegradeConfig struct {
+	SkipFunc     flux.FilterSkipper
+	FallbackFunc DegradeFallbackFunc
+	OverloadFunc DegradeOverloadFunc
+}
+
+func NewDegradeFilter(c DegradeConfig) *DegradeFilter {
+	return &DegradeFilter{
+		Configs: c,
+	}
+}
+
+// DegradeFilter 按Endpoint的降级层级，在手动切换或者过载时，优先拒绝非核心Endpoint的请求
+type DegradeFilter struct {
+	Configs      DegradeConfig
+	manual       int32
+	inflight     int64
+	adminPath    string
+	adminServers []string
+}
+
+func (r *DegradeFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyDegradeTier:           "full",
+		ConfigKeyOverloadReduced:       0,
+		ConfigKeyOverloadEssential:     0,
+		ConfigKeyDegradeAdminPath:      "/debug/degrade",
+		ConfigKeyDegradeAdminListeners: []string{"admin"},
+	})
+	tier, ok := ParseDegradeTier(config.GetString(ConfigKeyDegradeTier))
+	if !ok {
+		logger.Warnw("Degrade filter, unknown tier, use full", "tier", config.GetString(ConfigKeyDegradeTier))
+	}
+	r.SetTier(tier)
+	r.adminPath = config.GetString(ConfigKeyDegradeAdminPath)
+	r.adminServers = config.GetStringSlice(ConfigKeyDegradeAdminListeners)
+	if fluxpkg.IsNil(r.Configs.SkipFunc) {
+		r.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if fluxpkg.IsNil(r.Configs.FallbackFunc) {
+		r.Configs.FallbackFunc = DefaultDegradeFallbackFunc
+	}
+	if fluxpkg.IsNil(r.Configs.OverloadFunc) {
+		reduced, essential := config.GetInt64(ConfigKeyOverloadReduced), config.GetInt64(ConfigKeyOverloadEssential)
+		r.Configs.OverloadFunc = NewInflightOverloadFunc(reduced, essential)
+	}
+	logger.Infow("Degrade filter initializing", "tier", tier,
+		"overload-reduced", config.GetInt64(ConfigKeyOverloadReduced),
+		"overload-essential", config.GetInt64(ConfigKeyOverloadEssential),
+		"admin-path", r.adminPath, "admin-listeners", r.adminServers)
+	return nil
+}
+
+func (*DegradeFilter) FilterId() string {
+	return TypeIdDegradeFilter
+}
+
+func (r *DegradeFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if r.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		inflight := atomic.AddInt64(&r.inflight, 1)
+		defer atomic.AddInt64(&r.inflight, -1)
+		tier := r.effectiveTier(inflight)
+		if epTier := EndpointDegradeTier(ctx.Endpoint()); epTier < tier {
+			logger.TraceContext(ctx).Infow("DEGRADE:REJECTED", "tier", tier, "endpoint-tier", epTier)
+			return r.Configs.FallbackFunc(ctx, tier)
+		}
+		return next(ctx)
+	}
+}
+
+// SetTier 手动切换网关的降级层级
+func (r *DegradeFilter) SetTier(tier DegradeTier) {
+	atomic.StoreInt32(&r.manual, int32(tier))
+}
+
+// Tier 返回手动设置的降级层级
+func (r *DegradeFilter) Tier() DegradeTier {
+	return DegradeTier(atomic.LoadInt32(&r.manual))
+}
+
+// EffectiveTier 返回当前实际生效的降级层级：手动设置与过载信号中较高的层级
+func (r *DegradeFilter) EffectiveTier() DegradeTier {
+	return r.effectiveTier(atomic.LoadInt64(&r.inflight))
+}
+
+func (r *DegradeFilter) effectiveTier(inflight int64) DegradeTier {
+	tier := r.Tier()
+	if auto := r.Configs.OverloadFunc(inflight); auto > tier {
+		return auto
+	}
+	return tier
+}
+
+// RegisterWebHandlers 向 admin_listeners 中的WebListener注册降级层级的管理接口：GET/POST admin_path
+func (r *DegradeFilter) RegisterWebHandlers(listenerId string, server flux.WebListener) {
+	if r.adminPath == "" || !fluxpkg.StringSliceContains(r.adminServers, listenerId) {
+		return
+	}
+	handler := r.AdminHandler()
+	server.AddHandler(http.MethodGet, r.adminPath, handler)
+	server.AddHandler(http.MethodPost, r.adminPath, handler)
+}
+
+// AdminHandler 返回降级层级的管理接口：GET 查询状态；POST 以参数 tier 切换层级
+func (r *DegradeFilter) AdminHandler() flux.WebHandler {
+	return func(webex flux.ServerWebContext) error {
+		if webex.Method() == http.MethodPost {
+			name := webex.FormVar(ConfigKeyDegradeTier)
+			if name == "" {
+				name = webex.QueryVar(ConfigKeyDegradeTier)
+			}
+			tier, ok := ParseDegradeTier(name)
+			if !ok {
+				return webex.Write(flux.StatusBadRequest, flux.MIMETextPlainCharsetUTF8, []byte("unknown tier: "+name))
+			}
+			logger.Infow("DEGRADE:TIER:SWITCH", "from", r.Tier(), "to", tier)
+			r.SetTier(tier)
+		}
+		bytes, err := common.SerializeObject(map[string]interface{}{
+			"tier":      r.Tier().String(),
+			"effective": r.EffectiveTier().String(),
+			"inflight":  atomic.LoadInt64(&r.inflight),
+		})
+		if nil != err {
+			return err
+		}
+		return webex.Write(flux.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, bytes)
+	}
+}
+
+// EndpointDegradeTier 返回Endpoint所属的降级层级；未定义时为 full
+func EndpointDegradeTier(endpoint *flux.Endpoint) DegradeTier {
+	tier, _ := ParseDegradeTier(endpoint.GetAttr(EndpointAttrTagDegradeTier).GetString())
+	return tier
+}
+
+// NewInflightOverloadFunc 根据并发请求数量判定过载层级；阈值为0时不启用
+func NewInflightOverloadFunc(reduced, essential int64) DegradeOverloadFunc {
+	return func(inflight int64) DegradeTier {
+		if essential > 0 && inflight > essential {
+			return DegradeTierEssential
+		}
+		if reduced > 0 && inflight > reduced {
+			return DegradeTierReduced
+		}
+		return DegradeTierFull
+	}
+}
+
+// DefaultDegradeFallbackFunc 返回Endpoint定义的Fallback数据；未定义时返回服务不可用错误
+func DefaultDegradeFallbackFunc(ctx *flux.Context, tier DegradeTier) *flux.ServeError {
+	if fallback := ctx.Endpoint().GetAttr(EndpointAttrTagDegradeFallback).GetString(); fallback != "" {
+		if err := ctx.Write(flux.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, []byte(fallback)); nil != err {
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessageTransportWriteResponse,
+				CauseError: err,
+			}
+		}
+		return nil
+	}
+	return &flux.ServeError{
+		StatusCode: http.StatusServiceUnavailable,
+		ErrorCode:  flux.ErrorCodeGatewayDegraded,
+		Message:    "DEGRADED:SERVER_BUSY:" + strings.ToUpper(tier.String()),
+	}
+}
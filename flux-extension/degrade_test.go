@@ -0,0 +1,168 @@
+package fluxext
+
+import (
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// handlerListener 记录注册的处理接口的WebListener
+type handlerListener struct {
+	flux.WebListener
+	handlers map[string]flux.WebHandler
+}
+
+func (l *handlerListener) AddHandler(method, pattern string, h flux.WebHandler, _ ...flux.WebInterceptor) {
+	l.handlers[method+" "+pattern] = h
+}
+
+func newDegradeFilter(t *testing.T, config map[string]interface{}) *DegradeFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	filter := NewDegradeFilter(DegradeConfig{})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(config)))
+	return filter
+}
+
+func newDegradeContext(request *http.Request, attrs ...flux.Attribute) (*flux.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("degrade", request, nil, nil), &flux.Endpoint{
+		HttpPattern:        "/users",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+	})
+	ctx.SetResponseWriter(recorder)
+	return ctx, recorder
+}
+
+func degradeInvoke(filter *DegradeFilter, tier string, next flux.FilterInvoker) (*flux.ServeError, *httptest.ResponseRecorder) {
+	var attrs []flux.Attribute
+	if tier != "" {
+		attrs = append(attrs, flux.Attribute{Name: EndpointAttrTagDegradeTier, Value: tier})
+	}
+	ctx, recorder := newDegradeContext(httptest.NewRequest(http.MethodGet, "http://gateway/users", nil), attrs...)
+	return filter.DoFilter(next)(ctx), recorder
+}
+
+func degradeNext(_ *flux.Context) *flux.ServeError {
+	return nil
+}
+
+func TestParseDegradeTier(t *testing.T) {
+	assert := assert.New(t)
+	cases := map[string]DegradeTier{
+		"full":        DegradeTierFull,
+		" Reduced ":   DegradeTierReduced,
+		"ESSENTIAL":   DegradeTierEssential,
+		"":            DegradeTierFull,
+		"unsupported": DegradeTierFull,
+	}
+	for name, expected := range cases {
+		tier, ok := ParseDegradeTier(name)
+		assert.Equal(expected, tier, name)
+		assert.Equal(strings.TrimSpace(name) != "" && name != "unsupported", ok, name)
+	}
+	assert.Equal("unknown", DegradeTier(9).String())
+}
+
+func TestDegradeFilter_Decision(t *testing.T) {
+	assert := assert.New(t)
+	filter := newDegradeFilter(t, map[string]interface{}{ConfigKeyDegradeTier: "reduced"})
+	// 低于当前层级的Endpoint被拒绝，未定义层级的Endpoint为 full
+	for _, tier := range []string{"", "full"} {
+		serr, _ := degradeInvoke(filter, tier, degradeNext)
+		if assert.NotNil(serr, tier) {
+			assert.Equal(http.StatusServiceUnavailable, serr.StatusCode)
+			assert.Equal(flux.ErrorCodeGatewayDegraded, serr.ErrorCode)
+			assert.Equal("DEGRADED:SERVER_BUSY:REDUCED", serr.Message)
+		}
+	}
+	for _, tier := range []string{"reduced", "essential"} {
+		serr, _ := degradeInvoke(filter, tier, degradeNext)
+		assert.Nil(serr, tier)
+	}
+	// 定义Fallback数据时返回Fallback响应
+	ctx, recorder := newDegradeContext(httptest.NewRequest(http.MethodGet, "http://gateway/users", nil),
+		flux.Attribute{Name: EndpointAttrTagDegradeFallback, Value: `{"items":[]}`})
+	assert.Nil(filter.DoFilter(degradeNext)(ctx))
+	assert.Equal(http.StatusOK, recorder.Code)
+	assert.Equal(`{"items":[]}`, recorder.Body.String())
+}
+
+func TestDegradeFilter_Overload(t *testing.T) {
+	assert := assert.New(t)
+	filter := newDegradeFilter(t, map[string]interface{}{
+		ConfigKeyOverloadReduced:   1,
+		ConfigKeyOverloadEssential: 2,
+	})
+	assert.Equal(DegradeTierFull, filter.EffectiveTier())
+	// 在请求处理过程中发起的请求，并发数超过 overload_reduced 阈值
+	var inner *flux.ServeError
+	outer, _ := degradeInvoke(filter, "", func(_ *flux.Context) *flux.ServeError {
+		assert.Equal(DegradeTierFull, filter.EffectiveTier())
+		inner, _ = degradeInvoke(filter, "full", degradeNext)
+		reduced, _ := degradeInvoke(filter, "reduced", func(_ *flux.Context) *flux.ServeError {
+			assert.Equal(DegradeTierReduced, filter.EffectiveTier())
+			// 并发数超过 overload_essential 阈值
+			serr, _ := degradeInvoke(filter, "reduced", degradeNext)
+			assert.NotNil(serr)
+			return nil
+		})
+		assert.Nil(reduced)
+		return nil
+	})
+	assert.Nil(outer)
+	if assert.NotNil(inner) {
+		assert.Equal(flux.ErrorCodeGatewayDegraded, inner.ErrorCode)
+	}
+	// 手动层级高于过载层级时，以手动层级为准
+	filter.SetTier(DegradeTierEssential)
+	assert.Equal(DegradeTierEssential, filter.EffectiveTier())
+}
+
+func TestDegradeFilter_AdminSwitch(t *testing.T) {
+	assert := assert.New(t)
+	filter := newDegradeFilter(t, map[string]interface{}{})
+	admin := &handlerListener{handlers: make(map[string]flux.WebHandler)}
+	filter.RegisterWebHandlers("default", admin)
+	assert.Empty(admin.handlers)
+	filter.RegisterWebHandlers("admin", admin)
+	handler, ok := admin.handlers["POST /debug/degrade"]
+	assert.True(ok)
+	assert.Contains(admin.handlers, "GET /debug/degrade")
+
+	switchTo := func(method, tier string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		ctx, recorder := newDegradeContext(httptest.NewRequest(method, "http://gateway/debug/degrade?tier="+tier, nil))
+		assert.NoError(handler(ctx))
+		out := make(map[string]interface{})
+		if recorder.Code == http.StatusOK {
+			assert.NoError(json.Unmarshal(recorder.Body.Bytes(), &out))
+		}
+		return recorder, out
+	}
+	_, out := switchTo(http.MethodPost, "essential")
+	assert.Equal("essential", out["tier"])
+	assert.Equal("essential", out["effective"])
+	assert.Equal(DegradeTierEssential, filter.Tier())
+	serr, _ := degradeInvoke(filter, "reduced", degradeNext)
+	assert.NotNil(serr)
+
+	recorder, _ := switchTo(http.MethodPost, "unsupported")
+	assert.Equal(http.StatusBadRequest, recorder.Code)
+	assert.Equal(DegradeTierEssential, filter.Tier())
+	// GET只查询状态，不切换层级
+	_, out = switchTo(http.MethodGet, "full")
+	assert.Equal("essential", out["tier"])
+
+	_, _ = switchTo(http.MethodPost, "full")
+	assert.Equal(DegradeTierFull, filter.Tier())
+	serr, _ = degradeInvoke(filter, "", degradeNext)
+	assert.Nil(serr)
+}
@@ -0,0 +1,257 @@
+package fluxext
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	TypeIdDigestFilter = "digest_filter"
+)
+
+const (
+	ConfigKeyDigestAlgorithm       = "algorithm"
+	ConfigKeyDigestResponseHeaders = "response_headers"
+	ConfigKeyDigestMaxDecodedSize  = "max_decoded_size"
+)
+
+const (
+	// Endpoint属性：内容摘要选项，可定义多个：response 输出响应摘要；verify 校验请求摘要；require 请求必须携带摘要
+	EndpointAttrTagDigest = "digest"
+)
+
+const (
+	DigestOptionResponse = "response"
+	DigestOptionVerify   = "verify"
+	DigestOptionRequire  = "require"
+)
+
+const (
+	HeaderDigest         = "Digest"
+	HeaderContentMD5     = "Content-MD5"
+	HeaderXContentSha256 = "X-Content-Sha256"
+)
+
+const (
+	ErrorCodeDigestMismatch = "REQUEST:DIGEST:MISMATCH"
+	ErrorCodeDigestRequired = "REQUEST:DIGEST:REQUIRED"
+)
+
+var digestHashes = map[string]func() hash.Hash{
+	"SHA-256": sha256.New,
+	"SHA-512": sha512.New,
+	"MD5":     md5.New,
+}
+
+// DigestConfig 内容摘要配置
+type DigestConfig struct {
+	SkipFunc flux.FilterSkipper
+}
+
+func NewDigestFilter(c DigestConfig) *DigestFilter {
+	return &DigestFilter{
+		Configs: c,
+	}
+}
+
+// DigestFilter 按Endpoint定义，为响应数据输出内容摘要Header（Digest，Content-MD5，X-Content-Sha256），并校验请求数据的内容摘要；
+// 摘要按实际传输的数据计算；请求数据为gzip编码时，兼容按解码后的数据计算的摘要。
+type DigestFilter struct {
+	Configs    DigestConfig
+	algorithm  string
+	headers    []string
+	maxDecoded int64
+}
+
+func (f *DigestFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyDigestAlgorithm:       "SHA-256",
+		ConfigKeyDigestResponseHeaders: []string{HeaderDigest},
+		ConfigKeyDigestMaxDecodedSize:  10 * 1024 * 1024,
+	})
+	f.algorithm = strings.ToUpper(config.GetString(ConfigKeyDigestAlgorithm))
+	if _, ok := digestHashes[f.algorithm]; !ok {
+		return fmt.Errorf("DigestFilter: unsupported algorithm: %s", f.algorithm)
+	}
+	f.maxDecoded = config.GetInt64(ConfigKeyDigestMaxDecodedSize)
+	for _, h := range config.GetStringSlice(ConfigKeyDigestResponseHeaders) {
+		f.headers = append(f.headers, http.CanonicalHeaderKey(h))
+	}
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Infow("Digest filter initializing", "algorithm", f.algorithm, "response-headers", f.headers,
+		"max-decoded-size", f.maxDecoded)
+	return nil
+}
+
+func (*DigestFilter) FilterId() string {
+	return TypeIdDigestFilter
+}
+
+func (f *DigestFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		options := ctx.Endpoint().GetAttr(EndpointAttrTagDigest).GetStringSlice()
+		if len(options) == 0 {
+			return next(ctx)
+		}
+		var verify, require, response bool
+		for _, opt := range options {
+			switch strings.ToLower(opt) {
+			case DigestOptionVerify:
+				verify = true
+			case DigestOptionRequire:
+				verify, require = true, true
+			case DigestOptionResponse:
+				response = true
+			}
+		}
+		if verify {
+			if serr := f.verifyRequest(ctx, require); nil != serr {
+				return serr
+			}
+		}
+		if response {
+			ctx.AddResponseHook(f.digestResponse)
+		}
+		return next(ctx)
+	}
+}
+
+func (f *DigestFilter) verifyRequest(ctx *flux.Context, require bool) *flux.ServeError {
+	digest, md5sum, sha256sum := ctx.HeaderVar(HeaderDigest), ctx.HeaderVar(HeaderContentMD5), ctx.HeaderVar(HeaderXContentSha256)
+	if digest == "" && md5sum == "" && sha256sum == "" {
+		if require {
+			return &flux.ServeError{
+				StatusCode: flux.StatusBadRequest,
+				ErrorCode:  ErrorCodeDigestRequired,
+				Message:    "DIGEST:REQUIRED",
+			}
+		}
+		return nil
+	}
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return &flux.ServeError{StatusCode: flux.StatusBadRequest, ErrorCode: flux.ErrorCodeRequestInvalid,
+			Message: "DIGEST:READ_BODY", CauseError: err}
+	}
+	data, err := ioutil.ReadAll(reader)
+	_ = reader.Close()
+	if nil != err {
+		return &flux.ServeError{StatusCode: flux.StatusBadRequest, ErrorCode: flux.ErrorCodeRequestInvalid,
+			Message: "DIGEST:READ_BODY", CauseError: err}
+	}
+	err = verifyDigests(data, digest, md5sum, sha256sum)
+	if nil != err && strings.Contains(ctx.HeaderVar(flux.HeaderContentEncoding), "gzip") {
+		// 兼容按解码后的数据计算摘要的客户端
+		if decoded, derr := gunzip(data, f.maxDecoded); nil == derr {
+			err = verifyDigests(decoded, digest, md5sum, sha256sum)
+		}
+	}
+	if nil != err {
+		logger.TraceContext(ctx).Warnw("DIGEST:VERIFY:MISMATCH", "error", err)
+		return &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  ErrorCodeDigestMismatch,
+			Message:    "DIGEST:MISMATCH",
+			CauseError: err,
+		}
+	}
+	return nil
+}
+
+func (f *DigestFilter) digestResponse(_ *flux.Context, response *flux.ResponseBody) error {
+	data, err := common.SerializeObject(response.Body)
+	if nil != err {
+		return err
+	}
+	// 摘要按序列化后的数据计算，以序列化数据替换响应数据，保证输出内容一致
+	response.Body = data
+	if nil == response.Headers {
+		response.Headers = make(http.Header, len(f.headers))
+	}
+	for _, h := range f.headers {
+		switch h {
+		case HeaderDigest:
+			response.Headers.Set(HeaderDigest, f.algorithm+"="+base64.StdEncoding.EncodeToString(sum(digestHashes[f.algorithm], data)))
+		case HeaderContentMD5:
+			response.Headers.Set(HeaderContentMD5, base64.StdEncoding.EncodeToString(sum(md5.New, data)))
+		case HeaderXContentSha256:
+			response.Headers.Set(HeaderXContentSha256, hex.EncodeToString(sum(sha256.New, data)))
+		}
+	}
+	return nil
+}
+
+// verifyDigests 校验请求携带的全部摘要；Digest Header中不支持的算法被忽略
+func verifyDigests(data []byte, digest, md5sum, sha256sum string) error {
+	if md5sum != "" && md5sum != base64.StdEncoding.EncodeToString(sum(md5.New, data)) {
+		return errors.New("content-md5 mismatch")
+	}
+	if sha256sum != "" && !strings.EqualFold(sha256sum, hex.EncodeToString(sum(sha256.New, data))) {
+		return errors.New("x-content-sha256 mismatch")
+	}
+	verified := digest == ""
+	for _, item := range strings.Split(digest, ",") {
+		kv := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		fn, ok := digestHashes[strings.ToUpper(kv[0])]
+		if !ok {
+			continue
+		}
+		if kv[1] != base64.StdEncoding.EncodeToString(sum(fn, data)) {
+			return fmt.Errorf("digest mismatch, algorithm: %s", kv[0])
+		}
+		verified = true
+	}
+	if !verified {
+		return errors.New("digest algorithms not supported: " + digest)
+	}
+	return nil
+}
+
+func sum(fn func() hash.Hash, data []byte) []byte {
+	h := fn()
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+// gunzip 解码gzip数据；解码后的数据超出 limit 字节时返回错误
+func gunzip(data []byte, limit int64) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if nil != err {
+		return nil, err
+	}
+	defer reader.Close()
+	decoded, err := ioutil.ReadAll(io.LimitReader(reader, limit+1))
+	if nil != err {
+		return nil, err
+	}
+	if int64(len(decoded)) > limit {
+		return nil, fmt.Errorf("decoded content exceeds limit: %d", limit)
+	}
+	return decoded, nil
+}
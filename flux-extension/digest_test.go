@@ -0,0 +1,22 @@
+package fluxext
+
+import (
+	"bytes"
+	"compress/gzip"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGunzip_Limit(t *testing.T) {
+	assert := assert.New(t)
+	buf := new(bytes.Buffer)
+	writer := gzip.NewWriter(buf)
+	_, _ = writer.Write(make([]byte, 4096))
+	_ = writer.Close()
+	decoded, err := gunzip(buf.Bytes(), 4096)
+	assert.NoError(err)
+	assert.Len(decoded, 4096)
+	// 解码后的数据超出限制
+	_, err = gunzip(buf.Bytes(), 1024)
+	assert.Error(err)
+}
@@ -0,0 +1,173 @@
+package fluxext
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/spf13/cast"
+	"net/http"
+	"strings"
+)
+
+const (
+	TypeIdHeaderMappingFilter = "header_mapping_filter"
+)
+
+const (
+	// Endpoint属性：响应Body字段映射为响应Header的规则，格式：Header=$.path[;remove]，可定义多个
+	EndpointAttrTagResponseHeader = "responseheader"
+)
+
+const (
+	headerMappingOptionRemove = "remove"
+)
+
+// HeaderMapping 响应Body字段到响应Header的映射规则
+type HeaderMapping struct {
+	Header string
+	Path   string
+	Remove bool
+}
+
+// ParseHeaderMapping 解析映射规则：X-Next-Page=$.page.next;remove
+func ParseHeaderMapping(spec string) (HeaderMapping, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+		return HeaderMapping{}, fmt.Errorf("invalid header mapping: %s", spec)
+	}
+	mapping := HeaderMapping{Header: http.CanonicalHeaderKey(strings.TrimSpace(parts[0]))}
+	options := strings.Split(parts[1], ";")
+	mapping.Path = strings.TrimSpace(options[0])
+	if !strings.HasPrefix(mapping.Path, "$") {
+		return HeaderMapping{}, fmt.Errorf("invalid header mapping path: %s", spec)
+	}
+	for _, opt := range options[1:] {
+		if strings.EqualFold(strings.TrimSpace(opt), headerMappingOptionRemove) {
+			mapping.Remove = true
+		}
+	}
+	return mapping, nil
+}
+
+// HeaderMappingConfig 响应Header映射配置
+type HeaderMappingConfig struct {
+	SkipFunc flux.FilterSkipper
+}
+
+func NewHeaderMappingFilter(c HeaderMappingConfig) *HeaderMappingFilter {
+	return &HeaderMappingFilter{
+		Configs: c,
+	}
+}
+
+// HeaderMappingFilter 按Endpoint定义的映射规则，将后端响应Body的字段输出为响应Header，并可从Body中删除该字段；
+// 例如分页Token，链路追踪等元数据，客户端无需解析响应Body即可读取。
+type HeaderMappingFilter struct {
+	Configs HeaderMappingConfig
+}
+
+func (f *HeaderMappingFilter) Init(config *flux.Configuration) error {
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Info("HeaderMapping filter initializing")
+	return nil
+}
+
+func (*HeaderMappingFilter) FilterId() string {
+	return TypeIdHeaderMappingFilter
+}
+
+func (f *HeaderMappingFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		specs := ctx.Endpoint().GetAttr(EndpointAttrTagResponseHeader).GetStringSlice()
+		if len(specs) == 0 {
+			return next(ctx)
+		}
+		mappings := make([]HeaderMapping, 0, len(specs))
+		for _, spec := range specs {
+			mapping, err := ParseHeaderMapping(spec)
+			if nil != err {
+				logger.TraceContext(ctx).Warnw("HEADER_MAPPING:IGNORE", "error", err)
+				continue
+			}
+			mappings = append(mappings, mapping)
+		}
+		ctx.AddResponseHook(func(ctx *flux.Context, response *flux.ResponseBody) error {
+			return applyHeaderMappings(mappings, response)
+		})
+		return next(ctx)
+	}
+}
+
+func applyHeaderMappings(mappings []HeaderMapping, response *flux.ResponseBody) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+	data, ok, err := decodeResponseJSON(response)
+	if nil != err || !ok {
+		return err
+	}
+	if nil == response.Headers {
+		response.Headers = make(http.Header, len(mappings))
+	}
+	removed := false
+	for _, mapping := range mappings {
+		value, ok := LookupJSONPath(data, mapping.Path)
+		if !ok || nil == value {
+			continue
+		}
+		text, err := cast.ToStringE(value)
+		if nil != err {
+			bytes, err := ext.JSONMarshal(value)
+			if nil != err {
+				return err
+			}
+			text = string(bytes)
+		}
+		response.Headers.Set(mapping.Header, text)
+		if mapping.Remove && deleteJSONPath(data, mapping.Path) {
+			removed = true
+		}
+	}
+	if !removed {
+		return nil
+	}
+	out, err := ext.JSONMarshal(data)
+	if nil != err {
+		return err
+	}
+	response.Body = out
+	response.Headers.Del(flux.HeaderContentLength)
+	return nil
+}
+
+// deleteJSONPath 删除JSON数据中的对象字段；路径格式：$.a.b[0].c，最后一段必须为字段名
+func deleteJSONPath(data interface{}, path string) bool {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	pos := strings.LastIndexByte(path, '.')
+	parent, name := data, path
+	if pos >= 0 {
+		var ok bool
+		if parent, ok = LookupJSONPath(data, path[:pos]); !ok {
+			return false
+		}
+		name = path[pos+1:]
+	}
+	obj, ok := parent.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, ok := obj[name]; !ok {
+		return false
+	}
+	delete(obj, name)
+	return true
+}
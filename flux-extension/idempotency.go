@@ -0,0 +1,527 @@
+package fluxext
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdIdempotencyFilter = "idempotency_filter"
+)
+
+const (
+	ConfigKeyIdempotencyHeader       = "header"
+	ConfigKeyIdempotencyMethods      = "methods"
+	ConfigKeyIdempotencyScopeHeaders = "scope_headers"
+	ConfigKeyIdempotencyTTL          = "ttl"
+	ConfigKeyIdempotencyLockTTL      = "lock_ttl"
+	ConfigKeyIdempotencyWaitTimeout  = "wait_timeout"
+	ConfigKeyIdempotencyPollInterval = "poll_interval"
+	ConfigKeyIdempotencyMaxBody      = "max_body"
+	// 计算请求指纹时读取的请求Body最大长度；超过时拒绝请求
+	ConfigKeyIdempotencyMaxRequestBody = "max_request_body"
+	ConfigKeyIdempotencyStore          = "store"
+	ConfigKeyIdempotencyKeyPrefix      = "key_prefix"
+)
+
+const (
+	// Endpoint属性：是否启用幂等Key处理；未定义时按请求方法判断
+	EndpointAttrTagIdempotency = "idempotency"
+)
+
+const (
+	IdempotencyStoreMemory = "memory"
+	IdempotencyStoreRedis  = "redis"
+)
+
+const (
+	HeaderIdempotencyKey      = "Idempotency-Key"
+	HeaderIdempotentReplayed  = "Idempotent-Replayed"
+	idempotencyKeyMaxLength   = 255
+	idempotencyMemoryCapacity = 1024 * 64
+	// 保存及释放记录的超时时间；不使用请求Context，客户端取消请求后仍能保存或释放记录
+	idempotencyStoreTimeout = time.Second * 5
+)
+
+const (
+	ErrorCodeIdempotencyKeyInvalid  = "REQUEST:IDEMPOTENCY_KEY_INVALID"
+	ErrorCodeIdempotencyMismatch    = "REQUEST:IDEMPOTENCY_KEY_MISMATCH"
+	ErrorCodeIdempotencyInProgress  = "REQUEST:IDEMPOTENCY_IN_PROGRESS"
+	ErrorCodeIdempotencyStoreFailed = "GATEWAY:IDEMPOTENCY_STORE_FAILED"
+)
+
+var (
+	errIdempotencyBodyTooLarge = errors.New("request body exceeds max request body")
+)
+
+type (
+	// IdempotencyStore 幂等请求的响应存储
+	IdempotencyStore interface {
+		// Load 读取Key对应的记录；记录不存在时返回false
+		Load(ctx context.Context, key string) (*IdempotencyRecord, bool, error)
+		// Reserve 写入处理中的记录占用Key；Key已存在时返回false
+		Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (bool, error)
+		// Save 保存请求的响应记录
+		Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error
+		// Release 删除处理中的记录，允许客户端重试
+		Release(ctx context.Context, key string) error
+	}
+	// IdempotencyRedis Redis命令执行接口；transporter/redis.Client 实现此接口
+	IdempotencyRedis interface {
+		Do(ctx context.Context, args ...interface{}) (interface{}, error)
+	}
+)
+
+// IdempotencyRecord 幂等请求的记录：处理中的占位记录，或已完成请求的响应数据
+type IdempotencyRecord struct {
+	Pending     bool        `json:"pending"`
+	Fingerprint string      `json:"fingerprint"`
+	StatusCode  int         `json:"status"`
+	ContentType string      `json:"contentType,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+// IdempotencyConfig 幂等Key处理配置
+type IdempotencyConfig struct {
+	SkipFunc flux.FilterSkipper
+	// Store 自定义响应存储；未设置时按配置 store 创建
+	Store IdempotencyStore
+	// Redis 配置 store 为 redis 时使用的Redis客户端
+	Redis IdempotencyRedis
+}
+
+func NewIdempotencyFilter(c IdempotencyConfig) *IdempotencyFilter {
+	return &IdempotencyFilter{
+		Configs: c,
+	}
+}
+
+// IdempotencyFilter 处理请求的 Idempotency-Key Header：首个请求的响应按Key保存，TTL内相同Key的重复请求直接重放已保存的响应；
+// 相同Key的并发请求只调用一次后端服务，其它请求等待并共享首个请求的响应。
+// Key按Endpoint，scope_headers（例如 Authorization）隔离；相同Key但请求Body不同时，返回422错误。
+// 后端返回5xx错误，或请求处理失败时，不保存响应，客户端可使用相同的Key重试。
+type IdempotencyFilter struct {
+	Configs      IdempotencyConfig
+	header       string
+	methods      []string
+	scopeHeaders []string
+	ttl          time.Duration
+	lockTTL      time.Duration
+	waitTimeout  time.Duration
+	pollInterval time.Duration
+	maxBody      int
+	maxReqBody   int64
+	inflight     map[string]*idempotencyCall
+	mu           sync.Mutex
+}
+
+type idempotencyCall struct {
+	done   chan struct{}
+	record *IdempotencyRecord
+}
+
+func (f *IdempotencyFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyIdempotencyHeader:         HeaderIdempotencyKey,
+		ConfigKeyIdempotencyMethods:        []string{http.MethodPost, http.MethodPatch},
+		ConfigKeyIdempotencyScopeHeaders:   []string{flux.HeaderAuthorization},
+		ConfigKeyIdempotencyTTL:            time.Hour * 24,
+		ConfigKeyIdempotencyLockTTL:        time.Second * 30,
+		ConfigKeyIdempotencyWaitTimeout:    time.Second * 10,
+		ConfigKeyIdempotencyPollInterval:   time.Millisecond * 100,
+		ConfigKeyIdempotencyMaxBody:        1024 * 1024,
+		ConfigKeyIdempotencyMaxRequestBody: 1024 * 1024,
+		ConfigKeyIdempotencyStore:          IdempotencyStoreMemory,
+		ConfigKeyIdempotencyKeyPrefix:      "flux:idempotency:",
+	})
+	if fluxpkg.IsNil(f.Configs.Store) {
+		switch store := strings.ToLower(config.GetString(ConfigKeyIdempotencyStore)); store {
+		case IdempotencyStoreMemory:
+			f.Configs.Store = NewMemoryIdempotencyStore()
+		case IdempotencyStoreRedis:
+			if fluxpkg.IsNil(f.Configs.Redis) {
+				return fmt.Errorf("IdempotencyFilter: <Redis> client is required by store: %s", store)
+			}
+			f.Configs.Store = NewRedisIdempotencyStore(f.Configs.Redis, config.GetString(ConfigKeyIdempotencyKeyPrefix))
+		default:
+			return fmt.Errorf("IdempotencyFilter: unknown store: %s", store)
+		}
+	}
+	f.header = config.GetString(ConfigKeyIdempotencyHeader)
+	f.methods = config.GetStringSlice(ConfigKeyIdempotencyMethods)
+	f.scopeHeaders = config.GetStringSlice(ConfigKeyIdempotencyScopeHeaders)
+	f.ttl = config.GetDuration(ConfigKeyIdempotencyTTL)
+	f.lockTTL = config.GetDuration(ConfigKeyIdempotencyLockTTL)
+	f.waitTimeout = config.GetDuration(ConfigKeyIdempotencyWaitTimeout)
+	f.pollInterval = config.GetDuration(ConfigKeyIdempotencyPollInterval)
+	f.maxBody = config.GetInt(ConfigKeyIdempotencyMaxBody)
+	f.maxReqBody = config.GetInt64(ConfigKeyIdempotencyMaxRequestBody)
+	f.inflight = make(map[string]*idempotencyCall, 64)
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Infow("Idempotency filter initializing", "header", f.header, "methods", f.methods, "ttl", f.ttl,
+		"store", config.GetString(ConfigKeyIdempotencyStore))
+	return nil
+}
+
+func (*IdempotencyFilter) FilterId() string {
+	return TypeIdIdempotencyFilter
+}
+
+func (f *IdempotencyFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		key := strings.TrimSpace(ctx.HeaderVar(f.header))
+		if key == "" || !f.enabled(ctx) || f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		if len(key) > idempotencyKeyMaxLength {
+			return &flux.ServeError{
+				StatusCode: flux.StatusBadRequest,
+				ErrorCode:  ErrorCodeIdempotencyKeyInvalid,
+				Message:    "IDEMPOTENCY:KEY_TOO_LONG",
+			}
+		}
+		fingerprint, err := requestFingerprint(ctx, f.maxReqBody)
+		if err == errIdempotencyBodyTooLarge {
+			return &flux.ServeError{
+				StatusCode: flux.StatusTooLarge,
+				ErrorCode:  flux.ErrorCodeRequestInvalid,
+				Message:    "IDEMPOTENCY:BODY_TOO_LARGE",
+				CauseError: err,
+			}
+		} else if nil != err {
+			return &flux.ServeError{
+				StatusCode: flux.StatusBadRequest,
+				ErrorCode:  flux.ErrorCodeRequestInvalid,
+				Message:    "IDEMPOTENCY:READ_BODY",
+				CauseError: err,
+			}
+		}
+		storeKey := f.storeKey(ctx, key)
+		// 本实例内相同Key的并发请求，等待首个请求完成
+		f.mu.Lock()
+		if call, ok := f.inflight[storeKey]; ok {
+			f.mu.Unlock()
+			return f.await(ctx, call, fingerprint)
+		}
+		call := &idempotencyCall{done: make(chan struct{})}
+		f.inflight[storeKey] = call
+		f.mu.Unlock()
+		defer func() {
+			f.mu.Lock()
+			delete(f.inflight, storeKey)
+			f.mu.Unlock()
+			close(call.done)
+		}()
+		record, err := f.reserve(ctx, storeKey, fingerprint)
+		if nil != err {
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  ErrorCodeIdempotencyStoreFailed,
+				Message:    "IDEMPOTENCY:STORE_ERROR",
+				CauseError: err,
+			}
+		}
+		if nil != record {
+			call.record = record
+			return f.replay(ctx, record, fingerprint)
+		}
+		// 在ResponseWriter层记录响应，与TransportWriter的实现无关
+		recorder := newResponseRecorder(ctx.ResponseWriter(), f.maxBody)
+		ctx.SetResponseWriter(recorder)
+		serr := next(ctx)
+		ctx.SetResponseWriter(recorder.ResponseWriter)
+		storeCtx, cancel := context.WithTimeout(context.Background(), idempotencyStoreTimeout)
+		defer cancel()
+		if status, header, body, ok := recorder.recorded(); ok && status < http.StatusInternalServerError {
+			record := &IdempotencyRecord{
+				Fingerprint: fingerprint,
+				StatusCode:  status,
+				ContentType: header.Get(flux.HeaderContentType),
+				Header:      header,
+				Body:        body,
+			}
+			if err := f.Configs.Store.Save(storeCtx, storeKey, record, f.ttl); nil != err {
+				logger.TraceContext(ctx).Errorw("IDEMPOTENCY:SAVE:ERROR", "key", key, "error", err)
+			} else {
+				call.record = record
+				return serr
+			}
+		}
+		if err := f.Configs.Store.Release(storeCtx, storeKey); nil != err {
+			logger.TraceContext(ctx).Warnw("IDEMPOTENCY:RELEASE:ERROR", "key", key, "error", err)
+		}
+		return serr
+	}
+}
+
+// reserve 占用Key；Key已有完成的记录时返回该记录；其它实例处理中时，等待其完成
+func (f *IdempotencyFilter) reserve(ctx *flux.Context, storeKey, fingerprint string) (*IdempotencyRecord, error) {
+	deadline := time.Now().Add(f.waitTimeout)
+	for {
+		ok, err := f.Configs.Store.Reserve(ctx.Context(), storeKey, &IdempotencyRecord{Pending: true, Fingerprint: fingerprint}, f.lockTTL)
+		if nil != err || ok {
+			return nil, err
+		}
+		record, ok, err := f.Configs.Store.Load(ctx.Context(), storeKey)
+		if nil != err {
+			return nil, err
+		}
+		if ok && !record.Pending {
+			return record, nil
+		}
+		if ok && (record.Fingerprint != fingerprint || time.Now().After(deadline)) {
+			// 请求内容不一致，或等待超时，返回处理中的记录，由调用方返回错误
+			return record, nil
+		}
+		select {
+		case <-time.After(f.pollInterval):
+		case <-ctx.Context().Done():
+			return nil, ctx.Context().Err()
+		}
+	}
+}
+
+func (f *IdempotencyFilter) await(ctx *flux.Context, call *idempotencyCall, fingerprint string) *flux.ServeError {
+	timer := time.NewTimer(f.waitTimeout)
+	defer timer.Stop()
+	select {
+	case <-call.done:
+	case <-timer.C:
+	case <-ctx.Context().Done():
+		return &flux.ServeError{
+			StatusCode: flux.StatusOK,
+			ErrorCode:  flux.ErrorCodeGatewayCanceled,
+			Message:    "IDEMPOTENCY:CANCELED:BYCLIENT",
+			CauseError: ctx.Context().Err(),
+		}
+	}
+	select {
+	case <-call.done:
+		if nil != call.record {
+			return f.replay(ctx, call.record, fingerprint)
+		}
+	default:
+	}
+	return &flux.ServeError{
+		StatusCode: http.StatusConflict,
+		ErrorCode:  ErrorCodeIdempotencyInProgress,
+		Message:    "IDEMPOTENCY:REQUEST_IN_PROGRESS",
+	}
+}
+
+func (f *IdempotencyFilter) replay(ctx *flux.Context, record *IdempotencyRecord, fingerprint string) *flux.ServeError {
+	if record.Fingerprint != fingerprint {
+		return &flux.ServeError{
+			StatusCode: http.StatusUnprocessableEntity,
+			ErrorCode:  ErrorCodeIdempotencyMismatch,
+			Message:    "IDEMPOTENCY:REQUEST_MISMATCH",
+		}
+	}
+	if record.Pending {
+		return &flux.ServeError{
+			StatusCode: http.StatusConflict,
+			ErrorCode:  ErrorCodeIdempotencyInProgress,
+			Message:    "IDEMPOTENCY:REQUEST_IN_PROGRESS",
+		}
+	}
+	header := ctx.ResponseWriter().Header()
+	for name, values := range record.Header {
+		header[name] = values
+	}
+	header.Set(HeaderIdempotentReplayed, "true")
+	contentType := record.ContentType
+	if contentType == "" {
+		contentType = flux.MIMEApplicationJSONCharsetUTF8
+	}
+	if err := ctx.Write(record.StatusCode, contentType, record.Body); nil != err {
+		return &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageTransportWriteResponse,
+			CauseError: err,
+		}
+	}
+	return nil
+}
+
+func (f *IdempotencyFilter) enabled(ctx *flux.Context) bool {
+	if attr, ok := ctx.Endpoint().GetAttrEx(EndpointAttrTagIdempotency); ok {
+		return attr.GetBool()
+	}
+	return fluxpkg.StringSliceContains(f.methods, ctx.Method())
+}
+
+// storeKey 按Endpoint，隔离Header及客户端Key生成存储Key
+func (f *IdempotencyFilter) storeKey(ctx *flux.Context, key string) string {
+	hash := sha256.New()
+	endpoint := ctx.Endpoint()
+	for _, v := range []string{endpoint.Application, endpoint.HttpMethod, endpoint.HttpPattern} {
+		_, _ = io.WriteString(hash, v)
+		_, _ = hash.Write([]byte{0})
+	}
+	for _, name := range f.scopeHeaders {
+		_, _ = io.WriteString(hash, ctx.HeaderVar(name))
+		_, _ = hash.Write([]byte{0})
+	}
+	_, _ = io.WriteString(hash, key)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// replayableHeader 返回可重放的响应Header；跨域，Cookie等与单个请求相关的Header不保存
+func replayableHeader(header http.Header) http.Header {
+	out := make(http.Header, len(header))
+	for name, values := range header {
+		if strings.HasPrefix(name, "Access-Control-") || name == flux.HeaderSetCookie || name == flux.HeaderVary {
+			continue
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
+
+// requestFingerprint 按Query参数及请求Body计算请求指纹；请求Body超过 limit 时返回错误
+func requestFingerprint(ctx *flux.Context, limit int64) (string, error) {
+	if ctx.Request().ContentLength > limit {
+		return "", errIdempotencyBodyTooLarge
+	}
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return "", err
+	}
+	defer reader.Close()
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, ctx.URL().RawQuery)
+	_, _ = hash.Write([]byte{0})
+	if n, err := io.Copy(hash, io.LimitReader(reader, limit+1)); nil != err {
+		return "", err
+	} else if n > limit {
+		return "", errIdempotencyBodyTooLarge
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// MemoryIdempotencyStore 本地内存的响应存储；只能在单实例内去重
+type MemoryIdempotencyStore struct {
+	records map[string]memoryIdempotencyEntry
+	mu      sync.Mutex
+}
+
+type memoryIdempotencyEntry struct {
+	record  *IdempotencyRecord
+	expires time.Time
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{records: make(map[string]memoryIdempotencyEntry, 1024)}
+}
+
+func (m *MemoryIdempotencyStore) Load(_ context.Context, key string) (*IdempotencyRecord, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	entry, ok := m.records[key]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, false, nil
+	}
+	return entry.record, true, nil
+}
+
+func (m *MemoryIdempotencyStore) Reserve(_ context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if entry, ok := m.records[key]; ok && now.Before(entry.expires) {
+		return false, nil
+	}
+	if len(m.records) >= idempotencyMemoryCapacity {
+		for k, entry := range m.records {
+			if now.After(entry.expires) {
+				delete(m.records, k)
+			}
+		}
+	}
+	m.records[key] = memoryIdempotencyEntry{record: record, expires: now.Add(ttl)}
+	return true, nil
+}
+
+func (m *MemoryIdempotencyStore) Save(_ context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.records[key] = memoryIdempotencyEntry{record: record, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (m *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.records, key)
+	return nil
+}
+
+// RedisIdempotencyStore 基于Redis的响应存储，多个网关实例共享去重记录；记录以JSON格式保存
+type RedisIdempotencyStore struct {
+	client IdempotencyRedis
+	prefix string
+}
+
+func NewRedisIdempotencyStore(client IdempotencyRedis, prefix string) *RedisIdempotencyStore {
+	return &RedisIdempotencyStore{client: client, prefix: prefix}
+}
+
+func (r *RedisIdempotencyStore) Load(ctx context.Context, key string) (*IdempotencyRecord, bool, error) {
+	reply, err := r.client.Do(ctx, "GET", r.prefix+key)
+	if nil != err {
+		return nil, false, err
+	}
+	data, ok := reply.(string)
+	if !ok {
+		return nil, false, nil
+	}
+	record := new(IdempotencyRecord)
+	if err := ext.JSONUnmarshal([]byte(data), record); nil != err {
+		return nil, false, fmt.Errorf("decode idempotency record: %w", err)
+	}
+	return record, true, nil
+}
+
+func (r *RedisIdempotencyStore) Reserve(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) (bool, error) {
+	data, err := ext.JSONMarshal(record)
+	if nil != err {
+		return false, err
+	}
+	reply, err := r.client.Do(ctx, "SET", r.prefix+key, string(data), "PX", ttl.Milliseconds(), "NX")
+	if nil != err {
+		return false, err
+	}
+	return reply == "OK", nil
+}
+
+func (r *RedisIdempotencyStore) Save(ctx context.Context, key string, record *IdempotencyRecord, ttl time.Duration) error {
+	data, err := ext.JSONMarshal(record)
+	if nil != err {
+		return err
+	}
+	_, err = r.client.Do(ctx, "SET", r.prefix+key, string(data), "PX", ttl.Milliseconds())
+	return err
+}
+
+func (r *RedisIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := r.client.Do(ctx, "DEL", r.prefix+key)
+	return err
+}
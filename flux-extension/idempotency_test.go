@@ -0,0 +1,166 @@
+package fluxext
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// releaseRecordStore 记录 Release 调用时的Context状态
+type releaseRecordStore struct {
+	*MemoryIdempotencyStore
+	releaseErr error
+	releases   int
+}
+
+func (s *releaseRecordStore) Release(ctx context.Context, key string) error {
+	s.releases++
+	s.releaseErr = ctx.Err()
+	return s.MemoryIdempotencyStore.Release(ctx, key)
+}
+
+func newIdempotencyFilter(t *testing.T, store IdempotencyStore, config map[string]interface{}) *IdempotencyFilter {
+	filter := NewIdempotencyFilter(IdempotencyConfig{Store: store})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(config)))
+	return filter
+}
+
+func newIdempotencyContext(reqctx context.Context, key, body string) (*flux.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(http.MethodPost, "http://gateway/orders", strings.NewReader(body)).WithContext(reqctx)
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(body)), nil
+	}
+	request.Header.Set(HeaderIdempotencyKey, key)
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("idempotency", request, nil, nil), &flux.Endpoint{
+		HttpMethod:  http.MethodPost,
+		HttpPattern: "/orders",
+	})
+	ctx.SetResponseWriter(recorder)
+	return ctx, recorder
+}
+
+func TestIdempotencyFilter_Replay(t *testing.T) {
+	assert := assert.New(t)
+	filter := newIdempotencyFilter(t, NewMemoryIdempotencyStore(), map[string]interface{}{})
+	var calls int32
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		atomic.AddInt32(&calls, 1)
+		ctx.ResponseWriter().Header().Set("X-Order", "1")
+		_ = ctx.Write(http.StatusCreated, flux.MIMETextPlainCharsetUTF8, []byte("created"))
+		return nil
+	})
+	ctx, _ := newIdempotencyContext(context.Background(), "order-1", `{"sku":"a"}`)
+	assert.Nil(invoker(ctx))
+	ctx, recorder := newIdempotencyContext(context.Background(), "order-1", `{"sku":"a"}`)
+	assert.Nil(invoker(ctx))
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	assert.Equal(http.StatusCreated, recorder.Code)
+	assert.Equal(flux.MIMETextPlainCharsetUTF8, recorder.Header().Get(flux.HeaderContentType))
+	assert.Equal("1", recorder.Header().Get("X-Order"))
+	assert.Equal("true", recorder.Header().Get(HeaderIdempotentReplayed))
+	assert.Equal("created", recorder.Body.String())
+
+	// 相同Key，请求Body不同
+	ctx, _ = newIdempotencyContext(context.Background(), "order-1", `{"sku":"b"}`)
+	serr := invoker(ctx)
+	if assert.NotNil(serr) {
+		assert.Equal(ErrorCodeIdempotencyMismatch, serr.ErrorCode)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestIdempotencyFilter_ReleaseOnFailure(t *testing.T) {
+	assert := assert.New(t)
+	store := &releaseRecordStore{MemoryIdempotencyStore: NewMemoryIdempotencyStore()}
+	filter := newIdempotencyFilter(t, store, map[string]interface{}{})
+	var calls int32
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			_ = ctx.Write(http.StatusBadGateway, flux.MIMETextPlain, []byte("upstream error"))
+		}
+		return nil
+	})
+	ctx, _ := newIdempotencyContext(context.Background(), "order-2", "")
+	assert.Nil(invoker(ctx))
+	assert.Equal(1, store.releases)
+	// 5xx响应不保存，相同Key可重试
+	ctx, _ = newIdempotencyContext(context.Background(), "order-2", "")
+	assert.Nil(invoker(ctx))
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+
+	// 客户端取消请求后，使用未取消的Context释放Key
+	reqctx, cancel := context.WithCancel(context.Background())
+	invoker = filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		cancel()
+		return &flux.ServeError{StatusCode: flux.StatusOK, ErrorCode: flux.ErrorCodeGatewayCanceled}
+	})
+	ctx, _ = newIdempotencyContext(reqctx, "order-3", "")
+	assert.NotNil(invoker(ctx))
+	assert.Equal(3, store.releases)
+	assert.NoError(store.releaseErr)
+	_, ok, _ := store.Load(context.Background(), filter.storeKey(ctx, "order-3"))
+	assert.False(ok)
+}
+
+func TestIdempotencyFilter_RequestBodyLimit(t *testing.T) {
+	filter := newIdempotencyFilter(t, NewMemoryIdempotencyStore(), map[string]interface{}{
+		ConfigKeyIdempotencyMaxRequestBody: 8,
+	})
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		return nil
+	})
+	ctx, _ := newIdempotencyContext(context.Background(), "order-4", strings.Repeat("a", 16))
+	serr := invoker(ctx)
+	if assert.NotNil(t, serr) {
+		assert.Equal(t, flux.StatusTooLarge, serr.StatusCode)
+	}
+	// 未知长度的请求Body
+	ctx, _ = newIdempotencyContext(context.Background(), "order-4", strings.Repeat("a", 16))
+	ctx.Request().ContentLength = -1
+	serr = invoker(ctx)
+	if assert.NotNil(t, serr) {
+		assert.Equal(t, flux.StatusTooLarge, serr.StatusCode)
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	assert := assert.New(t)
+	store := NewMemoryIdempotencyStore()
+	ctx := context.Background()
+	ok, err := store.Reserve(ctx, "k", &IdempotencyRecord{Pending: true, Fingerprint: "f"}, time.Minute)
+	assert.NoError(err)
+	assert.True(ok)
+	ok, _ = store.Reserve(ctx, "k", &IdempotencyRecord{Pending: true, Fingerprint: "f"}, time.Minute)
+	assert.False(ok)
+	record, ok, _ := store.Load(ctx, "k")
+	assert.True(ok)
+	assert.True(record.Pending)
+
+	assert.NoError(store.Save(ctx, "k", &IdempotencyRecord{Fingerprint: "f", StatusCode: http.StatusOK}, time.Minute))
+	record, ok, _ = store.Load(ctx, "k")
+	assert.True(ok)
+	assert.False(record.Pending)
+	assert.NoError(store.Release(ctx, "k"))
+	_, ok, _ = store.Load(ctx, "k")
+	assert.False(ok)
+
+	// 过期的记录不可读取，可重新占用
+	ok, _ = store.Reserve(ctx, "expired", &IdempotencyRecord{Pending: true}, time.Millisecond)
+	assert.True(ok)
+	time.Sleep(time.Millisecond * 5)
+	_, ok, _ = store.Load(ctx, "expired")
+	assert.False(ok)
+	ok, _ = store.Reserve(ctx, "expired", &IdempotencyRecord{Pending: true}, time.Minute)
+	assert.True(ok)
+}
@@ -0,0 +1,347 @@
+package fluxext
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdIntrospectionFilter = "introspection_filter"
+)
+
+const (
+	ConfigKeyIntrospectionEndpoint     = "endpoint"
+	ConfigKeyIntrospectionClientId     = "client_id"
+	ConfigKeyIntrospectionClientSecret = "client_secret"
+	ConfigKeyIntrospectionTimeout      = "timeout"
+	ConfigKeyIntrospectionAttrPrefix   = "attr_prefix"
+)
+
+const (
+	// Endpoint属性：访问Endpoint需要的Scope，可定义多个
+	EndpointAttrTagRequiredScope = "requiredscope"
+)
+
+const (
+	// Context变量：Token内省结果(*IntrospectionResult)
+	ContextKeyIntrospection = "__flux.oauth2.introspection"
+)
+
+const (
+	// 内省接口响应数据的最大长度
+	introspectionMaxResponse = 1024 * 1024
+)
+
+const (
+	ErrorCodeTokenInactive     = "AUTHORIZATION:TOKEN:INACTIVE"
+	ErrorCodeTokenInsufficient = "AUTHORIZATION:TOKEN:INSUFFICIENT_SCOPE"
+)
+
+// IntrospectionResult RFC 7662 Token内省响应
+type IntrospectionResult struct {
+	Active    bool                   `json:"active"`
+	Scope     string                 `json:"scope"`
+	ClientId  string                 `json:"client_id"`
+	Username  string                 `json:"username"`
+	TokenType string                 `json:"token_type"`
+	Exp       int64                  `json:"exp"`
+	Iat       int64                  `json:"iat"`
+	Sub       string                 `json:"sub"`
+	Aud       interface{}            `json:"aud"`
+	Iss       string                 `json:"iss"`
+	Extra     map[string]interface{} `json:"-"`
+}
+
+// Scopes 返回以空格分隔的Scope列表
+func (r *IntrospectionResult) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// HasScopes 判断是否包含全部指定的Scope
+func (r *IntrospectionResult) HasScopes(required []string) bool {
+	scopes := r.Scopes()
+	for _, req := range required {
+		found := false
+		for _, s := range scopes {
+			if s == req {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+type (
+	// TokenIntrospector 查询Token状态
+	TokenIntrospector interface {
+		Introspect(ctx context.Context, token string) (*IntrospectionResult, error)
+	}
+)
+
+// IntrospectionConfig Token内省配置
+type IntrospectionConfig struct {
+	SkipFunc flux.FilterSkipper
+	// 查找Token的函数；默认从Header:Authorization和form:access_token中查找
+	TokenExtractor func(ctx *flux.Context) (string, error)
+	// Token内省实现；默认按配置请求授权服务器的内省接口
+	Introspector TokenIntrospector
+}
+
+func NewIntrospectionFilter(c IntrospectionConfig) *IntrospectionFilter {
+	return &IntrospectionFilter{
+		Configs: c,
+		cache:   make(map[string]*list.Element, 128),
+		lru:     list.New(),
+	}
+}
+
+// IntrospectionFilter 通过授权服务器的Token内省接口（RFC 7662）验证不透明的Bearer Token；
+// 内省结果按Token的SHA-256摘要缓存，缓存时间不超过Token的有效期；缓存已满时，优先淘汰已过期的结果，其次淘汰最久未使用的结果。
+// 验证通过后，将 sub，scope，client_id 写入Attributes，供权限验证及参数查找使用。
+type IntrospectionFilter struct {
+	Configs    IntrospectionConfig
+	attrPrefix string
+	ttl        time.Duration
+	size       int
+	cache      map[string]*list.Element
+	lru        *list.List
+	mu         sync.Mutex
+}
+
+type introspectedToken struct {
+	key       string
+	result    *IntrospectionResult
+	expiresAt time.Time
+}
+
+func (f *IntrospectionFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCacheExpiration:         time.Minute,
+		ConfigKeyCacheSize:               10000,
+		ConfigKeyIntrospectionTimeout:    time.Second * 3,
+		ConfigKeyIntrospectionAttrPrefix: "oauth2",
+	})
+	f.ttl = config.GetDuration(ConfigKeyCacheExpiration)
+	f.size = config.GetInt(ConfigKeyCacheSize)
+	f.attrPrefix = config.GetString(ConfigKeyIntrospectionAttrPrefix)
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if fluxpkg.IsNil(f.Configs.TokenExtractor) {
+		f.Configs.TokenExtractor = ExtractTokenOAuth2
+	}
+	if fluxpkg.IsNil(f.Configs.Introspector) {
+		endpoint := config.GetString(ConfigKeyIntrospectionEndpoint)
+		if endpoint == "" {
+			return errors.New("IntrospectionFilter: config(endpoint) is required for default introspector")
+		}
+		secret, err := config.GetStringE(ConfigKeyIntrospectionClientSecret)
+		if nil != err {
+			return fmt.Errorf("IntrospectionFilter: %w", err)
+		}
+		f.Configs.Introspector = &HttpTokenIntrospector{
+			Endpoint:     endpoint,
+			ClientId:     config.GetString(ConfigKeyIntrospectionClientId),
+			ClientSecret: secret,
+			Client:       &http.Client{Timeout: config.GetDuration(ConfigKeyIntrospectionTimeout)},
+		}
+	}
+	logger.Infow("Introspection filter initializing", "cache-ttl", f.ttl, "cache-size", f.size)
+	return nil
+}
+
+func (*IntrospectionFilter) FilterId() string {
+	return TypeIdIntrospectionFilter
+}
+
+func (f *IntrospectionFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) || !ctx.Endpoint().Authorize() {
+			return next(ctx)
+		}
+		token, err := f.Configs.TokenExtractor(ctx)
+		if nil != err || token == "" {
+			return &flux.ServeError{
+				StatusCode: http.StatusUnauthorized,
+				ErrorCode:  flux.ErrorCodeJwtNotFound,
+				Message:    "OAUTH2:INTROSPECT: token not found",
+			}
+		}
+		result, err := f.introspect(ctx, token)
+		if nil != err {
+			logger.TraceContext(ctx).Warnw("OAUTH2:INTROSPECT:ERROR", "error", err)
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    "OAUTH2:INTROSPECT:ERROR",
+				CauseError: err,
+			}
+		}
+		// 授权服务器返回的 exp 已过期时，同样视为无效Token
+		if !result.Active || (result.Exp > 0 && time.Now().After(time.Unix(result.Exp, 0))) {
+			return &flux.ServeError{
+				StatusCode: http.StatusUnauthorized,
+				ErrorCode:  ErrorCodeTokenInactive,
+				Message:    "OAUTH2:INTROSPECT: token inactive",
+			}
+		}
+		if required := ctx.Endpoint().GetAttr(EndpointAttrTagRequiredScope).GetStringSlice(); !result.HasScopes(required) {
+			return &flux.ServeError{
+				StatusCode: http.StatusForbidden,
+				ErrorCode:  ErrorCodeTokenInsufficient,
+				Message:    "OAUTH2:INTROSPECT: insufficient scope",
+			}
+		}
+		ctx.SetVariable(ContextKeyIntrospection, result)
+		ctx.SetAttribute(f.attrPrefix+".sub", result.Sub)
+		ctx.SetAttribute(f.attrPrefix+".scope", result.Scope)
+		ctx.SetAttribute(f.attrPrefix+".client_id", result.ClientId)
+		if result.Username != "" {
+			ctx.SetAttribute(f.attrPrefix+".username", result.Username)
+		}
+		return next(ctx)
+	}
+}
+
+// introspect 查询Token状态；结果缓存时间不超过Token的剩余有效期
+func (f *IntrospectionFilter) introspect(ctx *flux.Context, token string) (*IntrospectionResult, error) {
+	digest := sha256.Sum256([]byte(token))
+	key := hex.EncodeToString(digest[:])
+	if result, ok := f.lookup(key); ok {
+		return result, nil
+	}
+	result, err := f.Configs.Introspector.Introspect(ctx.Context(), token)
+	if nil != err {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(f.ttl)
+	if result.Exp > 0 && time.Unix(result.Exp, 0).Before(expiresAt) {
+		expiresAt = time.Unix(result.Exp, 0)
+	}
+	f.store(key, result, expiresAt)
+	return result, nil
+}
+
+func (f *IntrospectionFilter) lookup(key string) (*IntrospectionResult, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	elem, ok := f.cache[key]
+	if !ok {
+		return nil, false
+	}
+	cached := elem.Value.(*introspectedToken)
+	if time.Now().After(cached.expiresAt) {
+		f.lru.Remove(elem)
+		delete(f.cache, key)
+		return nil, false
+	}
+	f.lru.MoveToFront(elem)
+	return cached.result, true
+}
+
+func (f *IntrospectionFilter) store(key string, result *IntrospectionResult, expiresAt time.Time) {
+	if f.size <= 0 || !time.Now().Before(expiresAt) {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if elem, ok := f.cache[key]; ok {
+		f.lru.Remove(elem)
+		delete(f.cache, key)
+	}
+	if f.lru.Len() >= f.size {
+		now := time.Now()
+		for elem := f.lru.Back(); nil != elem; {
+			prev := elem.Prev()
+			if cached := elem.Value.(*introspectedToken); now.After(cached.expiresAt) {
+				f.lru.Remove(elem)
+				delete(f.cache, cached.key)
+			}
+			elem = prev
+		}
+	}
+	for f.lru.Len() >= f.size {
+		oldest := f.lru.Back()
+		f.lru.Remove(oldest)
+		delete(f.cache, oldest.Value.(*introspectedToken).key)
+	}
+	f.cache[key] = f.lru.PushFront(&introspectedToken{key: key, result: result, expiresAt: expiresAt})
+}
+
+// IntrospectionOf 返回IntrospectionFilter验证通过的Token内省结果，供后续Filter使用
+func IntrospectionOf(ctx *flux.Context) (*IntrospectionResult, bool) {
+	v, ok := ctx.GetVariable(ContextKeyIntrospection)
+	if !ok {
+		return nil, false
+	}
+	result, ok := v.(*IntrospectionResult)
+	return result, ok
+}
+
+var _ TokenIntrospector = new(HttpTokenIntrospector)
+
+// HttpTokenIntrospector 请求授权服务器的Token内省接口；以HTTP Basic方式认证客户端
+type HttpTokenIntrospector struct {
+	Endpoint     string
+	ClientId     string
+	ClientSecret string
+	Client       *http.Client
+}
+
+func (i *HttpTokenIntrospector) Introspect(ctx context.Context, token string) (*IntrospectionResult, error) {
+	form := url.Values{"token": []string{token}, "token_type_hint": []string{"access_token"}}
+	req, err := http.NewRequest(http.MethodPost, i.Endpoint, strings.NewReader(form.Encode()))
+	if nil != err {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set(flux.HeaderContentType, flux.MIMEApplicationForm)
+	req.Header.Set("Accept", flux.MIMEApplicationJSON)
+	if i.ClientId != "" {
+		req.SetBasicAuth(url.QueryEscape(i.ClientId), url.QueryEscape(i.ClientSecret))
+	}
+	resp, err := i.Client.Do(req)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, introspectionMaxResponse+1))
+	if nil != err {
+		return nil, err
+	}
+	if len(data) > introspectionMaxResponse {
+		return nil, fmt.Errorf("introspection response exceeds %d bytes", introspectionMaxResponse)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returns status: %d, body: %s", resp.StatusCode, string(data))
+	}
+	result := new(IntrospectionResult)
+	if err := ext.JSONUnmarshal(data, result); nil != err {
+		return nil, fmt.Errorf("decode introspection response, err: %w", err)
+	}
+	if err := ext.JSONUnmarshal(data, &result.Extra); nil != err {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,157 @@
+package fluxext
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newIntrospectionServer 模拟授权服务器的内省接口：前缀为 active 的Token有效，Token的 exp 由 exps 指定
+func newIntrospectionServer(t *testing.T, calls *int32, exps map[string]int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(calls, 1)
+		id, secret, _ := r.BasicAuth()
+		assert.Equal(t, "gateway", id)
+		assert.Equal(t, "s3cret", secret)
+		token := r.PostFormValue("token")
+		w.Header().Set(flux.HeaderContentType, flux.MIMEApplicationJSON)
+		if !strings.HasPrefix(token, "active") {
+			_, _ = w.Write([]byte(`{"active":false}`))
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"active":true,"scope":"read write","client_id":"app","sub":"alice","exp":%d,"tenant":"t1"}`, exps[token])
+	}))
+}
+
+func newIntrospectionFilter(t *testing.T, server *httptest.Server, config map[string]interface{}) *IntrospectionFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	config[ConfigKeyIntrospectionEndpoint] = server.URL
+	config[ConfigKeyIntrospectionClientId] = "gateway"
+	config[ConfigKeyIntrospectionClientSecret] = "s3cret"
+	filter := NewIntrospectionFilter(IntrospectionConfig{})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(config)))
+	return filter
+}
+
+func introspectionInvoke(filter *IntrospectionFilter, token string, scopes ...string) (*flux.Context, *flux.ServeError) {
+	request := httptest.NewRequest(http.MethodGet, "http://gateway/users", nil)
+	if token != "" {
+		request.Header.Set(flux.HeaderAuthorization, "Bearer "+token)
+	}
+	attrs := []flux.Attribute{{Name: flux.EndpointAttrTagAuthorize, Value: true}}
+	if len(scopes) > 0 {
+		attrs = append(attrs, flux.Attribute{Name: EndpointAttrTagRequiredScope, Value: scopes})
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("introspection", request, nil, nil), &flux.Endpoint{
+		HttpPattern:        "/users",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+	})
+	ctx.SetResponseWriter(httptest.NewRecorder())
+	return ctx, filter.DoFilter(func(_ *flux.Context) *flux.ServeError {
+		return nil
+	})(ctx)
+}
+
+func TestIntrospectionFilter_Tokens(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	server := newIntrospectionServer(t, &calls, map[string]int64{
+		"active-token":   time.Now().Add(time.Hour).Unix(),
+		"active-expired": time.Now().Add(-time.Minute).Unix(),
+	})
+	defer server.Close()
+	filter := newIntrospectionFilter(t, server, map[string]interface{}{})
+	// 有效Token：写入内省结果及Attributes
+	ctx, serr := introspectionInvoke(filter, "active-token", "read")
+	assert.Nil(serr)
+	result, ok := IntrospectionOf(ctx)
+	if assert.True(ok) {
+		assert.Equal("alice", result.Sub)
+		assert.Equal("t1", result.Extra["tenant"])
+	}
+	clientId, _ := ctx.GetAttribute("oauth2.client_id")
+	assert.Equal("app", clientId)
+	_, serr = introspectionInvoke(filter, "active-token", "admin")
+	if assert.NotNil(serr) {
+		assert.Equal(ErrorCodeTokenInsufficient, serr.ErrorCode)
+	}
+	// 无效Token
+	_, serr = introspectionInvoke(filter, "revoked-token")
+	if assert.NotNil(serr) {
+		assert.Equal(http.StatusUnauthorized, serr.StatusCode)
+		assert.Equal(ErrorCodeTokenInactive, serr.ErrorCode)
+	}
+	_, serr = introspectionInvoke(filter, "")
+	if assert.NotNil(serr) {
+		assert.Equal(flux.ErrorCodeJwtNotFound, serr.ErrorCode)
+	}
+	// 已过期的Token视为无效，并且不缓存内省结果
+	atomic.StoreInt32(&calls, 0)
+	for i := 0; i < 2; i++ {
+		_, serr = introspectionInvoke(filter, "active-expired")
+		if assert.NotNil(serr) {
+			assert.Equal(ErrorCodeTokenInactive, serr.ErrorCode)
+		}
+	}
+	assert.Equal(int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestIntrospectionFilter_Cache(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	exp := time.Now().Add(time.Hour).Unix()
+	server := newIntrospectionServer(t, &calls, map[string]int64{"active-1": exp, "active-2": exp, "active-3": exp})
+	defer server.Close()
+	filter := newIntrospectionFilter(t, server, map[string]interface{}{
+		ConfigKeyCacheExpiration: "50ms",
+		ConfigKeyCacheSize:       2,
+	})
+	for i := 0; i < 3; i++ {
+		_, serr := introspectionInvoke(filter, "active-1")
+		assert.Nil(serr)
+	}
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+	// 缓存Key为Token的SHA-256摘要，不保存Token原文
+	digest := sha256.Sum256([]byte("active-1"))
+	assert.Contains(filter.cache, hex.EncodeToString(digest[:]))
+	assert.NotContains(filter.cache, "active-1")
+	// 缓存已满时淘汰最久未使用的结果，新Token仍可写入缓存
+	introspectionInvoke(filter, "active-2")
+	introspectionInvoke(filter, "active-1")
+	introspectionInvoke(filter, "active-3")
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+	assert.Len(filter.cache, 2)
+	introspectionInvoke(filter, "active-1")
+	introspectionInvoke(filter, "active-3")
+	assert.Equal(int32(3), atomic.LoadInt32(&calls))
+	introspectionInvoke(filter, "active-2")
+	assert.Equal(int32(4), atomic.LoadInt32(&calls))
+	// 缓存过期后重新内省
+	time.Sleep(time.Millisecond * 60)
+	introspectionInvoke(filter, "active-2")
+	assert.Equal(int32(5), atomic.LoadInt32(&calls))
+}
+
+func TestIntrospectionFilter_ResponseLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"active":true,"sub":"` + strings.Repeat("x", introspectionMaxResponse) + `"}`))
+	}))
+	defer server.Close()
+	introspector := &HttpTokenIntrospector{Endpoint: server.URL, Client: server.Client()}
+	_, err := introspector.Introspect(context.Background(), "token")
+	assert.Error(t, err)
+}
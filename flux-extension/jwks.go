@@ -0,0 +1,256 @@
+package fluxext
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/dgrijalva/jwt-go"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	ErrJWKSKeyNotFound = errors.New("jwks: signing key not found")
+)
+
+// JSONWebKey JWKS中的单个密钥定义，支持 RSA，EC，oct 类型
+type JSONWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// JWKSKeySet 从JWKS地址加载并缓存签名验证密钥；
+// 缓存过期后重新加载；Token的kid不在缓存中时立即重新加载（受最小加载间隔限制），以支持密钥轮换。
+type JWKSKeySet struct {
+	URL                string
+	RefreshInterval    time.Duration
+	MinRefreshInterval time.Duration
+	client             *http.Client
+	keys               map[string]interface{}
+	loadedAt           time.Time
+	mu                 sync.RWMutex
+	loading            sync.Mutex
+}
+
+func NewJWKSKeySet(url string, refresh, minRefresh, timeout time.Duration) *JWKSKeySet {
+	return &JWKSKeySet{
+		URL:                url,
+		RefreshInterval:    refresh,
+		MinRefreshInterval: minRefresh,
+		client:             &http.Client{Timeout: timeout},
+		keys:               make(map[string]interface{}, 4),
+	}
+}
+
+// LookupKey 按kid查找签名验证密钥
+func (s *JWKSKeySet) LookupKey(kid string) (interface{}, error) {
+	s.mu.RLock()
+	key, ok := s.lookup(kid)
+	expired := time.Since(s.loadedAt) > s.RefreshInterval
+	s.mu.RUnlock()
+	if ok && !expired {
+		return key, nil
+	}
+	if err := s.refresh(ok); nil != err {
+		if ok {
+			// 刷新失败时，继续使用缓存中的密钥
+			logger.Warnw("JWT:JWKS:REFRESH:ERROR", "url", s.URL, "error", err)
+			return key, nil
+		}
+		return nil, err
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if key, ok := s.lookup(kid); ok {
+		return key, nil
+	}
+	return nil, ErrJWKSKeyNotFound
+}
+
+func (s *JWKSKeySet) lookup(kid string) (interface{}, bool) {
+	if kid == "" && len(s.keys) == 1 {
+		for _, key := range s.keys {
+			return key, true
+		}
+	}
+	key, ok := s.keys[kid]
+	return key, ok
+}
+
+// refresh 重新加载JWKS；因kid缺失触发的加载，受最小加载间隔限制
+func (s *JWKSKeySet) refresh(expired bool) error {
+	s.loading.Lock()
+	defer s.loading.Unlock()
+	s.mu.RLock()
+	since := time.Since(s.loadedAt)
+	s.mu.RUnlock()
+	if expired && since <= s.RefreshInterval {
+		return nil
+	}
+	if !expired && since < s.MinRefreshInterval {
+		return nil
+	}
+	keys, err := s.fetch()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// 无论成功与否，都记录加载时间，避免频繁请求JWKS地址
+	s.loadedAt = time.Now()
+	if nil != err {
+		return err
+	}
+	s.keys = keys
+	logger.Infow("JWT:JWKS:REFRESHED", "url", s.URL, "keys", len(keys))
+	return nil
+}
+
+func (s *JWKSKeySet) fetch() (map[string]interface{}, error) {
+	resp, err := s.client.Get(s.URL)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: unexpected status: %d, url: %s", resp.StatusCode, s.URL)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, err
+	}
+	var set struct {
+		Keys []JSONWebKey `json:"keys"`
+	}
+	if err := ext.JSONUnmarshal(data, &set); nil != err {
+		return nil, fmt.Errorf("jwks: decode key set, err: %w", err)
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.PublicKey()
+		if nil != err {
+			logger.Warnw("JWT:JWKS:KEY:IGNORE", "url", s.URL, "kid", jwk.Kid, "error", err)
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+	return keys, nil
+}
+
+// PublicKey 解析为签名验证密钥：*rsa.PublicKey，*ecdsa.PublicKey，[]byte
+func (k JSONWebKey) PublicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKNumber(k.N)
+		if nil != err {
+			return nil, err
+		}
+		e, err := decodeJWKNumber(k.E)
+		if nil != err {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("jwks: unsupported curve: %s", k.Crv)
+		}
+		x, err := decodeJWKNumber(k.X)
+		if nil != err {
+			return nil, err
+		}
+		y, err := decodeJWKNumber(k.Y)
+		if nil != err {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "oct":
+		return base64.RawURLEncoding.DecodeString(k.K)
+	default:
+		return nil, fmt.Errorf("jwks: unsupported key type: %s", k.Kty)
+	}
+}
+
+func decodeJWKNumber(v string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(v)
+	if nil != err {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// JWTIssuer 受信任的Token签发方：签发方标识，接受的Audience列表，签名密钥集
+type JWTIssuer struct {
+	Issuer    string
+	Audiences []string
+	KeySet    *JWKSKeySet
+}
+
+// verify 校验Token的Audience；未配置Audience时不校验
+func (i *JWTIssuer) verify(claims jwt.MapClaims) bool {
+	if len(i.Audiences) == 0 {
+		return true
+	}
+	for _, aud := range i.Audiences {
+		if claims.VerifyAudience(aud, true) || containsAudience(claims["aud"], aud) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsAudience 兼容 aud 为字符串列表的情况
+func containsAudience(v interface{}, aud string) bool {
+	if list, ok := v.([]interface{}); ok {
+		for _, item := range list {
+			if s, ok := item.(string); ok && s == aud {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NewJWKSSecretKeyLoader 按Token的签发方(iss)与kid，从对应的JWKS中加载签名验证密钥
+func NewJWKSSecretKeyLoader(issuers map[string]*JWTIssuer) func(ctx *flux.Context, token *jwt.Token) (interface{}, error) {
+	return func(ctx *flux.Context, token *jwt.Token) (interface{}, error) {
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, errors.New("jwks: unsupported claims type")
+		}
+		iss, _ := claims["iss"].(string)
+		issuer, ok := issuers[iss]
+		if !ok {
+			issuer, ok = issuers[""]
+		}
+		if !ok || nil == issuer.KeySet {
+			return nil, fmt.Errorf("jwks: untrusted issuer: %s", iss)
+		}
+		kid, _ := token.Header["kid"].(string)
+		return issuer.KeySet.LookupKey(kid)
+	}
+}
@@ -4,12 +4,14 @@ import (
 	"fmt"
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
 	fluxpkg "github.com/bytepowered/flux/flux-pkg"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/dgrijalva/jwt-go/request"
 	"github.com/spf13/cast"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
@@ -21,6 +23,25 @@ const (
 	ConfigKeyAttachmentKey = "attachment_key"
 )
 
+const (
+	ConfigKeyJWTIssuer              = "issuer"
+	ConfigKeyJWTIssuers             = "issuers"
+	ConfigKeyJWTAudiences           = "audiences"
+	ConfigKeyJWKSURL                = "jwks_url"
+	ConfigKeyJWKSRefreshInterval    = "jwks_refresh_interval"
+	ConfigKeyJWKSMinRefreshInterval = "jwks_min_refresh_interval"
+	ConfigKeyJWKSTimeout            = "jwks_timeout"
+)
+
+const (
+	// Context变量：校验通过的Token声明(jwt.MapClaims)
+	ContextKeyJWTClaims = flux.VariableKeyJWTClaims
+)
+
+const (
+	ErrorCodeJwtUntrusted = "AUTHORIZATION:JWT:UNTRUSTED"
+)
+
 var _ flux.Filter = new(JWTFilter)
 
 type JWTConfig struct {
@@ -38,7 +59,8 @@ func NewJWTFilter(config JWTConfig) *JWTFilter {
 }
 
 type JWTFilter struct {
-	Config JWTConfig
+	Config  JWTConfig
+	issuers map[string]*JWTIssuer
 }
 
 func (f *JWTFilter) FilterId() string {
@@ -55,10 +77,58 @@ func (f *JWTFilter) Init(config *flux.Configuration) error {
 	if "" == f.Config.AttKeyPrefix {
 		f.Config.AttKeyPrefix = cast.ToString(config.GetOrDefault(ConfigKeyAttachmentKey, "jwt"))
 	}
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyJWKSRefreshInterval:    time.Hour,
+		ConfigKeyJWKSMinRefreshInterval: time.Second * 30,
+		ConfigKeyJWKSTimeout:            time.Second * 5,
+	})
+	f.issuers = make(map[string]*JWTIssuer, 4)
+	// 单个签发方：issuer, audiences, jwks_url；多个签发方：issuers[]
+	if config.IsSet(ConfigKeyJWTIssuer) || config.IsSet(ConfigKeyJWTAudiences) || config.IsSet(ConfigKeyJWKSURL) {
+		f.addIssuer(config, config)
+	}
+	for _, ic := range config.GetConfigurationSlice(ConfigKeyJWTIssuers) {
+		f.addIssuer(config, ic)
+	}
+	if nil == f.Config.SecretKeyLoader && len(f.issuers) > 0 {
+		f.Config.SecretKeyLoader = NewJWKSSecretKeyLoader(f.issuers)
+	}
 	fluxpkg.AssertNotNil(f.Config.SecretKeyLoader, "<secret-loader> must not nil")
 	return nil
 }
 
+func (f *JWTFilter) addIssuer(config, ic *flux.Configuration) {
+	issuer := &JWTIssuer{
+		Issuer:    ic.GetString(ConfigKeyJWTIssuer),
+		Audiences: ic.GetStringSlice(ConfigKeyJWTAudiences),
+	}
+	if url := ic.GetString(ConfigKeyJWKSURL); url != "" {
+		issuer.KeySet = NewJWKSKeySet(url, config.GetDuration(ConfigKeyJWKSRefreshInterval),
+			config.GetDuration(ConfigKeyJWKSMinRefreshInterval), config.GetDuration(ConfigKeyJWKSTimeout))
+	}
+	logger.Infow("JWT:ISSUER:ADD", "issuer", issuer.Issuer, "audiences", issuer.Audiences, "jwks", ic.GetString(ConfigKeyJWKSURL))
+	f.issuers[issuer.Issuer] = issuer
+}
+
+// verifyIssuer 校验Token的签发方与Audience；未配置签发方时不校验
+func (f *JWTFilter) verifyIssuer(claims jwt.MapClaims) error {
+	if len(f.issuers) == 0 {
+		return nil
+	}
+	iss, _ := claims["iss"].(string)
+	issuer, ok := f.issuers[iss]
+	if !ok {
+		// 未指定签发方标识的配置，接受任意签发方
+		if issuer, ok = f.issuers[""]; !ok {
+			return fmt.Errorf("untrusted issuer: %s", iss)
+		}
+	}
+	if !issuer.verify(claims) {
+		return fmt.Errorf("untrusted audience: %v", claims["aud"])
+	}
+	return nil
+}
+
 func (f *JWTFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
 	return func(ctx *flux.Context) *flux.ServeError {
 		// Endpoint指定不需要授权
@@ -81,11 +151,21 @@ func (f *JWTFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
 			return f.Config.SecretKeyLoader(ctx, token)
 		})
 		if token != nil && token.Valid {
+			if err := f.verifyIssuer(claims); nil != err {
+				ctx.Logger().Infow("JWT:VALIDATE:UNTRUSTED", "error", err)
+				return &flux.ServeError{
+					StatusCode: http.StatusUnauthorized,
+					ErrorCode:  ErrorCodeJwtUntrusted,
+					Message:    "JWT:VALIDATE: token untrusted",
+					CauseError: err,
+				}
+			}
 			// set claims to attributes
 			ctx.Logger().Infow("JWT:VALIDATE:PASSED", "jwt.claims", claims)
 			for k, v := range claims {
 				ctx.SetAttribute(f.Config.AttKeyPrefix+"."+k, v)
 			}
+			ctx.SetVariable(ContextKeyJWTClaims, claims)
 			return next(ctx)
 		} else {
 			ctx.Logger().Infow("JWT:VALIDATE:REJECTED", "error", err)
@@ -123,6 +203,16 @@ func (f *JWTFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
 	}
 }
 
+// JWTClaimsOf 返回JWTFilter校验通过的Token声明，供后续Filter使用
+func JWTClaimsOf(ctx *flux.Context) (jwt.MapClaims, bool) {
+	v, ok := ctx.GetVariable(ContextKeyJWTClaims)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := v.(jwt.MapClaims)
+	return claims, ok
+}
+
 // ExtractTokenOAuth2 按OAuth2请求，从Header:Authorization和form:access_token中抓取Token
 func ExtractTokenOAuth2(ctx *flux.Context) (string, error) {
 	return request.OAuth2Extractor.ExtractToken(ctx.Request())
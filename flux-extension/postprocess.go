@@ -0,0 +1,503 @@
+package fluxext
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/spf13/cast"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	TypeIdPostProcessFilter = "postprocess_filter"
+)
+
+const (
+	// Endpoint属性：响应数据后处理脚本，可定义多个，单个属性内以分号分隔多条语句
+	EndpointAttrTagPostProcess = "postprocess"
+)
+
+// PostProcessConfig 响应后处理配置
+type PostProcessConfig struct {
+	SkipFunc flux.FilterSkipper
+}
+
+func NewPostProcessFilter(c PostProcessConfig) *PostProcessFilter {
+	return &PostProcessFilter{
+		Configs: c,
+	}
+}
+
+// PostProcessFilter 按Endpoint定义的后处理脚本，在响应数据解析后、输出前，对JSON响应数据作少量调整；
+// 适用于不需要完整转换规则的场景。支持语句：
+// 1. rename a.b -> c：重命名（移动）字段；
+// 2. drop a.b：删除字段；
+// 3. dropnulls [a.b]：递归删除值为null的字段，未指定路径时处理全部数据；
+// 4. set a.b = <表达式>：设置字段值；表达式支持数值，字符串（双引号或单引号），true，false，null字面值，$.path 读取响应数据，$attr.name 读取Attribute，
+// 运算符 + - * / 及括号；+ 的任一操作数不是数值时，作字符串拼接。字段名可以包含 -，路径之后的减号前需要空格，例如 $.total - 1。
+// 路径可省略 $. 前缀；字符串字面值内的分号不作为语句分隔符。
+type PostProcessFilter struct {
+	Configs  PostProcessConfig
+	compiled sync.Map
+}
+
+func (f *PostProcessFilter) Init(_ *flux.Configuration) error {
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Info("PostProcess filter initializing")
+	return nil
+}
+
+func (*PostProcessFilter) FilterId() string {
+	return TypeIdPostProcessFilter
+}
+
+func (f *PostProcessFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		if scripts := attrTexts(ctx.Endpoint(), EndpointAttrTagPostProcess); len(scripts) > 0 {
+			ctx.AddResponseHook(func(ctx *flux.Context, response *flux.ResponseBody) error {
+				return f.process(ctx, scripts, response)
+			})
+		}
+		return next(ctx)
+	}
+}
+
+func (f *PostProcessFilter) process(ctx *flux.Context, scripts []string, response *flux.ResponseBody) error {
+	data, ok, err := decodeResponseJSON(response)
+	if nil != err || !ok {
+		// 非JSON数据，不作处理
+		return err
+	}
+	for _, script := range scripts {
+		statements, err := f.lookup(script)
+		if nil != err {
+			return err
+		}
+		for _, stmt := range statements {
+			if data, err = stmt(ctx, data); nil != err {
+				return fmt.Errorf("postprocess script: %s, err: %w", script, err)
+			}
+		}
+	}
+	out, err := ext.JSONMarshal(data)
+	if nil != err {
+		return err
+	}
+	response.Body = out
+	if nil != response.Headers {
+		response.Headers.Del(flux.HeaderContentLength)
+	}
+	return nil
+}
+
+// lookup 解析并缓存后处理脚本
+func (f *PostProcessFilter) lookup(script string) ([]PostStatement, error) {
+	if v, ok := f.compiled.Load(script); ok {
+		return v.([]PostStatement), nil
+	}
+	statements, err := ParsePostProcessScript(script)
+	if nil != err {
+		return nil, err
+	}
+	f.compiled.Store(script, statements)
+	return statements, nil
+}
+
+// PostStatement 后处理语句，返回处理后的数据
+type PostStatement func(ctx *flux.Context, data interface{}) (interface{}, error)
+
+// ParsePostProcessScript 解析以分号分隔的后处理语句
+func ParsePostProcessScript(script string) ([]PostStatement, error) {
+	out := make([]PostStatement, 0, 4)
+	texts, err := splitPostStatements(script)
+	if nil != err {
+		return nil, err
+	}
+	for _, text := range texts {
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		stmt, err := parsePostStatement(text)
+		if nil != err {
+			return nil, fmt.Errorf("invalid postprocess statement: %s, err: %w", text, err)
+		}
+		out = append(out, stmt)
+	}
+	return out, nil
+}
+
+// splitPostStatements 按分号分割语句；字符串字面值内的分号不作分割
+func splitPostStatements(script string) ([]string, error) {
+	out := make([]string, 0, 4)
+	start := 0
+	for i := 0; i < len(script); i++ {
+		switch c := script[i]; c {
+		case '"', '\'':
+			end, ok := postQuoteEnd(script, i)
+			if !ok {
+				return nil, fmt.Errorf("unterminated string literal: %s", script[i:])
+			}
+			i = end
+		case ';':
+			out = append(out, script[start:i])
+			start = i + 1
+		}
+	}
+	return append(out, script[start:]), nil
+}
+
+// postQuoteEnd 返回从start开始的字符串字面值的结束引号位置；支持反斜杠转义
+func postQuoteEnd(text string, start int) (int, bool) {
+	quote := text[start]
+	for i := start + 1; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case quote:
+			return i, true
+		}
+	}
+	return len(text), false
+}
+
+// postPath 规范化语句中的字段路径：去除 $. 前缀；路径为空时返回错误
+func postPath(path string) (string, error) {
+	out := strings.TrimPrefix(strings.TrimPrefix(strings.TrimSpace(path), "$"), ".")
+	if out == "" {
+		return "", fmt.Errorf("invalid field path: '%s'", path)
+	}
+	return out, nil
+}
+
+func parsePostStatement(text string) (PostStatement, error) {
+	fields := strings.Fields(text)
+	switch strings.ToLower(fields[0]) {
+	case "rename":
+		parts := strings.SplitN(strings.TrimSpace(text[len(fields[0]):]), "->", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, errors.New("usage: rename <path> -> <path>")
+		}
+		from, err := postPath(parts[0])
+		if nil != err {
+			return nil, err
+		}
+		to, err := postPath(parts[1])
+		if nil != err {
+			return nil, err
+		}
+		return func(_ *flux.Context, data interface{}) (interface{}, error) {
+			if root, ok := data.(map[string]interface{}); ok {
+				if value, ok := LookupJSONPath(root, from); ok && deleteJSONPath(root, from) {
+					setJSONPath(root, to, value)
+				}
+			}
+			return data, nil
+		}, nil
+	case "drop":
+		if len(fields) != 2 {
+			return nil, errors.New("usage: drop <path>")
+		}
+		path, err := postPath(fields[1])
+		if nil != err {
+			return nil, err
+		}
+		return func(_ *flux.Context, data interface{}) (interface{}, error) {
+			deleteJSONPath(data, path)
+			return data, nil
+		}, nil
+	case "dropnulls":
+		if len(fields) > 2 {
+			return nil, errors.New("usage: dropnulls [path]")
+		}
+		path := "$"
+		if len(fields) == 2 {
+			p, err := postPath(fields[1])
+			if nil != err {
+				return nil, err
+			}
+			path = "$." + p
+		}
+		return func(_ *flux.Context, data interface{}) (interface{}, error) {
+			if target, ok := LookupJSONPath(data, path); ok {
+				dropNulls(target)
+			}
+			return data, nil
+		}, nil
+	case "set":
+		parts := strings.SplitN(strings.TrimSpace(text[len(fields[0]):]), "=", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" {
+			return nil, errors.New("usage: set <path> = <expr>")
+		}
+		path, err := postPath(parts[0])
+		if nil != err {
+			return nil, err
+		}
+		expr, err := ParsePostExpr(parts[1])
+		if nil != err {
+			return nil, err
+		}
+		return func(ctx *flux.Context, data interface{}) (interface{}, error) {
+			root, ok := data.(map[string]interface{})
+			if !ok {
+				return data, nil
+			}
+			value, err := expr(ctx, data)
+			if nil != err {
+				return nil, err
+			}
+			setJSONPath(root, path, value)
+			return data, nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown statement: %s", fields[0])
+	}
+}
+
+// PostExpr 后处理表达式
+type PostExpr func(ctx *flux.Context, data interface{}) (interface{}, error)
+
+// ParsePostExpr 解析后处理表达式
+func ParsePostExpr(text string) (PostExpr, error) {
+	tokens, err := tokenizePostExpr(text)
+	if nil != err {
+		return nil, err
+	}
+	p := &postExprParser{tokens: tokens}
+	expr, err := p.parseExpr()
+	if nil != err {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token: %s", p.tokens[p.pos])
+	}
+	return expr, nil
+}
+
+type postExprParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *postExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+// parseExpr expr := term (('+'|'-') term)*
+func (p *postExprParser) parseExpr() (PostExpr, error) {
+	left, err := p.parseTerm()
+	if nil != err {
+		return nil, err
+	}
+	for op := p.peek(); op == "+" || op == "-"; op = p.peek() {
+		p.pos++
+		right, err := p.parseTerm()
+		if nil != err {
+			return nil, err
+		}
+		left = binaryPostExpr(op, left, right)
+	}
+	return left, nil
+}
+
+// parseTerm term := factor (('*'|'/') factor)*
+func (p *postExprParser) parseTerm() (PostExpr, error) {
+	left, err := p.parseFactor()
+	if nil != err {
+		return nil, err
+	}
+	for op := p.peek(); op == "*" || op == "/"; op = p.peek() {
+		p.pos++
+		right, err := p.parseFactor()
+		if nil != err {
+			return nil, err
+		}
+		left = binaryPostExpr(op, left, right)
+	}
+	return left, nil
+}
+
+// parseFactor factor := literal | path | '(' expr ')' | '-' factor
+func (p *postExprParser) parseFactor() (PostExpr, error) {
+	token := p.peek()
+	p.pos++
+	switch {
+	case token == "":
+		return nil, errors.New("unexpected end of expression")
+	case token == "(":
+		expr, err := p.parseExpr()
+		if nil != err {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing ')'")
+		}
+		p.pos++
+		return expr, nil
+	case token == "-":
+		operand, err := p.parseFactor()
+		if nil != err {
+			return nil, err
+		}
+		return binaryPostExpr("-", constPostExpr(float64(0)), operand), nil
+	case strings.HasPrefix(token, "$attr."):
+		name := token[len("$attr."):]
+		return func(ctx *flux.Context, _ interface{}) (interface{}, error) {
+			v, _ := ctx.GetAttribute(name)
+			return v, nil
+		}, nil
+	case strings.HasPrefix(token, "$"):
+		return func(_ *flux.Context, data interface{}) (interface{}, error) {
+			v, _ := LookupJSONPath(data, token)
+			return v, nil
+		}, nil
+	case token[0] == '\'':
+		// 单引号字符串只支持 \' 及 \\ 转义
+		str := strings.NewReplacer(`\\`, `\`, `\'`, `'`).Replace(token[1 : len(token)-1])
+		return constPostExpr(str), nil
+	case token[0] == '"':
+		str, err := strconv.Unquote(token)
+		if nil != err {
+			return nil, fmt.Errorf("invalid string literal: %s", token)
+		}
+		return constPostExpr(str), nil
+	case token == "true" || token == "false":
+		return constPostExpr(token == "true"), nil
+	case token == "null":
+		return constPostExpr(nil), nil
+	default:
+		num, err := strconv.ParseFloat(token, 64)
+		if nil != err {
+			return nil, fmt.Errorf("invalid literal: %s", token)
+		}
+		return constPostExpr(num), nil
+	}
+}
+
+func constPostExpr(value interface{}) PostExpr {
+	return func(_ *flux.Context, _ interface{}) (interface{}, error) {
+		return value, nil
+	}
+}
+
+func binaryPostExpr(op string, left, right PostExpr) PostExpr {
+	return func(ctx *flux.Context, data interface{}) (interface{}, error) {
+		lv, err := left(ctx, data)
+		if nil != err {
+			return nil, err
+		}
+		rv, err := right(ctx, data)
+		if nil != err {
+			return nil, err
+		}
+		ln, lerr := cast.ToFloat64E(lv)
+		rn, rerr := cast.ToFloat64E(rv)
+		if op == "+" && (!isPostNumber(lv) || !isPostNumber(rv)) {
+			return cast.ToString(lv) + cast.ToString(rv), nil
+		}
+		if nil != lerr || nil != rerr {
+			return nil, fmt.Errorf("operator '%s' requires numbers, left: %v, right: %v", op, lv, rv)
+		}
+		switch op {
+		case "+":
+			return ln + rn, nil
+		case "-":
+			return ln - rn, nil
+		case "*":
+			return ln * rn, nil
+		default:
+			if rn == 0 {
+				return nil, errors.New("division by zero")
+			}
+			return ln / rn, nil
+		}
+	}
+}
+
+func isPostNumber(v interface{}) bool {
+	switch v.(type) {
+	case float64, float32, int, int32, int64, uint, uint32, uint64:
+		return true
+	}
+	return false
+}
+
+// tokenizePostExpr 将表达式分割为：运算符，括号，字符串字面值（双引号或单引号），数值，路径及其它连续字符；
+// 路径（$开头）内的 - 为字段名的一部分，数值内的 - 只用于指数，例如 1e-3；其它位置的 - 为减号或负号。
+func tokenizePostExpr(text string) ([]string, error) {
+	tokens := make([]string, 0, 8)
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case strings.IndexByte("+-*/()", c) >= 0:
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"' || c == '\'':
+			end, ok := postQuoteEnd(text, i)
+			if !ok {
+				return nil, fmt.Errorf("unterminated string literal: %s", text[i:])
+			}
+			tokens = append(tokens, text[i:end+1])
+			i = end + 1
+		default:
+			end := i
+			for end < len(text) {
+				ch := text[end]
+				if ch == '-' && c == '$' {
+					end++
+					continue
+				}
+				// 数值的指数部分：1e-3，2E+5
+				if (ch == '-' || ch == '+') && end > i && (text[end-1] == 'e' || text[end-1] == 'E') && isPostDigit(c) {
+					end++
+					continue
+				}
+				if strings.IndexByte(" \t+-*/()", ch) >= 0 {
+					break
+				}
+				end++
+			}
+			tokens = append(tokens, text[i:end])
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+func isPostDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || c == '.'
+}
+
+// dropNulls 递归删除值为null的字段
+func dropNulls(data interface{}) {
+	switch v := data.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			if nil == item {
+				delete(v, k)
+			} else {
+				dropNulls(item)
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			dropNulls(item)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func newPostProcessContext() *flux.Context {
+	ctx := flux.NewContext()
+	ctx.SetAttribute("tenant", "t1")
+	return ctx
+}
+
+func TestParsePostExpr(t *testing.T) {
+	assert := assert.New(t)
+	data := map[string]interface{}{
+		"total":      float64(10),
+		"first-name": "Ada",
+		"items":      []interface{}{map[string]interface{}{"price": float64(2.5)}},
+	}
+	cases := map[string]interface{}{
+		"1 + 2 * 3":             float64(7),
+		"(1 + 2) * 3":           float64(9),
+		"-3 + 1":                float64(-2),
+		"10-4":                  float64(6),
+		"2 - -1":                float64(3),
+		"5e-1 * 4":              float64(2),
+		"$.total - 1":           float64(9),
+		"$.total / 4":           float64(2.5),
+		"$.items[0].price * 2":  float64(5),
+		"$.first-name":          "Ada",
+		"$.first-name + ' Lee'": "Ada Lee",
+		`"a;b" + 'c\'d'`:        "a;bc'd",
+		"'id-' + $.total":       "id-10",
+		"$attr.tenant":          "t1",
+		"true":                  true,
+		"null":                  nil,
+		"$.missing":             nil,
+	}
+	for text, expected := range cases {
+		expr, err := ParsePostExpr(text)
+		if !assert.NoError(err, text) {
+			continue
+		}
+		value, err := expr(newPostProcessContext(), data)
+		assert.NoError(err, text)
+		assert.Equal(expected, value, text)
+	}
+	for _, text := range []string{"1 +", "(1 + 2", "'abc", `"abc`, "1 2", "abc"} {
+		_, err := ParsePostExpr(text)
+		assert.Error(err, text)
+	}
+	// 运行时错误
+	for _, text := range []string{"1 / 0", "$.first-name * 2"} {
+		expr, err := ParsePostExpr(text)
+		if assert.NoError(err, text) {
+			_, err = expr(newPostProcessContext(), data)
+			assert.Error(err, text)
+		}
+	}
+}
+
+func TestParsePostProcessScript(t *testing.T) {
+	assert := assert.New(t)
+	statements, err := ParsePostProcessScript(`set $.label = "a;b"; set $.count = $.total + 1;;`)
+	assert.NoError(err)
+	assert.Len(statements, 2)
+	for _, script := range []string{"unknown a", "drop", "drop a b", "rename a", "rename a -> ", "set a", "set $ = 1", "set = 1", "set a = 'x"} {
+		_, err := ParsePostProcessScript(script)
+		assert.Error(err, script)
+	}
+}
+
+func TestPostProcessFilter_Process(t *testing.T) {
+	assert := assert.New(t)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	filter := NewPostProcessFilter(PostProcessConfig{})
+	assert.NoError(filter.Init(flux.NewEmptyConfiguration()))
+	response := &flux.ResponseBody{
+		StatusCode: flux.StatusOK,
+		Headers:    http.Header{flux.HeaderContentLength: {"100"}},
+		Body:       []byte(`{"user":{"name":"ada","secret":"x","nick":null},"total":2,"tags":[{"v":null}]}`),
+	}
+	err := filter.process(newPostProcessContext(), []string{
+		"rename user.name -> profile.name; drop $.user.secret",
+		"dropnulls; set $.total = $.total * 10; set profile.tenant = $attr.tenant + ';'",
+	}, response)
+	assert.NoError(err)
+	var out map[string]interface{}
+	assert.NoError(ext.JSONUnmarshal(response.Body.([]byte), &out))
+	assert.Equal(map[string]interface{}{
+		"user":    map[string]interface{}{},
+		"profile": map[string]interface{}{"name": "ada", "tenant": "t1;"},
+		"total":   float64(20),
+		"tags":    []interface{}{map[string]interface{}{}},
+	}, out)
+	assert.Empty(response.Headers.Get(flux.HeaderContentLength))
+}
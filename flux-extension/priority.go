@@ -0,0 +1,56 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"strings"
+)
+
+const (
+	// Endpoint属性：请求优先级；high, normal, low
+	EndpointAttrTagPriority = "priority"
+)
+
+// Priority 请求优先级
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+var priorityNames = []string{"low", "normal", "high"}
+
+func (p Priority) String() string {
+	if p < PriorityLow || p > PriorityHigh {
+		return "unknown"
+	}
+	return priorityNames[p]
+}
+
+// ParsePriority 解析优先级名称；无法解析时返回 normal
+func ParsePriority(name string) (Priority, bool) {
+	for i, n := range priorityNames {
+		if strings.EqualFold(n, strings.TrimSpace(name)) {
+			return Priority(i), true
+		}
+	}
+	return PriorityNormal, false
+}
+
+// EndpointPriority 返回Endpoint定义的请求优先级；未定义时为 normal
+func EndpointPriority(endpoint *flux.Endpoint) Priority {
+	p, _ := ParsePriority(endpoint.GetAttr(EndpointAttrTagPriority).GetString())
+	return p
+}
+
+// RequestPriority 返回请求的优先级：请求Header（header非空时）定义的优先级优先，其次为Endpoint定义的优先级；
+// 优先级Header应由可信的上游（例如边缘网关）设置，避免客户端自行提升优先级。
+func RequestPriority(ctx *flux.Context, header string) Priority {
+	if header != "" {
+		if p, ok := ParsePriority(ctx.HeaderVar(header)); ok {
+			return p
+		}
+	}
+	return EndpointPriority(ctx.Endpoint())
+}
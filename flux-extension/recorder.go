@@ -0,0 +1,58 @@
+package fluxext
+
+import (
+	"bytes"
+	"net/http"
+)
+
+// responseRecorder 在ResponseWriter层记录写入客户端的响应状态码，Header及数据，用于保存并重放响应；
+// 记录与TransportWriter的实现无关。响应数据超过 limit 时停止记录。
+type responseRecorder struct {
+	http.ResponseWriter
+	limit    int
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	overflow bool
+}
+
+func newResponseRecorder(w http.ResponseWriter, limit int) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, limit: limit}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+		r.header = replayableHeader(r.ResponseWriter.Header())
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if r.body.Len()+len(data) > r.limit {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(data)
+		}
+	}
+	return r.ResponseWriter.Write(data)
+}
+
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// recorded 返回记录的响应；未写入响应，或响应数据超过限制时返回false
+func (r *responseRecorder) recorded() (status int, header http.Header, body []byte, ok bool) {
+	if r.status == 0 || r.overflow {
+		return 0, nil, nil, false
+	}
+	return r.status, r.header, append([]byte(nil), r.body.Bytes()...), true
+}
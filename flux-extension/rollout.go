@@ -0,0 +1,279 @@
+package fluxext
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"hash/fnv"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// RolloutSegmentAny 匹配全部消费方分组的规则
+	RolloutSegmentAny = "*"
+)
+
+const (
+	HeaderXConsumerId    = "X-Consumer-Id"
+	HeaderXConsumerGroup = "X-Consumer-Group"
+)
+
+type (
+	// ConsumerLookupFunc 查找请求的消费方标识和所属分组
+	ConsumerLookupFunc func(webex flux.ServerWebContext) (consumerId string, segment string)
+)
+
+// RolloutRule 按消费方分组的版本灰度规则
+type RolloutRule struct {
+	Method  string         `json:"method"`
+	Pattern string         `json:"pattern"`
+	Segment string         `json:"segment"`
+	Weights map[string]int `json:"weights"` // version -> weight
+}
+
+// RolloutConfig 版本灰度配置
+type RolloutConfig struct {
+	// ConsumerFunc 消费方查找函数；默认从Header X-Consumer-Id, X-Consumer-Group 读取
+	ConsumerFunc ConsumerLookupFunc
+	// VersionHeader 无灰度规则时，读取客户端指定版本的Header；默认为 X-Version
+	VersionHeader string
+	// AdminPath 灰度规则管理接口的路径；默认为 /debug/rollout
+	AdminPath string
+	// AdminListeners 注册管理接口的WebListener；默认为 admin
+	AdminListeners []string
+}
+
+func NewRolloutSelector(c RolloutConfig) *RolloutSelector {
+	if c.ConsumerFunc == nil {
+		c.ConsumerFunc = DefaultConsumerLookupFunc
+	}
+	if c.VersionHeader == "" {
+		c.VersionHeader = "X-Version"
+	}
+	if c.AdminPath == "" {
+		c.AdminPath = "/debug/rollout"
+	}
+	if len(c.AdminListeners) == 0 {
+		c.AdminListeners = []string{"admin"}
+	}
+	return &RolloutSelector{
+		Configs: c,
+		rules:   make(map[string]map[string]RolloutRule, 8),
+	}
+}
+
+var (
+	_ flux.EndpointSelector    = new(RolloutSelector)
+	_ flux.WebHandlerRegistrar = new(RolloutSelector)
+)
+
+// RolloutSelector 按消费方分组的权重，选择Endpoint版本，用于版本的逐步灰度发布。
+// 例如：内部用户分组全部使用v2，其它消费方5%使用v2；相同消费方在权重不变时，稳定选择同一版本。
+// 通过 ext.AddEndpointSelector 注册；通过管理服务的 AdminPath 接口管理灰度规则。
+type RolloutSelector struct {
+	Configs RolloutConfig
+	rules   map[string]map[string]RolloutRule // route -> segment -> rule
+	mu      sync.RWMutex
+}
+
+func (s *RolloutSelector) Active(_ flux.ServerWebContext, _ string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.rules) > 0
+}
+
+func (s *RolloutSelector) DoSelect(webex flux.ServerWebContext, _ string, multi *flux.MVCEndpoint) (flux.Endpoint, bool) {
+	versions := multi.Endpoints()
+	if len(versions) == 0 {
+		return flux.Endpoint{}, false
+	}
+	route := rolloutRouteKey(versions[0].HttpMethod, versions[0].HttpPattern)
+	consumer, segment := s.Configs.ConsumerFunc(webex)
+	if rule, ok := s.lookup(route, segment); ok {
+		if version, ok := pickRolloutVersion(rule.Weights, consumer, route); ok {
+			if ep, ok := multi.Lookup(version); ok {
+				return ep, true
+			}
+			logger.Trace(webex.RequestId()).Warnw("ROLLOUT:VERSION:NOT_FOUND", "route", route, "version", version)
+		}
+	}
+	return multi.Lookup(webex.HeaderVar(s.Configs.VersionHeader))
+}
+
+// SetRule 添加或更新灰度规则；权重全部为0时删除规则
+func (s *RolloutSelector) SetRule(rule RolloutRule) error {
+	if rule.Method == "" || rule.Pattern == "" {
+		return fmt.Errorf("rollout rule requires method and pattern")
+	}
+	if rule.Segment == "" {
+		rule.Segment = RolloutSegmentAny
+	}
+	total := 0
+	for version, weight := range rule.Weights {
+		if weight < 0 {
+			return fmt.Errorf("rollout weight must not negative, version: %s", version)
+		}
+		total += weight
+	}
+	route := rolloutRouteKey(rule.Method, rule.Pattern)
+	if total == 0 {
+		s.RemoveRule(rule.Method, rule.Pattern, rule.Segment)
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	segments, ok := s.rules[route]
+	if !ok {
+		segments = make(map[string]RolloutRule, 2)
+		s.rules[route] = segments
+	}
+	segments[rule.Segment] = rule
+	logger.Infow("ROLLOUT:RULE:SET", "route", route, "segment", rule.Segment, "weights", rule.Weights)
+	return nil
+}
+
+// RemoveRule 删除灰度规则
+func (s *RolloutSelector) RemoveRule(method, pattern, segment string) {
+	route := rolloutRouteKey(method, pattern)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if segments, ok := s.rules[route]; ok {
+		delete(segments, segment)
+		if len(segments) == 0 {
+			delete(s.rules, route)
+		}
+	}
+	logger.Infow("ROLLOUT:RULE:REMOVE", "route", route, "segment", segment)
+}
+
+// Rules 返回全部灰度规则
+func (s *RolloutSelector) Rules() []RolloutRule {
+	s.mu.RLock()
+	out := make([]RolloutRule, 0, len(s.rules))
+	for _, segments := range s.rules {
+		for _, rule := range segments {
+			out = append(out, rule)
+		}
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		a, b := out[i], out[j]
+		if a.Pattern != b.Pattern {
+			return a.Pattern < b.Pattern
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Segment < b.Segment
+	})
+	return out
+}
+
+// RegisterWebHandlers 向 AdminListeners 中的WebListener注册灰度规则的管理接口
+func (s *RolloutSelector) RegisterWebHandlers(listenerId string, server flux.WebListener) {
+	if !fluxpkg.StringSliceContains(s.Configs.AdminListeners, listenerId) {
+		return
+	}
+	handler := s.AdminHandler()
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		server.AddHandler(method, s.Configs.AdminPath, handler)
+	}
+}
+
+// AdminHandler 返回灰度规则的管理接口：GET 查询规则；POST 以JSON设置规则；DELETE 以参数 method, pattern, segment 删除规则
+func (s *RolloutSelector) AdminHandler() flux.WebHandler {
+	return func(webex flux.ServerWebContext) error {
+		switch webex.Method() {
+		case http.MethodPost, http.MethodPut:
+			reader, err := webex.BodyReader()
+			if nil != err {
+				return err
+			}
+			data, err := ioutil.ReadAll(reader)
+			_ = reader.Close()
+			if nil != err {
+				return err
+			}
+			var rule RolloutRule
+			if err := ext.JSONUnmarshal(data, &rule); nil != err {
+				return webex.Write(flux.StatusBadRequest, flux.MIMETextPlainCharsetUTF8, []byte("invalid rule: "+err.Error()))
+			}
+			if err := s.SetRule(rule); nil != err {
+				return webex.Write(flux.StatusBadRequest, flux.MIMETextPlainCharsetUTF8, []byte(err.Error()))
+			}
+		case http.MethodDelete:
+			segment := webex.QueryVar("segment")
+			if segment == "" {
+				segment = RolloutSegmentAny
+			}
+			s.RemoveRule(webex.QueryVar("method"), webex.QueryVar("pattern"), segment)
+		}
+		bytes, err := common.SerializeObject(s.Rules())
+		if nil != err {
+			return err
+		}
+		return webex.Write(flux.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, bytes)
+	}
+}
+
+func (s *RolloutSelector) lookup(route, segment string) (RolloutRule, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	segments, ok := s.rules[route]
+	if !ok {
+		return RolloutRule{}, false
+	}
+	if rule, ok := segments[segment]; ok && segment != "" {
+		return rule, true
+	}
+	rule, ok := segments[RolloutSegmentAny]
+	return rule, ok
+}
+
+// DefaultConsumerLookupFunc 从Header X-Consumer-Id, X-Consumer-Group 读取消费方标识和分组
+func DefaultConsumerLookupFunc(webex flux.ServerWebContext) (string, string) {
+	return webex.HeaderVar(HeaderXConsumerId), webex.HeaderVar(HeaderXConsumerGroup)
+}
+
+// pickRolloutVersion 按权重选择版本；有消费方标识时，按标识哈希稳定选择
+func pickRolloutVersion(weights map[string]int, consumer, route string) (string, bool) {
+	versions := make([]string, 0, len(weights))
+	total := 0
+	for version, weight := range weights {
+		if weight > 0 {
+			versions = append(versions, version)
+			total += weight
+		}
+	}
+	if total == 0 {
+		return "", false
+	}
+	sort.Strings(versions)
+	var point int
+	if consumer != "" {
+		hash := fnv.New32a()
+		_, _ = hash.Write([]byte(consumer + "#" + route))
+		point = int(hash.Sum32() % uint32(total))
+	} else {
+		point = rand.Intn(total)
+	}
+	for _, version := range versions {
+		point -= weights[version]
+		if point < 0 {
+			return version, true
+		}
+	}
+	return versions[len(versions)-1], true
+}
+
+func rolloutRouteKey(method, pattern string) string {
+	return strings.ToUpper(method) + "#" + pattern
+}
@@ -0,0 +1,170 @@
+package fluxext
+
+import (
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func newRolloutEndpoints() *flux.MVCEndpoint {
+	multi := flux.NewMultiEndpoint(&flux.Endpoint{Version: "v1", HttpMethod: http.MethodGet, HttpPattern: "/users"})
+	multi.Update("v2", &flux.Endpoint{Version: "v2", HttpMethod: http.MethodGet, HttpPattern: "/users"})
+	return multi
+}
+
+func newRolloutContext(method, target, body string, headers map[string]string) (*flux.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(body)), nil
+	}
+	for name, value := range headers {
+		request.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("rollout", request, nil, nil), &flux.Endpoint{})
+	ctx.SetResponseWriter(recorder)
+	return ctx, recorder
+}
+
+func rolloutSelect(selector *RolloutSelector, consumer, segment string, headers map[string]string) string {
+	if headers == nil {
+		headers = make(map[string]string, 2)
+	}
+	headers[HeaderXConsumerId] = consumer
+	headers[HeaderXConsumerGroup] = segment
+	ctx, _ := newRolloutContext(http.MethodGet, "http://gateway/users", "", headers)
+	ep, ok := selector.DoSelect(ctx, "default", newRolloutEndpoints())
+	if !ok {
+		return ""
+	}
+	return ep.Version
+}
+
+func TestPickRolloutVersion(t *testing.T) {
+	assert := assert.New(t)
+	_, ok := pickRolloutVersion(map[string]int{"v1": 0, "v2": 0}, "alice", "GET#/users")
+	assert.False(ok)
+	// 权重为0的版本不被选择
+	for i := 0; i < 100; i++ {
+		version, ok := pickRolloutVersion(map[string]int{"v1": 0, "v2": 10}, "", "GET#/users")
+		assert.True(ok)
+		assert.Equal("v2", version)
+	}
+	// 相同消费方稳定选择同一版本
+	weights := map[string]int{"v1": 50, "v2": 50}
+	first, _ := pickRolloutVersion(weights, "alice", "GET#/users")
+	for i := 0; i < 10; i++ {
+		version, _ := pickRolloutVersion(weights, "alice", "GET#/users")
+		assert.Equal(first, version)
+	}
+	// 按权重比例分配消费方
+	weights = map[string]int{"v1": 90, "v2": 10}
+	counts := make(map[string]int, 2)
+	for i := 0; i < 10000; i++ {
+		version, _ := pickRolloutVersion(weights, "consumer-"+strconv.Itoa(i), "GET#/users")
+		counts[version]++
+	}
+	assert.InDelta(9000, counts["v1"], 300)
+	assert.InDelta(1000, counts["v2"], 300)
+}
+
+func TestRolloutSelector_DoSelect(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	assert := assert.New(t)
+	selector := NewRolloutSelector(RolloutConfig{})
+	ctx, _ := newRolloutContext(http.MethodGet, "http://gateway/users", "", nil)
+	assert.False(selector.Active(ctx, "default"))
+	assert.NoError(selector.SetRule(RolloutRule{
+		Method: "get", Pattern: "/users", Segment: "internal", Weights: map[string]int{"v2": 100},
+	}))
+	assert.NoError(selector.SetRule(RolloutRule{
+		Method: http.MethodGet, Pattern: "/users", Weights: map[string]int{"v1": 100},
+	}))
+	assert.True(selector.Active(ctx, "default"))
+	assert.Equal("v2", rolloutSelect(selector, "alice", "internal", nil))
+	// 未匹配分组时使用 * 规则
+	assert.Equal("v1", rolloutSelect(selector, "bob", "partner", nil))
+	assert.Equal("v1", rolloutSelect(selector, "", "", map[string]string{"X-Version": "v2"}))
+	// 无灰度规则时，按客户端指定的版本选择
+	selector.RemoveRule(http.MethodGet, "/users", RolloutSegmentAny)
+	assert.Equal("v2", rolloutSelect(selector, "bob", "partner", map[string]string{"X-Version": "v2"}))
+	assert.Equal("", rolloutSelect(selector, "bob", "partner", map[string]string{"X-Version": "v3"}))
+	// 规则指定的版本不存在时，按客户端指定的版本选择
+	assert.NoError(selector.SetRule(RolloutRule{
+		Method: http.MethodGet, Pattern: "/users", Segment: "internal", Weights: map[string]int{"v9": 100},
+	}))
+	assert.Equal("v1", rolloutSelect(selector, "alice", "internal", map[string]string{"X-Version": "v1"}))
+}
+
+func TestRolloutSelector_SetRule(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	assert := assert.New(t)
+	selector := NewRolloutSelector(RolloutConfig{})
+	assert.Error(selector.SetRule(RolloutRule{Pattern: "/users", Weights: map[string]int{"v1": 1}}))
+	assert.Error(selector.SetRule(RolloutRule{Method: http.MethodGet, Pattern: "/users", Weights: map[string]int{"v1": -1}}))
+	assert.Empty(selector.Rules())
+	assert.NoError(selector.SetRule(RolloutRule{Method: http.MethodGet, Pattern: "/users", Weights: map[string]int{"v1": 1}}))
+	rules := selector.Rules()
+	if assert.Len(rules, 1) {
+		assert.Equal(RolloutSegmentAny, rules[0].Segment)
+	}
+	// 权重全部为0时删除规则
+	assert.NoError(selector.SetRule(RolloutRule{Method: http.MethodGet, Pattern: "/users", Weights: map[string]int{"v1": 0}}))
+	assert.Empty(selector.Rules())
+}
+
+func TestRolloutSelector_Admin(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	assert := assert.New(t)
+	selector := NewRolloutSelector(RolloutConfig{})
+	admin := &handlerListener{handlers: make(map[string]flux.WebHandler)}
+	selector.RegisterWebHandlers("default", admin)
+	assert.Empty(admin.handlers)
+	selector.RegisterWebHandlers("admin", admin)
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete} {
+		assert.Contains(admin.handlers, method+" /debug/rollout")
+	}
+	invoke := func(method, target, body string) (int, []RolloutRule) {
+		ctx, recorder := newRolloutContext(method, "http://gateway/debug/rollout"+target, body, nil)
+		assert.NoError(admin.handlers[method+" /debug/rollout"](ctx))
+		var rules []RolloutRule
+		if recorder.Code == http.StatusOK {
+			assert.NoError(json.Unmarshal(recorder.Body.Bytes(), &rules))
+		}
+		return recorder.Code, rules
+	}
+	// 调整权重
+	status, rules := invoke(http.MethodPost, "", `{"method":"GET","pattern":"/users","weights":{"v1":95,"v2":5}}`)
+	assert.Equal(http.StatusOK, status)
+	if assert.Len(rules, 1) {
+		assert.Equal(map[string]int{"v1": 95, "v2": 5}, rules[0].Weights)
+	}
+	// 全量发布
+	_, rules = invoke(http.MethodPut, "", `{"method":"GET","pattern":"/users","weights":{"v2":100}}`)
+	if assert.Len(rules, 1) {
+		assert.Equal(map[string]int{"v2": 100}, rules[0].Weights)
+	}
+	assert.Equal("v2", rolloutSelect(selector, "bob", "", nil))
+	status, _ = invoke(http.MethodPost, "", `{"method":"GET","weights":{"v2":100}}`)
+	assert.Equal(http.StatusBadRequest, status)
+	status, _ = invoke(http.MethodPost, "", `not-json`)
+	assert.Equal(http.StatusBadRequest, status)
+	// 回滚：删除规则
+	_, rules = invoke(http.MethodDelete, "?method=GET&pattern=/users", "")
+	assert.Empty(rules)
+	assert.Equal("v1", rolloutSelect(selector, "bob", "", map[string]string{"X-Version": "v1"}))
+	_, rules = invoke(http.MethodGet, "", "")
+	assert.Empty(rules)
+}
@@ -0,0 +1,286 @@
+package fluxext
+
+import (
+	"container/list"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdSchedulerFilter = "scheduler_filter"
+)
+
+const (
+	ConfigKeySchedulerMaxConcurrent  = "max_concurrent"
+	ConfigKeySchedulerPriorityHeader = "priority_header"
+	ConfigKeySchedulerWeightHigh     = "weight_high"
+	ConfigKeySchedulerWeightNormal   = "weight_normal"
+	ConfigKeySchedulerWeightLow      = "weight_low"
+	ConfigKeySchedulerQueueHigh      = "queue_high"
+	ConfigKeySchedulerQueueNormal    = "queue_normal"
+	ConfigKeySchedulerQueueLow       = "queue_low"
+	ConfigKeySchedulerMaxWaitHigh    = "max_wait_high"
+	ConfigKeySchedulerMaxWaitNormal  = "max_wait_normal"
+	ConfigKeySchedulerMaxWaitLow     = "max_wait_low"
+)
+
+const (
+	ErrorCodeGatewaySaturated = "GATEWAY:SATURATED"
+)
+
+type (
+	// SchedulerPriorityFunc 返回请求的优先级
+	SchedulerPriorityFunc func(ctx *flux.Context) Priority
+)
+
+// SchedulerConfig 优先级调度配置
+type SchedulerConfig struct {
+	SkipFunc     flux.FilterSkipper
+	PriorityFunc SchedulerPriorityFunc
+}
+
+// SchedulerStats 各优先级的排队统计
+type SchedulerStats struct {
+	Running int              `json:"running"`
+	Queued  map[string]int   `json:"queued"`
+	Served  map[string]int64 `json:"served"`
+	Dropped map[string]int64 `json:"dropped"`
+}
+
+func NewSchedulerFilter(c SchedulerConfig) *SchedulerFilter {
+	return &SchedulerFilter{
+		Configs: c,
+	}
+}
+
+// SchedulerFilter 按优先级加权调度请求：同时处理的请求数量达到 max_concurrent 后，新请求按优先级进入各自的等待队列；
+// 每当有请求处理完成，按平滑加权轮询（weight_high/normal/low）从非空队列中选择下一个请求，使健康检查及高优先级API
+// 先于批量导出等低优先级请求获得处理，同时低优先级请求不会被完全饿死。
+// 队列已满或等待超过其优先级的最大等待时间时，返回503错误。请求优先级由Endpoint属性 priority 定义，可被 priority_header 指定的请求Header覆盖。
+type SchedulerFilter struct {
+	Configs        SchedulerConfig
+	Disabled       bool
+	maxConcurrent  int
+	priorityHeader string
+	weights        [3]int
+	queueSizes     [3]int
+	maxWaits       [3]time.Duration
+	running        int
+	queues         [3]*list.List
+	currents       [3]int
+	served         [3]int64
+	dropped        [3]int64
+	mu             sync.Mutex
+}
+
+type schedulerWaiter struct {
+	ready   chan struct{}
+	granted bool
+}
+
+func (f *SchedulerFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeySchedulerMaxConcurrent:  0,
+		ConfigKeySchedulerPriorityHeader: "",
+		ConfigKeySchedulerWeightHigh:     8,
+		ConfigKeySchedulerWeightNormal:   4,
+		ConfigKeySchedulerWeightLow:      1,
+		ConfigKeySchedulerQueueHigh:      1024,
+		ConfigKeySchedulerQueueNormal:    512,
+		ConfigKeySchedulerQueueLow:       128,
+		ConfigKeySchedulerMaxWaitHigh:    time.Second * 2,
+		ConfigKeySchedulerMaxWaitNormal:  time.Second,
+		ConfigKeySchedulerMaxWaitLow:     time.Millisecond * 500,
+	})
+	f.maxConcurrent = config.GetInt(ConfigKeySchedulerMaxConcurrent)
+	if f.maxConcurrent <= 0 {
+		f.Disabled = true
+		logger.Info("Scheduler filter was DISABLED, max_concurrent is not set")
+		return nil
+	}
+	f.priorityHeader = config.GetString(ConfigKeySchedulerPriorityHeader)
+	f.weights[PriorityLow] = config.GetInt(ConfigKeySchedulerWeightLow)
+	f.weights[PriorityNormal] = config.GetInt(ConfigKeySchedulerWeightNormal)
+	f.weights[PriorityHigh] = config.GetInt(ConfigKeySchedulerWeightHigh)
+	f.queueSizes[PriorityLow] = config.GetInt(ConfigKeySchedulerQueueLow)
+	f.queueSizes[PriorityNormal] = config.GetInt(ConfigKeySchedulerQueueNormal)
+	f.queueSizes[PriorityHigh] = config.GetInt(ConfigKeySchedulerQueueHigh)
+	f.maxWaits[PriorityLow] = config.GetDuration(ConfigKeySchedulerMaxWaitLow)
+	f.maxWaits[PriorityNormal] = config.GetDuration(ConfigKeySchedulerMaxWaitNormal)
+	f.maxWaits[PriorityHigh] = config.GetDuration(ConfigKeySchedulerMaxWaitHigh)
+	for i := range f.queues {
+		f.queues[i] = list.New()
+		if f.weights[i] < 1 {
+			f.weights[i] = 1
+		}
+	}
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if fluxpkg.IsNil(f.Configs.PriorityFunc) {
+		f.Configs.PriorityFunc = func(ctx *flux.Context) Priority {
+			return RequestPriority(ctx, f.priorityHeader)
+		}
+	}
+	logger.Infow("Scheduler filter initializing", "max-concurrent", f.maxConcurrent, "priority-header", f.priorityHeader,
+		"weights", f.weights, "queue-sizes", f.queueSizes, "max-waits", f.maxWaits)
+	return nil
+}
+
+func (*SchedulerFilter) FilterId() string {
+	return TypeIdSchedulerFilter
+}
+
+func (f *SchedulerFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	if f.Disabled {
+		return next
+	}
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		priority := f.Configs.PriorityFunc(ctx)
+		if priority < PriorityLow || priority > PriorityHigh {
+			priority = PriorityNormal
+		}
+		start := time.Now()
+		if serr := f.acquire(ctx, priority); nil != serr {
+			return serr
+		}
+		defer f.release()
+		// 记录请求在队列中的等待时间
+		ctx.AddMetric(f.FilterId(), time.Since(start))
+		return next(ctx)
+	}
+}
+
+// Stats 返回各优先级的排队统计
+func (f *SchedulerFilter) Stats() SchedulerStats {
+	stats := SchedulerStats{
+		Queued:  make(map[string]int, 3),
+		Served:  make(map[string]int64, 3),
+		Dropped: make(map[string]int64, 3),
+	}
+	if f.Disabled {
+		return stats
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats.Running = f.running
+	for p := PriorityLow; p <= PriorityHigh; p++ {
+		stats.Queued[p.String()] = f.queues[p].Len()
+		stats.Served[p.String()] = f.served[p]
+		stats.Dropped[p.String()] = f.dropped[p]
+	}
+	return stats
+}
+
+// StatsHandler 查询排队统计的处理接口，需要注册到管理WebListener，例如：GET /debug/scheduler
+func (f *SchedulerFilter) StatsHandler(webex flux.ServerWebContext) error {
+	bytes, err := common.SerializeObject(f.Stats())
+	if nil != err {
+		return err
+	}
+	return webex.Write(flux.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, bytes)
+}
+
+// acquire 占用处理槽位；槽位已满时进入优先级队列等待调度
+func (f *SchedulerFilter) acquire(ctx *flux.Context, priority Priority) *flux.ServeError {
+	f.mu.Lock()
+	if f.running < f.maxConcurrent && f.queued() == 0 {
+		f.running++
+		f.served[priority]++
+		f.mu.Unlock()
+		return nil
+	}
+	queue := f.queues[priority]
+	if queue.Len() >= f.queueSizes[priority] {
+		f.dropped[priority]++
+		f.mu.Unlock()
+		logger.TraceContext(ctx).Infow("SCHEDULER:QUEUE_FULL", "priority", priority, "queue-size", f.queueSizes[priority])
+		return f.saturated("SCHEDULER:QUEUE_FULL", f.maxWaits[priority])
+	}
+	waiter := &schedulerWaiter{ready: make(chan struct{})}
+	elem := queue.PushBack(waiter)
+	f.mu.Unlock()
+	timer := time.NewTimer(f.maxWaits[priority])
+	defer timer.Stop()
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-timer.C:
+		if f.cancel(priority, elem, waiter) {
+			logger.TraceContext(ctx).Infow("SCHEDULER:WAIT_TIMEOUT", "priority", priority, "max-wait", f.maxWaits[priority])
+			return f.saturated("SCHEDULER:WAIT_TIMEOUT", f.maxWaits[priority])
+		}
+		return nil
+	case <-ctx.Context().Done():
+		if !f.cancel(priority, elem, waiter) {
+			f.release()
+		}
+		return &flux.ServeError{
+			StatusCode: flux.StatusOK,
+			ErrorCode:  flux.ErrorCodeGatewayCanceled,
+			Message:    "SCHEDULER:CANCELED:BYCLIENT",
+			CauseError: ctx.Context().Err(),
+		}
+	}
+}
+
+// cancel 将等待的请求移出队列；请求已被调度时返回false，此时请求已占用处理槽位
+func (f *SchedulerFilter) cancel(priority Priority, elem *list.Element, waiter *schedulerWaiter) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if waiter.granted {
+		return false
+	}
+	f.queues[priority].Remove(elem)
+	f.dropped[priority]++
+	return true
+}
+
+// release 释放处理槽位；按平滑加权轮询选择下一个等待的请求，并将槽位直接转交给它
+func (f *SchedulerFilter) release() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	total, selected := 0, -1
+	for i, queue := range f.queues {
+		if queue.Len() == 0 {
+			continue
+		}
+		f.currents[i] += f.weights[i]
+		total += f.weights[i]
+		if selected < 0 || f.currents[i] > f.currents[selected] {
+			selected = i
+		}
+	}
+	if selected < 0 {
+		f.running--
+		return
+	}
+	f.currents[selected] -= total
+	waiter := f.queues[selected].Remove(f.queues[selected].Front()).(*schedulerWaiter)
+	waiter.granted = true
+	f.served[selected]++
+	close(waiter.ready)
+}
+
+func (f *SchedulerFilter) queued() int {
+	return f.queues[PriorityLow].Len() + f.queues[PriorityNormal].Len() + f.queues[PriorityHigh].Len()
+}
+
+func (f *SchedulerFilter) saturated(message string, wait time.Duration) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: http.StatusServiceUnavailable,
+		ErrorCode:  ErrorCodeGatewaySaturated,
+		Message:    message,
+		Header:     map[string][]string{flux.HeaderRetryAfter: {retryAfterSeconds(wait)}},
+	}
+}
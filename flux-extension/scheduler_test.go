@@ -0,0 +1,132 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newSchedulerFilter(t *testing.T, maxWait string) *SchedulerFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	filter := NewSchedulerFilter(SchedulerConfig{})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeySchedulerMaxConcurrent: 1,
+		ConfigKeySchedulerMaxWaitHigh:   maxWait,
+		ConfigKeySchedulerMaxWaitNormal: maxWait,
+		ConfigKeySchedulerMaxWaitLow:    maxWait,
+	})))
+	return filter
+}
+
+func newSchedulerContext() *flux.Context {
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("scheduler", httptest.NewRequest(http.MethodGet, "http://gateway/orders", nil), nil, nil),
+		&flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/orders"})
+	return ctx
+}
+
+func assertSchedulerIdle(t *testing.T, filter *SchedulerFilter) {
+	filter.mu.Lock()
+	defer filter.mu.Unlock()
+	assert.Equal(t, 0, filter.running, "scheduler slot leaked")
+	assert.Equal(t, 0, filter.queued(), "scheduler waiter leaked")
+}
+
+func TestSchedulerFilter_WaitMetric(t *testing.T) {
+	assert := assert.New(t)
+	filter := newSchedulerFilter(t, "1s")
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		return nil
+	})
+	// 指标只记录排队等待的时间，不包含请求此前的处理时间
+	ctx := newSchedulerContext()
+	time.Sleep(time.Millisecond * 50)
+	assert.Nil(invoker(ctx))
+	metrics := ctx.Metrics()
+	if assert.Equal(1, len(metrics)) {
+		assert.Equal(TypeIdSchedulerFilter, metrics[0].Name)
+		assert.True(metrics[0].Elapsed < time.Millisecond*50, "metric must be the queue wait")
+	}
+
+	// 槽位被占用时，等待时间为槽位释放前的排队时间
+	assert.Nil(filter.acquire(newSchedulerContext(), PriorityNormal))
+	ctx = newSchedulerContext()
+	done := make(chan *flux.ServeError, 1)
+	go func() {
+		done <- invoker(ctx)
+	}()
+	time.Sleep(time.Millisecond * 30)
+	filter.release()
+	assert.Nil(<-done)
+	metrics = ctx.Metrics()
+	if assert.Equal(1, len(metrics)) {
+		assert.True(metrics[0].Elapsed >= time.Millisecond*30, "metric must be the queue wait")
+	}
+	assertSchedulerIdle(t, filter)
+}
+
+func TestSchedulerFilter_CancelGranted(t *testing.T) {
+	assert := assert.New(t)
+	filter := newSchedulerFilter(t, "1s")
+	assert.Nil(filter.acquire(newSchedulerContext(), PriorityNormal))
+	filter.mu.Lock()
+	waiter := &schedulerWaiter{ready: make(chan struct{})}
+	elem := filter.queues[PriorityNormal].PushBack(waiter)
+	filter.mu.Unlock()
+	// 槽位已转交给等待的请求，取消等待时返回false，由请求负责释放槽位
+	filter.release()
+	assert.True(waiter.granted)
+	assert.False(filter.cancel(PriorityNormal, elem, waiter))
+	assert.Equal(int64(0), filter.dropped[PriorityNormal])
+	assert.Equal(1, filter.running)
+	filter.release()
+	assertSchedulerIdle(t, filter)
+}
+
+func TestSchedulerFilter_GrantCancelRace(t *testing.T) {
+	// 等待超时与槽位转交同时发生
+	filter := newSchedulerFilter(t, "1ms")
+	for i := 0; i < 200; i++ {
+		assert.Nil(t, filter.acquire(newSchedulerContext(), PriorityHigh))
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if nil == filter.acquire(newSchedulerContext(), PriorityLow) {
+				filter.release()
+			}
+		}()
+		time.Sleep(time.Millisecond)
+		filter.release()
+		wg.Wait()
+	}
+	assertSchedulerIdle(t, filter)
+
+	// 客户端取消与槽位转交同时发生
+	filter = newSchedulerFilter(t, "1s")
+	for i := 0; i < 200; i++ {
+		assert.Nil(t, filter.acquire(newSchedulerContext(), PriorityHigh))
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx := newSchedulerContext()
+			cancel := ctx.WithTimeout(time.Millisecond)
+			defer cancel()
+			if nil == filter.acquire(ctx, PriorityLow) {
+				filter.release()
+			}
+		}()
+		time.Sleep(time.Millisecond)
+		filter.release()
+		wg.Wait()
+	}
+	assertSchedulerIdle(t, filter)
+}
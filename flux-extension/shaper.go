@@ -0,0 +1,160 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdShaperFilter = "shaper_filter"
+)
+
+const (
+	ConfigKeyShaperRate          = "rate"
+	ConfigKeyShaperMaxWaitHigh   = "max_wait_high"
+	ConfigKeyShaperMaxWaitNormal = "max_wait_normal"
+	ConfigKeyShaperMaxWaitLow    = "max_wait_low"
+)
+
+type (
+	// ShaperPriorityFunc 返回请求的优先级
+	ShaperPriorityFunc func(ctx *flux.Context) Priority
+)
+
+// ShaperConfig 全局入口流量整形配置
+type ShaperConfig struct {
+	SkipFunc     flux.FilterSkipper
+	PriorityFunc ShaperPriorityFunc
+}
+
+func NewShaperFilter(c ShaperConfig) *ShaperFilter {
+	return &ShaperFilter{
+		Configs: c,
+	}
+}
+
+// ShaperFilter 基于漏桶算法的全局入口流量整形：请求按固定速率放行，突发请求排队等待；
+// 排队等待时间超过其优先级允许的最大等待时间时，拒绝请求。低优先级请求的可等待时间更短，在突发流量下优先被拒绝。
+type ShaperFilter struct {
+	Configs  ShaperConfig
+	Disabled bool
+	interval time.Duration
+	maxWaits [3]time.Duration
+	next     time.Time
+	mu       sync.Mutex
+}
+
+func (r *ShaperFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyShaperRate:          0,
+		ConfigKeyShaperMaxWaitHigh:   time.Millisecond * 500,
+		ConfigKeyShaperMaxWaitNormal: time.Millisecond * 200,
+		ConfigKeyShaperMaxWaitLow:    time.Millisecond * 50,
+	})
+	rate := config.GetInt64(ConfigKeyShaperRate)
+	if rate <= 0 {
+		r.Disabled = true
+		logger.Info("Shaper filter was DISABLED, rate is not set")
+		return nil
+	}
+	r.interval = time.Second / time.Duration(rate)
+	r.maxWaits[PriorityLow] = config.GetDuration(ConfigKeyShaperMaxWaitLow)
+	r.maxWaits[PriorityNormal] = config.GetDuration(ConfigKeyShaperMaxWaitNormal)
+	r.maxWaits[PriorityHigh] = config.GetDuration(ConfigKeyShaperMaxWaitHigh)
+	if fluxpkg.IsNil(r.Configs.SkipFunc) {
+		r.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if fluxpkg.IsNil(r.Configs.PriorityFunc) {
+		r.Configs.PriorityFunc = func(ctx *flux.Context) Priority {
+			return EndpointPriority(ctx.Endpoint())
+		}
+	}
+	logger.Infow("Shaper filter initializing", "rate", rate, "interval", r.interval,
+		"max-wait-high", r.maxWaits[PriorityHigh],
+		"max-wait-normal", r.maxWaits[PriorityNormal],
+		"max-wait-low", r.maxWaits[PriorityLow])
+	return nil
+}
+
+func (*ShaperFilter) FilterId() string {
+	return TypeIdShaperFilter
+}
+
+func (r *ShaperFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	if r.Disabled {
+		return next
+	}
+	return func(ctx *flux.Context) *flux.ServeError {
+		if r.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		priority := r.Configs.PriorityFunc(ctx)
+		wait, ok := r.reserve(time.Now(), r.maxWaitOf(priority))
+		if !ok {
+			logger.TraceContext(ctx).Infow("SHAPER:REJECTED", "priority", priority, "wait", wait)
+			return &flux.ServeError{
+				StatusCode: flux.StatusTooMany,
+				ErrorCode:  flux.ErrorCodeGatewayRateLimited,
+				Message:    "SHAPER:RATE_LIMITED",
+				Header:     map[string][]string{flux.HeaderRetryAfter: {retryAfterSeconds(wait)}},
+			}
+		}
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Context().Done():
+				timer.Stop()
+				return &flux.ServeError{
+					StatusCode: flux.StatusOK,
+					ErrorCode:  flux.ErrorCodeGatewayCanceled,
+					Message:    "SHAPER:CANCELED:BYCLIENT",
+					CauseError: ctx.Context().Err(),
+				}
+			}
+		}
+		// 记录请求等待放行的时间
+		ctx.AddMetric(r.FilterId(), wait)
+		return next(ctx)
+	}
+}
+
+// reserve 预约漏桶的放行时间槽，返回需要等待的时间；等待时间超过maxWait时，不占用时间槽并返回false
+func (r *ShaperFilter) reserve(now time.Time, maxWait time.Duration) (time.Duration, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	slot := r.next
+	if slot.Before(now) {
+		slot = now
+	}
+	wait := slot.Sub(now)
+	if wait > maxWait {
+		return wait, false
+	}
+	r.next = slot.Add(r.interval)
+	return wait, true
+}
+
+func (r *ShaperFilter) maxWaitOf(p Priority) time.Duration {
+	if p < PriorityLow || p > PriorityHigh {
+		return r.maxWaits[PriorityNormal]
+	}
+	return r.maxWaits[p]
+}
+
+func retryAfterSeconds(wait time.Duration) string {
+	secs := int64(wait / time.Second)
+	if wait%time.Second > 0 {
+		secs++
+	}
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
@@ -0,0 +1,38 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestShaperFilter_WaitMetric(t *testing.T) {
+	assert := assert.New(t)
+	filter := NewShaperFilter(ShaperConfig{})
+	assert.NoError(filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyShaperRate:          10,
+		ConfigKeyShaperMaxWaitNormal: "1s",
+	})))
+	invoker := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		return nil
+	})
+	// 指标只记录等待放行的时间，不包含请求此前的处理时间
+	first := newSchedulerContext()
+	time.Sleep(time.Millisecond * 50)
+	assert.Nil(invoker(first))
+	second := newSchedulerContext()
+	assert.Nil(invoker(second))
+	waits := make([]time.Duration, 0, 2)
+	for _, ctx := range []*flux.Context{first, second} {
+		metrics := ctx.Metrics()
+		if assert.Equal(1, len(metrics)) {
+			assert.Equal(TypeIdShaperFilter, metrics[0].Name)
+			waits = append(waits, metrics[0].Elapsed)
+		}
+	}
+	if assert.Equal(2, len(waits)) {
+		assert.Equal(time.Duration(0), waits[0])
+		assert.True(waits[1] > time.Millisecond*50 && waits[1] <= time.Millisecond*100, "metric must be the shaping wait")
+	}
+}
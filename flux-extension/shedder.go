@@ -0,0 +1,264 @@
+package fluxext
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"math"
+	"math/rand"
+	"net/http"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	TypeIdShedderFilter = "shedder_filter"
+)
+
+const (
+	ConfigKeyShedderSampleInterval     = "sample_interval"
+	ConfigKeyShedderCPUThreshold       = "cpu_threshold"
+	ConfigKeyShedderGoroutineThreshold = "goroutine_threshold"
+	ConfigKeyShedderLagThreshold       = "lag_threshold"
+	ConfigKeyShedderDropStep           = "drop_step"
+	ConfigKeyShedderMaxDrop            = "max_drop"
+	ConfigKeyShedderPriorityHeader     = "priority_header"
+	ConfigKeyShedderRetryAfter         = "retry_after"
+)
+
+const (
+	ErrorCodeGatewayOverloaded = "GATEWAY:OVERLOADED"
+)
+
+const (
+	// 调度延迟EWMA的平滑系数
+	shedderLagAlpha = 0.3
+	// CPU使用率EWMA的平滑系数
+	shedderCPUAlpha = 0.5
+)
+
+type (
+	// ShedderPriorityFunc 返回请求的优先级
+	ShedderPriorityFunc func(ctx *flux.Context) Priority
+)
+
+// ShedderConfig 自适应过载保护配置
+type ShedderConfig struct {
+	SkipFunc     flux.FilterSkipper
+	PriorityFunc ShedderPriorityFunc
+}
+
+// ShedderStatus 过载保护的状态
+type ShedderStatus struct {
+	Overloaded bool    `json:"overloaded"`
+	DropRate   float64 `json:"dropRate"`
+	CPU        float64 `json:"cpu"`
+	Goroutines int     `json:"goroutines"`
+	Inflight   int64   `json:"inflight"`
+	LagMs      float64 `json:"lagMs"`
+	Dropped    int64   `json:"dropped"`
+}
+
+func NewShedderFilter(c ShedderConfig) *ShedderFilter {
+	return &ShedderFilter{
+		Configs: c,
+	}
+}
+
+// ShedderFilter 自适应过载保护：周期采样进程CPU使用率，Goroutine数量，以及Go调度延迟（采样定时器的触发延迟），
+// 任一指标超过阈值时判定网关自身过载，逐步提高丢弃比例，按比例丢弃低优先级请求（normal优先级按一半比例丢弃，high不丢弃）；
+// 过载解除后丢弃比例逐步回落。各指标只反映网关进程自身的负载，上游服务变慢不会触发丢弃。
+// 请求优先级由Endpoint属性 priority 定义，可被 priority_header 指定的请求Header覆盖。
+type ShedderFilter struct {
+	Configs            ShedderConfig
+	interval           time.Duration
+	cpuThreshold       float64
+	goroutineThreshold int
+	lagThreshold       time.Duration
+	dropStep           float64
+	maxDrop            float64
+	priorityHeader     string
+	retryAfter         time.Duration
+	inflight           int64
+	dropped            int64
+	// 丢弃比例，math.Float64bits
+	dropRate uint64
+	status   ShedderStatus
+	mu       sync.RWMutex
+	cancel   context.CancelFunc
+}
+
+func (f *ShedderFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyShedderSampleInterval:     time.Millisecond * 250,
+		ConfigKeyShedderCPUThreshold:       0.8,
+		ConfigKeyShedderGoroutineThreshold: 0,
+		ConfigKeyShedderLagThreshold:       time.Millisecond * 50,
+		ConfigKeyShedderDropStep:           0.05,
+		ConfigKeyShedderMaxDrop:            0.9,
+		ConfigKeyShedderPriorityHeader:     "",
+		ConfigKeyShedderRetryAfter:         time.Second,
+	})
+	f.interval = config.GetDuration(ConfigKeyShedderSampleInterval)
+	f.cpuThreshold = config.GetFloat64(ConfigKeyShedderCPUThreshold)
+	f.goroutineThreshold = config.GetInt(ConfigKeyShedderGoroutineThreshold)
+	f.lagThreshold = config.GetDuration(ConfigKeyShedderLagThreshold)
+	f.dropStep = config.GetFloat64(ConfigKeyShedderDropStep)
+	f.maxDrop = math.Min(math.Max(config.GetFloat64(ConfigKeyShedderMaxDrop), 0), 1)
+	f.priorityHeader = config.GetString(ConfigKeyShedderPriorityHeader)
+	f.retryAfter = config.GetDuration(ConfigKeyShedderRetryAfter)
+	if f.interval <= 0 {
+		f.interval = time.Millisecond * 250
+	}
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if fluxpkg.IsNil(f.Configs.PriorityFunc) {
+		f.Configs.PriorityFunc = func(ctx *flux.Context) Priority {
+			return RequestPriority(ctx, f.priorityHeader)
+		}
+	}
+	logger.Infow("Shedder filter initializing", "sample-interval", f.interval, "cpu-threshold", f.cpuThreshold,
+		"goroutine-threshold", f.goroutineThreshold, "lag-threshold", f.lagThreshold,
+		"drop-step", f.dropStep, "max-drop", f.maxDrop, "priority-header", f.priorityHeader)
+	return nil
+}
+
+func (f *ShedderFilter) Startup() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+	go f.sampling(ctx)
+	return nil
+}
+
+func (f *ShedderFilter) Shutdown(_ context.Context) error {
+	if nil != f.cancel {
+		f.cancel()
+	}
+	return nil
+}
+
+func (*ShedderFilter) FilterId() string {
+	return TypeIdShedderFilter
+}
+
+func (f *ShedderFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		if rate := f.DropRate(); rate > 0 {
+			priority := f.Configs.PriorityFunc(ctx)
+			if f.shouldDrop(priority, rate) {
+				atomic.AddInt64(&f.dropped, 1)
+				logger.TraceContext(ctx).Infow("SHEDDER:DROPPED", "priority", priority, "drop-rate", rate)
+				return &flux.ServeError{
+					StatusCode: http.StatusServiceUnavailable,
+					ErrorCode:  ErrorCodeGatewayOverloaded,
+					Message:    "SHEDDER:OVERLOADED",
+					Header:     map[string][]string{flux.HeaderRetryAfter: {retryAfterSeconds(f.retryAfter)}},
+				}
+			}
+		}
+		atomic.AddInt64(&f.inflight, 1)
+		defer atomic.AddInt64(&f.inflight, -1)
+		return next(ctx)
+	}
+}
+
+// DropRate 返回当前低优先级请求的丢弃比例
+func (f *ShedderFilter) DropRate() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.dropRate))
+}
+
+// Status 返回最近一次采样的过载保护状态
+func (f *ShedderFilter) Status() ShedderStatus {
+	f.mu.RLock()
+	status := f.status
+	f.mu.RUnlock()
+	status.Inflight = atomic.LoadInt64(&f.inflight)
+	status.Dropped = atomic.LoadInt64(&f.dropped)
+	return status
+}
+
+// StatusHandler 查询过载保护状态的处理接口，需要注册到管理WebListener，例如：GET /debug/shedder
+func (f *ShedderFilter) StatusHandler(webex flux.ServerWebContext) error {
+	bytes, err := common.SerializeObject(f.Status())
+	if nil != err {
+		return err
+	}
+	return webex.Write(flux.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, bytes)
+}
+
+func (f *ShedderFilter) shouldDrop(priority Priority, rate float64) bool {
+	switch priority {
+	case PriorityHigh:
+		return false
+	case PriorityNormal:
+		rate = rate / 2
+	}
+	return rand.Float64() < rate
+}
+
+func (f *ShedderFilter) sampling(ctx context.Context) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+	lastCPU, cpuOk := processCPUTime()
+	lastAt := time.Now()
+	var cpu, lag float64
+	for {
+		var tick time.Time
+		select {
+		case tick = <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+		now := time.Now()
+		// 调度延迟：定时器触发到采样协程被调度执行的时间；网关CPU饱和或Goroutine积压时增大
+		lag = lag*(1-shedderLagAlpha) + float64(now.Sub(tick))*shedderLagAlpha
+		// CPU使用率：进程CPU时间增量 / (墙上时间增量 * CPU核数)
+		if used, ok := processCPUTime(); ok && cpuOk {
+			elapsed := now.Sub(lastAt)
+			if elapsed > 0 {
+				usage := float64(used-lastCPU) / (float64(elapsed) * float64(runtime.NumCPU()))
+				cpu = cpu*(1-shedderCPUAlpha) + usage*shedderCPUAlpha
+			}
+			lastCPU = used
+		}
+		lastAt = now
+		f.update(cpu, runtime.NumGoroutine(), time.Duration(lag))
+	}
+}
+
+// update 按采样指标判定网关是否过载，并调整丢弃比例：过载时加性增长，过载解除后按倍数回落
+func (f *ShedderFilter) update(cpu float64, goroutines int, lag time.Duration) {
+	overloaded := (f.cpuThreshold > 0 && cpu >= f.cpuThreshold) ||
+		(f.goroutineThreshold > 0 && goroutines >= f.goroutineThreshold) ||
+		(f.lagThreshold > 0 && lag >= f.lagThreshold)
+	rate := f.DropRate()
+	if overloaded {
+		rate = math.Min(rate+f.dropStep, f.maxDrop)
+	} else if rate = rate / 2; rate < 0.01 {
+		rate = 0
+	}
+	if prev := f.DropRate(); (prev == 0) != (rate == 0) {
+		logger.Infow("SHEDDER:STATE:CHANGED", "overloaded", overloaded, "drop-rate", rate, "cpu", cpu,
+			"goroutines", goroutines, "lag", lag)
+	}
+	atomic.StoreUint64(&f.dropRate, math.Float64bits(rate))
+	f.mu.Lock()
+	f.status = ShedderStatus{
+		Overloaded: overloaded,
+		DropRate:   rate,
+		CPU:        cpu,
+		Goroutines: goroutines,
+		LagMs:      float64(lag) / float64(time.Millisecond),
+	}
+	f.mu.Unlock()
+}
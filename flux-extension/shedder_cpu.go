@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package fluxext
+
+import (
+	"syscall"
+	"time"
+)
+
+// processCPUTime 返回进程累计使用的CPU时间（用户态及内核态）
+func processCPUTime() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); nil != err {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
@@ -0,0 +1,10 @@
+package fluxext
+
+import (
+	"time"
+)
+
+// processCPUTime Windows平台不采集进程CPU时间，CPU过载信号不生效
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
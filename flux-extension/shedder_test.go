@@ -0,0 +1,97 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newShedderFilter(t *testing.T, priority Priority) *ShedderFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	filter := NewShedderFilter(ShedderConfig{PriorityFunc: func(_ *flux.Context) Priority {
+		return priority
+	}})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyShedderCPUThreshold:   0,
+		ConfigKeyShedderLagThreshold:   "50ms",
+		ConfigKeyShedderDropStep:       0.25,
+		ConfigKeyShedderMaxDrop:        1.0,
+		ConfigKeyShedderRetryAfter:     "2s",
+		ConfigKeyShedderSampleInterval: "1h",
+	})))
+	return filter
+}
+
+func newShedderContext() *flux.Context {
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("shedder", httptest.NewRequest(http.MethodGet, "http://gateway/orders", nil), nil, nil),
+		&flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/orders"})
+	return ctx
+}
+
+func TestShedderFilter_Update(t *testing.T) {
+	assert := assert.New(t)
+	filter := newShedderFilter(t, PriorityLow)
+	// 上游服务变慢不影响网关自身的过载判定
+	slow := filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		time.Sleep(time.Millisecond * 20)
+		return nil
+	})
+	assert.Nil(slow(newShedderContext()))
+	filter.update(0, 10, time.Millisecond)
+	assert.Equal(0.0, filter.DropRate())
+	assert.False(filter.Status().Overloaded)
+
+	// 调度延迟超过阈值：丢弃比例加性增长，不超过最大比例
+	for i := 1; i <= 5; i++ {
+		filter.update(0, 10, time.Millisecond*80)
+	}
+	assert.Equal(1.0, filter.DropRate())
+	status := filter.Status()
+	assert.True(status.Overloaded)
+	assert.Equal(80.0, status.LagMs)
+	// 过载解除后按倍数回落
+	filter.update(0, 10, time.Millisecond)
+	assert.Equal(0.5, filter.DropRate())
+	for i := 0; i < 8; i++ {
+		filter.update(0, 10, time.Millisecond)
+	}
+	assert.Equal(0.0, filter.DropRate())
+
+	// Goroutine数量超过阈值
+	filter.goroutineThreshold = 100
+	filter.update(0, 200, 0)
+	assert.Equal(0.25, filter.DropRate())
+}
+
+func TestShedderFilter_Drop(t *testing.T) {
+	assert := assert.New(t)
+	next := func(ctx *flux.Context) *flux.ServeError {
+		return nil
+	}
+	low := newShedderFilter(t, PriorityLow)
+	for i := 0; i < 4; i++ {
+		low.update(0, 0, time.Second)
+	}
+	serr := low.DoFilter(next)(newShedderContext())
+	if assert.NotNil(serr) {
+		assert.Equal(http.StatusServiceUnavailable, serr.StatusCode)
+		assert.Equal(ErrorCodeGatewayOverloaded, serr.ErrorCode)
+		assert.Equal("2", serr.Header.Get(flux.HeaderRetryAfter))
+	}
+	assert.Equal(int64(1), low.Status().Dropped)
+
+	// 高优先级请求不丢弃
+	high := newShedderFilter(t, PriorityHigh)
+	for i := 0; i < 4; i++ {
+		high.update(0, 0, time.Second)
+	}
+	assert.Nil(high.DoFilter(next)(newShedderContext()))
+	assert.Equal(int64(0), high.Status().Inflight)
+}
@@ -0,0 +1,255 @@
+package fluxext
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/dgrijalva/jwt-go"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	TypeIdTokenExchangeFilter = "token_exchange_filter"
+)
+
+const (
+	ConfigKeyTokenIssuer          = "issuer"
+	ConfigKeyTokenSecret          = "secret"
+	ConfigKeyTokenTTL             = "ttl"
+	ConfigKeyTokenHeader          = "header"
+	ConfigKeyTokenClaims          = "claims"
+	ConfigKeyTokenClaimsPrefix    = "claims_prefix"
+	ConfigKeyTokenAudience        = "audience"
+	ConfigKeyTokenStripCredential = "strip_credential"
+)
+
+const (
+	// Endpoint属性：上游服务身份令牌的Audience；未定义时使用默认配置
+	EndpointAttrTagTokenAudience = "tokenaudience"
+)
+
+const (
+	ErrorCodeTokenExchangeFailed = "AUTHORIZATION:TOKEN_EXCHANGE:FAILED"
+)
+
+type (
+	// TokenSubjectFunc 查找已验证的终端用户身份声明；返回false表示请求未携带已验证的身份
+	TokenSubjectFunc func(ctx *flux.Context) (jwt.MapClaims, bool)
+	// TokenExchanger 将终端用户身份交换为面向指定上游服务（Audience）的身份令牌
+	TokenExchanger interface {
+		Exchange(ctx *flux.Context, subject jwt.MapClaims, audience string) (token string, expiresAt time.Time, err error)
+	}
+)
+
+// TokenExchangeConfig 令牌交换配置
+type TokenExchangeConfig struct {
+	SkipFunc    flux.FilterSkipper
+	SubjectFunc TokenSubjectFunc
+	Exchanger   TokenExchanger
+}
+
+func NewTokenExchangeFilter(c TokenExchangeConfig) *TokenExchangeFilter {
+	return &TokenExchangeFilter{
+		Configs: c,
+		cache:   make(map[string]exchangedToken, 64),
+	}
+}
+
+// TokenExchangeFilter 将已验证的终端用户凭证，交换为面向上游服务的身份令牌（收窄Audience与Claims），
+// 并通过Attribute传递给上游服务；上游服务不再接收客户端的原始凭证。
+// 注意：需要在JWTFilter等身份验证Filter之后执行。
+type TokenExchangeFilter struct {
+	Configs  TokenExchangeConfig
+	header   string
+	audience string
+	strip    bool
+	size     int
+	cache    map[string]exchangedToken
+	mu       sync.RWMutex
+}
+
+type exchangedToken struct {
+	token     string
+	refreshAt time.Time
+}
+
+func (f *TokenExchangeFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyTokenIssuer:          "flux",
+		ConfigKeyTokenTTL:             time.Minute * 5,
+		ConfigKeyTokenHeader:          "X-Identity-Token",
+		ConfigKeyTokenClaims:          []string{"sub"},
+		ConfigKeyTokenClaimsPrefix:    "jwt",
+		ConfigKeyTokenAudience:        "",
+		ConfigKeyTokenStripCredential: true,
+		ConfigKeyCacheSize:            10000,
+	})
+	f.header = config.GetString(ConfigKeyTokenHeader)
+	f.audience = config.GetString(ConfigKeyTokenAudience)
+	f.strip = config.GetBool(ConfigKeyTokenStripCredential)
+	f.size = config.GetInt(ConfigKeyCacheSize)
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	if fluxpkg.IsNil(f.Configs.SubjectFunc) {
+		f.Configs.SubjectFunc = NewAttributeSubjectFunc(config.GetString(ConfigKeyTokenClaimsPrefix))
+	}
+	if fluxpkg.IsNil(f.Configs.Exchanger) {
+		secret, err := config.GetStringE(ConfigKeyTokenSecret)
+		if nil != err {
+			return fmt.Errorf("TokenExchangeFilter: %w", err)
+		}
+		if secret == "" {
+			return errors.New("TokenExchangeFilter: config(secret) is required for default exchanger")
+		}
+		f.Configs.Exchanger = &SignedTokenExchanger{
+			Issuer:  config.GetString(ConfigKeyTokenIssuer),
+			Secret:  []byte(secret),
+			TTL:     config.GetDuration(ConfigKeyTokenTTL),
+			Claims:  config.GetStringSlice(ConfigKeyTokenClaims),
+			Signing: jwt.SigningMethodHS256,
+		}
+	}
+	logger.Infow("TokenExchange filter initializing", "header", f.header,
+		"audience", f.audience, "strip-credential", f.strip, "cache-size", f.size)
+	return nil
+}
+
+func (*TokenExchangeFilter) FilterId() string {
+	return TypeIdTokenExchangeFilter
+}
+
+func (f *TokenExchangeFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		subject, ok := f.Configs.SubjectFunc(ctx)
+		if !ok {
+			return next(ctx)
+		}
+		audience := ctx.Endpoint().GetAttr(EndpointAttrTagTokenAudience).GetString()
+		if audience == "" {
+			audience = f.audience
+		}
+		if audience == "" {
+			audience = ctx.Transporter().Interface
+		}
+		token, err := f.exchange(ctx, subject, audience)
+		if nil != err {
+			logger.TraceContext(ctx).Warnw("TOKEN_EXCHANGE:FAILED", "audience", audience, "error", err)
+			return &flux.ServeError{
+				StatusCode: http.StatusUnauthorized,
+				ErrorCode:  ErrorCodeTokenExchangeFailed,
+				Message:    "TOKEN_EXCHANGE:FAILED",
+				CauseError: err,
+			}
+		}
+		if f.strip {
+			ctx.Request().Header.Del(flux.HeaderAuthorization)
+		}
+		ctx.SetAttribute(f.header, token)
+		return next(ctx)
+	}
+}
+
+// exchange 交换身份令牌；相同凭证、身份声明与Audience的令牌在有效期内复用
+func (f *TokenExchangeFilter) exchange(ctx *flux.Context, subject jwt.MapClaims, audience string) (string, error) {
+	key := exchangeKey(ctx, subject, audience)
+	now := time.Now()
+	f.mu.RLock()
+	cached, ok := f.cache[key]
+	f.mu.RUnlock()
+	if ok && now.Before(cached.refreshAt) {
+		return cached.token, nil
+	}
+	token, expiresAt, err := f.Configs.Exchanger.Exchange(ctx, subject, audience)
+	if nil != err {
+		return "", err
+	}
+	// 在令牌有效期剩余10%时刷新
+	refreshAt := expiresAt.Add(-expiresAt.Sub(now) / 10)
+	f.mu.Lock()
+	if len(f.cache) >= f.size {
+		for k, v := range f.cache {
+			if now.After(v.refreshAt) {
+				delete(f.cache, k)
+			}
+		}
+	}
+	if len(f.cache) < f.size {
+		f.cache[key] = exchangedToken{token: token, refreshAt: refreshAt}
+	}
+	f.mu.Unlock()
+	return token, nil
+}
+
+// exchangeKey 按Audience、客户端原始凭证和全部身份声明（包括scope等）生成令牌缓存Key
+func exchangeKey(ctx *flux.Context, subject jwt.MapClaims, audience string) string {
+	names := make([]string, 0, len(subject))
+	for name := range subject {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, audience)
+	_, _ = hash.Write([]byte{0})
+	_, _ = io.WriteString(hash, ctx.HeaderVar(flux.HeaderAuthorization))
+	for _, name := range names {
+		_, _ = hash.Write([]byte{0})
+		_, _ = fmt.Fprintf(hash, "%s=%v", name, subject[name])
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// NewAttributeSubjectFunc 从JWTFilter写入的Attributes（prefix.claim）中读取终端用户身份声明
+func NewAttributeSubjectFunc(prefix string) TokenSubjectFunc {
+	prefix = prefix + "."
+	return func(ctx *flux.Context) (jwt.MapClaims, bool) {
+		claims := jwt.MapClaims{}
+		for k, v := range ctx.Attributes() {
+			if strings.HasPrefix(k, prefix) {
+				claims[k[len(prefix):]] = v
+			}
+		}
+		_, ok := claims["sub"]
+		return claims, ok
+	}
+}
+
+// SignedTokenExchanger 由网关签发面向上游服务的JWT身份令牌，只保留白名单内的Claims
+type SignedTokenExchanger struct {
+	Issuer  string
+	Secret  interface{}
+	TTL     time.Duration
+	Claims  []string
+	Signing jwt.SigningMethod
+}
+
+func (e *SignedTokenExchanger) Exchange(_ *flux.Context, subject jwt.MapClaims, audience string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(e.TTL)
+	claims := jwt.MapClaims{}
+	for _, name := range e.Claims {
+		if v, ok := subject[name]; ok {
+			claims[name] = v
+		}
+	}
+	claims["iss"] = e.Issuer
+	claims["aud"] = audience
+	claims["iat"] = now.Unix()
+	claims["exp"] = expiresAt.Unix()
+	token, err := jwt.NewWithClaims(e.Signing, claims).SignedString(e.Secret)
+	return token, expiresAt, err
+}
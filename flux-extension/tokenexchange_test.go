@@ -0,0 +1,65 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// countingExchanger 记录交换次数，每次返回新的令牌
+type countingExchanger struct {
+	calls int
+}
+
+func (e *countingExchanger) Exchange(_ *flux.Context, _ jwt.MapClaims, audience string) (string, time.Time, error) {
+	e.calls++
+	return audience + "#" + strconv.Itoa(e.calls), time.Now().Add(time.Minute), nil
+}
+
+func newTokenExchangeContext(authorization string) *flux.Context {
+	request := httptest.NewRequest(http.MethodGet, "http://gateway/users", nil)
+	request.Header.Set(flux.HeaderAuthorization, authorization)
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("token", request, nil, nil), &flux.Endpoint{HttpPattern: "/users"})
+	return ctx
+}
+
+func TestTokenExchangeFilter_CacheKey(t *testing.T) {
+	assert := assert.New(t)
+	exchanger := new(countingExchanger)
+	filter := NewTokenExchangeFilter(TokenExchangeConfig{Exchanger: exchanger})
+	assert.NoError(filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{})))
+	read := jwt.MapClaims{"sub": "u1", "scope": "read"}
+	write := jwt.MapClaims{"sub": "u1", "scope": "write"}
+
+	first, err := filter.exchange(newTokenExchangeContext("Bearer a"), read, "orders")
+	assert.NoError(err)
+	cached, err := filter.exchange(newTokenExchangeContext("Bearer a"), read, "orders")
+	assert.NoError(err)
+	assert.Equal(first, cached)
+	assert.Equal(1, exchanger.calls)
+	// 不同的scope，或不同的原始凭证，不复用令牌
+	_, _ = filter.exchange(newTokenExchangeContext("Bearer a"), write, "orders")
+	assert.Equal(2, exchanger.calls)
+	_, _ = filter.exchange(newTokenExchangeContext("Bearer b"), read, "orders")
+	assert.Equal(3, exchanger.calls)
+}
+
+func TestTokenExchangeFilter_CacheSize(t *testing.T) {
+	assert := assert.New(t)
+	filter := NewTokenExchangeFilter(TokenExchangeConfig{Exchanger: new(countingExchanger)})
+	assert.NoError(filter.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyCacheSize: 2,
+	})))
+	for i := 0; i < 10; i++ {
+		_, err := filter.exchange(newTokenExchangeContext("Bearer "+strconv.Itoa(i)), jwt.MapClaims{"sub": "u1"}, "orders")
+		assert.NoError(err)
+	}
+	assert.Len(filter.cache, 2)
+}
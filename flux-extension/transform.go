@@ -0,0 +1,376 @@
+package fluxext
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const (
+	TypeIdTransformFilter = "transform_filter"
+)
+
+const (
+	ConfigKeyTransformTemplates = "templates"
+)
+
+const (
+	// Endpoint属性：请求Body的转换规则
+	EndpointAttrTagTransformRequest = "transformrequest"
+	// Endpoint属性：响应Body的转换规则
+	EndpointAttrTagTransformResponse = "transformresponse"
+)
+
+const (
+	// 转换规则前缀：Go模板，模板输出必须为JSON
+	transformPrefixTemplate = "tmpl:"
+	// 转换规则前缀：引用配置中的命名规则
+	transformPrefixNamed = "@"
+)
+
+// BodyTransformer JSON数据转换接口
+type BodyTransformer interface {
+	// Transform 转换JSON数据，返回转换后的JSON字节数据
+	Transform(ctx *flux.Context, data interface{}) ([]byte, error)
+}
+
+// TransformConfig 数据转换配置
+type TransformConfig struct {
+	SkipFunc flux.FilterSkipper
+}
+
+func NewTransformFilter(c TransformConfig) *TransformFilter {
+	return &TransformFilter{
+		Configs: c,
+	}
+}
+
+// TransformFilter 按Endpoint定义的转换规则，在转发前转换JSON请求Body，在响应解析后转换JSON响应Body。
+// 转换规则支持两种格式：
+// 1. JSONPath映射规则：{"target.path": "$.source.path", "literal": 1}，源路径以 $ 开头，$attr.name 读取Attribute；
+// 2. Go模板：以 tmpl: 开头，模板数据为JSON Body，支持函数 json，attr；
+// 以 @name 引用配置 templates 中定义的命名规则。
+type TransformFilter struct {
+	Configs   TransformConfig
+	templates map[string]string
+	compiled  sync.Map
+}
+
+func (f *TransformFilter) Init(config *flux.Configuration) error {
+	f.templates = config.GetStringMapString(ConfigKeyTransformTemplates)
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Infow("Transform filter initializing", "templates", len(f.templates))
+	return nil
+}
+
+func (*TransformFilter) FilterId() string {
+	return TypeIdTransformFilter
+}
+
+func (f *TransformFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		if f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		if spec := ctx.Endpoint().GetAttr(EndpointAttrTagTransformRequest).GetString(); spec != "" {
+			if err := f.transformRequest(ctx, spec); nil != err {
+				logger.TraceContext(ctx).Warnw("TRANSFORM:REQUEST:ERROR", "error", err)
+				return &flux.ServeError{
+					StatusCode: flux.StatusBadRequest,
+					ErrorCode:  flux.ErrorCodeRequestInvalid,
+					Message:    "TRANSFORM:REQUEST",
+					CauseError: err,
+				}
+			}
+		}
+		if spec := ctx.Endpoint().GetAttr(EndpointAttrTagTransformResponse).GetString(); spec != "" {
+			ctx.AddResponseHook(func(ctx *flux.Context, response *flux.ResponseBody) error {
+				return f.transformResponse(ctx, spec, response)
+			})
+		}
+		return next(ctx)
+	}
+}
+
+func (f *TransformFilter) transformRequest(ctx *flux.Context, spec string) error {
+	if !strings.Contains(ctx.HeaderVar(flux.HeaderContentType), "json") {
+		return nil
+	}
+	transformer, err := f.lookup(spec)
+	if nil != err {
+		return err
+	}
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return err
+	}
+	data, err := decodeJSONBody(reader)
+	if nil != err {
+		return err
+	}
+	out, err := transformer.Transform(ctx, data)
+	if nil != err {
+		return err
+	}
+	request := ctx.Request()
+	request.Body = ioutil.NopCloser(bytes.NewReader(out))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(out)), nil
+	}
+	request.ContentLength = int64(len(out))
+	request.Header.Set(flux.HeaderContentLength, strconv.Itoa(len(out)))
+	return nil
+}
+
+func (f *TransformFilter) transformResponse(ctx *flux.Context, spec string, response *flux.ResponseBody) error {
+	transformer, err := f.lookup(spec)
+	if nil != err {
+		return err
+	}
+	data, ok, err := decodeResponseJSON(response)
+	if nil != err || !ok {
+		// 非JSON数据，不作转换
+		return err
+	}
+	out, err := transformer.Transform(ctx, data)
+	if nil != err {
+		return err
+	}
+	response.Body = out
+	if nil != response.Headers {
+		response.Headers.Del(flux.HeaderContentLength)
+	}
+	return nil
+}
+
+// lookup 解析并缓存转换规则
+func (f *TransformFilter) lookup(spec string) (BodyTransformer, error) {
+	if strings.HasPrefix(spec, transformPrefixNamed) {
+		named, ok := f.templates[spec[len(transformPrefixNamed):]]
+		if !ok {
+			return nil, fmt.Errorf("transform template not found: %s", spec)
+		}
+		spec = named
+	}
+	if v, ok := f.compiled.Load(spec); ok {
+		return v.(BodyTransformer), nil
+	}
+	transformer, err := NewBodyTransformer(spec)
+	if nil != err {
+		return nil, err
+	}
+	f.compiled.Store(spec, transformer)
+	return transformer, nil
+}
+
+// NewBodyTransformer 解析转换规则
+func NewBodyTransformer(spec string) (BodyTransformer, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, transformPrefixTemplate) {
+		return newTemplateTransformer(spec[len(transformPrefixTemplate):])
+	}
+	rules := make(map[string]interface{}, 8)
+	if err := ext.JSONUnmarshal([]byte(spec), &rules); nil != err {
+		return nil, fmt.Errorf("invalid transform mapping rules, err: %w", err)
+	}
+	return MappingTransformer(rules), nil
+}
+
+// MappingTransformer JSONPath映射规则：目标路径 -> 源路径或字面值
+type MappingTransformer map[string]interface{}
+
+func (m MappingTransformer) Transform(ctx *flux.Context, data interface{}) ([]byte, error) {
+	out := make(map[string]interface{}, len(m))
+	for target, source := range m {
+		value := source
+		if path, ok := source.(string); ok && strings.HasPrefix(path, "$") {
+			if strings.HasPrefix(path, "$attr.") {
+				value, _ = ctx.GetAttribute(path[len("$attr."):])
+			} else {
+				value, _ = LookupJSONPath(data, path)
+			}
+		}
+		setJSONPath(out, target, value)
+	}
+	return ext.JSONMarshal(out)
+}
+
+type templateTransformer struct {
+	tmpl *template.Template
+}
+
+func newTemplateTransformer(text string) (*templateTransformer, error) {
+	// attr 函数在执行时按请求绑定
+	tmpl, err := template.New("transform").Funcs(template.FuncMap{
+		"json": jsonTemplateFunc,
+		"attr": func(string) interface{} { return nil },
+	}).Parse(text)
+	if nil != err {
+		return nil, fmt.Errorf("invalid transform template, err: %w", err)
+	}
+	return &templateTransformer{tmpl: tmpl}, nil
+}
+
+func (t *templateTransformer) Transform(ctx *flux.Context, data interface{}) ([]byte, error) {
+	tmpl, err := t.tmpl.Clone()
+	if nil != err {
+		return nil, err
+	}
+	tmpl.Funcs(template.FuncMap{
+		"attr": func(name string) interface{} {
+			v, _ := ctx.GetAttribute(name)
+			return v
+		},
+	})
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, data); nil != err {
+		return nil, err
+	}
+	var check interface{}
+	if err := ext.JSONUnmarshal(buf.Bytes(), &check); nil != err {
+		return nil, fmt.Errorf("transform template output is not json, err: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func jsonTemplateFunc(v interface{}) (string, error) {
+	bytes, err := ext.JSONMarshal(v)
+	return string(bytes), err
+}
+
+// decodeResponseJSON 将响应数据解析为JSON结构；响应数据流读取后以字节数据替换；字节及字符串数据按JSON文本解析，不是JSON格式时返回false
+func decodeResponseJSON(response *flux.ResponseBody) (interface{}, bool, error) {
+	var data interface{}
+	switch body := response.Body.(type) {
+	case io.Reader:
+		raw, err := ioutil.ReadAll(body)
+		if closer, ok := body.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		if nil != err {
+			return nil, false, err
+		}
+		response.Body = raw
+		if err := ext.JSONUnmarshal(raw, &data); nil != err {
+			return nil, false, nil
+		}
+	case []byte:
+		if err := ext.JSONUnmarshal(body, &data); nil != err {
+			return nil, false, nil
+		}
+	case string:
+		if err := ext.JSONUnmarshal([]byte(body), &data); nil != err {
+			return nil, false, nil
+		}
+	default:
+		// 对象数据统一转换为JSON结构
+		raw, err := ext.JSONMarshal(body)
+		if nil != err {
+			return nil, false, err
+		}
+		if err := ext.JSONUnmarshal(raw, &data); nil != err {
+			return nil, false, err
+		}
+	}
+	return data, true, nil
+}
+
+func decodeJSONBody(reader io.ReadCloser) (interface{}, error) {
+	defer reader.Close()
+	raw, err := ioutil.ReadAll(reader)
+	if nil != err {
+		return nil, err
+	}
+	var data interface{}
+	if len(raw) == 0 {
+		return data, nil
+	}
+	if err := ext.JSONUnmarshal(raw, &data); nil != err {
+		return nil, fmt.Errorf("decode json body, err: %w", err)
+	}
+	return data, nil
+}
+
+// LookupJSONPath 按路径查找JSON数据；路径格式：$.a.b[0].c
+func LookupJSONPath(data interface{}, path string) (interface{}, bool) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" {
+		return data, true
+	}
+	current := data
+	for _, part := range strings.Split(path, ".") {
+		name, indexes, err := parseJSONPathPart(part)
+		if nil != err {
+			return nil, false
+		}
+		if name != "" {
+			obj, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if current, ok = obj[name]; !ok {
+				return nil, false
+			}
+		}
+		for _, idx := range indexes {
+			arr, ok := current.([]interface{})
+			if !ok || idx < 0 || idx >= len(arr) {
+				return nil, false
+			}
+			current = arr[idx]
+		}
+	}
+	return current, true
+}
+
+// parseJSONPathPart 解析路径片段：name[0][1]
+func parseJSONPathPart(part string) (string, []int, error) {
+	pos := strings.IndexByte(part, '[')
+	if pos < 0 {
+		return part, nil, nil
+	}
+	name, rest := part[:pos], part[pos:]
+	indexes := make([]int, 0, 1)
+	for len(rest) > 0 {
+		end := strings.IndexByte(rest, ']')
+		if rest[0] != '[' || end < 0 {
+			return "", nil, errors.New("invalid json path: " + part)
+		}
+		idx, err := strconv.Atoi(rest[1:end])
+		if nil != err {
+			return "", nil, err
+		}
+		indexes = append(indexes, idx)
+		rest = rest[end+1:]
+	}
+	return name, indexes, nil
+}
+
+func setJSONPath(out map[string]interface{}, path string, value interface{}) {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	parts := strings.Split(path, ".")
+	current := out
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{}, 4)
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
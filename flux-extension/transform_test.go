@@ -0,0 +1,44 @@
+package fluxext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestDecodeResponseJSON(t *testing.T) {
+	assert := assert.New(t)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	expected := map[string]interface{}{"id": "u1"}
+	cases := []interface{}{
+		ioutil.NopCloser(strings.NewReader(`{"id":"u1"}`)),
+		[]byte(`{"id":"u1"}`),
+		`{"id":"u1"}`,
+		map[string]string{"id": "u1"},
+		struct {
+			Id string `json:"id"`
+		}{Id: "u1"},
+	}
+	for _, body := range cases {
+		response := &flux.ResponseBody{Body: body}
+		data, ok, err := decodeResponseJSON(response)
+		assert.NoError(err)
+		assert.True(ok)
+		assert.Equal(expected, data)
+	}
+	// 读取后的数据流以字节数据替换
+	response := &flux.ResponseBody{Body: strings.NewReader(`{"id":"u1"}`)}
+	_, _, _ = decodeResponseJSON(response)
+	assert.Equal([]byte(`{"id":"u1"}`), response.Body)
+
+	// 非JSON格式的字节及字符串数据不作转换
+	for _, body := range []interface{}{[]byte("plain text"), "plain text", strings.NewReader("plain text")} {
+		response := &flux.ResponseBody{Body: body}
+		_, ok, err := decodeResponseJSON(response)
+		assert.NoError(err)
+		assert.False(ok)
+	}
+}
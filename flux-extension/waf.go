@@ -0,0 +1,431 @@
+package fluxext
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	TypeIdWAFFilter = "waf_filter"
+)
+
+const (
+	ConfigKeyWAFMode           = "mode"
+	ConfigKeyWAFRuleSets       = "rule_sets"
+	ConfigKeyWAFRuleFiles      = "rule_files"
+	ConfigKeyWAFDisabledRules  = "disabled_rules"
+	ConfigKeyWAFInspectHeaders = "inspect_headers"
+	ConfigKeyWAFBodyLimit      = "body_limit"
+	// 不检测Body的请求类型；block 模式下，其它无法检测的请求Body被拒绝
+	ConfigKeyWAFBypassBodyTypes = "bypass_body_types"
+)
+
+const (
+	// Endpoint属性：WAF工作模式；off, detect, block
+	EndpointAttrTagWAF = "waf"
+)
+
+const (
+	// WAFModeOff 不检测请求
+	WAFModeOff = "off"
+	// WAFModeDetect 只检测并记录命中的规则，不拦截请求
+	WAFModeDetect = "detect"
+	// WAFModeBlock 拦截命中规则的请求
+	WAFModeBlock = "block"
+)
+
+const (
+	// 规则检测的请求部位
+	WAFTargetURL     = "url"
+	WAFTargetQuery   = "query"
+	WAFTargetHeaders = "headers"
+	WAFTargetBody    = "body"
+)
+
+const (
+	// 内置规则集
+	WAFRuleSetSQLi      = "sqli"
+	WAFRuleSetXSS       = "xss"
+	WAFRuleSetTraversal = "traversal"
+)
+
+const (
+	ErrorCodeWAFBlocked = "PERMISSION:WAF_BLOCKED"
+)
+
+var (
+	errWAFBodyTooLarge      = errors.New("request body exceeds body limit")
+	errWAFBodyUninspectable = errors.New("request body content type is not inspectable")
+)
+
+var (
+	wafBuiltinRules = []WAFRule{
+		{Id: "sqli-001", Category: WAFRuleSetSQLi, Message: "union select",
+			Pattern: `(?i)\bunion\b[\s\S]{0,40}?\bselect\b`},
+		{Id: "sqli-002", Category: WAFRuleSetSQLi, Message: "boolean tautology",
+			Pattern: `(?i)['"]\s*\b(or|and)\b\s+['"]?\w+['"]?\s*(=|like)\s*['"]?\w+`},
+		{Id: "sqli-003", Category: WAFRuleSetSQLi, Message: "quote followed by comment",
+			Pattern: `(?i)'\s*(--|#|/\*)`},
+		{Id: "sqli-004", Category: WAFRuleSetSQLi, Message: "stacked statement",
+			Pattern: `(?i);\s*\b(drop|delete|insert|update|alter|create|truncate|exec)\b\s+\w+`},
+		{Id: "sqli-005", Category: WAFRuleSetSQLi, Message: "time based or file access function",
+			Pattern: `(?i)\b(sleep|benchmark|pg_sleep|load_file)\s*\(|\bwaitfor\s+delay\b|\binto\s+(out|dump)file\b`},
+		{Id: "xss-001", Category: WAFRuleSetXSS, Message: "script tag",
+			Pattern: `(?i)<\s*/?\s*script\b`},
+		{Id: "xss-002", Category: WAFRuleSetXSS, Message: "event handler attribute",
+			Pattern: `(?i)<[^>]+\bon[a-z]+\s*=`},
+		{Id: "xss-003", Category: WAFRuleSetXSS, Message: "script uri",
+			Pattern: `(?i)\b(javascript|vbscript)\s*:`},
+		{Id: "xss-004", Category: WAFRuleSetXSS, Message: "embedded content tag",
+			Pattern: `(?i)<\s*(iframe|object|embed|applet|base|meta)\b`},
+		{Id: "traversal-001", Category: WAFRuleSetTraversal, Message: "parent directory",
+			Pattern: `(\.\.[/\\])|([/\\]\.\.$)`, Targets: []string{WAFTargetURL, WAFTargetQuery, WAFTargetHeaders}},
+		{Id: "traversal-002", Category: WAFRuleSetTraversal, Message: "sensitive system file",
+			Pattern: `(?i)(/etc/(passwd|shadow|hosts)\b|\bwin\.ini\b|/proc/self/)`},
+		{Id: "traversal-003", Category: WAFRuleSetTraversal, Message: "null byte",
+			Pattern: `\x00`, Targets: []string{WAFTargetURL, WAFTargetQuery, WAFTargetHeaders}},
+	}
+)
+
+// WAFRule WAF检测规则；规则文件为JSON格式的规则列表
+type WAFRule struct {
+	Id       string   `json:"id"`
+	Category string   `json:"category"`
+	Pattern  string   `json:"pattern"`
+	Targets  []string `json:"targets"`
+	Message  string   `json:"message"`
+	regex    *regexp.Regexp
+}
+
+func (r *WAFRule) compile() error {
+	if r.Id == "" {
+		return fmt.Errorf("rule id is required, pattern: %s", r.Pattern)
+	}
+	regex, err := regexp.Compile(r.Pattern)
+	if nil != err {
+		return fmt.Errorf("rule pattern invalid, id: %s, error: %w", r.Id, err)
+	}
+	r.regex = regex
+	if len(r.Targets) == 0 {
+		r.Targets = []string{WAFTargetURL, WAFTargetQuery, WAFTargetHeaders, WAFTargetBody}
+	}
+	return nil
+}
+
+func (r *WAFRule) inspects(target string) bool {
+	return fluxpkg.StringSliceContains(r.Targets, target)
+}
+
+// WAFHit 规则命中统计
+type WAFHit struct {
+	RuleId   string `json:"ruleId"`
+	Category string `json:"category"`
+	Hits     int64  `json:"hits"`
+}
+
+// WAFConfig WAF配置
+type WAFConfig struct {
+	SkipFunc flux.FilterSkipper
+	// HitCounter 规则命中计数指标，标签：rule，category，mode；可选
+	HitCounter flux.CounterVec
+}
+
+func NewWAFFilter(c WAFConfig) *WAFFilter {
+	return &WAFFilter{
+		Configs: c,
+	}
+}
+
+// WAFFilter Web应用防火墙：按规则检测请求URL，Query参数，Header及Body中的常见攻击特征（SQL注入，XSS，路径穿越）；
+// 工作模式由全局配置 mode 及Endpoint属性 waf 决定：detect 只记录命中的规则，block 拦截请求。
+// 规则由内置规则集及 rule_files 指定的规则文件加载，可通过 disabled_rules 禁用指定规则。
+// block 模式下，超过 body_limit，读取失败或类型无法检测（bypass_body_types 除外）的请求Body被拒绝；body_limit 不大于0时不检测Body。
+type WAFFilter struct {
+	Configs     WAFConfig
+	mode        string
+	rules       []*WAFRule
+	headers     []string
+	bodyLimit   int64
+	bypassTypes []string
+	hits        sync.Map
+}
+
+func (f *WAFFilter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyWAFMode:           WAFModeDetect,
+		ConfigKeyWAFRuleSets:       []string{WAFRuleSetSQLi, WAFRuleSetXSS, WAFRuleSetTraversal},
+		ConfigKeyWAFInspectHeaders: []string{flux.HeaderUserAgent, flux.HeaderReferer, flux.HeaderCookie},
+		ConfigKeyWAFBodyLimit:      1024 * 1024,
+	})
+	mode, ok := parseWAFMode(config.GetString(ConfigKeyWAFMode))
+	if !ok {
+		return fmt.Errorf("WAFFilter: unknown mode: %s", config.GetString(ConfigKeyWAFMode))
+	}
+	rules, err := loadWAFRules(config.GetStringSlice(ConfigKeyWAFRuleSets), config.GetStringSlice(ConfigKeyWAFRuleFiles),
+		config.GetStringSlice(ConfigKeyWAFDisabledRules))
+	if nil != err {
+		return fmt.Errorf("WAFFilter: %w", err)
+	}
+	f.mode, f.rules = mode, rules
+	f.headers = config.GetStringSlice(ConfigKeyWAFInspectHeaders)
+	f.bodyLimit = config.GetInt64(ConfigKeyWAFBodyLimit)
+	f.bypassTypes = lowerStrings(config.GetStringSlice(ConfigKeyWAFBypassBodyTypes))
+	if fluxpkg.IsNil(f.Configs.SkipFunc) {
+		f.Configs.SkipFunc = func(_ *flux.Context) bool {
+			return false
+		}
+	}
+	logger.Infow("WAF filter initializing", "mode", f.mode, "rules", len(f.rules),
+		"rule-files", config.GetStringSlice(ConfigKeyWAFRuleFiles), "inspect-headers", f.headers, "body-limit", f.bodyLimit)
+	return nil
+}
+
+func (*WAFFilter) FilterId() string {
+	return TypeIdWAFFilter
+}
+
+func (f *WAFFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		mode := f.modeOf(ctx.Endpoint())
+		if mode == WAFModeOff || f.Configs.SkipFunc(ctx) {
+			return next(ctx)
+		}
+		rule, target, err := f.inspect(ctx)
+		if nil != err {
+			logger.TraceContext(ctx).Warnw("WAF:INSPECT:ERROR", "mode", mode, "error", err)
+			// 无法完整检测的请求，block 模式下拒绝
+			if mode == WAFModeBlock {
+				return f.rejectUninspected(err)
+			}
+		}
+		if nil == rule {
+			return next(ctx)
+		}
+		f.hit(rule, mode)
+		logger.TraceContext(ctx).Warnw("WAF:RULE:HIT", "mode", mode, "rule-id", rule.Id, "category", rule.Category,
+			"target", target, "message", rule.Message, "client-ip", flux.RealIP(ctx))
+		if mode != WAFModeBlock {
+			return next(ctx)
+		}
+		return &flux.ServeError{
+			StatusCode: flux.StatusAccessDenied,
+			ErrorCode:  ErrorCodeWAFBlocked,
+			Message:    "WAF:REQUEST_BLOCKED",
+			CauseError: fmt.Errorf("waf rule hit, id: %s, target: %s", rule.Id, target),
+		}
+	}
+}
+
+func (f *WAFFilter) rejectUninspected(err error) *flux.ServeError {
+	serr := &flux.ServeError{
+		StatusCode: flux.StatusBadRequest,
+		ErrorCode:  ErrorCodeWAFBlocked,
+		Message:    "WAF:BODY_UNREADABLE",
+		CauseError: err,
+	}
+	switch err {
+	case errWAFBodyTooLarge:
+		serr.StatusCode, serr.Message = flux.StatusTooLarge, "WAF:BODY_TOO_LARGE"
+	case errWAFBodyUninspectable:
+		serr.StatusCode, serr.Message = flux.StatusUnsupported, "WAF:BODY_UNINSPECTABLE"
+	}
+	return serr
+}
+
+// Hits 返回规则命中统计，按命中次数降序排列
+func (f *WAFFilter) Hits() []WAFHit {
+	out := make([]WAFHit, 0, len(f.rules))
+	f.hits.Range(func(key, value interface{}) bool {
+		hit := value.(*WAFHit)
+		out = append(out, WAFHit{RuleId: hit.RuleId, Category: hit.Category, Hits: atomic.LoadInt64(&hit.Hits)})
+		return true
+	})
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Hits > out[j].Hits
+	})
+	return out
+}
+
+// HitsHandler 查询规则命中统计的处理接口，需要注册到管理WebListener，例如：GET /debug/waf/hits
+func (f *WAFFilter) HitsHandler(webex flux.ServerWebContext) error {
+	data, err := ext.JSONMarshal(map[string]interface{}{"mode": f.mode, "rules": len(f.rules), "hits": f.Hits()})
+	if nil != err {
+		return err
+	}
+	return webex.Write(flux.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, data)
+}
+
+func (f *WAFFilter) modeOf(endpoint *flux.Endpoint) string {
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagWAF); ok {
+		if mode, ok := parseWAFMode(attr.GetString()); ok {
+			return mode
+		}
+	}
+	return f.mode
+}
+
+func (f *WAFFilter) hit(rule *WAFRule, mode string) {
+	v, _ := f.hits.LoadOrStore(rule.Id, &WAFHit{RuleId: rule.Id, Category: rule.Category})
+	atomic.AddInt64(&v.(*WAFHit).Hits, 1)
+	if !fluxpkg.IsNil(f.Configs.HitCounter) {
+		f.Configs.HitCounter.WithLabelValues(rule.Id, rule.Category, mode).Inc()
+	}
+}
+
+// inspect 按URL，Query参数名称及参数值，Header，Body的顺序检测请求，返回首个命中的规则及请求部位；
+// Body超过限制时只检测限制内的数据，并返回错误
+func (f *WAFFilter) inspect(ctx *flux.Context) (*WAFRule, string, error) {
+	if rule := f.match(WAFTargetURL, decodeWAFValue(ctx.URL().EscapedPath())); nil != rule {
+		return rule, WAFTargetURL, nil
+	}
+	for name, values := range ctx.QueryVars() {
+		if rule := f.match(WAFTargetQuery, decodeWAFValue(name)); nil != rule {
+			return rule, WAFTargetQuery, nil
+		}
+		for _, value := range values {
+			if rule := f.match(WAFTargetQuery, decodeWAFValue(value)); nil != rule {
+				return rule, WAFTargetQuery, nil
+			}
+		}
+	}
+	for _, name := range f.headers {
+		for _, value := range ctx.HeaderVars().Values(name) {
+			if rule := f.match(WAFTargetHeaders, decodeWAFValue(value)); nil != rule {
+				return rule, WAFTargetHeaders, nil
+			}
+		}
+	}
+	if f.bodyLimit <= 0 || ctx.Request().ContentLength == 0 {
+		return nil, "", nil
+	}
+	mediaType := wafMediaType(ctx.HeaderVar(flux.HeaderContentType))
+	if fluxpkg.StringSliceContains(f.bypassTypes, mediaType) {
+		return nil, "", nil
+	}
+	if !isInspectableBody(mediaType) {
+		return nil, "", errWAFBodyUninspectable
+	}
+	if ctx.Request().ContentLength > f.bodyLimit {
+		return nil, "", errWAFBodyTooLarge
+	}
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return nil, "", err
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(reader, f.bodyLimit+1))
+	_ = reader.Close()
+	if nil != err {
+		return nil, "", err
+	}
+	if int64(len(data)) > f.bodyLimit {
+		data, err = data[:f.bodyLimit], errWAFBodyTooLarge
+	}
+	body := string(data)
+	if mediaType == flux.MIMEApplicationForm {
+		body = decodeWAFValue(body)
+	}
+	if rule := f.match(WAFTargetBody, body); nil != rule {
+		return rule, WAFTargetBody, nil
+	}
+	return nil, "", err
+}
+
+func (f *WAFFilter) match(target, value string) *WAFRule {
+	if value == "" {
+		return nil
+	}
+	for _, rule := range f.rules {
+		if rule.inspects(target) && rule.regex.MatchString(value) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func loadWAFRules(sets, files, disabled []string) ([]*WAFRule, error) {
+	rules := make([]*WAFRule, 0, len(wafBuiltinRules))
+	for _, builtin := range wafBuiltinRules {
+		if fluxpkg.StringSliceContains(sets, builtin.Category) {
+			rule := builtin
+			rules = append(rules, &rule)
+		}
+	}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if nil != err {
+			return nil, fmt.Errorf("read rule file: %s, error: %w", file, err)
+		}
+		loaded := make([]*WAFRule, 0, 8)
+		if err := ext.JSONUnmarshal(data, &loaded); nil != err {
+			return nil, fmt.Errorf("decode rule file: %s, error: %w", file, err)
+		}
+		rules = append(rules, loaded...)
+	}
+	out := make([]*WAFRule, 0, len(rules))
+	ids := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if err := rule.compile(); nil != err {
+			return nil, err
+		}
+		if ids[rule.Id] {
+			return nil, fmt.Errorf("duplicated rule id: %s", rule.Id)
+		}
+		ids[rule.Id] = true
+		if !fluxpkg.StringSliceContains(disabled, rule.Id) {
+			out = append(out, rule)
+		}
+	}
+	return out, nil
+}
+
+func parseWAFMode(mode string) (string, bool) {
+	switch mode = strings.ToLower(strings.TrimSpace(mode)); mode {
+	case WAFModeOff, WAFModeDetect, WAFModeBlock:
+		return mode, true
+	default:
+		return "", false
+	}
+}
+
+// decodeWAFValue 对请求值做两次URL解码，识别双重编码的攻击特征
+func decodeWAFValue(value string) string {
+	for i := 0; i < 2 && strings.ContainsAny(value, "%+"); i++ {
+		decoded, err := url.QueryUnescape(value)
+		if nil != err {
+			break
+		}
+		value = decoded
+	}
+	return value
+}
+
+// wafMediaType 返回小写的请求Body类型，不包含参数
+func wafMediaType(contentType string) string {
+	if idx := strings.IndexByte(contentType, ';'); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// isInspectableBody 文本格式的请求Body：JSON，XML（包括 +json，+xml 结构化类型），表单，Multipart表单及纯文本
+func isInspectableBody(mediaType string) bool {
+	switch mediaType {
+	case flux.MIMEApplicationJSON, flux.MIMEApplicationForm, flux.MIMEMultipartForm, flux.MIMEApplicationXML,
+		flux.MIMETextXML, flux.MIMETextPlain:
+		return true
+	default:
+		return strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+	}
+}
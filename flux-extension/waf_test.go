@@ -0,0 +1,134 @@
+package fluxext
+
+import (
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newWAFFilter(t *testing.T, config map[string]interface{}) *WAFFilter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	filter := NewWAFFilter(WAFConfig{})
+	assert.NoError(t, filter.Init(flux.NewConfigurationOfMap(config)))
+	return filter
+}
+
+func newWAFContext(target, contentType, body string) *flux.Context {
+	request := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(body)), nil
+	}
+	if contentType != "" {
+		request.Header.Set(flux.HeaderContentType, contentType)
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("waf", request, nil, nil), &flux.Endpoint{HttpPattern: "/search"})
+	ctx.SetResponseWriter(httptest.NewRecorder())
+	return ctx
+}
+
+func wafNext(_ *flux.Context) *flux.ServeError {
+	return nil
+}
+
+func TestWAFFilter_BuiltinRules(t *testing.T) {
+	filter := newWAFFilter(t, map[string]interface{}{})
+	cases := []struct {
+		rule     string
+		target   string
+		positive string
+		negative string
+	}{
+		{"sqli-001", WAFTargetQuery, "1 UNION ALL SELECT password FROM users", "the union of selected sets"},
+		{"sqli-002", WAFTargetQuery, "admin' OR 1=1", "O'Reilly and Sons"},
+		{"sqli-003", WAFTargetQuery, "admin'--", "it's #1"},
+		{"sqli-004", WAFTargetBody, "1; DROP TABLE users", "a; dropbox sync"},
+		{"sqli-005", WAFTargetQuery, "1 AND SLEEP(5)", "sleep well tonight"},
+		{"xss-001", WAFTargetBody, "<script>alert(1)</script>", "manuscript text"},
+		{"xss-002", WAFTargetQuery, "<img src=x onerror=alert(1)>", "onerror=retry"},
+		{"xss-003", WAFTargetHeaders, "javascript:alert(1)", "javascript tutorial"},
+		{"xss-004", WAFTargetBody, "<iframe src=//evil.com>", "metadata <b>bold</b>"},
+		{"traversal-001", WAFTargetURL, "/files/../../secret", "/files/version-1..2"},
+		{"traversal-002", WAFTargetQuery, "/etc/passwd", "/etc/nginx.conf"},
+		{"traversal-003", WAFTargetURL, "/files/a\x00.png", "/files/a0.png"},
+	}
+	assert.Len(t, filter.rules, len(cases))
+	for _, c := range cases {
+		rule := filter.match(c.target, c.positive)
+		if assert.NotNil(t, rule, c.rule) {
+			assert.Equal(t, c.rule, rule.Id)
+		}
+		assert.Nil(t, filter.match(c.target, c.negative), c.rule)
+	}
+	// 路径穿越及空字节规则不检测Body
+	assert.Nil(t, filter.match(WAFTargetBody, "../../secret"))
+	assert.Nil(t, filter.match(WAFTargetBody, "a\x00b"))
+}
+
+func TestWAFFilter_InspectTargets(t *testing.T) {
+	assert := assert.New(t)
+	filter := newWAFFilter(t, map[string]interface{}{ConfigKeyWAFMode: WAFModeBlock})
+	blocked := []*flux.Context{
+		// Query参数名称
+		newWAFContext("http://gateway/search?%3Cscript%3E=1", "", ""),
+		// Body类型不区分大小写，支持 +json 结构化类型
+		newWAFContext("http://gateway/search", "Application/Vnd.Api+JSON; charset=utf-8", `{"q":"' OR 1=1"}`),
+		newWAFContext("http://gateway/search", "multipart/form-data; boundary=x",
+			"--x\r\nContent-Disposition: form-data; name=\"q\"\r\n\r\n<script>alert(1)</script>\r\n--x--\r\n"),
+		newWAFContext("http://gateway/search", "application/x-www-form-urlencoded", "q=%3Cscript%3E"),
+	}
+	for _, ctx := range blocked {
+		serr := filter.DoFilter(wafNext)(ctx)
+		if assert.NotNil(serr, ctx.URI()) {
+			assert.Equal(flux.StatusAccessDenied, serr.StatusCode)
+			assert.Equal(ErrorCodeWAFBlocked, serr.ErrorCode)
+		}
+	}
+	assert.Nil(filter.DoFilter(wafNext)(newWAFContext("http://gateway/search?q=shoes", flux.MIMEApplicationJSON, `{"q":"shoes"}`)))
+}
+
+func TestWAFFilter_BlockUninspectedBody(t *testing.T) {
+	assert := assert.New(t)
+	config := map[string]interface{}{ConfigKeyWAFMode: WAFModeBlock, ConfigKeyWAFBodyLimit: 16}
+	filter := newWAFFilter(t, config)
+	body := strings.Repeat("a", 32)
+	serr := filter.DoFilter(wafNext)(newWAFContext("http://gateway/search", flux.MIMETextPlain, body))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusTooLarge, serr.StatusCode)
+	}
+	// 未知长度的Body超过限制
+	ctx := newWAFContext("http://gateway/search", flux.MIMETextPlain, body)
+	ctx.Request().ContentLength = -1
+	serr = filter.DoFilter(wafNext)(ctx)
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusTooLarge, serr.StatusCode)
+	}
+	serr = filter.DoFilter(wafNext)(newWAFContext("http://gateway/search", "application/octet-stream", "data"))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusUnsupported, serr.StatusCode)
+	}
+	ctx = newWAFContext("http://gateway/search", flux.MIMETextPlain, "data")
+	ctx.Request().GetBody = func() (io.ReadCloser, error) {
+		return nil, errors.New("read error")
+	}
+	serr = filter.DoFilter(wafNext)(ctx)
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusBadRequest, serr.StatusCode)
+	}
+
+	// detect 模式不拦截；bypass_body_types 指定的类型不检测
+	config[ConfigKeyWAFMode] = WAFModeDetect
+	assert.Nil(newWAFFilter(t, config).DoFilter(wafNext)(newWAFContext("http://gateway/search", flux.MIMETextPlain, body)))
+	config[ConfigKeyWAFMode] = WAFModeBlock
+	config[ConfigKeyWAFBypassBodyTypes] = []string{"Application/Octet-Stream"}
+	assert.Nil(newWAFFilter(t, config).DoFilter(wafNext)(newWAFContext("http://gateway/search", "application/octet-stream", "data")))
+}
@@ -0,0 +1,45 @@
+package fluxinspect
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"sort"
+)
+
+// OrphanRef 被Endpoint引用但未注册的服务
+type OrphanRef struct {
+	ServiceId string   `json:"serviceId"`
+	Refs      []string `json:"refs"`
+}
+
+// Orphans 服务引用关系检查结果
+type Orphans struct {
+	// Orphans 被引用但未注册的服务
+	Orphans []OrphanRef `json:"orphans"`
+	// Unreferenced 已注册但未被任何Endpoint引用的服务ID
+	Unreferenced []string `json:"unreferenced"`
+}
+
+// DoQueryOrphans 检查Endpoint与Service的引用关系
+func DoQueryOrphans() Orphans {
+	out := Orphans{Orphans: make([]OrphanRef, 0, 4), Unreferenced: make([]string, 0, 4)}
+	for id, refs := range ext.OrphanServiceRefs() {
+		out.Orphans = append(out.Orphans, OrphanRef{ServiceId: id, Refs: refs})
+	}
+	sort.Slice(out.Orphans, func(i, j int) bool {
+		return out.Orphans[i].ServiceId < out.Orphans[j].ServiceId
+	})
+	for id, srv := range ext.TransporterServices() {
+		if ext.ServiceRefCount(id) > 0 || ext.ServiceRefCount(srv.ServiceId) > 0 ||
+			(srv.AliasId != "" && ext.ServiceRefCount(srv.AliasId) > 0) {
+			continue
+		}
+		out.Unreferenced = append(out.Unreferenced, id)
+	}
+	sort.Strings(out.Unreferenced)
+	return out
+}
+
+func OrphansHandler(ctx flux.ServerWebContext) error {
+	return send(ctx, flux.StatusOK, DoQueryOrphans())
+}
@@ -0,0 +1,45 @@
+package flux
+
+import "time"
+
+const (
+	// Endpoint属性：访问日志开关；off/false 关闭此Endpoint的访问日志，always 不受采样比例限制
+	EndpointAttrTagAccessLog = "accesslog"
+)
+
+const (
+	AccessLogModeOff    = "off"
+	AccessLogModeAlways = "always"
+)
+
+// AccessLog 单个请求的访问日志记录
+type AccessLog struct {
+	Time       time.Time     `json:"time"`
+	RequestId  string        `json:"requestId"`
+	TraceId    string        `json:"traceId,omitempty"`
+	ListenerId string        `json:"listenerId"`
+	RemoteAddr string        `json:"remoteAddr"`
+	Host       string        `json:"host"`
+	Method     string        `json:"method"`
+	URI        string        `json:"uri"`
+	Proto      string        `json:"proto"`
+	Status     int           `json:"status"`
+	Bytes      int64         `json:"bytes"`
+	ReqBytes   int64         `json:"reqBytes"`
+	Elapsed    time.Duration `json:"elapsed"`
+	Referer    string        `json:"referer,omitempty"`
+	UserAgent  string        `json:"userAgent,omitempty"`
+	Pattern    string        `json:"pattern"`
+	Version    string        `json:"version,omitempty"`
+	ServiceId  string        `json:"serviceId"`
+	ErrorCode  string        `json:"errorCode,omitempty"`
+	Metrics    []Metric      `json:"metrics,omitempty"`
+	// Always 为true时，不受采样比例限制
+	Always bool `json:"-"`
+}
+
+// AccessLogWriter 访问日志输出接口
+type AccessLogWriter interface {
+	// WriteAccessLog 输出访问日志；实现方需要保证不阻塞请求处理
+	WriteAccessLog(log *AccessLog)
+}
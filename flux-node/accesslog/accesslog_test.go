@@ -0,0 +1,181 @@
+package accesslog
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestAccessLog() *flux.AccessLog {
+	return &flux.AccessLog{
+		Time:       time.Date(2021, 3, 1, 10, 20, 30, 0, time.FixedZone("CST", 8*3600)),
+		RequestId:  "req-1",
+		RemoteAddr: "10.0.0.1",
+		Method:     "GET",
+		URI:        "/api/users?id=1",
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		Bytes:      128,
+		Elapsed:    time.Millisecond * 1500,
+		UserAgent:  "curl/7.64",
+	}
+}
+
+func TestCombinedFormatter(t *testing.T) {
+	line, err := CombinedFormatter(newTestAccessLog())
+	assert2.Nil(t, err)
+	assert2.Equal(t, `10.0.0.1 - - [01/Mar/2021:10:20:30 +0800] "GET /api/users?id=1 HTTP/1.1" 200 128 "-" "curl/7.64"`, string(line))
+}
+
+func TestJSONFormatter(t *testing.T) {
+	assert := assert2.New(t)
+	line, err := JSONFormatter(newTestAccessLog())
+	assert.Nil(err)
+	assert.Contains(string(line), `"requestId":"req-1"`)
+	assert.Contains(string(line), `"elapsed":1500`)
+	assert.NotContains(string(line), "Always")
+}
+
+func TestAccessLogger_Sampled(t *testing.T) {
+	assert := assert2.New(t)
+	l := NewAccessLogger()
+	l.ratio, l.alwaysErrors = 0, true
+	log := newTestAccessLog()
+	assert.False(l.sampled(log))
+	log.Status = 502
+	assert.True(l.sampled(log))
+	log.Status, log.Always = 200, true
+	assert.True(l.sampled(log))
+}
+
+func TestAccessLogger_SampledErrorsAndSlow(t *testing.T) {
+	assert := assert2.New(t)
+	l := NewAccessLogger()
+	l.ratio, l.alwaysErrors, l.errorStatus = 0, true, 400
+	log := newTestAccessLog()
+	log.Elapsed = time.Millisecond * 100
+	assert.False(l.sampled(log))
+	// 慢请求
+	l.slow = time.Millisecond * 100
+	assert.True(l.sampled(log))
+	log.Elapsed = time.Millisecond * 99
+	assert.False(l.sampled(log))
+	// 4xx 及网关错误码
+	log.Status = 404
+	assert.True(l.sampled(log))
+	log.Status, log.ErrorCode = 200, "GATEWAY:ENDPOINT_DISABLED"
+	assert.True(l.sampled(log))
+	l.alwaysErrors = false
+	assert.False(l.sampled(log))
+}
+
+func TestFileSink_Rotate(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "flux-accesslog")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+	sink, err := NewFileSink(path, 16, 1)
+	assert.Nil(err)
+	for i := 0; i < 3; i++ {
+		assert.Nil(sink.Write([]byte("0123456789")))
+		time.Sleep(time.Millisecond * 2)
+	}
+	assert.Nil(sink.Close())
+	backups, _ := filepath.Glob(path + ".*")
+	assert.Equal(1, len(backups))
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal("0123456789", strings.TrimSpace(string(data)))
+}
+
+func TestHttpSink_Batch(t *testing.T) {
+	assert := assert2.New(t)
+	bodies := make(chan string, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		bodies <- string(data)
+	}))
+	defer srv.Close()
+	sink := NewHttpSink(srv.URL, 2, time.Hour, srv.Client())
+	assert.Nil(sink.Write([]byte(`{"id":1}`)))
+	assert.Equal(0, len(bodies))
+	assert.Nil(sink.Write([]byte(`{"id":2}`)))
+	assert.Equal("{\"id\":1}\n{\"id\":2}\n", <-bodies)
+	assert.Nil(sink.Write([]byte(`{"id":3}`)))
+	assert.Nil(sink.Close())
+	assert.Equal("{\"id\":3}\n", <-bodies)
+}
+
+func TestJournal_Append(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "flux-journal")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.log")
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	journal := NewJournal()
+	assert.Nil(journal.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyJournalBody:       true,
+		ConfigKeyJournalEncryptKey: key,
+		ConfigKeyJournalMaxPending: 1,
+		ConfigKeySinks:             []interface{}{map[string]interface{}{"type": SinkTypeFile, "path": path}},
+	})))
+	assert.Nil(journal.Append(&JournalEntry{RequestId: "req-1", Status: 200}, []byte(`{"amount":100}`)))
+	// 写入位置已被占用时，等待超时返回Busy
+	journal.slots <- struct{}{}
+	journal.wait = time.Millisecond
+	assert.Equal(ErrJournalBusy, journal.Append(&JournalEntry{RequestId: "req-2"}, nil))
+	<-journal.slots
+	assert.Nil(journal.Shutdown(context.TODO()))
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(err)
+	entry := new(JournalEntry)
+	assert.Nil(json.Unmarshal(data, entry))
+	assert.Equal("req-1", entry.RequestId)
+	assert.True(entry.Encrypted)
+	body, err := OpenJournalBody(key, entry.Body)
+	assert.Nil(err)
+	assert.Equal(`{"amount":100}`, string(body))
+}
+
+func TestAuditTrail_Record(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "flux-audit")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "audit.log")
+	audit := NewAuditTrail()
+	assert.Nil(audit.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeySinks: []interface{}{map[string]interface{}{"type": SinkTypeFile, "path": path}},
+	})))
+	assert.Nil(audit.Record(&AuditEvent{
+		Category: AuditCategoryAdmin, Action: "ENDPOINT:DISABLE", Actor: "ops", RequestId: "req-1",
+		Before: map[string]bool{"disabled": false}, After: map[string]bool{"disabled": true},
+	}))
+	// 异步写入的事件在关闭前全部输出
+	audit.Post(&AuditEvent{Category: AuditCategoryDenied, Action: "AUTHORIZATION:DENIED", Actor: "anon", RequestId: "req-2"})
+	assert.Nil(audit.Shutdown(context.TODO()))
+	assert.Equal(uint64(0), audit.Dropped())
+	data, err := ioutil.ReadFile(path)
+	assert.Nil(err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(lines, 2)
+	event := make(map[string]interface{})
+	assert.Nil(json.Unmarshal([]byte(lines[0]), &event))
+	assert.Equal("ops", event["actor"])
+	assert.Equal(map[string]interface{}{"disabled": true}, event["after"])
+	assert.NotEmpty(event["time"])
+	event = make(map[string]interface{})
+	assert.Nil(json.Unmarshal([]byte(lines[1]), &event))
+	assert.Equal(AuditCategoryDenied, event["category"])
+}
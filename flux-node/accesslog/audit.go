@@ -0,0 +1,163 @@
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 管理接口调用
+	AuditCategoryAdmin = "admin"
+	// 权限及认证拒绝
+	AuditCategoryDenied = "denied"
+	// 配置文件或配置中心变更触发的操作
+	AuditCategorySystem = "system"
+)
+
+var (
+	_ flux.Initializer = new(AuditTrail)
+	_ flux.Shutdowner  = new(AuditTrail)
+)
+
+// AuditEvent 管理审计事件；Before/After 为操作前后的状态，无状态变更的事件为空
+type AuditEvent struct {
+	Time       time.Time   `json:"time"`
+	Category   string      `json:"category"`
+	Action     string      `json:"action"`
+	Actor      string      `json:"actor"`
+	RequestId  string      `json:"requestId"`
+	RemoteAddr string      `json:"remoteAddr"`
+	Target     string      `json:"target,omitempty"`
+	Status     int         `json:"status,omitempty"`
+	ErrorCode  string      `json:"errorCode,omitempty"`
+	Before     interface{} `json:"before,omitempty"`
+	After      interface{} `json:"after,omitempty"`
+}
+
+// AuditTrail 管理审计日志组件：记录管理接口调用、Endpoint启停、配置重载及权限认证拒绝事件；
+// 与请求审计日志 Journal 分开输出。Record 同步写入全部Sink；请求路径上的事件通过 Post 异步写入。
+type AuditTrail struct {
+	sinks   []Sink
+	queue   chan *AuditEvent
+	done    chan struct{}
+	dropped uint64
+	mu      sync.Mutex
+}
+
+func NewAuditTrail() *AuditTrail {
+	return &AuditTrail{
+		sinks: make([]Sink, 0, 1),
+	}
+}
+
+// AddSink 添加管理审计日志输出目标；需要在Init之前添加
+func (a *AuditTrail) AddSink(sink Sink) {
+	a.sinks = append(a.sinks, sink)
+}
+
+func (a *AuditTrail) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyQueueSize: 1024,
+	})
+	for _, sc := range config.GetConfigurationSlice(ConfigKeySinks) {
+		stype := sc.GetString("type")
+		factory, ok := sinkFactories[stype]
+		if !ok {
+			return fmt.Errorf("unknown audit sink type: %s", stype)
+		}
+		sink, err := factory(sc)
+		if nil != err {
+			return err
+		}
+		logger.Infow("AuditTrail add sink", "type", stype)
+		a.sinks = append(a.sinks, sink)
+	}
+	if len(a.sinks) == 0 {
+		sink, err := NewFileSink("./logs/audit.log", 0, 0)
+		if nil != err {
+			return err
+		}
+		a.sinks = append(a.sinks, sink)
+	}
+	a.queue = make(chan *AuditEvent, config.GetInt(ConfigKeyQueueSize))
+	a.done = make(chan struct{})
+	go a.loop()
+	logger.Infow("AuditTrail init", "sinks", len(a.sinks), "queue-size", cap(a.queue))
+	return nil
+}
+
+func (a *AuditTrail) Shutdown(ctx context.Context) error {
+	if nil != a.queue {
+		close(a.queue)
+		select {
+		case <-a.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, sink := range a.sinks {
+		if err := sink.Close(); nil != err {
+			logger.Warnw("AuditTrail close sink", "error", err)
+		}
+	}
+	return nil
+}
+
+// Record 写入管理审计事件；未设置时间时使用当前时间
+func (a *AuditTrail) Record(event *AuditEvent) error {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	line, err := json.Marshal(event)
+	if nil != err {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, sink := range a.sinks {
+		if err := sink.Write(line); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Post 将审计事件放入异步写入队列，不阻塞请求；队列已满时丢弃
+func (a *AuditTrail) Post(event *AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	defer func() {
+		// 关闭后到达的事件直接丢弃
+		_ = recover()
+	}()
+	select {
+	case a.queue <- event:
+	default:
+		if n := atomic.AddUint64(&a.dropped, 1); n%1000 == 1 {
+			logger.Warnw("AUDIT:QUEUE:FULL", "dropped", n)
+		}
+	}
+}
+
+// Dropped 返回因队列已满而丢弃的审计事件数量
+func (a *AuditTrail) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+func (a *AuditTrail) loop() {
+	defer close(a.done)
+	for event := range a.queue {
+		if err := a.Record(event); nil != err {
+			logger.Warnw("AUDIT:SINK:ERROR", "action", event.Action, "request-id", event.RequestId, "error", err)
+		}
+	}
+}
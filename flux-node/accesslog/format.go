@@ -0,0 +1,64 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"strconv"
+	"strings"
+)
+
+const (
+	FormatJSON     = "json"
+	FormatCombined = "combined"
+)
+
+// Formatter 将访问日志格式化为单行文本（不含换行符）
+type Formatter func(log *flux.AccessLog) ([]byte, error)
+
+// FormatterOf 返回指定名称的Formatter
+func FormatterOf(name string) (Formatter, bool) {
+	switch strings.ToLower(name) {
+	case FormatJSON, "":
+		return JSONFormatter, true
+	case FormatCombined:
+		return CombinedFormatter, true
+	default:
+		return nil, false
+	}
+}
+
+// JSONFormatter JSON格式；耗时字段以毫秒输出
+func JSONFormatter(log *flux.AccessLog) ([]byte, error) {
+	type alias flux.AccessLog
+	return json.Marshal(struct {
+		*alias
+		Elapsed float64 `json:"elapsed"`
+	}{
+		alias:   (*alias)(log),
+		Elapsed: float64(log.Elapsed.Microseconds()) / 1000,
+	})
+}
+
+// CombinedFormatter Apache Combined格式：
+// %h - - [%t] "%r" %>s %b "%{Referer}i" "%{User-agent}i"
+func CombinedFormatter(log *flux.AccessLog) ([]byte, error) {
+	host := log.RemoteAddr
+	if host == "" {
+		host = "-"
+	}
+	size := "-"
+	if log.Bytes > 0 {
+		size = strconv.FormatInt(log.Bytes, 10)
+	}
+	return []byte(fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s \"%s\" \"%s\"",
+		host, log.Time.Format("02/Jan/2006:15:04:05 -0700"), log.Method, log.URI, log.Proto,
+		log.Status, size, orDash(log.Referer), orDash(log.UserAgent))), nil
+}
+
+func orDash(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
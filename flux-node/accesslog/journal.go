@@ -0,0 +1,217 @@
+package accesslog
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"time"
+)
+
+const (
+	ConfigKeyJournalMaxPending  = "max_pending"
+	ConfigKeyJournalWaitTimeout = "wait_timeout"
+	ConfigKeyJournalBody        = "body"
+	ConfigKeyJournalBodyLimit   = "body_limit"
+	ConfigKeyJournalEncryptKey  = "encrypt_key"
+	ConfigKeyJournalFsync       = "fsync"
+)
+
+var (
+	// ErrJournalBusy 等待写入的审计日志数量达到上限，并且等待超时
+	ErrJournalBusy = errors.New("journal busy: too many pending entries")
+)
+
+var (
+	_ flux.Initializer = new(Journal)
+	_ flux.Shutdowner  = new(Journal)
+)
+
+// JournalEntry 审计日志条目；Body为请求数据，开启加密时为 base64(nonce+AES-GCM密文)
+type JournalEntry struct {
+	Time       time.Time `json:"time"`
+	RequestId  string    `json:"requestId"`
+	TraceId    string    `json:"traceId,omitempty"`
+	ListenerId string    `json:"listenerId"`
+	RemoteAddr string    `json:"remoteAddr"`
+	Method     string    `json:"method"`
+	URI        string    `json:"uri"`
+	Pattern    string    `json:"pattern"`
+	Version    string    `json:"version,omitempty"`
+	ServiceId  string    `json:"serviceId"`
+	Status     int       `json:"status"`
+	ErrorCode  string    `json:"errorCode,omitempty"`
+	Body       string    `json:"body,omitempty"`
+	Encrypted  bool      `json:"encrypted,omitempty"`
+}
+
+// Journal 审计日志组件：同步写入只追加的审计日志，写入完成后才响应客户端；
+// 同时写入的数量超过上限时，等待空闲写入位置，超时返回 ErrJournalBusy，由调用方拒绝请求。
+type Journal struct {
+	sinks     []Sink
+	slots     chan struct{}
+	wait      time.Duration
+	body      bool
+	bodyLimit int
+	fsync     bool
+	aead      cipher.AEAD
+}
+
+func NewJournal() *Journal {
+	return &Journal{
+		sinks: make([]Sink, 0, 1),
+	}
+}
+
+// AddSink 添加审计日志输出目标，例如远程存储；需要在Init之前添加
+func (j *Journal) AddSink(sink Sink) {
+	j.sinks = append(j.sinks, sink)
+}
+
+func (j *Journal) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyJournalMaxPending:  256,
+		ConfigKeyJournalWaitTimeout: time.Second,
+		ConfigKeyJournalBody:        false,
+		ConfigKeyJournalBodyLimit:   64 * 1024,
+		ConfigKeyJournalFsync:       true,
+	})
+	j.slots = make(chan struct{}, config.GetInt(ConfigKeyJournalMaxPending))
+	j.wait = config.GetDuration(ConfigKeyJournalWaitTimeout)
+	j.body = config.GetBool(ConfigKeyJournalBody)
+	j.bodyLimit = config.GetInt(ConfigKeyJournalBodyLimit)
+	j.fsync = config.GetBool(ConfigKeyJournalFsync)
+	// 密钥读取失败时拒绝启动，避免以不加密的方式记录请求数据
+	key, err := config.GetStringE(ConfigKeyJournalEncryptKey)
+	if nil != err {
+		return err
+	}
+	if key != "" {
+		aead, err := newJournalCipher(key)
+		if nil != err {
+			return err
+		}
+		j.aead = aead
+	}
+	for _, sc := range config.GetConfigurationSlice(ConfigKeySinks) {
+		stype := sc.GetString("type")
+		factory, ok := sinkFactories[stype]
+		if !ok {
+			return fmt.Errorf("unknown journal sink type: %s", stype)
+		}
+		sink, err := factory(sc)
+		if nil != err {
+			return err
+		}
+		logger.Infow("Journal add sink", "type", stype)
+		j.sinks = append(j.sinks, sink)
+	}
+	if len(j.sinks) == 0 {
+		sink, err := NewFileSink("./logs/journal.log", 0, 0)
+		if nil != err {
+			return err
+		}
+		j.sinks = append(j.sinks, sink)
+	}
+	logger.Infow("Journal init", "max-pending", cap(j.slots), "wait-timeout", j.wait,
+		"body", j.body, "encrypted", nil != j.aead, "sinks", len(j.sinks))
+	return nil
+}
+
+func (j *Journal) Shutdown(_ context.Context) error {
+	for _, sink := range j.sinks {
+		if err := sink.Close(); nil != err {
+			logger.Warnw("Journal close sink", "error", err)
+		}
+	}
+	return nil
+}
+
+// RecordBody 返回是否记录请求数据
+func (j *Journal) RecordBody() bool {
+	return j.body
+}
+
+// Append 写入审计日志条目；body 为请求数据，按配置截断并加密；全部Sink写入成功才返回nil
+func (j *Journal) Append(entry *JournalEntry, body []byte) error {
+	timer := time.NewTimer(j.wait)
+	defer timer.Stop()
+	select {
+	case j.slots <- struct{}{}:
+		defer func() { <-j.slots }()
+	case <-timer.C:
+		return ErrJournalBusy
+	}
+	if j.body && len(body) > 0 {
+		if j.bodyLimit > 0 && len(body) > j.bodyLimit {
+			body = body[:j.bodyLimit]
+		}
+		if nil != j.aead {
+			sealed, err := j.seal(body)
+			if nil != err {
+				return err
+			}
+			entry.Body, entry.Encrypted = sealed, true
+		} else {
+			entry.Body = string(body)
+		}
+	}
+	line, err := json.Marshal(entry)
+	if nil != err {
+		return err
+	}
+	for _, sink := range j.sinks {
+		if err := sink.Write(line); nil != err {
+			return err
+		}
+		if syncer, ok := sink.(interface{ Sync() error }); ok && j.fsync {
+			if err := syncer.Sync(); nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (j *Journal) seal(data []byte) (string, error) {
+	nonce := make([]byte, j.aead.NonceSize())
+	if _, err := rand.Read(nonce); nil != err {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(j.aead.Seal(nonce, nonce, data, nil)), nil
+}
+
+// OpenJournalBody 解密审计日志条目中加密的请求数据；key 为base64编码的AES密钥
+func OpenJournalBody(key string, body string) ([]byte, error) {
+	aead, err := newJournalCipher(key)
+	if nil != err {
+		return nil, err
+	}
+	data, err := base64.StdEncoding.DecodeString(body)
+	if nil != err {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("journal body too short")
+	}
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+}
+
+// newJournalCipher 创建AES-GCM加密实现；密钥长度为16，24或32字节
+func newJournalCipher(key string) (cipher.AEAD, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if nil != err {
+		return nil, fmt.Errorf("journal encrypt_key must be base64 encoded, err: %w", err)
+	}
+	block, err := aes.NewCipher(raw)
+	if nil != err {
+		return nil, fmt.Errorf("journal encrypt_key is invalid, err: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
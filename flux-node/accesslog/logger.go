@@ -0,0 +1,182 @@
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ConfigKeyFormat       = "format"
+	ConfigKeySampleRatio  = "sample_ratio"
+	ConfigKeyAlwaysErrors = "always_errors"
+	ConfigKeyErrorStatus  = "error_status"
+	ConfigKeySlowRequest  = "slow_threshold"
+	ConfigKeyQueueSize    = "queue_size"
+	ConfigKeySinks        = "sinks"
+)
+
+var (
+	_ flux.AccessLogWriter = new(AccessLogger)
+	_ flux.Initializer     = new(AccessLogger)
+	_ flux.Startuper       = new(AccessLogger)
+	_ flux.Shutdowner      = new(AccessLogger)
+)
+
+// defaultErrorStatus 默认的错误请求响应状态码下限
+const defaultErrorStatus = 500
+
+// AccessLogger 访问日志组件：按采样比例，将访问日志格式化后异步输出到多个Sink；
+// 错误请求及耗时达到慢请求阈值的请求不受采样比例限制。
+type AccessLogger struct {
+	formatter    Formatter
+	ratio        float64
+	alwaysErrors bool
+	errorStatus  int
+	slow         time.Duration
+	sinks        []Sink
+	queue        chan *flux.AccessLog
+	done         chan struct{}
+	dropped      uint64
+	random       *rand.Rand
+	randmu       sync.Mutex
+}
+
+func NewAccessLogger() *AccessLogger {
+	return &AccessLogger{
+		errorStatus: defaultErrorStatus,
+		sinks:       make([]Sink, 0, 2),
+		random:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// AddSink 添加访问日志输出目标；需要在Startup之前添加
+func (l *AccessLogger) AddSink(sink Sink) {
+	l.sinks = append(l.sinks, sink)
+}
+
+func (l *AccessLogger) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyFormat:       FormatJSON,
+		ConfigKeySampleRatio:  1.0,
+		ConfigKeyAlwaysErrors: true,
+		ConfigKeyErrorStatus:  defaultErrorStatus,
+		ConfigKeySlowRequest:  0,
+		ConfigKeyQueueSize:    4096,
+	})
+	formatter, ok := FormatterOf(config.GetString(ConfigKeyFormat))
+	if !ok {
+		return fmt.Errorf("unknown access log format: %s", config.GetString(ConfigKeyFormat))
+	}
+	l.formatter = formatter
+	l.ratio = config.GetFloat64(ConfigKeySampleRatio)
+	l.alwaysErrors = config.GetBool(ConfigKeyAlwaysErrors)
+	l.errorStatus = config.GetInt(ConfigKeyErrorStatus)
+	l.slow = config.GetDuration(ConfigKeySlowRequest)
+	l.queue = make(chan *flux.AccessLog, config.GetInt(ConfigKeyQueueSize))
+	l.done = make(chan struct{})
+	for _, sc := range config.GetConfigurationSlice(ConfigKeySinks) {
+		stype := sc.GetString("type")
+		factory, ok := sinkFactories[stype]
+		if !ok {
+			return fmt.Errorf("unknown access log sink type: %s", stype)
+		}
+		sink, err := factory(sc)
+		if nil != err {
+			return err
+		}
+		logger.Infow("AccessLog add sink", "type", stype)
+		l.sinks = append(l.sinks, sink)
+	}
+	if len(l.sinks) == 0 {
+		l.sinks = append(l.sinks, NewWriterSink(stdout))
+	}
+	logger.Infow("AccessLog init", "format", config.GetString(ConfigKeyFormat),
+		"sample-ratio", l.ratio, "always-errors", l.alwaysErrors, "error-status", l.errorStatus,
+		"slow-threshold", l.slow, "sinks", len(l.sinks))
+	return nil
+}
+
+func (l *AccessLogger) Startup() error {
+	go l.loop()
+	return nil
+}
+
+func (l *AccessLogger) Shutdown(ctx context.Context) error {
+	close(l.queue)
+	select {
+	case <-l.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	for _, sink := range l.sinks {
+		if err := sink.Close(); nil != err {
+			logger.Warnw("AccessLog close sink", "error", err)
+		}
+	}
+	return nil
+}
+
+// WriteAccessLog 按采样规则将访问日志放入输出队列；队列已满时丢弃
+func (l *AccessLogger) WriteAccessLog(log *flux.AccessLog) {
+	if !l.sampled(log) {
+		return
+	}
+	defer func() {
+		// 关闭后到达的日志直接丢弃
+		_ = recover()
+	}()
+	select {
+	case l.queue <- log:
+	default:
+		if n := atomic.AddUint64(&l.dropped, 1); n%1000 == 1 {
+			logger.Warnw("ACCESSLOG:QUEUE:FULL", "dropped", n)
+		}
+	}
+}
+
+// Dropped 返回因队列已满而丢弃的日志数量
+func (l *AccessLogger) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+func (l *AccessLogger) sampled(log *flux.AccessLog) bool {
+	if log.Always || l.ratio >= 1 || l.isError(log) || (l.slow > 0 && log.Elapsed >= l.slow) {
+		return true
+	}
+	if l.ratio <= 0 {
+		return false
+	}
+	l.randmu.Lock()
+	defer l.randmu.Unlock()
+	return l.random.Float64() < l.ratio
+}
+
+// isError 判断请求是否为错误请求：响应状态码达到 error_status，或者网关返回错误码
+func (l *AccessLogger) isError(log *flux.AccessLog) bool {
+	if !l.alwaysErrors {
+		return false
+	}
+	return log.ErrorCode != "" || (l.errorStatus > 0 && log.Status >= l.errorStatus)
+}
+
+func (l *AccessLogger) loop() {
+	defer close(l.done)
+	for log := range l.queue {
+		line, err := l.formatter(log)
+		if nil != err {
+			logger.Warnw("ACCESSLOG:FORMAT:ERROR", "request-id", log.RequestId, "error", err)
+			continue
+		}
+		for _, sink := range l.sinks {
+			if err := sink.Write(line); nil != err {
+				logger.Warnw("ACCESSLOG:SINK:ERROR", "request-id", log.RequestId, "error", err)
+			}
+		}
+	}
+}
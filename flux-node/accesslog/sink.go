@@ -0,0 +1,278 @@
+package accesslog
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	SinkTypeStdout = "stdout"
+	SinkTypeFile   = "file"
+	SinkTypeKafka  = "kafka"
+	SinkTypeHttp   = "http"
+)
+
+// Sink 访问日志的输出目标
+type Sink interface {
+	io.Closer
+	// Write 输出单行格式化后的访问日志
+	Write(line []byte) error
+}
+
+// SinkFactory 根据配置创建Sink
+type SinkFactory func(config *flux.Configuration) (Sink, error)
+
+var (
+	sinkFactories = map[string]SinkFactory{
+		SinkTypeStdout: func(_ *flux.Configuration) (Sink, error) {
+			return NewWriterSink(stdout), nil
+		},
+		SinkTypeFile: func(config *flux.Configuration) (Sink, error) {
+			config.SetDefaults(map[string]interface{}{
+				"path":        "./logs/access.log",
+				"max_size":    100,
+				"max_backups": 7,
+			})
+			return NewFileSink(config.GetString("path"), config.GetInt64("max_size")*1024*1024, config.GetInt("max_backups"))
+		},
+		SinkTypeKafka: func(config *flux.Configuration) (Sink, error) {
+			config.SetDefault("topic", "flux-access-log")
+			if kafkaProducer == nil {
+				return nil, fmt.Errorf("kafka producer not set, use accesslog.SetKafkaProducer")
+			}
+			return NewKafkaSink(config.GetString("topic"), kafkaProducer), nil
+		},
+		SinkTypeHttp: func(config *flux.Configuration) (Sink, error) {
+			config.SetDefaults(map[string]interface{}{
+				"batch_size":     100,
+				"flush_interval": time.Second,
+				"timeout":        time.Second * 3,
+			})
+			url := config.GetString("url")
+			if url == "" {
+				return nil, fmt.Errorf("http sink config(url) is required")
+			}
+			return NewHttpSink(url, config.GetInt("batch_size"), config.GetDuration("flush_interval"),
+				&http.Client{Timeout: config.GetDuration("timeout")}), nil
+		},
+	}
+	kafkaProducer KafkaProducer
+	stdout        io.Writer = os.Stdout
+)
+
+// RegisterSinkFactory 注册自定义类型的Sink
+func RegisterSinkFactory(sinkType string, factory SinkFactory) {
+	sinkFactories[sinkType] = factory
+}
+
+// WriterSink 输出到 io.Writer，如标准输出
+type WriterSink struct {
+	out io.Writer
+	mu  sync.Mutex
+}
+
+func NewWriterSink(out io.Writer) *WriterSink {
+	return &WriterSink{out: out}
+}
+
+func (s *WriterSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.out.Write(append(line, '\n'))
+	return err
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FileSink 输出到文件；文件大小超过限制时滚动，保留指定数量的历史文件
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+	mu         sync.Mutex
+}
+
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); nil != err {
+		return nil, fmt.Errorf("access log create dir, path: %s, err: %w", path, err)
+	}
+	s := &FileSink{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := s.open(); nil != err {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	size := int64(len(line) + 1)
+	if s.maxSize > 0 && s.size > 0 && s.size+size > s.maxSize {
+		if err := s.rotate(); nil != err {
+			return err
+		}
+	}
+	n, err := s.file.Write(append(line, '\n'))
+	s.size += int64(n)
+	return err
+}
+
+// Sync 将已写入的数据刷新到磁盘
+func (s *FileSink) Sync() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Sync()
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if nil != err {
+		return fmt.Errorf("access log open file, path: %s, err: %w", s.path, err)
+	}
+	info, err := file.Stat()
+	if nil != err {
+		_ = file.Close()
+		return fmt.Errorf("access log stat file, path: %s, err: %w", s.path, err)
+	}
+	s.file, s.size = file, info.Size()
+	return nil
+}
+
+func (s *FileSink) rotate() error {
+	_ = s.file.Close()
+	backup := s.path + "." + time.Now().Format("20060102-150405.000")
+	if err := os.Rename(s.path, backup); nil != err {
+		return fmt.Errorf("access log rotate file, path: %s, err: %w", s.path, err)
+	}
+	if s.maxBackups > 0 {
+		if backups, err := filepath.Glob(s.path + ".*"); nil == err && len(backups) > s.maxBackups {
+			sort.Strings(backups)
+			for _, old := range backups[:len(backups)-s.maxBackups] {
+				_ = os.Remove(old)
+			}
+		}
+	}
+	return s.open()
+}
+
+// KafkaProducer Kafka消息发送接口；由使用方基于具体的Kafka客户端实现
+type KafkaProducer interface {
+	Send(topic string, value []byte) error
+}
+
+// SetKafkaProducer 设置 kafka 类型Sink使用的消息发送实现
+func SetKafkaProducer(producer KafkaProducer) {
+	kafkaProducer = producer
+}
+
+// KafkaSink 输出到Kafka主题
+type KafkaSink struct {
+	topic    string
+	producer KafkaProducer
+}
+
+func NewKafkaSink(topic string, producer KafkaProducer) *KafkaSink {
+	return &KafkaSink{topic: topic, producer: producer}
+}
+
+func (s *KafkaSink) Write(line []byte) error {
+	return s.producer.Send(s.topic, line)
+}
+
+func (s *KafkaSink) Close() error {
+	if closer, ok := s.producer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// HttpSink 批量输出到HTTP接口；每批数据以换行分隔（application/x-ndjson）POST到指定URL，
+// 达到批量大小或者刷新间隔时发送。
+type HttpSink struct {
+	url    string
+	batch  int
+	client *http.Client
+	buffer bytes.Buffer
+	lines  int
+	done   chan struct{}
+	mu     sync.Mutex
+}
+
+func NewHttpSink(url string, batch int, interval time.Duration, client *http.Client) *HttpSink {
+	s := &HttpSink{url: url, batch: batch, client: client, done: make(chan struct{})}
+	go s.flushLoop(interval)
+	return s
+}
+
+func (s *HttpSink) Write(line []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffer.Write(line)
+	s.buffer.WriteByte('\n')
+	if s.lines++; s.lines >= s.batch {
+		return s.flush()
+	}
+	return nil
+}
+
+func (s *HttpSink) Close() error {
+	close(s.done)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.flush()
+}
+
+func (s *HttpSink) flushLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if err := s.flush(); nil != err {
+				logger.Warnw("ACCESSLOG:SINK:HTTP/ERROR", "url", s.url, "error", err)
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *HttpSink) flush() error {
+	if s.lines == 0 {
+		return nil
+	}
+	data := make([]byte, s.buffer.Len())
+	copy(data, s.buffer.Bytes())
+	s.buffer.Reset()
+	s.lines = 0
+	resp, err := s.client.Post(s.url, "application/x-ndjson", bytes.NewReader(data))
+	if nil != err {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("http sink returns status: %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package flux
+
+import (
+	"context"
+	"strings"
+)
+
+const (
+	// Service属性：多实例地址的负载均衡策略：roundrobin（默认），leastconn，hash，sticky
+	ServiceAttrTagLoadBalance = "loadbalance"
+	// Service属性：负载均衡策略为 hash 时，计算一致性哈希的请求Header名称
+	ServiceAttrTagHashHeader = "hashheader"
+	// Service属性：负载均衡策略为 sticky 时，记录会话绑定实例的Cookie名称
+	ServiceAttrTagStickyCookie = "stickycookie"
+	// Service属性：实例地址来源，格式为 <resolver>:<name>，例如：dnssrv:_http._tcp.users.local，consul:users，nacos:users
+	ServiceAttrTagInstanceSource = "instancesource"
+)
+
+const (
+	LoadBalanceRoundRobin = "roundrobin"
+	LoadBalanceLeastConn  = "leastconn"
+	LoadBalanceHash       = "hash"
+	LoadBalanceSticky     = "sticky"
+)
+
+type (
+	// LoadBalancer 在Service的多个实例地址中选择本次请求的目标地址；
+	// 返回的done函数在请求完成后调用，用于统计实例的活跃连接数等状态。
+	LoadBalancer interface {
+		Select(ctx *Context, service TransporterService, instances []string) (instance string, done func())
+	}
+	// InstanceResolver 从注册中心或DNS查询服务的实例地址列表（host:port）
+	InstanceResolver interface {
+		Resolve(ctx context.Context, name string) ([]string, error)
+	}
+)
+
+// Instances 返回Service的实例地址列表；RemoteHost以逗号分隔定义多个实例地址
+func (b TransporterService) Instances() []string {
+	if !strings.Contains(b.RemoteHost, ",") {
+		return []string{b.RemoteHost}
+	}
+	out := make([]string, 0, 4)
+	for _, host := range strings.Split(b.RemoteHost, ",") {
+		if host = strings.TrimSpace(host); host != "" {
+			out = append(out, host)
+		}
+	}
+	return out
+}
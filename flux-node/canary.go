@@ -0,0 +1,166 @@
+package flux
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+const (
+	// Endpoint属性：未指定版本的请求，按百分比（0-100）分配到此版本的流量权重
+	EndpointAttrTagCanaryWeight = "canaryweight"
+	// Endpoint属性：匹配后路由到此版本的规则，可定义多个：header:Name=value，cookie:name=value，user:id1,id2
+	EndpointAttrTagCanaryRule = "canaryrule"
+)
+
+const (
+	CanaryRuleHeader = "header"
+	CanaryRuleCookie = "cookie"
+	CanaryRuleUser   = "user"
+)
+
+var (
+	// CanaryUserHeader 灰度规则 user 匹配，以及按权重分配流量时，读取用户标识的Header
+	CanaryUserHeader = "X-User-Id"
+)
+
+// Select 按请求选择Endpoint版本；指定版本时按版本查找；
+// 未指定版本时，按各版本的灰度规则匹配；没有匹配的规则时，按灰度权重分配，未分配的流量路由到没有设置权重的版本。
+// 按权重分配时，相同用户标识的请求总是路由到相同的版本。
+func (m *MVCEndpoint) Select(webex ServerWebContext, version string) (Endpoint, bool) {
+	if "" != version {
+		return m.Lookup(version)
+	}
+	m.RLock()
+	defer m.RUnlock()
+	versions := m.ordered
+	if len(versions) == 0 {
+		return Endpoint{}, false
+	}
+	if len(versions) == 1 {
+		return m.dup(versions[0]), true
+	}
+	for _, ep := range versions {
+		for _, rule := range ep.GetAttr(EndpointAttrTagCanaryRule).GetStringSlice() {
+			if MatchCanaryRule(rule, webex) {
+				return m.dup(ep), true
+			}
+		}
+	}
+	user := webex.HeaderVar(CanaryUserHeader)
+	if user == "" {
+		user = webex.RequestId()
+	}
+	return m.dup(selectCanaryWeight(versions, canaryBucket(user))), true
+}
+
+// sortVersions 按版本号排序，保证选择结果稳定；在版本变更时执行，不在请求时排序
+func sortVersions(versions map[string]*Endpoint) []*Endpoint {
+	out := make([]*Endpoint, 0, len(versions))
+	for _, ep := range versions {
+		out = append(out, ep)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return CompareVersion(out[i].Version, out[j].Version) < 0
+	})
+	return out
+}
+
+// CompareVersion 按自然顺序比较版本号，数字部分按数值比较，例如 v9 < v10，1.9 < 1.10；
+// 返回值小于0、等于0、大于0分别表示 a 小于、等于、大于 b。
+func CompareVersion(a, b string) int {
+	for a != "" && b != "" {
+		var ca, cb string
+		ca, a = versionChunk(a)
+		cb, b = versionChunk(b)
+		if isDigit(ca[0]) && isDigit(cb[0]) {
+			ca, cb = strings.TrimLeft(ca, "0"), strings.TrimLeft(cb, "0")
+			if len(ca) != len(cb) {
+				return len(ca) - len(cb)
+			}
+		}
+		if c := strings.Compare(ca, cb); c != 0 {
+			return c
+		}
+	}
+	return len(a) - len(b)
+}
+
+// versionChunk 返回版本号开头的连续数字或连续非数字部分，以及剩余部分
+func versionChunk(v string) (string, string) {
+	digit := isDigit(v[0])
+	i := 1
+	for i < len(v) && isDigit(v[i]) == digit {
+		i++
+	}
+	return v[:i], v[i:]
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// selectCanaryWeight 按百分比区间选择版本；bucket取值 [0, 100)
+func selectCanaryWeight(versions []*Endpoint, bucket int) *Endpoint {
+	var primary *Endpoint
+	acc := 0
+	for _, ep := range versions {
+		weight := ep.GetAttr(EndpointAttrTagCanaryWeight).GetInt()
+		if weight <= 0 {
+			if nil == primary {
+				primary = ep
+			}
+			continue
+		}
+		acc += weight
+		if bucket < acc {
+			return ep
+		}
+	}
+	if nil != primary {
+		return primary
+	}
+	return versions[0]
+}
+
+func canaryBucket(key string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+// MatchCanaryRule 判断请求是否匹配灰度规则
+func MatchCanaryRule(rule string, webex ServerWebContext) bool {
+	kv := strings.SplitN(rule, ":", 2)
+	if len(kv) != 2 {
+		return false
+	}
+	switch strings.ToLower(kv[0]) {
+	case CanaryRuleHeader:
+		name, value := splitCanaryRule(kv[1])
+		return name != "" && value != "" && webex.HeaderVar(name) == value
+	case CanaryRuleCookie:
+		name, value := splitCanaryRule(kv[1])
+		cookie, err := webex.CookieVar(name)
+		return nil == err && cookie.Value == value
+	case CanaryRuleUser:
+		user := webex.HeaderVar(CanaryUserHeader)
+		if user == "" {
+			return false
+		}
+		for _, id := range strings.Split(kv[1], ",") {
+			if strings.TrimSpace(id) == user {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func splitCanaryRule(expr string) (string, string) {
+	kv := strings.SplitN(expr, "=", 2)
+	if len(kv) != 2 {
+		return "", ""
+	}
+	return strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+}
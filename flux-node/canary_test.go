@@ -0,0 +1,71 @@
+package flux
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestSelectCanaryWeight(t *testing.T) {
+	assert := assert2.New(t)
+	stable := &Endpoint{Version: "1.0"}
+	canary := &Endpoint{Version: "2.0", EmbeddedAttributes: EmbeddedAttributes{
+		Attributes: []Attribute{{Name: EndpointAttrTagCanaryWeight, Value: 20}},
+	}}
+	versions := []*Endpoint{stable, canary}
+	assert.Equal(canary, selectCanaryWeight(versions, 0))
+	assert.Equal(canary, selectCanaryWeight(versions, 19))
+	assert.Equal(stable, selectCanaryWeight(versions, 20))
+	assert.Equal(stable, selectCanaryWeight(versions, 99))
+	// 全部版本设置权重，未分配的流量路由到第一个版本
+	stable.Attributes = []Attribute{{Name: EndpointAttrTagCanaryWeight, Value: 50}}
+	assert.Equal(stable, selectCanaryWeight(versions, 40))
+	assert.Equal(canary, selectCanaryWeight(versions, 60))
+	assert.Equal(stable, selectCanaryWeight(versions, 80))
+}
+
+func TestCanaryBucket(t *testing.T) {
+	assert := assert2.New(t)
+	assert.Equal(canaryBucket("user-1001"), canaryBucket("user-1001"))
+	hits := 0
+	for i := 0; i < 10000; i++ {
+		if canaryBucket("user-"+strconv.Itoa(i)) < 20 {
+			hits++
+		}
+	}
+	assert.InDelta(2000, hits, 300)
+}
+
+func TestSplitCanaryRule(t *testing.T) {
+	assert := assert2.New(t)
+	name, value := splitCanaryRule("X-Canary = true")
+	assert.Equal("X-Canary", name)
+	assert.Equal("true", value)
+	name, value = splitCanaryRule("X-Canary")
+	assert.Equal("", name)
+	assert.Equal("", value)
+}
+
+func TestCompareVersion(t *testing.T) {
+	assert := assert2.New(t)
+	ordered := []string{"", "1", "1.2", "1.9", "1.10", "2.0", "v2", "v9", "v10", "v10-beta"}
+	for i := 1; i < len(ordered); i++ {
+		assert.True(CompareVersion(ordered[i-1], ordered[i]) < 0, ordered[i-1]+" < "+ordered[i])
+		assert.True(CompareVersion(ordered[i], ordered[i-1]) > 0, ordered[i]+" > "+ordered[i-1])
+	}
+	assert.Equal(0, CompareVersion("v010", "v10"))
+}
+
+func TestMVCEndpoint_Ordered(t *testing.T) {
+	assert := assert2.New(t)
+	multi := NewMultiEndpoint(&Endpoint{Version: "v9"})
+	multi.Update("v10", &Endpoint{Version: "v10"})
+	multi.Update("v2", &Endpoint{Version: "v2"})
+	versions := make([]string, 0, 3)
+	for _, ep := range multi.ordered {
+		versions = append(versions, ep.Version)
+	}
+	assert.Equal([]string{"v2", "v9", "v10"}, versions)
+	multi.Delete("v9")
+	assert.Len(multi.ordered, 2)
+}
@@ -0,0 +1,31 @@
+package common
+
+import (
+	"github.com/spf13/cast"
+)
+
+// ToJSONValue 将YAML解析的 map[interface{}]interface{} 转换为可序列化为JSON的 map[string]interface{}
+func ToJSONValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[cast.ToString(k)] = ToJSONValue(e)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[k] = ToJSONValue(e)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = ToJSONValue(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
@@ -0,0 +1,94 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"io/ioutil"
+	"mime"
+	"strings"
+)
+
+const (
+	// 已解析的请求Body数据；同一请求的多个参数共享解析结果
+	bodyVariableParsed = "flux.common.body.parsed"
+)
+
+// IsBodyPath 判断Key是否为请求Body内的路径：JSONPath（$.order.items[0].sku）或 JSON Pointer（/order/items/0/sku）
+func IsBodyPath(key string) bool {
+	return strings.HasPrefix(key, "$") || strings.HasPrefix(key, "/")
+}
+
+// LookupBodyPath 按JSONPath或JSON Pointer查找请求Body内的值；Body按其媒体类型解析，JSON之外的类型由已注册的BodyParser解析。
+// JSONPath支持 .name，['name']，[index]（负数从末尾计算），[*] 及 .*；包含通配符时，返回全部匹配值的列表，用于绑定列表类型的参数。
+func LookupBodyPath(ctx *flux.Context, path string) (flux.MTValue, error) {
+	tokens, multi, err := parsePath(path)
+	if nil != err {
+		return flux.NewInvalidMTValue(), err
+	}
+	body, err := lookupParsedBody(ctx)
+	if nil != err {
+		return flux.NewInvalidMTValue(), err
+	}
+	values := selectPath(body, tokens)
+	if multi {
+		return flux.WrapObjectMTValue(values), nil
+	}
+	if len(values) == 0 {
+		return flux.NewInvalidMTValue(), nil
+	}
+	return wrapBodyValue(values[0]), nil
+}
+
+func lookupParsedBody(ctx *flux.Context) (interface{}, error) {
+	if v, ok := ctx.GetVariable(bodyVariableParsed); ok {
+		return v, nil
+	}
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return nil, err
+	}
+	data, err := ioutil.ReadAll(reader)
+	_ = reader.Close()
+	if nil != err {
+		return nil, err
+	}
+	var body interface{}
+	contentType := lookupBodyType(ctx)
+	if len(bytes.TrimSpace(data)) > 0 {
+		if isJSONMediaType(contentType) {
+			// 直接解析为任意JSON值，支持顶层为数组的Body
+			err = ext.JSONUnmarshal(data, &body)
+		} else if parser, ok := ext.BodyParserByType(contentType); ok {
+			body, err = parser(contentType, data)
+		} else {
+			err = errors.New("unsupported body content-type to lookup path: " + contentType)
+		}
+		if nil != err {
+			return nil, fmt.Errorf("parse body to lookup path, content-type: %s, error: %w", contentType, err)
+		}
+	}
+	ctx.SetVariable(bodyVariableParsed, body)
+	return body, nil
+}
+
+func isJSONMediaType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if nil != err {
+		return false
+	}
+	return mediaType == flux.MIMEApplicationJSON || strings.HasSuffix(mediaType, "+json")
+}
+
+func wrapBodyValue(value interface{}) flux.MTValue {
+	switch v := value.(type) {
+	case string:
+		return flux.WrapStringMTValue(v)
+	case map[string]interface{}:
+		return flux.WrapStrMapMTValue(v)
+	default:
+		return flux.WrapObjectMTValue(v)
+	}
+}
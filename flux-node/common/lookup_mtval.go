@@ -4,6 +4,7 @@ import (
 	"errors"
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-pkg"
+	"mime"
 	"net/http"
 	"net/textproto"
 	"net/url"
@@ -61,8 +62,12 @@ func LookupMTValue(scope, key string, ctx *flux.Context) (value flux.MTValue, er
 	case flux.ScopeAttrs:
 		return flux.WrapStrMapMTValue(ctx.Attributes()), nil
 	case flux.ScopeBody:
+		// Key为JSONPath或JSON Pointer时，查找Body内的值
+		if IsBodyPath(key) {
+			return LookupBodyPath(ctx, key)
+		}
 		reader, err := ctx.BodyReader()
-		return flux.MTValue{Valid: err == nil, Value: reader, MediaType: ctx.HeaderVar(flux.HeaderContentType)}, err
+		return flux.MTValue{Valid: err == nil, Value: reader, MediaType: lookupBodyType(ctx)}, err
 	case flux.ScopeParam:
 		v, _ := fluxpkg.LookupByProviders(key, ctx.QueryVars, ctx.FormVars)
 		return flux.WrapStringMTValue(v), nil
@@ -120,3 +125,27 @@ func lookupValues(mapVal interface{}, key string) flux.MTValue {
 		return flux.NewInvalidMTValue()
 	}
 }
+
+// lookupBodyType 查找请求Body的媒体类型；Endpoint定义的 bodytype 属性优先于请求的Content-Type，
+// 并保留请求Content-Type中的参数，例如 multipart 的 boundary
+func lookupBodyType(ctx *flux.Context) string {
+	ct := ctx.HeaderVar(flux.HeaderContentType)
+	if nil == ctx.Endpoint() {
+		return ct
+	}
+	bt := ctx.Endpoint().GetAttr(flux.EndpointAttrTagBodyType).GetString()
+	if bt == "" {
+		return ct
+	}
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if nil != err {
+		return bt
+	}
+	if strings.EqualFold(mediaType, bt) {
+		return ct
+	}
+	if typed := mime.FormatMediaType(bt, params); typed != "" {
+		return typed
+	}
+	return bt
+}
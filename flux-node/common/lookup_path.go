@@ -0,0 +1,179 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/spf13/cast"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type pathToken struct {
+	name     string
+	isName   bool
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// SelectPath 按JSONPath（$.data.items[0].id，可省略 $ 前缀）或 JSON Pointer（/data/items/0/id）查找数据，返回全部匹配的值，以及路径是否包含通配符；
+// 网关内按路径提取数据（请求Body参数绑定，聚合，编排等）均使用此实现。
+func SelectPath(root interface{}, path string) ([]interface{}, bool, error) {
+	if path == "" || path == "$" {
+		return []interface{}{root}, false, nil
+	}
+	if !IsBodyPath(path) {
+		path = "$." + path
+	}
+	tokens, multi, err := parsePath(path)
+	if nil != err {
+		return nil, false, err
+	}
+	return selectPath(root, tokens), multi, nil
+}
+
+// LookupPath 按路径提取数据：路径包含通配符时返回全部匹配值的列表；路径不存在时返回false
+func LookupPath(root interface{}, path string) (interface{}, bool, error) {
+	values, multi, err := SelectPath(root, path)
+	if nil != err {
+		return nil, false, err
+	}
+	if multi {
+		return values, true, nil
+	}
+	if len(values) == 0 {
+		return nil, false, nil
+	}
+	return values[0], true, nil
+}
+
+// parsePath 解析JSONPath或JSON Pointer，返回路径Token列表，以及路径是否包含通配符
+func parsePath(path string) ([]pathToken, bool, error) {
+	switch {
+	case strings.HasPrefix(path, "$"):
+		return parseJSONPath(path)
+	case strings.HasPrefix(path, "/"):
+		return parseJSONPointer(path), false, nil
+	default:
+		return nil, false, errors.New("illegal body path: " + path)
+	}
+}
+
+func parseJSONPath(path string) ([]pathToken, bool, error) {
+	tokens := make([]pathToken, 0, 4)
+	multi := false
+	illegal := func(msg string) ([]pathToken, bool, error) {
+		return nil, false, fmt.Errorf("illegal json path: %s, %s", path, msg)
+	}
+	for i := 1; i < len(path); {
+		switch path[i] {
+		case '.':
+			end := i + 1
+			for end < len(path) && path[end] != '.' && path[end] != '[' {
+				end++
+			}
+			name := path[i+1 : end]
+			if name == "" {
+				return illegal("empty field name")
+			}
+			if name == "*" {
+				multi = true
+				tokens = append(tokens, pathToken{wildcard: true})
+			} else {
+				tokens = append(tokens, namePathToken(name))
+			}
+			i = end
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return illegal("unclosed bracket")
+			}
+			inner := strings.TrimSpace(path[i+1 : i+end])
+			switch {
+			case inner == "*":
+				multi = true
+				tokens = append(tokens, pathToken{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				tokens = append(tokens, pathToken{name: inner[1 : len(inner)-1], isName: true})
+			default:
+				index, err := strconv.Atoi(inner)
+				if nil != err {
+					return illegal("invalid index: " + inner)
+				}
+				tokens = append(tokens, pathToken{index: index, isIndex: true})
+			}
+			i += end + 1
+		default:
+			return illegal("unexpected char: " + string(path[i]))
+		}
+	}
+	return tokens, multi, nil
+}
+
+func parseJSONPointer(path string) []pathToken {
+	segments := strings.Split(path[1:], "/")
+	tokens := make([]pathToken, 0, len(segments))
+	for _, seg := range segments {
+		seg = strings.ReplaceAll(strings.ReplaceAll(seg, "~1", "/"), "~0", "~")
+		tokens = append(tokens, namePathToken(seg))
+	}
+	return tokens
+}
+
+// namePathToken 字段名Token；数字字段名在数组中按下标，在对象中按字段名查找
+func namePathToken(name string) pathToken {
+	token := pathToken{name: name, isName: true}
+	if index, err := strconv.Atoi(name); nil == err && index >= 0 {
+		token.index, token.isIndex = index, true
+	}
+	return token
+}
+
+// selectPath 按路径Token逐层查找，返回全部匹配的值
+func selectPath(root interface{}, tokens []pathToken) []interface{} {
+	nodes := []interface{}{root}
+	for _, token := range tokens {
+		next := make([]interface{}, 0, len(nodes))
+		for _, node := range nodes {
+			switch v := node.(type) {
+			case map[string]interface{}:
+				if token.wildcard {
+					keys := make([]string, 0, len(v))
+					for key := range v {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, v[key])
+					}
+				} else if value, ok := v[token.name]; ok && token.isName {
+					next = append(next, value)
+				}
+			case map[interface{}]interface{}:
+				if token.wildcard {
+					next = append(next, selectPath(cast.ToStringMap(v), []pathToken{token})...)
+				} else if value, ok := v[token.name]; ok && token.isName {
+					next = append(next, value)
+				}
+			case []interface{}:
+				if token.wildcard {
+					next = append(next, v...)
+				} else if token.isIndex {
+					index := token.index
+					if index < 0 {
+						index += len(v)
+					}
+					if index >= 0 && index < len(v) {
+						next = append(next, v[index])
+					}
+				}
+			}
+		}
+		if len(next) == 0 {
+			return next
+		}
+		nodes = next
+	}
+	return nodes
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func TestSelectPath(t *testing.T) {
+	assert := assert2.New(t)
+	var body interface{}
+	assert.NoError(json.Unmarshal([]byte(`{"order":{"id":"o1","items":[{"sku":"s1","qty":1},{"sku":"s2","qty":2}],"a/b":"slash"}}`), &body))
+	cases := []struct {
+		path   string
+		multi  bool
+		expect []interface{}
+	}{
+		{path: "$.order.id", expect: []interface{}{"o1"}},
+		{path: "$.order.items[0].sku", expect: []interface{}{"s1"}},
+		{path: "$.order.items[-1].qty", expect: []interface{}{float64(2)}},
+		{path: "$['order']['items'][1]['sku']", expect: []interface{}{"s2"}},
+		{path: "$.order.items[*].sku", multi: true, expect: []interface{}{"s1", "s2"}},
+		{path: "$.order.items.*.qty", multi: true, expect: []interface{}{float64(1), float64(2)}},
+		{path: "$.order.items[2].sku", expect: []interface{}{}},
+		{path: "$.order.missing", expect: []interface{}{}},
+		{path: "/order/items/1/sku", expect: []interface{}{"s2"}},
+		{path: "/order/a~1b", expect: []interface{}{"slash"}},
+	}
+	for _, tcase := range cases {
+		tokens, multi, err := parsePath(tcase.path)
+		assert.NoError(err, "path: "+tcase.path)
+		assert.Equal(tcase.multi, multi, "path: "+tcase.path)
+		assert.Equal(tcase.expect, selectPath(body, tokens), "path: "+tcase.path)
+	}
+}
+
+func TestParsePath_Illegal(t *testing.T) {
+	assert := assert2.New(t)
+	for _, path := range []string{"order.id", "$..id", "$.items[", "$.items[x]", "$order"} {
+		_, _, err := parsePath(path)
+		assert.Error(err, "path: "+path)
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	assert := assert2.New(t)
+	value := map[string]interface{}{
+		"data": map[interface{}]interface{}{
+			"items": []interface{}{map[string]interface{}{"id": 1}, map[string]interface{}{"id": 2}},
+		},
+	}
+	for path, expect := range map[string]interface{}{
+		"":                   value,
+		"$":                  value,
+		"data.items[0].id":   1,
+		"$.data.items.1.id":  2,
+		"/data/items/1/id":   2,
+		"$.data.items[*].id": []interface{}{1, 2},
+	} {
+		v, ok, err := LookupPath(value, path)
+		assert.NoError(err, "path: "+path)
+		assert.True(ok, "path: "+path)
+		assert.Equal(expect, v, "path: "+path)
+	}
+	_, ok, err := LookupPath(value, "$.data.missing")
+	assert.NoError(err)
+	assert.False(ok)
+	_, _, err = LookupPath(value, "$.data[")
+	assert.Error(err)
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/internal"
 	"github.com/labstack/echo/v4"
+	"net/http"
 	"net/http/httptest"
 )
 
@@ -20,3 +21,15 @@ func MockContext(id string) *flux.Context {
 	ctx.Reset(MockWebContext(id), &flux.Endpoint{})
 	return ctx
 }
+
+// MockRequestContext 使用指定的请求及路径参数创建WebContext；用于测试
+func MockRequestContext(id string, request *http.Request, params map[string]string, listener flux.WebListener) flux.ServerWebContext {
+	echoc := mock.NewContext(request, httptest.NewRecorder())
+	names, values := make([]string, 0, len(params)), make([]string, 0, len(params))
+	for name, value := range params {
+		names, values = append(names, name), append(values, value)
+	}
+	echoc.SetParamNames(names...)
+	echoc.SetParamValues(values...)
+	return internal.NewServeWebContext(echoc, id, listener)
+}
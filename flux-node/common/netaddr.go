@@ -0,0 +1,127 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// JoinListenAddress 组合监听地址（host:port）；host 支持IPv4，IPv6（可带方括号，可带Zone），主机名，或为空（监听全部地址，双栈）；
+// host 已包含端口时，忽略 port 参数。
+func JoinListenAddress(host, port string) (string, error) {
+	host = strings.TrimSpace(host)
+	if h, p, err := net.SplitHostPort(host); nil == err {
+		host, port = h, p
+	} else {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if err := ValidateHost(host); nil != err {
+		return "", err
+	}
+	if n, err := strconv.Atoi(strings.TrimSpace(port)); nil != err || n < 0 || n > 65535 {
+		return "", fmt.Errorf("invalid port: %q", port)
+	}
+	return net.JoinHostPort(host, strings.TrimSpace(port)), nil
+}
+
+// ValidateHost 校验主机地址格式：空，IPv4，IPv6（可带Zone），或者主机名
+func ValidateHost(host string) error {
+	if host == "" {
+		return nil
+	}
+	ip := host
+	if pos := strings.IndexByte(ip, '%'); pos > 0 {
+		ip = ip[:pos]
+	}
+	if nil != net.ParseIP(ip) {
+		return nil
+	}
+	if strings.Contains(host, ":") {
+		return fmt.Errorf("invalid ip address: %q", host)
+	}
+	for _, label := range strings.Split(strings.TrimSuffix(host, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return fmt.Errorf("invalid host name: %q", host)
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				return fmt.Errorf("invalid host name: %q", host)
+			}
+		}
+	}
+	return nil
+}
+
+// ClientIP 从RemoteAddr（host:port）中解析客户端IP；支持IPv6地址，IPv4映射的IPv6地址转换为IPv4格式
+func ClientIP(remoteAddr string) string {
+	return flux.ClientIP(remoteAddr)
+}
+
+// IPMatcher 匹配IP地址是否属于IP或CIDR列表；同时支持IPv4和IPv6，IPv4映射的IPv6地址按IPv4匹配
+type IPMatcher []*net.IPNet
+
+// ParseIPMatcher 解析IP或CIDR列表；单个IP地址按 /32（IPv4）或 /128（IPv6）处理
+func ParseIPMatcher(items []string) (IPMatcher, error) {
+	out := make(IPMatcher, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if strings.Contains(item, "/") {
+			_, ipnet, err := net.ParseCIDR(item)
+			if nil != err {
+				return nil, fmt.Errorf("invalid cidr: %q", item)
+			}
+			out = append(out, ipnet)
+			continue
+		}
+		ip := net.ParseIP(item)
+		if nil == ip {
+			return nil, fmt.Errorf("invalid ip address: %q", item)
+		}
+		if v4 := ip.To4(); nil != v4 {
+			out = append(out, &net.IPNet{IP: v4, Mask: net.CIDRMask(32, 32)})
+		} else {
+			out = append(out, &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)})
+		}
+	}
+	return out, nil
+}
+
+// Contains 判断IP地址（可以是 host:port 格式）是否匹配
+func (m IPMatcher) Contains(addr string) bool {
+	ip := net.ParseIP(ClientIP(addr))
+	if nil == ip {
+		return false
+	}
+	for _, ipnet := range m {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	ErrInvalidIPPreference = errors.New("invalid ip preference, must be one of: any, ipv4, ipv6")
+)
+
+const (
+	IPPreferenceAny  = "any"
+	IPPreferenceIPv4 = "ipv4"
+	IPPreferenceIPv6 = "ipv6"
+)
+
+// ValidateIPPreference 校验解析上游地址时的IP版本偏好配置
+func ValidateIPPreference(prefer string) error {
+	switch strings.ToLower(prefer) {
+	case "", IPPreferenceAny, IPPreferenceIPv4, IPPreferenceIPv6:
+		return nil
+	default:
+		return ErrInvalidIPPreference
+	}
+}
@@ -0,0 +1,54 @@
+package common
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestJoinListenAddress(t *testing.T) {
+	assert := assert2.New(t)
+	cases := [][3]string{
+		{"", "8080", ":8080"},
+		{"0.0.0.0", "8080", "0.0.0.0:8080"},
+		{"127.0.0.1:9090", "8080", "127.0.0.1:9090"},
+		{"::", "8080", "[::]:8080"},
+		{"::1", "8080", "[::1]:8080"},
+		{"[::1]", "8080", "[::1]:8080"},
+		{"[::1]:9090", "8080", "[::1]:9090"},
+		{"fe80::1%eth0", "80", "[fe80::1%eth0]:80"},
+		{"localhost", "80", "localhost:80"},
+	}
+	for _, c := range cases {
+		addr, err := JoinListenAddress(c[0], c[1])
+		assert.Nil(err, c[0])
+		assert.Equal(c[2], addr, c[0])
+	}
+	for _, c := range [][2]string{{"1::2::3", "80"}, {"a b", "80"}, {"", ""}, {"", "70000"}} {
+		_, err := JoinListenAddress(c[0], c[1])
+		assert.Error(err, c[0]+":"+c[1])
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	assert := assert2.New(t)
+	assert.Equal("10.0.0.1", ClientIP("10.0.0.1:5678"))
+	assert.Equal("2001:db8::1", ClientIP("[2001:db8::1]:5678"))
+	assert.Equal("2001:db8::1", ClientIP("2001:db8::1"))
+	assert.Equal("10.0.0.1", ClientIP("[::ffff:10.0.0.1]:5678"))
+	assert.Equal("fe80::1", ClientIP("[fe80::1%eth0]:80"))
+}
+
+func TestIPMatcher(t *testing.T) {
+	assert := assert2.New(t)
+	m, err := ParseIPMatcher([]string{"10.0.0.0/8", "2001:db8::/32", "192.168.1.1", "::1"})
+	assert.Nil(err)
+	assert.True(m.Contains("10.1.2.3"))
+	assert.True(m.Contains("[::ffff:10.1.2.3]:80"))
+	assert.True(m.Contains("192.168.1.1:1234"))
+	assert.True(m.Contains("[2001:db8:1::5]:443"))
+	assert.True(m.Contains("::1"))
+	assert.False(m.Contains("192.168.1.2"))
+	assert.False(m.Contains("2001:db9::1"))
+	_, err = ParseIPMatcher([]string{"10.0.0.0/33"})
+	assert.Error(err)
+}
@@ -0,0 +1,248 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	MIMEApplicationXML        = "application/xml"
+	MIMETextXML               = "text/xml"
+	MIMEMultipartForm         = "multipart/form-data"
+	MIMEApplicationProtobuf   = "application/protobuf"
+	MIMEApplicationXProtobuf  = "application/x-protobuf"
+	MIMEApplicationProtobufV2 = "application/vnd.google.protobuf"
+)
+
+func init() {
+	ext.RegisterBodyParser(flux.MIMEApplicationJSON, JSONBodyParser)
+	ext.RegisterBodyParser(flux.MIMEApplicationForm, FormBodyParser)
+	ext.RegisterBodyParser(MIMEMultipartForm, MultipartBodyParser)
+	ext.RegisterBodyParser(MIMEApplicationXML, XMLBodyParser)
+	ext.RegisterBodyParser(MIMETextXML, XMLBodyParser)
+	ext.RegisterBodyParser(MIMEApplicationProtobuf, ProtobufBodyParser)
+	ext.RegisterBodyParser(MIMEApplicationXProtobuf, ProtobufBodyParser)
+	ext.RegisterBodyParser(MIMEApplicationProtobufV2, ProtobufBodyParser)
+}
+
+// JSONBodyParser 解析JSON对象
+func JSONBodyParser(_ string, data []byte) (map[string]interface{}, error) {
+	var hashmap = map[string]interface{}{}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return hashmap, nil
+	}
+	err := ext.JSONUnmarshal(data, &hashmap)
+	return hashmap, err
+}
+
+// FormBodyParser 解析表单数据；与原有的表单转换保持一致，多值参数解析为列表
+func FormBodyParser(_ string, data []byte) (map[string]interface{}, error) {
+	jbs, err := JSONBytesFromQueryString(data)
+	if nil != err {
+		return nil, err
+	}
+	return JSONBodyParser(flux.MIMEApplicationJSON, jbs)
+}
+
+// MultipartBodyParser 解析Multipart表单数据；文件字段解析为文件名、类型和大小信息
+func MultipartBodyParser(contentType string, data []byte) (map[string]interface{}, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if nil != err {
+		return nil, err
+	}
+	boundary := params["boundary"]
+	if boundary == "" {
+		return nil, errors.New("multipart boundary not found, content-type: " + contentType)
+	}
+	reader := multipart.NewReader(bytes.NewReader(data), boundary)
+	values := make(url.Values, 8)
+	files := make(map[string][]interface{}, 2)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		} else if nil != err {
+			return nil, err
+		}
+		content, err := ioutil.ReadAll(part)
+		_ = part.Close()
+		if nil != err {
+			return nil, err
+		}
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+		if part.FileName() == "" {
+			values.Add(name, string(content))
+		} else {
+			files[name] = append(files[name], map[string]interface{}{
+				"filename":    part.FileName(),
+				"contentType": part.Header.Get(flux.HeaderContentType),
+				"size":        len(content),
+			})
+		}
+	}
+	out := valuesToMap(values)
+	for name, list := range files {
+		if len(list) == 1 {
+			out[name] = list[0]
+		} else {
+			out[name] = list
+		}
+	}
+	return out, nil
+}
+
+// XMLBodyParser 解析XML文档为根元素的子元素结构：
+// 属性以 @name 为键；重复元素解析为列表；只包含文本的元素解析为字符串；混合内容的文本以 #text 为键。
+func XMLBodyParser(_ string, data []byte) (map[string]interface{}, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return map[string]interface{}{}, nil
+		} else if nil != err {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			value, err := decodeXMLElement(decoder, start)
+			if nil != err {
+				return nil, err
+			}
+			if hashmap, ok := value.(map[string]interface{}); ok {
+				return hashmap, nil
+			}
+			return map[string]interface{}{"#text": value}, nil
+		}
+	}
+}
+
+func decodeXMLElement(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	children := make(map[string]interface{}, len(start.Attr))
+	for _, attr := range start.Attr {
+		children["@"+attr.Name.Local] = attr.Value
+	}
+	text := new(strings.Builder)
+	for {
+		token, err := decoder.Token()
+		if nil != err {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			value, err := decodeXMLElement(decoder, t)
+			if nil != err {
+				return nil, err
+			}
+			name := t.Name.Local
+			if prev, ok := children[name]; ok {
+				if list, ok := prev.([]interface{}); ok {
+					children[name] = append(list, value)
+				} else {
+					children[name] = []interface{}{prev, value}
+				}
+			} else {
+				children[name] = value
+			}
+		case xml.CharData:
+			text.Write(t)
+		case xml.EndElement:
+			content := strings.TrimSpace(text.String())
+			if len(children) == 0 {
+				return content, nil
+			}
+			if content != "" {
+				children["#text"] = content
+			}
+			return children, nil
+		}
+	}
+}
+
+// ProtobufBodyParser 在没有消息定义的情况下，按Protobuf编码格式解析消息：以字段编号为键；
+// Varint解析为uint64，定长类型解析为uint32/uint64，Length-delimited解析为UTF8字符串或字节数组；重复字段解析为列表。
+// 需要按消息定义解析时，通过 ext.RegisterBodyParser 注册自定义解析函数。
+func ProtobufBodyParser(_ string, data []byte) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, 8)
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, errors.New("invalid protobuf field tag")
+		}
+		data = data[n:]
+		var value interface{}
+		switch wire := tag & 0x7; wire {
+		case 0:
+			v, n := binary.Uvarint(data)
+			if n <= 0 {
+				return nil, errors.New("invalid protobuf varint")
+			}
+			value, data = v, data[n:]
+		case 1:
+			if len(data) < 8 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			value, data = binary.LittleEndian.Uint64(data), data[8:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return nil, errors.New("invalid protobuf length-delimited field")
+			}
+			raw := data[n : n+int(size)]
+			if utf8.Valid(raw) {
+				value = string(raw)
+			} else {
+				value = raw
+			}
+			data = data[n+int(size):]
+		case 5:
+			if len(data) < 4 {
+				return nil, io.ErrUnexpectedEOF
+			}
+			value, data = binary.LittleEndian.Uint32(data), data[4:]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type: %d", wire)
+		}
+		key := strconv.FormatUint(tag>>3, 10)
+		if prev, ok := out[key]; ok {
+			if list, ok := prev.([]interface{}); ok {
+				out[key] = append(list, value)
+			} else {
+				out[key] = []interface{}{prev, value}
+			}
+		} else {
+			out[key] = value
+		}
+	}
+	return out, nil
+}
+
+func valuesToMap(values url.Values) map[string]interface{} {
+	out := make(map[string]interface{}, len(values))
+	for key, list := range values {
+		if len(list) == 1 {
+			out[key] = list[0]
+		} else {
+			copied := make([]interface{}, len(list))
+			for i, v := range list {
+				copied[i] = v
+			}
+			out[key] = copied
+		}
+	}
+	return out
+}
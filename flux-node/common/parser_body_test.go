@@ -0,0 +1,105 @@
+package common
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	assert2 "github.com/stretchr/testify/assert"
+)
+
+func TestBodyParserByType(t *testing.T) {
+	assert := assert2.New(t)
+	for _, ct := range []string{
+		"application/json; charset=UTF-8",
+		"application/problem+json",
+		"application/x-www-form-urlencoded",
+		"multipart/form-data; boundary=abc",
+		"text/xml",
+		"application/soap+xml",
+		"application/x-protobuf",
+	} {
+		_, ok := ext.BodyParserByType(ct)
+		assert.True(ok, "parser must found, content-type: "+ct)
+	}
+	_, ok := ext.BodyParserByType("application/octet-stream")
+	assert.False(ok)
+}
+
+func TestToStringMapE_XML(t *testing.T) {
+	assert := assert2.New(t)
+	xml := `<user id="1"><name>yong</name><tag>a</tag><tag>b</tag></user>`
+	sm, err := ToStringMapE(flux.MTValue{Valid: true, Value: xml, MediaType: "application/xml"})
+	assert.NoError(err)
+	assert.Equal("1", sm["@id"])
+	assert.Equal("yong", sm["name"])
+	assert.Equal([]interface{}{"a", "b"}, sm["tag"])
+}
+
+func TestToStringMapE_Multipart(t *testing.T) {
+	assert := assert2.New(t)
+	buf := new(bytes.Buffer)
+	writer := multipart.NewWriter(buf)
+	_ = writer.WriteField("name", "yong")
+	fw, _ := writer.CreateFormFile("avatar", "a.png")
+	_, _ = fw.Write([]byte("png"))
+	_ = writer.Close()
+	sm, err := ToStringMapE(flux.MTValue{Valid: true, Value: buf, MediaType: writer.FormDataContentType()})
+	assert.NoError(err)
+	assert.Equal("yong", sm["name"])
+	assert.Equal("a.png", sm["avatar"].(map[string]interface{})["filename"])
+	assert.Equal(3, sm["avatar"].(map[string]interface{})["size"])
+}
+
+func TestToStringMapE_Protobuf(t *testing.T) {
+	assert := assert2.New(t)
+	// field 1: varint 150; field 2: string "abc"; field 2: string "de"
+	data := []byte{0x08, 0x96, 0x01, 0x12, 0x03, 'a', 'b', 'c', 0x12, 0x02, 'd', 'e'}
+	sm, err := ToStringMapE(flux.MTValue{Valid: true, Value: data, MediaType: "application/x-protobuf"})
+	assert.NoError(err)
+	assert.Equal(uint64(150), sm["1"])
+	assert.Equal([]interface{}{"abc", "de"}, sm["2"])
+}
+
+func TestToStringMapE_Form(t *testing.T) {
+	assert := assert2.New(t)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	sm, err := ToStringMapE(flux.MTValue{Valid: true, Value: "a=1&b=2&b=3", MediaType: flux.MIMEApplicationForm})
+	assert.NoError(err)
+	assert.Equal("1", sm["a"])
+	assert.Equal([]interface{}{"2", "3"}, sm["b"])
+	sm, err = ToStringMapE(flux.MTValue{Valid: true, Value: `q="x"&q=y`, MediaType: flux.MIMEApplicationForm})
+	assert.NoError(err)
+	assert.Equal([]interface{}{`"x"`, "y"}, sm["q"])
+}
+
+func TestLookupBodyType(t *testing.T) {
+	assert := assert2.New(t)
+	cases := []struct {
+		contentType string
+		bodyType    string
+		expected    string
+	}{
+		{contentType: "application/json", bodyType: "", expected: "application/json"},
+		{contentType: "multipart/form-data; boundary=abc", bodyType: "multipart/form-data", expected: "multipart/form-data; boundary=abc"},
+		// 覆盖媒体类型时，保留请求Content-Type中的参数
+		{contentType: "multipart/mixed; boundary=abc", bodyType: "multipart/form-data", expected: "multipart/form-data; boundary=abc"},
+		{contentType: "text/plain", bodyType: "application/json", expected: "application/json"},
+		{contentType: "", bodyType: "application/xml", expected: "application/xml"},
+	}
+	for _, tc := range cases {
+		request := httptest.NewRequest(http.MethodPost, "http://gateway/upload", nil)
+		request.Header.Set(flux.HeaderContentType, tc.contentType)
+		endpoint := &flux.Endpoint{}
+		if tc.bodyType != "" {
+			endpoint.Attributes = []flux.Attribute{{Name: flux.EndpointAttrTagBodyType, Value: tc.bodyType}}
+		}
+		ctx := flux.NewContext()
+		ctx.Reset(MockRequestContext("bodytype", request, nil, nil), endpoint)
+		assert.Equal(tc.expected, lookupBodyType(ctx), tc.contentType)
+	}
+}
@@ -149,32 +149,20 @@ func ToStringMapE(mtValue flux.MTValue) (map[string]interface{}, error) {
 			return sm, nil
 		}
 	default:
-		var data []byte
-		if strings.Contains(mtValue.MediaType, "application/json") {
-			if bs, err := toByteArray(mtValue.Value); nil != err {
+		// 按媒体类型查找Body解析函数
+		if parser, ok := ext.BodyParserByType(mtValue.MediaType); ok {
+			if data, err := toByteArray(mtValue.Value); nil != err {
 				return nil, err
 			} else {
-				data = bs
-			}
-		} else if strings.Contains(mtValue.MediaType, "application/x-www-form-urlencoded") {
-			if bs, err := toByteArray(mtValue.Value); nil != err {
-				return nil, err
-			} else if jbs, err := JSONBytesFromQueryString(bs); nil != err {
-				return nil, err
-			} else {
-				data = jbs
+				return parser(mtValue.MediaType, data)
 			}
+		}
+		if sm, err := cast.ToStringMapE(mtValue.Value); nil == err {
+			return sm, nil
 		} else {
-			if sm, err := cast.ToStringMapE(mtValue.Value); nil == err {
-				return sm, nil
-			} else {
-				return nil, fmt.Errorf("unsupported mime-type to hashmap, value: %+v, value.type:%T, mime-type: %s",
-					mtValue.Value, mtValue.Value, mtValue.MediaType)
-			}
+			return nil, fmt.Errorf("unsupported mime-type to hashmap, value: %+v, value.type:%T, mime-type: %s",
+				mtValue.Value, mtValue.Value, mtValue.MediaType)
 		}
-		var hashmap = map[string]interface{}{}
-		err := ext.JSONUnmarshal(data, &hashmap)
-		return hashmap, err
 	}
 }
 
@@ -185,14 +173,17 @@ func ToGenericListE(generics []string, mtValue flux.MTValue) (interface{}, error
 		return make([]interface{}, 0), nil
 	}
 	vType := reflect.TypeOf(mtValue.Value)
-	// 没有指定泛型类型
+	kind := vType.Kind()
+	// 没有指定泛型类型：列表值不做转换，其它值包装为单元素列表
 	if len(generics) == 0 {
+		if kind == reflect.Slice || kind == reflect.Array {
+			return mtValue.Value, nil
+		}
 		return []interface{}{mtValue.Value}, nil
 	}
 	// 进行特定泛型类型转换
 	generic := generics[0]
 	resolver := ext.MTValueResolverByType(generic)
-	kind := vType.Kind()
 	if kind == reflect.Slice {
 		vValue := reflect.ValueOf(mtValue.Value)
 		out := make([]interface{}, vValue.Len())
@@ -4,7 +4,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/bytepowered/flux/flux-node"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -221,3 +224,28 @@ func TestCastToStringMap_Object2(t *testing.T) {
 	assert.Equal(1, sm["a"])
 	assert.Equal("c", sm["b"])
 }
+
+func TestListResolver_BodyPath(t *testing.T) {
+	assert := assert2.New(t)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	body := `{"items":[{"sku":"s1"},{"sku":"s2"}]}`
+	request := httptest.NewRequest(http.MethodPost, "http://gateway/orders", strings.NewReader(body))
+	request.Header.Set(flux.HeaderContentType, flux.MIMEApplicationJSON)
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(body)), nil
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(MockRequestContext("list", request, nil, nil), &flux.Endpoint{})
+	value, err := LookupMTValue(flux.ScopeBody, "$.items[*].sku", ctx)
+	assert.NoError(err)
+	// 未指定泛型类型的列表参数，不嵌套包装列表值
+	list, err := ext.MTValueResolverByType(flux.JavaUtilListClassName)(value, flux.JavaUtilListClassName, nil)
+	assert.NoError(err)
+	assert.Equal([]interface{}{"s1", "s2"}, list)
+	list, err = ext.MTValueResolverByType(flux.JavaUtilListClassName)(value, flux.JavaUtilListClassName, GenericTypeString)
+	assert.NoError(err)
+	assert.Equal([]interface{}{"s1", "s2"}, list)
+	list, err = ToGenericListE(nil, flux.WrapStringMTValue("s1"))
+	assert.NoError(err)
+	assert.Equal([]interface{}{"s1"}, list)
+}
@@ -0,0 +1,11 @@
+package flux
+
+import "io"
+
+const (
+	// Endpoint属性：响应压缩开关；off/false 关闭此Endpoint的响应压缩
+	EndpointAttrTagCompress = "compress"
+)
+
+// ResponseEncoderFactory 创建响应数据的压缩编码Writer；Close时输出全部剩余的编码数据
+type ResponseEncoderFactory func(w io.Writer) (io.WriteCloser, error)
@@ -1,11 +1,16 @@
 package flux
 
 import (
+	"bytes"
+	"fmt"
 	"github.com/spf13/cast"
 	"github.com/spf13/viper"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
 	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -78,27 +83,25 @@ func (c *Configuration) Get(key string) interface{} {
 // 与Viper的Alias不同的是，Configuration的GlobalAlias是作用于局部命名空间下的别名映射。
 // 当然，这不影响原有Viper的Alias功能。
 func (c *Configuration) GetOrDefault(key string, def interface{}) interface{} {
+	v, err := c.getOrDefaultE(key, def)
+	if nil != err {
+		if handler := getDynamicValueErrorHandler(); nil != handler {
+			handler(key, err)
+		}
+	}
+	return v
+}
+
+func (c *Configuration) getOrDefaultE(key string, def interface{}) (interface{}, error) {
 	v := c.instance.Get(key)
 	// 动态全局Key和默认值： ${username:yongjia}
 	if strv, ok := v.(string); ok {
 		dkey, defv, typ := ParseDynamicKey(strv)
-		switch typ {
-		case DynamicTypeConfig:
-			if viper.IsSet(dkey) {
-				return viper.Get(dkey)
-			} else {
-				return defv
-			}
-		case DynamicTypeEnv:
-			if ev, ok := os.LookupEnv(dkey); ok {
-				return ev
-			} else {
-				return defv
-			}
-
-		default:
-			return v
+		if typ != DynamicTypeValue {
+			return LookupDynamicValue(dkey, defv, typ)
 		}
+		// 字符串内嵌的占位符：jdbc://#{DB_USER}:${$file:/run/secrets/db}@host
+		return ExpandDynamicValuesE(strv)
 	}
 	// GlobalAlias优先级低一些
 	if nil == v && c.globalAlias != nil {
@@ -109,7 +112,7 @@ func (c *Configuration) GetOrDefault(key string, def interface{}) interface{} {
 	if nil == v {
 		v = def
 	}
-	return v
+	return v, nil
 }
 
 // Set 向当前配置实例以覆盖的方式设置Key-Value键值。
@@ -155,6 +158,16 @@ func (c *Configuration) GetString(key string) string {
 	return cast.ToString(c.Get(key))
 }
 
+// GetStringE 读取字符串配置值；占位符 ${$file:/path}，${$secret:path#field} 读取失败时返回错误。
+// 密钥等不可缺省的配置使用此函数读取，避免读取失败时以空值运行。
+func (c *Configuration) GetStringE(key string) (string, error) {
+	v, err := c.getOrDefaultE(key, nil)
+	if nil != err {
+		return "", fmt.Errorf("config(%s): %w", key, err)
+	}
+	return cast.ToString(v), nil
+}
+
 // GetBool returns the value associated with the key as a boolean.
 func (c *Configuration) GetBool(key string) bool {
 	return cast.ToBool(c.Get(key))
@@ -248,13 +261,44 @@ func (c *Configuration) GetConfigurationSlice(key string) []*Configuration {
 	return out
 }
 
+var (
+	dynamicValueErrorHandler   func(key string, err error)
+	dynamicValueErrorHandlerMu sync.RWMutex
+)
+
+// SetDynamicValueErrorHandler 设置占位符 ${$file:/path}，${$secret:path#field} 读取失败时的通知函数，通常用于输出日志；
+// 读取失败的占位符替换为空值，需要在读取失败时中止处理的组件，使用 GetStringE 函数读取配置。
+func SetDynamicValueErrorHandler(handler func(key string, err error)) {
+	dynamicValueErrorHandlerMu.Lock()
+	defer dynamicValueErrorHandlerMu.Unlock()
+	dynamicValueErrorHandler = handler
+}
+
+func getDynamicValueErrorHandler() func(key string, err error) {
+	dynamicValueErrorHandlerMu.RLock()
+	defer dynamicValueErrorHandlerMu.RUnlock()
+	return dynamicValueErrorHandler
+}
+
 const (
 	DynamicTypeValue  = 0
 	DynamicTypeConfig = 1
 	DynamicTypeEnv    = 2
+	DynamicTypeFile   = 3
+	DynamicTypeSecret = 4
 )
 
-// 解析动态值：配置参数：${key:defaultV}，环境变量：#{key:defaultV}
+const (
+	// 动态值的来源以 $ 前缀声明，与配置参数的Key区分：${$env:NAME}，${$file:/path}，${$secret:path#field}
+	DynamicSourcePrefix = "$"
+	DynamicSourceEnv    = "env"
+	DynamicSourceFile   = "file"
+	DynamicSourceSecret = "secret"
+)
+
+// 解析动态值；配置值与Endpoint元数据模板使用同一语法：
+// 配置参数：${key:defaultV}；环境变量：#{key:defaultV}，${$env:key:defaultV}；
+// 文件内容：${$file:/path}；密钥：${$secret:path#field}。
 func ParseDynamicKey(pattern string) (key string, def string, typ int) {
 	pattern = strings.TrimSpace(pattern)
 	size := len(pattern)
@@ -265,17 +309,188 @@ func ParseDynamicKey(pattern string) (key string, def string, typ int) {
 	env := "#{" == pattern[:2]
 	if (dyn || env) && '}' == pattern[size-1] {
 		values := strings.TrimSpace(pattern[2 : size-1])
-		idx := strings.IndexByte(values, ':')
-		key = values
-		if idx > 0 {
-			key = values[:idx]
-			def = values[idx+1:]
-		}
 		if env {
+			key, def = splitDynamicKey(values)
 			return key, def, DynamicTypeEnv
-		} else {
-			return key, def, DynamicTypeConfig
 		}
+		if strings.HasPrefix(values, DynamicSourcePrefix) {
+			source, ref := splitDynamicKey(values[len(DynamicSourcePrefix):])
+			if ref != "" {
+				switch source {
+				case DynamicSourceFile:
+					return ref, "", DynamicTypeFile
+				case DynamicSourceSecret:
+					return ref, "", DynamicTypeSecret
+				case DynamicSourceEnv:
+					key, def = splitDynamicKey(ref)
+					return key, def, DynamicTypeEnv
+				}
+			}
+		}
+		key, def = splitDynamicKey(values)
+		return key, def, DynamicTypeConfig
 	}
 	return pattern, "", DynamicTypeValue
 }
+
+func splitDynamicKey(values string) (key string, def string) {
+	if idx := strings.IndexByte(values, ':'); idx > 0 {
+		return values[:idx], values[idx+1:]
+	}
+	return values, ""
+}
+
+// LookupDynamicValue 读取动态值；配置参数及环境变量不存在时返回默认值；
+// ${$file:/path} 读取文件内容（去除首尾空白），用于挂载的密钥文件；每次读取配置时查找，不缓存；
+// ${$secret:path#field} 从密钥提供者读取，由提供者负责缓存及续期；文件及密钥读取失败时返回错误。
+func LookupDynamicValue(key, def string, typ int) (interface{}, error) {
+	switch typ {
+	case DynamicTypeConfig:
+		if viper.IsSet(key) {
+			return viper.Get(key), nil
+		}
+		return def, nil
+	case DynamicTypeEnv:
+		if ev, ok := os.LookupEnv(key); ok {
+			return ev, nil
+		}
+		return def, nil
+	case DynamicTypeFile:
+		data, err := ioutil.ReadFile(key)
+		if nil != err {
+			return "", fmt.Errorf("read placeholder file: %w", err)
+		}
+		return strings.TrimSpace(string(data)), nil
+	case DynamicTypeSecret:
+		return LookupSecret(key)
+	default:
+		return key, nil
+	}
+}
+
+// ExpandDynamicValues 替换字符串中内嵌的全部 ${...}，#{...} 占位符；未闭合的占位符保留原文；读取失败的占位符替换为空值
+func ExpandDynamicValues(value string) string {
+	out, _ := ReplaceDynamicValues(value, func(_, key, def string, typ int) (string, error) {
+		v, _ := LookupDynamicValue(key, def, typ)
+		return cast.ToString(v), nil
+	})
+	return out
+}
+
+// ExpandDynamicValuesE 替换字符串中内嵌的全部 ${...}，#{...} 占位符；文件及密钥读取失败时返回错误
+func ExpandDynamicValuesE(value string) (string, error) {
+	return ReplaceDynamicValues(value, func(_, key, def string, typ int) (string, error) {
+		v, err := LookupDynamicValue(key, def, typ)
+		return cast.ToString(v), err
+	})
+}
+
+// ReplaceDynamicValues 以 lookup 函数的返回值，替换字符串中内嵌的全部 ${...}，#{...} 占位符；未闭合的占位符保留原文；
+// lookup 函数的参数 pattern 为占位符原文；lookup 返回错误时停止替换，并返回此错误。
+func ReplaceDynamicValues(value string, lookup func(pattern, key, def string, typ int) (string, error)) (string, error) {
+	if !strings.Contains(value, "${") && !strings.Contains(value, "#{") {
+		return value, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(value); {
+		if i+1 < len(value) && (value[i] == '$' || value[i] == '#') && value[i+1] == '{' {
+			if end := strings.IndexByte(value[i:], '}'); end > 0 {
+				pattern := value[i : i+end+1]
+				if key, def, typ := ParseDynamicKey(pattern); typ != DynamicTypeValue {
+					v, err := lookup(pattern, key, def, typ)
+					if nil != err {
+						return "", fmt.Errorf("placeholder: %s, %w", pattern, err)
+					}
+					sb.WriteString(v)
+					i += end + 1
+					continue
+				}
+			}
+		}
+		sb.WriteByte(value[i])
+		i++
+	}
+	return sb.String(), nil
+}
+
+var (
+	// 串行化全局配置的重新读取与远程配置的合并，避免并发修改全局配置时的数据竞争
+	configmu sync.Mutex
+	// 最近一次加载的远程配置；重新读取配置文件后按顺序重新合并
+	remoteConfigs []map[string]interface{}
+)
+
+// ReadInConfig 重新读取全局配置文件，并重新合并远程配置；配置文件监听与手动触发的重新读取串行执行
+func ReadInConfig() error {
+	configmu.Lock()
+	defer configmu.Unlock()
+	return readInConfig()
+}
+
+// SetRemoteConfig 以远程配置中心的全部配置替换此前加载的远程配置：重新读取本地配置文件后，按顺序合并远程配置，后者覆盖前者；
+// 远程配置中已删除的键，不再保留在全局配置中。
+func SetRemoteConfig(configs ...map[string]interface{}) error {
+	configmu.Lock()
+	defer configmu.Unlock()
+	remoteConfigs = configs
+	return readInConfig()
+}
+
+func readInConfig() error {
+	var err error
+	if viper.ConfigFileUsed() != "" {
+		err = viper.ReadInConfig()
+	} else {
+		// 没有本地配置文件，清空已合并的配置
+		err = viper.ReadConfig(bytes.NewReader(nil))
+	}
+	if nil != err {
+		return err
+	}
+	for _, remote := range remoteConfigs {
+		// 合并时会修改并引用传入的Map，使用副本保留原始的远程配置
+		if err := viper.MergeConfigMap(copySettings(remote)); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseRemoteConfig 解析远程配置中心的配置数据；format 为Viper支持的格式：yaml，json，toml，properties等
+func ParseRemoteConfig(data []byte, format string) (map[string]interface{}, error) {
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(bytes.NewReader(data)); nil != err {
+		return nil, err
+	}
+	return v.AllSettings(), nil
+}
+
+// ParseRemoteConfigValues 解析远程配置中心的键值配置（键以 . 分隔层级）；
+// 值按YAML标量解析为数值，布尔等类型，与本地配置的值类型保持一致（Viper不合并类型不一致的值）。
+func ParseRemoteConfigValues(values map[string]string) map[string]interface{} {
+	v := viper.New()
+	for key, value := range values {
+		var typed interface{}
+		if err := yaml.Unmarshal([]byte(value), &typed); nil != err || nil == typed {
+			typed = value
+		}
+		if _, ok := typed.(string); !ok && strings.ContainsAny(value, "{}[]:\n") {
+			// 只转换标量值，复合结构保留原始字符串
+			typed = value
+		}
+		v.Set(key, typed)
+	}
+	return v.AllSettings()
+}
+
+func copySettings(settings map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(settings))
+	for key, value := range settings {
+		if sub, ok := value.(map[string]interface{}); ok {
+			value = copySettings(sub)
+		}
+		out[key] = value
+	}
+	return out
+}
@@ -1,6 +1,8 @@
 package flux
 
 import (
+	"context"
+	"crypto/x509"
 	"go.uber.org/zap"
 	"time"
 )
@@ -12,20 +14,32 @@ const (
 	XRequestAgent = "X-Request-Agent"
 )
 
+// ResponseHookFunc 后端服务响应数据解析完成后、写入客户端之前的处理函数
+type ResponseHookFunc func(ctx *Context, response *ResponseBody) error
+
+// WriteHookFunc 响应数据序列化完成后、写入客户端之前的处理函数；可修改响应Header，返回实际写入的数据。
+// 用于响应数据签名，添加水印，记录实际输出的数据等。
+type WriteHookFunc func(ctx *Context, status int, body []byte) ([]byte, error)
+
 // Context 定义每个请求的上下文环境
 type Context struct {
 	ServerWebContext
-	endpoint   *Endpoint
-	attributes map[string]interface{}
-	metrics    []Metric
-	startTime  time.Time
-	ctxLogger  Logger
+	endpoint      *Endpoint
+	attributes    map[string]interface{}
+	metrics       []Metric
+	responseHooks []ResponseHookFunc
+	writeHooks    []WriteHookFunc
+	deadlineCtx   context.Context
+	startTime     time.Time
+	ctxLogger     Logger
 }
 
 func NewContext() *Context {
 	return &Context{
-		attributes: make(map[string]interface{}, 16),
-		metrics:    make([]Metric, 0, 16),
+		attributes:    make(map[string]interface{}, 16),
+		metrics:       make([]Metric, 0, 16),
+		responseHooks: make([]ResponseHookFunc, 0, 2),
+		writeHooks:    make([]WriteHookFunc, 0, 2),
 	}
 }
 
@@ -35,6 +49,9 @@ func (c *Context) Reset(webex ServerWebContext, endpoint *Endpoint) {
 	c.ctxLogger = zap.S()
 	c.startTime = time.Now()
 	c.metrics = c.metrics[:0]
+	c.responseHooks = c.responseHooks[:0]
+	c.writeHooks = c.writeHooks[:0]
+	c.deadlineCtx = nil
 	for k := range c.attributes {
 		delete(c.attributes, k)
 	}
@@ -103,6 +120,55 @@ func (c *Context) SetAttribute(key string, value interface{}) {
 	c.attributes[key] = value
 }
 
+// Context 返回请求的Context；设置请求超时后，返回带有截止时间的Context
+func (c *Context) Context() context.Context {
+	if nil != c.deadlineCtx {
+		return c.deadlineCtx
+	}
+	return c.ServerWebContext.Context()
+}
+
+// WithTimeout 设置请求超时时间，超时后请求的Context被取消；调用方必须执行返回的取消函数
+func (c *Context) WithTimeout(timeout time.Duration) context.CancelFunc {
+	ctx, cancel := context.WithTimeout(c.Context(), timeout)
+	c.deadlineCtx = ctx
+	return cancel
+}
+
+// WithScopedTimeout 设置仅在当前执行范围内有效的超时时间；执行返回的恢复函数后，取消此超时并恢复原来的Context；
+// 恢复函数可重复执行。
+func (c *Context) WithScopedTimeout(timeout time.Duration) (restore func()) {
+	parent := c.deadlineCtx
+	ctx, cancel := context.WithTimeout(c.Context(), timeout)
+	c.deadlineCtx = ctx
+	restored := false
+	return func() {
+		if !restored {
+			restored = true
+			cancel()
+			c.deadlineCtx = parent
+		}
+	}
+}
+
+// RemainingTimeout 返回请求剩余的超时时间；未设置截止时间时返回false
+func (c *Context) RemainingTimeout() (time.Duration, bool) {
+	deadline, ok := c.Context().Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// ClientCertificate 返回已验证的客户端证书（mTLS）；非TLS请求或客户端未提供证书时返回false
+func (c *Context) ClientCertificate() (*x509.Certificate, bool) {
+	state := c.Request().TLS
+	if nil == state || len(state.PeerCertificates) == 0 {
+		return nil, false
+	}
+	return state.PeerCertificates[0], true
+}
+
 // StartAt 返回Http请求起始的服务器时间
 func (c *Context) StartAt() time.Time {
 	return c.startTime
@@ -122,6 +188,26 @@ func (c *Context) Metrics() []Metric {
 	return dist
 }
 
+// AddResponseHook 添加请求范围的响应处理函数；按添加顺序执行
+func (c *Context) AddResponseHook(hook ResponseHookFunc) {
+	c.responseHooks = append(c.responseHooks, hook)
+}
+
+// ResponseHooks 返回请求范围的响应处理函数列表
+func (c *Context) ResponseHooks() []ResponseHookFunc {
+	return c.responseHooks
+}
+
+// AddWriteHook 添加请求范围的响应写入处理函数；按添加顺序执行
+func (c *Context) AddWriteHook(hook WriteHookFunc) {
+	c.writeHooks = append(c.writeHooks, hook)
+}
+
+// WriteHooks 返回请求范围的响应写入处理函数列表
+func (c *Context) WriteHooks() []WriteHookFunc {
+	return c.writeHooks
+}
+
 // GetLogger 添加Context范围的Logger。
 // 通常是将关联一些追踪字段的Logger设置为ContextLogger
 func (c *Context) SetLogger(logger Logger) {
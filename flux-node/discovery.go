@@ -14,3 +14,10 @@ type EndpointDiscovery interface {
 	// WatchServices 监听TransporterService注册事件
 	WatchServices(ctx context.Context, events chan<- ServiceEvent) error
 }
+
+// EndpointDiscoverySyncer 可选接口，用于通知首次全量数据已发送完成；
+// 未实现此接口的注册中心，在 WatchEndpoints/WatchServices 返回时，视为已完成首次同步。
+type EndpointDiscoverySyncer interface {
+	// Synced 返回首次全量数据发送完成的通知通道
+	Synced() <-chan struct{}
+}
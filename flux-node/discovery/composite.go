@@ -0,0 +1,270 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"strings"
+	"sync"
+)
+
+const (
+	CompositeId = "composite"
+)
+
+const (
+	compositeConfigPriorities = "priorities"
+)
+
+var _ flux.EndpointDiscovery = new(CompositeDiscoveryService)
+var _ flux.EndpointDiscoverySyncer = new(CompositeDiscoveryService)
+var _ flux.HealthIndicator = new(CompositeDiscoveryService)
+
+type (
+	// CompositeOption 配置函数
+	CompositeOption func(discovery *CompositeDiscoveryService)
+)
+
+// CompositeDiscoveryService 组合多个注册中心，合并其事件流。
+// 多个注册中心存在相同的Endpoint(Method+Pattern+Version)或Service(ServiceId)时，以优先级高的注册中心数据为准；
+// 优先级相同时，按注册中心ID排序选择。事件的Source字段标记实际生效数据的注册中心ID。
+// 注意：被组合的注册中心不应再通过 ext.RegisterEndpointDiscovery 单独注册。
+type CompositeDiscoveryService struct {
+	id         string
+	sources    []flux.EndpointDiscovery
+	priorities map[string]int
+	endpoints  *compositeTable
+	services   *compositeTable
+	synced     *syncNotifier
+}
+
+// WithCompositeSource 添加被组合的注册中心，并指定其优先级（数值越大越优先）
+func WithCompositeSource(source flux.EndpointDiscovery, priority int) CompositeOption {
+	return func(discovery *CompositeDiscoveryService) {
+		discovery.sources = append(discovery.sources, source)
+		discovery.priorities[source.Id()] = priority
+	}
+}
+
+// NewCompositeServiceWith returns new a composite discovery service
+func NewCompositeServiceWith(id string, opts ...CompositeOption) *CompositeDiscoveryService {
+	r := &CompositeDiscoveryService{
+		id:         id,
+		sources:    make([]flux.EndpointDiscovery, 0, 4),
+		priorities: make(map[string]int, 4),
+		synced:     newSyncNotifier(2),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	r.endpoints = newCompositeTable(r.priorityOf)
+	r.services = newCompositeTable(r.priorityOf)
+	return r
+}
+
+func (r *CompositeDiscoveryService) Id() string {
+	return r.id
+}
+
+// Synced 返回全部被组合的注册中心首次同步完成的通知通道
+func (r *CompositeDiscoveryService) Synced() <-chan struct{} {
+	return r.synced.Synced()
+}
+
+// Init 初始化被组合的注册中心；配置 priorities 可覆盖各注册中心的优先级
+func (r *CompositeDiscoveryService) Init(config *flux.Configuration) error {
+	for sid, priority := range config.GetStringMap(compositeConfigPriorities) {
+		r.priorities[sid] = cast.ToInt(priority)
+	}
+	for _, source := range r.sources {
+		logger.Infow("CompositeEndpointDiscovery init source",
+			"source-id", source.Id(), "priority", r.priorityOf(source.Id()))
+		if init, ok := source.(flux.Initializer); ok {
+			ns := flux.NamespaceEndpointDiscoveryServices + "." + source.Id()
+			if err := init.Init(flux.NewConfigurationOfNS(ns)); nil != err {
+				return fmt.Errorf("composite discovery init source: %s, err: %w", source.Id(), err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *CompositeDiscoveryService) Startup() error {
+	for _, source := range r.sources {
+		if startup, ok := source.(flux.Startuper); ok {
+			if err := startup.Startup(); nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (r *CompositeDiscoveryService) Shutdown(ctx context.Context) error {
+	for _, source := range r.sources {
+		if shutdown, ok := source.(flux.Shutdowner); ok {
+			if err := shutdown.Shutdown(ctx); nil != err {
+				logger.Warnw("CompositeEndpointDiscovery shutdown source", "source-id", source.Id(), "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+func (r *CompositeDiscoveryService) HealthId() string {
+	return "discovery:" + r.id
+}
+
+// Health 汇总被组合的注册中心的健康状态；未实现 HealthIndicator 的注册中心视为健康
+func (r *CompositeDiscoveryService) Health(ctx context.Context) flux.Health {
+	details := make(map[string]interface{}, len(r.sources))
+	up := true
+	for _, source := range r.sources {
+		indicator, ok := source.(flux.HealthIndicator)
+		if !ok {
+			details[source.Id()] = flux.NewHealthUp(nil)
+			continue
+		}
+		health := indicator.Health(ctx)
+		details[source.Id()] = health
+		up = up && health.IsUp()
+	}
+	if up {
+		return flux.NewHealthUp(details)
+	}
+	return flux.NewHealthDown(details)
+}
+
+func (r *CompositeDiscoveryService) WatchEndpoints(ctx context.Context, events chan<- flux.EndpointEvent) error {
+	sources := newSyncNotifier(len(r.sources))
+	r.synced.markSyncedOn(ctx, sources.Synced())
+	for _, source := range r.sources {
+		sid := source.Id()
+		in := make(chan flux.EndpointEvent, 4)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case evt := <-in:
+					ep := evt.Endpoint
+					key := fmt.Sprintf("%s#%s#%s", strings.ToUpper(ep.HttpMethod), ep.HttpPattern, ep.Version)
+					if etype, value, winner, ok := r.endpoints.resolve(key, sid, evt.EventType, ep); ok {
+						select {
+						case events <- flux.EndpointEvent{EventType: etype, Endpoint: value.(flux.Endpoint), Source: winner}:
+						case <-ctx.Done():
+							return
+						}
+					} else {
+						logger.Infow("DISCOVERY:COMPOSITE:ENDPOINT:SHADOWED", "source-id", sid, "key", key)
+					}
+				}
+			}
+		}()
+		if err := source.WatchEndpoints(ctx, in); nil != err {
+			return fmt.Errorf("composite discovery watch endpoints, source: %s, err: %w", sid, err)
+		}
+		sources.markSyncedOn(ctx, syncedOf(source))
+	}
+	return nil
+}
+
+func (r *CompositeDiscoveryService) WatchServices(ctx context.Context, events chan<- flux.ServiceEvent) error {
+	sources := newSyncNotifier(len(r.sources))
+	r.synced.markSyncedOn(ctx, sources.Synced())
+	for _, source := range r.sources {
+		sid := source.Id()
+		in := make(chan flux.ServiceEvent, 4)
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case evt := <-in:
+					key := evt.Service.ServiceId
+					if etype, value, winner, ok := r.services.resolve(key, sid, evt.EventType, evt.Service); ok {
+						select {
+						case events <- flux.ServiceEvent{EventType: etype, Service: value.(flux.TransporterService), Source: winner}:
+						case <-ctx.Done():
+							return
+						}
+					} else {
+						logger.Infow("DISCOVERY:COMPOSITE:SERVICE:SHADOWED", "source-id", sid, "key", key)
+					}
+				}
+			}
+		}()
+		if err := source.WatchServices(ctx, in); nil != err {
+			return fmt.Errorf("composite discovery watch services, source: %s, err: %w", sid, err)
+		}
+		sources.markSyncedOn(ctx, syncedOf(source))
+	}
+	return nil
+}
+
+func (r *CompositeDiscoveryService) priorityOf(sourceId string) int {
+	return r.priorities[sourceId]
+}
+
+// compositeTable 记录各注册中心的数据，并按优先级选择生效的数据
+type compositeTable struct {
+	entries  map[string]map[string]interface{} // key -> source -> value
+	priority func(sourceId string) int
+	mu       sync.Mutex
+}
+
+func newCompositeTable(priority func(string) int) *compositeTable {
+	return &compositeTable{
+		entries:  make(map[string]map[string]interface{}, 16),
+		priority: priority,
+	}
+}
+
+// resolve 更新来源数据，返回需要发送的事件；ok为false时，表示事件被高优先级的来源覆盖，无需发送。
+func (t *compositeTable) resolve(key, source string, etype flux.EventType, value interface{}) (flux.EventType, interface{}, string, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	values, ok := t.entries[key]
+	if !ok {
+		values = make(map[string]interface{}, 2)
+		t.entries[key] = values
+	}
+	prev := t.winner(values)
+	if etype == flux.EventTypeRemoved {
+		delete(values, source)
+	} else {
+		values[source] = value
+	}
+	next := t.winner(values)
+	switch {
+	case next == "" && prev == "":
+		delete(t.entries, key)
+		return etype, nil, "", false
+	case next == "":
+		delete(t.entries, key)
+		return flux.EventTypeRemoved, value, source, true
+	case next != source && next == prev:
+		return etype, nil, "", false
+	case prev == "":
+		return flux.EventTypeAdded, values[next], next, true
+	default:
+		return flux.EventTypeUpdated, values[next], next, true
+	}
+}
+
+func (t *compositeTable) winner(values map[string]interface{}) string {
+	winner := ""
+	for sid := range values {
+		if winner == "" {
+			winner = sid
+			continue
+		}
+		p, wp := t.priority(sid), t.priority(winner)
+		if p > wp || (p == wp && sid < winner) {
+			winner = sid
+		}
+	}
+	return winner
+}
@@ -0,0 +1,116 @@
+package discovery
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// staticDiscovery 不发送事件的注册中心
+type staticDiscovery struct {
+	id string
+}
+
+func (d *staticDiscovery) Id() string {
+	return d.id
+}
+
+func (d *staticDiscovery) WatchEndpoints(_ context.Context, _ chan<- flux.EndpointEvent) error {
+	return nil
+}
+
+func (d *staticDiscovery) WatchServices(_ context.Context, _ chan<- flux.ServiceEvent) error {
+	return nil
+}
+
+// syncingDiscovery 由测试控制首次同步完成时机的注册中心
+type syncingDiscovery struct {
+	staticDiscovery
+	synced *syncNotifier
+}
+
+func (d *syncingDiscovery) Synced() <-chan struct{} {
+	return d.synced.Synced()
+}
+
+func TestCompositeDiscoveryService_Synced(t *testing.T) {
+	assert := assert2.New(t)
+	remote := &syncingDiscovery{staticDiscovery: staticDiscovery{id: "remote"}, synced: newSyncNotifier(1)}
+	// 未实现 EndpointDiscoverySyncer 接口的注册中心，Watch返回后视为已同步
+	local := &staticDiscovery{id: "local"}
+	composite := NewCompositeServiceWith(CompositeId, WithCompositeSource(remote, 0), WithCompositeSource(local, 10))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(composite.WatchEndpoints(ctx, make(chan flux.EndpointEvent, 4)))
+	assert.NoError(composite.WatchServices(ctx, make(chan flux.ServiceEvent, 4)))
+	select {
+	case <-composite.Synced():
+		assert.Fail("synced before remote source synced")
+	case <-time.After(time.Millisecond * 20):
+	}
+	remote.synced.markSynced()
+	select {
+	case <-composite.Synced():
+	case <-time.After(time.Second):
+		assert.Fail("not synced after all sources synced")
+	}
+}
+
+func TestCompositeTable_Resolve(t *testing.T) {
+	assert := assert2.New(t)
+	priorities := map[string]int{"zookeeper": 0, "filesystem": 10}
+	table := newCompositeTable(func(sid string) int {
+		return priorities[sid]
+	})
+	// zk add
+	etype, value, winner, ok := table.resolve("k", "zookeeper", flux.EventTypeAdded, "zk-v1")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeAdded), etype)
+	assert.Equal("zk-v1", value)
+	assert.Equal("zookeeper", winner)
+	// fs override
+	etype, value, winner, ok = table.resolve("k", "filesystem", flux.EventTypeAdded, "fs-v1")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("fs-v1", value)
+	assert.Equal("filesystem", winner)
+	// zk update shadowed
+	_, _, _, ok = table.resolve("k", "zookeeper", flux.EventTypeUpdated, "zk-v2")
+	assert.False(ok)
+	// fs remove, fallback to zk
+	etype, value, winner, ok = table.resolve("k", "filesystem", flux.EventTypeRemoved, "fs-v1")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeUpdated), etype)
+	assert.Equal("zk-v2", value)
+	assert.Equal("zookeeper", winner)
+	// zk remove
+	etype, _, winner, ok = table.resolve("k", "zookeeper", flux.EventTypeRemoved, "zk-v2")
+	assert.True(ok)
+	assert.Equal(flux.EventType(flux.EventTypeRemoved), etype)
+	assert.Equal("zookeeper", winner)
+}
+
+func TestCompositeDiscoveryService_Health(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "flux-discovery")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	local := NewFilesystemServiceWith("local", WithFilesystemDirectory(dir))
+	missing := NewFilesystemServiceWith("missing", WithFilesystemDirectory(filepath.Join(dir, "missing")))
+	// 未实现 HealthIndicator 的注册中心视为健康
+	static := &staticDiscovery{id: "static"}
+	composite := NewCompositeServiceWith(CompositeId, WithCompositeSource(local, 0), WithCompositeSource(static, 0))
+	assert.Equal("discovery:composite", composite.HealthId())
+	assert.True(composite.Health(context.Background()).IsUp())
+	// 任意一个注册中心非健康时，组合注册中心为非健康状态
+	composite = NewCompositeServiceWith(CompositeId, WithCompositeSource(local, 0), WithCompositeSource(missing, 0))
+	health := composite.Health(context.Background())
+	assert.False(health.IsUp())
+	assert.True(health.Details["local"].(flux.Health).IsUp())
+	assert.False(health.Details["missing"].(flux.Health).IsUp())
+}
@@ -0,0 +1,255 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	FilesystemId = "filesystem"
+)
+
+const (
+	filesystemConfigDirectory = "directory"
+)
+
+var _ flux.EndpointDiscovery = new(FilesystemDiscoveryService)
+var _ flux.EndpointDiscoverySyncer = new(FilesystemDiscoveryService)
+var _ flux.HealthIndicator = new(FilesystemDiscoveryService)
+
+type (
+	// FilesystemOption 配置函数
+	FilesystemOption func(discovery *FilesystemDiscoveryService)
+	// filesystemApplyFunc 处理单个文件的全量数据；文件被删除时，数据为空；
+	filesystemApplyFunc func(file string, res Resources)
+	// FilesystemLoader 加载单个文件并转换为Endpoint及Service元数据
+	FilesystemLoader func(file string) (Resources, error)
+)
+
+// FilesystemDiscoveryService 基于本地目录的Endpoint元数据注册中心，主要用于本地开发调试。
+// 加载目录下的JSON/YAML文件（格式与Resource一致），并通过fsnotify监听文件变更，发送Add/Update/Remove事件。
+// 注意：FilesystemDiscoveryService 默认不注册，需要通过 ext.RegisterEndpointDiscovery 手动注册。
+type FilesystemDiscoveryService struct {
+	id        string
+	directory string
+	loader    FilesystemLoader
+	synced    *syncNotifier
+}
+
+// WithFilesystemDirectory 配置加载的文件目录
+func WithFilesystemDirectory(dir string) FilesystemOption {
+	return func(discovery *FilesystemDiscoveryService) {
+		discovery.directory = dir
+	}
+}
+
+// WithFilesystemLoader 配置文件加载函数；默认加载与Resource格式一致的JSON/YAML文件
+func WithFilesystemLoader(loader FilesystemLoader) FilesystemOption {
+	return func(discovery *FilesystemDiscoveryService) {
+		discovery.loader = loader
+	}
+}
+
+// NewFilesystemServiceWith returns new a filesystem based discovery service
+func NewFilesystemServiceWith(id string, opts ...FilesystemOption) *FilesystemDiscoveryService {
+	r := &FilesystemDiscoveryService{
+		id:     id,
+		loader: loadResourceFile,
+		synced: newSyncNotifier(2),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *FilesystemDiscoveryService) Id() string {
+	return r.id
+}
+
+// Synced 返回Endpoint及Service首次加载目录文件完成的通知通道
+func (r *FilesystemDiscoveryService) Synced() <-chan struct{} {
+	return r.synced.Synced()
+}
+
+func (r *FilesystemDiscoveryService) Init(config *flux.Configuration) error {
+	if dir := config.GetString(filesystemConfigDirectory); dir != "" {
+		r.directory = dir
+	}
+	if r.directory == "" {
+		return errors.New("config(directory) of filesystem discovery is required")
+	}
+	logger.Infow("FilesystemEndpointDiscovery init", "directory", r.directory)
+	return nil
+}
+
+func (r *FilesystemDiscoveryService) HealthId() string {
+	return "discovery:" + r.id
+}
+
+// Health 检查元数据目录是否可访问
+func (r *FilesystemDiscoveryService) Health(_ context.Context) flux.Health {
+	details := map[string]interface{}{"directory": r.directory}
+	info, err := os.Stat(r.directory)
+	if nil != err {
+		details["error"] = err.Error()
+		return flux.NewHealthDown(details)
+	}
+	if !info.IsDir() {
+		details["error"] = "not a directory"
+		return flux.NewHealthDown(details)
+	}
+	return flux.NewHealthUp(details)
+}
+
+func (r *FilesystemDiscoveryService) WatchEndpoints(ctx context.Context, events chan<- flux.EndpointEvent) error {
+	known := make(map[string]map[string]flux.Endpoint, 8)
+	return r.watch(ctx, func(file string, res Resources) {
+		prev := known[file]
+		next := make(map[string]flux.Endpoint, len(res.Endpoints))
+		for _, ep := range res.Endpoints {
+			if !ep.IsValid() {
+				logger.LimitedWarnw("DISCOVERY:FILESYSTEM:ENDPOINT:INVALID", "file", file, "pattern", ep.HttpPattern)
+				continue
+			}
+			EnsureServiceAttrs(&ep.Service)
+			EnsureServiceAttrs(&ep.Permission)
+			key := fmt.Sprintf("%s#%s#%s", strings.ToUpper(ep.HttpMethod), ep.HttpPattern, ep.Version)
+			next[key] = ep
+			etype := flux.EventType(flux.EventTypeAdded)
+			if _, ok := prev[key]; ok {
+				etype = flux.EventTypeUpdated
+			}
+			events <- flux.EndpointEvent{EventType: etype, Endpoint: ep}
+		}
+		for key, ep := range prev {
+			if _, ok := next[key]; !ok {
+				events <- flux.EndpointEvent{EventType: flux.EventTypeRemoved, Endpoint: ep}
+			}
+		}
+		known[file] = next
+	})
+}
+
+func (r *FilesystemDiscoveryService) WatchServices(ctx context.Context, events chan<- flux.ServiceEvent) error {
+	known := make(map[string]map[string]flux.TransporterService, 8)
+	return r.watch(ctx, func(file string, res Resources) {
+		prev := known[file]
+		next := make(map[string]flux.TransporterService, len(res.Services))
+		for _, srv := range res.Services {
+			if !srv.IsValid() {
+				logger.LimitedWarnw("DISCOVERY:FILESYSTEM:SERVICE:INVALID", "file", file, "service-id", srv.ServiceId)
+				continue
+			}
+			EnsureServiceAttrs(&srv)
+			next[srv.ServiceId] = srv
+			etype := flux.EventType(flux.EventTypeAdded)
+			if _, ok := prev[srv.ServiceId]; ok {
+				etype = flux.EventTypeUpdated
+			}
+			events <- flux.ServiceEvent{EventType: etype, Service: srv}
+		}
+		for id, srv := range prev {
+			if _, ok := next[id]; !ok {
+				events <- flux.ServiceEvent{EventType: flux.EventTypeRemoved, Service: srv}
+			}
+		}
+		known[file] = next
+	})
+}
+
+// watch 加载目录下全部文件，并监听文件变更
+func (r *FilesystemDiscoveryService) watch(ctx context.Context, apply filesystemApplyFunc) error {
+	infos, err := ioutil.ReadDir(r.directory)
+	if nil != err {
+		return fmt.Errorf("filesystem discovery read dir, path: %s, err: %w", r.directory, err)
+	}
+	for _, info := range infos {
+		file := filepath.Join(r.directory, info.Name())
+		if info.IsDir() || !isResourceFile(file) {
+			continue
+		}
+		res, err := r.loader(file)
+		if nil != err {
+			return err
+		}
+		apply(file, res)
+	}
+	r.synced.markSynced()
+	watcher, err := fsnotify.NewWatcher()
+	if nil != err {
+		return fmt.Errorf("filesystem discovery create watcher, err: %w", err)
+	}
+	if err := watcher.Add(r.directory); nil != err {
+		_ = watcher.Close()
+		return fmt.Errorf("filesystem discovery watch dir, path: %s, err: %w", r.directory, err)
+	}
+	go func() {
+		defer func() {
+			_ = watcher.Close()
+		}()
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Infow("DISCOVERY:FILESYSTEM:WATCH/CANCELED", "directory", r.directory)
+				return
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warnw("DISCOVERY:FILESYSTEM:WATCH/ERROR", "directory", r.directory, "error", err)
+			case evt, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isResourceFile(evt.Name) {
+					continue
+				}
+				if evt.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+					logger.Infow("DISCOVERY:FILESYSTEM:FILE:REMOVE", "file", evt.Name)
+					apply(evt.Name, Resources{})
+				} else if evt.Op&(fsnotify.Create|fsnotify.Write) != 0 {
+					res, err := r.loader(evt.Name)
+					if nil != err {
+						logger.Warnw("DISCOVERY:FILESYSTEM:FILE:LOAD/ERROR", "file", evt.Name, "error", err)
+						continue
+					}
+					logger.Infow("DISCOVERY:FILESYSTEM:FILE:RELOAD", "file", evt.Name)
+					apply(evt.Name, res)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+func isResourceFile(file string) bool {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json", ".yml", ".yaml":
+		return true
+	default:
+		return false
+	}
+}
+
+// loadResourceFile 加载JSON/YAML文件；YAML兼容JSON格式
+func loadResourceFile(file string) (Resources, error) {
+	var out Resources
+	bytes, err := ioutil.ReadFile(file)
+	if nil != err {
+		return out, fmt.Errorf("filesystem discovery read file, path: %s, err: %w", file, err)
+	}
+	if err := yaml.Unmarshal(bytes, &out); nil != err {
+		return out, fmt.Errorf("filesystem discovery decode file, path: %s, err: %w", file, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilesystemDiscoveryService_Snapshot(t *testing.T) {
+	assert := assert2.New(t)
+	dir, err := ioutil.TempDir("", "flux-discovery")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	text := `{
+  "endpoints": [{"version": "1.0", "httpMethod": "GET", "httpPattern": "/api/users",
+    "service": {"serviceId": "users.get", "interface": "users", "method": "get"}}],
+  "services": [{"serviceId": "users.get", "interface": "users", "method": "get"}]
+}`
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "users.json"), []byte(text), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0644))
+
+	fs := NewFilesystemServiceWith(FilesystemId, WithFilesystemDirectory(dir))
+	assert.NoError(fs.Init(flux.NewEmptyConfiguration()))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	endpoints := make(chan flux.EndpointEvent, 4)
+	assert.NoError(fs.WatchEndpoints(ctx, endpoints))
+	select {
+	case <-fs.Synced():
+		assert.Fail("synced before services loaded")
+	default:
+	}
+	services := make(chan flux.ServiceEvent, 4)
+	assert.NoError(fs.WatchServices(ctx, services))
+	select {
+	case <-fs.Synced():
+	default:
+		assert.Fail("not synced after snapshot loaded")
+	}
+	assert.Equal(1, len(endpoints))
+	assert.Equal(1, len(services))
+	epEvt := <-endpoints
+	assert.Equal(flux.EventType(flux.EventTypeAdded), epEvt.EventType)
+	assert.Equal("/api/users", epEvt.Endpoint.HttpPattern)
+	srvEvt := <-services
+	assert.Equal("users.get", srvEvt.Service.ServiceId)
+}
@@ -0,0 +1,397 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/remoting"
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"github.com/spf13/cast"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 在Nacos配置中心注册的默认分组。需要与客户端的注册保持一致。
+	nacosDiscoveryEndpointGroup = "flux-endpoint"
+	nacosDiscoveryServiceGroup  = "flux-service"
+)
+
+const (
+	NacosId = "nacos"
+)
+
+const (
+	nacosConfigAddress         = "address"
+	nacosConfigNamespaceId     = "namespace_id"
+	nacosConfigContextPath     = "context_path"
+	nacosConfigUsername        = "username"
+	nacosConfigPassword        = "password"
+	nacosConfigTimeout         = "timeout"
+	nacosConfigGroupEndpoint   = "group_endpoint"
+	nacosConfigGroupService    = "group_service"
+	nacosConfigRefreshInterval = "refresh_interval"
+	nacosConfigPageSize        = "page_size"
+)
+
+var _ flux.EndpointDiscovery = new(NacosDiscoveryService)
+var _ flux.EndpointDiscoverySyncer = new(NacosDiscoveryService)
+var _ flux.HealthIndicator = new(NacosDiscoveryService)
+
+type (
+	// NacosOption 配置函数
+	NacosOption func(discovery *NacosDiscoveryService)
+	// nacosEventFunc 将Nacos配置数据转换为Endpoint/Service事件
+	nacosEventFunc func(dataId string, data []byte, etype remoting.EventType)
+)
+
+// NacosDiscoveryService 基于Nacos配置中心实现的Endpoint元数据注册中心。
+// 约定：Endpoint与Service元数据分别发布在 group_endpoint 和 group_service 分组下，每个DataId对应一个元数据JSON；
+// 启动监听前，先从配置中心加载全量快照；之后监听各DataId的数据变更，并定期扫描分组以发现新增与删除的DataId。
+// 注意：NacosDiscoveryService 默认不注册，需要通过 ext.RegisterEndpointDiscovery 手动注册。
+type NacosDiscoveryService struct {
+	id            string
+	globalAlias   map[string]string
+	client        config_client.IConfigClient
+	clientConfig  constant.ClientConfig
+	serverConfigs []constant.ServerConfig
+	endpointGroup string
+	serviceGroup  string
+	refresh       time.Duration
+	pageSize      int
+	watched       map[string]map[string]string // group -> dataId -> content
+	searchErrs    map[string]string            // group -> 最近一次加载失败的错误
+	watchedmu     sync.Mutex
+	synced        *syncNotifier
+}
+
+// WithNacosGlobalAlias 配置注册中心的配置别名
+func WithNacosGlobalAlias(alias map[string]string) NacosOption {
+	return func(discovery *NacosDiscoveryService) {
+		discovery.globalAlias = alias
+	}
+}
+
+// NewNacosServiceWith returns new a nacos discovery service
+func NewNacosServiceWith(id string, opts ...NacosOption) *NacosDiscoveryService {
+	r := &NacosDiscoveryService{
+		id:         id,
+		watched:    make(map[string]map[string]string, 2),
+		searchErrs: make(map[string]string, 2),
+		synced:     newSyncNotifier(2),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+func (r *NacosDiscoveryService) Id() string {
+	return r.id
+}
+
+// Synced 返回Endpoint及Service分组全量快照加载完成的通知通道
+func (r *NacosDiscoveryService) Synced() <-chan struct{} {
+	return r.synced.Synced()
+}
+
+// Init init discovery
+func (r *NacosDiscoveryService) Init(config *flux.Configuration) error {
+	config.SetGlobalAlias(map[string]string{
+		nacosConfigAddress:     "nacos.address",
+		nacosConfigNamespaceId: "nacos.namespace_id",
+		nacosConfigUsername:    "nacos.username",
+		nacosConfigPassword:    "nacos.password",
+	})
+	if len(r.globalAlias) != 0 {
+		config.SetGlobalAlias(r.globalAlias)
+	}
+	config.SetDefaults(map[string]interface{}{
+		nacosConfigGroupEndpoint:   nacosDiscoveryEndpointGroup,
+		nacosConfigGroupService:    nacosDiscoveryServiceGroup,
+		nacosConfigTimeout:         time.Second * 10,
+		nacosConfigRefreshInterval: time.Second * 30,
+		nacosConfigPageSize:        100,
+	})
+	r.endpointGroup = config.GetString(nacosConfigGroupEndpoint)
+	r.serviceGroup = config.GetString(nacosConfigGroupService)
+	if r.endpointGroup == "" || r.serviceGroup == "" {
+		return errors.New("config(group_endpoint, group_service) is empty")
+	}
+	r.refresh = config.GetDuration(nacosConfigRefreshInterval)
+	r.pageSize = config.GetInt(nacosConfigPageSize)
+	servers, err := ParseNacosServerConfigs(config.GetString(nacosConfigAddress), config.GetString(nacosConfigContextPath))
+	if nil != err {
+		return err
+	}
+	r.serverConfigs = servers
+	r.clientConfig = constant.ClientConfig{
+		TimeoutMs:           uint64(config.GetDuration(nacosConfigTimeout).Milliseconds()),
+		NamespaceId:         config.GetString(nacosConfigNamespaceId),
+		Username:            config.GetString(nacosConfigUsername),
+		Password:            config.GetString(nacosConfigPassword),
+		NotLoadCacheAtStart: true,
+	}
+	logger.Infow("NacosEndpointDiscovery init",
+		"address", r.serverConfigs, "namespace-id", r.clientConfig.NamespaceId,
+		"group-endpoint", r.endpointGroup, "group-service", r.serviceGroup)
+	return nil
+}
+
+// Startup startup discovery service
+func (r *NacosDiscoveryService) Startup() error {
+	logger.Info("NacosEndpointDiscovery startup")
+	client, err := clients.CreateConfigClient(map[string]interface{}{
+		constant.KEY_SERVER_CONFIGS: r.serverConfigs,
+		constant.KEY_CLIENT_CONFIG:  r.clientConfig,
+	})
+	if nil != err {
+		return fmt.Errorf("nacos config client create failed, id: %s, err: %w", r.id, err)
+	}
+	r.client = client
+	return nil
+}
+
+func (r *NacosDiscoveryService) HealthId() string {
+	return "discovery:" + r.id
+}
+
+// Health 检查Nacos客户端是否已创建，以及各分组最近一次加载配置是否成功
+func (r *NacosDiscoveryService) Health(_ context.Context) flux.Health {
+	if nil == r.client {
+		return flux.NewHealthDown(map[string]interface{}{"client": "not-started"})
+	}
+	details := make(map[string]interface{}, 2)
+	up := true
+	r.watchedmu.Lock()
+	for _, group := range []string{r.endpointGroup, r.serviceGroup} {
+		if err, ok := r.searchErrs[group]; ok {
+			details[group] = err
+			up = false
+		} else {
+			details[group] = len(r.watched[group])
+		}
+	}
+	r.watchedmu.Unlock()
+	if up {
+		return flux.NewHealthUp(details)
+	}
+	return flux.NewHealthDown(details)
+}
+
+// Shutdown shutdown discovery service
+func (r *NacosDiscoveryService) Shutdown(ctx context.Context) error {
+	logger.Info("NacosEndpointDiscovery shutdown")
+	params := make([]vo.ConfigParam, 0, 16)
+	r.watchedmu.Lock()
+	for group, items := range r.watched {
+		for dataId := range items {
+			params = append(params, vo.ConfigParam{DataId: dataId, Group: group})
+		}
+	}
+	r.watchedmu.Unlock()
+	for _, param := range params {
+		_ = r.client.CancelListenConfig(param)
+	}
+	return nil
+}
+
+// WatchEndpoints Listen http endpoints events
+func (r *NacosDiscoveryService) WatchEndpoints(ctx context.Context, events chan<- flux.EndpointEvent) error {
+	const msg = "DISCOVERY:NACOS:ENDPOINT:LISTEN_CONFIG"
+	logger.Infow(msg, "group", r.endpointGroup)
+	return r.watch(ctx, r.endpointGroup, func(dataId string, data []byte, etype remoting.EventType) {
+		if evt, err := NewEndpointEvent(data, etype); nil == err {
+			select {
+			case events <- evt:
+			case <-ctx.Done():
+			}
+		} else {
+			logger.Errorw(msg, "data-id", dataId, "event-type", etype, "error", err)
+		}
+	})
+}
+
+// WatchServices Listen gateway services events
+func (r *NacosDiscoveryService) WatchServices(ctx context.Context, events chan<- flux.ServiceEvent) error {
+	const msg = "DISCOVERY:NACOS:SERVICE:LISTEN_CONFIG"
+	logger.Infow(msg, "group", r.serviceGroup)
+	return r.watch(ctx, r.serviceGroup, func(dataId string, data []byte, etype remoting.EventType) {
+		if evt, ok := NewServiceEvent(data, etype, dataId); ok {
+			select {
+			case events <- evt:
+			case <-ctx.Done():
+			}
+		}
+	})
+}
+
+func (r *NacosDiscoveryService) watch(ctx context.Context, group string, eventf nacosEventFunc) error {
+	// 全量快照：在监听之前加载
+	snapshot, err := r.search(group)
+	if nil != err {
+		return fmt.Errorf("nacos load snapshot, group: %s, error: %w", group, err)
+	}
+	logger.Infow("DISCOVERY:NACOS:SNAPSHOT:LOADED", "group", group, "size", len(snapshot))
+	r.sync(group, snapshot, eventf)
+	r.synced.markSynced()
+	if r.refresh <= 0 {
+		return nil
+	}
+	go func() {
+		ticker := time.NewTicker(r.refresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				logger.Infow("DISCOVERY:NACOS:REFRESH/CANCELED", "group", group)
+				return
+			case <-ticker.C:
+				if items, err := r.search(group); nil != err {
+					logger.Warnw("DISCOVERY:NACOS:REFRESH/ERROR", "group", group, "error", err)
+				} else {
+					r.sync(group, items, eventf)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// sync 对比已监听的DataId列表：新增的DataId发送Add事件并监听其变更；已删除的DataId发送Remove事件并取消监听；
+// 只在更新监听列表时持有锁，发送事件及调用Nacos客户端时不持有锁，避免阻塞配置变更回调。
+func (r *NacosDiscoveryService) sync(group string, items map[string]string, eventf nacosEventFunc) {
+	added := make(map[string]string, len(items))
+	removed := make(map[string]string, 4)
+	r.watchedmu.Lock()
+	watched, ok := r.watched[group]
+	if !ok {
+		watched = make(map[string]string, len(items))
+		r.watched[group] = watched
+	}
+	for dataId, content := range items {
+		if _, exists := watched[dataId]; !exists {
+			watched[dataId] = content
+			added[dataId] = content
+		}
+	}
+	for dataId, content := range watched {
+		if _, exists := items[dataId]; !exists {
+			delete(watched, dataId)
+			removed[dataId] = content
+		}
+	}
+	r.watchedmu.Unlock()
+	for dataId, content := range added {
+		eventf(dataId, []byte(content), remoting.EventTypeNodeAdd)
+		if err := r.listen(group, dataId, eventf); nil != err {
+			logger.Warnw("DISCOVERY:NACOS:LISTEN/ERROR", "group", group, "data-id", dataId, "error", err)
+		}
+	}
+	for dataId, content := range removed {
+		_ = r.client.CancelListenConfig(vo.ConfigParam{DataId: dataId, Group: group})
+		eventf(dataId, []byte(content), remoting.EventTypeNodeDelete)
+	}
+}
+
+func (r *NacosDiscoveryService) listen(group, dataId string, eventf nacosEventFunc) error {
+	return r.client.ListenConfig(vo.ConfigParam{
+		DataId: dataId,
+		Group:  group,
+		OnChange: func(_, group, dataId, data string) {
+			defer func() {
+				if rvr := recover(); nil != rvr {
+					logger.Errorw("DISCOVERY:NACOS:LISTEN:PANIC", "group", group, "data-id", dataId, "error", rvr)
+				}
+			}()
+			r.watchedmu.Lock()
+			watched := r.watched[group]
+			prev, exists := watched[dataId]
+			if !exists {
+				r.watchedmu.Unlock()
+				return
+			}
+			// 配置被删除时，Nacos推送空数据；使用最后一次的数据来构建Remove事件
+			if strings.TrimSpace(data) == "" {
+				delete(watched, dataId)
+				r.watchedmu.Unlock()
+				_ = r.client.CancelListenConfig(vo.ConfigParam{DataId: dataId, Group: group})
+				eventf(dataId, []byte(prev), remoting.EventTypeNodeDelete)
+				return
+			}
+			watched[dataId] = data
+			r.watchedmu.Unlock()
+			eventf(dataId, []byte(data), remoting.EventTypeNodeUpdate)
+		},
+	})
+}
+
+// search 分页加载指定分组下的全部配置项，返回 DataId -> Content；记录加载结果用于健康检查
+func (r *NacosDiscoveryService) search(group string) (map[string]string, error) {
+	out, err := r.searchPages(group)
+	r.watchedmu.Lock()
+	if nil != err {
+		r.searchErrs[group] = err.Error()
+	} else {
+		delete(r.searchErrs, group)
+	}
+	r.watchedmu.Unlock()
+	return out, err
+}
+
+func (r *NacosDiscoveryService) searchPages(group string) (map[string]string, error) {
+	out := make(map[string]string, 16)
+	for pageNo := 1; ; pageNo++ {
+		page, err := r.client.SearchConfig(vo.SearchConfigParm{
+			Search:   "accurate",
+			Group:    group,
+			PageNo:   pageNo,
+			PageSize: r.pageSize,
+		})
+		if nil != err {
+			return nil, err
+		}
+		if nil == page {
+			break
+		}
+		for _, item := range page.PageItems {
+			if strings.TrimSpace(item.Content) != "" {
+				out[item.DataId] = item.Content
+			}
+		}
+		if len(page.PageItems) == 0 || pageNo >= page.PagesAvailable {
+			break
+		}
+	}
+	return out, nil
+}
+
+// ParseNacosServerConfigs 解析以逗号分隔的Nacos服务地址列表（host:port）
+func ParseNacosServerConfigs(address, contextPath string) ([]constant.ServerConfig, error) {
+	if strings.TrimSpace(address) == "" {
+		return nil, errors.New("config(address) of nacos is required")
+	}
+	if contextPath == "" {
+		contextPath = "/nacos"
+	}
+	out := make([]constant.ServerConfig, 0, 2)
+	for _, addr := range strings.Split(address, ",") {
+		host, port, err := net.SplitHostPort(strings.TrimSpace(addr))
+		if nil != err {
+			return nil, fmt.Errorf("invalid nacos address: %s, error: %w", addr, err)
+		}
+		out = append(out, constant.ServerConfig{
+			IpAddr:      host,
+			Port:        cast.ToUint64(port),
+			ContextPath: contextPath,
+		})
+	}
+	return out, nil
+}
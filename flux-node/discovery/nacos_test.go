@@ -0,0 +1,126 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/remoting"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/model"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNacosClient 返回固定配置项，并记录监听的DataId
+type fakeNacosClient struct {
+	config_client.IConfigClient
+	items     []model.ConfigItem
+	searchErr error
+	listened  []string
+	cancelled []string
+	mu        sync.Mutex
+}
+
+func (c *fakeNacosClient) SearchConfig(_ vo.SearchConfigParm) (*model.ConfigPage, error) {
+	if nil != c.searchErr {
+		return nil, c.searchErr
+	}
+	return &model.ConfigPage{PagesAvailable: 1, PageItems: c.items}, nil
+}
+
+func (c *fakeNacosClient) ListenConfig(param vo.ConfigParam) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listened = append(c.listened, param.DataId)
+	return nil
+}
+
+func (c *fakeNacosClient) CancelListenConfig(param vo.ConfigParam) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cancelled = append(c.cancelled, param.DataId)
+	return nil
+}
+
+func TestNacosDiscoveryService_SyncUnlocked(t *testing.T) {
+	assert := assert.New(t)
+	client := new(fakeNacosClient)
+	r := NewNacosServiceWith("nacos")
+	r.client = client
+	events := make([]string, 0, 4)
+	eventf := func(dataId string, _ []byte, etype remoting.EventType) {
+		// 发送事件时，配置变更回调可以获取锁
+		locked := make(chan struct{})
+		go func() {
+			r.watchedmu.Lock()
+			r.watchedmu.Unlock()
+			close(locked)
+		}()
+		select {
+		case <-locked:
+		case <-time.After(time.Second):
+			t.Error("sync must not hold the watched lock while sending events")
+		}
+		events = append(events, fmt.Sprintf("%s#%d", dataId, etype))
+	}
+	r.sync("endpoints", map[string]string{"a": "{}", "b": "{}"}, eventf)
+	sort.Strings(events)
+	assert.Equal([]string{fmt.Sprintf("a#%d", remoting.EventTypeNodeAdd), fmt.Sprintf("b#%d", remoting.EventTypeNodeAdd)}, events)
+	sort.Strings(client.listened)
+	assert.Equal([]string{"a", "b"}, client.listened)
+
+	events = events[:0]
+	r.sync("endpoints", map[string]string{"a": "{}"}, eventf)
+	assert.Equal([]string{fmt.Sprintf("b#%d", remoting.EventTypeNodeDelete)}, events)
+	assert.Equal([]string{"b"}, client.cancelled)
+}
+
+func TestNacosDiscoveryService_WatchCanceled(t *testing.T) {
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	r := NewNacosServiceWith("nacos")
+	r.client = &fakeNacosClient{items: []model.ConfigItem{
+		{DataId: "s1", Content: `{"serviceId":"s1","interface":"/users","method":"GET"}`},
+	}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// 无接收方时，取消的Context不阻塞监听
+	done := make(chan error, 1)
+	go func() {
+		done <- r.WatchServices(ctx, make(chan flux.ServiceEvent))
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("watch must not block on a canceled context")
+	}
+}
+
+func TestNacosDiscoveryService_Health(t *testing.T) {
+	assert := assert.New(t)
+	r := NewNacosServiceWith("nacos")
+	r.endpointGroup, r.serviceGroup = nacosDiscoveryEndpointGroup, nacosDiscoveryServiceGroup
+	assert.Equal("discovery:nacos", r.HealthId())
+	assert.False(r.Health(context.Background()).IsUp())
+	client := &fakeNacosClient{items: []model.ConfigItem{{DataId: "a", Content: "{}"}}}
+	r.client = client
+	_, err := r.search(nacosDiscoveryEndpointGroup)
+	assert.NoError(err)
+	assert.True(r.Health(context.Background()).IsUp())
+	// 加载配置失败时为非健康状态，恢复后为健康状态
+	client.searchErr = errors.New("connection refused")
+	_, err = r.search(nacosDiscoveryServiceGroup)
+	assert.Error(err)
+	health := r.Health(context.Background())
+	assert.False(health.IsUp())
+	assert.Equal("connection refused", health.Details[nacosDiscoveryServiceGroup])
+	client.searchErr = nil
+	_, _ = r.search(nacosDiscoveryServiceGroup)
+	assert.True(r.Health(context.Background()).IsUp())
+}
@@ -0,0 +1,220 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	OpenAPIId = "openapi"
+)
+
+const (
+	openapiConfigDirectory   = "directory"
+	openapiConfigRemoteHost  = "remote_host"
+	openapiConfigScheme      = "scheme"
+	openapiConfigPathPrefix  = "path_prefix"
+	openapiConfigVersion     = "version"
+	openapiConfigApplication = "application"
+	openapiConfigRpcTimeout  = "rpc_timeout"
+)
+
+const (
+	// Service属性：导入的OpenAPI接口的operationId
+	ServiceAttrTagOperationId = "operationid"
+)
+
+var _ flux.EndpointDiscovery = new(OpenAPIDiscoveryService)
+
+// OpenAPIImportOptions OpenAPI文档转换为Endpoint/Service元数据的选项；为空的选项使用文档中的定义
+type OpenAPIImportOptions struct {
+	// 后端服务地址，默认使用文档的 servers（OpenAPI 3）或 host（Swagger 2）
+	RemoteHost string
+	Scheme     string
+	// 网关路由路径前缀；后端请求路径不包含此前缀
+	PathPrefix  string
+	Version     string
+	Application string
+	RpcTimeout  string
+}
+
+// OpenAPIDiscoveryService 从本地目录加载OpenAPI 3/Swagger 2文档（JSON/YAML），转换为HTTP协议的Endpoint及Service元数据；
+// 每个接口操作生成一个Endpoint，后端服务透传请求的Query参数，Header及Body数据，Interface 中的 {name} 由请求路径参数替换。
+// 目录中的文件变更时发送Add/Update/Remove事件，用于通过放置 swagger.json 文件快速接入现有的REST服务。
+// 注意：OpenAPIDiscoveryService 默认不注册，需要通过 ext.RegisterEndpointDiscovery 手动注册。
+type OpenAPIDiscoveryService struct {
+	*FilesystemDiscoveryService
+	options OpenAPIImportOptions
+}
+
+// NewOpenAPIServiceWith returns new a OpenAPI document based discovery service
+func NewOpenAPIServiceWith(id string, opts ...FilesystemOption) *OpenAPIDiscoveryService {
+	r := &OpenAPIDiscoveryService{}
+	r.FilesystemDiscoveryService = NewFilesystemServiceWith(id, append(opts, WithFilesystemLoader(r.load))...)
+	return r
+}
+
+func (r *OpenAPIDiscoveryService) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		openapiConfigRpcTimeout: "10s",
+	})
+	if dir := config.GetString(openapiConfigDirectory); dir != "" {
+		r.directory = dir
+	}
+	if r.directory == "" {
+		return errors.New("config(directory) of openapi discovery is required")
+	}
+	r.options = OpenAPIImportOptions{
+		RemoteHost:  config.GetString(openapiConfigRemoteHost),
+		Scheme:      config.GetString(openapiConfigScheme),
+		PathPrefix:  config.GetString(openapiConfigPathPrefix),
+		Version:     config.GetString(openapiConfigVersion),
+		Application: config.GetString(openapiConfigApplication),
+		RpcTimeout:  config.GetString(openapiConfigRpcTimeout),
+	}
+	logger.Infow("OpenAPIEndpointDiscovery init", "directory", r.directory, "remote-host", r.options.RemoteHost,
+		"path-prefix", r.options.PathPrefix)
+	return nil
+}
+
+func (r *OpenAPIDiscoveryService) load(file string) (Resources, error) {
+	bytes, err := ioutil.ReadFile(file)
+	if nil != err {
+		return Resources{}, fmt.Errorf("openapi discovery read file, path: %s, err: %w", file, err)
+	}
+	res, err := ParseOpenAPIResources(bytes, r.options)
+	if nil != err {
+		return res, fmt.Errorf("openapi discovery convert file, path: %s, err: %w", file, err)
+	}
+	return res, nil
+}
+
+type openapiSpec struct {
+	Swagger  string                            `yaml:"swagger"`
+	OpenAPI  string                            `yaml:"openapi"`
+	Info     struct{ Title string }            `yaml:"info"`
+	Host     string                            `yaml:"host"`
+	BasePath string                            `yaml:"basePath"`
+	Schemes  []string                          `yaml:"schemes"`
+	Servers  []struct{ URL string }            `yaml:"servers"`
+	Paths    map[string]map[string]interface{} `yaml:"paths"`
+}
+
+type openapiOperation struct {
+	OperationId string   `yaml:"operationId"`
+	Tags        []string `yaml:"tags"`
+	Deprecated  bool     `yaml:"deprecated"`
+}
+
+// ParseOpenAPIResources 将OpenAPI 3/Swagger 2文档（JSON/YAML）转换为HTTP协议的Endpoint及Service元数据
+func ParseOpenAPIResources(bytes []byte, opts OpenAPIImportOptions) (Resources, error) {
+	var out Resources
+	var spec openapiSpec
+	if err := yaml.Unmarshal(bytes, &spec); nil != err {
+		return out, fmt.Errorf("decode openapi document, err: %w", err)
+	}
+	if spec.Swagger == "" && spec.OpenAPI == "" {
+		return out, errors.New("not a openapi document, field(openapi/swagger) is required")
+	}
+	scheme, host, basePath := "http", spec.Host, spec.BasePath
+	if len(spec.Schemes) > 0 {
+		scheme = spec.Schemes[0]
+	}
+	if len(spec.Servers) > 0 {
+		server, err := url.Parse(spec.Servers[0].URL)
+		if nil != err {
+			return out, fmt.Errorf("invalid openapi servers.url: %s, err: %w", spec.Servers[0].URL, err)
+		}
+		if server.Host != "" {
+			scheme, host = server.Scheme, server.Host
+		}
+		basePath = server.Path
+	}
+	if opts.RemoteHost != "" {
+		host = opts.RemoteHost
+	}
+	if opts.Scheme != "" {
+		scheme = opts.Scheme
+	}
+	if host == "" {
+		return out, errors.New("remote host of openapi document is required, define servers/host or config(remote_host)")
+	}
+	application := opts.Application
+	if application == "" {
+		application = spec.Info.Title
+	}
+	paths := make([]string, 0, len(spec.Paths))
+	for path := range spec.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		for method, raw := range spec.Paths[path] {
+			method = strings.ToUpper(method)
+			if !isOpenAPIMethod(method) {
+				continue
+			}
+			var op openapiOperation
+			if data, err := yaml.Marshal(raw); nil == err {
+				_ = yaml.Unmarshal(data, &op)
+			}
+			if op.Deprecated {
+				logger.Infow("DISCOVERY:OPENAPI:OPERATION:DEPRECATED/IGNORE", "method", method, "path", path)
+				continue
+			}
+			upstream := joinURLPath(basePath, path)
+			service := flux.TransporterService{
+				ServiceId:  method + ":" + scheme + "://" + host + upstream,
+				Scheme:     scheme,
+				RemoteHost: host,
+				Interface:  upstream,
+				Method:     method,
+				EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+					{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoHttp},
+					{Name: flux.ServiceAttrTagRpcTimeout, Value: opts.RpcTimeout},
+					{Name: ServiceAttrTagOperationId, Value: op.OperationId},
+				}},
+			}
+			out.Services = append(out.Services, service)
+			out.Endpoints = append(out.Endpoints, flux.Endpoint{
+				Application: application,
+				Version:     opts.Version,
+				HttpPattern: toRoutePattern(joinURLPath(opts.PathPrefix, path)),
+				HttpMethod:  method,
+				Service:     service,
+			})
+		}
+	}
+	return out, nil
+}
+
+func isOpenAPIMethod(method string) bool {
+	switch method {
+	case "GET", "PUT", "POST", "DELETE", "PATCH", "HEAD", "OPTIONS":
+		return true
+	default:
+		return false
+	}
+}
+
+// toRoutePattern 将OpenAPI路径参数 {name} 转换为路由参数 :name
+func toRoutePattern(path string) string {
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			segments[i] = ":" + seg[1:len(seg)-1]
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func joinURLPath(base, path string) string {
+	return "/" + strings.Trim(strings.TrimRight(base, "/")+"/"+strings.TrimLeft(path, "/"), "/")
+}
@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseOpenAPIResources_OpenAPI3(t *testing.T) {
+	assert := assert2.New(t)
+	text := `{
+  "openapi": "3.0.1",
+  "info": {"title": "users", "version": "1.0"},
+  "servers": [{"url": "https://users.internal:8443/v1"}],
+  "paths": {
+    "/users/{id}": {
+      "parameters": [{"name": "id", "in": "path", "required": true}],
+      "get": {"operationId": "getUser"},
+      "delete": {"operationId": "deleteUser", "deprecated": true}
+    }
+  }
+}`
+	res, err := ParseOpenAPIResources([]byte(text), OpenAPIImportOptions{PathPrefix: "/api"})
+	assert.NoError(err)
+	assert.Equal(1, len(res.Endpoints))
+	assert.Equal(1, len(res.Services))
+	ep := res.Endpoints[0]
+	assert.Equal("GET", ep.HttpMethod)
+	assert.Equal("/api/users/:id", ep.HttpPattern)
+	assert.Equal("users", ep.Application)
+	assert.Equal("https", ep.Service.Scheme)
+	assert.Equal("users.internal:8443", ep.Service.RemoteHost)
+	assert.Equal("/v1/users/{id}", ep.Service.Interface)
+	assert.Equal(flux.ProtoHttp, ep.Service.RpcProto())
+	assert.Equal("getUser", ep.Service.GetAttr(ServiceAttrTagOperationId).GetString())
+}
+
+func TestParseOpenAPIResources_Swagger2(t *testing.T) {
+	assert := assert2.New(t)
+	text := `
+swagger: "2.0"
+info:
+  title: orders
+host: orders.internal
+basePath: /
+paths:
+  /orders:
+    post:
+      operationId: createOrder
+`
+	res, err := ParseOpenAPIResources([]byte(text), OpenAPIImportOptions{RemoteHost: "127.0.0.1:8080"})
+	assert.NoError(err)
+	assert.Equal(1, len(res.Endpoints))
+	assert.Equal("/orders", res.Endpoints[0].HttpPattern)
+	assert.Equal("127.0.0.1:8080", res.Endpoints[0].Service.RemoteHost)
+	assert.Equal("http", res.Endpoints[0].Service.Scheme)
+
+	_, err = ParseOpenAPIResources([]byte(`{"paths": {}}`), OpenAPIImportOptions{})
+	assert.Error(err)
+}
@@ -15,18 +15,18 @@ func NewServiceEvent(bytes []byte, etype remoting.EventType, node string) (fxEvt
 	// Check json text
 	size := len(bytes)
 	if size < len("{\"k\":0}") || (bytes[0] != '[' && bytes[size-1] != '}') {
-		logger.Warnw("DISCOVERY:SERVICE:ILLEGAL_JSONSIZE", "data", string(bytes), "node", node)
+		logger.LimitedWarnw("DISCOVERY:SERVICE:ILLEGAL_JSONSIZE", "data", string(bytes), "node", node)
 		return invalidServiceEvent, false
 	}
 	service := flux.TransporterService{}
 	if err := ext.JSONUnmarshal(bytes, &service); nil != err {
-		logger.Warnw("DISCOVERY:SERVICE:ILLEGAL_JSONFORMAT",
+		logger.LimitedWarnw("DISCOVERY:SERVICE:ILLEGAL_JSONFORMAT",
 			"event-type", etype, "data", string(bytes), "error", err, "node", node)
 		return invalidServiceEvent, false
 	}
 	// 检查有效性
 	if !service.IsValid() {
-		logger.Warnw("DISCOVERY:SERVICE:INVALID_VALUES", "service", service, "node", node)
+		logger.LimitedWarnw("DISCOVERY:SERVICE:INVALID_VALUES", "service", service, "node", node)
 		return invalidServiceEvent, false
 	}
 	EnsureServiceAttrs(&service)
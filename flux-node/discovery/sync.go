@@ -0,0 +1,52 @@
+package discovery
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"sync/atomic"
+)
+
+// syncNotifier 首次全量数据同步通知：全部同步项（例如Endpoint及Service）完成后，关闭通知通道
+type syncNotifier struct {
+	pending int32
+	done    chan struct{}
+}
+
+func newSyncNotifier(parts int) *syncNotifier {
+	n := &syncNotifier{pending: int32(parts), done: make(chan struct{})}
+	if parts <= 0 {
+		close(n.done)
+	}
+	return n
+}
+
+// Synced 返回首次全量数据发送完成的通知通道
+func (n *syncNotifier) Synced() <-chan struct{} {
+	return n.done
+}
+
+// markSynced 标记一个同步项已完成；重复标记不影响通知通道
+func (n *syncNotifier) markSynced() {
+	if atomic.AddInt32(&n.pending, -1) == 0 {
+		close(n.done)
+	}
+}
+
+// markSyncedOn 在通知通道关闭后标记一个同步项已完成；Context取消时不标记
+func (n *syncNotifier) markSyncedOn(ctx context.Context, synced <-chan struct{}) {
+	go func() {
+		select {
+		case <-synced:
+			n.markSynced()
+		case <-ctx.Done():
+		}
+	}()
+}
+
+// syncedOf 返回注册中心首次同步完成的通知通道；未实现 flux.EndpointDiscoverySyncer 接口的注册中心，视为已完成
+func syncedOf(discovery flux.EndpointDiscovery) <-chan struct{} {
+	if syncer, ok := discovery.(flux.EndpointDiscoverySyncer); ok {
+		return syncer.Synced()
+	}
+	return newSyncNotifier(0).Synced()
+}
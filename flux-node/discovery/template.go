@@ -0,0 +1,158 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+const (
+	templateSourceConfig = "config"
+	templateSourceEnv    = "env"
+)
+
+// TemplateExpander 在注册时，对Endpoint/Service元数据的值进行模板变量替换；模板变量与配置值的占位符语法相同：
+// 环境变量 ${ENV_NAME}，#{ENV_NAME}，${env:ENV_NAME}，${$env:ENV_NAME}；全局配置 ${config:key}；文件内容 ${$file:/path}；密钥 ${$secret:path#field}。
+// 元数据模板中 ${name} 优先查找全局配置，不存在时查找同名环境变量；可指定默认值：${name:defaultV}。
+// Strict模式下，存在无法解析的模板变量时返回错误；否则保留原始文本。
+type TemplateExpander struct {
+	Strict bool
+	// 查找环境变量，默认为 os.LookupEnv
+	LookupEnv func(key string) (string, bool)
+	// 查找全局配置，默认为 viper 全局配置
+	LookupConfig func(key string) (string, bool)
+}
+
+// NewTemplateExpander 创建模板变量替换器
+func NewTemplateExpander(strict bool) *TemplateExpander {
+	return &TemplateExpander{
+		Strict:    strict,
+		LookupEnv: os.LookupEnv,
+		LookupConfig: func(key string) (string, bool) {
+			if viper.IsSet(key) {
+				return viper.GetString(key), true
+			}
+			return "", false
+		},
+	}
+}
+
+// ExpandEndpoint 替换Endpoint元数据中的模板变量
+func (t *TemplateExpander) ExpandEndpoint(endpoint *flux.Endpoint) (err error) {
+	if endpoint.Application, err = t.Expand(endpoint.Application); nil != err {
+		return err
+	}
+	if err = t.expandAttrs(endpoint.Attributes); nil != err {
+		return err
+	}
+	if err = t.ExpandService(&endpoint.Service); nil != err {
+		return err
+	}
+	return t.ExpandService(&endpoint.Permission)
+}
+
+// ExpandService 替换Service元数据中的模板变量
+func (t *TemplateExpander) ExpandService(service *flux.TransporterService) (err error) {
+	for _, field := range []*string{&service.Scheme, &service.RemoteHost, &service.Interface, &service.Method} {
+		if *field, err = t.Expand(*field); nil != err {
+			return fmt.Errorf("service: %s, %w", service.ServiceId, err)
+		}
+	}
+	if err = t.expandAttrs(service.Attributes); nil != err {
+		return fmt.Errorf("service: %s, %w", service.ServiceId, err)
+	}
+	return t.expandArgs(service.Arguments)
+}
+
+// Expand 替换文本中的全部模板变量
+func (t *TemplateExpander) Expand(text string) (string, error) {
+	return flux.ReplaceDynamicValues(text, func(pattern, key, def string, typ int) (string, error) {
+		if value, ok := t.lookup(key, def, typ); ok {
+			return value, nil
+		}
+		if t.Strict {
+			return "", errors.New("TEMPLATE:UNRESOLVED")
+		}
+		return pattern, nil
+	})
+}
+
+func (t *TemplateExpander) lookup(key, def string, typ int) (string, bool) {
+	if key == "" {
+		return "", false
+	}
+	switch typ {
+	case flux.DynamicTypeConfig:
+		// 显式指定来源：${config:key:defaultV}，${env:NAME:defaultV}
+		if source := key; (source == templateSourceConfig || source == templateSourceEnv) && def != "" {
+			key, def = def, ""
+			if idx := strings.IndexByte(key, ':'); idx > 0 {
+				key, def = key[:idx], key[idx+1:]
+			}
+			lookup := t.LookupConfig
+			if source == templateSourceEnv {
+				lookup = t.LookupEnv
+			}
+			if value, ok := lookup(key); ok {
+				return value, true
+			}
+			break
+		}
+		if value, ok := t.LookupConfig(key); ok {
+			return value, true
+		}
+		if value, ok := t.LookupEnv(key); ok {
+			return value, true
+		}
+	case flux.DynamicTypeEnv:
+		if value, ok := t.LookupEnv(key); ok {
+			return value, true
+		}
+	default:
+		value, err := flux.LookupDynamicValue(key, def, typ)
+		return cast.ToString(value), nil == err
+	}
+	return def, def != ""
+}
+
+func (t *TemplateExpander) expandAttrs(attrs []flux.Attribute) (err error) {
+	for i := range attrs {
+		switch v := attrs[i].Value.(type) {
+		case string:
+			if attrs[i].Value, err = t.Expand(v); nil != err {
+				return fmt.Errorf("attr: %s, %w", attrs[i].Name, err)
+			}
+		case []interface{}:
+			for j, iv := range v {
+				if sv, ok := iv.(string); ok {
+					if v[j], err = t.Expand(sv); nil != err {
+						return fmt.Errorf("attr: %s, %w", attrs[i].Name, err)
+					}
+				}
+			}
+		case []string:
+			for j := range v {
+				if v[j], err = t.Expand(v[j]); nil != err {
+					return fmt.Errorf("attr: %s, %w", attrs[i].Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (t *TemplateExpander) expandArgs(args []flux.Argument) error {
+	for i := range args {
+		if err := t.expandAttrs(args[i].Attributes); nil != err {
+			return fmt.Errorf("argument: %s, %w", args[i].Name, err)
+		}
+		if err := t.expandArgs(args[i].Fields); nil != err {
+			return err
+		}
+	}
+	return nil
+}
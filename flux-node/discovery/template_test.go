@@ -0,0 +1,62 @@
+package discovery
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestTemplateExpander(strict bool) *TemplateExpander {
+	t := NewTemplateExpander(strict)
+	t.LookupEnv = func(key string) (string, bool) {
+		v, ok := map[string]string{"UPSTREAM_HOST": "10.0.0.1:8080", "ENV": "prod"}[key]
+		return v, ok
+	}
+	t.LookupConfig = func(key string) (string, bool) {
+		v, ok := map[string]string{"app.group": "g1"}[key]
+		return v, ok
+	}
+	return t
+}
+
+func TestTemplateExpander_Expand(t *testing.T) {
+	cases := []struct {
+		text     string
+		expected string
+	}{
+		{text: "plain", expected: "plain"},
+		{text: "${UPSTREAM_HOST}", expected: "10.0.0.1:8080"},
+		{text: "http://${UPSTREAM_HOST}/${ENV}", expected: "http://10.0.0.1:8080/prod"},
+		{text: "${config:app.group}", expected: "g1"},
+		{text: "#{ENV}-${env:ENV}-${$env:ENV}", expected: "prod-prod-prod"},
+		{text: "${config:app.name:flux}", expected: "flux"},
+		{text: "${NOT_EXISTS:default}", expected: "default"},
+		{text: "${ NOT_EXISTS }", expected: "${ NOT_EXISTS }"},
+		{text: "${UNCLOSED", expected: "${UNCLOSED"},
+	}
+	assert := assert2.New(t)
+	expander := newTestTemplateExpander(false)
+	for _, tcase := range cases {
+		out, err := expander.Expand(tcase.text)
+		assert.NoError(err)
+		assert.Equal(tcase.expected, out)
+	}
+}
+
+func TestTemplateExpander_Strict(t *testing.T) {
+	assert := assert2.New(t)
+	expander := newTestTemplateExpander(true)
+	_, err := expander.Expand("${NOT_EXISTS}")
+	assert.Error(err)
+	service := flux.TransporterService{
+		RemoteHost: "${UPSTREAM_HOST}",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+			{Name: flux.ServiceAttrTagRpcGroup, Value: "${config:app.group}"},
+		}},
+	}
+	assert.NoError(expander.ExpandService(&service))
+	assert.Equal("10.0.0.1:8080", service.RemoteHost)
+	assert.Equal("g1", service.RpcGroup())
+	endpoint := flux.Endpoint{Application: "${config:app.name}"}
+	assert.Error(expander.ExpandEndpoint(&endpoint))
+}
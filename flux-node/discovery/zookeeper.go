@@ -1,3 +1,6 @@
+//go:build !no_zookeeper
+// +build !no_zookeeper
+
 package discovery
 
 import (
@@ -8,6 +11,8 @@ import (
 	"github.com/bytepowered/flux/flux-node/logger"
 	"github.com/bytepowered/flux/flux-node/remoting"
 	"github.com/bytepowered/flux/flux-node/remoting/zk"
+	"path"
+	"sync"
 	"time"
 )
 
@@ -28,6 +33,8 @@ const (
 )
 
 var _ flux.EndpointDiscovery = new(ZookeeperDiscoveryService)
+var _ flux.HealthIndicator = new(ZookeeperDiscoveryService)
+var _ flux.EndpointDiscoverySyncer = new(ZookeeperDiscoveryService)
 
 type (
 	// ZookeeperOption 配置函数
@@ -41,6 +48,7 @@ type ZookeeperDiscoveryService struct {
 	endpointPath string
 	servicePath  string
 	retrievers   []*zk.ZookeeperRetriever
+	synced       *syncNotifier
 }
 
 // WithGlobalAlias 配置注册中心的配置别名
@@ -53,7 +61,8 @@ func WithGlobalAlias(alias map[string]string) ZookeeperOption {
 // NewZookeeperServiceWith returns new a zookeeper discovery factory
 func NewZookeeperServiceWith(id string, opts ...ZookeeperOption) *ZookeeperDiscoveryService {
 	r := &ZookeeperDiscoveryService{
-		id: id,
+		id:     id,
+		synced: newSyncNotifier(2),
 	}
 	for _, opt := range opts {
 		opt(r)
@@ -65,6 +74,11 @@ func (r *ZookeeperDiscoveryService) Id() string {
 	return r.id
 }
 
+// Synced 返回各注册中心的Endpoint及Service节点首次数据均已发送的通知通道
+func (r *ZookeeperDiscoveryService) Synced() <-chan struct{} {
+	return r.synced.Synced()
+}
+
 // Init init discovery
 func (r *ZookeeperDiscoveryService) Init(config *flux.Configuration) error {
 	config.SetDefaults(map[string]interface{}{
@@ -116,7 +130,7 @@ func (r *ZookeeperDiscoveryService) WatchEndpoints(ctx context.Context, events c
 		if evt, err := NewEndpointEvent(event.Data, event.EventType); nil == err {
 			events <- evt
 		} else {
-			logger.Errorw(msg, "endpoint-event", event, "error", err)
+			logger.LimitedErrorw(msg, "endpoint-event", event, "error", err)
 		}
 	}
 	logger.Infow(msg, "endpoint-path", r.endpointPath)
@@ -141,9 +155,11 @@ func (r *ZookeeperDiscoveryService) WatchServices(ctx context.Context, events ch
 }
 
 func (r *ZookeeperDiscoveryService) onRetrievers(ctx context.Context, path string, callback func(remoting.NodeEvent)) error {
+	retrievers := newSyncNotifier(len(r.retrievers))
+	r.synced.markSyncedOn(ctx, retrievers.Synced())
 	for _, retriever := range r.retrievers {
 		watcher := func(ret *zk.ZookeeperRetriever, notify chan<- struct{}) {
-			if err := r.watch(ret, path, callback); err != nil {
+			if err := r.watch(ret, path, callback, retrievers.markSynced); err != nil {
 				logger.Errorw("DISCOVERY:ZOOKEEPER:RETRIEVERS:WATCH/Error", "watch-path", path, "error", err)
 			} else {
 				logger.Infow("DISCOVERY:ZOOKEEPER:RETRIEVERS:WATCH/Success", "watch-path", path)
@@ -165,7 +181,8 @@ func (r *ZookeeperDiscoveryService) onRetrievers(ctx context.Context, path strin
 	return nil
 }
 
-func (r *ZookeeperDiscoveryService) watch(retriever *zk.ZookeeperRetriever, rootpath string, nodeListener func(remoting.NodeEvent)) error {
+// watch 监听根节点下的子节点及其数据；监听时已存在的子节点均收到首次数据事件后，调用 synced 函数
+func (r *ZookeeperDiscoveryService) watch(retriever *zk.ZookeeperRetriever, rootpath string, nodeListener func(remoting.NodeEvent), synced func()) error {
 	exist, err := retriever.Exists(rootpath)
 	if nil != err {
 		return fmt.Errorf("check path exists, path: %s, error: %w", rootpath, err)
@@ -175,16 +192,61 @@ func (r *ZookeeperDiscoveryService) watch(retriever *zk.ZookeeperRetriever, root
 			return fmt.Errorf("init metadata node: %w", err)
 		}
 	}
+	children, err := retriever.Children(rootpath)
+	if nil != err {
+		return fmt.Errorf("load children, path: %s, error: %w", rootpath, err)
+	}
+	pending := make(map[string]struct{}, len(children))
+	for _, child := range children {
+		pending[path.Join(rootpath, child)] = struct{}{}
+	}
+	var once sync.Once
+	var pendingmu sync.Mutex
+	received := func(nodepath string) {
+		pendingmu.Lock()
+		delete(pending, nodepath)
+		done := len(pending) == 0
+		pendingmu.Unlock()
+		if done {
+			once.Do(synced)
+		}
+	}
+	if len(pending) == 0 {
+		once.Do(synced)
+	}
+	listener := func(event remoting.NodeEvent) {
+		nodeListener(event)
+		received(event.Path)
+	}
 	return retriever.AddChildrenNodeChangedListener("", rootpath, func(event remoting.NodeEvent) {
 		logger.Infow("DISCOVERY:ZOOKEEPER:RETRIEVERS:WATCH:RECV", "event", event)
 		if event.EventType == remoting.EventTypeChildAdd {
-			if err := retriever.AddNodeChangedListener("", event.Path, nodeListener); nil != err {
+			if err := retriever.AddNodeChangedListener("", event.Path, listener); nil != err {
 				logger.Warnw("Watch child node data", "error", err)
 			}
 		}
 	})
 }
 
+func (r *ZookeeperDiscoveryService) HealthId() string {
+	return "discovery:" + r.id
+}
+
+// Health 检查各注册中心的连接状态
+func (r *ZookeeperDiscoveryService) Health(_ context.Context) flux.Health {
+	details := make(map[string]interface{}, len(r.retrievers))
+	up := true
+	for _, retriever := range r.retrievers {
+		connected := retriever.Connected()
+		details[retriever.Id] = connected
+		up = up && connected
+	}
+	if up {
+		return flux.NewHealthUp(details)
+	}
+	return flux.NewHealthDown(details)
+}
+
 // Startup startup discovery service
 func (r *ZookeeperDiscoveryService) Startup() error {
 	logger.Info("ZkEndpointDiscovery startup")
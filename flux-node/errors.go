@@ -12,9 +12,18 @@ const (
 	ErrorCodeGatewayEndpoint    = "GATEWAY:ENDPOINT"
 	ErrorCodeGatewayCircuited   = "GATEWAY:CIRCUITED"
 	ErrorCodeGatewayCanceled    = "GATEWAY:CANCELED"
+	ErrorCodeGatewayDegraded    = "GATEWAY:DEGRADED"
+	ErrorCodeGatewayRateLimited = "GATEWAY:RATE_LIMITED"
+	ErrorCodeGatewayTimeout     = "GATEWAY:TIMEOUT"
+	ErrorCodeGatewayOverloaded  = "GATEWAY:UPSTREAM_OVERLOADED"
 	ErrorCodeRequestInvalid     = "REQUEST:INVALID"
 	ErrorCodeRequestNotFound    = "REQUEST:NOT_FOUND"
+	ErrorCodeRequestMediaType   = "REQUEST:UNSUPPORTED_MEDIA_TYPE"
+	ErrorCodeRequestMethod      = "REQUEST:METHOD_NOT_ALLOWED"
+	ErrorCodeRequestTooLarge    = "REQUEST:ENTITY_TOO_LARGE"
 	ErrorCodePermissionDenied   = "PERMISSION:ACCESS_DENIED"
+	ErrorCodeClientCertRequired = "PERMISSION:CLIENT_CERT_REQUIRED"
+	ErrorCodeClientCertDenied   = "PERMISSION:CLIENT_CERT_DENIED"
 )
 
 const (
@@ -28,9 +37,12 @@ const (
 
 	ErrorMessageTransportDecodeResponse = "TRANSPORT:DECODE_RESPONSE"
 	ErrorMessageTransportWriteResponse  = "TRANSPORT:WRITE_RESPONSE"
+	ErrorMessageTransportResponseHook   = "TRANSPORT:RESPONSE_HOOK"
+	ErrorMessageTransportWriteHook      = "TRANSPORT:WRITE_HOOK"
 
 	ErrorMessageDubboInvokeFailed        = "TRANSPORT:DU:INVOKE"
 	ErrorMessageDubboAssembleFailed      = "TRANSPORT:DU:ASSEMBLE"
+	ErrorMessageDubboProviderException   = "TRANSPORT:DU:PROVIDER_EXCEPTION"
 	ErrorMessageDubboDecodeInvalidHeader = "TRANSPORT:DU:DECODE:INVALID_HEADERS"
 	ErrorMessageDubboDecodeInvalidStatus = "TRANSPORT:DU:DECODE:INVALID_STATUS"
 
@@ -41,9 +53,12 @@ const (
 	ErrorMessagePermissionServiceNotFound = "PERMISSION:SERVICE:NOT_FOUND"
 	ErrorMessagePermissionVerifyError     = "PERMISSION:VERIFY:ERROR"
 
-	ErrorMessageWebServerRequestNotFound = "SERVER:REQUEST:NOT_FOUND"
+	ErrorMessageWebServerRequestNotFound         = "SERVER:REQUEST:NOT_FOUND"
+	ErrorMessageWebServerRequestMethodNotAllowed = "SERVER:REQUEST:METHOD_NOT_ALLOWED"
 
-	ErrorMessageRequestPrepare = "REQUEST:BODY:PREPARE"
+	ErrorMessageRequestPrepare  = "REQUEST:BODY:PREPARE"
+	ErrorMessageRequestTimeout  = "REQUEST:TIMEOUT"
+	ErrorMessageRequestTooLarge = "REQUEST:BODY:TOO_LARGE"
 )
 
 // ServeError 定义网关处理请求的服务错误；
@@ -92,3 +107,13 @@ func (e *ServeError) Merge(header http.Header) *ServeError {
 	}
 	return e
 }
+
+// NewTimeoutServeError 返回请求超时（504）的服务错误
+func NewTimeoutServeError(cause error) *ServeError {
+	return &ServeError{
+		StatusCode: StatusTimeout,
+		ErrorCode:  ErrorCodeGatewayTimeout,
+		Message:    ErrorMessageRequestTimeout,
+		CauseError: cause,
+	}
+}
@@ -0,0 +1,79 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-pkg"
+	"strings"
+)
+
+// RegisterLoadBalancer 添加指定名称的负载均衡策略实现
+func RegisterLoadBalancer(name string, balancer flux.LoadBalancer) {
+	defaultRegistry.RegisterLoadBalancer(name, balancer)
+}
+
+// LoadBalancerByName 查找指定名称的负载均衡策略实现
+func LoadBalancerByName(name string) (flux.LoadBalancer, bool) {
+	return defaultRegistry.LoadBalancerByName(name)
+}
+
+// LoadBalancers 返回全部已注册的负载均衡策略实现
+func LoadBalancers() map[string]flux.LoadBalancer {
+	return defaultRegistry.LoadBalancers()
+}
+
+// RegisterInstanceResolver 添加指定名称的服务实例地址查询实现
+func RegisterInstanceResolver(name string, resolver flux.InstanceResolver) {
+	defaultRegistry.RegisterInstanceResolver(name, resolver)
+}
+
+// InstanceResolverByName 查找指定名称的服务实例地址查询实现
+func InstanceResolverByName(name string) (flux.InstanceResolver, bool) {
+	return defaultRegistry.InstanceResolverByName(name)
+}
+
+// RegisterLoadBalancer 注册负载均衡策略
+func (r *Registry) RegisterLoadBalancer(name string, balancer flux.LoadBalancer) {
+	name = strings.ToLower(fluxpkg.MustNotEmpty(name, "name is empty"))
+	balancer = fluxpkg.MustNotNil(balancer, "LoadBalancer is nil").(flux.LoadBalancer)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.balancers[name] = balancer
+	r.record(ComponentKindLoadBalancer, name, balancer)
+}
+
+// LoadBalancerByName 查找指定名称的负载均衡策略实现
+func (r *Registry) LoadBalancerByName(name string) (flux.LoadBalancer, bool) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	b, ok := r.store.balancers[strings.ToLower(name)]
+	return b, ok
+}
+
+// LoadBalancers 返回全部已注册的负载均衡策略实现
+func (r *Registry) LoadBalancers() map[string]flux.LoadBalancer {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make(map[string]flux.LoadBalancer, len(r.store.balancers))
+	for k, v := range r.store.balancers {
+		out[k] = v
+	}
+	return out
+}
+
+// RegisterInstanceResolver 注册上游实例解析器
+func (r *Registry) RegisterInstanceResolver(name string, resolver flux.InstanceResolver) {
+	name = strings.ToLower(fluxpkg.MustNotEmpty(name, "name is empty"))
+	resolver = fluxpkg.MustNotNil(resolver, "InstanceResolver is nil").(flux.InstanceResolver)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.instanceResolvers[name] = resolver
+	r.record(ComponentKindInstanceResolver, name, resolver)
+}
+
+// InstanceResolverByName 查找指定名称的服务实例地址查询实现
+func (r *Registry) InstanceResolverByName(name string) (flux.InstanceResolver, bool) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	v, ok := r.store.instanceResolvers[strings.ToLower(name)]
+	return v, ok
+}
@@ -0,0 +1,64 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-pkg"
+	"mime"
+	"strings"
+)
+
+// RegisterBodyParser 添加指定媒体类型的请求Body解析函数；媒体类型不区分大小写，不包含参数部分
+func RegisterBodyParser(mediaType string, parser flux.BodyParser) {
+	defaultRegistry.RegisterBodyParser(mediaType, parser)
+}
+
+// BodyParserByType 按Content-Type查找请求Body解析函数；
+// 如果指定类型不存在，以结构化后缀（+json，+xml）查找对应的解析函数。
+func BodyParserByType(contentType string) (flux.BodyParser, bool) {
+	return defaultRegistry.BodyParserByType(contentType)
+}
+
+// BodyParsers 返回全部已注册的请求Body解析函数
+func BodyParsers() map[string]flux.BodyParser {
+	return defaultRegistry.BodyParsers()
+}
+
+// RegisterBodyParser 注册请求Body解析器
+func (r *Registry) RegisterBodyParser(mediaType string, parser flux.BodyParser) {
+	mediaType = strings.ToLower(fluxpkg.MustNotEmpty(mediaType, "mediaType is empty"))
+	parser = fluxpkg.MustNotNil(parser, "BodyParser is nil").(flux.BodyParser)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.bodyParsers[mediaType] = parser
+	r.record(ComponentKindBodyParser, mediaType, parser)
+}
+
+// BodyParserByType 按Content-Type查找请求Body解析函数
+func (r *Registry) BodyParserByType(contentType string) (flux.BodyParser, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if nil != err {
+		mediaType = strings.TrimSpace(strings.Split(contentType, ";")[0])
+	}
+	mediaType = strings.ToLower(mediaType)
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	if p, ok := r.store.bodyParsers[mediaType]; ok {
+		return p, true
+	}
+	if idx := strings.LastIndexByte(mediaType, '+'); idx > 0 {
+		p, ok := r.store.bodyParsers["application/"+mediaType[idx+1:]]
+		return p, ok
+	}
+	return nil, false
+}
+
+// BodyParsers 返回全部已注册的请求Body解析函数
+func (r *Registry) BodyParsers() map[string]flux.BodyParser {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make(map[string]flux.BodyParser, len(r.store.bodyParsers))
+	for k, v := range r.store.bodyParsers {
+		out[k] = v
+	}
+	return out
+}
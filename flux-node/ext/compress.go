@@ -0,0 +1,51 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-pkg"
+	"strings"
+)
+
+// RegisterResponseEncoder 添加指定Content-Encoding的响应压缩编码实现，例如：gzip，deflate，br
+func RegisterResponseEncoder(encoding string, factory flux.ResponseEncoderFactory) {
+	defaultRegistry.RegisterResponseEncoder(encoding, factory)
+}
+
+// ResponseEncoderByName 查找指定Content-Encoding的响应压缩编码实现
+func ResponseEncoderByName(encoding string) (flux.ResponseEncoderFactory, bool) {
+	return defaultRegistry.ResponseEncoderByName(encoding)
+}
+
+// ResponseEncoders 返回全部已注册的响应压缩编码实现
+func ResponseEncoders() map[string]flux.ResponseEncoderFactory {
+	return defaultRegistry.ResponseEncoders()
+}
+
+// RegisterResponseEncoder 注册响应压缩编码器
+func (r *Registry) RegisterResponseEncoder(encoding string, factory flux.ResponseEncoderFactory) {
+	encoding = strings.ToLower(fluxpkg.MustNotEmpty(encoding, "encoding is empty"))
+	factory = fluxpkg.MustNotNil(factory, "ResponseEncoderFactory is nil").(flux.ResponseEncoderFactory)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.encoders[encoding] = factory
+	r.record(ComponentKindEncoder, encoding, factory)
+}
+
+// ResponseEncoderByName 查找指定Content-Encoding的响应压缩编码实现
+func (r *Registry) ResponseEncoderByName(encoding string) (flux.ResponseEncoderFactory, bool) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	f, ok := r.store.encoders[strings.ToLower(encoding)]
+	return f, ok
+}
+
+// ResponseEncoders 返回全部已注册的响应压缩编码实现
+func (r *Registry) ResponseEncoders() map[string]flux.ResponseEncoderFactory {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make(map[string]flux.ResponseEncoderFactory, len(r.store.encoders))
+	for k, v := range r.store.encoders {
+		out[k] = v
+	}
+	return out
+}
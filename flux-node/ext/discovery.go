@@ -4,23 +4,52 @@ import (
 	"github.com/bytepowered/flux/flux-node"
 )
 
-var (
-	endpointDiscoveryMap = make(map[string]flux.EndpointDiscovery, 4)
-)
-
 func RegisterEndpointDiscovery(discovery flux.EndpointDiscovery) {
-	endpointDiscoveryMap[discovery.Id()] = discovery
+	defaultRegistry.RegisterEndpointDiscovery(discovery)
 }
 
 func EndpointDiscoveryById(id string) (flux.EndpointDiscovery, bool) {
-	v, ok := endpointDiscoveryMap[id]
-	return v, ok
+	return defaultRegistry.EndpointDiscoveryById(id)
 }
 
 func EndpointDiscoveries() []flux.EndpointDiscovery {
-	out := make([]flux.EndpointDiscovery, 0, len(endpointDiscoveryMap))
-	for _, d := range endpointDiscoveryMap {
+	return defaultRegistry.EndpointDiscoveries()
+}
+
+// RemoveEndpointDiscovery 移除已注册的注册中心；通常用于替换默认注册的注册中心。
+func RemoveEndpointDiscovery(id string) {
+	defaultRegistry.RemoveEndpointDiscovery(id)
+}
+
+// RegisterEndpointDiscovery 注册Endpoint注册中心
+func (r *Registry) RegisterEndpointDiscovery(discovery flux.EndpointDiscovery) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.discoveries[discovery.Id()] = discovery
+	r.record(ComponentKindDiscovery, discovery.Id(), discovery)
+}
+
+func (r *Registry) EndpointDiscoveryById(id string) (flux.EndpointDiscovery, bool) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	v, ok := r.store.discoveries[id]
+	return v, ok
+}
+
+func (r *Registry) EndpointDiscoveries() []flux.EndpointDiscovery {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make([]flux.EndpointDiscovery, 0, len(r.store.discoveries))
+	for _, d := range r.store.discoveries {
 		out = append(out, d)
 	}
 	return out
 }
+
+// RemoveEndpointDiscovery 移除已注册的注册中心
+func (r *Registry) RemoveEndpointDiscovery(id string) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	delete(r.store.discoveries, id)
+	r.removeRecord(ComponentKindDiscovery + "/" + id)
+}
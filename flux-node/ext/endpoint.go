@@ -2,6 +2,7 @@ package ext
 
 import (
 	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-pkg"
 	"sync"
 )
 
@@ -31,3 +32,18 @@ func Endpoints() map[string]*flux.MVCEndpoint {
 	})
 	return out
 }
+
+var (
+	endpointValidators = make([]flux.EndpointValidateFunc, 0, 4)
+)
+
+// AddEndpointValidator 添加Endpoint事件的校验函数；按添加顺序执行
+func AddEndpointValidator(f flux.EndpointValidateFunc) {
+	endpointValidators = append(endpointValidators, fluxpkg.MustNotNil(f, "EndpointValidateFunc is nil").(flux.EndpointValidateFunc))
+}
+
+func EndpointValidators() []flux.EndpointValidateFunc {
+	out := make([]flux.EndpointValidateFunc, len(endpointValidators))
+	copy(out, endpointValidators)
+	return out
+}
@@ -5,17 +5,44 @@ import (
 	"github.com/bytepowered/flux/flux-pkg"
 )
 
-var (
-	typedFactories = make(map[string]flux.Factory, 16)
-)
-
 func RegisterFactory(typeName string, factory flux.Factory) {
-	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
-	typedFactories[typeName] = fluxpkg.MustNotNil(factory, "Factory is nil").(flux.Factory)
+	defaultRegistry.RegisterFactory(typeName, factory)
 }
 
 func FactoryByType(typeName string) (flux.Factory, bool) {
+	return defaultRegistry.FactoryByType(typeName)
+}
+
+// Factories 返回全部已注册的组件工厂函数
+func Factories() map[string]flux.Factory {
+	return defaultRegistry.Factories()
+}
+
+// RegisterFactory 注册动态组件（例如动态Filter）的工厂函数
+func (r *Registry) RegisterFactory(typeName string, factory flux.Factory) {
+	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
+	factory = fluxpkg.MustNotNil(factory, "Factory is nil").(flux.Factory)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.factories[typeName] = factory
+	r.record(ComponentKindFactory, typeName, factory)
+}
+
+func (r *Registry) FactoryByType(typeName string) (flux.Factory, bool) {
 	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
-	f, o := typedFactories[typeName]
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	f, o := r.store.factories[typeName]
 	return f, o
 }
+
+// Factories 返回全部已注册的组件工厂函数
+func (r *Registry) Factories() map[string]flux.Factory {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make(map[string]flux.Factory, len(r.store.factories))
+	for k, v := range r.store.factories {
+		out[k] = v
+	}
+	return out
+}
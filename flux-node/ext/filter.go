@@ -3,6 +3,7 @@ package ext
 import (
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-pkg"
+	"reflect"
 	"sort"
 )
 
@@ -17,54 +18,118 @@ func (s filterArray) Len() int           { return len(s) }
 func (s filterArray) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
 func (s filterArray) Less(i, j int) bool { return s[i].order < s[j].order }
 
-var (
-	globalFilter    = make([]filterWrapper, 0, 16)
-	selectiveFilter = make([]filterWrapper, 0, 16)
-	filterSelectors = make([]flux.FilterSelector, 0, 8)
-)
-
 // AddGlobalFilter 注册全局Filter；
 func AddGlobalFilter(v interface{}) {
-	globalFilter = _checkedAppendFilter(v, globalFilter)
-	sort.Sort(filterArray(globalFilter))
+	defaultRegistry.AddGlobalFilter(fluxpkg.MustNotNil(v, "Not a valid Filter").(flux.Filter))
 }
 
 // AddSelectiveFilter 注册可选Filter；
 func AddSelectiveFilter(v interface{}) {
-	selectiveFilter = _checkedAppendFilter(v, selectiveFilter)
-	sort.Sort(filterArray(selectiveFilter))
+	defaultRegistry.AddSelectiveFilter(fluxpkg.MustNotNil(v, "Not a valid Filter").(flux.Filter))
 }
 
-func _checkedAppendFilter(v interface{}, in []filterWrapper) (out []filterWrapper) {
-	f := fluxpkg.MustNotNil(v, "Not a valid Filter").(flux.Filter)
-	return append(in, filterWrapper{filter: f, order: orderOf(v)})
+// ReplaceFilter 替换相同FilterId的已注册Filter；返回是否已替换
+func ReplaceFilter(filter flux.Filter) bool {
+	return defaultRegistry.ReplaceFilter(filter)
 }
 
 // SelectiveFilters 获取已排序的Filter列表
 func SelectiveFilters() []flux.Filter {
-	return getFilters(selectiveFilter)
+	return defaultRegistry.SelectiveFilters()
 }
 
 // GlobalFilters 获取已排序的全局Filter列表
 func GlobalFilters() []flux.Filter {
-	return getFilters(globalFilter)
+	return defaultRegistry.GlobalFilters()
 }
 
 func AddFilterSelector(s flux.FilterSelector) {
-	fluxpkg.MustNotNil(s, "FilterSelector is nil")
-	filterSelectors = append(filterSelectors, s)
+	defaultRegistry.AddFilterSelector(s)
 }
 
 func FilterSelectors() []flux.FilterSelector {
-	out := make([]flux.FilterSelector, len(filterSelectors))
-	copy(out, filterSelectors)
-	return out
+	return defaultRegistry.FilterSelectors()
 }
 
 // SelectiveFilterById 获取已排序的可选Filter列表
 func SelectiveFilterById(filterId string) (flux.Filter, bool) {
+	return defaultRegistry.SelectiveFilterById(filterId)
+}
+
+// AddGlobalFilter 注册全局Filter
+func (r *Registry) AddGlobalFilter(filter flux.Filter) {
+	filter = fluxpkg.MustNotNil(filter, "Not a valid Filter").(flux.Filter)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.globalFilters = append(r.store.globalFilters, filterWrapper{filter: filter, order: orderOf(filter)})
+	sort.Sort(filterArray(r.store.globalFilters))
+	r.record(ComponentKindFilter, filter.FilterId(), filter)
+}
+
+// AddSelectiveFilter 注册可选Filter
+func (r *Registry) AddSelectiveFilter(filter flux.Filter) {
+	filter = fluxpkg.MustNotNil(filter, "Not a valid Filter").(flux.Filter)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.selectiveFilters = append(r.store.selectiveFilters, filterWrapper{filter: filter, order: orderOf(filter)})
+	sort.Sort(filterArray(r.store.selectiveFilters))
+	r.record(ComponentKindFilter, filter.FilterId(), filter)
+}
+
+// ReplaceFilter 替换相同FilterId的已注册全局Filter或可选Filter；返回是否已替换
+func (r *Registry) ReplaceFilter(filter flux.Filter) bool {
+	filter = fluxpkg.MustNotNil(filter, "Not a valid Filter").(flux.Filter)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	for _, filters := range [][]filterWrapper{r.store.globalFilters, r.store.selectiveFilters} {
+		for i := range filters {
+			if filters[i].filter.FilterId() == filter.FilterId() {
+				filters[i].filter = filter
+				r.record(ComponentKindFilter, filter.FilterId(), filter)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SelectiveFilters 获取已排序的Filter列表
+func (r *Registry) SelectiveFilters() []flux.Filter {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	return getFilters(r.store.selectiveFilters)
+}
+
+// GlobalFilters 获取已排序的全局Filter列表
+func (r *Registry) GlobalFilters() []flux.Filter {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	return getFilters(r.store.globalFilters)
+}
+
+// AddFilterSelector 注册Filter选择器
+func (r *Registry) AddFilterSelector(selector flux.FilterSelector) {
+	selector = fluxpkg.MustNotNil(selector, "FilterSelector is nil").(flux.FilterSelector)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.selectors = append(r.store.selectors, selector)
+	r.record(ComponentKindSelector, reflect.TypeOf(selector).String(), selector)
+}
+
+func (r *Registry) FilterSelectors() []flux.FilterSelector {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make([]flux.FilterSelector, len(r.store.selectors))
+	copy(out, r.store.selectors)
+	return out
+}
+
+// SelectiveFilterById 获取已排序的可选Filter列表
+func (r *Registry) SelectiveFilterById(filterId string) (flux.Filter, bool) {
 	filterId = fluxpkg.MustNotEmpty(filterId, "filterId is empty")
-	for _, f := range selectiveFilter {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	for _, f := range r.store.selectiveFilters {
 		if filterId == f.filter.FilterId() {
 			return f.filter, true
 		}
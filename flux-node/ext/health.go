@@ -0,0 +1,31 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-pkg"
+)
+
+// AddHealthIndicator 添加组件健康检查接口
+func AddHealthIndicator(indicator flux.HealthIndicator) {
+	defaultRegistry.AddHealthIndicator(indicator)
+}
+
+func HealthIndicators() []flux.HealthIndicator {
+	return defaultRegistry.HealthIndicators()
+}
+
+// AddHealthIndicator 注册健康检查指示器
+func (r *Registry) AddHealthIndicator(indicator flux.HealthIndicator) {
+	indicator = fluxpkg.MustNotNil(indicator, "HealthIndicator is nil").(flux.HealthIndicator)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.indicators = append(r.store.indicators, indicator)
+}
+
+func (r *Registry) HealthIndicators() []flux.HealthIndicator {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	dst := make([]flux.HealthIndicator, len(r.store.indicators))
+	copy(dst, r.store.indicators)
+	return dst
+}
@@ -5,42 +5,101 @@ import (
 	"github.com/bytepowered/flux/flux-pkg"
 )
 
-var (
-	hooksPrepare  = make([]flux.PrepareHookFunc, 0, 16)
-	hooksStartup  = make([]flux.Startuper, 0, 16)
-	hooksShutdown = make([]flux.Shutdowner, 0, 16)
-)
-
 // AddHookFunc 添加生命周期启动与停止的钩子接口
 func AddHookFunc(hook interface{}) {
+	defaultRegistry.AddHookFunc(hook)
+}
+
+// ReplaceHookFunc 使用新实例替换已注册的生命周期钩子
+func ReplaceHookFunc(old, hook interface{}) {
+	defaultRegistry.ReplaceHookFunc(old, hook)
+}
+
+// AddPrepareHook 添加预备阶段钩子函数
+func AddPrepareHook(pf flux.PrepareHookFunc) {
+	defaultRegistry.AddPrepareHook(pf)
+}
+
+func PrepareHooks() []flux.PrepareHookFunc {
+	return defaultRegistry.PrepareHooks()
+}
+
+func StartupHooks() []flux.Startuper {
+	return defaultRegistry.StartupHooks()
+}
+
+func ShutdownHooks() []flux.Shutdowner {
+	return defaultRegistry.ShutdownHooks()
+}
+
+// AddHookFunc 注册启动/停止生命周期钩子
+func (r *Registry) AddHookFunc(hook interface{}) {
 	fluxpkg.MustNotNil(hook, "Hook is nil")
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
 	if startup, ok := hook.(flux.Startuper); ok {
-		hooksStartup = append(hooksStartup, startup)
+		r.store.startupHooks = append(r.store.startupHooks, startup)
 	}
 	if shutdown, ok := hook.(flux.Shutdowner); ok {
-		hooksShutdown = append(hooksShutdown, shutdown)
+		r.store.shutdownHooks = append(r.store.shutdownHooks, shutdown)
 	}
 }
 
-// AddPrepareHook 添加预备阶段钩子函数
-func AddPrepareHook(pf flux.PrepareHookFunc) {
-	hooksPrepare = append(hooksPrepare, fluxpkg.MustNotNil(pf, "PrepareHookFunc is nil").(flux.PrepareHookFunc))
+// ReplaceHookFunc 使用新实例替换已注册的生命周期钩子；新实例未注册的钩子类型被移除
+func (r *Registry) ReplaceHookFunc(old, hook interface{}) {
+	fluxpkg.MustNotNil(hook, "Hook is nil")
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	startups := r.store.startupHooks[:0]
+	for _, startup := range r.store.startupHooks {
+		if startup != old {
+			startups = append(startups, startup)
+		}
+	}
+	r.store.startupHooks = startups
+	if startup, ok := hook.(flux.Startuper); ok {
+		r.store.startupHooks = append(r.store.startupHooks, startup)
+	}
+	shutdowns := r.store.shutdownHooks[:0]
+	for _, shutdown := range r.store.shutdownHooks {
+		if shutdown != old {
+			shutdowns = append(shutdowns, shutdown)
+		}
+	}
+	r.store.shutdownHooks = shutdowns
+	if shutdown, ok := hook.(flux.Shutdowner); ok {
+		r.store.shutdownHooks = append(r.store.shutdownHooks, shutdown)
+	}
 }
 
-func PrepareHooks() []flux.PrepareHookFunc {
-	dst := make([]flux.PrepareHookFunc, len(hooksPrepare))
-	copy(dst, hooksPrepare)
+// AddPrepareHook 注册预备阶段钩子函数
+func (r *Registry) AddPrepareHook(pf flux.PrepareHookFunc) {
+	pf = fluxpkg.MustNotNil(pf, "PrepareHookFunc is nil").(flux.PrepareHookFunc)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.prepareHooks = append(r.store.prepareHooks, pf)
+}
+
+func (r *Registry) PrepareHooks() []flux.PrepareHookFunc {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	dst := make([]flux.PrepareHookFunc, len(r.store.prepareHooks))
+	copy(dst, r.store.prepareHooks)
 	return dst
 }
 
-func StartupHooks() []flux.Startuper {
-	dst := make([]flux.Startuper, len(hooksStartup))
-	copy(dst, hooksStartup)
+func (r *Registry) StartupHooks() []flux.Startuper {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	dst := make([]flux.Startuper, len(r.store.startupHooks))
+	copy(dst, r.store.startupHooks)
 	return dst
 }
 
-func ShutdownHooks() []flux.Shutdowner {
-	dst := make([]flux.Shutdowner, len(hooksShutdown))
-	copy(dst, hooksShutdown)
+func (r *Registry) ShutdownHooks() []flux.Shutdowner {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	dst := make([]flux.Shutdowner, len(r.store.shutdownHooks))
+	copy(dst, r.store.shutdownHooks)
 	return dst
 }
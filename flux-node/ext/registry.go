@@ -0,0 +1,339 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ComponentState 扩展组件的生命周期状态
+type ComponentState string
+
+const (
+	ComponentStateRegistered  ComponentState = "registered"
+	ComponentStateInitialized ComponentState = "initialized"
+	ComponentStateStarted     ComponentState = "started"
+	ComponentStateStopped     ComponentState = "stopped"
+	ComponentStateFailed      ComponentState = "failed"
+)
+
+const (
+	ComponentKindFilter           = "filter"
+	ComponentKindSelector         = "filter_selector"
+	ComponentKindTransporter      = "transporter"
+	ComponentKindSerializer       = "serializer"
+	ComponentKindResolver         = "resolver"
+	ComponentKindBodyParser       = "body_parser"
+	ComponentKindEncoder          = "response_encoder"
+	ComponentKindDiscovery        = "discovery"
+	ComponentKindFactory          = "factory"
+	ComponentKindLoadBalancer     = "load_balancer"
+	ComponentKindInstanceResolver = "instance_resolver"
+	// 未通过注册入口注册，但参与生命周期初始化的组件，例如AccessLog
+	ComponentKindComponent = "component"
+)
+
+const (
+	// 外部插件的注册来源前缀：plugin:<file>；内置组件的注册来源为注册调用所在的包路径
+	OriginPluginPrefix = "plugin:"
+)
+
+// ComponentRecord 扩展组件的注册记录：注册来源，注册/初始化顺序及生命周期状态
+type ComponentRecord struct {
+	Kind          string         `json:"kind"`
+	Id            string         `json:"id"`
+	Type          string         `json:"type"`
+	Origin        string         `json:"origin"`
+	State         ComponentState `json:"state"`
+	RegisterOrder int            `json:"registerOrder"`
+	InitOrder     int            `json:"initOrder,omitempty"`
+	Error         string         `json:"error,omitempty"`
+	UpdatedAt     time.Time      `json:"updatedAt"`
+	ref           interface{}
+}
+
+// Registry 扩展组件的注册入口；外部插件通过导出的 Register(*ext.Registry) 函数注册组件，
+// 注册的组件与内置组件一致，由服务启动流程统一初始化。
+// 同一组件存储可有多个注册入口视图（WithOrigin），区别仅在于记录的注册来源。
+type Registry struct {
+	origin string
+	store  *registryStore
+}
+
+type registryStore struct {
+	mu                sync.RWMutex
+	factories         map[string]flux.Factory
+	transporters      map[string]flux.Transporter
+	transporterFacts  map[string]flux.Factory
+	discoveries       map[string]flux.EndpointDiscovery
+	serializers       map[string]flux.Serializer
+	resolvers         map[string]flux.MTValueResolver
+	bodyParsers       map[string]flux.BodyParser
+	encoders          map[string]flux.ResponseEncoderFactory
+	balancers         map[string]flux.LoadBalancer
+	instanceResolvers map[string]flux.InstanceResolver
+	globalFilters     []filterWrapper
+	selectiveFilters  []filterWrapper
+	selectors         []flux.FilterSelector
+	prepareHooks      []flux.PrepareHookFunc
+	startupHooks      []flux.Startuper
+	shutdownHooks     []flux.Shutdowner
+	indicators        []flux.HealthIndicator
+	records           map[string]*ComponentRecord
+	recordRefs        map[interface{}][]*ComponentRecord
+	registerSeq       int
+	initSeq           int
+}
+
+var (
+	defaultRegistry = NewRegistry()
+)
+
+// NewRegistry 创建独立存储的注册入口
+func NewRegistry() *Registry {
+	return &Registry{store: &registryStore{
+		factories:         make(map[string]flux.Factory, 16),
+		transporters:      make(map[string]flux.Transporter, 4),
+		transporterFacts:  make(map[string]flux.Factory, 4),
+		discoveries:       make(map[string]flux.EndpointDiscovery, 4),
+		serializers:       make(map[string]flux.Serializer, 2),
+		resolvers:         make(map[string]flux.MTValueResolver, 16),
+		bodyParsers:       make(map[string]flux.BodyParser, 8),
+		encoders:          make(map[string]flux.ResponseEncoderFactory, 4),
+		balancers:         make(map[string]flux.LoadBalancer, 4),
+		instanceResolvers: make(map[string]flux.InstanceResolver, 4),
+		globalFilters:     make([]filterWrapper, 0, 16),
+		selectiveFilters:  make([]filterWrapper, 0, 16),
+		selectors:         make([]flux.FilterSelector, 0, 8),
+		prepareHooks:      make([]flux.PrepareHookFunc, 0, 16),
+		startupHooks:      make([]flux.Startuper, 0, 16),
+		shutdownHooks:     make([]flux.Shutdowner, 0, 16),
+		indicators:        make([]flux.HealthIndicator, 0, 8),
+		records:           make(map[string]*ComponentRecord, 64),
+		recordRefs:        make(map[interface{}][]*ComponentRecord, 64),
+	}}
+}
+
+// DefaultRegistry 返回默认的扩展组件注册入口
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// WithOrigin 返回共享组件存储，以指定来源记录注册信息的注册入口
+func (r *Registry) WithOrigin(origin string) *Registry {
+	return &Registry{origin: origin, store: r.store}
+}
+
+// Components 返回全部组件的注册记录，按注册顺序排列
+func (r *Registry) Components() []ComponentRecord {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make([]ComponentRecord, 0, len(r.store.records))
+	for _, rec := range r.store.records {
+		out = append(out, *rec)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].RegisterOrder < out[j].RegisterOrder
+	})
+	return out
+}
+
+// ComponentOf 查找指定类型及标识的组件注册记录
+func (r *Registry) ComponentOf(kind, id string) (ComponentRecord, bool) {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	if rec, ok := r.store.records[kind+"/"+id]; ok {
+		return *rec, true
+	}
+	return ComponentRecord{}, false
+}
+
+// ComponentNamesOf 返回组件实例的全部注册名称：<kind>:<id>
+func (r *Registry) ComponentNamesOf(ref interface{}) []string {
+	if !isRecordRef(ref) {
+		return nil
+	}
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	recs := r.store.recordRefs[ref]
+	names := make([]string, 0, len(recs))
+	for _, rec := range recs {
+		names = append(names, rec.Kind+":"+rec.Id)
+	}
+	return names
+}
+
+// MarkInitialized 记录组件初始化结果；err不为nil时组件状态为 failed
+func (r *Registry) MarkInitialized(ref interface{}, err error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.initSeq++
+	for _, rec := range r.transition(ref, ComponentStateInitialized, err) {
+		rec.InitOrder = r.store.initSeq
+	}
+}
+
+// MarkStarted 记录组件启动结果
+func (r *Registry) MarkStarted(ref interface{}, err error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.transition(ref, ComponentStateStarted, err)
+}
+
+// MarkStopped 记录组件停止结果
+func (r *Registry) MarkStopped(ref interface{}, err error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.transition(ref, ComponentStateStopped, err)
+}
+
+// transition 更新组件状态；未注册的组件以 component 类型记录。需持有写锁。
+func (r *Registry) transition(ref interface{}, state ComponentState, err error) []*ComponentRecord {
+	if !isRecordRef(ref) {
+		return nil
+	}
+	recs := r.store.recordRefs[ref]
+	if len(recs) == 0 {
+		// 同类型的多个匿名组件，以序号区分
+		name := reflect.TypeOf(ref).String()
+		id := name
+		for i := 2; nil != r.store.records[ComponentKindComponent+"/"+id]; i++ {
+			id = name + "#" + strconv.Itoa(i)
+		}
+		recs = []*ComponentRecord{r.record(ComponentKindComponent, id, ref)}
+	}
+	for _, rec := range recs {
+		rec.State, rec.Error, rec.UpdatedAt = state, "", time.Now()
+		if nil != err {
+			rec.State, rec.Error = ComponentStateFailed, err.Error()
+		}
+	}
+	return recs
+}
+
+// record 添加或替换组件注册记录。需持有写锁。
+func (r *Registry) record(kind, id string, ref interface{}) *ComponentRecord {
+	key := kind + "/" + id
+	r.removeRecord(key)
+	// 已参与生命周期的匿名组件，注册后沿用其状态
+	state, initOrder := ComponentStateRegistered, 0
+	if isRecordRef(ref) {
+		prevs := append([]*ComponentRecord(nil), r.store.recordRefs[ref]...)
+		for _, prev := range prevs {
+			if prev.Kind == ComponentKindComponent {
+				state, initOrder = prev.State, prev.InitOrder
+				r.removeRecord(prev.Kind + "/" + prev.Id)
+			}
+		}
+	}
+	r.store.registerSeq++
+	rec := &ComponentRecord{
+		Kind: kind, Id: id, Type: reflect.TypeOf(ref).String(),
+		Origin: r.originOf(), State: state,
+		RegisterOrder: r.store.registerSeq, InitOrder: initOrder,
+		UpdatedAt: time.Now(), ref: ref,
+	}
+	r.store.records[key] = rec
+	if isRecordRef(ref) {
+		r.store.recordRefs[ref] = append(r.store.recordRefs[ref], rec)
+	}
+	return rec
+}
+
+// removeRecord 移除组件注册记录。需持有写锁。
+func (r *Registry) removeRecord(key string) {
+	rec, ok := r.store.records[key]
+	if !ok {
+		return
+	}
+	delete(r.store.records, key)
+	if !isRecordRef(rec.ref) {
+		return
+	}
+	refs := r.store.recordRefs[rec.ref]
+	for i, v := range refs {
+		if v == rec {
+			refs = append(refs[:i], refs[i+1:]...)
+			break
+		}
+	}
+	if len(refs) == 0 {
+		delete(r.store.recordRefs, rec.ref)
+	} else {
+		r.store.recordRefs[rec.ref] = refs
+	}
+}
+
+func (r *Registry) originOf() string {
+	if r.origin != "" {
+		return r.origin
+	}
+	return callerPackage()
+}
+
+// isRecordRef 判断组件实例是否可作为记录索引；函数等不可比较类型的组件仅按类型及标识记录
+func isRecordRef(ref interface{}) bool {
+	if nil == ref {
+		return false
+	}
+	return reflect.TypeOf(ref).Comparable()
+}
+
+// callerPackage 返回调用注册函数的包路径（跳过ext包内部调用）
+func callerPackage() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	self := reflect.TypeOf(Registry{}).PkgPath()
+	for {
+		frame, more := frames.Next()
+		if pkg := packageOf(frame.Function); pkg != "" && pkg != self {
+			return pkg
+		}
+		if !more {
+			return "unknown"
+		}
+	}
+}
+
+func packageOf(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return ""
+}
+
+// Components 返回默认注册入口的全部组件注册记录
+func Components() []ComponentRecord {
+	return defaultRegistry.Components()
+}
+
+// ComponentOf 查找默认注册入口中指定类型及标识的组件注册记录
+func ComponentOf(kind, id string) (ComponentRecord, bool) {
+	return defaultRegistry.ComponentOf(kind, id)
+}
+
+// ComponentNamesOf 返回默认注册入口中组件实例的全部注册名称
+func ComponentNamesOf(ref interface{}) []string {
+	return defaultRegistry.ComponentNamesOf(ref)
+}
+
+// MarkInitialized 记录组件初始化结果
+func MarkInitialized(ref interface{}, err error) {
+	defaultRegistry.MarkInitialized(ref, err)
+}
+
+// MarkStarted 记录组件启动结果
+func MarkStarted(ref interface{}, err error) {
+	defaultRegistry.MarkStarted(ref, err)
+}
+
+// MarkStopped 记录组件停止结果
+func MarkStopped(ref interface{}, err error) {
+	defaultRegistry.MarkStopped(ref, err)
+}
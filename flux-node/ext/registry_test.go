@@ -0,0 +1,64 @@
+package ext
+
+import (
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type testRegistryTransporter struct {
+	flux.Transporter
+	id string
+}
+
+func TestRegistry_ComponentRecords(t *testing.T) {
+	assert := assert2.New(t)
+	registry := NewRegistry()
+	builtin := &testRegistryTransporter{id: "builtin"}
+	external := &testRegistryTransporter{id: "external"}
+	registry.RegisterTransporter("builtin", builtin)
+	registry.WithOrigin(OriginPluginPrefix+"demo.so").RegisterTransporter("external", external)
+
+	transporter, ok := registry.TransporterBy("external")
+	assert.True(ok)
+	assert.Equal(external, transporter)
+
+	records := registry.Components()
+	assert.Equal(2, len(records))
+	// 注册来源为ext包外的调用方
+	assert.Equal("testing", records[0].Origin)
+	assert.Equal("plugin:demo.so", records[1].Origin)
+	assert.Equal(ComponentStateRegistered, records[0].State)
+	assert.True(records[0].RegisterOrder < records[1].RegisterOrder)
+
+	registry.MarkInitialized(external, nil)
+	registry.MarkInitialized(builtin, errors.New("init failed"))
+	registry.MarkStarted(external, nil)
+	rec, ok := registry.ComponentOf(ComponentKindTransporter, "external")
+	assert.True(ok)
+	assert.Equal(ComponentStateStarted, rec.State)
+	assert.Equal(1, rec.InitOrder)
+	rec, _ = registry.ComponentOf(ComponentKindTransporter, "builtin")
+	assert.Equal(ComponentStateFailed, rec.State)
+	assert.Equal("init failed", rec.Error)
+	assert.Equal(2, rec.InitOrder)
+}
+
+func TestRegistry_AnonymousComponent(t *testing.T) {
+	assert := assert2.New(t)
+	registry := NewRegistry()
+	filter := &TestFilter{id: "anonymous"}
+	// 先初始化，后注册的动态Filter沿用初始化状态
+	registry.MarkInitialized(filter, nil)
+	rec, ok := registry.ComponentOf(ComponentKindComponent, "*ext.TestFilter")
+	assert.True(ok)
+	assert.Equal(ComponentStateInitialized, rec.State)
+	registry.AddSelectiveFilter(filter)
+	_, ok = registry.ComponentOf(ComponentKindComponent, "*ext.TestFilter")
+	assert.False(ok)
+	rec, ok = registry.ComponentOf(ComponentKindFilter, "anonymous")
+	assert.True(ok)
+	assert.Equal(ComponentStateInitialized, rec.State)
+	assert.Equal(1, rec.InitOrder)
+}
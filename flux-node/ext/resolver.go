@@ -10,24 +10,51 @@ const (
 	DefaultMTValueResolverName = "default"
 )
 
-var (
-	mediaTypeValueResolvers = make(map[string]flux.MTValueResolver, 16)
-)
-
 // RegisterMTValueResolver 添加实际值类型解析函数
 func RegisterMTValueResolver(typeName string, resolver flux.MTValueResolver) {
+	defaultRegistry.RegisterMTValueResolver(typeName, resolver)
+}
+
+// MTValueResolverByType 获取值类型解析函数；如果指定类型不存在，返回默认解析函数；
+func MTValueResolverByType(typeName string) flux.MTValueResolver {
+	return defaultRegistry.MTValueResolverByType(typeName)
+}
+
+// MTValueResolvers 返回全部已注册的值类型解析函数
+func MTValueResolvers() map[string]flux.MTValueResolver {
+	return defaultRegistry.MTValueResolvers()
+}
+
+// RegisterMTValueResolver 注册参数值解析器
+func (r *Registry) RegisterMTValueResolver(typeName string, resolver flux.MTValueResolver) {
 	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
 	typeName = strings.ToLower(typeName)
-	mediaTypeValueResolvers[typeName] = resolver
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.resolvers[typeName] = resolver
+	r.record(ComponentKindResolver, typeName, resolver)
 }
 
 // MTValueResolverByType 获取值类型解析函数；如果指定类型不存在，返回默认解析函数；
-func MTValueResolverByType(typeName string) flux.MTValueResolver {
+func (r *Registry) MTValueResolverByType(typeName string) flux.MTValueResolver {
 	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
 	typeName = strings.ToLower(typeName)
-	if r, ok := mediaTypeValueResolvers[typeName]; ok {
-		return r
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	if v, ok := r.store.resolvers[typeName]; ok {
+		return v
 	} else {
-		return mediaTypeValueResolvers[DefaultMTValueResolverName]
+		return r.store.resolvers[DefaultMTValueResolverName]
+	}
+}
+
+// MTValueResolvers 返回全部已注册的值类型解析函数
+func (r *Registry) MTValueResolvers() map[string]flux.MTValueResolver {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make(map[string]flux.MTValueResolver, len(r.store.resolvers))
+	for k, v := range r.store.resolvers {
+		out[k] = v
 	}
+	return out
 }
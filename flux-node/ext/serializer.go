@@ -12,24 +12,18 @@ const (
 	TypeNameSerializerJson    = "json"
 )
 
-var (
-	typedSerializers = make(map[string]flux.Serializer, 2)
-)
-
 ////
 
 func RegisterSerializer(typeName string, serializer flux.Serializer) {
-	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
-	typedSerializers[typeName] = fluxpkg.MustNotNil(serializer, "Serializer is nil").(flux.Serializer)
+	defaultRegistry.RegisterSerializer(typeName, serializer)
 }
 
 func SerializerByType(typeName string) flux.Serializer {
-	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
-	return typedSerializers[typeName]
+	return defaultRegistry.SerializerByType(typeName)
 }
 
 func JSONMarshal(data interface{}) ([]byte, error) {
-	json := typedSerializers[TypeNameSerializerJson]
+	json := defaultRegistry.SerializerByType(TypeNameSerializerJson)
 	if nil == json {
 		return nil, errors.New("JSON serializer not found")
 	}
@@ -37,9 +31,42 @@ func JSONMarshal(data interface{}) ([]byte, error) {
 }
 
 func JSONUnmarshal(data []byte, out interface{}) error {
-	json := typedSerializers[TypeNameSerializerJson]
+	json := defaultRegistry.SerializerByType(TypeNameSerializerJson)
 	if nil == json {
 		return errors.New("JSON serializer not found")
 	}
 	return json.Unmarshal(data, out)
 }
+
+// Serializers 返回全部已注册的序列化实现
+func Serializers() map[string]flux.Serializer {
+	return defaultRegistry.Serializers()
+}
+
+// RegisterSerializer 注册序列化器
+func (r *Registry) RegisterSerializer(typeName string, serializer flux.Serializer) {
+	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
+	serializer = fluxpkg.MustNotNil(serializer, "Serializer is nil").(flux.Serializer)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.serializers[typeName] = serializer
+	r.record(ComponentKindSerializer, typeName, serializer)
+}
+
+func (r *Registry) SerializerByType(typeName string) flux.Serializer {
+	typeName = fluxpkg.MustNotEmpty(typeName, "typeName is empty")
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	return r.store.serializers[typeName]
+}
+
+// Serializers 返回全部已注册的序列化实现
+func (r *Registry) Serializers() map[string]flux.Serializer {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	out := make(map[string]flux.Serializer, len(r.store.serializers))
+	for k, v := range r.store.serializers {
+		out[k] = v
+	}
+	return out
+}
@@ -3,6 +3,7 @@ package ext
 import (
 	"fmt"
 	"github.com/bytepowered/flux/flux-node"
+	"sort"
 	"sync"
 )
 
@@ -23,7 +24,7 @@ func RegisterTransporterService(service flux.TransporterService) {
 
 func TransporterServices() map[string]flux.TransporterService {
 	out := make(map[string]flux.TransporterService, 512)
-	endpoints.Range(func(key, value interface{}) bool {
+	servicesMap.Range(func(key, value interface{}) bool {
 		out[key.(string)] = value.(flux.TransporterService)
 		return true
 	})
@@ -60,3 +61,91 @@ func _ensureServiceID(service *flux.TransporterService) string {
 	}
 	return id
 }
+
+var (
+	serviceRefs = &serviceRefTable{
+		byOwner:   make(map[string][]string, 64),
+		byService: make(map[string]map[string]struct{}, 64),
+	}
+)
+
+// serviceRefTable 记录Endpoint对Service的引用关系
+type serviceRefTable struct {
+	byOwner   map[string][]string            // owner -> serviceIds
+	byService map[string]map[string]struct{} // serviceId -> owners
+	mu        sync.RWMutex
+}
+
+// SetServiceRefs 设置引用方（通常为Endpoint的 Method#Pattern#Version）引用的服务ID列表，替换此引用方的原有引用
+func SetServiceRefs(owner string, serviceIds []string) {
+	serviceRefs.mu.Lock()
+	defer serviceRefs.mu.Unlock()
+	serviceRefs.remove(owner)
+	if len(serviceIds) == 0 {
+		return
+	}
+	serviceRefs.byOwner[owner] = serviceIds
+	for _, id := range serviceIds {
+		owners, ok := serviceRefs.byService[id]
+		if !ok {
+			owners = make(map[string]struct{}, 2)
+			serviceRefs.byService[id] = owners
+		}
+		owners[owner] = struct{}{}
+	}
+}
+
+// RemoveServiceRefs 删除引用方的全部服务引用
+func RemoveServiceRefs(owner string) {
+	serviceRefs.mu.Lock()
+	serviceRefs.remove(owner)
+	serviceRefs.mu.Unlock()
+}
+
+// ServiceRefs 返回引用指定服务的引用方列表
+func ServiceRefs(serviceId string) []string {
+	serviceRefs.mu.RLock()
+	defer serviceRefs.mu.RUnlock()
+	out := make([]string, 0, len(serviceRefs.byService[serviceId]))
+	for owner := range serviceRefs.byService[serviceId] {
+		out = append(out, owner)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ServiceRefCount 返回指定服务的引用计数
+func ServiceRefCount(serviceId string) int {
+	serviceRefs.mu.RLock()
+	defer serviceRefs.mu.RUnlock()
+	return len(serviceRefs.byService[serviceId])
+}
+
+// OrphanServiceRefs 返回被引用但未注册的服务ID，及其引用方列表
+func OrphanServiceRefs() map[string][]string {
+	serviceRefs.mu.RLock()
+	ids := make([]string, 0, len(serviceRefs.byService))
+	for id := range serviceRefs.byService {
+		ids = append(ids, id)
+	}
+	serviceRefs.mu.RUnlock()
+	out := make(map[string][]string, 4)
+	for _, id := range ids {
+		if !HasTransporterService(id) {
+			out[id] = ServiceRefs(id)
+		}
+	}
+	return out
+}
+
+func (t *serviceRefTable) remove(owner string) {
+	for _, id := range t.byOwner[owner] {
+		if owners, ok := t.byService[id]; ok {
+			delete(owners, owner)
+			if len(owners) == 0 {
+				delete(t.byService, id)
+			}
+		}
+	}
+	delete(t.byOwner, owner)
+}
@@ -0,0 +1,26 @@
+package ext
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	assert2 "github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestServiceRefs(t *testing.T) {
+	assert := assert2.New(t)
+	RegisterTransporterService(flux.TransporterService{ServiceId: "test.svc.a", Interface: "a", Method: "m"})
+	defer RemoveTransporterService("test.svc.a")
+	SetServiceRefs("GET#/a#v1", []string{"test.svc.a", "test.svc.b"})
+	SetServiceRefs("GET#/b#v1", []string{"test.svc.b"})
+	assert.Equal(1, ServiceRefCount("test.svc.a"))
+	assert.Equal([]string{"GET#/a#v1", "GET#/b#v1"}, ServiceRefs("test.svc.b"))
+	assert.Equal(map[string][]string{"test.svc.b": {"GET#/a#v1", "GET#/b#v1"}}, OrphanServiceRefs())
+	// Update replaces previous refs
+	SetServiceRefs("GET#/a#v1", []string{"test.svc.a"})
+	assert.Equal([]string{"GET#/b#v1"}, ServiceRefs("test.svc.b"))
+	RemoveServiceRefs("GET#/b#v1")
+	assert.Equal(0, ServiceRefCount("test.svc.b"))
+	assert.Equal(0, len(OrphanServiceRefs()))
+	RemoveServiceRefs("GET#/a#v1")
+	assert.Equal(0, ServiceRefCount("test.svc.a"))
+}
@@ -5,24 +5,69 @@ import (
 	"github.com/bytepowered/flux/flux-pkg"
 )
 
-var (
-	transporters = make(map[string]flux.Transporter, 4)
-)
-
 func RegisterTransporter(protoName string, transporter flux.Transporter) {
-	protoName = fluxpkg.MustNotEmpty(protoName, "protoName is empty")
-	transporters[protoName] = fluxpkg.MustNotNil(transporter, "Transporter is nil").(flux.Transporter)
+	defaultRegistry.RegisterTransporter(protoName, transporter)
+}
+
+// RegisterTransporterFactory 注册指定协议的Transporter工厂函数，并注册工厂函数创建的实例；
+// 配置变更时，通过工厂函数创建新实例来重新加载配置
+func RegisterTransporterFactory(protoName string, factory flux.Factory) {
+	defaultRegistry.RegisterTransporterFactory(protoName, factory)
+}
+
+func TransporterFactoryBy(protoName string) (flux.Factory, bool) {
+	return defaultRegistry.TransporterFactoryBy(protoName)
 }
 
 func TransporterBy(protoName string) (flux.Transporter, bool) {
+	return defaultRegistry.TransporterBy(protoName)
+}
+
+func Transporters() map[string]flux.Transporter {
+	return defaultRegistry.Transporters()
+}
+
+// RegisterTransporter 注册指定协议的Transporter
+func (r *Registry) RegisterTransporter(protoName string, transporter flux.Transporter) {
+	protoName = fluxpkg.MustNotEmpty(protoName, "protoName is empty")
+	transporter = fluxpkg.MustNotNil(transporter, "Transporter is nil").(flux.Transporter)
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.transporters[protoName] = transporter
+	r.record(ComponentKindTransporter, protoName, transporter)
+}
+
+// RegisterTransporterFactory 注册指定协议的Transporter工厂函数，并注册工厂函数创建的实例
+func (r *Registry) RegisterTransporterFactory(protoName string, factory flux.Factory) {
 	protoName = fluxpkg.MustNotEmpty(protoName, "protoName is empty")
-	transporter, ok := transporters[protoName]
+	factory = fluxpkg.MustNotNil(factory, "Factory is nil").(flux.Factory)
+	r.RegisterTransporter(protoName, fluxpkg.MustNotNil(factory(), "Transporter is nil").(flux.Transporter))
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.transporterFacts[protoName] = factory
+}
+
+func (r *Registry) TransporterFactoryBy(protoName string) (flux.Factory, bool) {
+	protoName = fluxpkg.MustNotEmpty(protoName, "protoName is empty")
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	factory, ok := r.store.transporterFacts[protoName]
+	return factory, ok
+}
+
+func (r *Registry) TransporterBy(protoName string) (flux.Transporter, bool) {
+	protoName = fluxpkg.MustNotEmpty(protoName, "protoName is empty")
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	transporter, ok := r.store.transporters[protoName]
 	return transporter, ok
 }
 
-func Transporters() map[string]flux.Transporter {
-	m := make(map[string]flux.Transporter, len(transporters))
-	for p, e := range transporters {
+func (r *Registry) Transporters() map[string]flux.Transporter {
+	r.store.mu.RLock()
+	defer r.store.mu.RUnlock()
+	m := make(map[string]flux.Transporter, len(r.store.transporters))
+	for p, e := range r.store.transporters {
 		m[p] = e
 	}
 	return m
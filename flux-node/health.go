@@ -0,0 +1,38 @@
+package flux
+
+import "context"
+
+const (
+	HealthStatusUp   = "UP"
+	HealthStatusDown = "DOWN"
+)
+
+// HealthIndicator 组件健康检查接口；Filter、Transporter、EndpointDiscovery等组件可实现此接口，参与服务就绪检查。
+type HealthIndicator interface {
+	// HealthId 返回健康检查项的标识
+	HealthId() string
+
+	// Health 执行健康检查，返回检查结果
+	Health(ctx context.Context) Health
+}
+
+// Health 健康检查结果
+type Health struct {
+	Status  string                 `json:"status"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// IsUp 判断检查结果是否为健康状态
+func (h Health) IsUp() bool {
+	return h.Status == HealthStatusUp
+}
+
+// NewHealthUp 构建健康状态的检查结果
+func NewHealthUp(details map[string]interface{}) Health {
+	return Health{Status: HealthStatusUp, Details: details}
+}
+
+// NewHealthDown 构建非健康状态的检查结果
+func NewHealthDown(details map[string]interface{}) Health {
+	return Health{Status: HealthStatusDown, Details: details}
+}
@@ -26,10 +26,19 @@ type (
 	Initializer interface {
 		Init(configuration *Configuration) error // 当服务初始化时，调用此函数
 	}
+	// Reloadable 配置变更时，重新加载配置；未实现此接口的组件，重新调用 Initializer.Init 函数
+	Reloadable interface {
+		OnReload(configuration *Configuration) error // 当配置变更时，调用此函数
+	}
 	// Orderer 用于定义顺序
 	Orderer interface {
 		Order() int // 返回排序顺序
 	}
+	// Dependent 声明组件启动依赖的其它组件：依赖的组件先于此组件启动，并在此组件停止之后停止；
+	// 组件名称格式为 <kind>:<id>，例如 discovery:zookeeper，transporter:dubbo；<kind>:* 表示该类型的全部组件。
+	Dependent interface {
+		DependsOn() []string // 返回依赖的组件名称列表
+	}
 )
 
 // 日志Logger接口定义
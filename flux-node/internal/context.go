@@ -7,12 +7,15 @@ import (
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/labstack/echo/v4"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 )
 
 var _ flux.ServerWebContext = new(EchoWebContext)
 
+var detached = echo.New()
+
 func NewServeWebContext(ctx echo.Context, reqid string, listener flux.WebListener) flux.ServerWebContext {
 	return &EchoWebContext{
 		echoc:     ctx,
@@ -22,6 +25,72 @@ func NewServeWebContext(ctx echo.Context, reqid string, listener flux.WebListene
 	}
 }
 
+// NewDetachedWebContext 复制请求的方法，URL，Header，路径参数，以及已读取的Body数据，创建与原请求生命周期无关的WebContext；
+// 写入的响应数据被丢弃。用于在后台协程中执行的请求副本，例如影子流量；必须在原请求的处理协程中调用。
+func NewDetachedWebContext(webex flux.ServerWebContext, body []byte) flux.ServerWebContext {
+	return NewForkedWebContext(webex, context.Background(), body)
+}
+
+// NewForkedWebContext 复制请求的方法，URL，Header，路径参数，以及已读取的Body数据，创建以指定Context为请求Context的WebContext；
+// 新的WebContext不与原请求共享变量及表单解析状态，写入的响应数据被丢弃。用于在其它协程中执行的子请求；必须在原请求的处理协程中调用。
+func NewForkedWebContext(webex flux.ServerWebContext, ctx context.Context, body []byte) flux.ServerWebContext {
+	request := webex.Request().Clone(ctx)
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	request.ContentLength = int64(len(body))
+	request.Form, request.PostForm, request.MultipartForm = nil, nil, nil
+	server := detached
+	if listener := webex.WebListener(); nil != listener {
+		if e, ok := listener.ShadowServer().(*echo.Echo); ok {
+			server = e
+		}
+	}
+	echoc := server.NewContext(request, &discardResponseWriter{header: make(http.Header)})
+	params := webex.PathVars()
+	names, values := make([]string, 0, len(params)), make([]string, 0, len(params))
+	for name := range params {
+		names, values = append(names, name), append(values, params.Get(name))
+	}
+	echoc.SetParamNames(names...)
+	echoc.SetParamValues(values...)
+	return NewServeWebContext(echoc, webex.RequestId(), webex.WebListener())
+}
+
+// NewRoutedWebContext 按WebListener的路由表匹配请求，创建携带路径参数的WebContext，写入的响应数据被丢弃；
+// 返回匹配的路由模式（/users/:id 格式）。用于不经过WebListener处理的请求，例如路由解释及跨域预检；
+// WebListener不支持路由查找时返回false。
+func NewRoutedWebContext(listener flux.WebListener, request *http.Request, reqid string) (flux.ServerWebContext, string, bool) {
+	server, ok := listener.ShadowServer().(*echo.Echo)
+	if !ok {
+		return nil, "", false
+	}
+	echoc := server.NewContext(request, &discardResponseWriter{header: make(http.Header)})
+	path := request.URL.RawPath
+	if path == "" {
+		path = request.URL.Path
+	}
+	server.Router().Find(request.Method, path, echoc)
+	return NewServeWebContext(echoc, reqid, listener), echoc.Path(), true
+}
+
+// discardResponseWriter 丢弃全部响应数据
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(_ int) {
+}
+
 type EchoWebContext struct {
 	listener  flux.WebListener
 	context   context.Context
@@ -13,14 +13,23 @@ const (
 	MIMEApplicationJSON            = "application/json"
 	MIMEApplicationJSONCharsetUTF8 = MIMEApplicationJSON + "; " + charsetUTF8
 	MIMEApplicationForm            = "application/x-www-form-urlencoded"
+	MIMEApplicationXML             = "application/xml"
+	MIMETextXML                    = "text/xml"
+	MIMEMultipartForm              = "multipart/form-data"
+	MIMETextPlain                  = "text/plain"
+	MIMETextPlainCharsetUTF8       = MIMETextPlain + "; " + charsetUTF8
+	MIMETextHTML                   = "text/html"
+	MIMETextHTMLCharsetUTF8        = MIMETextHTML + "; " + charsetUTF8
 )
 
 // Headers
 const (
 	HeaderAccept              = "Accept"
 	HeaderAcceptEncoding      = "Accept-Encoding"
+	HeaderAcceptLanguage      = "Accept-Language"
 	HeaderAllow               = "Allow"
 	HeaderAuthorization       = "Authorization"
+	HeaderCacheControl        = "Cache-Control"
 	HeaderContentDisposition  = "Content-Disposition"
 	HeaderContentEncoding     = "Content-Encoding"
 	HeaderContentLength       = "Content-Length"
@@ -44,6 +53,8 @@ const (
 	HeaderXRequestedWith      = "X-Requested-With"
 	HeaderServer              = "Server"
 	HeaderOrigin              = "Origin"
+	HeaderUserAgent           = "User-Agent"
+	HeaderReferer             = "Referer"
 
 	// Access control
 	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
@@ -65,6 +76,8 @@ const (
 	HeaderXCSRFToken                      = "X-CSRF-Token"
 	HeaderReferrerPolicy                  = "Referrer-Policy"
 
+	HeaderRetryAfter = "Retry-After"
+
 	// Ext
 	HeaderXRequestId = "X-Request-Id"
 )
@@ -79,6 +92,11 @@ const (
 	StatusServerError  = http.StatusInternalServerError
 	StatusBadGateway   = http.StatusBadGateway
 	StatusNoContent    = http.StatusNoContent
+	StatusTooMany      = http.StatusTooManyRequests
+	StatusUnavailable  = http.StatusServiceUnavailable
+	StatusUnsupported  = http.StatusUnsupportedMediaType
+	StatusTimeout      = http.StatusGatewayTimeout
+	StatusTooLarge     = http.StatusRequestEntityTooLarge
 )
 
 // Web interfaces defines
@@ -222,6 +240,9 @@ type WebListener interface {
 	// NotfoundHandle 调用NotFound处理函数
 	HandleNotfound(webex ServerWebContext) error
 
+	// SetMethodNotAllowedHandler 设置Web路由存在，但请求Method不匹配的处理函数
+	SetMethodNotAllowedHandler(h WebHandler)
+
 	// SetBodyResolver 设置Body体解析接口
 	SetBodyResolver(decoder WebBodyResolver)
 
@@ -244,6 +265,29 @@ type WebListener interface {
 	ShadowRouter() interface{}
 }
 
+// ACMEChallenger 通过ACME自动申请证书的WebListener实现此接口，提供HTTP-01验证请求的处理函数；
+// 验证请求由管理服务的 /.well-known/acme-challenge/ 路径处理。
+type ACMEChallenger interface {
+	// ACMEChallengeHandler 返回处理本WebListener证书域名验证请求的函数，其它域名的请求交由 next 处理；未开启ACME时返回false
+	ACMEChallengeHandler(next http.Handler) (http.Handler, bool)
+}
+
+// WebHandlerRegistrar Filter或EndpointSelector实现此接口，在Filter初始化后向WebListener注册自身提供的处理接口，例如签发Token的接口；
+// 服务对每个WebListener调用一次，由实现方按 listenerId 决定是否注册。
+type WebHandlerRegistrar interface {
+	// RegisterWebHandlers 向指定的WebListener注册处理接口
+	RegisterWebHandlers(listenerId string, server WebListener)
+}
+
+// BindableListener 支持先绑定监听地址再启动服务的WebListener实现此接口；
+// 服务启动时先绑定全部WebListener的地址，任一地址绑定失败时中止启动，不会出现部分服务已对外提供服务的状态。
+type BindableListener interface {
+	// Bind 绑定监听地址；Listen 时使用已绑定的地址
+	Bind() error
+	// BoundAddrs 返回已绑定的监听地址；绑定端口为0时返回实际分配的端口
+	BoundAddrs() []string
+}
+
 // EndpointSelector 用于请求处理前的动态选择Endpoint
 type EndpointSelector interface {
 	// Active 判定选择器是否激活
@@ -4,6 +4,7 @@ import (
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/common"
 	"github.com/bytepowered/flux/flux-node/logger"
+	"net/http"
 	"reflect"
 )
 
@@ -16,6 +17,15 @@ func DefaultNotfoundHandler(_ flux.ServerWebContext) error {
 	}
 }
 
+// DefaultMethodNotAllowedHandler 生成MethodNotAllowed错误，由ErrorHandler处理
+func DefaultMethodNotAllowedHandler(_ flux.ServerWebContext) error {
+	return &flux.ServeError{
+		StatusCode: http.StatusMethodNotAllowed,
+		ErrorCode:  flux.ErrorCodeRequestMethod,
+		Message:    flux.ErrorMessageWebServerRequestMethodNotAllowed,
+	}
+}
+
 func DefaultErrorHandler(webex flux.ServerWebContext, error error) {
 	if nil == error || (*flux.ServeError)(nil) == error || reflect.ValueOf(error).IsNil() {
 		return
@@ -34,7 +44,13 @@ func DefaultErrorHandler(webex flux.ServerWebContext, error error) {
 		logger.Trace(webex.RequestId()).Errorw("SERVER:ERROR_HANDLE", "error", err)
 		return
 	}
-	webex.ResponseWriter().Header().Add("X-Writer-Id", "Fx-EWriter")
+	header := webex.ResponseWriter().Header()
+	for key, values := range serr.Header {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	header.Add("X-Writer-Id", "Fx-EWriter")
 	if err := webex.Write(serr.StatusCode, flux.MIMEApplicationJSON, bytes); nil != err {
 		logger.Trace(webex.RequestId()).Errorw("SERVER:ERROR_HANDLE", "error", err)
 	}
@@ -0,0 +1,91 @@
+package listener
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-pkg"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ScopedHandler 按请求的Host和Path前缀选择处理函数，没有匹配时使用默认处理函数；
+// 用于为不同域名或路径前缀设置不同的NotFound，MethodNotAllowed处理函数，例如：/api 返回JSON错误，/web 返回SPA首页。
+type ScopedHandler struct {
+	fallback flux.WebHandler
+	scopes   []handlerScope
+	mu       sync.RWMutex
+}
+
+type handlerScope struct {
+	host    string
+	prefix  string
+	handler flux.WebHandler
+}
+
+func NewScopedHandler(fallback flux.WebHandler) *ScopedHandler {
+	return &ScopedHandler{
+		fallback: fluxpkg.MustNotNil(fallback, "fallback handler is nil").(flux.WebHandler),
+		scopes:   make([]handlerScope, 0, 4),
+	}
+}
+
+// SetFallback 设置没有匹配范围时的默认处理函数
+func (s *ScopedHandler) SetFallback(h flux.WebHandler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = fluxpkg.MustNotNil(h, "fallback handler is nil").(flux.WebHandler)
+}
+
+// AddScope 添加指定Host和Path前缀的处理函数；host为空时匹配全部Host，支持 *.example.com 通配；
+// 匹配顺序：指定Host优先于通配Host，通配Host优先于全部Host；Host相同优先级时，Path前缀越长越优先。
+func (s *ScopedHandler) AddScope(host, prefix string, h flux.WebHandler) {
+	fluxpkg.AssertNotNil(h, "scoped handler is nil")
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.scopes = append(s.scopes, handlerScope{host: strings.ToLower(host), prefix: prefix, handler: h})
+	sort.SliceStable(s.scopes, func(i, j int) bool {
+		a, b := s.scopes[i], s.scopes[j]
+		if ra, rb := scopeHostRank(a.host), scopeHostRank(b.host); ra != rb {
+			return ra > rb
+		}
+		return len(a.prefix) > len(b.prefix)
+	})
+}
+
+// Handle 选择匹配的处理函数处理请求
+func (s *ScopedHandler) Handle(webex flux.ServerWebContext) error {
+	host := strings.ToLower(webex.Host())
+	if h, _, err := net.SplitHostPort(host); nil == err {
+		host = h
+	}
+	path := webex.URL().Path
+	s.mu.RLock()
+	handler := s.fallback
+	for _, scope := range s.scopes {
+		if matchScopeHost(scope.host, host) && strings.HasPrefix(path, scope.prefix) {
+			handler = scope.handler
+			break
+		}
+	}
+	s.mu.RUnlock()
+	return handler(webex)
+}
+
+func scopeHostRank(pattern string) int {
+	switch {
+	case pattern == "":
+		return 0
+	case strings.HasPrefix(pattern, "*."):
+		return 1
+	default:
+		return 2
+	}
+}
+
+func matchScopeHost(pattern, host string) bool {
+	if pattern == "" || pattern == host {
+		return true
+	}
+	return strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])
+}
@@ -0,0 +1,57 @@
+package listener
+
+import (
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func namedHandler(name string) flux.WebHandler {
+	return func(_ flux.ServerWebContext) error {
+		return errors.New(name)
+	}
+}
+
+func scopedHandle(handler *ScopedHandler, target string) string {
+	webex := common.MockRequestContext("scoped", httptest.NewRequest(http.MethodGet, target, nil), nil, nil)
+	return handler.Handle(webex).Error()
+}
+
+func TestScopedHandler_Handle(t *testing.T) {
+	handler := NewScopedHandler(namedHandler("fallback"))
+	// 添加顺序不影响匹配优先级
+	handler.AddScope("", "/api", namedHandler("api"))
+	handler.AddScope("", "/api/v2", namedHandler("api-v2"))
+	handler.AddScope("", "/web", namedHandler("web"))
+	handler.AddScope("Admin.Example.com", "", namedHandler("admin"))
+	handler.AddScope("*.example.com", "/api", namedHandler("tenant-api"))
+	cases := []struct {
+		name     string
+		target   string
+		expected string
+	}{
+		{name: "fallback", target: "http://gateway/other", expected: "fallback"},
+		{name: "prefix", target: "http://gateway/api/users", expected: "api"},
+		{name: "longest-prefix", target: "http://gateway/api/v2/users", expected: "api-v2"},
+		{name: "prefix-root", target: "http://gateway/web", expected: "web"},
+		// 指定Host优先于通配Host及更长的Path前缀
+		{name: "host-over-prefix", target: "http://admin.example.com/api/v2/users", expected: "admin"},
+		{name: "host-case-port", target: "http://ADMIN.example.com:8080/any", expected: "admin"},
+		{name: "wildcard-host", target: "http://foo.example.com/api/v2/users", expected: "tenant-api"},
+		{name: "wildcard-nested", target: "http://a.b.example.com:443/api", expected: "tenant-api"},
+		{name: "wildcard-prefix-miss", target: "http://foo.example.com/web", expected: "web"},
+		// 通配符不匹配根域名及相同后缀的其它域名
+		{name: "wildcard-apex", target: "http://example.com/api/v2", expected: "api-v2"},
+		{name: "wildcard-suffix", target: "http://badexample.com/api", expected: "api"},
+		{name: "wildcard-fallback", target: "http://badexample.com/", expected: "fallback"},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, scopedHandle(handler, tc.target), tc.name)
+	}
+	handler.SetFallback(namedHandler("spa"))
+	assert.Equal(t, "spa", scopedHandle(handler, "http://gateway/other"))
+}
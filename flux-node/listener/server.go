@@ -15,6 +15,7 @@ func New(id string, config *flux.Configuration, wis []flux.WebInterceptor, opts
 	opts = append([]Option{
 		WithErrorHandler(DefaultErrorHandler),
 		WithNotfoundHandler(DefaultNotfoundHandler),
+		WithMethodNotAllowedHandler(DefaultMethodNotAllowedHandler),
 		WithInterceptors(wis),
 	}, opts...)
 	return NewWith(id, config, opts...)
@@ -43,6 +44,12 @@ func WithNotfoundHandler(f flux.WebHandler) Option {
 	}
 }
 
+func WithMethodNotAllowedHandler(f flux.WebHandler) Option {
+	return func(server flux.WebListener) {
+		server.SetMethodNotAllowedHandler(f)
+	}
+}
+
 func WithInterceptors(array []flux.WebInterceptor) Option {
 	return WithInterceptor(array...)
 }
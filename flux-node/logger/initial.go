@@ -69,6 +69,9 @@ func LoadConfig(logfile string) (zap.Config, error) {
 }
 
 func NewZapLogger(config zap.Config) *zap.SugaredLogger {
+	// 使用全局日志级别，支持运行时修改
+	levels.global.SetLevel(config.Level.Level())
+	config.Level = levels.global
 	zLogger, err := config.Build()
 	if nil != err {
 		panic(err)
@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	limited = NewLimitedLogging(5, time.Minute)
+)
+
+// LimitedLogging 按日志标签限制输出频率：每个周期内最多输出N条，超出的日志被丢弃并计数；
+// 下一周期首次输出时，附带上一周期被丢弃的日志数量；长时间不再出现的标签，在周期结束后输出汇总日志。
+type LimitedLogging struct {
+	burst   int
+	period  time.Duration
+	entries map[string]*limitedEntry
+	swept   time.Time
+	mu      sync.Mutex
+}
+
+type limitedEntry struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+func NewLimitedLogging(burst int, period time.Duration) *LimitedLogging {
+	return &LimitedLogging{
+		burst:   burst,
+		period:  period,
+		entries: make(map[string]*limitedEntry, 16),
+		swept:   time.Now(),
+	}
+}
+
+// SetLimitedLogging 设置限流日志的每周期输出数量及周期；输出数量为0时不限流
+func SetLimitedLogging(burst int, period time.Duration) {
+	limited.mu.Lock()
+	limited.burst = burst
+	limited.period = period
+	limited.mu.Unlock()
+}
+
+// Allow 判断指定标签的日志是否允许输出；允许时返回上一周期被丢弃的日志数量
+func (l *LimitedLogging) Allow(key string) (bool, int) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.burst <= 0 {
+		return true, 0
+	}
+	l.sweep(now)
+	entry, ok := l.entries[key]
+	if !ok {
+		entry = &limitedEntry{start: now}
+		l.entries[key] = entry
+	}
+	suppressed := 0
+	if now.Sub(entry.start) >= l.period {
+		suppressed = entry.suppressed
+		entry.start, entry.count, entry.suppressed = now, 0, 0
+	}
+	if entry.count >= l.burst {
+		entry.suppressed++
+		return false, 0
+	}
+	entry.count++
+	return true, suppressed
+}
+
+// sweep 输出已结束周期内被丢弃的日志汇总，并清理过期的标签
+func (l *LimitedLogging) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.period {
+		return
+	}
+	l.swept = now
+	for key, entry := range l.entries {
+		if now.Sub(entry.start) < l.period {
+			continue
+		}
+		if entry.suppressed > 0 {
+			simLogger.Warnw("LOGGER:SUPPRESSED", "tag", key, "suppressed", entry.suppressed, "period", l.period)
+		}
+		delete(l.entries, key)
+	}
+}
+
+// LimitedWarnw 按日志标签限流输出Warn日志，用于注册中心事件处理、数据解码等可能短时间大量重复的告警
+func LimitedWarnw(msg string, keysAndValues ...interface{}) {
+	if ok, suppressed := limited.Allow(msg); ok {
+		if suppressed > 0 {
+			keysAndValues = append(keysAndValues, "suppressed", suppressed)
+		}
+		simLogger.Warnw(msg, keysAndValues...)
+	}
+}
+
+// LimitedErrorw 按日志标签限流输出Error日志
+func LimitedErrorw(msg string, keysAndValues ...interface{}) {
+	if ok, suppressed := limited.Allow(msg); ok {
+		if suppressed > 0 {
+			keysAndValues = append(keysAndValues, "suppressed", suppressed)
+		}
+		simLogger.Errorw(msg, keysAndValues...)
+	}
+}
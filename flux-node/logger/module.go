@@ -0,0 +1,170 @@
+package logger
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sync"
+)
+
+const (
+	ModuleServer      = "server"
+	ModuleRoute       = "route"
+	ModuleFilter      = "filter"
+	ModuleTransporter = "transporter"
+	ModuleDiscovery   = "discovery"
+)
+
+const (
+	// 模块日志字段名称
+	Module = "module"
+)
+
+var (
+	levels = &moduleLevels{
+		global:  zap.NewAtomicLevelAt(zap.InfoLevel),
+		modules: make(map[string]zap.AtomicLevel, 8),
+		loggers: make(map[string]*zap.SugaredLogger, 8),
+	}
+	// 各模块包装Core的Option
+	moduleOptions = new(sync.Map)
+)
+
+// moduleLevels 全局日志级别及模块日志级别；未设置级别的模块使用全局日志级别
+type moduleLevels struct {
+	global  zap.AtomicLevel
+	modules map[string]zap.AtomicLevel
+	loggers map[string]*zap.SugaredLogger
+	mu      sync.RWMutex
+}
+
+func (m *moduleLevels) enabled(module string, level zapcore.Level) bool {
+	m.mu.RLock()
+	ml, ok := m.modules[module]
+	m.mu.RUnlock()
+	if ok {
+		return ml.Enabled(level)
+	}
+	return m.global.Enabled(level)
+}
+
+// SetLevel 运行时修改全局日志级别：debug，info，warn，error
+func SetLevel(level string) error {
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(level)); nil != err {
+		return fmt.Errorf("invalid log level: %s, err: %w", level, err)
+	}
+	levels.global.SetLevel(l)
+	return nil
+}
+
+// GetLevel 返回全局日志级别
+func GetLevel() string {
+	return levels.global.Level().String()
+}
+
+// SetModuleLevel 运行时修改模块日志级别：server，route，filter，transporter，discovery 等；级别为空时恢复使用全局日志级别
+func SetModuleLevel(module string, level string) error {
+	if module == "" {
+		return fmt.Errorf("log module is empty")
+	}
+	if level == "" {
+		levels.mu.Lock()
+		delete(levels.modules, module)
+		levels.mu.Unlock()
+		return nil
+	}
+	var l zapcore.Level
+	if err := l.UnmarshalText([]byte(level)); nil != err {
+		return fmt.Errorf("invalid log level: %s, module: %s, err: %w", level, module, err)
+	}
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	if ml, ok := levels.modules[module]; ok {
+		ml.SetLevel(l)
+	} else {
+		levels.modules[module] = zap.NewAtomicLevelAt(l)
+	}
+	return nil
+}
+
+// ModuleLevels 返回已设置日志级别的模块及其级别
+func ModuleLevels() map[string]string {
+	levels.mu.RLock()
+	defer levels.mu.RUnlock()
+	out := make(map[string]string, len(levels.modules))
+	for module, ml := range levels.modules {
+		out[module] = ml.Level().String()
+	}
+	return out
+}
+
+// WithModule 返回模块Logger；日志输出受模块日志级别控制，并附带 module 字段
+func WithModule(module string) flux.Logger {
+	levels.mu.RLock()
+	sugar, ok := levels.loggers[module]
+	levels.mu.RUnlock()
+	if ok {
+		return sugar
+	}
+	// 模块Logger由调用方直接调用，不跳过调用栈层级
+	sugar = moduleSugar(module, rawLogger)
+	levels.mu.Lock()
+	levels.loggers[module] = sugar
+	levels.mu.Unlock()
+	return sugar
+}
+
+// ModuleOf 将Logger转换为模块Logger；不支持的Logger实现原样返回。
+// 每个模块的包装Option只创建一次，module 字段在输出日志时追加，转换请求Logger时不复制其编码器。
+func ModuleOf(module string, logger flux.Logger) flux.Logger {
+	if sugar, ok := logger.(*zap.SugaredLogger); ok {
+		return moduleSugar(module, sugar)
+	}
+	return logger
+}
+
+func moduleSugar(module string, sugar *zap.SugaredLogger) *zap.SugaredLogger {
+	return sugar.Desugar().WithOptions(moduleOption(module)).Sugar()
+}
+
+func moduleOption(module string) zap.Option {
+	if opt, ok := moduleOptions.Load(module); ok {
+		return opt.(zap.Option)
+	}
+	opt, _ := moduleOptions.LoadOrStore(module, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		return &moduleCore{Core: core, module: module}
+	}))
+	return opt.(zap.Option)
+}
+
+// moduleCore 按模块日志级别过滤日志；模块日志级别可以低于全局日志级别
+type moduleCore struct {
+	zapcore.Core
+	module string
+}
+
+func (c *moduleCore) Enabled(level zapcore.Level) bool {
+	return levels.enabled(c.module, level)
+}
+
+func (c *moduleCore) With(fields []zapcore.Field) zapcore.Core {
+	return &moduleCore{Core: c.Core.With(fields), module: c.module}
+}
+
+func (c *moduleCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.Enabled(entry.Level) {
+		return checked
+	}
+	// 原Core启用该级别但丢弃日志时（例如采样），不输出日志；
+	// 模块日志级别低于全局日志级别时，跳过原Core的级别检查
+	if c.Core.Enabled(entry.Level) && nil == c.Core.Check(entry, nil) {
+		return checked
+	}
+	return checked.AddCore(entry, c)
+}
+
+func (c *moduleCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	return c.Core.Write(entry, append(fields, zap.String(Module, c.module)))
+}
@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"path/filepath"
+	"testing"
+)
+
+func TestWithModule(t *testing.T) {
+	assert := assert.New(t)
+	core, logs := observer.New(zapcore.InfoLevel)
+	SetSimpleLogger(zap.New(core, zap.AddCaller()).Sugar())
+	defer SetSimpleLogger(zap.S())
+
+	// 直接调用模块Logger时，输出调用方的位置
+	WithModule(ModuleServer).Infow("module-log")
+	// 模块Logger按模块缓存
+	assert.Equal(WithModule(ModuleServer), WithModule(ModuleServer))
+	entries := logs.TakeAll()
+	if assert.Len(entries, 1) {
+		assert.Equal("module_test.go", filepath.Base(entries[0].Caller.File))
+		assert.Equal(ModuleServer, entries[0].ContextMap()[Module])
+	}
+
+	// 模块日志级别低于全局日志级别
+	assert.NoError(SetModuleLevel(ModuleRoute, "debug"))
+	defer SetModuleLevel(ModuleRoute, "")
+	ModuleOf(ModuleRoute, NewWith(TraceId, "t1")).Debugw("route-log")
+	ModuleOf(ModuleServer, NewWith(TraceId, "t2")).Debugw("server-log")
+	entries = logs.TakeAll()
+	if assert.Len(entries, 1) {
+		assert.Equal("route-log", entries[0].Message)
+		assert.Equal(map[string]interface{}{TraceId: "t1", Module: ModuleRoute}, entries[0].ContextMap())
+	}
+}
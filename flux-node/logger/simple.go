@@ -7,6 +7,8 @@ import (
 
 var (
 	simLogger *zap.SugaredLogger
+	// 未增加调用栈跳过层级的原始Logger，用于构建直接调用的模块Logger
+	rawLogger *zap.SugaredLogger
 )
 
 func init() {
@@ -15,7 +17,11 @@ func init() {
 
 // SetSimpleLogger set simple logger instance
 func SetSimpleLogger(logger *zap.SugaredLogger) {
+	rawLogger = logger
 	simLogger = logger.Desugar().WithOptions(zap.AddCallerSkip(1)).Sugar()
+	levels.mu.Lock()
+	levels.loggers = make(map[string]*zap.SugaredLogger, 8)
+	levels.mu.Unlock()
 }
 
 // SimpleLogger get a simple logger instance
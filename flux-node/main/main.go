@@ -3,23 +3,18 @@ package main
 import (
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/server"
-	_ "github.com/bytepowered/flux/flux-node/transporter/dubbo"
-	_ "github.com/bytepowered/flux/flux-node/transporter/echo"
 	_ "github.com/bytepowered/flux/flux-node/transporter/http"
 	_ "github.com/bytepowered/flux/flux-node/webecho"
 )
 
-import (
-	_ "github.com/apache/dubbo-go/filter/filter_impl"
-	_ "github.com/apache/dubbo-go/registry/zookeeper"
-)
-
 var (
 	GitCommit string
 	Version   string
 	BuildDate string
 )
 
+// 可选模块通过构建标签裁剪（见 module_*.go）：no_dubbo，no_echo，no_zookeeper；
+// 例如边缘部署只需要Http转发及文件注册中心：go build -tags "no_dubbo no_echo no_zookeeper"
 // 注意：自定义实现main方法时，需要导入WebServer实现模块；
 // 或者导入 _ "github.com/bytepowered/flux/webecho" 自动注册WebServer；
 func main() {
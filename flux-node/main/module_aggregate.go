@@ -0,0 +1,9 @@
+//go:build !no_aggregate
+// +build !no_aggregate
+
+package main
+
+// 使用构建标签 no_aggregate 排除响应聚合网关服务
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/aggregate"
+)
@@ -0,0 +1,11 @@
+//go:build !no_dubbo
+// +build !no_dubbo
+
+package main
+
+// 使用构建标签 no_dubbo 排除Dubbo协议转发及dubbo-go依赖
+import (
+	_ "github.com/apache/dubbo-go/filter/filter_impl"
+	_ "github.com/apache/dubbo-go/registry/zookeeper"
+	_ "github.com/bytepowered/flux/flux-node/transporter/dubbo"
+)
@@ -0,0 +1,9 @@
+//go:build !no_echo
+// +build !no_echo
+
+package main
+
+// 使用构建标签 no_echo 排除Echo调试协议
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/echo"
+)
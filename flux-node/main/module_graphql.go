@@ -0,0 +1,9 @@
+//go:build !no_graphql
+// +build !no_graphql
+
+package main
+
+// 使用构建标签 no_graphql 排除GraphQL网关服务
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/graphql"
+)
@@ -0,0 +1,9 @@
+//go:build !no_mock
+// +build !no_mock
+
+package main
+
+// 使用构建标签 no_mock 排除静态模拟响应网关服务
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/mock"
+)
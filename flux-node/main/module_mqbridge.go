@@ -0,0 +1,9 @@
+//go:build !no_mqbridge
+// +build !no_mqbridge
+
+package main
+
+// 使用构建标签 no_mqbridge 排除RocketMQ，AMQP消息桥接网关服务
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/mqbridge"
+)
@@ -0,0 +1,9 @@
+//go:build !no_pipeline
+// +build !no_pipeline
+
+package main
+
+// 使用构建标签 no_pipeline 排除顺序编排网关服务
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/pipeline"
+)
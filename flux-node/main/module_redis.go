@@ -0,0 +1,9 @@
+//go:build !no_redis
+// +build !no_redis
+
+package main
+
+// 使用构建标签 no_redis 排除Redis命令网关服务
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/redis"
+)
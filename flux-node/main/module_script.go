@@ -0,0 +1,9 @@
+//go:build !no_script
+// +build !no_script
+
+package main
+
+// 使用构建标签 no_script 排除脚本网关服务
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/script"
+)
@@ -0,0 +1,11 @@
+//go:build wasm
+// +build wasm
+
+package main
+
+// WebAssembly插件Filter及网关服务需要使用方通过 wasm.SetEngine 设置运行时，默认不导入；
+// 使用构建标签 wasm 导入：go build -tags "wasm"
+import (
+	_ "github.com/bytepowered/flux/flux-node/transporter/wasm"
+	_ "github.com/bytepowered/flux/flux-node/wasm"
+)
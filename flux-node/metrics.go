@@ -0,0 +1,60 @@
+package flux
+
+// MetricOpts 指标定义
+type MetricOpts struct {
+	Namespace string
+	Subsystem string
+	Name      string
+	Help      string
+	Labels    []string
+	Buckets   []float64 // 仅 Histogram 类型有效
+}
+
+// FullName 返回指标全名：<namespace>_<subsystem>_<name>
+func (o MetricOpts) FullName() string {
+	name := o.Name
+	if o.Subsystem != "" {
+		name = o.Subsystem + "_" + name
+	}
+	if o.Namespace != "" {
+		name = o.Namespace + "_" + name
+	}
+	return name
+}
+
+type (
+	// MetricsSink 网关指标的输出实现，例如 Prometheus，StatsD
+	MetricsSink interface {
+		NewCounter(opts MetricOpts) CounterVec
+		NewGauge(opts MetricOpts) GaugeVec
+		NewHistogram(opts MetricOpts) HistogramVec
+	}
+	// CounterVec 按标签值区分的计数指标
+	CounterVec interface {
+		WithLabelValues(values ...string) Counter
+	}
+	// Counter 计数指标
+	Counter interface {
+		Inc()
+		Add(value float64)
+	}
+	// GaugeVec 按标签值区分的瞬时值指标
+	GaugeVec interface {
+		WithLabelValues(values ...string) Gauge
+	}
+	// Gauge 瞬时值指标
+	Gauge interface {
+		Set(value float64)
+		Inc()
+		Dec()
+		Add(value float64)
+	}
+	// HistogramVec 按标签值区分的分布统计指标
+	HistogramVec interface {
+		WithLabelValues(values ...string) Observer
+	}
+	// Observer 分布统计指标；与 prometheus.Observer 兼容
+	Observer interface {
+		Observe(value float64)
+	}
+)
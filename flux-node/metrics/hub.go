@@ -0,0 +1,181 @@
+package metrics
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	_ flux.MetricsSink = new(Hub)
+)
+
+// Hub 将指标同时输出到多个 MetricsSink；输出目标可在指标创建之后通过 Bind 重新设置，
+// 已创建的指标在下一次记录时输出到新的目标。
+type Hub struct {
+	sinks    []flux.MetricsSink
+	bindings []func([]flux.MetricsSink)
+	mu       sync.Mutex
+}
+
+func NewHub(sinks ...flux.MetricsSink) *Hub {
+	return &Hub{sinks: sinks}
+}
+
+// Bind 重新设置指标的输出目标
+func (h *Hub) Bind(sinks ...flux.MetricsSink) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sinks = sinks
+	for _, bind := range h.bindings {
+		bind(sinks)
+	}
+}
+
+// Sinks 返回当前的指标输出目标
+func (h *Hub) Sinks() []flux.MetricsSink {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]flux.MetricsSink, len(h.sinks))
+	copy(out, h.sinks)
+	return out
+}
+
+func (h *Hub) NewCounter(opts flux.MetricOpts) flux.CounterVec {
+	vec := new(hubCounterVec)
+	h.register(func(sinks []flux.MetricsSink) {
+		vecs := make([]flux.CounterVec, len(sinks))
+		for i, sink := range sinks {
+			vecs[i] = sink.NewCounter(opts)
+		}
+		vec.vecs.Store(vecs)
+	})
+	return vec
+}
+
+func (h *Hub) NewGauge(opts flux.MetricOpts) flux.GaugeVec {
+	vec := new(hubGaugeVec)
+	h.register(func(sinks []flux.MetricsSink) {
+		vecs := make([]flux.GaugeVec, len(sinks))
+		for i, sink := range sinks {
+			vecs[i] = sink.NewGauge(opts)
+		}
+		vec.vecs.Store(vecs)
+	})
+	return vec
+}
+
+func (h *Hub) NewHistogram(opts flux.MetricOpts) flux.HistogramVec {
+	vec := new(hubHistogramVec)
+	h.register(func(sinks []flux.MetricsSink) {
+		vecs := make([]flux.HistogramVec, len(sinks))
+		for i, sink := range sinks {
+			vecs[i] = sink.NewHistogram(opts)
+		}
+		vec.vecs.Store(vecs)
+	})
+	return vec
+}
+
+func (h *Hub) register(bind func([]flux.MetricsSink)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.bindings = append(h.bindings, bind)
+	bind(h.sinks)
+}
+
+type hubCounterVec struct {
+	vecs atomic.Value
+}
+
+func (v *hubCounterVec) WithLabelValues(values ...string) flux.Counter {
+	vecs := v.vecs.Load().([]flux.CounterVec)
+	if len(vecs) == 1 {
+		return vecs[0].WithLabelValues(values...)
+	}
+	out := make(multiCounter, len(vecs))
+	for i, vec := range vecs {
+		out[i] = vec.WithLabelValues(values...)
+	}
+	return out
+}
+
+type hubGaugeVec struct {
+	vecs atomic.Value
+}
+
+func (v *hubGaugeVec) WithLabelValues(values ...string) flux.Gauge {
+	vecs := v.vecs.Load().([]flux.GaugeVec)
+	if len(vecs) == 1 {
+		return vecs[0].WithLabelValues(values...)
+	}
+	out := make(multiGauge, len(vecs))
+	for i, vec := range vecs {
+		out[i] = vec.WithLabelValues(values...)
+	}
+	return out
+}
+
+type hubHistogramVec struct {
+	vecs atomic.Value
+}
+
+func (v *hubHistogramVec) WithLabelValues(values ...string) flux.Observer {
+	vecs := v.vecs.Load().([]flux.HistogramVec)
+	if len(vecs) == 1 {
+		return vecs[0].WithLabelValues(values...)
+	}
+	out := make(multiObserver, len(vecs))
+	for i, vec := range vecs {
+		out[i] = vec.WithLabelValues(values...)
+	}
+	return out
+}
+
+type multiCounter []flux.Counter
+
+func (m multiCounter) Inc() {
+	for _, c := range m {
+		c.Inc()
+	}
+}
+
+func (m multiCounter) Add(value float64) {
+	for _, c := range m {
+		c.Add(value)
+	}
+}
+
+type multiGauge []flux.Gauge
+
+func (m multiGauge) Set(value float64) {
+	for _, g := range m {
+		g.Set(value)
+	}
+}
+
+func (m multiGauge) Inc() {
+	for _, g := range m {
+		g.Inc()
+	}
+}
+
+func (m multiGauge) Dec() {
+	for _, g := range m {
+		g.Dec()
+	}
+}
+
+func (m multiGauge) Add(value float64) {
+	for _, g := range m {
+		g.Add(value)
+	}
+}
+
+type multiObserver []flux.Observer
+
+func (m multiObserver) Observe(value float64) {
+	for _, o := range m {
+		o.Observe(value)
+	}
+}
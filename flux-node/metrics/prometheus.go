@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/prometheus/client_golang/prometheus"
+	"sync"
+)
+
+const (
+	SinkPrometheus = "prometheus"
+)
+
+var (
+	_ flux.MetricsSink = new(PrometheusSink)
+)
+
+// PrometheusSink 输出到Prometheus默认注册中心的指标实现；同名指标只注册一次
+type PrometheusSink struct {
+	vecs map[string]interface{}
+	mu   sync.Mutex
+}
+
+func NewPrometheusSink() *PrometheusSink {
+	return &PrometheusSink{
+		vecs: make(map[string]interface{}, 16),
+	}
+}
+
+func (s *PrometheusSink) NewCounter(opts flux.MetricOpts) flux.CounterVec {
+	vec := s.lookup(opts, func() interface{} {
+		return register(prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: opts.Namespace, Subsystem: opts.Subsystem, Name: opts.Name, Help: opts.Help,
+		}, opts.Labels))
+	})
+	return &promCounterVec{vec: vec.(*prometheus.CounterVec)}
+}
+
+func (s *PrometheusSink) NewGauge(opts flux.MetricOpts) flux.GaugeVec {
+	vec := s.lookup(opts, func() interface{} {
+		return register(prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: opts.Namespace, Subsystem: opts.Subsystem, Name: opts.Name, Help: opts.Help,
+		}, opts.Labels))
+	})
+	return &promGaugeVec{vec: vec.(*prometheus.GaugeVec)}
+}
+
+func (s *PrometheusSink) NewHistogram(opts flux.MetricOpts) flux.HistogramVec {
+	vec := s.lookup(opts, func() interface{} {
+		return register(prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: opts.Namespace, Subsystem: opts.Subsystem, Name: opts.Name, Help: opts.Help,
+			Buckets: opts.Buckets,
+		}, opts.Labels))
+	})
+	return &promHistogramVec{vec: vec.(*prometheus.HistogramVec)}
+}
+
+func (s *PrometheusSink) lookup(opts flux.MetricOpts, factory func() interface{}) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	name := opts.FullName()
+	if vec, ok := s.vecs[name]; ok {
+		return vec
+	}
+	vec := factory()
+	s.vecs[name] = vec
+	return vec
+}
+
+// register 注册到Prometheus默认注册中心；已由其它Sink实例注册的同名指标，复用已注册的指标
+func register(vec prometheus.Collector) interface{} {
+	if err := prometheus.Register(vec); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return are.ExistingCollector
+		}
+		panic(err)
+	}
+	return vec
+}
+
+type promCounterVec struct {
+	vec *prometheus.CounterVec
+}
+
+func (v *promCounterVec) WithLabelValues(values ...string) flux.Counter {
+	return v.vec.WithLabelValues(values...)
+}
+
+type promGaugeVec struct {
+	vec *prometheus.GaugeVec
+}
+
+func (v *promGaugeVec) WithLabelValues(values ...string) flux.Gauge {
+	return v.vec.WithLabelValues(values...)
+}
+
+type promHistogramVec struct {
+	vec *prometheus.HistogramVec
+}
+
+func (v *promHistogramVec) WithLabelValues(values ...string) flux.Observer {
+	return v.vec.WithLabelValues(values...)
+}
@@ -0,0 +1,323 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	SinkStatsd = "statsd"
+)
+
+const (
+	ConfigKeyStatsdAddress       = "address"
+	ConfigKeyStatsdPrefix        = "prefix"
+	ConfigKeyStatsdDogStatsd     = "dogstatsd"
+	ConfigKeyStatsdTags          = "tags"
+	ConfigKeyStatsdFlushInterval = "flush_interval"
+	ConfigKeyStatsdMaxPacketSize = "max_packet_size"
+	ConfigKeyStatsdQueueSize     = "queue_size"
+)
+
+var (
+	_ flux.MetricsSink = new(StatsdSink)
+	_ flux.Shutdowner  = new(StatsdSink)
+)
+
+// StatsdSink 以UDP协议输出StatsD指标；开启 dogstatsd 时标签以DogStatsD的 |#key:value 格式输出，
+// 否则标签值以 . 连接追加到指标名称。Histogram指标在DogStatsD中以 |h 输出，在StatsD中以 |ms 输出；
+// StatsD的 |ms 数值单位为毫秒，耗时指标（名称以 _duration 或 _seconds 结尾，数值单位为秒）输出时转换为毫秒。
+// 指标在内存队列中缓冲，按周期或数据包长度批量发送；队列已满时丢弃。
+type StatsdSink struct {
+	prefix    string
+	dogstatsd bool
+	tags      string
+	maxPacket int
+	interval  time.Duration
+	conn      net.Conn
+	queue     chan string
+	done      chan struct{}
+	closed    chan struct{}
+	dropped   uint64
+	once      sync.Once
+}
+
+// NewStatsdSink 按配置创建StatsD输出，并启动后台发送
+func NewStatsdSink(config *flux.Configuration) (*StatsdSink, error) {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyStatsdAddress:       "127.0.0.1:8125",
+		ConfigKeyStatsdDogStatsd:     true,
+		ConfigKeyStatsdFlushInterval: time.Second,
+		ConfigKeyStatsdMaxPacketSize: 1432,
+		ConfigKeyStatsdQueueSize:     8192,
+	})
+	address := config.GetString(ConfigKeyStatsdAddress)
+	conn, err := net.Dial("udp", address)
+	if nil != err {
+		return nil, fmt.Errorf("dial statsd: %s, err: %w", address, err)
+	}
+	sink := &StatsdSink{
+		prefix:    config.GetString(ConfigKeyStatsdPrefix),
+		dogstatsd: config.GetBool(ConfigKeyStatsdDogStatsd),
+		maxPacket: config.GetInt(ConfigKeyStatsdMaxPacketSize),
+		interval:  config.GetDuration(ConfigKeyStatsdFlushInterval),
+		conn:      conn,
+		queue:     make(chan string, config.GetInt(ConfigKeyStatsdQueueSize)),
+		done:      make(chan struct{}),
+		closed:    make(chan struct{}),
+	}
+	if tags := config.GetStringMapString(ConfigKeyStatsdTags); len(tags) > 0 {
+		pairs := make([]string, 0, len(tags))
+		for k, v := range tags {
+			pairs = append(pairs, k+":"+v)
+		}
+		sort.Strings(pairs)
+		sink.tags = strings.Join(pairs, ",")
+	}
+	logger.Infow("Metrics statsd sink init", "address", address, "prefix", sink.prefix,
+		"dogstatsd", sink.dogstatsd, "flush-interval", sink.interval, "max-packet-size", sink.maxPacket)
+	go sink.loop()
+	return sink, nil
+}
+
+func (s *StatsdSink) NewCounter(opts flux.MetricOpts) flux.CounterVec {
+	return &statsdCounterVec{sink: s, opts: opts}
+}
+
+func (s *StatsdSink) NewGauge(opts flux.MetricOpts) flux.GaugeVec {
+	return &statsdGaugeVec{sink: s, opts: opts, gauges: make(map[string]*statsdGauge, 16)}
+}
+
+func (s *StatsdSink) NewHistogram(opts flux.MetricOpts) flux.HistogramVec {
+	return &statsdHistogramVec{sink: s, opts: opts}
+}
+
+// Dropped 返回因队列已满而丢弃的指标数量
+func (s *StatsdSink) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Shutdown 发送队列中剩余的指标，并关闭连接
+func (s *StatsdSink) Shutdown(ctx context.Context) error {
+	s.once.Do(func() {
+		close(s.done)
+	})
+	select {
+	case <-s.closed:
+	case <-ctx.Done():
+	}
+	return s.conn.Close()
+}
+
+// metric 格式化指标数据行：<name>:<value>|<type>[|#tags]
+func (s *StatsdSink) metric(opts flux.MetricOpts, values []string, value float64, typ string) string {
+	var sb strings.Builder
+	sb.WriteString(s.prefix)
+	sb.WriteString(opts.FullName())
+	if !s.dogstatsd {
+		for _, v := range values {
+			sb.WriteByte('.')
+			sb.WriteString(sanitize(v))
+		}
+	}
+	sb.WriteByte(':')
+	sb.WriteString(strconv.FormatFloat(value, 'f', -1, 64))
+	sb.WriteByte('|')
+	sb.WriteString(typ)
+	if s.dogstatsd && (len(values) > 0 || s.tags != "") {
+		sb.WriteString("|#")
+		sb.WriteString(s.tags)
+		for i, v := range values {
+			if i < len(opts.Labels) {
+				if i > 0 || s.tags != "" {
+					sb.WriteByte(',')
+				}
+				sb.WriteString(opts.Labels[i])
+				sb.WriteByte(':')
+				sb.WriteString(sanitize(v))
+			}
+		}
+	}
+	return sb.String()
+}
+
+func (s *StatsdSink) send(line string) {
+	select {
+	case s.queue <- line:
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+	}
+}
+
+func (s *StatsdSink) loop() {
+	defer close(s.closed)
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	buffer := make([]byte, 0, s.maxPacket)
+	flush := func() {
+		if len(buffer) > 0 {
+			if _, err := s.conn.Write(buffer); nil != err {
+				logger.LimitedWarnw("METRICS:STATSD:SEND/ERROR", "error", err)
+			}
+			buffer = buffer[:0]
+		}
+	}
+	write := func(line string) {
+		if len(buffer) > 0 && len(buffer)+1+len(line) > s.maxPacket {
+			flush()
+		}
+		if len(buffer) > 0 {
+			buffer = append(buffer, '\n')
+		}
+		buffer = append(buffer, line...)
+	}
+	for {
+		select {
+		case line := <-s.queue:
+			write(line)
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case line := <-s.queue:
+					write(line)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// sanitize 替换StatsD协议的保留字符
+func sanitize(v string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', '@', ',', '#', '\n', ' ':
+			return '_'
+		}
+		return r
+	}, v)
+}
+
+type statsdCounterVec struct {
+	sink *StatsdSink
+	opts flux.MetricOpts
+}
+
+func (v *statsdCounterVec) WithLabelValues(values ...string) flux.Counter {
+	return &statsdCounter{vec: v, values: values}
+}
+
+type statsdCounter struct {
+	vec    *statsdCounterVec
+	values []string
+}
+
+func (c *statsdCounter) Inc() {
+	c.Add(1)
+}
+
+func (c *statsdCounter) Add(value float64) {
+	c.vec.sink.send(c.vec.sink.metric(c.vec.opts, c.values, value, "c"))
+}
+
+// statsdGaugeVec 在本地保存每个标签组合的当前值，以绝对值输出，保证 Inc/Dec 与 Set 语义一致
+type statsdGaugeVec struct {
+	sink   *StatsdSink
+	opts   flux.MetricOpts
+	gauges map[string]*statsdGauge
+	mu     sync.Mutex
+}
+
+func (v *statsdGaugeVec) WithLabelValues(values ...string) flux.Gauge {
+	key := strings.Join(values, "\x00")
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	gauge, ok := v.gauges[key]
+	if !ok {
+		gauge = &statsdGauge{vec: v, values: values}
+		v.gauges[key] = gauge
+	}
+	return gauge
+}
+
+type statsdGauge struct {
+	bits   uint64
+	vec    *statsdGaugeVec
+	values []string
+}
+
+func (g *statsdGauge) Set(value float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(value))
+	g.emit(value)
+}
+
+func (g *statsdGauge) Inc() {
+	g.Add(1)
+}
+
+func (g *statsdGauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *statsdGauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		value := math.Float64frombits(old) + delta
+		if atomic.CompareAndSwapUint64(&g.bits, old, math.Float64bits(value)) {
+			g.emit(value)
+			return
+		}
+	}
+}
+
+func (g *statsdGauge) emit(value float64) {
+	sink := g.vec.sink
+	// StatsD协议中带符号的Gauge值表示增量；负数绝对值需要先置零
+	if value < 0 {
+		sink.send(sink.metric(g.vec.opts, g.values, 0, "g"))
+	}
+	sink.send(sink.metric(g.vec.opts, g.values, value, "g"))
+}
+
+type statsdHistogramVec struct {
+	sink *StatsdSink
+	opts flux.MetricOpts
+}
+
+func (v *statsdHistogramVec) WithLabelValues(values ...string) flux.Observer {
+	return &statsdObserver{vec: v, values: values}
+}
+
+type statsdObserver struct {
+	vec    *statsdHistogramVec
+	values []string
+}
+
+func (o *statsdObserver) Observe(value float64) {
+	typ := "ms"
+	if o.vec.sink.dogstatsd {
+		typ = "h"
+	} else if isSecondsMetric(o.vec.opts) {
+		value *= 1000
+	}
+	o.vec.sink.send(o.vec.sink.metric(o.vec.opts, o.values, value, typ))
+}
+
+// isSecondsMetric 判断指标数值是否以秒为单位
+func isSecondsMetric(opts flux.MetricOpts) bool {
+	return strings.HasSuffix(opts.Name, "_duration") || strings.HasSuffix(opts.Name, "_seconds")
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	assert2 "github.com/stretchr/testify/assert"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatsdSink_Hub(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	assert := assert2.New(t)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer conn.Close()
+	sink, err := NewStatsdSink(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyStatsdAddress: conn.LocalAddr().String(),
+		ConfigKeyStatsdTags:    map[string]interface{}{"env": "test"},
+	}))
+	assert.NoError(err)
+
+	hub := NewHub()
+	counter := hub.NewCounter(flux.MetricOpts{Namespace: "flux", Name: "access_total", Labels: []string{"Method"}})
+	gauge := hub.NewGauge(flux.MetricOpts{Namespace: "flux", Name: "healthy", Labels: []string{"Instance"}})
+	// 未绑定输出目标时不记录
+	counter.WithLabelValues("GET").Inc()
+	hub.Bind(sink)
+	counter.WithLabelValues("GET").Add(2)
+	gauge.WithLabelValues("a").Inc()
+	gauge.WithLabelValues("a").Inc()
+	assert.NoError(sink.Shutdown(context.Background()))
+
+	buf := make([]byte, 2048)
+	assert.NoError(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"flux_access_total:2|c|#env:test,Method:GET",
+		"flux_healthy:1|g|#env:test,Instance:a",
+		"flux_healthy:2|g|#env:test,Instance:a",
+	}, strings.Split(string(buf[:n]), "\n"))
+	assert.Equal(uint64(0), sink.Dropped())
+}
+
+func TestStatsdSink_Timing(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	assert := assert2.New(t)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(err)
+	defer conn.Close()
+	sink, err := NewStatsdSink(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyStatsdAddress:   conn.LocalAddr().String(),
+		ConfigKeyStatsdDogStatsd: false,
+	}))
+	assert.NoError(err)
+	hub := NewHub()
+	hub.Bind(sink)
+	// 耗时指标以秒为单位记录，StatsD按毫秒输出
+	hub.NewHistogram(flux.MetricOpts{Namespace: "flux", Name: "route_duration", Labels: []string{"Method"}}).
+		WithLabelValues("GET").Observe(0.25)
+	hub.NewHistogram(flux.MetricOpts{Namespace: "flux", Name: "response_bytes"}).WithLabelValues().Observe(512)
+	assert.NoError(sink.Shutdown(context.Background()))
+
+	buf := make([]byte, 2048)
+	assert.NoError(conn.SetReadDeadline(time.Now().Add(time.Second)))
+	n, _, err := conn.ReadFrom(buf)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"flux_route_duration.GET:250|ms",
+		"flux_response_bytes:512|ms",
+	}, strings.Split(string(buf[:n]), "\n"))
+}
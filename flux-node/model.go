@@ -3,6 +3,7 @@ package flux
 import (
 	fluxpkg "github.com/bytepowered/flux/flux-pkg"
 	"github.com/spf13/cast"
+	"sort"
 	"strings"
 	"sync"
 )
@@ -60,10 +61,19 @@ const (
 
 // Support protocols
 const (
-	ProtoDubbo = "DUBBO"
-	ProtoGRPC  = "GRPC"
-	ProtoHttp  = "HTTP"
-	ProtoEcho  = "ECHO"
+	ProtoDubbo     = "DUBBO"
+	ProtoGRPC      = "GRPC"
+	ProtoHttp      = "HTTP"
+	ProtoEcho      = "ECHO"
+	ProtoGraphQL   = "GRAPHQL"
+	ProtoAggregate = "AGGREGATE"
+	ProtoPipeline  = "PIPELINE"
+	ProtoRocketMQ  = "ROCKETMQ"
+	ProtoAMQP      = "AMQP"
+	ProtoRedis     = "REDIS"
+	ProtoMock      = "MOCK"
+	ProtoScript    = "SCRIPT"
+	ProtoWasm      = "WASM"
 )
 
 // ServiceAttributes
@@ -78,10 +88,19 @@ const (
 
 // EndpointAttributes
 const (
-	EndpointAttrTagNotDefined = ""           // 默认的，未定义的属性
-	EndpointAttrTagAuthorize  = "authorize"  // 标识Endpoint访问是否需要授权
-	EndpointAttrTagListenerId = "listenerid" // 标识Endpoint绑定到哪个ListenServer服务
-	EndpointAttrTagBizId      = "bizid"      // 标识Endpoint绑定到业务标识
+	EndpointAttrTagNotDefined    = ""              // 默认的，未定义的属性
+	EndpointAttrTagAuthorize     = "authorize"     // 标识Endpoint访问是否需要授权
+	EndpointAttrTagListenerId    = "listenerid"    // 标识Endpoint绑定到哪个ListenServer服务
+	EndpointAttrTagBizId         = "bizid"         // 标识Endpoint绑定到业务标识
+	EndpointAttrTagBodyType      = "bodytype"      // 指定解析请求Body的媒体类型，覆盖请求的Content-Type
+	EndpointAttrTagAccepts       = "accepts"       // 标识Endpoint接受的请求Content-Type，支持 type/* 通配
+	EndpointAttrTagTimeout       = "timeout"       // 标识Endpoint的请求超时时间，例如 3s；纯数字为毫秒
+	EndpointAttrTagBodyLimit     = "bodylimit"     // 标识Endpoint的请求Body最大长度，例如 512K，2M；纯数字为字节数
+	EndpointAttrTagShadowService = "shadowservice" // 标识Endpoint的影子服务ID，请求按比例复制到影子服务
+	EndpointAttrTagShadowRatio   = "shadowratio"   // 标识复制到影子服务的请求百分比，0-100
+	EndpointAttrTagClientCert    = "clientcert"    // 标识Endpoint是否要求客户端提供已验证的TLS证书（mTLS）
+	EndpointAttrTagClientCN      = "clientcn"      // 标识Endpoint允许的客户端证书CommonName列表，支持 *. 前缀通配
+	EndpointAttrTagHost          = "host"          // 标识Endpoint匹配的请求Host列表，支持 *. 前缀通配；未定义时匹配全部Host
 )
 
 // ArgumentAttributes
@@ -268,9 +287,24 @@ func (e *Endpoint) Authorize() bool {
 	return e.GetAttr(EndpointAttrTagAuthorize).GetBool()
 }
 
+// Hosts 返回Endpoint匹配的请求Host列表，已转换为小写并排序；未定义时返回空列表，匹配全部Host
+func (e *Endpoint) Hosts() []string {
+	hosts := make([]string, 0, 2)
+	for _, attr := range e.GetAttrs(EndpointAttrTagHost) {
+		for _, host := range attr.GetStringSlice() {
+			if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+				hosts = append(hosts, host)
+			}
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
+
 // Multi version control Endpoint
 type MVCEndpoint struct {
 	versions      map[string]*Endpoint // 各版本数据
+	ordered       []*Endpoint          // 按版本号排序的各版本数据
 	*sync.RWMutex                      // 读写锁
 }
 
@@ -279,6 +313,7 @@ func NewMultiEndpoint(endpoint *Endpoint) *MVCEndpoint {
 		versions: map[string]*Endpoint{
 			endpoint.Version: endpoint,
 		},
+		ordered: []*Endpoint{endpoint},
 		RWMutex: new(sync.RWMutex),
 	}
 }
@@ -317,12 +352,14 @@ func (m *MVCEndpoint) dup(src *Endpoint) Endpoint {
 func (m *MVCEndpoint) Update(version string, endpoint *Endpoint) {
 	m.Lock()
 	m.versions[version] = endpoint
+	m.ordered = sortVersions(m.versions)
 	m.Unlock()
 }
 
 func (m *MVCEndpoint) Delete(version string) {
 	m.Lock()
 	delete(m.versions, version)
+	m.ordered = sortVersions(m.versions)
 	m.Unlock()
 }
 
@@ -349,10 +386,16 @@ func (m *MVCEndpoint) Endpoints() []*Endpoint {
 type EndpointEvent struct {
 	EventType EventType
 	Endpoint  Endpoint
+	Source    string // 事件来源的注册中心ID
 }
 
+// EndpointValidateFunc 在应用注册中心的Endpoint事件之前，校验Endpoint定义；
+// 可以修改事件中的Endpoint定义；返回错误时拒绝此事件。
+type EndpointValidateFunc func(event *EndpointEvent) error
+
 // ServiceEvent  定义从注册中心接收到的Service定义数据变更
 type ServiceEvent struct {
 	EventType EventType
 	Service   TransporterService
+	Source    string // 事件来源的注册中心ID
 }
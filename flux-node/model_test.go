@@ -179,3 +179,19 @@ type AssertCase struct {
 	Actual   func(endpoint *Endpoint) interface{}
 	Message  string
 }
+
+func TestTransporterServiceInstances(t *testing.T) {
+	assert := assert2.New(t)
+	assert.Equal([]string{"127.0.0.1:8080"}, TransporterService{RemoteHost: "127.0.0.1:8080"}.Instances())
+	assert.Equal([]string{"10.0.0.1:80", "10.0.0.2:80"}, TransporterService{RemoteHost: "10.0.0.1:80, 10.0.0.2:80,"}.Instances())
+}
+
+func TestEndpointHosts(t *testing.T) {
+	assert := assert2.New(t)
+	endpoint := Endpoint{EmbeddedAttributes: EmbeddedAttributes{Attributes: []Attribute{
+		{Name: EndpointAttrTagHost, Value: []interface{}{"WWW.Example.com", " *.api.example.com "}},
+		{Name: EndpointAttrTagHost, Value: "shop.example.com"},
+	}}}
+	assert.Equal([]string{"*.api.example.com", "shop.example.com", "www.example.com"}, endpoint.Hosts())
+	assert.Equal([]string{}, (&Endpoint{}).Hosts())
+}
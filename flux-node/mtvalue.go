@@ -50,6 +50,9 @@ func WrapStrValuesMapMTValue(value map[string][]string) MTValue {
 	return MTValue{Valid: value != nil, Value: value, MediaType: ValueMediaTypeGoStringValuesMap}
 }
 
+// BodyParser 按媒体类型解析请求Body数据；contentType 包含媒体类型参数，例如 multipart 的 boundary
+type BodyParser func(contentType string, data []byte) (map[string]interface{}, error)
+
 // MTValueResolver 将未定类型的值，按指定类型以及泛型类型转换为实际类型
 // @param mtValue Http请求指示媒体类型的值
 // @param toClass 目标值类型
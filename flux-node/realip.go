@@ -0,0 +1,180 @@
+package flux
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const (
+	// 客户端IP的来源Header，按配置的顺序解析
+	RealIPHeaderForwarded     = "forwarded"
+	RealIPHeaderXForwardedFor = "x-forwarded-for"
+	RealIPHeaderXRealIP       = "x-real-ip"
+)
+
+var (
+	realIPResolver = NewRealIPResolver(nil, nil)
+	realIPMu       sync.RWMutex
+)
+
+// RealIPResolver 解析请求的真实客户端IP：只有直接连接的对端地址属于可信代理时，才读取代理Header；
+// 列表型Header（Forwarded，X-Forwarded-For）从右向左跳过可信代理地址，第一个不可信地址为客户端IP，
+// 避免客户端伪造Header。
+type RealIPResolver struct {
+	trusted []*net.IPNet
+	headers []string
+}
+
+// NewRealIPResolver 创建客户端IP解析器；trusted 为可信代理的IP或CIDR列表，headers 为按优先级排列的来源Header
+func NewRealIPResolver(trusted []*net.IPNet, headers []string) *RealIPResolver {
+	if len(headers) == 0 {
+		headers = []string{RealIPHeaderForwarded, RealIPHeaderXForwardedFor, RealIPHeaderXRealIP}
+	}
+	return &RealIPResolver{trusted: trusted, headers: headers}
+}
+
+// ParseTrustedProxies 解析可信代理的IP或CIDR列表；单个IP地址按 /32（IPv4）或 /128（IPv6）处理
+func ParseTrustedProxies(items []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(items))
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if nil == ip {
+				return nil, fmt.Errorf("invalid trusted proxy ip: %q", item)
+			}
+			if v4 := ip.To4(); nil != v4 {
+				item = v4.String() + "/32"
+			} else {
+				item = ip.String() + "/128"
+			}
+		}
+		_, ipnet, err := net.ParseCIDR(item)
+		if nil != err {
+			return nil, fmt.Errorf("invalid trusted proxy cidr: %q", item)
+		}
+		out = append(out, ipnet)
+	}
+	return out, nil
+}
+
+// SetRealIPResolver 设置全局的客户端IP解析器
+func SetRealIPResolver(resolver *RealIPResolver) {
+	realIPMu.Lock()
+	defer realIPMu.Unlock()
+	realIPResolver = resolver
+}
+
+// RealIP 使用全局的客户端IP解析器，返回请求的真实客户端IP
+func RealIP(webex ServerWebContext) string {
+	realIPMu.RLock()
+	resolver := realIPResolver
+	realIPMu.RUnlock()
+	return resolver.Resolve(webex.RemoteAddr(), webex.HeaderVars())
+}
+
+// Resolve 按对端地址及请求Header解析客户端IP
+func (r *RealIPResolver) Resolve(remoteAddr string, header http.Header) string {
+	remote := ClientIP(remoteAddr)
+	if !r.isTrusted(remote) {
+		return remote
+	}
+	for _, name := range r.headers {
+		var candidates []string
+		switch strings.ToLower(name) {
+		case RealIPHeaderForwarded:
+			candidates = forwardedFor(header.Values("Forwarded"))
+		case RealIPHeaderXForwardedFor:
+			candidates = splitAddrList(header.Values(HeaderXForwardedFor))
+		default:
+			candidates = splitAddrList(header.Values(name))
+		}
+		if ip, ok := r.rightmostUntrusted(candidates); ok {
+			return ip
+		}
+	}
+	return remote
+}
+
+func (r *RealIPResolver) rightmostUntrusted(candidates []string) (string, bool) {
+	var leftmost string
+	for i := len(candidates) - 1; i >= 0; i-- {
+		ip := ClientIP(candidates[i])
+		if nil == net.ParseIP(ip) {
+			// 无法解析的地址（例如 unknown，混淆标识）之后的地址不可信
+			break
+		}
+		if !r.isTrusted(ip) {
+			return ip, true
+		}
+		leftmost = ip
+	}
+	return leftmost, leftmost != ""
+}
+
+func (r *RealIPResolver) isTrusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if nil == parsed {
+		return false
+	}
+	for _, ipnet := range r.trusted {
+		if ipnet.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor 解析RFC7239 Forwarded Header中的 for 参数列表
+func forwardedFor(values []string) []string {
+	out := make([]string, 0, 2)
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+					out = append(out, strings.Trim(kv[1], "\""))
+				}
+			}
+		}
+	}
+	return out
+}
+
+func splitAddrList(values []string) []string {
+	out := make([]string, 0, 2)
+	for _, value := range values {
+		for _, addr := range strings.Split(value, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				out = append(out, addr)
+			}
+		}
+	}
+	return out
+}
+
+// ClientIP 从RemoteAddr（host:port）中解析客户端IP；支持IPv6地址，IPv4映射的IPv6地址转换为IPv4格式
+func ClientIP(remoteAddr string) string {
+	host := remoteAddr
+	if h, _, err := net.SplitHostPort(remoteAddr); nil == err {
+		host = h
+	} else {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if pos := strings.IndexByte(host, '%'); pos > 0 {
+		host = host[:pos]
+	}
+	if ip := net.ParseIP(host); nil != ip {
+		if v4 := ip.To4(); nil != v4 {
+			return v4.String()
+		}
+		return ip.String()
+	}
+	return host
+}
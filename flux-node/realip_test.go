@@ -0,0 +1,32 @@
+package flux
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestRealIPResolver(t *testing.T) {
+	assert := assert2.New(t)
+	trusted, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.168.1.1"})
+	assert.Nil(err)
+	resolver := NewRealIPResolver(trusted, nil)
+	header := http.Header{}
+	header.Set(HeaderXForwardedFor, "1.2.3.4, 5.6.7.8, 10.0.0.2")
+	// 不可信的对端地址，忽略代理Header
+	assert.Equal("8.8.8.8", resolver.Resolve("8.8.8.8:1234", header))
+	// 从右向左跳过可信代理
+	assert.Equal("5.6.7.8", resolver.Resolve("10.0.0.1:1234", header))
+	assert.Equal("5.6.7.8", resolver.Resolve("192.168.1.1:1234", header))
+	// Forwarded 优先于 X-Forwarded-For
+	header.Set("Forwarded", `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`)
+	assert.Equal("2001:db8::1", resolver.Resolve("10.0.0.1:1234", header))
+	// 全部为可信代理时，使用最左侧地址
+	header = http.Header{}
+	header.Set(HeaderXRealIP, "10.1.1.1")
+	assert.Equal("10.1.1.1", resolver.Resolve("10.0.0.1:1234", header))
+	// 未配置可信代理
+	assert.Equal("10.0.0.1", NewRealIPResolver(nil, nil).Resolve("10.0.0.1:1234", header))
+	_, err = ParseTrustedProxies([]string{"10.0.0.0/33"})
+	assert.Error(err)
+}
@@ -0,0 +1,213 @@
+package configcenter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// Apollo长轮询的服务端挂起时间为60秒，客户端超时需要大于此时间
+	apolloPollTimeout = time.Second * 90
+	apolloRetryDelay  = time.Second * 5
+)
+
+var _ Source = new(ApolloSource)
+
+// ApolloSource 通过Apollo HTTP接口加载配置，并以长轮询通知接口监听配置变更；
+// properties 格式的命名空间按键值合并（键以 . 分隔层级）；以 .yml，.yaml，.json 结尾的命名空间按文件内容合并。
+type ApolloSource struct {
+	address       string
+	appId         string
+	cluster       string
+	namespaces    []string
+	client        *http.Client
+	poller        *http.Client
+	notifications map[string]int64
+	cancel        context.CancelFunc
+	mu            sync.Mutex
+	// 串行化配置加载，避免旧的配置覆盖新的配置
+	loadmu sync.Mutex
+}
+
+func NewApolloSource(config *flux.Configuration) (*ApolloSource, error) {
+	config.SetDefaults(map[string]interface{}{
+		"cluster":    "default",
+		"namespaces": []string{"application"},
+		"timeout":    time.Second * 10,
+	})
+	s := &ApolloSource{
+		address:       strings.TrimSuffix(config.GetString("address"), "/"),
+		appId:         config.GetString("app_id"),
+		cluster:       config.GetString("cluster"),
+		namespaces:    config.GetStringSlice("namespaces"),
+		client:        &http.Client{Timeout: config.GetDuration("timeout")},
+		poller:        &http.Client{Timeout: apolloPollTimeout},
+		notifications: make(map[string]int64, 4),
+	}
+	if s.address == "" || s.appId == "" {
+		return nil, errors.New("config(address, app_id) of apollo config center is required")
+	}
+	for _, ns := range s.namespaces {
+		s.notifications[ns] = -1
+	}
+	logger.Infow("ConfigCenter:apollo init", "address", s.address, "app-id", s.appId,
+		"cluster", s.cluster, "namespaces", s.namespaces)
+	return s, nil
+}
+
+// Load 加载全部命名空间的配置，整体替换此前加载的远程配置；
+// 首次加载前先获取各命名空间的通知ID，加载后发生的变更由长轮询通知，不会遗漏。
+func (s *ApolloSource) Load() error {
+	s.loadmu.Lock()
+	defer s.loadmu.Unlock()
+	if !s.synced() {
+		// 通知ID未初始化时，Apollo立即返回当前的通知ID
+		ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout)
+		_, err := s.notified(ctx, s.client)
+		cancel()
+		if nil != err {
+			// 获取失败时，首次长轮询返回的通知触发重新加载
+			logger.Warnw("CONFIG_CENTER:APOLLO:NOTIFICATIONS/ERROR", "error", err)
+		}
+	}
+	configs := make([]map[string]interface{}, 0, len(s.namespaces))
+	for _, ns := range s.namespaces {
+		var result struct {
+			Configurations map[string]string `json:"configurations"`
+		}
+		api := fmt.Sprintf("%s/configs/%s/%s/%s", s.address, url.PathEscape(s.appId), url.PathEscape(s.cluster), url.PathEscape(ns))
+		if err := s.getJSON(context.Background(), s.client, api, &result); nil != err {
+			return fmt.Errorf("apollo get config, namespace: %s, err: %w", ns, err)
+		}
+		switch suffix := strings.ToLower(path.Ext(ns)); suffix {
+		case ".yml", ".yaml", ".json":
+			config, err := flux.ParseRemoteConfig([]byte(result.Configurations["content"]), strings.TrimPrefix(suffix, "."))
+			if nil != err {
+				return fmt.Errorf("apollo parse config, namespace: %s, err: %w", ns, err)
+			}
+			configs = append(configs, config)
+		default:
+			configs = append(configs, flux.ParseRemoteConfigValues(result.Configurations))
+		}
+	}
+	if err := flux.SetRemoteConfig(configs...); nil != err {
+		return fmt.Errorf("apollo merge config, err: %w", err)
+	}
+	return nil
+}
+
+func (s *ApolloSource) Watch(onChange func()) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go s.poll(ctx, onChange)
+	return nil
+}
+
+func (s *ApolloSource) Close() error {
+	if nil != s.cancel {
+		s.cancel()
+	}
+	return nil
+}
+
+// poll 长轮询配置变更通知；通知ID在首次加载前已初始化，任何通知均表示加载后的配置变更
+func (s *ApolloSource) poll(ctx context.Context, onChange func()) {
+	for {
+		changed, err := s.notified(ctx, s.poller)
+		if nil != ctx.Err() {
+			return
+		}
+		if nil != err {
+			logger.Warnw("CONFIG_CENTER:APOLLO:POLL/ERROR", "error", err)
+			select {
+			case <-time.After(apolloRetryDelay):
+			case <-ctx.Done():
+				return
+			}
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if err := s.Load(); nil != err {
+			logger.Errorw("CONFIG_CENTER:APOLLO:RELOAD/ERROR", "error", err)
+			continue
+		}
+		logger.Infow("CONFIG_CENTER:APOLLO:CHANGED", "app-id", s.appId)
+		onChange()
+	}
+}
+
+func (s *ApolloSource) synced() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range s.notifications {
+		if id == -1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *ApolloSource) notified(ctx context.Context, client *http.Client) (changed bool, err error) {
+	type notification struct {
+		NamespaceName  string `json:"namespaceName"`
+		NotificationId int64  `json:"notificationId"`
+	}
+	s.mu.Lock()
+	current := make([]notification, 0, len(s.notifications))
+	for ns, id := range s.notifications {
+		current = append(current, notification{NamespaceName: ns, NotificationId: id})
+	}
+	s.mu.Unlock()
+	data, err := ext.JSONMarshal(current)
+	if nil != err {
+		return false, err
+	}
+	query := url.Values{"appId": {s.appId}, "cluster": {s.cluster}, "notifications": {string(data)}}
+	var updates []notification
+	if err := s.getJSON(ctx, client, s.address+"/notifications/v2?"+query.Encode(), &updates); nil != err {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range updates {
+		s.notifications[u.NamespaceName] = u.NotificationId
+	}
+	return len(updates) > 0, nil
+}
+
+// getJSON 请求Apollo接口；304表示没有变更，不解析数据
+func (s *ApolloSource) getJSON(ctx context.Context, client *http.Client, api string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api, nil)
+	if nil != err {
+		return err
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("apollo returns status: %d, body: %s", resp.StatusCode, string(body))
+	}
+	return ext.JSONUnmarshal(body, out)
+}
@@ -0,0 +1,116 @@
+package configcenter
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/discovery"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/config_client"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"sync"
+	"time"
+)
+
+var _ Source = new(NacosSource)
+
+// NacosSource 从Nacos配置中心加载配置；每个DataId对应一份完整的配置数据，按顺序合并，后者覆盖前者
+type NacosSource struct {
+	client  config_client.IConfigClient
+	group   string
+	dataIds []string
+	format  string
+	// 串行化多个DataId变更时的重新加载，避免旧的配置覆盖新的配置
+	mu sync.Mutex
+}
+
+func NewNacosSource(config *flux.Configuration) (*NacosSource, error) {
+	config.SetDefaults(map[string]interface{}{
+		"group":        "flux-config",
+		"data_ids":     []string{"application.yml"},
+		"format":       "yaml",
+		"timeout":      time.Second * 10,
+		"context_path": "/nacos",
+	})
+	servers, err := discovery.ParseNacosServerConfigs(config.GetString("address"), config.GetString("context_path"))
+	if nil != err {
+		return nil, err
+	}
+	client, err := clients.CreateConfigClient(map[string]interface{}{
+		constant.KEY_SERVER_CONFIGS: servers,
+		constant.KEY_CLIENT_CONFIG: constant.ClientConfig{
+			TimeoutMs:           uint64(config.GetDuration("timeout").Milliseconds()),
+			NamespaceId:         config.GetString("namespace_id"),
+			Username:            config.GetString("username"),
+			Password:            config.GetString("password"),
+			NotLoadCacheAtStart: true,
+		},
+	})
+	if nil != err {
+		return nil, fmt.Errorf("nacos config client create failed, err: %w", err)
+	}
+	s := &NacosSource{
+		client:  client,
+		group:   config.GetString("group"),
+		dataIds: config.GetStringSlice("data_ids"),
+		format:  config.GetString("format"),
+	}
+	if len(s.dataIds) == 0 {
+		return nil, errors.New("config(data_ids) of nacos config center is required")
+	}
+	logger.Infow("ConfigCenter:nacos init", "address", servers, "group", s.group, "data-ids", s.dataIds)
+	return s, nil
+}
+
+// Load 加载全部DataId的配置，整体替换此前加载的远程配置；任一DataId加载失败时，保留此前的配置
+func (s *NacosSource) Load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	configs := make([]map[string]interface{}, 0, len(s.dataIds))
+	for _, dataId := range s.dataIds {
+		data, err := s.client.GetConfig(vo.ConfigParam{DataId: dataId, Group: s.group})
+		if nil != err {
+			return fmt.Errorf("nacos get config, data-id: %s, err: %w", dataId, err)
+		}
+		config, err := flux.ParseRemoteConfig([]byte(data), s.format)
+		if nil != err {
+			return fmt.Errorf("nacos parse config, data-id: %s, err: %w", dataId, err)
+		}
+		configs = append(configs, config)
+	}
+	if err := flux.SetRemoteConfig(configs...); nil != err {
+		return fmt.Errorf("nacos merge config, err: %w", err)
+	}
+	return nil
+}
+
+func (s *NacosSource) Watch(onChange func()) error {
+	for _, dataId := range s.dataIds {
+		err := s.client.ListenConfig(vo.ConfigParam{
+			DataId: dataId,
+			Group:  s.group,
+			OnChange: func(_, _, dataId, data string) {
+				// 单个DataId变更时，重新加载全部DataId，保证合并顺序
+				if err := s.Load(); nil != err {
+					logger.Errorw("CONFIG_CENTER:NACOS:RELOAD/ERROR", "data-id", dataId, "error", err)
+					return
+				}
+				logger.Infow("CONFIG_CENTER:NACOS:CHANGED", "data-id", dataId)
+				onChange()
+			},
+		})
+		if nil != err {
+			return fmt.Errorf("nacos listen config, data-id: %s, err: %w", dataId, err)
+		}
+	}
+	return nil
+}
+
+func (s *NacosSource) Close() error {
+	for _, dataId := range s.dataIds {
+		_ = s.client.CancelListenConfig(vo.ConfigParam{DataId: dataId, Group: s.group})
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package configcenter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+)
+
+const (
+	SourceTypeNacos  = "nacos"
+	SourceTypeApollo = "apollo"
+)
+
+type (
+	// Source 远程配置中心：加载配置数据合并到全局配置，并监听配置变更
+	Source interface {
+		// Load 加载全部配置，合并到全局配置
+		Load() error
+		// Watch 监听配置变更；配置变更并合并到全局配置后，调用 onChange 函数
+		Watch(onChange func()) error
+		// Close 停止监听
+		Close() error
+	}
+	// SourceFactory 根据配置创建远程配置中心
+	SourceFactory func(config *flux.Configuration) (Source, error)
+)
+
+var (
+	sourceFactories = map[string]SourceFactory{
+		SourceTypeNacos: func(config *flux.Configuration) (Source, error) {
+			return NewNacosSource(config)
+		},
+		SourceTypeApollo: func(config *flux.Configuration) (Source, error) {
+			return NewApolloSource(config)
+		},
+	}
+)
+
+// RegisterSourceFactory 注册自定义类型的远程配置中心
+func RegisterSourceFactory(sourceType string, factory SourceFactory) {
+	sourceFactories[sourceType] = factory
+}
+
+// NewSource 按配置类型创建远程配置中心
+func NewSource(sourceType string, config *flux.Configuration) (Source, error) {
+	factory, ok := sourceFactories[sourceType]
+	if !ok {
+		return nil, fmt.Errorf("unknown config center type: %s", sourceType)
+	}
+	return factory(config)
+}
@@ -0,0 +1,45 @@
+package secret
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+)
+
+const (
+	ProviderTypeVault = "vault"
+)
+
+type (
+	// Provider 密钥提供者：读取密钥数据，并在后台维持访问凭证的有效性
+	Provider interface {
+		flux.SecretProvider
+		// Start 登录并启动访问凭证的续期
+		Start() error
+		// Close 停止续期
+		Close() error
+	}
+	// ProviderFactory 根据配置创建密钥提供者
+	ProviderFactory func(config *flux.Configuration) (Provider, error)
+)
+
+var (
+	providerFactories = map[string]ProviderFactory{
+		ProviderTypeVault: func(config *flux.Configuration) (Provider, error) {
+			return NewVaultProvider(config)
+		},
+	}
+)
+
+// RegisterProviderFactory 注册自定义类型的密钥提供者
+func RegisterProviderFactory(providerType string, factory ProviderFactory) {
+	providerFactories[providerType] = factory
+}
+
+// NewProvider 按类型创建密钥提供者
+func NewProvider(providerType string, config *flux.Configuration) (Provider, error) {
+	factory, ok := providerFactories[providerType]
+	if !ok {
+		return nil, fmt.Errorf("unknown secret provider type: %s", providerType)
+	}
+	return factory(config)
+}
@@ -0,0 +1,331 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	VaultAuthToken   = "token"
+	VaultAuthAppRole = "approle"
+)
+
+const (
+	headerVaultToken     = "X-Vault-Token"
+	headerVaultNamespace = "X-Vault-Namespace"
+	vaultRetryDelay      = time.Second * 10
+	// 后台检查缓存密钥过期时间的间隔；在此间隔内过期的密钥提前刷新
+	vaultRefreshCheckInterval = time.Second * 10
+)
+
+var (
+	errVaultForbidden = errors.New("vault permission denied")
+)
+
+var _ Provider = new(VaultProvider)
+
+// VaultProvider 通过HashiCorp Vault HTTP接口读取密钥；支持Token及AppRole认证；
+// 密钥按租约时间（不超过 refresh_interval）缓存，由后台在过期前刷新，刷新失败时继续使用已缓存的密钥；
+// 读取密钥时只在首次读取（通常在组件初始化时）请求Vault，不在请求处理过程中同步请求；
+// 后台按Token有效期续期，续期失败时（AppRole认证）重新登录。
+type VaultProvider struct {
+	address   string
+	namespace string
+	auth      string
+	roleId    string
+	secretId  string
+	mount     string
+	refresh   time.Duration
+	client    *http.Client
+	token     string
+	tokenTTL  time.Duration
+	cache     map[string]vaultSecret
+	cancel    context.CancelFunc
+	mu        sync.RWMutex
+	tokenMu   sync.RWMutex
+}
+
+type vaultSecret struct {
+	data      map[string]string
+	expiresAt time.Time
+}
+
+type vaultResponse struct {
+	Data          map[string]interface{} `json:"data"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+func NewVaultProvider(config *flux.Configuration) (*VaultProvider, error) {
+	config.SetDefaults(map[string]interface{}{
+		"auth":             VaultAuthToken,
+		"approle.mount":    "approle",
+		"refresh_interval": time.Minute * 5,
+		"timeout":          time.Second * 10,
+	})
+	p := &VaultProvider{
+		address:   strings.TrimSuffix(config.GetString("address"), "/"),
+		namespace: config.GetString("namespace"),
+		auth:      config.GetString("auth"),
+		token:     config.GetString("token"),
+		roleId:    config.GetString("approle.role_id"),
+		secretId:  config.GetString("approle.secret_id"),
+		mount:     config.GetString("approle.mount"),
+		refresh:   config.GetDuration("refresh_interval"),
+		client:    &http.Client{Timeout: config.GetDuration("timeout")},
+		cache:     make(map[string]vaultSecret, 8),
+	}
+	if p.address == "" {
+		return nil, errors.New("config(address) of vault secret provider is required")
+	}
+	switch p.auth {
+	case VaultAuthToken:
+		if p.token == "" {
+			return nil, errors.New("config(token) of vault secret provider is required")
+		}
+	case VaultAuthAppRole:
+		if p.roleId == "" || p.secretId == "" {
+			return nil, errors.New("config(approle.role_id, approle.secret_id) of vault secret provider is required")
+		}
+	default:
+		return nil, fmt.Errorf("unknown vault auth method: %s", p.auth)
+	}
+	logger.Infow("SecretProvider:vault init", "address", p.address, "namespace", p.namespace,
+		"auth", p.auth, "refresh-interval", p.refresh)
+	return p, nil
+}
+
+func (p *VaultProvider) Start() error {
+	if err := p.login(); nil != err {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	go p.renewLoop(ctx)
+	go p.refreshLoop(ctx)
+	return nil
+}
+
+func (p *VaultProvider) Close() error {
+	if nil != p.cancel {
+		p.cancel()
+	}
+	return nil
+}
+
+// GetSecret 读取密钥；path 为Vault API路径（不含 /v1/ 前缀），KV v2 引擎的路径包含 data 段，例如：secret/data/flux/tls
+func (p *VaultProvider) GetSecret(path string) (map[string]string, error) {
+	path = strings.Trim(path, "/")
+	p.mu.RLock()
+	cached, ok := p.cache[path]
+	p.mu.RUnlock()
+	if ok {
+		return cached.data, nil
+	}
+	data, ttl, err := p.fetch(path)
+	if nil != err {
+		return nil, err
+	}
+	p.store(path, data, ttl)
+	return data, nil
+}
+
+// refreshLoop 刷新即将过期的缓存密钥
+func (p *VaultProvider) refreshLoop(ctx context.Context) {
+	ticker := time.NewTicker(vaultRefreshCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.refreshExpiring(time.Now().Add(vaultRefreshCheckInterval))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *VaultProvider) refreshExpiring(deadline time.Time) {
+	p.mu.RLock()
+	paths := make([]string, 0, len(p.cache))
+	for path, cached := range p.cache {
+		if cached.expiresAt.Before(deadline) {
+			paths = append(paths, path)
+		}
+	}
+	p.mu.RUnlock()
+	for _, path := range paths {
+		data, ttl, err := p.fetch(path)
+		if nil != err {
+			logger.Warnw("SECRET:VAULT:REFRESH/ERROR", "path", path, "error", err)
+			continue
+		}
+		p.store(path, data, ttl)
+	}
+}
+
+// fetch 从Vault读取密钥及缓存时间；Token失效时（AppRole认证）重新登录后重试
+func (p *VaultProvider) fetch(path string) (map[string]string, time.Duration, error) {
+	data, lease, err := p.read(path)
+	if errors.Is(err, errVaultForbidden) && p.auth == VaultAuthAppRole {
+		if lerr := p.login(); nil == lerr {
+			data, lease, err = p.read(path)
+		}
+	}
+	if nil != err {
+		return nil, 0, err
+	}
+	ttl := p.refresh
+	if lease > 0 && lease < ttl {
+		ttl = lease
+	}
+	return data, ttl, nil
+}
+
+func (p *VaultProvider) store(path string, data map[string]string, ttl time.Duration) {
+	p.mu.Lock()
+	p.cache[path] = vaultSecret{data: data, expiresAt: time.Now().Add(ttl)}
+	p.mu.Unlock()
+}
+
+func (p *VaultProvider) read(path string) (map[string]string, time.Duration, error) {
+	resp, err := p.request(http.MethodGet, "/v1/"+path, nil)
+	if nil != err {
+		return nil, 0, fmt.Errorf("vault read secret, path: %s, err: %w", path, err)
+	}
+	values := resp.Data
+	// KV v2：密钥数据位于 data.data
+	if inner, ok := values["data"].(map[string]interface{}); ok {
+		if _, ok := values["metadata"]; ok {
+			values = inner
+		}
+	}
+	out := make(map[string]string, len(values))
+	for k, v := range values {
+		out[k] = cast.ToString(v)
+	}
+	return out, time.Duration(resp.LeaseDuration) * time.Second, nil
+}
+
+// login AppRole认证时登录获取Token；Token认证时查询Token的有效期
+func (p *VaultProvider) login() error {
+	var resp *vaultResponse
+	var err error
+	if p.auth == VaultAuthAppRole {
+		body, _ := ext.JSONMarshal(map[string]string{"role_id": p.roleId, "secret_id": p.secretId})
+		resp, err = p.request(http.MethodPost, "/v1/auth/"+p.mount+"/login", body)
+	} else {
+		resp, err = p.request(http.MethodGet, "/v1/auth/token/lookup-self", nil)
+	}
+	if nil != err {
+		return fmt.Errorf("vault login, auth: %s, err: %w", p.auth, err)
+	}
+	p.tokenMu.Lock()
+	defer p.tokenMu.Unlock()
+	if nil != resp.Auth {
+		p.token = resp.Auth.ClientToken
+		p.tokenTTL = time.Duration(resp.Auth.LeaseDuration) * time.Second
+	} else {
+		p.tokenTTL = time.Duration(cast.ToInt64(resp.Data["ttl"])) * time.Second
+	}
+	return nil
+}
+
+// renewLoop 在Token有效期过半时续期；不过期的Token不续期
+func (p *VaultProvider) renewLoop(ctx context.Context) {
+	for {
+		p.tokenMu.RLock()
+		ttl := p.tokenTTL
+		p.tokenMu.RUnlock()
+		if ttl <= 0 {
+			return
+		}
+		select {
+		case <-time.After(ttl / 2):
+		case <-ctx.Done():
+			return
+		}
+		if err := p.renew(); nil != err {
+			logger.Warnw("SECRET:VAULT:RENEW/ERROR", "error", err)
+			if p.auth == VaultAuthAppRole {
+				err = p.login()
+			}
+			if nil != err {
+				logger.Errorw("SECRET:VAULT:LOGIN/ERROR", "error", err)
+				select {
+				case <-time.After(vaultRetryDelay):
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *VaultProvider) renew() error {
+	resp, err := p.request(http.MethodPost, "/v1/auth/token/renew-self", []byte("{}"))
+	if nil != err {
+		return err
+	}
+	if nil == resp.Auth {
+		return errors.New("vault renew returns no auth")
+	}
+	ttl := time.Duration(resp.Auth.LeaseDuration) * time.Second
+	p.tokenMu.Lock()
+	p.tokenTTL = ttl
+	p.tokenMu.Unlock()
+	logger.Infow("SECRET:VAULT:RENEWED", "ttl", ttl)
+	return nil
+}
+
+func (p *VaultProvider) request(method, api string, body []byte) (*vaultResponse, error) {
+	req, err := http.NewRequest(method, p.address+api, bytes.NewReader(body))
+	if nil != err {
+		return nil, err
+	}
+	p.tokenMu.RLock()
+	token := p.token
+	p.tokenMu.RUnlock()
+	// 登录接口不携带Token，避免已失效的Token导致登录被拒绝
+	if token != "" && !strings.HasSuffix(api, "/login") {
+		req.Header.Set(headerVaultToken, token)
+	}
+	if p.namespace != "" {
+		req.Header.Set(headerVaultNamespace, p.namespace)
+	}
+	resp, err := p.client.Do(req)
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusForbidden {
+		return nil, errVaultForbidden
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("vault returns status: %d, body: %s", resp.StatusCode, string(data))
+	}
+	out := new(vaultResponse)
+	if err := ext.JSONUnmarshal(data, out); nil != err {
+		return nil, fmt.Errorf("decode vault response, err: %w", err)
+	}
+	return out, nil
+}
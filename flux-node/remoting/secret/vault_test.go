@@ -0,0 +1,63 @@
+package secret
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestVaultProvider_GetSecret(t *testing.T) {
+	assert := assert.New(t)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	var reads, fail int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("root", r.Header.Get(headerVaultToken))
+		if r.URL.Path != "/v1/secret/data/flux/tls" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		n := atomic.AddInt32(&reads, 1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"lease_duration":0,"data":{"data":{"cert":"v` + strconv.Itoa(int(n)) + `"},"metadata":{}}}`))
+	}))
+	defer server.Close()
+	provider, err := NewVaultProvider(flux.NewConfigurationOfMap(map[string]interface{}{
+		"address":          server.URL,
+		"token":            "root",
+		"refresh_interval": "1m",
+	}))
+	assert.NoError(err)
+
+	// 首次读取请求Vault，之后读取缓存
+	data, err := provider.GetSecret("/secret/data/flux/tls")
+	assert.NoError(err)
+	assert.Equal("v1", data["cert"])
+	data, err = provider.GetSecret("secret/data/flux/tls")
+	assert.NoError(err)
+	assert.Equal("v1", data["cert"])
+	assert.Equal(int32(1), atomic.LoadInt32(&reads))
+	_, err = provider.GetSecret("secret/data/missing")
+	assert.Error(err)
+
+	// 后台刷新即将过期的密钥；刷新失败时保留已缓存的密钥
+	atomic.StoreInt32(&fail, 1)
+	provider.refreshExpiring(time.Now().Add(time.Hour))
+	data, _ = provider.GetSecret("secret/data/flux/tls")
+	assert.Equal("v1", data["cert"])
+	atomic.StoreInt32(&fail, 0)
+	provider.refreshExpiring(time.Now())
+	assert.Equal(int32(1), atomic.LoadInt32(&reads))
+	provider.refreshExpiring(time.Now().Add(time.Hour))
+	data, _ = provider.GetSecret("secret/data/flux/tls")
+	assert.Equal("v2", data["cert"])
+}
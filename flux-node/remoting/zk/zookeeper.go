@@ -83,12 +83,23 @@ func (r *ZookeeperRetriever) Shutdown(ctx context.Context) error {
 	return nil
 }
 
+// Connected 判定ZK客户端是否已建立会话
+func (r *ZookeeperRetriever) Connected() bool {
+	return r.conn != nil && r.conn.State() == zk.StateHasSession
+}
+
 // Exists 判定指定Path是否存在。注意Path是完整路径。
 func (r *ZookeeperRetriever) Exists(path string) (bool, error) {
 	b, _, err := r.conn.Exists(path)
 	return b, err
 }
 
+// Children 返回指定Path的子节点名称列表。注意Path是完整路径。
+func (r *ZookeeperRetriever) Children(path string) ([]string, error) {
+	children, _, err := r.conn.Children(path)
+	return children, err
+}
+
 // Create 创建指定Path的节点
 func (r *ZookeeperRetriever) Create(path string) error {
 	_, err := r.conn.Create(path, []byte{}, 0, zk.WorldACL(zk.PermAll))
@@ -0,0 +1,57 @@
+package flux
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// 密钥占位符未指定字段时读取的默认字段
+	SecretFieldDefault = "value"
+)
+
+// SecretProvider 密钥提供者：按路径读取密钥数据；实现方负责缓存，续期及轮换
+type SecretProvider interface {
+	GetSecret(path string) (map[string]string, error)
+}
+
+var (
+	secretProvider   SecretProvider
+	secretProviderMu sync.RWMutex
+)
+
+// SetSecretProvider 设置配置占位符 ${$secret:path#field} 使用的密钥提供者
+func SetSecretProvider(provider SecretProvider) {
+	secretProviderMu.Lock()
+	defer secretProviderMu.Unlock()
+	secretProvider = provider
+}
+
+// GetSecretProvider 返回已设置的密钥提供者；未设置时返回nil
+func GetSecretProvider() SecretProvider {
+	secretProviderMu.RLock()
+	defer secretProviderMu.RUnlock()
+	return secretProvider
+}
+
+// LookupSecret 读取密钥字段；ref 格式为 path#field，未指定字段时读取 value 字段
+func LookupSecret(ref string) (string, error) {
+	provider := GetSecretProvider()
+	if nil == provider {
+		return "", fmt.Errorf("secret provider not set, ref: %s", ref)
+	}
+	path, field := ref, SecretFieldDefault
+	if idx := strings.LastIndexByte(ref, '#'); idx > 0 {
+		path, field = ref[:idx], ref[idx+1:]
+	}
+	data, err := provider.GetSecret(path)
+	if nil != err {
+		return "", err
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("secret field not found, path: %s, field: %s", path, field)
+	}
+	return value, nil
+}
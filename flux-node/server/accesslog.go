@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bufio"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/tracing"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// 访问日志配置：disabled，format，sample_ratio，always_errors，error_status，slow_threshold，queue_size，sinks
+	ConfigNsAccessLog = "access_log"
+)
+
+// maxMeasureBodySize 计算未声明Content-Length的请求Body大小时，最多读取的字节数
+const maxMeasureBodySize = 4 * 1024 * 1024
+
+// responseRecorder 记录响应状态码和响应数据大小
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseRecorder) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// accessLogMode 返回Endpoint的访问日志模式
+func accessLogMode(endpoint *flux.Endpoint) string {
+	switch mode := strings.ToLower(endpoint.GetAttr(flux.EndpointAttrTagAccessLog).GetString()); mode {
+	case "false", flux.AccessLogModeOff:
+		return flux.AccessLogModeOff
+	default:
+		return mode
+	}
+}
+
+// requestBodySize 返回请求Body的数据大小；优先使用Content-Length，
+// 未声明时（如chunked请求）最多读取 maxMeasureBodySize 字节计算
+func requestBodySize(ctx *flux.Context) int64 {
+	if size := ctx.Request().ContentLength; size >= 0 {
+		return size
+	}
+	reader, err := ctx.BodyReader()
+	if nil != err || nil == reader {
+		return 0
+	}
+	defer reader.Close()
+	size, _ := io.Copy(ioutil.Discard, io.LimitReader(reader, maxMeasureBodySize))
+	return size
+}
+
+func newAccessLog(ctx *flux.Context, listenerId string, rw *responseRecorder, serr *flux.ServeError) *flux.AccessLog {
+	request := ctx.Request()
+	endpoint := ctx.Endpoint()
+	log := &flux.AccessLog{
+		Time:       ctx.StartAt(),
+		RequestId:  ctx.RequestId(),
+		ListenerId: listenerId,
+		RemoteAddr: flux.RealIP(ctx),
+		Host:       ctx.Host(),
+		Method:     ctx.Method(),
+		URI:        ctx.URI(),
+		Proto:      request.Proto,
+		Status:     rw.status,
+		Bytes:      rw.bytes,
+		ReqBytes:   requestBodySize(ctx),
+		Elapsed:    time.Since(ctx.StartAt()),
+		Referer:    request.Referer(),
+		UserAgent:  request.UserAgent(),
+		Pattern:    endpoint.HttpPattern,
+		Version:    endpoint.Version,
+		ServiceId:  endpoint.Service.ServiceID(),
+		Metrics:    ctx.Metrics(),
+		Always:     accessLogMode(endpoint) == flux.AccessLogModeAlways,
+	}
+	if span := tracing.CurrentSpan(ctx); span != nil {
+		log.TraceId = span.Context.TraceID.String()
+	}
+	if nil != serr {
+		log.ErrorCode = serr.GetErrorCode()
+		if log.Status == 0 {
+			log.Status = serr.StatusCode
+		}
+	}
+	return log
+}
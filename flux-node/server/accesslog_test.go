@@ -0,0 +1,34 @@
+package server
+
+import (
+	"bytes"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newBodySizeContext(body []byte, contentLength int64) *flux.Context {
+	request := httptest.NewRequest(http.MethodPost, "http://gateway/upload", bytes.NewReader(body))
+	request.ContentLength = contentLength
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("size", request, nil, nil), &flux.Endpoint{HttpMethod: http.MethodPost, HttpPattern: "/upload"})
+	return ctx
+}
+
+func TestRequestBodySize(t *testing.T) {
+	assert := assert.New(t)
+	// 声明Content-Length时不读取Body
+	assert.Equal(int64(128), requestBodySize(newBodySizeContext(nil, 128)))
+	// 未声明Content-Length时读取Body计算，最多读取 maxMeasureBodySize 字节
+	assert.Equal(int64(5), requestBodySize(newBodySizeContext([]byte("hello"), -1)))
+	large := make([]byte, maxMeasureBodySize+10)
+	assert.Equal(int64(maxMeasureBodySize), requestBodySize(newBodySizeContext(large, -1)))
+}
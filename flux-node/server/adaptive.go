@@ -0,0 +1,232 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 自适应上游超时配置：enable，percentile，multiplier，min，max，window，min_samples，refresh_interval
+	ConfigNsAdaptiveTimeout = "adaptive_timeout"
+)
+
+const (
+	ConfigKeyAdaptivePercentile = "percentile"
+	ConfigKeyAdaptiveMultiplier = "multiplier"
+	ConfigKeyAdaptiveMin        = "min"
+	ConfigKeyAdaptiveMax        = "max"
+	ConfigKeyAdaptiveWindow     = "window"
+	ConfigKeyAdaptiveMinSamples = "min_samples"
+	ConfigKeyAdaptiveRefresh    = "refresh_interval"
+)
+
+const (
+	// Service属性：是否使用自适应超时；开启自适应超时后，设置为false的Service使用固定的 rpctimeout
+	ServiceAttrTagAdaptiveTimeout = "adaptivetimeout"
+)
+
+// AdaptiveTimeouts 按Service最近调用耗时的分位值（默认p99）乘以倍数，计算上游调用的超时时间，并限制在 min-max 范围内；
+// 样本数不足 min_samples 时不调整，使用Service定义的固定超时；
+// 自适应超时替换Service定义的固定超时（rpctimeout），可以缩短或延长上游调用的超时，但不超出Endpoint定义的请求截止时间；
+// 只有成功响应与上游超时的调用耗时作为样本，错误与快速失败的请求不影响超时计算。
+type AdaptiveTimeouts struct {
+	enabled    bool
+	percentile float64
+	multiplier float64
+	min        time.Duration
+	max        time.Duration
+	window     int
+	minSamples int
+	refresh    time.Duration
+	services   map[string]*latencyWindow
+	mu         sync.RWMutex
+}
+
+// AdaptiveTimeoutStatus Service的自适应超时状态
+type AdaptiveTimeoutStatus struct {
+	ServiceId  string `json:"serviceId"`
+	Samples    int    `json:"samples"`
+	Percentile string `json:"percentile"`
+	Timeout    string `json:"timeout"`
+}
+
+// latencyWindow 固定容量的调用耗时环形窗口，超时时间按刷新间隔重新计算
+type latencyWindow struct {
+	samples    []time.Duration
+	next       int
+	count      int
+	value      time.Duration
+	timeout    time.Duration
+	computedAt time.Time
+	mu         sync.Mutex
+}
+
+func NewAdaptiveTimeouts() *AdaptiveTimeouts {
+	return &AdaptiveTimeouts{
+		services: make(map[string]*latencyWindow, 16),
+	}
+}
+
+func (a *AdaptiveTimeouts) Init(config *flux.Configuration) error {
+	a.enabled = config.GetBool("enable")
+	if !a.enabled {
+		return nil
+	}
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyAdaptivePercentile: 0.99,
+		ConfigKeyAdaptiveMultiplier: 3.0,
+		ConfigKeyAdaptiveMin:        time.Millisecond * 100,
+		ConfigKeyAdaptiveMax:        time.Second * 30,
+		ConfigKeyAdaptiveWindow:     1000,
+		ConfigKeyAdaptiveMinSamples: 100,
+		ConfigKeyAdaptiveRefresh:    time.Second * 5,
+	})
+	a.percentile = config.GetFloat64(ConfigKeyAdaptivePercentile)
+	a.multiplier = config.GetFloat64(ConfigKeyAdaptiveMultiplier)
+	a.min = config.GetDuration(ConfigKeyAdaptiveMin)
+	a.max = config.GetDuration(ConfigKeyAdaptiveMax)
+	a.window = config.GetInt(ConfigKeyAdaptiveWindow)
+	a.minSamples = config.GetInt(ConfigKeyAdaptiveMinSamples)
+	a.refresh = config.GetDuration(ConfigKeyAdaptiveRefresh)
+	if a.percentile <= 0 || a.percentile > 1 {
+		return fmt.Errorf("config(adaptive_timeout.percentile) must be in range (0, 1], was: %v", a.percentile)
+	}
+	if a.multiplier <= 0 || a.min <= 0 || a.max < a.min {
+		return fmt.Errorf("config(adaptive_timeout.multiplier, min, max) is invalid, multiplier: %v, min: %s, max: %s", a.multiplier, a.min, a.max)
+	}
+	if a.window < a.minSamples {
+		a.window = a.minSamples
+	}
+	logger.Infow("SERVER:ADAPTIVE_TIMEOUT:ENABLED", "percentile", a.percentile, "multiplier", a.multiplier,
+		"min", a.min, "max", a.max, "window", a.window)
+	return nil
+}
+
+// Timeout 返回Service当前的自适应超时时间；未开启，Service未启用或样本不足时返回false
+func (a *AdaptiveTimeouts) Timeout(service *flux.TransporterService) (time.Duration, bool) {
+	if !a.enabled {
+		return 0, false
+	}
+	if attr, ok := service.GetAttrEx(ServiceAttrTagAdaptiveTimeout); ok && !attr.GetBool() {
+		return 0, false
+	}
+	a.mu.RLock()
+	window, ok := a.services[service.ServiceID()]
+	a.mu.RUnlock()
+	if !ok {
+		return 0, false
+	}
+	return window.timeoutOf(a)
+}
+
+// Apply 在当前请求范围内，以自适应超时替换Service定义的固定超时，并设置上游调用的截止时间；
+// 返回的恢复函数恢复原来的Service定义和截止时间。
+func (a *AdaptiveTimeouts) Apply(ctx *flux.Context, timeout time.Duration) (restore func()) {
+	service := &ctx.Endpoint().Service
+	origin := service.Attributes
+	// 复制属性列表，不修改注册的Endpoint定义
+	attrs := make([]flux.Attribute, 0, len(origin)+1)
+	for _, attr := range origin {
+		if !strings.EqualFold(attr.Name, flux.ServiceAttrTagRpcTimeout) {
+			attrs = append(attrs, attr)
+		}
+	}
+	service.Attributes = append(attrs, flux.Attribute{Name: flux.ServiceAttrTagRpcTimeout, Value: timeout.String()})
+	cancel := ctx.WithScopedTimeout(timeout)
+	return func() {
+		cancel()
+		service.Attributes = origin
+	}
+}
+
+// Observe 记录Service的一次调用耗时
+func (a *AdaptiveTimeouts) Observe(service *flux.TransporterService, elapsed time.Duration) {
+	if !a.enabled {
+		return
+	}
+	id := service.ServiceID()
+	a.mu.RLock()
+	window, ok := a.services[id]
+	a.mu.RUnlock()
+	if !ok {
+		a.mu.Lock()
+		if window, ok = a.services[id]; !ok {
+			window = &latencyWindow{samples: make([]time.Duration, a.window)}
+			a.services[id] = window
+		}
+		a.mu.Unlock()
+	}
+	window.add(elapsed)
+}
+
+// Statuses 返回各Service的自适应超时状态
+func (a *AdaptiveTimeouts) Statuses() []AdaptiveTimeoutStatus {
+	a.mu.RLock()
+	out := make([]AdaptiveTimeoutStatus, 0, len(a.services))
+	for id, window := range a.services {
+		timeout, _ := window.timeoutOf(a)
+		window.mu.Lock()
+		out = append(out, AdaptiveTimeoutStatus{
+			ServiceId:  id,
+			Samples:    window.count,
+			Percentile: window.value.String(),
+			Timeout:    timeout.String(),
+		})
+		window.mu.Unlock()
+	}
+	a.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ServiceId < out[j].ServiceId
+	})
+	return out
+}
+
+// StatusHandler 查询自适应超时状态的管理接口
+func (a *AdaptiveTimeouts) StatusHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, a.Statuses())
+}
+
+func (w *latencyWindow) add(elapsed time.Duration) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.samples[w.next] = elapsed
+	w.next = (w.next + 1) % len(w.samples)
+	if w.count < len(w.samples) {
+		w.count++
+	}
+}
+
+func (w *latencyWindow) timeoutOf(a *AdaptiveTimeouts) (time.Duration, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.count < a.minSamples {
+		return 0, false
+	}
+	if now := time.Now(); w.timeout == 0 || now.Sub(w.computedAt) >= a.refresh {
+		w.computedAt = now
+		sorted := make([]time.Duration, w.count)
+		copy(sorted, w.samples[:w.count])
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+		idx := int(math.Ceil(a.percentile*float64(w.count))) - 1
+		if idx < 0 {
+			idx = 0
+		}
+		w.value = sorted[idx]
+		timeout := time.Duration(float64(w.value) * a.multiplier)
+		if timeout < a.min {
+			timeout = a.min
+		} else if timeout > a.max {
+			timeout = a.max
+		}
+		w.timeout = timeout
+	}
+	return w.timeout, true
+}
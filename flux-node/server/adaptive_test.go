@@ -0,0 +1,64 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newAdaptiveTimeouts(t *testing.T) *AdaptiveTimeouts {
+	timeouts := NewAdaptiveTimeouts()
+	assert.NoError(t, timeouts.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		"enable":                    true,
+		ConfigKeyAdaptiveMultiplier: 2.0,
+		ConfigKeyAdaptiveMin:        "10ms",
+		ConfigKeyAdaptiveMax:        "10s",
+		ConfigKeyAdaptiveWindow:     10,
+		ConfigKeyAdaptiveMinSamples: 10,
+	})))
+	return timeouts
+}
+
+func TestAdaptiveTimeouts_Timeout(t *testing.T) {
+	assert := assert.New(t)
+	timeouts := newAdaptiveTimeouts(t)
+	service := flux.TransporterService{ServiceId: "adaptive.service"}
+	for i := 0; i < 9; i++ {
+		timeouts.Observe(&service, time.Second)
+	}
+	_, ok := timeouts.Timeout(&service)
+	assert.False(ok, "samples not enough")
+	timeouts.Observe(&service, time.Second)
+	timeout, ok := timeouts.Timeout(&service)
+	assert.True(ok)
+	assert.Equal(time.Second*2, timeout)
+}
+
+func TestAdaptiveTimeouts_Apply(t *testing.T) {
+	assert := assert.New(t)
+	timeouts := newAdaptiveTimeouts(t)
+	registered := &flux.Endpoint{HttpPattern: "/users"}
+	registered.Service.Attributes = []flux.Attribute{
+		{Name: flux.ServiceAttrTagRpcTimeout, Value: "1s"},
+		{Name: flux.ServiceAttrTagRpcProto, Value: flux.ProtoHttp},
+	}
+	endpoint := *registered
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("adaptive", httptest.NewRequest(http.MethodGet, "http://gateway/users", nil), nil, nil), &endpoint)
+	// 自适应超时可以延长Service定义的固定超时
+	restore := timeouts.Apply(ctx, time.Second*3)
+	assert.Equal("3s", ctx.Transporter().RpcTimeout())
+	assert.Equal(flux.ProtoHttp, ctx.Transporter().RpcProto())
+	remaining, ok := ctx.RemainingTimeout()
+	assert.True(ok)
+	assert.True(remaining > time.Second*2, "deadline must follow the adaptive timeout")
+	assert.Equal("1s", registered.Service.RpcTimeout())
+	restore()
+	assert.Equal("1s", ctx.Transporter().RpcTimeout())
+	_, ok = ctx.RemainingTimeout()
+	assert.False(ok)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/accesslog"
+	"math/rand"
+)
+
+const (
+	// 请求数据分析镜像配置：enable，format，queue_size，sinks；与访问日志使用相同的Sink配置
+	ConfigNsAnalytics = "analytics"
+)
+
+const (
+	ConfigKeyAnalyticsEnable = "enable"
+)
+
+const (
+	// Endpoint属性：将请求元数据（不含请求数据，不请求后端服务）镜像到数据分析Sink
+	EndpointAttrTagAnalytics = "analytics"
+	// Endpoint属性：镜像到数据分析Sink的请求百分比，0-100；未定义时镜像全部请求
+	EndpointAttrTagAnalyticsRatio = "analyticsratio"
+)
+
+// AnalyticsSummary 请求数据分析汇总：累计请求数及错误数由持久化的Endpoint统计延续，服务重启后不归零
+type AnalyticsSummary struct {
+	Total     int64          `json:"total"`
+	Errors    int64          `json:"errors"`
+	Dropped   uint64         `json:"dropped"`
+	Endpoints []EndpointStat `json:"endpoints"`
+}
+
+// WithAnalyticsSink 添加请求数据分析镜像的输出目标，例如自定义的数据分析平台接口
+func WithAnalyticsSink(sink accesslog.Sink) Option {
+	return func(bs *BootstrapServer) {
+		bs.analytics.AddSink(sink)
+	}
+}
+
+// initAnalytics 加载请求数据分析镜像配置；未开启时不创建输出队列
+func (s *BootstrapServer) initAnalytics() error {
+	config := flux.NewConfigurationOfNS(ConfigNsAnalytics)
+	if !config.GetBool(ConfigKeyAnalyticsEnable) {
+		s.analytics = nil
+		return nil
+	}
+	// 采样比例由Endpoint属性定义
+	config.SetDefault(accesslog.ConfigKeySampleRatio, 1.0)
+	return s.dispatcher.AddInitHook(s.analytics, config)
+}
+
+// analyticsSampled 判断请求是否按Endpoint定义的比例镜像到数据分析Sink
+func (s *BootstrapServer) analyticsSampled(endpoint *flux.Endpoint) bool {
+	if nil == s.analytics || !endpoint.GetAttr(EndpointAttrTagAnalytics).GetBool() {
+		return false
+	}
+	if ratio, ok := endpoint.GetAttrEx(EndpointAttrTagAnalyticsRatio); ok && rand.Intn(100) >= ratio.GetInt() {
+		return false
+	}
+	return true
+}
+
+// Analytics 返回请求数据分析汇总；未开启 endpoint_stats 时累计统计为空
+func (s *BootstrapServer) Analytics() AnalyticsSummary {
+	summary := AnalyticsSummary{Endpoints: []EndpointStat{}}
+	if nil != s.stats {
+		summary.Endpoints = s.stats.Snapshot()
+	}
+	for _, stat := range summary.Endpoints {
+		summary.Total += stat.Total
+		summary.Errors += stat.Errors
+	}
+	if nil != s.analytics {
+		summary.Dropped = s.analytics.Dropped()
+	}
+	return summary
+}
+
+// AnalyticsHandler 查询请求数据分析汇总的管理接口
+func (s *BootstrapServer) AnalyticsHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.Analytics())
+}
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/accesslog"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"net/http"
+)
+
+const (
+	// 管理审计日志配置：enable，actor_header，reads，denied，sinks
+	ConfigNsAudit = "audit"
+)
+
+const (
+	ConfigKeyAuditActorHeader = "actor_header"
+	ConfigKeyAuditReads       = "reads"
+	ConfigKeyAuditDenied      = "denied"
+)
+
+const (
+	AuditActionAdminApi      = "ADMIN:API"
+	AuditActionEndpointOff   = "ENDPOINT:DISABLE"
+	AuditActionEndpointOn    = "ENDPOINT:ENABLE"
+	AuditActionFilterOff     = "FILTER:DISABLE"
+	AuditActionFilterOn      = "FILTER:ENABLE"
+	AuditActionConfigReload  = "CONFIG:RELOAD"
+	AuditActionLoggingChange = "LOGGING:CHANGE"
+)
+
+const (
+	// 配置重载来源：管理接口
+	reloadSourceAdmin = "admin"
+	// 非管理接口触发的审计事件的操作者
+	auditActorSystem = "system"
+)
+
+const (
+	// 管理接口处理函数记录的状态变更，由管理审计拦截器合并写入审计事件
+	auditVariableChange = "flux.server.audit.change"
+)
+
+// WithAuditSink 添加管理审计日志的输出目标，例如远程审计存储
+func WithAuditSink(sink accesslog.Sink) Option {
+	return func(bs *BootstrapServer) {
+		bs.audit.AddSink(sink)
+	}
+}
+
+// auditOptions 管理审计日志选项：操作者请求头，是否记录查询请求及权限拒绝事件
+type auditOptions struct {
+	actorHeader string
+	reads       bool
+	denied      bool
+}
+
+type auditChange struct {
+	action string
+	target string
+	before interface{}
+	after  interface{}
+}
+
+// initAudit 加载管理审计日志配置；未开启时不创建管理审计日志组件
+func (s *BootstrapServer) initAudit() error {
+	config := flux.NewConfigurationOfNS(ConfigNsAudit)
+	if !config.GetBool("enable") {
+		s.audit = nil
+		return nil
+	}
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyAuditActorHeader: "",
+		ConfigKeyAuditReads:       false,
+		ConfigKeyAuditDenied:      true,
+	})
+	s.auditOpts = auditOptions{
+		actorHeader: config.GetString(ConfigKeyAuditActorHeader),
+		reads:       config.GetBool(ConfigKeyAuditReads),
+		denied:      config.GetBool(ConfigKeyAuditDenied),
+	}
+	return s.dispatcher.AddInitHook(s.audit, config)
+}
+
+// AuditInterceptor 记录管理接口调用的拦截器；默认不记录GET/HEAD查询请求
+func (s *BootstrapServer) AuditInterceptor(next flux.WebHandler) flux.WebHandler {
+	return func(webex flux.ServerWebContext) error {
+		if nil == s.audit || (!s.auditOpts.reads && (webex.Method() == http.MethodGet || webex.Method() == http.MethodHead)) {
+			return next(webex)
+		}
+		rw := &responseRecorder{ResponseWriter: webex.ResponseWriter()}
+		webex.SetResponseWriter(rw)
+		err := next(webex)
+		event := &accesslog.AuditEvent{
+			Category:   accesslog.AuditCategoryAdmin,
+			Action:     AuditActionAdminApi,
+			Actor:      s.auditActor(webex),
+			RequestId:  webex.RequestId(),
+			RemoteAddr: flux.RealIP(webex),
+			Target:     webex.Method() + " " + webex.URL().Path,
+			Status:     rw.status,
+		}
+		if v, ok := webex.GetVariable(auditVariableChange); ok {
+			change := v.(*auditChange)
+			event.Action, event.Target = change.action, change.target
+			event.Before, event.After = change.before, change.after
+		}
+		if nil != err {
+			event.ErrorCode = err.Error()
+		}
+		s.recordAudit(event)
+		return err
+	}
+}
+
+// auditChanged 记录管理接口的状态变更，由管理审计拦截器写入审计事件
+func (s *BootstrapServer) auditChanged(webex flux.ServerWebContext, action, target string, before, after interface{}) {
+	if nil == s.audit {
+		return
+	}
+	webex.SetVariable(auditVariableChange, &auditChange{action: action, target: target, before: before, after: after})
+}
+
+// auditReload 记录配置文件或配置中心变更触发的配置重载
+func (s *BootstrapServer) auditReload(source string, results []ReloadResult) {
+	if nil == s.audit {
+		return
+	}
+	s.recordAudit(&accesslog.AuditEvent{
+		Category: accesslog.AuditCategorySystem,
+		Action:   AuditActionConfigReload,
+		Actor:    auditActorSystem,
+		Target:   source,
+		After:    results,
+	})
+}
+
+// auditDenial 记录请求被权限或认证拒绝的事件
+func (s *BootstrapServer) auditDenial(ctx *flux.Context, serr *flux.ServeError) {
+	if nil == s.audit || !s.auditOpts.denied || nil == serr {
+		return
+	}
+	if serr.StatusCode != flux.StatusUnauthorized && serr.StatusCode != flux.StatusAccessDenied {
+		return
+	}
+	endpoint := ctx.Endpoint()
+	// 请求路径上异步写入；被拒绝请求的认证信息未通过校验，操作者记录为客户端地址
+	s.audit.Post(&accesslog.AuditEvent{
+		Category:   accesslog.AuditCategoryDenied,
+		Action:     serr.GetErrorCode(),
+		Actor:      flux.RealIP(ctx),
+		RequestId:  ctx.RequestId(),
+		RemoteAddr: flux.RealIP(ctx),
+		Target:     ctx.Method() + " " + endpoint.HttpPattern,
+		Status:     serr.StatusCode,
+		ErrorCode:  serr.GetErrorCode(),
+	})
+}
+
+// auditActor 返回操作者：Basic认证用户名，actor_header 请求头，客户端地址；
+// 客户端可伪造 actor_header 请求头，仅在前置代理覆盖该请求头时配置
+func (s *BootstrapServer) auditActor(webex flux.ServerWebContext) string {
+	if user, _, ok := webex.Request().BasicAuth(); ok && user != "" {
+		return user
+	}
+	if s.auditOpts.actorHeader != "" {
+		if actor := webex.HeaderVar(s.auditOpts.actorHeader); actor != "" {
+			return actor
+		}
+	}
+	return flux.RealIP(webex)
+}
+
+func (s *BootstrapServer) recordAudit(event *accesslog.AuditEvent) {
+	if err := s.audit.Record(event); nil != err {
+		logger.Errorw("SERVER:AUDIT:RECORD/ERROR", "action", event.Action, "request-id", event.RequestId, "error", err)
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/accesslog"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newAuditRequest(user string, actor string) *http.Request {
+	request := httptest.NewRequest(http.MethodPost, "http://gateway/orders", nil)
+	request.RemoteAddr = "10.0.0.1:5000"
+	if user != "" {
+		request.SetBasicAuth(user, "secret")
+	}
+	if actor != "" {
+		request.Header.Set("X-Flux-Actor", actor)
+	}
+	return request
+}
+
+func TestAuditActor(t *testing.T) {
+	assert := assert.New(t)
+	server := &BootstrapServer{}
+	// 默认不使用客户端可伪造的操作者请求头
+	assert.Equal("10.0.0.1", server.auditActor(common.MockRequestContext("a1", newAuditRequest("", "root"), nil, nil)))
+	server.auditOpts.actorHeader = "X-Flux-Actor"
+	assert.Equal("root", server.auditActor(common.MockRequestContext("a2", newAuditRequest("", "root"), nil, nil)))
+	// Basic认证用户名优先于操作者请求头
+	assert.Equal("ops", server.auditActor(common.MockRequestContext("a3", newAuditRequest("ops", "root"), nil, nil)))
+}
+
+func TestAuditDenial(t *testing.T) {
+	assert := assert.New(t)
+	out := new(bytes.Buffer)
+	audit := accesslog.NewAuditTrail()
+	audit.AddSink(accesslog.NewWriterSink(out))
+	assert.NoError(audit.Init(flux.NewConfigurationOfMap(map[string]interface{}{})))
+	server := &BootstrapServer{audit: audit, auditOpts: auditOptions{actorHeader: "X-Flux-Actor", denied: true}}
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("d1", newAuditRequest("ops", "root"), nil, nil),
+		&flux.Endpoint{HttpMethod: http.MethodPost, HttpPattern: "/orders"})
+	server.auditDenial(ctx, &flux.ServeError{StatusCode: flux.StatusOK, ErrorCode: "NOT_FOUND"})
+	server.auditDenial(ctx, &flux.ServeError{StatusCode: flux.StatusAccessDenied, ErrorCode: "PERMISSION:DENIED"})
+	assert.NoError(audit.Shutdown(context.TODO()))
+	event := make(map[string]interface{})
+	assert.NoError(json.Unmarshal(out.Bytes(), &event))
+	// 被拒绝请求的认证信息未通过校验，操作者为客户端地址
+	assert.Equal("10.0.0.1", event["actor"])
+	assert.Equal(accesslog.AuditCategoryDenied, event["category"])
+	assert.Equal("POST /orders", event["target"])
+}
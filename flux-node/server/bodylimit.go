@@ -0,0 +1,49 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/labstack/gommon/bytes"
+	"io"
+	"io/ioutil"
+)
+
+// verifyBodyLimit 检查请求Body长度是否超出Endpoint定义的 bodylimit 属性；
+// 全局的Body长度及解码限制由WebListener在读取Body时检查。
+func verifyBodyLimit(ctx *flux.Context) *flux.ServeError {
+	attr := ctx.Endpoint().GetAttr(flux.EndpointAttrTagBodyLimit).GetString()
+	if attr == "" {
+		return nil
+	}
+	limit, err := bytes.Parse(attr)
+	if nil != err || limit <= 0 {
+		logger.TraceContext(ctx).Warnw("SERVER:ROUTE:BODY_LIMIT:INVALID", "bodylimit", attr, "error", err)
+		return nil
+	}
+	size := ctx.Request().ContentLength
+	if size < 0 {
+		size = bodySize(ctx, limit)
+	}
+	if size <= limit {
+		return nil
+	}
+	logger.TraceContext(ctx).Infow("SERVER:ROUTE:BODY_TOO_LARGE", "size", size, "bodylimit", attr)
+	return &flux.ServeError{
+		StatusCode: flux.StatusTooLarge,
+		ErrorCode:  flux.ErrorCodeRequestTooLarge,
+		Message:    flux.ErrorMessageRequestTooLarge,
+		CauseError: fmt.Errorf("request body size: %d, limit: %d", size, limit),
+	}
+}
+
+// bodySize 读取Body计算长度，最多读取 limit+1 字节
+func bodySize(ctx *flux.Context, limit int64) int64 {
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return 0
+	}
+	defer reader.Close()
+	n, _ := io.Copy(ioutil.Discard, io.LimitReader(reader, limit+1))
+	return n
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Filter执行时间预算配置：disabled，policy，filters
+	ConfigNsFilterBudget = "filter_budget"
+)
+
+const (
+	ConfigKeyBudgetPolicy   = "policy"
+	ConfigKeyBudgetFilters  = "filters"
+	ConfigKeyBudgetFilterId = "filter_id"
+	ConfigKeyBudgetTimeout  = "timeout"
+)
+
+const (
+	// FilterBudgetPolicySkip 超出时间预算时跳过此Filter，继续执行后续Filter
+	FilterBudgetPolicySkip = "skip"
+	// FilterBudgetPolicyFail 超出时间预算时返回超时错误
+	FilterBudgetPolicyFail = "fail"
+)
+
+// FilterBudgets 为指定Filter设置执行时间预算，限制Filter自身（不含后续调用链）增加的请求延迟；
+// Filter执行期间，请求Context的截止时间为预算时间，使用 ctx.Context() 的网络调用在预算耗尽时被取消；
+// 预算耗尽时Filter仍未返回或进入后续调用链，则不再等待，按策略跳过此Filter或返回超时错误；
+// 被放弃的Filter在后台继续执行至返回，其后续调用链不再执行。
+type FilterBudgets struct {
+	budgets map[string]filterBudget
+	counter flux.CounterVec
+}
+
+type filterBudget struct {
+	timeout time.Duration
+	policy  string
+}
+
+func NewFilterBudgets(counter flux.CounterVec) *FilterBudgets {
+	return &FilterBudgets{
+		budgets: make(map[string]filterBudget, 4),
+		counter: counter,
+	}
+}
+
+func (b *FilterBudgets) Init(config *flux.Configuration) error {
+	if IsDisabled(config) {
+		return nil
+	}
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyBudgetPolicy: FilterBudgetPolicyFail,
+	})
+	defaultPolicy := config.GetString(ConfigKeyBudgetPolicy)
+	for _, item := range config.GetConfigurationSlice(ConfigKeyBudgetFilters) {
+		id := item.GetString(ConfigKeyBudgetFilterId)
+		timeout := item.GetDuration(ConfigKeyBudgetTimeout)
+		if id == "" || timeout <= 0 {
+			return fmt.Errorf("filter budget requires <filter_id> and <timeout>, was: %s, %s", id, timeout)
+		}
+		policy := item.GetString(ConfigKeyBudgetPolicy)
+		if policy == "" {
+			policy = defaultPolicy
+		}
+		if policy != FilterBudgetPolicySkip && policy != FilterBudgetPolicyFail {
+			return fmt.Errorf("unknown filter budget policy: %s, filter-id: %s", policy, id)
+		}
+		b.budgets[id] = filterBudget{timeout: timeout, policy: policy}
+		logger.Infow("Filter budget init", "filter-id", id, "timeout", timeout, "policy", policy)
+	}
+	return nil
+}
+
+// Decorate 返回按时间预算执行的Filter；未设置预算的Filter原样返回
+func (b *FilterBudgets) Decorate(filter flux.Filter) flux.Filter {
+	budget, ok := b.budgets[filter.FilterId()]
+	if !ok {
+		return filter
+	}
+	return &budgetFilter{Filter: filter, budget: budget, counter: b.counter}
+}
+
+type budgetFilter struct {
+	flux.Filter
+	budget  filterBudget
+	counter flux.CounterVec
+}
+
+const (
+	budgetRunning int32 = iota
+	budgetEntered
+	budgetAbandoned
+)
+
+func (f *budgetFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		start := time.Now()
+		restore := ctx.WithScopedTimeout(f.budget.timeout)
+		state := budgetRunning
+		done := make(chan *flux.ServeError, 1)
+		go func() {
+			defer func() {
+				if rvr := recover(); nil != rvr {
+					done <- &flux.ServeError{
+						StatusCode: flux.StatusServerError,
+						ErrorCode:  flux.ErrorCodeGatewayInternal,
+						Message:    "FILTER:PANIC:" + f.FilterId(),
+						CauseError: fmt.Errorf("filter panic: %v", rvr),
+					}
+				}
+			}()
+			done <- f.Filter.DoFilter(func(ctx *flux.Context) *flux.ServeError {
+				// 预算已耗尽，请求已按策略处理
+				if !atomic.CompareAndSwapInt32(&state, budgetRunning, budgetEntered) {
+					return &flux.ServeError{
+						StatusCode: flux.StatusTimeout,
+						ErrorCode:  flux.ErrorCodeGatewayTimeout,
+						Message:    "FILTER:TIMEOUT:" + f.FilterId(),
+					}
+				}
+				// 进入后续调用链，恢复请求原来的截止时间
+				restore()
+				return next(ctx)
+			})(ctx)
+		}()
+		timer := time.NewTimer(f.budget.timeout)
+		defer timer.Stop()
+		select {
+		case serr := <-done:
+			if atomic.LoadInt32(&state) == budgetEntered {
+				return serr
+			}
+			restore()
+			// Filter未进入后续调用链，并在预算耗尽后返回错误：视为超时
+			if nil != serr && time.Since(start) > f.budget.timeout {
+				if serr := f.exceeded(ctx, start); nil != serr {
+					return serr
+				}
+				return next(ctx)
+			}
+			return serr
+		case <-timer.C:
+			if !atomic.CompareAndSwapInt32(&state, budgetRunning, budgetAbandoned) {
+				// Filter已进入后续调用链，等待调用链返回
+				return <-done
+			}
+			restore()
+			if serr := f.exceeded(ctx, start); nil != serr {
+				return serr
+			}
+			return next(ctx)
+		}
+	}
+}
+
+// exceeded 记录超出预算的Filter；按策略返回超时错误，或者返回nil以跳过此Filter
+func (f *budgetFilter) exceeded(ctx *flux.Context, start time.Time) *flux.ServeError {
+	id := f.FilterId()
+	f.counter.WithLabelValues(id, f.budget.policy).Inc()
+	logger.ModuleOf(logger.ModuleFilter, logger.TraceContext(ctx)).Warnw("SERVER:FILTER:BUDGET_EXCEEDED", "filter-id", id,
+		"budget", f.budget.timeout, "elapsed", time.Since(start), "policy", f.budget.policy)
+	if f.budget.policy == FilterBudgetPolicySkip {
+		return nil
+	}
+	return &flux.ServeError{
+		StatusCode: flux.StatusTimeout,
+		ErrorCode:  flux.ErrorCodeGatewayTimeout,
+		Message:    "FILTER:TIMEOUT:" + id,
+		CauseError: fmt.Errorf("filter exceeded budget: %s", f.budget.timeout),
+	}
+}
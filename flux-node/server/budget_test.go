@@ -0,0 +1,107 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// budgetTestFilter 由测试函数实现的Filter
+type budgetTestFilter func(ctx *flux.Context, next flux.FilterInvoker) *flux.ServeError
+
+func (budgetTestFilter) FilterId() string {
+	return "budget_test_filter"
+}
+
+func (f budgetTestFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		return f(ctx, next)
+	}
+}
+
+func newBudgetInvoker(t *testing.T, policy string, filter flux.Filter, calls *int32) flux.FilterInvoker {
+	budgets := NewFilterBudgets(nopCounterVec{})
+	assert.NoError(t, budgets.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyBudgetFilters: []interface{}{map[string]interface{}{
+			ConfigKeyBudgetFilterId: filter.FilterId(),
+			ConfigKeyBudgetTimeout:  "20ms",
+			ConfigKeyBudgetPolicy:   policy,
+		}},
+	})))
+	return budgets.Decorate(filter).DoFilter(func(ctx *flux.Context) *flux.ServeError {
+		atomic.AddInt32(calls, 1)
+		// 后续调用链不受Filter预算限制
+		if _, ok := ctx.RemainingTimeout(); ok {
+			return &flux.ServeError{StatusCode: flux.StatusServerError, Message: "budget leaked"}
+		}
+		return nil
+	})
+}
+
+func newBudgetContext() *flux.Context {
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("budget", httptest.NewRequest(http.MethodGet, "http://gateway/budget", nil), nil, nil),
+		&flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/budget"})
+	return ctx
+}
+
+func TestFilterBudget_BlockedFilter(t *testing.T) {
+	assert := assert.New(t)
+	// Filter忽略Context取消，阻塞超过预算时间
+	blocked := budgetTestFilter(func(ctx *flux.Context, next flux.FilterInvoker) *flux.ServeError {
+		time.Sleep(time.Millisecond * 200)
+		return next(ctx)
+	})
+	var calls int32
+	start := time.Now()
+	serr := newBudgetInvoker(t, FilterBudgetPolicyFail, blocked, &calls)(newBudgetContext())
+	assert.True(time.Since(start) < time.Millisecond*150, "filter budget must not wait for the blocked filter")
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusTimeout, serr.StatusCode)
+		assert.Equal("FILTER:TIMEOUT:budget_test_filter", serr.Message)
+	}
+	// 被放弃的Filter返回后，不执行后续调用链
+	time.Sleep(time.Millisecond * 250)
+	assert.Equal(int32(0), atomic.LoadInt32(&calls))
+
+	calls = 0
+	start = time.Now()
+	assert.Nil(newBudgetInvoker(t, FilterBudgetPolicySkip, blocked, &calls)(newBudgetContext()))
+	assert.True(time.Since(start) < time.Millisecond*150, "filter budget must not wait for the blocked filter")
+	time.Sleep(time.Millisecond * 250)
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestFilterBudget_WithinBudget(t *testing.T) {
+	assert := assert.New(t)
+	var calls int32
+	// Filter进入后续调用链后，不受预算限制
+	pass := budgetTestFilter(func(ctx *flux.Context, next flux.FilterInvoker) *flux.ServeError {
+		return next(ctx)
+	})
+	assert.Nil(newBudgetInvoker(t, FilterBudgetPolicyFail, pass, &calls)(newBudgetContext()))
+	assert.Equal(int32(1), atomic.LoadInt32(&calls))
+
+	// Filter在预算耗尽后返回Context取消错误
+	canceled := budgetTestFilter(func(ctx *flux.Context, next flux.FilterInvoker) *flux.ServeError {
+		<-ctx.Context().Done()
+		return &flux.ServeError{StatusCode: flux.StatusBadRequest, Message: "canceled"}
+	})
+	serr := newBudgetInvoker(t, FilterBudgetPolicyFail, canceled, &calls)(newBudgetContext())
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusTimeout, serr.StatusCode)
+	}
+	// Filter panic
+	panics := budgetTestFilter(func(ctx *flux.Context, next flux.FilterInvoker) *flux.ServeError {
+		panic("boom")
+	})
+	serr = newBudgetInvoker(t, FilterBudgetPolicyFail, panics, &calls)(newBudgetContext())
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusServerError, serr.StatusCode)
+	}
+}
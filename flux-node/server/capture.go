@@ -0,0 +1,504 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 请求抓取配置：enable，capacity，sample_ratio，body_limit，methods，patterns，min_status，
+	// redact_headers，redact_queries，redact_fields
+	ConfigNsCapture = "capture"
+)
+
+const (
+	ConfigKeyCaptureCapacity      = "capacity"
+	ConfigKeyCaptureSampleRatio   = "sample_ratio"
+	ConfigKeyCaptureBodyLimit     = "body_limit"
+	ConfigKeyCaptureMethods       = "methods"
+	ConfigKeyCapturePatterns      = "patterns"
+	ConfigKeyCaptureMinStatus     = "min_status"
+	ConfigKeyCaptureRedactHeaders = "redact_headers"
+	ConfigKeyCaptureRedactQueries = "redact_queries"
+	ConfigKeyCaptureRedactFields  = "redact_fields"
+)
+
+const (
+	// Endpoint属性：是否抓取Endpoint的请求；false时不抓取，true时忽略采样比例
+	EndpointAttrTagCapture = "capture"
+)
+
+const (
+	// 脱敏后的替换值；回放请求时不发送脱敏的Header
+	CaptureRedacted = "[REDACTED]"
+)
+
+type captureReplayKey struct{}
+
+// Capture 抓取的请求及响应
+type Capture struct {
+	Id               string              `json:"id"`
+	Time             time.Time           `json:"time"`
+	RequestId        string              `json:"requestId"`
+	ListenerId       string              `json:"listenerId"`
+	RemoteAddr       string              `json:"remoteAddr"`
+	Method           string              `json:"method"`
+	URI              string              `json:"uri"`
+	Host             string              `json:"host"`
+	Pattern          string              `json:"pattern"`
+	Version          string              `json:"version"`
+	RequestHeaders   map[string][]string `json:"requestHeaders"`
+	RequestBody      string              `json:"requestBody,omitempty"`
+	RequestTruncated bool                `json:"requestTruncated"`
+	Status           int                 `json:"status"`
+	ErrorCode        string              `json:"errorCode,omitempty"`
+	ResponseHeaders  map[string][]string `json:"responseHeaders"`
+	ResponseBody     string              `json:"responseBody,omitempty"`
+	Truncated        bool                `json:"truncated"`
+	Elapsed          string              `json:"elapsed"`
+}
+
+// CaptureSummary 抓取记录的摘要，用于列表查询
+type CaptureSummary struct {
+	Id      string    `json:"id"`
+	Time    time.Time `json:"time"`
+	Method  string    `json:"method"`
+	URI     string    `json:"uri"`
+	Pattern string    `json:"pattern"`
+	Status  int       `json:"status"`
+	Elapsed string    `json:"elapsed"`
+}
+
+// CaptureReplay 抓取记录的回放结果
+type CaptureReplay struct {
+	Id            string              `json:"id"`
+	Status        int                 `json:"status"`
+	Headers       map[string][]string `json:"headers"`
+	Body          string              `json:"body,omitempty"`
+	Elapsed       string              `json:"elapsed"`
+	StatusMatched bool                `json:"statusMatched"`
+	BodyMatched   bool                `json:"bodyMatched"`
+}
+
+// Captures 按采样比例及过滤规则抓取完整的请求及响应，保存在固定容量的环形缓冲区中，用于调试及回放；
+// 请求及响应的敏感Header，Query参数及JSON/Form字段按规则脱敏后保存。
+type Captures struct {
+	capacity  int
+	ratio     int
+	bodyLimit int
+	minStatus int
+	methods   map[string]bool
+	patterns  []string
+	headers   map[string]bool
+	queries   map[string]bool
+	fields    map[string]bool
+	ring      []*Capture
+	next      int
+	seq       uint64
+	mu        sync.RWMutex
+}
+
+func NewCaptures() *Captures {
+	return &Captures{}
+}
+
+func (c *Captures) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCaptureCapacity:    100,
+		ConfigKeyCaptureSampleRatio: 100,
+		ConfigKeyCaptureBodyLimit:   1024 * 64,
+		ConfigKeyCaptureRedactHeaders: []string{
+			flux.HeaderAuthorization, flux.HeaderCookie, flux.HeaderSetCookie, "Proxy-Authorization",
+		},
+		ConfigKeyCaptureRedactQueries: []string{"token", "access_token"},
+		ConfigKeyCaptureRedactFields:  []string{"password", "secret", "token"},
+	})
+	c.capacity = config.GetInt(ConfigKeyCaptureCapacity)
+	c.ratio = config.GetInt(ConfigKeyCaptureSampleRatio)
+	c.bodyLimit = config.GetInt(ConfigKeyCaptureBodyLimit)
+	c.minStatus = config.GetInt(ConfigKeyCaptureMinStatus)
+	if c.capacity <= 0 {
+		return fmt.Errorf("capture config(capacity) must be positive, was: %d", c.capacity)
+	}
+	c.ring = make([]*Capture, c.capacity)
+	c.methods = toLookupSet(config.GetStringSlice(ConfigKeyCaptureMethods), strings.ToUpper)
+	c.patterns = config.GetStringSlice(ConfigKeyCapturePatterns)
+	c.headers = toLookupSet(config.GetStringSlice(ConfigKeyCaptureRedactHeaders), http.CanonicalHeaderKey)
+	c.queries = toLookupSet(config.GetStringSlice(ConfigKeyCaptureRedactQueries), nil)
+	c.fields = toLookupSet(config.GetStringSlice(ConfigKeyCaptureRedactFields), strings.ToLower)
+	return nil
+}
+
+// Sampled 按Endpoint属性，过滤规则及采样比例判断是否抓取请求；回放的请求不再抓取
+func (c *Captures) Sampled(webex flux.ServerWebContext, endpoint *flux.Endpoint) bool {
+	if nil != webex.Context().Value(captureReplayKey{}) {
+		return false
+	}
+	attr, forced := endpoint.GetAttrEx(EndpointAttrTagCapture)
+	if forced && !attr.GetBool() {
+		return false
+	}
+	if len(c.methods) > 0 && !c.methods[webex.Method()] {
+		return false
+	}
+	if len(c.patterns) > 0 && !matchCapturePatterns(c.patterns, endpoint.HttpPattern) {
+		return false
+	}
+	return forced || c.ratio >= 100 || rand.Intn(100) < c.ratio
+}
+
+// Wrap 包装响应Writer以记录响应数据；在压缩之后包装，记录未压缩的响应数据
+func (c *Captures) Wrap(webex flux.ServerWebContext) *captureWriter {
+	w := &captureWriter{ResponseWriter: webex.ResponseWriter(), limit: c.bodyLimit}
+	webex.SetResponseWriter(w)
+	return w
+}
+
+// Record 记录已完成的请求
+func (c *Captures) Record(ctx *flux.Context, listenerId string, w *captureWriter, serr *flux.ServeError) {
+	status := w.status
+	if nil != serr && status == 0 {
+		status = serr.StatusCode
+	}
+	if status < c.minStatus {
+		return
+	}
+	endpoint := ctx.Endpoint()
+	record := &Capture{
+		Time:            ctx.StartAt(),
+		RequestId:       ctx.RequestId(),
+		ListenerId:      listenerId,
+		RemoteAddr:      flux.RealIP(ctx),
+		Method:          ctx.Method(),
+		URI:             c.redactURI(ctx.URL()),
+		Host:            ctx.Host(),
+		Pattern:         endpoint.HttpPattern,
+		Version:         endpoint.Version,
+		RequestHeaders:  c.redactHeaders(ctx.HeaderVars()),
+		Status:          status,
+		ResponseHeaders: c.redactHeaders(w.Header()),
+		Truncated:       w.truncated,
+		Elapsed:         time.Since(ctx.StartAt()).String(),
+	}
+	if nil != serr {
+		record.ErrorCode = serr.GetErrorCode()
+	}
+	if reader, err := ctx.BodyReader(); nil == err {
+		data, _ := ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if len(data) > c.bodyLimit {
+			data, record.RequestTruncated = data[:c.bodyLimit], true
+		}
+		record.RequestBody = c.redactBody(ctx.HeaderVar(flux.HeaderContentType), data)
+	}
+	record.ResponseBody = c.redactBody(w.Header().Get(flux.HeaderContentType), w.body.Bytes())
+	c.mu.Lock()
+	c.seq++
+	record.Id = strconv.FormatUint(c.seq, 10)
+	c.ring[c.next] = record
+	c.next = (c.next + 1) % c.capacity
+	c.mu.Unlock()
+}
+
+// Captures 返回按时间倒序的抓取记录摘要
+func (c *Captures) Captures() []CaptureSummary {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	out := make([]CaptureSummary, 0, c.capacity)
+	for i := 1; i <= c.capacity; i++ {
+		record := c.ring[(c.next-i+c.capacity)%c.capacity]
+		if nil == record {
+			break
+		}
+		out = append(out, CaptureSummary{
+			Id: record.Id, Time: record.Time, Method: record.Method, URI: record.URI,
+			Pattern: record.Pattern, Status: record.Status, Elapsed: record.Elapsed,
+		})
+	}
+	return out
+}
+
+// Lookup 按ID查找抓取记录
+func (c *Captures) Lookup(id string) (*Capture, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, record := range c.ring {
+		if nil != record && record.Id == id {
+			return record, true
+		}
+	}
+	return nil, false
+}
+
+// Clear 清除全部抓取记录
+func (c *Captures) Clear() {
+	c.mu.Lock()
+	c.ring = make([]*Capture, c.capacity)
+	c.next = 0
+	c.mu.Unlock()
+}
+
+func (c *Captures) redactHeaders(header http.Header) map[string][]string {
+	out := make(map[string][]string, len(header))
+	for name, values := range header {
+		if c.headers[http.CanonicalHeaderKey(name)] {
+			out[name] = []string{CaptureRedacted}
+		} else {
+			out[name] = append([]string(nil), values...)
+		}
+	}
+	return out
+}
+
+func (c *Captures) redactURI(u *url.URL) string {
+	if len(c.queries) == 0 || u.RawQuery == "" {
+		return u.RequestURI()
+	}
+	query := u.Query()
+	for name := range query {
+		if c.queries[name] {
+			query.Set(name, CaptureRedacted)
+		}
+	}
+	return u.EscapedPath() + "?" + query.Encode()
+}
+
+// redactBody 按字段名（不区分大小写）脱敏JSON及Form格式的数据；无法解析的数据保持原样
+func (c *Captures) redactBody(contentType string, data []byte) string {
+	if len(c.fields) == 0 || len(data) == 0 {
+		return string(data)
+	}
+	switch {
+	case strings.Contains(contentType, "json"):
+		var value interface{}
+		if err := json.Unmarshal(data, &value); nil != err {
+			return string(data)
+		}
+		if out, err := json.Marshal(c.redactValue(value)); nil == err {
+			return string(out)
+		}
+	case strings.HasPrefix(contentType, flux.MIMEApplicationForm):
+		values, err := url.ParseQuery(string(data))
+		if nil != err {
+			return string(data)
+		}
+		for name := range values {
+			if c.fields[strings.ToLower(name)] {
+				values.Set(name, CaptureRedacted)
+			}
+		}
+		return values.Encode()
+	}
+	return string(data)
+}
+
+func (c *Captures) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if c.fields[strings.ToLower(key)] {
+				v[key] = CaptureRedacted
+			} else {
+				v[key] = c.redactValue(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = c.redactValue(item)
+		}
+	}
+	return value
+}
+
+// captureWriter 记录响应状态码及不超过限制大小的响应数据
+type captureWriter struct {
+	http.ResponseWriter
+	status    int
+	limit     int
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *captureWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if remaining := w.limit - w.body.Len(); remaining < len(b) {
+		w.body.Write(b[:remaining])
+		w.truncated = true
+	} else {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *captureWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// initCaptures 加载请求抓取配置；未开启时不抓取请求
+func (s *BootstrapServer) initCaptures() error {
+	config := flux.NewConfigurationOfNS(ConfigNsCapture)
+	if !config.GetBool("enable") {
+		s.captures = nil
+		return nil
+	}
+	return s.dispatcher.AddInitHook(s.captures, config)
+}
+
+// captureSampled 判断是否抓取请求
+func (s *BootstrapServer) captureSampled(webex flux.ServerWebContext, endpoint *flux.Endpoint) bool {
+	return nil != s.captures && s.captures.Sampled(webex, endpoint)
+}
+
+// ReplayCapture 按当前的路由表回放抓取的请求；回放请求不发送已脱敏的Header，且不会被再次抓取。
+// 非安全方法（GET，HEAD，OPTIONS之外）的请求会再次修改后端数据，须确认后回放；请求Body被截断或脱敏的请求不能回放。
+func (s *BootstrapServer) ReplayCapture(record *Capture, confirmed bool) (*CaptureReplay, error) {
+	switch record.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		if !confirmed {
+			return nil, fmt.Errorf("replay unsafe method requires confirmation, method: %s", record.Method)
+		}
+	}
+	if record.RequestTruncated {
+		return nil, fmt.Errorf("request body was truncated, id: %s", record.Id)
+	}
+	if strings.Contains(record.RequestBody, CaptureRedacted) {
+		return nil, fmt.Errorf("request body was redacted, id: %s", record.Id)
+	}
+	listener, ok := s.WebListenerById(record.ListenerId)
+	if !ok {
+		return nil, fmt.Errorf("web listener not found, id: %s", record.ListenerId)
+	}
+	request := httptest.NewRequest(record.Method, record.URI, strings.NewReader(record.RequestBody))
+	request.Host = record.Host
+	for name, values := range record.RequestHeaders {
+		if len(values) == 1 && values[0] == CaptureRedacted {
+			continue
+		}
+		for _, value := range values {
+			request.Header.Add(name, value)
+		}
+	}
+	request.Header.Del(flux.HeaderContentLength)
+	request.Header.Del(flux.HeaderXRequestID)
+	request = request.WithContext(context.WithValue(request.Context(), captureReplayKey{}, record.Id))
+	recorder := httptest.NewRecorder()
+	start := time.Now()
+	listener.ServeHTTP(recorder, request)
+	body := recorder.Body.String()
+	return &CaptureReplay{
+		Id:            record.Id,
+		Status:        recorder.Code,
+		Headers:       recorder.Header(),
+		Body:          body,
+		Elapsed:       time.Since(start).String(),
+		StatusMatched: recorder.Code == record.Status,
+		BodyMatched:   !record.Truncated && body == record.ResponseBody,
+	}, nil
+}
+
+// CapturesHandler 查询抓取记录列表的管理接口
+func (s *BootstrapServer) CapturesHandler(webex flux.ServerWebContext) error {
+	if nil == s.captures {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "capture is disabled"})
+	}
+	return writeJSON(webex, flux.StatusOK, s.captures.Captures())
+}
+
+// CaptureHandler 查询抓取记录详情的管理接口；请求及响应Body默认屏蔽，参数 body=true 时返回
+func (s *BootstrapServer) CaptureHandler(webex flux.ServerWebContext) error {
+	if nil == s.captures {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "capture is disabled"})
+	}
+	record, ok := s.captures.Lookup(webex.PathVar("id"))
+	if !ok {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "capture not found"})
+	}
+	if webex.QueryVar("body") != "true" {
+		masked := *record
+		masked.RequestBody, masked.ResponseBody = maskCaptureBody(record.RequestBody), maskCaptureBody(record.ResponseBody)
+		return writeJSON(webex, flux.StatusOK, &masked)
+	}
+	return writeJSON(webex, flux.StatusOK, record)
+}
+
+// CapturesClearHandler 清除抓取记录的管理接口
+func (s *BootstrapServer) CapturesClearHandler(webex flux.ServerWebContext) error {
+	if nil == s.captures {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "capture is disabled"})
+	}
+	s.captures.Clear()
+	return writeJSON(webex, flux.StatusOK, map[string]string{"status": "cleared"})
+}
+
+// CaptureReplayHandler 回放抓取记录的管理接口；回放非安全方法的请求须指定参数 confirm=true
+func (s *BootstrapServer) CaptureReplayHandler(webex flux.ServerWebContext) error {
+	if nil == s.captures {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "capture is disabled"})
+	}
+	record, ok := s.captures.Lookup(webex.PathVar("id"))
+	if !ok {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "capture not found"})
+	}
+	replay, err := s.ReplayCapture(record, webex.QueryVar("confirm") == "true")
+	if nil != err {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	return writeJSON(webex, flux.StatusOK, replay)
+}
+
+func maskCaptureBody(body string) string {
+	if body == "" {
+		return ""
+	}
+	return CaptureRedacted
+}
+
+func matchCapturePatterns(patterns []string, pattern string) bool {
+	for _, p := range patterns {
+		if p == pattern || (strings.HasSuffix(p, "*") && strings.HasPrefix(pattern, p[:len(p)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func toLookupSet(values []string, normalize func(string) string) map[string]bool {
+	out := make(map[string]bool, len(values))
+	for _, v := range values {
+		if nil != normalize {
+			v = normalize(v)
+		}
+		out[v] = true
+	}
+	return out
+}
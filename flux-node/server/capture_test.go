@@ -0,0 +1,27 @@
+package server
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestBootstrapServer_ReplayCaptureRefused(t *testing.T) {
+	s := &BootstrapServer{}
+	cases := []struct {
+		name      string
+		record    *Capture
+		confirmed bool
+	}{
+		{name: "unsafe method", record: &Capture{Id: "1", Method: http.MethodPost}},
+		{name: "truncated body", record: &Capture{Id: "2", Method: http.MethodPut, RequestTruncated: true}, confirmed: true},
+		{name: "redacted body", record: &Capture{Id: "3", Method: http.MethodPost, RequestBody: `{"password":"` + CaptureRedacted + `"}`}, confirmed: true},
+	}
+	for _, tc := range cases {
+		_, err := s.ReplayCapture(tc.record, tc.confirmed)
+		assert.Error(t, err, tc.name)
+	}
+	// 安全方法不需要确认，WebListener不存在
+	_, err := s.ReplayCapture(&Capture{Id: "4", Method: http.MethodGet, ListenerId: "missing"}, false)
+	assert.EqualError(t, err, "web listener not found, id: missing")
+}
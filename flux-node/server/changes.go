@@ -0,0 +1,187 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// 路由表变更历史配置：disabled，capacity
+	ConfigNsChangeHistory = "change_history"
+)
+
+const (
+	ConfigKeyChangeHistoryCapacity = "capacity"
+)
+
+const (
+	defaultChangeHistoryCapacity = 256
+)
+
+const (
+	ChangeKindEndpoint = "endpoint"
+	ChangeKindService  = "service"
+)
+
+// ChangeRecord 一次Endpoint/Service注册事件的变更记录
+type ChangeRecord struct {
+	Seq       uint64        `json:"seq"`
+	Time      time.Time     `json:"time"`
+	Kind      string        `json:"kind"`
+	EventType string        `json:"eventType"`
+	Source    string        `json:"source"`
+	Key       string        `json:"key"`
+	Changes   []FieldChange `json:"changes,omitempty"`
+}
+
+// FieldChange 变更前后的字段值；字段名为JSON路径，例如 service.interface
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old,omitempty"`
+	New   interface{} `json:"new,omitempty"`
+}
+
+// changeHistory 有界的路由表变更历史，超出容量时丢弃最早的记录；
+// 保存每个Endpoint/Service的最后定义，用于计算变更字段。
+type changeHistory struct {
+	records  []ChangeRecord
+	capacity int
+	seq      uint64
+	last     map[string]map[string]interface{}
+	mu       sync.RWMutex
+}
+
+func newChangeHistory(capacity int) *changeHistory {
+	return &changeHistory{
+		records:  make([]ChangeRecord, 0, capacity),
+		capacity: capacity,
+		last:     make(map[string]map[string]interface{}, 64),
+	}
+}
+
+func (h *changeHistory) setCapacity(capacity int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.capacity = capacity
+	h.trim()
+}
+
+func (h *changeHistory) record(kind string, eventType flux.EventType, source, key string, value interface{}) {
+	fields, err := flattenDefinition(value)
+	if nil != err {
+		logger.Warnw("SERVER:CHANGES:FLATTEN/ERROR", "kind", kind, "key", key, "error", err)
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.capacity <= 0 {
+		return
+	}
+	lastKey := kind + ":" + key
+	previous := h.last[lastKey]
+	if eventType == flux.EventTypeRemoved {
+		fields = nil
+		delete(h.last, lastKey)
+	} else {
+		h.last[lastKey] = fields
+	}
+	h.seq++
+	h.records = append(h.records, ChangeRecord{
+		Seq:       h.seq,
+		Time:      time.Now(),
+		Kind:      kind,
+		EventType: eventTypeName(eventType),
+		Source:    source,
+		Key:       key,
+		Changes:   diffFields(previous, fields),
+	})
+	h.trim()
+}
+
+func (h *changeHistory) trim() {
+	if over := len(h.records) - h.capacity; over > 0 {
+		h.records = append(h.records[:0:0], h.records[over:]...)
+	}
+}
+
+// query 按时间倒序返回变更记录；key不为空时只返回指定Key的记录
+func (h *changeHistory) query(key string, limit int) []ChangeRecord {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	out := make([]ChangeRecord, 0, len(h.records))
+	for i := len(h.records) - 1; i >= 0 && (limit <= 0 || len(out) < limit); i-- {
+		if key == "" || h.records[i].Key == key {
+			out = append(out, h.records[i])
+		}
+	}
+	return out
+}
+
+func flattenDefinition(value interface{}) (map[string]interface{}, error) {
+	data, err := ext.JSONMarshal(value)
+	if nil != err {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if err := ext.JSONUnmarshal(data, &tree); nil != err {
+		return nil, err
+	}
+	fields := make(map[string]interface{}, 32)
+	flattenInto(fields, "", tree)
+	return fields, nil
+}
+
+func flattenInto(fields map[string]interface{}, prefix string, tree map[string]interface{}) {
+	for k, v := range tree {
+		if prefix != "" {
+			k = prefix + "." + k
+		}
+		if sub, ok := v.(map[string]interface{}); ok && len(sub) > 0 {
+			flattenInto(fields, k, sub)
+		} else {
+			fields[k] = v
+		}
+	}
+}
+
+func diffFields(old, new map[string]interface{}) []FieldChange {
+	changes := make([]FieldChange, 0, 4)
+	for k, ov := range old {
+		if nv, ok := new[k]; !ok || !reflect.DeepEqual(ov, nv) {
+			changes = append(changes, FieldChange{Field: k, Old: ov, New: new[k]})
+		}
+	}
+	for k, nv := range new {
+		if _, ok := old[k]; !ok {
+			changes = append(changes, FieldChange{Field: k, New: nv})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+	return changes
+}
+
+// initChangeHistory 加载路由表变更历史配置
+func (s *BootstrapServer) initChangeHistory() {
+	config := flux.NewConfigurationOfNS(ConfigNsChangeHistory)
+	if IsDisabled(config) {
+		s.changes.setCapacity(0)
+		return
+	}
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyChangeHistoryCapacity: defaultChangeHistoryCapacity,
+	})
+	s.changes.setCapacity(config.GetInt(ConfigKeyChangeHistoryCapacity))
+}
+
+// ChangesHandler 查询最近的路由表变更历史的管理接口；支持参数：key，limit
+func (s *BootstrapServer) ChangesHandler(webex flux.ServerWebContext) error {
+	limit, _ := strconv.Atoi(webex.QueryVar("limit"))
+	return writeJSON(webex, flux.StatusOK, s.changes.query(webex.QueryVar("key"), limit))
+}
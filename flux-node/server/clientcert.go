@@ -0,0 +1,50 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"strings"
+)
+
+// verifyClientCert 按Endpoint的 clientcert，clientcn 属性检查客户端证书；
+// 证书已由WebListener按 tls_client_ca 验证，此处只检查是否提供以及CommonName是否允许。
+func verifyClientCert(ctx *flux.Context) *flux.ServeError {
+	endpoint := ctx.Endpoint()
+	names := endpoint.GetAttr(flux.EndpointAttrTagClientCN).GetStringSlice()
+	if !endpoint.GetAttr(flux.EndpointAttrTagClientCert).GetBool() && len(names) == 0 {
+		return nil
+	}
+	cert, ok := ctx.ClientCertificate()
+	if !ok {
+		logger.TraceContext(ctx).Infow("SERVER:ROUTE:CLIENT_CERT_REQUIRED")
+		return &flux.ServeError{
+			StatusCode: flux.StatusUnauthorized,
+			ErrorCode:  flux.ErrorCodeClientCertRequired,
+			Message:    "ROUTE:CLIENT_CERT_REQUIRED",
+		}
+	}
+	if len(names) == 0 || matchCommonNames(names, cert.Subject.CommonName) {
+		return nil
+	}
+	logger.TraceContext(ctx).Infow("SERVER:ROUTE:CLIENT_CERT_DENIED", "subject", cert.Subject.String(), "allowed", names)
+	return &flux.ServeError{
+		StatusCode: flux.StatusAccessDenied,
+		ErrorCode:  flux.ErrorCodeClientCertDenied,
+		Message:    "ROUTE:CLIENT_CERT_DENIED",
+	}
+}
+
+// matchCommonNames 匹配证书CommonName；支持 *.example.com 形式的子域名通配
+func matchCommonNames(names []string, cn string) bool {
+	cn = strings.ToLower(cn)
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == cn {
+			return true
+		}
+		if strings.HasPrefix(name, "*.") && strings.HasSuffix(cn, name[1:]) && len(cn) > len(name)-1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchCommonNames(t *testing.T) {
+	cases := []struct {
+		names    []string
+		cn       string
+		expected bool
+	}{
+		{names: []string{"gateway.example.com"}, cn: "gateway.example.com", expected: true},
+		{names: []string{" Gateway.Example.COM "}, cn: "gateway.example.com", expected: true},
+		{names: []string{"gateway.example.com"}, cn: "GATEWAY.example.com", expected: true},
+		{names: []string{"gateway.example.com"}, cn: "api.example.com", expected: false},
+		{names: []string{"*.example.com"}, cn: "api.example.com", expected: true},
+		// 通配符不匹配根域名，以及以相同字符结尾的其它域名
+		{names: []string{"*.example.com"}, cn: "example.com", expected: false},
+		{names: []string{"*.example.com"}, cn: "evilexample.com", expected: false},
+		{names: []string{"*.example.com"}, cn: ".example.com", expected: false},
+		{names: []string{"*.example.com"}, cn: "api.example.com.evil.org", expected: false},
+		{names: []string{"api.example.org", "*.example.com"}, cn: "api.example.com", expected: true},
+		{names: []string{"*.example.com"}, cn: "", expected: false},
+		{names: []string{}, cn: "api.example.com", expected: false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, matchCommonNames(c.names, c.cn), "names: %v, cn: %s", c.names, c.cn)
+	}
+}
+
+func TestVerifyClientCert(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	cases := []struct {
+		name   string
+		attrs  []flux.Attribute
+		cn     string
+		noCert bool
+		code   string
+	}{
+		{name: "not required, no cert", noCert: true},
+		{name: "not required, any cert", cn: "anyone"},
+		{name: "required, no cert", attrs: []flux.Attribute{{Name: flux.EndpointAttrTagClientCert, Value: true}},
+			noCert: true, code: flux.ErrorCodeClientCertRequired},
+		{name: "required, any cert", attrs: []flux.Attribute{{Name: flux.EndpointAttrTagClientCert, Value: true}}, cn: "anyone"},
+		// 定义 clientcn 时必须提供证书
+		{name: "cn, no cert", attrs: []flux.Attribute{{Name: flux.EndpointAttrTagClientCN, Value: "*.example.com"}},
+			noCert: true, code: flux.ErrorCodeClientCertRequired},
+		{name: "cn, allowed", attrs: []flux.Attribute{{Name: flux.EndpointAttrTagClientCN, Value: "*.example.com"}},
+			cn: "api.example.com"},
+		{name: "cn, apex denied", attrs: []flux.Attribute{{Name: flux.EndpointAttrTagClientCN, Value: "*.example.com"}},
+			cn: "example.com", code: flux.ErrorCodeClientCertDenied},
+		{name: "cn, suffix denied", attrs: []flux.Attribute{{Name: flux.EndpointAttrTagClientCN, Value: "*.example.com"}},
+			cn: "evilexample.com", code: flux.ErrorCodeClientCertDenied},
+		{name: "cn list", attrs: []flux.Attribute{{Name: flux.EndpointAttrTagClientCert, Value: true},
+			{Name: flux.EndpointAttrTagClientCN, Value: []string{"billing", "orders"}}}, cn: "orders"},
+	}
+	for _, c := range cases {
+		request := httptest.NewRequest(http.MethodGet, "https://gateway/orders", nil)
+		if c.noCert {
+			request.TLS = &tls.ConnectionState{}
+		} else {
+			request.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: c.cn}}}}
+		}
+		ctx := flux.NewContext()
+		ctx.Reset(common.MockRequestContext("clientcert", request, nil, nil), &flux.Endpoint{
+			HttpPattern:        "/orders",
+			EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: c.attrs},
+		})
+		serr := verifyClientCert(ctx)
+		if c.code == "" {
+			assert.Nil(t, serr, c.name)
+			continue
+		}
+		if assert.NotNil(t, serr, c.name) {
+			assert.Equal(t, c.code, serr.ErrorCode, c.name)
+			if c.code == flux.ErrorCodeClientCertRequired {
+				assert.Equal(t, flux.StatusUnauthorized, serr.StatusCode, c.name)
+			} else {
+				assert.Equal(t, flux.StatusAccessDenied, serr.StatusCode, c.name)
+			}
+		}
+	}
+}
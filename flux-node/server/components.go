@@ -0,0 +1,120 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"reflect"
+	"sort"
+)
+
+const (
+	ComponentKindFilter      = ext.ComponentKindFilter
+	ComponentKindSelector    = ext.ComponentKindSelector
+	ComponentKindTransporter = ext.ComponentKindTransporter
+	ComponentKindSerializer  = ext.ComponentKindSerializer
+	ComponentKindResolver    = ext.ComponentKindResolver
+	ComponentKindBodyParser  = ext.ComponentKindBodyParser
+	ComponentKindEncoder     = ext.ComponentKindEncoder
+	ComponentKindDiscovery   = ext.ComponentKindDiscovery
+	ComponentKindFactory     = ext.ComponentKindFactory
+	ComponentKindListener    = "web_listener"
+	ComponentKindServer      = "server"
+)
+
+type (
+	// Versioned 组件可实现此接口声明自身版本；未实现时使用服务构建版本
+	Versioned interface {
+		Version() string
+	}
+)
+
+// ComponentInfo 已注册的扩展组件信息；注册来源，注册/初始化顺序及生命周期状态来自 ext.Registry 的注册记录
+type ComponentInfo struct {
+	Kind          string             `json:"kind"`
+	Id            string             `json:"id"`
+	Type          string             `json:"type"`
+	ConfigNs      string             `json:"configNs,omitempty"`
+	Version       string             `json:"version"`
+	Enabled       bool               `json:"enabled"`
+	Origin        string             `json:"origin,omitempty"`
+	State         ext.ComponentState `json:"state,omitempty"`
+	RegisterOrder int                `json:"registerOrder,omitempty"`
+	InitOrder     int                `json:"initOrder,omitempty"`
+	Error         string             `json:"error,omitempty"`
+}
+
+// Components 返回全部已注册的扩展组件
+func (s *BootstrapServer) Components() []ComponentInfo {
+	out := make([]ComponentInfo, 0, 32)
+	seen := make(map[string]bool, 32)
+	add := func(kind, id string, ref interface{}, ns string, enabled bool) {
+		version := s.build.Version
+		if v, ok := ref.(Versioned); ok {
+			version = v.Version()
+		}
+		info := ComponentInfo{
+			Kind: kind, Id: id, Type: reflect.TypeOf(ref).String(),
+			ConfigNs: ns, Version: version, Enabled: enabled,
+		}
+		if rec, ok := ext.ComponentOf(kind, id); ok {
+			info.Origin, info.State, info.Error = rec.Origin, rec.State, rec.Error
+			info.RegisterOrder, info.InitOrder = rec.RegisterOrder, rec.InitOrder
+		}
+		seen[kind+"/"+id] = true
+		out = append(out, info)
+	}
+	for _, filter := range s.dispatcher.Filters() {
+		ref, _ := filterById(filter.FilterId)
+		add(ComponentKindFilter, filter.FilterId, ref, filter.ConfigNs, filter.Enabled)
+	}
+	for _, selector := range ext.FilterSelectors() {
+		add(ComponentKindSelector, reflect.TypeOf(selector).String(), selector, "", true)
+	}
+	for proto, transporter := range ext.Transporters() {
+		add(ComponentKindTransporter, proto, transporter, flux.NamespaceTransporters+"."+proto, true)
+	}
+	for _, discovery := range ext.EndpointDiscoveries() {
+		ns := flux.NamespaceEndpointDiscoveryServices + "." + discovery.Id()
+		add(ComponentKindDiscovery, discovery.Id(), discovery, ns, !IsDisabled(flux.NewConfigurationOfNS(ns)))
+	}
+	for id, listener := range s.listener {
+		add(ComponentKindListener, id, listener, flux.NamespaceWebListeners+"."+id, true)
+	}
+	for name, serializer := range ext.Serializers() {
+		add(ComponentKindSerializer, name, serializer, "", true)
+	}
+	for name, resolver := range ext.MTValueResolvers() {
+		add(ComponentKindResolver, name, resolver, "", true)
+	}
+	for name, parser := range ext.BodyParsers() {
+		add(ComponentKindBodyParser, name, parser, "", true)
+	}
+	for name, encoder := range ext.ResponseEncoders() {
+		add(ComponentKindEncoder, name, encoder, ConfigNsCompression, s.compressor.enabled)
+	}
+	for name, factory := range ext.Factories() {
+		add(ComponentKindFactory, name, factory, "", true)
+	}
+	// 其它已注册或参与生命周期的组件：负载均衡，实例解析及AccessLog等内部组件
+	for _, rec := range ext.Components() {
+		if !seen[rec.Kind+"/"+rec.Id] {
+			out = append(out, ComponentInfo{
+				Kind: rec.Kind, Id: rec.Id, Type: rec.Type, Version: s.build.Version, Enabled: true,
+				Origin: rec.Origin, State: rec.State, Error: rec.Error,
+				RegisterOrder: rec.RegisterOrder, InitOrder: rec.InitOrder,
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Kind != out[j].Kind {
+			return out[i].Kind < out[j].Kind
+		}
+		return out[i].Id < out[j].Id
+	})
+	return out
+}
+
+// ComponentsHandler 查询已注册扩展组件的管理接口
+func (s *BootstrapServer) ComponentsHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.Components())
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// 响应压缩配置：enable，min_size，mime_types，encodings
+	ConfigNsCompression = "compression"
+)
+
+const (
+	ConfigKeyCompressEnable    = "enable"
+	ConfigKeyCompressMinSize   = "min_size"
+	ConfigKeyCompressMimeTypes = "mime_types"
+	ConfigKeyCompressEncodings = "encodings"
+)
+
+func init() {
+	gzipPool := &sync.Pool{New: func() interface{} {
+		return gzip.NewWriter(nil)
+	}}
+	ext.RegisterResponseEncoder("gzip", func(w io.Writer) (io.WriteCloser, error) {
+		gw := gzipPool.Get().(*gzip.Writer)
+		gw.Reset(w)
+		return &pooledEncoder{WriteCloser: gw, flush: gw.Flush, release: func() { gzipPool.Put(gw) }}, nil
+	})
+	zlibPool := &sync.Pool{New: func() interface{} {
+		return zlib.NewWriter(nil)
+	}}
+	ext.RegisterResponseEncoder("deflate", func(w io.Writer) (io.WriteCloser, error) {
+		zw := zlibPool.Get().(*zlib.Writer)
+		zw.Reset(w)
+		return &pooledEncoder{WriteCloser: zw, flush: zw.Flush, release: func() { zlibPool.Put(zw) }}, nil
+	})
+}
+
+// Compressor 按请求的Accept-Encoding压缩响应数据；只压缩长度达到最小值，并且Content-Type在允许列表中的响应；
+// Endpoint可通过 compress 属性关闭响应压缩。
+type Compressor struct {
+	enabled   bool
+	minSize   int
+	mimeTypes []string
+	encodings []string
+}
+
+func NewCompressor() *Compressor {
+	return &Compressor{}
+}
+
+func (c *Compressor) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyCompressEnable:    false,
+		ConfigKeyCompressMinSize:   1024,
+		ConfigKeyCompressMimeTypes: []string{"application/json", "application/xml", "application/javascript", "text/*"},
+		ConfigKeyCompressEncodings: []string{"gzip", "deflate"},
+	})
+	c.enabled = config.GetBool(ConfigKeyCompressEnable)
+	c.minSize = config.GetInt(ConfigKeyCompressMinSize)
+	c.mimeTypes = config.GetStringSlice(ConfigKeyCompressMimeTypes)
+	c.encodings = c.encodings[:0]
+	for _, encoding := range config.GetStringSlice(ConfigKeyCompressEncodings) {
+		if _, ok := ext.ResponseEncoderByName(encoding); ok {
+			c.encodings = append(c.encodings, strings.ToLower(encoding))
+		} else {
+			logger.Warnw("Compression encoding not registered, ignored", "encoding", encoding)
+		}
+	}
+	logger.Infow("Compression init", "enable", c.enabled, "min-size", c.minSize,
+		"mime-types", c.mimeTypes, "encodings", c.encodings)
+	return nil
+}
+
+// Wrap 按请求的Accept-Encoding包装ResponseWriter；返回的Writer必须在响应完成后关闭
+func (c *Compressor) Wrap(webex flux.ServerWebContext, endpoint *flux.Endpoint) (io.Closer, bool) {
+	if !c.enabled {
+		return nil, false
+	}
+	switch strings.ToLower(endpoint.GetAttr(flux.EndpointAttrTagCompress).GetString()) {
+	case "false", "off":
+		return nil, false
+	}
+	webex.ResponseWriter().Header().Add(flux.HeaderVary, flux.HeaderAcceptEncoding)
+	encoding := c.negotiate(webex.HeaderVar(flux.HeaderAcceptEncoding))
+	if encoding == "" {
+		return nil, false
+	}
+	w := &compressWriter{ResponseWriter: webex.ResponseWriter(), compressor: c, encoding: encoding}
+	webex.SetResponseWriter(w)
+	return w, true
+}
+
+// negotiate 按服务端配置的顺序，选择客户端接受的压缩编码
+func (c *Compressor) negotiate(accept string) string {
+	if accept == "" {
+		return ""
+	}
+	qualities := make(map[string]float64, 4)
+	for _, item := range strings.Split(accept, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		q := 1.0
+		for _, p := range parts[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if v, err := strconv.ParseFloat(kv[1], 64); nil == err {
+					q = v
+				}
+			}
+		}
+		qualities[strings.ToLower(parts[0])] = q
+	}
+	for _, encoding := range c.encodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > 0 {
+			return encoding
+		}
+	}
+	return ""
+}
+
+func (c *Compressor) acceptable(header http.Header) bool {
+	if header.Get(flux.HeaderContentEncoding) != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(header.Get(flux.HeaderContentType))
+	return nil == err && matchMediaTypes(c.mimeTypes, mediaType)
+}
+
+// compressWriter 缓存响应数据直到达到最小压缩长度，再决定是否压缩输出
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *Compressor
+	encoding   string
+	status     int
+	buffer     []byte
+	encoder    io.WriteCloser
+	decided    bool
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if w.decided {
+		w.ResponseWriter.WriteHeader(status)
+	} else if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if !w.decided {
+		w.buffer = append(w.buffer, b...)
+		if len(w.buffer) < w.compressor.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(); nil != err {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if nil != w.encoder {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *compressWriter) decide() error {
+	w.decided = true
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	header := w.ResponseWriter.Header()
+	if len(w.buffer) >= w.compressor.minSize && status != http.StatusNoContent && status != http.StatusNotModified &&
+		w.compressor.acceptable(header) {
+		factory, _ := ext.ResponseEncoderByName(w.encoding)
+		if encoder, err := factory(w.ResponseWriter); nil != err {
+			logger.Warnw("SERVER:COMPRESS:ENCODER/ERROR", "encoding", w.encoding, "error", err)
+		} else {
+			w.encoder = encoder
+			header.Set(flux.HeaderContentEncoding, w.encoding)
+			header.Del(flux.HeaderContentLength)
+		}
+	}
+	w.ResponseWriter.WriteHeader(status)
+	buffer := w.buffer
+	w.buffer = nil
+	if len(buffer) == 0 {
+		return nil
+	}
+	_, err := w.Write(buffer)
+	return err
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		_ = w.decide()
+	}
+	if f, ok := w.encoder.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// Close 输出缓存及剩余的编码数据
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 && len(w.buffer) == 0 {
+			return nil
+		}
+		if err := w.decide(); nil != err {
+			return err
+		}
+	}
+	if nil != w.encoder {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+type pooledEncoder struct {
+	io.WriteCloser
+	flush   func() error
+	release func()
+}
+
+func (e *pooledEncoder) Flush() error {
+	return e.flush()
+}
+
+func (e *pooledEncoder) Close() error {
+	err := e.WriteCloser.Close()
+	e.release()
+	return err
+}
@@ -0,0 +1,179 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newCompressor(t *testing.T, config map[string]interface{}) *Compressor {
+	compressor := NewCompressor()
+	config[ConfigKeyCompressEnable] = true
+	if _, ok := config[ConfigKeyCompressMinSize]; !ok {
+		config[ConfigKeyCompressMinSize] = 16
+	}
+	assert.NoError(t, compressor.Init(flux.NewConfigurationOfMap(config)))
+	return compressor
+}
+
+func newCompressContext(accept string, attrs ...flux.Attribute) (*flux.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(http.MethodGet, "http://gateway/users", nil)
+	if accept != "" {
+		request.Header.Set(flux.HeaderAcceptEncoding, accept)
+	}
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("compress", request, nil, nil), &flux.Endpoint{
+		HttpPattern:        "/users",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+	})
+	ctx.SetResponseWriter(recorder)
+	return ctx, recorder
+}
+
+// compressServe 以压缩包装的ResponseWriter输出响应，返回解码后的响应数据
+func compressServe(t *testing.T, compressor *Compressor, ctx *flux.Context, recorder *httptest.ResponseRecorder, write func(w http.ResponseWriter)) string {
+	closer, wrapped := compressor.Wrap(ctx, ctx.Endpoint())
+	write(ctx.ResponseWriter())
+	if wrapped {
+		assert.NoError(t, closer.Close())
+	}
+	var reader io.Reader = recorder.Body
+	var err error
+	switch recorder.Header().Get(flux.HeaderContentEncoding) {
+	case "gzip":
+		reader, err = gzip.NewReader(recorder.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(recorder.Body)
+	}
+	assert.NoError(t, err)
+	data, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func writeResponse(status int, contentType string, chunks ...string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		if contentType != "" {
+			w.Header().Set(flux.HeaderContentType, contentType)
+		}
+		w.WriteHeader(status)
+		for _, chunk := range chunks {
+			_, _ = w.Write([]byte(chunk))
+		}
+	}
+}
+
+func TestCompressor_Negotiate(t *testing.T) {
+	compressor := newCompressor(t, map[string]interface{}{
+		ConfigKeyCompressEncodings: []string{"br", "gzip", "deflate"},
+	})
+	// 未注册实现的编码被忽略
+	assert.Equal(t, []string{"gzip", "deflate"}, compressor.encodings)
+	cases := map[string]string{
+		"":                          "",
+		"identity":                  "",
+		"br":                        "",
+		"gzip":                      "gzip",
+		"GZIP":                      "gzip",
+		"deflate, gzip":             "gzip",
+		"deflate":                   "deflate",
+		"gzip;q=0, deflate":         "deflate",
+		"gzip; q=0.0, deflate;q=0":  "",
+		"gzip;q=0.5, deflate;q=1.0": "gzip",
+		"*":                         "gzip",
+		"*;q=0":                     "",
+		"gzip;q=0, *":               "deflate",
+		"br;q=1.0, *;q=0.1":         "gzip",
+		"gzip;q=invalid":            "gzip",
+	}
+	for accept, expected := range cases {
+		assert.Equal(t, expected, compressor.negotiate(accept), "accept-encoding: "+accept)
+	}
+}
+
+func TestCompressor_Wrap(t *testing.T) {
+	assert := assert.New(t)
+	disabled := NewCompressor()
+	assert.NoError(disabled.Init(flux.NewConfigurationOfMap(map[string]interface{}{})))
+	ctx, recorder := newCompressContext("gzip")
+	_, ok := disabled.Wrap(ctx, ctx.Endpoint())
+	assert.False(ok)
+	assert.Empty(recorder.Header().Get(flux.HeaderVary))
+
+	compressor := newCompressor(t, map[string]interface{}{})
+	ctx, recorder = newCompressContext("gzip", flux.Attribute{Name: flux.EndpointAttrTagCompress, Value: "off"})
+	_, ok = compressor.Wrap(ctx, ctx.Endpoint())
+	assert.False(ok)
+	// 客户端不接受压缩时，仍声明响应随Accept-Encoding变化
+	ctx, recorder = newCompressContext("")
+	_, ok = compressor.Wrap(ctx, ctx.Endpoint())
+	assert.False(ok)
+	assert.Equal(flux.HeaderAcceptEncoding, recorder.Header().Get(flux.HeaderVary))
+}
+
+func TestCompressor_MinSize(t *testing.T) {
+	assert := assert.New(t)
+	compressor := newCompressor(t, map[string]interface{}{})
+	body := `{"users":["` + strings.Repeat("u", 64) + `"]}`
+	// 未达到最小长度：不压缩，缓存的数据在关闭时输出
+	ctx, recorder := newCompressContext("gzip")
+	out := compressServe(t, compressor, ctx, recorder, writeResponse(http.StatusCreated, flux.MIMEApplicationJSONCharsetUTF8, `{"id":1}`))
+	assert.Equal(`{"id":1}`, out)
+	assert.Equal(http.StatusCreated, recorder.Code)
+	assert.Empty(recorder.Header().Get(flux.HeaderContentEncoding))
+	// 分段写入，累计达到最小长度后压缩全部数据
+	ctx, recorder = newCompressContext("gzip, deflate")
+	out = compressServe(t, compressor, ctx, recorder, writeResponse(http.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, body[:8], body[8:12], body[12:]))
+	assert.Equal(body, out)
+	assert.Equal("gzip", recorder.Header().Get(flux.HeaderContentEncoding))
+	assert.Empty(recorder.Header().Get(flux.HeaderContentLength))
+	ctx, recorder = newCompressContext("deflate")
+	out = compressServe(t, compressor, ctx, recorder, writeResponse(http.StatusOK, "text/plain; charset=utf-8", body))
+	assert.Equal(body, out)
+	assert.Equal("deflate", recorder.Header().Get(flux.HeaderContentEncoding))
+	// 无响应数据
+	ctx, recorder = newCompressContext("gzip")
+	closer, ok := compressor.Wrap(ctx, ctx.Endpoint())
+	assert.True(ok)
+	assert.NoError(closer.Close())
+	assert.Empty(recorder.Header().Get(flux.HeaderContentEncoding))
+	assert.Equal(0, recorder.Body.Len())
+}
+
+func TestCompressor_SkipResponses(t *testing.T) {
+	assert := assert.New(t)
+	compressor := newCompressor(t, map[string]interface{}{})
+	body := strings.Repeat("x", 64)
+	// 已编码的响应原样输出
+	ctx, recorder := newCompressContext("gzip")
+	encoded := new(bytes.Buffer)
+	gw := gzip.NewWriter(encoded)
+	_, _ = gw.Write([]byte(body))
+	_ = gw.Close()
+	out := compressServe(t, compressor, ctx, recorder, func(w http.ResponseWriter) {
+		w.Header().Set(flux.HeaderContentEncoding, "gzip")
+		writeResponse(http.StatusOK, flux.MIMEApplicationJSONCharsetUTF8, encoded.String())(w)
+	})
+	assert.Equal(body, out)
+	assert.Equal("gzip", recorder.Header().Get(flux.HeaderContentEncoding))
+	// 不在允许列表中的Content-Type
+	ctx, recorder = newCompressContext("gzip")
+	out = compressServe(t, compressor, ctx, recorder, writeResponse(http.StatusOK, "image/png", body))
+	assert.Equal(body, out)
+	assert.Empty(recorder.Header().Get(flux.HeaderContentEncoding))
+	// 未声明Content-Type
+	ctx, recorder = newCompressContext("gzip")
+	out = compressServe(t, compressor, ctx, recorder, writeResponse(http.StatusOK, "", body))
+	assert.Equal(body, out)
+	assert.Empty(recorder.Header().Get(flux.HeaderContentEncoding))
+}
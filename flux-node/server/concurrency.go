@@ -0,0 +1,197 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 并发请求数限制配置：global，endpoint，service，services，retry_after；数量为0时不限制，支持热加载
+	ConfigNsConcurrency = "concurrency"
+)
+
+const (
+	ConfigKeyConcurrencyGlobal     = "global"
+	ConfigKeyConcurrencyEndpoint   = "endpoint"
+	ConfigKeyConcurrencyService    = "service"
+	ConfigKeyConcurrencyServices   = "services"
+	ConfigKeyConcurrencyRetryAfter = "retry_after"
+)
+
+const (
+	// Endpoint属性：Endpoint的最大并发请求数，覆盖全局配置 endpoint
+	EndpointAttrTagMaxConcurrency = "maxconcurrency"
+)
+
+const (
+	ConcurrencyScopeGlobal   = "global"
+	ConcurrencyScopeEndpoint = "endpoint"
+	ConcurrencyScopeService  = "service"
+)
+
+const (
+	ErrorCodeConcurrencyLimited = "GATEWAY:CONCURRENCY_LIMITED"
+)
+
+// ConcurrencyLimiter 基于信号量的并发请求数限制：按全局，Endpoint，后端服务（ServiceId）三个层级限制正在处理的请求数量；
+// 任一层级达到上限时，返回503错误及 Retry-After Header；各层级的并发数量输出到 concurrency_inflight 指标。
+type ConcurrencyLimiter struct {
+	options   atomic.Value
+	global    *concurrencySemaphore
+	endpoints sync.Map
+	services  sync.Map
+	inflight  flux.GaugeVec
+	rejected  flux.CounterVec
+}
+
+type concurrencyOptions struct {
+	global     int64
+	endpoint   int64
+	service    int64
+	services   map[string]int64
+	retryAfter time.Duration
+}
+
+type concurrencyLevel struct {
+	scope string
+	key   string
+	limit int64
+}
+
+type concurrencySemaphore struct {
+	count int64
+	gauge flux.Gauge
+}
+
+// tryAcquire 并发数量未达到上限时占用一个信号量；limit 小于等于0时不限制
+func (s *concurrencySemaphore) tryAcquire(limit int64) bool {
+	for {
+		count := atomic.LoadInt64(&s.count)
+		if limit > 0 && count >= limit {
+			return false
+		}
+		if atomic.CompareAndSwapInt64(&s.count, count, count+1) {
+			s.gauge.Inc()
+			return true
+		}
+	}
+}
+
+func (s *concurrencySemaphore) release() {
+	atomic.AddInt64(&s.count, -1)
+	s.gauge.Dec()
+}
+
+func NewConcurrencyLimiter(inflight flux.GaugeVec, rejected flux.CounterVec) *ConcurrencyLimiter {
+	limiter := &ConcurrencyLimiter{
+		inflight: inflight,
+		rejected: rejected,
+	}
+	limiter.global = &concurrencySemaphore{gauge: inflight.WithLabelValues(ConcurrencyScopeGlobal, "")}
+	limiter.options.Store(&concurrencyOptions{})
+	return limiter
+}
+
+func (c *ConcurrencyLimiter) Init(config *flux.Configuration) error {
+	return c.OnReload(config)
+}
+
+func (c *ConcurrencyLimiter) OnReload(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyConcurrencyGlobal:     0,
+		ConfigKeyConcurrencyEndpoint:   0,
+		ConfigKeyConcurrencyService:    0,
+		ConfigKeyConcurrencyRetryAfter: time.Second,
+	})
+	opts := &concurrencyOptions{
+		global:     config.GetInt64(ConfigKeyConcurrencyGlobal),
+		endpoint:   config.GetInt64(ConfigKeyConcurrencyEndpoint),
+		service:    config.GetInt64(ConfigKeyConcurrencyService),
+		services:   make(map[string]int64, 8),
+		retryAfter: config.GetDuration(ConfigKeyConcurrencyRetryAfter),
+	}
+	services := config.Sub(ConfigKeyConcurrencyServices)
+	for serviceId := range config.GetStringMap(ConfigKeyConcurrencyServices) {
+		opts.services[serviceId] = services.GetInt64(serviceId)
+	}
+	c.options.Store(opts)
+	logger.Infow("SERVER:CONCURRENCY:LOAD", "global", opts.global, "endpoint", opts.endpoint, "service", opts.service,
+		"services", len(opts.services), "retry-after", opts.retryAfter)
+	return nil
+}
+
+// Acquire 按全局，Endpoint，后端服务的顺序占用并发信号量；返回释放函数，任一层级达到上限时返回错误
+func (c *ConcurrencyLimiter) Acquire(ctx *flux.Context) (func(), *flux.ServeError) {
+	opts := c.options.Load().(*concurrencyOptions)
+	endpoint := ctx.Endpoint()
+	endpointKey := endpoint.HttpMethod + " " + endpoint.HttpPattern
+	endpointLimit := opts.endpoint
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagMaxConcurrency); ok {
+		endpointLimit = int64(attr.GetInt())
+	}
+	serviceKey := ctx.Transporter().ServiceID()
+	serviceLimit, ok := opts.services[serviceKey]
+	if !ok {
+		serviceLimit = opts.service
+	}
+	acquired := make([]*concurrencySemaphore, 0, 3)
+	release := func() {
+		for _, sem := range acquired {
+			sem.release()
+		}
+	}
+	for _, level := range []concurrencyLevel{
+		{scope: ConcurrencyScopeGlobal, key: "", limit: opts.global},
+		{scope: ConcurrencyScopeEndpoint, key: endpointKey, limit: endpointLimit},
+		{scope: ConcurrencyScopeService, key: serviceKey, limit: serviceLimit},
+	} {
+		if level.limit <= 0 {
+			continue
+		}
+		sem := c.semaphore(level.scope, level.key)
+		if !sem.tryAcquire(level.limit) {
+			release()
+			c.rejected.WithLabelValues(level.scope, level.key).Inc()
+			logger.TraceContext(ctx).Infow("SERVER:CONCURRENCY:LIMITED", "scope", level.scope, "key", level.key, "limit", level.limit)
+			return nil, &flux.ServeError{
+				StatusCode: http.StatusServiceUnavailable,
+				ErrorCode:  ErrorCodeConcurrencyLimited,
+				Message:    "CONCURRENCY:LIMITED:" + strings.ToUpper(level.scope),
+				Header:     map[string][]string{flux.HeaderRetryAfter: {retryAfterHeader(opts.retryAfter)}},
+			}
+		}
+		acquired = append(acquired, sem)
+	}
+	return release, nil
+}
+
+func (c *ConcurrencyLimiter) semaphore(scope, key string) *concurrencySemaphore {
+	var sems *sync.Map
+	switch scope {
+	case ConcurrencyScopeEndpoint:
+		sems = &c.endpoints
+	case ConcurrencyScopeService:
+		sems = &c.services
+	default:
+		return c.global
+	}
+	if v, ok := sems.Load(key); ok {
+		return v.(*concurrencySemaphore)
+	}
+	v, _ := sems.LoadOrStore(key, &concurrencySemaphore{gauge: c.inflight.WithLabelValues(scope, key)})
+	return v.(*concurrencySemaphore)
+}
+
+func retryAfterHeader(wait time.Duration) string {
+	secs := int64((wait + time.Second - 1) / time.Second)
+	if secs < 1 {
+		secs = 1
+	}
+	return strconv.FormatInt(secs, 10)
+}
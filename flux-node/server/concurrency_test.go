@@ -0,0 +1,105 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+type nopGaugeVec struct{}
+
+func (nopGaugeVec) WithLabelValues(_ ...string) flux.Gauge {
+	return nopGauge{}
+}
+
+type nopGauge struct{}
+
+func (nopGauge) Set(_ float64) {}
+
+func (nopGauge) Inc() {}
+
+func (nopGauge) Dec() {}
+
+func (nopGauge) Add(_ float64) {}
+
+func newConcurrencyContext(pattern, service string) *flux.Context {
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("concurrency", httptest.NewRequest(http.MethodGet, "http://gateway"+pattern, nil), nil, nil),
+		&flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: pattern, Service: flux.TransporterService{Interface: service, Method: "get"}})
+	return ctx
+}
+
+func TestConcurrencySemaphore_TryAcquire(t *testing.T) {
+	assert := assert.New(t)
+	sem := &concurrencySemaphore{gauge: nopGauge{}}
+	assert.True(sem.tryAcquire(2))
+	assert.True(sem.tryAcquire(2))
+	assert.False(sem.tryAcquire(2))
+	sem.release()
+	assert.True(sem.tryAcquire(2))
+	// 不限制数量
+	assert.True(sem.tryAcquire(0))
+
+	// 并发占用不超过上限
+	sem = &concurrencySemaphore{gauge: nopGauge{}}
+	var acquired int64
+	var wg sync.WaitGroup
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if sem.tryAcquire(10) {
+				atomic.AddInt64(&acquired, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(int64(10), acquired)
+	assert.Equal(int64(10), atomic.LoadInt64(&sem.count))
+}
+
+func TestConcurrencyLimiter_Acquire(t *testing.T) {
+	assert := assert.New(t)
+	limiter := NewConcurrencyLimiter(nopGaugeVec{}, nopCounterVec{})
+	assert.NoError(limiter.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyConcurrencyGlobal:   3,
+		ConfigKeyConcurrencyEndpoint: 1,
+		ConfigKeyConcurrencyServices: map[string]interface{}{"users:get": 2},
+	})))
+	release, serr := limiter.Acquire(newConcurrencyContext("/users", "users"))
+	assert.Nil(serr)
+	// Endpoint层级达到上限；已占用的全局信号量被释放
+	_, serr = limiter.Acquire(newConcurrencyContext("/users", "users"))
+	if assert.NotNil(serr) {
+		assert.Equal(ErrorCodeConcurrencyLimited, serr.ErrorCode)
+		assert.Equal("CONCURRENCY:LIMITED:ENDPOINT", serr.Message)
+		assert.Equal("1", serr.Header.Get(flux.HeaderRetryAfter))
+	}
+	assert.Equal(int64(1), atomic.LoadInt64(&limiter.global.count))
+	// 服务层级达到上限
+	other, serr := limiter.Acquire(newConcurrencyContext("/users/profile", "users"))
+	assert.Nil(serr)
+	_, serr = limiter.Acquire(newConcurrencyContext("/users/orders", "users"))
+	if assert.NotNil(serr) {
+		assert.Equal("CONCURRENCY:LIMITED:SERVICE", serr.Message)
+	}
+	// 全局层级达到上限
+	third, serr := limiter.Acquire(newConcurrencyContext("/orders", "orders"))
+	assert.Nil(serr)
+	_, serr = limiter.Acquire(newConcurrencyContext("/items", "items"))
+	if assert.NotNil(serr) {
+		assert.Equal("CONCURRENCY:LIMITED:GLOBAL", serr.Message)
+	}
+	release()
+	other()
+	third()
+	assert.Equal(int64(0), atomic.LoadInt64(&limiter.global.count))
+	release, serr = limiter.Acquire(newConcurrencyContext("/users", "users"))
+	assert.Nil(serr)
+	release()
+}
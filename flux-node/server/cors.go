@@ -0,0 +1,362 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// 跨域资源共享（CORS）配置：allow_origins，allow_methods，allow_headers，expose_headers，allow_credentials，max_age，rules；
+	// 只对 features.cors_enable 为true的WebListener生效，支持热加载
+	ConfigNsCORS = "cors"
+)
+
+const (
+	ConfigKeyCORSAllowOrigins     = "allow_origins"
+	ConfigKeyCORSAllowMethods     = "allow_methods"
+	ConfigKeyCORSAllowHeaders     = "allow_headers"
+	ConfigKeyCORSExposeHeaders    = "expose_headers"
+	ConfigKeyCORSAllowCredentials = "allow_credentials"
+	ConfigKeyCORSMaxAge           = "max_age"
+	ConfigKeyCORSRules            = "rules"
+	// WebListener配置：是否开启跨域请求处理
+	ConfigKeyListenerCORSEnable = "features.cors_enable"
+)
+
+const (
+	// Endpoint属性：是否允许跨域请求；为false时不返回CORS响应Header
+	EndpointAttrTagCORS = "cors"
+	// Endpoint属性：允许的跨域来源，支持通配，例如：https://*.example.com
+	EndpointAttrTagCORSOrigins = "corsorigins"
+	// Endpoint属性：预检请求允许的请求方法
+	EndpointAttrTagCORSMethods = "corsmethods"
+	// Endpoint属性：预检请求允许的请求Header
+	EndpointAttrTagCORSHeaders = "corsheaders"
+	// Endpoint属性：允许浏览器读取的响应Header
+	EndpointAttrTagCORSExpose = "corsexpose"
+	// Endpoint属性：是否允许携带凭证（Cookie）
+	EndpointAttrTagCORSCredentials = "corscredentials"
+	// Endpoint属性：预检请求结果的缓存时间，单位：秒
+	EndpointAttrTagCORSMaxAge = "corsmaxage"
+)
+
+const (
+	corsVarOrigin = "cors.origin"
+)
+
+var (
+	corsEndpointAttrs = []string{
+		EndpointAttrTagCORS, EndpointAttrTagCORSOrigins, EndpointAttrTagCORSMethods, EndpointAttrTagCORSHeaders,
+		EndpointAttrTagCORSExpose, EndpointAttrTagCORSCredentials, EndpointAttrTagCORSMaxAge,
+	}
+)
+
+// CORSPolicy 跨域资源共享策略
+type CORSPolicy struct {
+	AllowOrigins     []string `json:"allowOrigins"`
+	AllowMethods     []string `json:"allowMethods"`
+	AllowHeaders     []string `json:"allowHeaders"`
+	ExposeHeaders    []string `json:"exposeHeaders"`
+	AllowCredentials bool     `json:"allowCredentials"`
+	MaxAge           int      `json:"maxAge"`
+}
+
+// AllowOrigin 判断是否允许跨域来源；来源规则支持 *（全部来源），及 https://*.example.com 形式的通配
+func (p CORSPolicy) AllowOrigin(origin string) bool {
+	for _, pattern := range p.AllowOrigins {
+		if matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowAnyOrigin 判断是否允许全部来源
+func (p CORSPolicy) AllowAnyOrigin() bool {
+	for _, pattern := range p.AllowOrigins {
+		if pattern == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// CORSEngine 按配置及Endpoint属性处理跨域请求：
+// 1. 全局策略：cors 配置的默认策略；
+// 2. 来源规则：rules 列表中首个匹配请求来源的规则，未配置的字段继承全局策略；
+// 3. Endpoint策略：Endpoint定义的 cors* 属性覆盖对应的字段；
+// 预检请求按 Access-Control-Request-Method 查找目标Endpoint；实际请求在路由到Endpoint后按Endpoint策略修正响应Header。
+type CORSEngine struct {
+	defaults CORSPolicy
+	rules    []corsRule
+	mu       sync.RWMutex
+}
+
+type corsRule struct {
+	origins []string
+	policy  CORSPolicy
+}
+
+// CORSLookupFunc 按请求方法，Host及路径查找预检请求的目标Endpoint
+type CORSLookupFunc func(webex flux.ServerWebContext, method string) (*flux.Endpoint, bool)
+
+func NewCORSEngine() *CORSEngine {
+	return &CORSEngine{}
+}
+
+func (c *CORSEngine) Init(config *flux.Configuration) error {
+	return c.OnReload(config)
+}
+
+func (c *CORSEngine) OnReload(config *flux.Configuration) error {
+	defaults := map[string]interface{}{
+		ConfigKeyCORSAllowOrigins:     []string{"*"},
+		ConfigKeyCORSAllowMethods:     []string{http.MethodGet, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodPost, http.MethodDelete},
+		ConfigKeyCORSAllowHeaders:     []string{},
+		ConfigKeyCORSExposeHeaders:    []string{},
+		ConfigKeyCORSAllowCredentials: false,
+		ConfigKeyCORSMaxAge:           0,
+	}
+	config.SetDefaults(defaults)
+	policy := loadCORSPolicy(config)
+	// 来源规则继承全局策略
+	inherits := map[string]interface{}{
+		ConfigKeyCORSAllowMethods:     policy.AllowMethods,
+		ConfigKeyCORSAllowHeaders:     policy.AllowHeaders,
+		ConfigKeyCORSExposeHeaders:    policy.ExposeHeaders,
+		ConfigKeyCORSAllowCredentials: policy.AllowCredentials,
+		ConfigKeyCORSMaxAge:           policy.MaxAge,
+	}
+	rules := make([]corsRule, 0, 4)
+	for i, rc := range config.GetConfigurationSlice(ConfigKeyCORSRules) {
+		rc.SetDefaults(inherits)
+		rule := corsRule{origins: rc.GetStringSlice(ConfigKeyCORSAllowOrigins), policy: loadCORSPolicy(rc)}
+		if len(rule.origins) == 0 {
+			logger.Warnw("SERVER:CORS:RULE/NO_ORIGINS, ignored", "rule-index", i)
+			continue
+		}
+		if rule.policy.AllowCredentials && rule.policy.AllowAnyOrigin() {
+			logger.Warnw("SERVER:CORS:RULE/CREDENTIALS_ANY_ORIGIN, credentials disabled", "rule-index", i)
+		}
+		rules = append(rules, rule)
+	}
+	if policy.AllowCredentials && policy.AllowAnyOrigin() {
+		logger.Warnw("SERVER:CORS:CREDENTIALS_ANY_ORIGIN, credentials disabled", "allow-origins", policy.AllowOrigins)
+	}
+	c.mu.Lock()
+	c.defaults, c.rules = policy, rules
+	c.mu.Unlock()
+	logger.Infow("SERVER:CORS:LOAD", "allow-origins", policy.AllowOrigins, "allow-credentials", policy.AllowCredentials,
+		"max-age", policy.MaxAge, "rules", len(rules))
+	return nil
+}
+
+// Interceptor 返回处理跨域请求的WebInterceptor；预检请求直接响应，不路由到Endpoint
+func (c *CORSEngine) Interceptor(lookup CORSLookupFunc) flux.WebInterceptor {
+	return func(next flux.WebHandler) flux.WebHandler {
+		return func(webex flux.ServerWebContext) error {
+			origin := webex.HeaderVar(flux.HeaderOrigin)
+			if origin == "" {
+				return next(webex)
+			}
+			method := webex.HeaderVar(flux.HeaderAccessControlRequestMethod)
+			if webex.Method() == http.MethodOptions && method != "" {
+				endpoint, _ := lookup(webex, strings.ToUpper(method))
+				if policy, ok := c.Resolve(origin, endpoint); ok {
+					policy.writePreflight(webex.ResponseWriter().Header(), origin, webex.HeaderVar(flux.HeaderAccessControlRequestHeaders))
+				} else {
+					webex.ResponseWriter().Header().Add(flux.HeaderVary, flux.HeaderOrigin)
+				}
+				webex.ResponseWriter().WriteHeader(http.StatusNoContent)
+				return nil
+			}
+			webex.SetVariable(corsVarOrigin, origin)
+			if policy, ok := c.Resolve(origin, nil); ok {
+				policy.writeActual(webex.ResponseWriter().Header(), origin)
+			} else {
+				webex.ResponseWriter().Header().Add(flux.HeaderVary, flux.HeaderOrigin)
+			}
+			return next(webex)
+		}
+	}
+}
+
+// ApplyEndpoint 实际请求路由到Endpoint后，按Endpoint定义的CORS属性修正响应Header；未经过CORS拦截器的请求不处理
+func (c *CORSEngine) ApplyEndpoint(webex flux.ServerWebContext, endpoint *flux.Endpoint) {
+	v, ok := webex.GetVariable(corsVarOrigin)
+	if !ok || !hasCORSAttrs(endpoint) {
+		return
+	}
+	origin := v.(string)
+	header := webex.ResponseWriter().Header()
+	header.Del(flux.HeaderAccessControlAllowOrigin)
+	header.Del(flux.HeaderAccessControlAllowCredentials)
+	header.Del(flux.HeaderAccessControlExposeHeaders)
+	if policy, ok := c.Resolve(origin, endpoint); ok {
+		policy.writeActual(header, origin)
+	}
+}
+
+// Resolve 按请求来源及Endpoint解析跨域策略；返回false表示不允许此来源的跨域请求
+func (c *CORSEngine) Resolve(origin string, endpoint *flux.Endpoint) (CORSPolicy, bool) {
+	if nil != endpoint {
+		if attr, ok := endpoint.GetAttrEx(EndpointAttrTagCORS); ok && !attr.GetBool() {
+			return CORSPolicy{}, false
+		}
+	}
+	c.mu.RLock()
+	policy := c.defaults
+	allowed := policy.AllowOrigin(origin)
+	for _, rule := range c.rules {
+		if (CORSPolicy{AllowOrigins: rule.origins}).AllowOrigin(origin) {
+			policy, allowed = rule.policy, true
+			break
+		}
+	}
+	c.mu.RUnlock()
+	if nil == endpoint {
+		return policy, allowed
+	}
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagCORSOrigins); ok {
+		policy.AllowOrigins = attr.GetStringSlice()
+		allowed = policy.AllowOrigin(origin)
+	}
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagCORSMethods); ok {
+		policy.AllowMethods = attr.GetStringSlice()
+	}
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagCORSHeaders); ok {
+		policy.AllowHeaders = attr.GetStringSlice()
+	}
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagCORSExpose); ok {
+		policy.ExposeHeaders = attr.GetStringSlice()
+	}
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagCORSCredentials); ok {
+		policy.AllowCredentials = attr.GetBool()
+	}
+	if attr, ok := endpoint.GetAttrEx(EndpointAttrTagCORSMaxAge); ok {
+		policy.MaxAge = attr.GetInt()
+	}
+	return policy, allowed
+}
+
+func (p CORSPolicy) writeActual(header http.Header, origin string) {
+	p.writeOrigin(header, origin)
+	if len(p.ExposeHeaders) > 0 {
+		header.Set(flux.HeaderAccessControlExposeHeaders, strings.Join(p.ExposeHeaders, ","))
+	}
+}
+
+func (p CORSPolicy) writePreflight(header http.Header, origin string, requestHeaders string) {
+	header.Add(flux.HeaderVary, flux.HeaderAccessControlRequestMethod)
+	header.Add(flux.HeaderVary, flux.HeaderAccessControlRequestHeaders)
+	p.writeOrigin(header, origin)
+	header.Set(flux.HeaderAccessControlAllowMethods, strings.Join(p.AllowMethods, ","))
+	if len(p.AllowHeaders) > 0 {
+		header.Set(flux.HeaderAccessControlAllowHeaders, strings.Join(p.AllowHeaders, ","))
+	} else if requestHeaders != "" {
+		header.Set(flux.HeaderAccessControlAllowHeaders, requestHeaders)
+	}
+	if p.MaxAge > 0 {
+		header.Set(flux.HeaderAccessControlMaxAge, strconv.Itoa(p.MaxAge))
+	}
+}
+
+func (p CORSPolicy) writeOrigin(header http.Header, origin string) {
+	if !varyContains(header, flux.HeaderOrigin) {
+		header.Add(flux.HeaderVary, flux.HeaderOrigin)
+	}
+	// 允许全部来源时不允许携带凭证，避免任意站点读取携带Cookie的响应；携带凭证须配置明确的来源列表
+	if p.AllowAnyOrigin() {
+		header.Set(flux.HeaderAccessControlAllowOrigin, "*")
+		return
+	}
+	header.Set(flux.HeaderAccessControlAllowOrigin, origin)
+	if p.AllowCredentials {
+		header.Set(flux.HeaderAccessControlAllowCredentials, "true")
+	}
+}
+
+func loadCORSPolicy(config *flux.Configuration) CORSPolicy {
+	return CORSPolicy{
+		AllowOrigins:     config.GetStringSlice(ConfigKeyCORSAllowOrigins),
+		AllowMethods:     config.GetStringSlice(ConfigKeyCORSAllowMethods),
+		AllowHeaders:     config.GetStringSlice(ConfigKeyCORSAllowHeaders),
+		ExposeHeaders:    config.GetStringSlice(ConfigKeyCORSExposeHeaders),
+		AllowCredentials: config.GetBool(ConfigKeyCORSAllowCredentials),
+		MaxAge:           config.GetInt(ConfigKeyCORSMaxAge),
+	}
+}
+
+func hasCORSAttrs(endpoint *flux.Endpoint) bool {
+	for _, name := range corsEndpointAttrs {
+		if _, ok := endpoint.GetAttrEx(name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func varyContains(header http.Header, name string) bool {
+	for _, value := range header.Values(flux.HeaderVary) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchOrigin 匹配跨域来源；通配符 * 可出现在来源的任意位置，例如：https://*.example.com，http://localhost:*
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" {
+		return true
+	}
+	idx := strings.IndexByte(pattern, '*')
+	if idx < 0 {
+		return strings.EqualFold(pattern, origin)
+	}
+	prefix, suffix := strings.ToLower(pattern[:idx]), strings.ToLower(pattern[idx+1:])
+	origin = strings.ToLower(origin)
+	return len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix)
+}
+
+// initCORS 加载跨域配置，并注册到开启 features.cors_enable 的WebListener
+func (s *BootstrapServer) initCORS() error {
+	if err := s.cors.Init(flux.NewConfigurationOfNS(ConfigNsCORS)); nil != err {
+		return err
+	}
+	s.dispatcher.reloads = append(s.dispatcher.reloads, reloadTarget{kind: ComponentKindServer, id: ConfigNsCORS, ns: ConfigNsCORS, ref: s.cors})
+	for id, webListener := range s.listener {
+		if LoadWebListenerConfig(id).GetBool(ConfigKeyListenerCORSEnable) {
+			logger.Infow("SERVER:CORS:ENABLED", "listener-id", id)
+			webListener.AddInterceptor(s.cors.Interceptor(s.corsLookupFunc(id)))
+		}
+	}
+	return nil
+}
+
+// corsLookupFunc 按预检请求的目标方法及路径，在WebListener的路由表中匹配Endpoint
+func (s *BootstrapServer) corsLookupFunc(listenerId string) CORSLookupFunc {
+	return func(webex flux.ServerWebContext, method string) (*flux.Endpoint, bool) {
+		webListener, ok := s.WebListenerById(listenerId)
+		if !ok {
+			return nil, false
+		}
+		request := webex.Request().WithContext(webex.Context())
+		request.Method = method
+		_, mve, routed, ok := s.matchRoute(webListener, request, webex.RequestId())
+		if !ok {
+			return nil, false
+		}
+		endpoint, found := mve.Select(routed, s.versionFunc(routed))
+		if !found {
+			endpoint = mve.Random()
+		}
+		return &endpoint, true
+	}
+}
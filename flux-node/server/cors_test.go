@@ -0,0 +1,92 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestMatchOrigin(t *testing.T) {
+	cases := []struct {
+		pattern  string
+		origin   string
+		expected bool
+	}{
+		{pattern: "*", origin: "https://a.com", expected: true},
+		{pattern: "https://a.com", origin: "https://a.com", expected: true},
+		{pattern: "https://a.com", origin: "HTTPS://A.COM", expected: true},
+		{pattern: "https://a.com", origin: "https://a.com.evil.com", expected: false},
+		{pattern: "https://*.example.com", origin: "https://api.example.com", expected: true},
+		{pattern: "https://*.example.com", origin: "https://a.b.example.com", expected: true},
+		{pattern: "https://*.example.com", origin: "https://.example.com", expected: false},
+		{pattern: "https://*.example.com", origin: "https://example.com", expected: false},
+		{pattern: "https://*.example.com", origin: "https://evilexample.com", expected: false},
+		{pattern: "https://*.example.com", origin: "http://api.example.com", expected: false},
+		{pattern: "http://localhost:*", origin: "http://localhost:8080", expected: true},
+		{pattern: "http://localhost:*", origin: "http://localhost", expected: false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, matchOrigin(tc.pattern, tc.origin), tc.pattern+" <- "+tc.origin)
+	}
+}
+
+func newTestCORSEngine() *CORSEngine {
+	return &CORSEngine{
+		defaults: CORSPolicy{AllowOrigins: []string{"*"}, AllowMethods: []string{http.MethodGet}},
+		rules: []corsRule{
+			{
+				origins: []string{"https://*.example.com"},
+				policy: CORSPolicy{
+					AllowOrigins: []string{"https://*.example.com"}, AllowMethods: []string{http.MethodGet, http.MethodPost},
+					AllowCredentials: true, MaxAge: 600,
+				},
+			},
+		},
+	}
+}
+
+func TestCORSEngine_Resolve(t *testing.T) {
+	assert := assert.New(t)
+	engine := newTestCORSEngine()
+	// 全局策略
+	policy, ok := engine.Resolve("https://other.com", nil)
+	assert.True(ok)
+	assert.False(policy.AllowCredentials)
+	// 来源规则
+	policy, ok = engine.Resolve("https://app.example.com", nil)
+	assert.True(ok)
+	assert.True(policy.AllowCredentials)
+	assert.Equal(600, policy.MaxAge)
+	// Endpoint属性覆盖
+	endpoint := &flux.Endpoint{EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+		{Name: EndpointAttrTagCORSOrigins, Value: []string{"https://admin.example.com"}},
+		{Name: EndpointAttrTagCORSMaxAge, Value: 60},
+	}}}
+	_, ok = engine.Resolve("https://app.example.com", endpoint)
+	assert.False(ok)
+	policy, ok = engine.Resolve("https://admin.example.com", endpoint)
+	assert.True(ok)
+	assert.Equal(60, policy.MaxAge)
+	// Endpoint关闭跨域
+	disabled := &flux.Endpoint{EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: []flux.Attribute{
+		{Name: EndpointAttrTagCORS, Value: false},
+	}}}
+	_, ok = engine.Resolve("https://app.example.com", disabled)
+	assert.False(ok)
+}
+
+func TestCORSPolicy_WriteOrigin(t *testing.T) {
+	assert := assert.New(t)
+	// 明确的来源列表允许携带凭证
+	header := http.Header{}
+	CORSPolicy{AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true}.writeOrigin(header, "https://app.example.com")
+	assert.Equal("https://app.example.com", header.Get(flux.HeaderAccessControlAllowOrigin))
+	assert.Equal("true", header.Get(flux.HeaderAccessControlAllowCredentials))
+	assert.Equal(flux.HeaderOrigin, header.Get(flux.HeaderVary))
+	// 允许全部来源时不允许携带凭证
+	header = http.Header{}
+	CORSPolicy{AllowOrigins: []string{"https://a.com", "*"}, AllowCredentials: true}.writeOrigin(header, "https://evil.com")
+	assert.Equal("*", header.Get(flux.HeaderAccessControlAllowOrigin))
+	assert.Empty(header.Get(flux.HeaderAccessControlAllowCredentials))
+}
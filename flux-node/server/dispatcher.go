@@ -6,21 +6,49 @@ import (
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/ext"
 	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/tracing"
 	"github.com/prometheus/client_golang/prometheus"
 	"reflect"
-	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Dispatcher struct {
-	metrics *Metrics
-	hooks   []flux.PrepareHookFunc
+	metrics   *Metrics
+	guards    *FilterGuards
+	budgets   *FilterBudgets
+	shadow    *ShadowTraffic
+	timeout   *AdaptiveTimeouts
+	timings   *FilterTimings
+	labels    *EndpointLabels
+	filters   *filterSwitches
+	tracer    *tracing.Tracer
+	lifecycle *Lifecycle
+	hooks     []flux.PrepareHookFunc
+	reloads   []reloadTarget
+	// 重新加载配置后，旧实例等待处理中请求完成的时间
+	reloadGrace time.Duration
+	// Filter执行之后，调用后端服务之前的请求检查
+	verifiers []flux.FilterInvoker
 }
 
 func NewDispatcher() *Dispatcher {
+	metrics := NewMetrics()
+	labels := NewEndpointLabels()
 	return &Dispatcher{
-		metrics: NewMetrics(),
-		hooks:   make([]flux.PrepareHookFunc, 0, 4),
+		metrics:     metrics,
+		guards:      NewFilterGuards(metrics.FilterPanic),
+		budgets:     NewFilterBudgets(metrics.FilterTimeout),
+		shadow:      NewShadowTraffic(metrics.ShadowAccess, metrics.RouteDuration, labels),
+		tracer:      tracing.NewTracer(),
+		timeout:     NewAdaptiveTimeouts(),
+		timings:     NewFilterTimings(metrics.FilterDuration),
+		labels:      labels,
+		filters:     newFilterSwitches(),
+		lifecycle:   NewLifecycle(),
+		hooks:       make([]flux.PrepareHookFunc, 0, 4),
+		reloadGrace: time.Second * 30,
 	}
 }
 
@@ -36,6 +64,42 @@ func (r *Dispatcher) Prepare() error {
 
 func (r *Dispatcher) Initial() error {
 	logger.Info("Dispatcher initialing")
+	// Metrics
+	if err := r.metrics.Init(flux.NewConfigurationOfNS(ConfigNsMetrics)); nil != err {
+		return err
+	}
+	// Filter guard
+	if err := r.guards.Init(flux.NewConfigurationOfNS(ConfigNsFilterGuard)); nil != err {
+		return err
+	}
+	// Filter budget
+	if err := r.budgets.Init(flux.NewConfigurationOfNS(ConfigNsFilterBudget)); nil != err {
+		return err
+	}
+	// Shadow traffic
+	if err := r.shadow.Init(flux.NewConfigurationOfNS(ConfigNsShadowTraffic)); nil != err {
+		return err
+	}
+	// Adaptive timeout
+	if err := r.timeout.Init(flux.NewConfigurationOfNS(ConfigNsAdaptiveTimeout)); nil != err {
+		return err
+	}
+	// Lifecycle
+	if err := r.lifecycle.Init(flux.NewConfigurationOfNS(ConfigNsLifecycle)); nil != err {
+		return err
+	}
+	// Endpoint metric labels
+	if err := r.labels.Init(flux.NewConfigurationOfNS(ConfigNsMetricLabels)); nil != err {
+		return err
+	}
+	// Filter timing
+	if err := r.timings.Init(flux.NewConfigurationOfNS(ConfigNsFilterTiming)); nil != err {
+		return err
+	}
+	// Tracing
+	if err := r.AddInitHook(r.tracer, flux.NewConfigurationOfNS(ConfigNsTracing)); nil != err {
+		return err
+	}
 	// Transporter
 	for proto, transporter := range ext.Transporters() {
 		ns := flux.NamespaceTransporters + "." + proto
@@ -43,12 +107,15 @@ func (r *Dispatcher) Initial() error {
 		if err := r.AddInitHook(transporter, flux.NewConfigurationOfNS(ns)); nil != err {
 			return err
 		}
+		factory, _ := ext.TransporterFactoryBy(proto)
+		r.reloads = append(r.reloads, reloadTarget{kind: ComponentKindTransporter, id: proto, ns: ns, ref: transporter, factory: factory})
 	}
 	// 手动注册的单实例Filters
 	for _, filter := range append(ext.GlobalFilters(), ext.SelectiveFilters()...) {
 		ns := filter.FilterId()
 		logger.Infow("Load static-filter", "type", reflect.TypeOf(filter), "config-ns", ns)
 		config := flux.NewConfigurationOfNS(ns)
+		r.filters.register(filter.FilterId(), ns, IsDisabled(config))
 		if IsDisabled(config) {
 			logger.Infow("Set static-filter DISABLED", "filter-id", filter.FilterId())
 			continue
@@ -56,6 +123,7 @@ func (r *Dispatcher) Initial() error {
 		if err := r.AddInitHook(filter, config); nil != err {
 			return err
 		}
+		r.reloads = append(r.reloads, reloadTarget{kind: ComponentKindFilter, id: filter.FilterId(), ns: ns, ref: filter})
 	}
 	// 加载和注册，动态多实例Filter
 	dynFilters, err := dynamicFilters()
@@ -72,7 +140,9 @@ func (r *Dispatcher) Initial() error {
 		if err := r.AddInitHook(filter, item.Config); nil != err {
 			return err
 		}
+		r.reloads = append(r.reloads, reloadTarget{kind: ComponentKindFilter, id: item.Id, ns: "filter." + item.Id, ref: filter, factory: item.Factory})
 		if filter, ok := filter.(flux.Filter); ok {
+			r.filters.register(filter.FilterId(), "filter."+item.Id, false)
 			ext.AddSelectiveFilter(filter)
 		}
 	}
@@ -81,60 +151,69 @@ func (r *Dispatcher) Initial() error {
 
 func (r *Dispatcher) AddInitHook(ref interface{}, config *flux.Configuration) error {
 	if init, ok := ref.(flux.Initializer); ok {
-		if err := init.Init(config); nil != err {
+		err := init.Init(config)
+		ext.MarkInitialized(ref, err)
+		if nil != err {
 			return err
 		}
 	}
 	ext.AddHookFunc(ref)
+	if indicator, ok := ref.(flux.HealthIndicator); ok {
+		ext.AddHealthIndicator(indicator)
+	}
 	return nil
 }
 
 func (r *Dispatcher) Startup() error {
-	for _, startup := range sortedStartup(ext.StartupHooks()) {
-		if err := startup.Startup(); nil != err {
-			return err
-		}
-	}
-	return nil
+	return r.lifecycle.Startup(ext.StartupHooks())
 }
 
 func (r *Dispatcher) Shutdown(ctx context.Context) error {
-	for _, shutdown := range sortedShutdown(ext.ShutdownHooks()) {
-		if err := shutdown.Shutdown(ctx); nil != err {
-			return err
-		}
-	}
-	return nil
+	return r.lifecycle.Shutdown(ctx, ext.ShutdownHooks())
 }
 
 func (r *Dispatcher) Route(ctx *flux.Context) *flux.ServeError {
+	pattern, version := r.labels.Labels(ctx.Endpoint())
 	// 统计异常
 	doMetricEndpointFunc := func(err *flux.ServeError) *flux.ServeError {
-		// Access Counter: ProtoName, Interface, Method
+		// Access Counter: ProtoName, Interface, Method, HttpPattern, Version
 		service := ctx.Transporter()
 		proto, uri, method := service.RpcProto(), service.Interface, service.Method
-		r.metrics.EndpointAccess.WithLabelValues(proto, uri, method).Inc()
+		r.metrics.EndpointAccess.WithLabelValues(proto, uri, method, pattern, version).Inc()
 		if nil != err {
-			// Error Counter: ProtoName, Interface, Method, ErrorCode
-			r.metrics.EndpointError.WithLabelValues(proto, uri, method, err.GetErrorCode()).Inc()
+			// Error Counter: ProtoName, Interface, Method, ErrorCode, HttpPattern, Version
+			r.metrics.EndpointError.WithLabelValues(proto, uri, method, err.GetErrorCode(), pattern, version).Inc()
 		}
 		return err
 	}
+	// 请求超时：超时后取消请求的Context，上游调用按剩余时间传递截止时间
+	if timeout, ok := endpointTimeout(ctx.Endpoint()); ok {
+		cancel := ctx.WithTimeout(timeout)
+		defer cancel()
+	}
 	// Metric: Route
 	defer func() {
 		ctx.AddMetric("route", time.Since(ctx.StartAt()))
 	}()
-	// Select filters
-	selective := make([]flux.Filter, 0, 16)
-	for _, selector := range ext.FilterSelectors() {
-		if selector.Activate(ctx) {
-			selective = append(selective, selector.DoSelect(ctx)...)
-		}
+	// 客户端证书，请求媒体类型及Body长度检查，在Filter和参数解析之前拒绝不支持的请求
+	if serr := verifyClientCert(ctx); nil != serr {
+		return doMetricEndpointFunc(serr)
 	}
+	if serr := verifyContentType(ctx); nil != serr {
+		return doMetricEndpointFunc(serr)
+	}
+	if serr := verifyBodyLimit(ctx); nil != serr {
+		return doMetricEndpointFunc(serr)
+	}
+	// Select filters
+	filters := r.selectFilters(ctx)
 	ctx.AddMetric("selector", time.Since(ctx.StartAt()))
 	transport := func(ctx *flux.Context) *flux.ServeError {
 		select {
 		case <-ctx.Context().Done():
+			if ctx.Context().Err() == context.DeadlineExceeded {
+				return flux.NewTimeoutServeError(ctx.Context().Err())
+			}
 			return &flux.ServeError{
 				StatusCode: flux.StatusOK,
 				ErrorCode:  "ROUTE:TRANSPORT/B:CANCELED",
@@ -143,6 +222,11 @@ func (r *Dispatcher) Route(ctx *flux.Context) *flux.ServeError {
 		default:
 			break
 		}
+		for _, verify := range r.verifiers {
+			if serr := verify(ctx); nil != serr {
+				return serr
+			}
+		}
 		defer func() {
 			ctx.AddMetric("transporter", time.Since(ctx.StartAt()))
 		}()
@@ -158,52 +242,119 @@ func (r *Dispatcher) Route(ctx *flux.Context) *flux.ServeError {
 			}
 		}
 		// Transporter exchange
-		timer := prometheus.NewTimer(r.metrics.RouteDuration.WithLabelValues("Transporter", proto))
+		span := r.tracer.StartSpan(ctx, "transporter:"+proto, tracing.SpanKindClient)
+		span.SetAttribute("rpc.system", proto)
+		span.SetAttribute("rpc.service", ctx.Transporter().Interface)
+		span.SetAttribute("rpc.method", ctx.Transporter().Method)
+		r.tracer.Inject(ctx)
+		service := ctx.Transporter()
+		r.metrics.RequestSize.WithLabelValues(proto, service.Interface, service.Method).Observe(float64(requestBodySize(ctx)))
+		recorder := &responseRecorder{ResponseWriter: ctx.ResponseWriter()}
+		ctx.SetResponseWriter(recorder)
+		r.shadow.Start(ctx)
+		// 自适应超时：按Service最近调用耗时替换Service定义的固定超时
+		if timeout, ok := r.timeout.Timeout(&service); ok {
+			restore := r.timeout.Apply(ctx, timeout)
+			defer restore()
+		}
+		timer := prometheus.NewTimer(r.metrics.RouteDuration.WithLabelValues("Transporter", proto, pattern, version))
 		transporter.Transport(ctx)
-		timer.ObserveDuration()
+		elapsed := timer.ObserveDuration()
+		// 只记录成功响应与上游超时的耗时，错误与快速失败的请求不作为样本；
+		// 超时的调用以实际耗时作为样本，使过短的超时时间可以延长
+		if status := recorder.status; (status >= flux.StatusOK && status < flux.StatusBadRequest) || status == flux.StatusTimeout {
+			r.timeout.Observe(&service, elapsed)
+		}
+		ctx.SetResponseWriter(recorder.ResponseWriter)
+		r.metrics.ResponseSize.WithLabelValues(proto, service.Interface, service.Method).Observe(float64(recorder.bytes))
+		span.End()
 		return nil
 	}
 	// Walk filters
-	filters := append(ext.GlobalFilters(), selective...)
-	return doMetricEndpointFunc(r.walk(transport, filters)(ctx))
+	timings := r.timings.newRecorder(len(filters))
+	serr := r.walk(transport, filters, timings)(ctx)
+	r.timings.report(ctx, timings)
+	return doMetricEndpointFunc(serr)
 }
 
-func (r *Dispatcher) walk(next flux.FilterInvoker, filters []flux.Filter) flux.FilterInvoker {
-	for i := len(filters) - 1; i >= 0; i-- {
-		next = filters[i].DoFilter(next)
+// selectFilters 返回请求需要执行的Filter列表：全局Filter，以及由FilterSelector选择的Filter；
+// 不包含已停用的Filter，以及请求所属租户停用的Filter
+func (r *Dispatcher) selectFilters(ctx *flux.Context) []flux.Filter {
+	selective := make([]flux.Filter, 0, 16)
+	for _, selector := range ext.FilterSelectors() {
+		if selector.Activate(ctx) {
+			selective = append(selective, selector.DoSelect(ctx)...)
+		}
 	}
-	return next
+	filters := r.filters.active(append(ext.GlobalFilters(), selective...))
+	if tenant, ok := flux.TenantOf(ctx); ok && len(tenant.DisabledFilters) > 0 {
+		out := filters[:0]
+		for _, filter := range filters {
+			if !tenant.IsFilterDisabled(filter.FilterId()) {
+				out = append(out, filter)
+			}
+		}
+		filters = out
+	}
+	return filters
 }
 
-func sortedStartup(items []flux.Startuper) []flux.Startuper {
-	out := make(StartupArray, len(items))
-	for i, v := range items {
-		out[i] = v
-	}
-	sort.Sort(out)
-	return out
+// Tracer 返回请求链路追踪对象
+func (r *Dispatcher) Tracer() *tracing.Tracer {
+	return r.tracer
 }
 
-func sortedShutdown(items []flux.Shutdowner) []flux.Shutdowner {
-	out := make(ShutdownArray, len(items))
-	for i, v := range items {
-		out[i] = v
-	}
-	sort.Sort(out)
-	return out
+// AdaptiveTimeouts 返回自适应上游超时管理对象
+func (r *Dispatcher) AdaptiveTimeouts() *AdaptiveTimeouts {
+	return r.timeout
 }
 
-type StartupArray []flux.Startuper
+// FilterGuards 返回Filter异常隔离管理对象
+func (r *Dispatcher) FilterGuards() *FilterGuards {
+	return r.guards
+}
 
-func (s StartupArray) Len() int           { return len(s) }
-func (s StartupArray) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
-func (s StartupArray) Less(i, j int) bool { return orderOf(s[i]) < orderOf(s[j]) }
+func (r *Dispatcher) walk(next flux.FilterInvoker, filters []flux.Filter, timings *filterTimingRecorder) flux.FilterInvoker {
+	for i := len(filters) - 1; i >= 0; i-- {
+		id := filters[i].FilterId()
+		invoker := r.guards.Wrap(r.budgets.Decorate(filters[i]), timings.downstream(i, next))
+		next = r.traced(id, timings.filter(i, id, invoker))
+	}
+	return next
+}
 
-type ShutdownArray []flux.Shutdowner
+// traced 为Filter的执行创建链路追踪Span；Span覆盖此Filter及其后续调用链
+func (r *Dispatcher) traced(filterId string, next flux.FilterInvoker) flux.FilterInvoker {
+	if !r.tracer.Enabled() {
+		return next
+	}
+	return func(ctx *flux.Context) *flux.ServeError {
+		span := r.tracer.StartSpan(ctx, "filter:"+filterId, tracing.SpanKindInternal)
+		defer span.End()
+		serr := next(ctx)
+		if nil != serr {
+			span.SetError(serr.Message)
+		}
+		return serr
+	}
+}
 
-func (s ShutdownArray) Len() int           { return len(s) }
-func (s ShutdownArray) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
-func (s ShutdownArray) Less(i, j int) bool { return orderOf(s[i]) < orderOf(s[j]) }
+// endpointTimeout 读取Endpoint定义的请求超时时间；纯数字为毫秒
+func endpointTimeout(endpoint *flux.Endpoint) (time.Duration, bool) {
+	value := strings.TrimSpace(endpoint.GetAttr(flux.EndpointAttrTagTimeout).GetString())
+	if value == "" {
+		return 0, false
+	}
+	if ms, err := strconv.Atoi(value); nil == err {
+		return time.Duration(ms) * time.Millisecond, ms > 0
+	}
+	timeout, err := time.ParseDuration(value)
+	if nil != err {
+		logger.Warnw("SERVER:ROUTE:TIMEOUT/ILLEGAL", "pattern", endpoint.HttpPattern, "timeout", value)
+		return 0, false
+	}
+	return timeout, timeout > 0
+}
 
 func orderOf(v interface{}) int {
 	if v, ok := v.(flux.Orderer); ok {
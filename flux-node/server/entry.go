@@ -6,6 +6,8 @@ import (
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/ext"
 	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/remoting/configcenter"
+	"github.com/bytepowered/flux/flux-node/remoting/secret"
 	"github.com/spf13/cast"
 	"github.com/spf13/viper"
 	"go.uber.org/zap"
@@ -18,6 +20,13 @@ const (
 	EnvKeyDeployEnv = "DEPLOY_ENV"
 )
 
+const (
+	// 远程配置中心：type（nacos，apollo），以及与type同名的配置中心参数
+	ConfigNsConfigCenter = "config_center"
+	// 密钥提供者：type（vault），以及与type同名的密钥提供者参数；配置值以 ${$secret:path#field} 引用密钥
+	ConfigNsSecrets = "secrets"
+)
+
 func InitLogger() {
 	config, err := logger.LoadConfig("")
 	if nil != err {
@@ -50,10 +59,55 @@ func InitAppConfig(envKey string) {
 	if err := viper.ReadInConfig(); nil != err {
 		logger.Panicw("Fatal config error", "path", file, "error", err)
 	}
+	flux.SetDynamicValueErrorHandler(func(key string, err error) {
+		logger.Warnw("CONFIG:PLACEHOLDER/ERROR", "key", key, "error", err)
+	})
+}
+
+// InitRemoteConfig 从远程配置中心加载配置，合并到全局配置；未配置远程配置中心时返回nil
+func InitRemoteConfig() configcenter.Source {
+	config := flux.NewConfigurationOfNS(ConfigNsConfigCenter)
+	stype := config.GetString("type")
+	if stype == "" || IsDisabled(config) {
+		return nil
+	}
+	source, err := configcenter.NewSource(stype, config.Sub(stype))
+	if nil != err {
+		logger.Panicw("Fatal config center error", "type", stype, "error", err)
+	}
+	if err := source.Load(); nil != err {
+		logger.Panicw("Fatal config center load error", "type", stype, "error", err)
+	}
+	logger.Infow("Using config center", "type", stype)
+	return source
+}
+
+// InitSecretProvider 创建并启动密钥提供者，设置为配置占位符 ${$secret:path#field} 的密钥来源；未配置时返回nil
+func InitSecretProvider() secret.Provider {
+	config := flux.NewConfigurationOfNS(ConfigNsSecrets)
+	ptype := config.GetString("type")
+	if ptype == "" || IsDisabled(config) {
+		return nil
+	}
+	provider, err := secret.NewProvider(ptype, config.Sub(ptype))
+	if nil != err {
+		logger.Panicw("Fatal secret provider error", "type", ptype, "error", err)
+	}
+	if err := provider.Start(); nil != err {
+		logger.Panicw("Fatal secret provider start error", "type", ptype, "error", err)
+	}
+	flux.SetSecretProvider(provider)
+	logger.Infow("Using secret provider", "type", ptype)
+	return provider
 }
 
 func Bootstrap(build flux.Build) {
 	InitAppConfig(EnvKeyDeployEnv)
+	// 密钥提供者先于远程配置中心初始化，配置中心的访问凭证可引用密钥
+	if provider := InitSecretProvider(); nil != provider {
+		defer provider.Close()
+	}
+	source := InitRemoteConfig()
 	server := NewDefaultBootstrapServer()
 	if err := server.Prepare(); nil != err {
 		logger.Panic("BootstrapServer prepare:", err)
@@ -61,12 +115,21 @@ func Bootstrap(build flux.Build) {
 	if err := server.Initial(); nil != err {
 		logger.Panic("BootstrapServer init:", err)
 	}
+	// 远程配置变更时，重新加载组件配置
+	if nil != source {
+		if err := source.Watch(func() { server.ReloadConfig(ConfigNsConfigCenter) }); nil != err {
+			logger.Errorw("Config center watch", "error", err)
+		}
+		defer source.Close()
+	}
+	quit := make(chan os.Signal, 1)
 	go func() {
 		if err := server.Startup(build); nil != err && !errors.Is(err, http.ErrServerClosed) {
 			logger.Error(err)
+			// 启动失败或WebListener异常退出时，停止全部服务
+			quit <- os.Interrupt
 		}
 	}()
-	quit := make(chan os.Signal, 1)
 	server.OnSignalShutdown(quit, 10*time.Second)
 }
 
@@ -0,0 +1,177 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// RouteExplainRequest 路由解释的模拟请求
+type RouteExplainRequest struct {
+	Method     string            `json:"method"`
+	Path       string            `json:"path"`
+	Host       string            `json:"host"`
+	Headers    map[string]string `json:"headers"`
+	Version    string            `json:"version"`
+	ListenerId string            `json:"listenerId"`
+}
+
+// RouteExplain 模拟请求的路由结果；不执行Filter，不调用后端服务
+type RouteExplain struct {
+	Matched     bool              `json:"matched"`
+	RouteKey    string            `json:"routeKey,omitempty"`
+	PathParams  map[string]string `json:"pathParams,omitempty"`
+	Versions    []string          `json:"versions,omitempty"`
+	Version     string            `json:"version"`
+	Selected    bool              `json:"selected"`
+	EndpointId  string            `json:"endpointId,omitempty"`
+	Disabled    bool              `json:"disabled"`
+	Timeout     string            `json:"timeout,omitempty"`
+	Filters     []string          `json:"filters"`
+	Transporter *ExplainService   `json:"transporter,omitempty"`
+	Message     string            `json:"message,omitempty"`
+}
+
+// ExplainService 将要调用的后端服务
+type ExplainService struct {
+	Proto           string `json:"proto"`
+	Type            string `json:"type,omitempty"`
+	Available       bool   `json:"available"`
+	ServiceId       string `json:"serviceId"`
+	Interface       string `json:"interface"`
+	Method          string `json:"method"`
+	RemoteHost      string `json:"remoteHost,omitempty"`
+	RpcTimeout      string `json:"rpcTimeout,omitempty"`
+	AdaptiveTimeout string `json:"adaptiveTimeout,omitempty"`
+}
+
+// ExplainRoute 按模拟请求解释路由过程：匹配的Endpoint及版本，选择的Filter，以及将要调用的后端服务
+func (s *BootstrapServer) ExplainRoute(req RouteExplainRequest) RouteExplain {
+	out := RouteExplain{Filters: []string{}}
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+	listenerId := strings.ToLower(req.ListenerId)
+	if listenerId == "" {
+		listenerId = ListenerIdDefault
+	}
+	listener, ok := s.WebListenerById(listenerId)
+	if !ok {
+		out.Message = "listener not found: " + listenerId
+		return out
+	}
+	request, err := http.NewRequest(method, req.Path, nil)
+	if nil != err {
+		out.Message = "invalid request path: " + err.Error()
+		return out
+	}
+	if req.Host != "" {
+		request.Host = req.Host
+	}
+	for name, value := range req.Headers {
+		request.Header.Set(name, value)
+	}
+	key, mve, webex, ok := s.matchRoute(listener, request, "explain")
+	if !ok {
+		out.Message = "no endpoint matches the request"
+		return out
+	}
+	out.Matched, out.RouteKey, out.PathParams = true, key, make(map[string]string, 2)
+	for name, values := range webex.PathVars() {
+		out.PathParams[name] = values[0]
+	}
+	for _, ep := range mve.Endpoints() {
+		out.Versions = append(out.Versions, ep.Version)
+	}
+	version := req.Version
+	if version == "" {
+		version = s.versionFunc(webex)
+	}
+	endpoint, found := mve.Select(webex, version)
+	for _, selector := range ext.EndpointSelectors() {
+		if selector.Active(webex, listenerId) {
+			if endpoint, found = selector.DoSelect(webex, listenerId, mve); found {
+				break
+			}
+		}
+	}
+	if !found {
+		out.Message = "no endpoint version selected, version: " + version
+		return out
+	}
+	out.Selected, out.Version, out.EndpointId = true, endpoint.Version, EndpointId(&endpoint)
+	if !s.servedBy(&endpoint, listenerId) {
+		out.Message = "selected endpoint version is not served by listener: " + listenerId
+		return out
+	}
+	out.Disabled = s.switches.isDisabled(&endpoint)
+	if timeout, ok := endpointTimeout(&endpoint); ok {
+		out.Timeout = timeout.String()
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(webex, &endpoint)
+	for _, filter := range s.dispatcher.selectFilters(ctx) {
+		out.Filters = append(out.Filters, filter.FilterId())
+	}
+	service := ctx.Transporter()
+	explain := &ExplainService{
+		Proto:      service.RpcProto(),
+		ServiceId:  service.ServiceID(),
+		Interface:  service.Interface,
+		Method:     service.Method,
+		RemoteHost: service.RemoteHost,
+		RpcTimeout: service.RpcTimeout(),
+	}
+	if transporter, ok := ext.TransporterBy(explain.Proto); ok {
+		explain.Available, explain.Type = true, reflect.TypeOf(transporter).String()
+	}
+	if timeout, ok := s.dispatcher.timeout.Timeout(&service); ok {
+		explain.AdaptiveTimeout = timeout.String()
+	}
+	out.Transporter = explain
+	return out
+}
+
+// RouteExplainHandler 路由解释的管理接口；请求Body为JSON格式的 RouteExplainRequest
+func (s *BootstrapServer) RouteExplainHandler(webex flux.ServerWebContext) error {
+	reader, err := webex.BodyReader()
+	if nil != err {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	data, err := ioutil.ReadAll(reader)
+	_ = reader.Close()
+	if nil != err {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	var req RouteExplainRequest
+	if err := ext.JSONUnmarshal(data, &req); nil != err {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": "invalid request: " + err.Error()})
+	}
+	if req.Path == "" {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": "path is required"})
+	}
+	return writeJSON(webex, flux.StatusOK, s.ExplainRoute(req))
+}
+
+// matchRoute 按WebListener的路由表匹配请求，及按请求Host选择Endpoint；返回路由Key，匹配的Endpoint，以及携带路径参数的WebContext
+func (s *BootstrapServer) matchRoute(listener flux.WebListener, request *http.Request, reqid string) (string, *flux.MVCEndpoint, flux.ServerWebContext, bool) {
+	webex, pattern, ok := internal.NewRoutedWebContext(listener, request, reqid)
+	if !ok {
+		return "", nil, nil, false
+	}
+	routes, ok := s.lookupHostRoutes(listener.ListenerId(), request.Method, pattern)
+	if !ok {
+		return "", nil, nil, false
+	}
+	mve, ok := routes.match(request.Host)
+	if !ok {
+		return "", nil, nil, false
+	}
+	bind := mve.Random()
+	return routeKeyOf(request.Method, bind.HttpPattern, bind.Hosts()), mve, webex, true
+}
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// routeListener 只实现路由注册及查找的WebListener
+type routeListener struct {
+	flux.WebListener
+	id     string
+	server *echo.Echo
+}
+
+func newRouteListener(id string) *routeListener {
+	return &routeListener{id: id, server: echo.New()}
+}
+
+func (l *routeListener) ListenerId() string {
+	return l.id
+}
+
+func (l *routeListener) ShadowServer() interface{} {
+	return l.server
+}
+
+func (l *routeListener) AddHandler(method, pattern string, _ flux.WebHandler, _ ...flux.WebInterceptor) {
+	pattern = strings.NewReplacer("{", ":", "}", "").Replace(pattern)
+	l.server.Add(method, pattern, func(echo.Context) error {
+		return nil
+	})
+}
+
+func bindTestRoute(s *BootstrapServer, webListener flux.WebListener, method, pattern string, hosts ...string) *flux.MVCEndpoint {
+	endpoint := &flux.Endpoint{Version: "v1", HttpMethod: method, HttpPattern: pattern}
+	mve := flux.NewMultiEndpoint(endpoint)
+	mve.Update(endpoint.Version, endpoint)
+	s.bindHostRoute(webListener, method, pattern, hosts, mve)
+	return mve
+}
+
+func TestBootstrapServer_MatchRoute(t *testing.T) {
+	assert := assert.New(t)
+	s := &BootstrapServer{hosts: make(map[string]*hostRoutes, 4)}
+	web := newRouteListener("web")
+	static := bindTestRoute(s, web, http.MethodGet, "/users/me")
+	param := bindTestRoute(s, web, http.MethodGet, "/users/{id}")
+	tenant := bindTestRoute(s, web, http.MethodGet, "/users/{id}", "*.tenant.com")
+	wildcard := bindTestRoute(s, web, http.MethodGet, "/files/*")
+
+	match := func(method, target, host string) (*flux.MVCEndpoint, flux.ServerWebContext, bool) {
+		request := httptest.NewRequest(method, target, nil)
+		if host != "" {
+			request.Host = host
+		}
+		_, mve, webex, ok := s.matchRoute(web, request, "test")
+		return mve, webex, ok
+	}
+	// 静态路径优先于参数路径
+	mve, _, ok := match(http.MethodGet, "/users/me", "")
+	assert.True(ok)
+	assert.Equal(static, mve)
+	mve, webex, ok := match(http.MethodGet, "/users/123?x=1", "")
+	assert.True(ok)
+	assert.Equal(param, mve)
+	assert.Equal("123", webex.PathVar("id"))
+	// 匹配Host的Endpoint优先于未定义Host的Endpoint
+	mve, _, ok = match(http.MethodGet, "/users/123", "a.tenant.com:8080")
+	assert.True(ok)
+	assert.Equal(tenant, mve)
+	mve, webex, ok = match(http.MethodGet, "/files/a/b.txt", "")
+	assert.True(ok)
+	assert.Equal(wildcard, mve)
+	assert.Equal("a/b.txt", webex.PathVar("*"))
+	// 方法，路径不匹配
+	_, _, ok = match(http.MethodPost, "/users/123", "")
+	assert.False(ok)
+	_, _, ok = match(http.MethodGet, "/orders/123", "")
+	assert.False(ok)
+	// 其它WebListener未绑定路由
+	_, _, _, ok = s.matchRoute(newRouteListener("admin"), httptest.NewRequest(http.MethodGet, "/users/me", nil), "test")
+	assert.False(ok)
+}
+
+func TestHostRouteKey(t *testing.T) {
+	assert.Equal(t, hostRouteKey("Web", "GET", "/users/{id}/orders/{oid}"), hostRouteKey("web", "GET", "/users/:id/orders/:oid"))
+	assert.NotEqual(t, hostRouteKey("web", "GET", "/users/{id}"), hostRouteKey("web", "POST", "/users/{id}"))
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"reflect"
+	"sync"
+)
+
+const (
+	FilterKindGlobal    = "global"
+	FilterKindSelective = "selective"
+)
+
+// FilterInfo 已加载的Filter信息；Order 为执行顺序，值越小越先执行
+type FilterInfo struct {
+	FilterId string `json:"filterId"`
+	Kind     string `json:"kind"`
+	Order    int    `json:"order"`
+	Type     string `json:"type"`
+	ConfigNs string `json:"configNs"`
+	Enabled  bool   `json:"enabled"`
+}
+
+// filterSwitches Filter的启用状态；配置 disabled 的Filter初始为停用，可通过管理接口在运行时启用或停用，
+// 停用的Filter在 Dispatcher.Route 选择Filter时被跳过，立即生效。
+type filterSwitches struct {
+	disabled map[string]bool
+	// 重新加载配置时因配置停用的Filter
+	configured  map[string]bool
+	initialized map[string]bool
+	namespaces  map[string]string
+	mu          sync.RWMutex
+}
+
+func newFilterSwitches() *filterSwitches {
+	return &filterSwitches{
+		disabled:    make(map[string]bool, 4),
+		configured:  make(map[string]bool, 4),
+		initialized: make(map[string]bool, 16),
+		namespaces:  make(map[string]string, 16),
+	}
+}
+
+// register 记录Filter的配置命名空间及初始状态
+func (w *filterSwitches) register(filterId, ns string, disabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.namespaces[filterId] = ns
+	w.disabled[filterId] = disabled
+	w.initialized[filterId] = !disabled
+}
+
+// reconfigure 按重新加载的配置停用Filter；配置恢复启用时，只恢复因配置停用的Filter，不改变通过管理接口停用的Filter
+func (w *filterSwitches) reconfigure(filterId string, disabled bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if disabled {
+		if !w.disabled[filterId] {
+			w.disabled[filterId] = true
+			w.configured[filterId] = true
+		}
+	} else if w.configured[filterId] {
+		w.disabled[filterId] = false
+		delete(w.configured, filterId)
+	}
+}
+
+func (w *filterSwitches) isDisabled(filterId string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.disabled[filterId]
+}
+
+// active 返回启用状态的Filter列表
+// enabled 返回Filter是否处于启用状态
+func (w *filterSwitches) enabled(filterId string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return !w.disabled[filterId]
+}
+
+func (w *filterSwitches) active(filters []flux.Filter) []flux.Filter {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	out := filters[:0]
+	for _, filter := range filters {
+		if !w.disabled[filter.FilterId()] {
+			out = append(out, filter)
+		}
+	}
+	return out
+}
+
+// Filters 返回已加载的Filter列表，按执行顺序排列
+func (r *Dispatcher) Filters() []FilterInfo {
+	out := make([]FilterInfo, 0, 16)
+	add := func(kind string, filters []flux.Filter) {
+		for _, filter := range filters {
+			id := filter.FilterId()
+			r.filters.mu.RLock()
+			ns, ok := r.filters.namespaces[id]
+			disabled := r.filters.disabled[id]
+			r.filters.mu.RUnlock()
+			if !ok {
+				ns = id
+			}
+			out = append(out, FilterInfo{
+				FilterId: id, Kind: kind, Order: orderOf(filter), Type: reflect.TypeOf(filter).String(),
+				ConfigNs: ns, Enabled: !disabled,
+			})
+		}
+	}
+	add(FilterKindGlobal, ext.GlobalFilters())
+	add(FilterKindSelective, ext.SelectiveFilters())
+	return out
+}
+
+// SetFilterEnabled 在运行时启用或停用Filter；启用配置为停用（未初始化）的Filter时，先按配置初始化。
+// 与重新加载配置互斥执行；初始化及启动Filter时不持有Filter状态锁，不阻塞 Dispatcher.Route 选择Filter。
+func (r *Dispatcher) SetFilterEnabled(filterId string, enabled bool) error {
+	filter, ok := filterById(filterId)
+	if !ok {
+		return fmt.Errorf("filter not found: %s", filterId)
+	}
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	r.filters.mu.RLock()
+	initialized := r.filters.initialized[filterId]
+	ns, ok := r.filters.namespaces[filterId]
+	r.filters.mu.RUnlock()
+	if !ok {
+		ns = filterId
+	}
+	if enabled && !initialized {
+		if init, ok := filter.(flux.Initializer); ok {
+			if err := init.Init(flux.NewConfigurationOfNS(ns)); nil != err {
+				return fmt.Errorf("filter init, filter-id: %s, err: %w", filterId, err)
+			}
+		}
+		// 服务已启动，直接执行启动Hook
+		if startup, ok := filter.(flux.Startuper); ok {
+			if err := startup.Startup(); nil != err {
+				return fmt.Errorf("filter startup, filter-id: %s, err: %w", filterId, err)
+			}
+		}
+		ext.AddHookFunc(filter)
+		r.reloads = append(r.reloads, reloadTarget{kind: ComponentKindFilter, id: filterId, ns: ns, ref: filter})
+	}
+	r.filters.mu.Lock()
+	defer r.filters.mu.Unlock()
+	if enabled {
+		r.filters.initialized[filterId] = true
+	}
+	r.filters.disabled[filterId] = !enabled
+	return nil
+}
+
+func filterById(filterId string) (flux.Filter, bool) {
+	for _, filter := range append(ext.GlobalFilters(), ext.SelectiveFilters()...) {
+		if filter.FilterId() == filterId {
+			return filter, true
+		}
+	}
+	return nil, false
+}
+
+// FilterListHandler 查询已加载Filter的管理接口
+func (s *BootstrapServer) FilterListHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.dispatcher.Filters())
+}
+
+// FilterEnableHandler 启用Filter的管理接口；路径参数：id
+func (s *BootstrapServer) FilterEnableHandler(webex flux.ServerWebContext) error {
+	return s.switchFilter(webex, true)
+}
+
+// FilterDisableHandler 停用Filter的管理接口；路径参数：id
+func (s *BootstrapServer) FilterDisableHandler(webex flux.ServerWebContext) error {
+	return s.switchFilter(webex, false)
+}
+
+func (s *BootstrapServer) switchFilter(webex flux.ServerWebContext, enabled bool) error {
+	id := webex.PathVar("id")
+	if _, ok := filterById(id); !ok {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "filter not found: " + id})
+	}
+	before := s.dispatcher.filters.enabled(id)
+	if err := s.dispatcher.SetFilterEnabled(id, enabled); nil != err {
+		logger.Errorw("SERVER:FILTER:SWITCH/ERROR", "filter-id", id, "enabled", enabled, "error", err)
+		return writeJSON(webex, flux.StatusServerError, map[string]string{"error": err.Error()})
+	}
+	logger.Infow("SERVER:FILTER:SWITCHED", "filter-id", id, "enabled", enabled)
+	action := AuditActionFilterOff
+	if enabled {
+		action = AuditActionFilterOn
+	}
+	s.auditChanged(webex, action, id, map[string]bool{"enabled": before}, map[string]bool{"enabled": enabled})
+	return writeJSON(webex, flux.StatusOK, map[string]interface{}{"filterId": id, "enabled": enabled})
+}
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"time"
+)
+
+const (
+	// Filter耗时统计配置：disabled，slow_threshold
+	ConfigNsFilterTiming = "filter_timing"
+)
+
+const (
+	ConfigKeyTimingSlowThreshold = "slow_threshold"
+)
+
+// FilterTimings 统计每个Filter在请求中的自身耗时（不含后续调用链）；
+// 任一Filter的自身耗时达到慢阈值时，输出请求全部Filter的耗时明细日志。
+type FilterTimings struct {
+	disabled  bool
+	threshold time.Duration
+	histogram flux.HistogramVec
+}
+
+func NewFilterTimings(histogram flux.HistogramVec) *FilterTimings {
+	return &FilterTimings{
+		threshold: 200 * time.Millisecond,
+		histogram: histogram,
+	}
+}
+
+func (t *FilterTimings) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyTimingSlowThreshold: 200 * time.Millisecond,
+	})
+	t.disabled = IsDisabled(config)
+	t.threshold = config.GetDuration(ConfigKeyTimingSlowThreshold)
+	logger.Infow("Filter timing init", "disabled", t.disabled, "slow-threshold", t.threshold)
+	return nil
+}
+
+// newRecorder 创建单个请求的Filter耗时记录；关闭统计时返回nil
+func (t *FilterTimings) newRecorder(size int) *filterTimingRecorder {
+	if t.disabled || size == 0 {
+		return nil
+	}
+	return &filterTimingRecorder{
+		ids:     make([]string, size),
+		entered: make([]bool, size),
+		totals:  make([]time.Duration, size),
+		nested:  make([]time.Duration, size),
+	}
+}
+
+// report 记录请求中已执行Filter的耗时指标，并检查慢Filter
+func (t *FilterTimings) report(ctx *flux.Context, rec *filterTimingRecorder) {
+	if nil == rec {
+		return
+	}
+	slow := make([]string, 0, 2)
+	breakdown := make([]string, 0, len(rec.ids))
+	for i, id := range rec.ids {
+		if !rec.entered[i] {
+			continue
+		}
+		elapsed := rec.totals[i] - rec.nested[i]
+		t.histogram.WithLabelValues(id).Observe(elapsed.Seconds())
+		breakdown = append(breakdown, id+"="+elapsed.String())
+		if t.threshold > 0 && elapsed >= t.threshold {
+			slow = append(slow, id)
+		}
+	}
+	if len(slow) > 0 {
+		logger.ModuleOf(logger.ModuleFilter, logger.TraceContext(ctx)).Warnw("SERVER:FILTER:SLOW", "slow-filters", slow,
+			"slow-threshold", t.threshold.String(), "filters", breakdown)
+	}
+}
+
+// filterTimingRecorder 单个请求的Filter耗时记录，按Filter在调用链中的位置记录
+type filterTimingRecorder struct {
+	ids     []string
+	entered []bool
+	totals  []time.Duration
+	nested  []time.Duration
+}
+
+// filter 记录Filter及其后续调用链的总耗时
+func (r *filterTimingRecorder) filter(i int, filterId string, next flux.FilterInvoker) flux.FilterInvoker {
+	if nil == r {
+		return next
+	}
+	r.ids[i] = filterId
+	return func(ctx *flux.Context) *flux.ServeError {
+		r.entered[i] = true
+		start := time.Now()
+		defer func() {
+			r.totals[i] += time.Since(start)
+		}()
+		return next(ctx)
+	}
+}
+
+// downstream 记录Filter后续调用链的耗时
+func (r *filterTimingRecorder) downstream(i int, next flux.FilterInvoker) flux.FilterInvoker {
+	if nil == r {
+		return next
+	}
+	return func(ctx *flux.Context) *flux.ServeError {
+		start := time.Now()
+		defer func() {
+			r.nested[i] += time.Since(start)
+		}()
+		return next(ctx)
+	}
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/listener"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+	"sort"
+	"strings"
+)
+
+const (
+	// 服务分组配置：分组ID为key，listener，interceptors，match_attr，match_values
+	ConfigNsServingGroups = "serving_groups"
+)
+
+const (
+	ConfigKeyGroupListener     = "listener"
+	ConfigKeyGroupInterceptors = "interceptors"
+	ConfigKeyGroupMatchAttr    = "match_attr"
+	ConfigKeyGroupMatchValues  = "match_values"
+)
+
+// ServingGroup 服务分组：一组Endpoint由独立的WebListener及拦截器链对外服务，例如 public，internal，partner；
+// 按Endpoint属性选择分组内的Endpoint：属性值属于 match_values；match_values 为空时属性值为true；match_attr 为空时选择全部Endpoint。
+type ServingGroup struct {
+	Id           string   `json:"id"`
+	ListenerId   string   `json:"listenerId"`
+	Interceptors []string `json:"interceptors,omitempty"`
+	MatchAttr    string   `json:"matchAttr,omitempty"`
+	MatchValues  []string `json:"matchValues,omitempty"`
+}
+
+// Match 判断Endpoint是否属于此服务分组
+func (g ServingGroup) Match(endpoint *flux.Endpoint) bool {
+	if g.MatchAttr == "" {
+		return true
+	}
+	attr, ok := endpoint.GetAttrEx(g.MatchAttr)
+	if !ok {
+		return false
+	}
+	if len(g.MatchValues) == 0 {
+		return attr.GetBool()
+	}
+	value := attr.GetString()
+	for _, v := range g.MatchValues {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithServingGroupInterceptor 注册服务分组可引用的拦截器；分组配置 interceptors 按名称引用
+func WithServingGroupInterceptor(name string, interceptor flux.WebInterceptor) Option {
+	return func(bs *BootstrapServer) {
+		bs.interceptors[name] = interceptor
+	}
+}
+
+// initServingGroups 加载服务分组配置；分组的WebListener未注册时，按 web_listeners 下同名配置创建
+func (s *BootstrapServer) initServingGroups() error {
+	ids := make([]string, 0, 4)
+	for id := range viper.GetStringMap(ConfigNsServingGroups) {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	groups := make([]ServingGroup, 0, len(ids))
+	for _, id := range ids {
+		gc := flux.NewConfigurationOfNS(ConfigNsServingGroups + "." + id)
+		if IsDisabled(gc) {
+			continue
+		}
+		gc.SetDefault(ConfigKeyGroupListener, id)
+		group := ServingGroup{
+			Id:           id,
+			ListenerId:   strings.ToLower(gc.GetString(ConfigKeyGroupListener)),
+			Interceptors: cast.ToStringSlice(gc.Get(ConfigKeyGroupInterceptors)),
+			MatchAttr:    gc.GetString(ConfigKeyGroupMatchAttr),
+			MatchValues:  cast.ToStringSlice(gc.Get(ConfigKeyGroupMatchValues)),
+		}
+		if strings.EqualFold(group.ListenerId, ListenServerIdAdmin) {
+			return fmt.Errorf("serving group cannot use admin listener, group: %s", id)
+		}
+		webListener, ok := s.WebListenerById(group.ListenerId)
+		if !ok {
+			webListener = listener.New(group.ListenerId, LoadWebListenerConfig(group.ListenerId), nil)
+			s.AddWebListener(group.ListenerId, webListener)
+		}
+		for _, name := range group.Interceptors {
+			interceptor, ok := s.interceptors[name]
+			if !ok {
+				return fmt.Errorf("serving group interceptor not found, group: %s, interceptor: %s", id, name)
+			}
+			webListener.AddInterceptor(interceptor)
+		}
+		logger.Infow("SERVER:SERVING_GROUP", "group", id, "listener-id", group.ListenerId,
+			"interceptors", group.Interceptors, "match-attr", group.MatchAttr, "match-values", group.MatchValues)
+		groups = append(groups, group)
+	}
+	s.groups = groups
+	return nil
+}
+
+// ServingGroups 返回已加载的服务分组
+func (s *BootstrapServer) ServingGroups() []ServingGroup {
+	return s.groups
+}
+
+// endpointListenerIds 返回Endpoint绑定的WebListener：Endpoint属性 listenerid 指定时只绑定指定的WebListener；
+// 否则绑定全部匹配的服务分组；未配置服务分组时绑定默认WebListener。
+func (s *BootstrapServer) endpointListenerIds(endpoint *flux.Endpoint) []string {
+	if id := endpoint.GetAttr(flux.EndpointAttrTagListenerId).GetString(); id != "" || len(s.groups) == 0 {
+		return []string{endpointListenerId(endpoint)}
+	}
+	ids := make([]string, 0, 1)
+	for _, group := range s.groups {
+		if group.Match(endpoint) && !containsFold(ids, group.ListenerId) {
+			ids = append(ids, group.ListenerId)
+		}
+	}
+	return ids
+}
+
+// servedBy 判断Endpoint是否由指定WebListener服务
+func (s *BootstrapServer) servedBy(endpoint *flux.Endpoint, listenerId string) bool {
+	return containsFold(s.endpointListenerIds(endpoint), listenerId)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServingGroupsHandler 查询服务分组的管理接口
+func (s *BootstrapServer) ServingGroupsHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.ServingGroups())
+}
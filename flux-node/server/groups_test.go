@@ -0,0 +1,95 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newGroupEndpoint(attrs ...flux.Attribute) *flux.Endpoint {
+	return &flux.Endpoint{
+		HttpPattern:        "/users",
+		HttpMethod:         "GET",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+	}
+}
+
+func TestServingGroup_Match(t *testing.T) {
+	cases := []struct {
+		name     string
+		group    ServingGroup
+		endpoint *flux.Endpoint
+		expected bool
+	}{
+		{
+			name:     "no match attr",
+			group:    ServingGroup{Id: "all"},
+			endpoint: newGroupEndpoint(),
+			expected: true,
+		},
+		{
+			name:     "bool attr true",
+			group:    ServingGroup{Id: "internal", MatchAttr: "internal"},
+			endpoint: newGroupEndpoint(flux.Attribute{Name: "internal", Value: true}),
+			expected: true,
+		},
+		{
+			name:     "bool attr false",
+			group:    ServingGroup{Id: "internal", MatchAttr: "internal"},
+			endpoint: newGroupEndpoint(flux.Attribute{Name: "internal", Value: false}),
+			expected: false,
+		},
+		{
+			name:     "attr missing",
+			group:    ServingGroup{Id: "internal", MatchAttr: "internal"},
+			endpoint: newGroupEndpoint(),
+			expected: false,
+		},
+		{
+			name:     "value matched ignore case",
+			group:    ServingGroup{Id: "partner", MatchAttr: "audience", MatchValues: []string{"public", "Partner"}},
+			endpoint: newGroupEndpoint(flux.Attribute{Name: "audience", Value: "partner"}),
+			expected: true,
+		},
+		{
+			name:     "value not matched",
+			group:    ServingGroup{Id: "partner", MatchAttr: "audience", MatchValues: []string{"public", "partner"}},
+			endpoint: newGroupEndpoint(flux.Attribute{Name: "audience", Value: "internal"}),
+			expected: false,
+		},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.expected, tc.group.Match(tc.endpoint), tc.name)
+	}
+}
+
+func TestBootstrapServer_EndpointListenerIds(t *testing.T) {
+	assert := assert.New(t)
+	// 未配置服务分组
+	s := &BootstrapServer{}
+	assert.Equal([]string{ListenerIdDefault}, s.endpointListenerIds(newGroupEndpoint()))
+	assert.Equal([]string{"admin2"}, s.endpointListenerIds(newGroupEndpoint(
+		flux.Attribute{Name: flux.EndpointAttrTagListenerId, Value: "admin2"})))
+	s.groups = []ServingGroup{
+		{Id: "public", ListenerId: "public", MatchAttr: "audience", MatchValues: []string{"public"}},
+		{Id: "partner", ListenerId: "partner", MatchAttr: "audience", MatchValues: []string{"public", "partner"}},
+		{Id: "partner2", ListenerId: "PARTNER", MatchAttr: "audience", MatchValues: []string{"partner"}},
+	}
+	public := newGroupEndpoint(flux.Attribute{Name: "audience", Value: "public"})
+	assert.Equal([]string{"public", "partner"}, s.endpointListenerIds(public))
+	// 相同WebListener只绑定一次
+	partner := newGroupEndpoint(flux.Attribute{Name: "audience", Value: "partner"})
+	assert.Equal([]string{"partner"}, s.endpointListenerIds(partner))
+	// 不匹配任何服务分组
+	internal := newGroupEndpoint(flux.Attribute{Name: "audience", Value: "internal"})
+	assert.Empty(s.endpointListenerIds(internal))
+	// Endpoint属性 listenerid 优先于服务分组
+	pinned := newGroupEndpoint(flux.Attribute{Name: "audience", Value: "public"},
+		flux.Attribute{Name: flux.EndpointAttrTagListenerId, Value: "internal"})
+	assert.Equal([]string{"internal"}, s.endpointListenerIds(pinned))
+
+	assert.True(s.servedBy(public, "PUBLIC"))
+	assert.True(s.servedBy(public, "partner"))
+	assert.False(s.servedBy(partner, "public"))
+	assert.False(s.servedBy(internal, ListenerIdDefault))
+}
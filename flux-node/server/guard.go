@@ -0,0 +1,309 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// Filter异常隔离配置：disabled（关闭熔断，仍捕获Panic），panic_threshold，panic_window，policy
+	ConfigNsFilterGuard = "filter_guard"
+)
+
+const (
+	ConfigKeyGuardPanicThreshold = "panic_threshold"
+	ConfigKeyGuardPanicWindow    = "panic_window"
+	ConfigKeyGuardPolicy         = "policy"
+)
+
+const (
+	// FilterGuardPolicyDisable 熔断后跳过此Filter，继续执行后续Filter
+	FilterGuardPolicyDisable = "disable"
+	// FilterGuardPolicyFail 熔断后拒绝所有经过此Filter的请求
+	FilterGuardPolicyFail = "fail"
+)
+
+const (
+	FilterStatusActive   = "active"
+	FilterStatusTripped  = "tripped"
+	FilterStatusDisabled = "disabled"
+)
+
+type (
+	// FilterAlert Filter因异常次数过多被熔断时发出的告警事件
+	FilterAlert struct {
+		FilterId string        `json:"filterId"`
+		Policy   string        `json:"policy"`
+		Panics   int           `json:"panics"`
+		Window   time.Duration `json:"window"`
+		Error    string        `json:"error"`
+		Time     time.Time     `json:"time"`
+	}
+	// FilterAlertFunc 接收Filter熔断告警事件的函数
+	FilterAlertFunc func(alert FilterAlert)
+)
+
+// FilterStatus Filter运行状态
+type FilterStatus struct {
+	FilterId  string    `json:"filterId"`
+	Status    string    `json:"status"`
+	Panics    int       `json:"panics"`
+	LastPanic string    `json:"lastPanic"`
+	TrippedAt time.Time `json:"trippedAt"`
+}
+
+// downstreamPanic 标记后续调用链产生的Panic，避免被上层Filter重复统计
+type downstreamPanic struct {
+	value interface{}
+}
+
+func (p downstreamPanic) String() string {
+	return fmt.Sprintf("%v", p.value)
+}
+
+// FilterGuards 对每个Filter的执行进行Panic隔离：Panic转换为ServeError响应，并按FilterId统计；
+// 在时间窗口内Panic次数达到阈值时，按策略熔断此Filter
+type FilterGuards struct {
+	counter   flux.CounterVec
+	disabled  bool
+	threshold int
+	window    time.Duration
+	policy    string
+	alerts    []FilterAlertFunc
+	guards    map[string]*filterGuard
+	mu        sync.RWMutex
+}
+
+type filterGuard struct {
+	filterId  string
+	panics    []time.Time
+	lastPanic string
+	tripped   bool
+	trippedAt time.Time
+	mu        sync.Mutex
+}
+
+func NewFilterGuards(counter flux.CounterVec) *FilterGuards {
+	return &FilterGuards{
+		counter:   counter,
+		threshold: 5,
+		window:    time.Minute,
+		policy:    FilterGuardPolicyDisable,
+		alerts:    make([]FilterAlertFunc, 0, 2),
+		guards:    make(map[string]*filterGuard, 16),
+	}
+}
+
+func (g *FilterGuards) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyGuardPanicThreshold: 5,
+		ConfigKeyGuardPanicWindow:    time.Minute,
+		ConfigKeyGuardPolicy:         FilterGuardPolicyDisable,
+	})
+	g.disabled = IsDisabled(config)
+	g.threshold = config.GetInt(ConfigKeyGuardPanicThreshold)
+	g.window = config.GetDuration(ConfigKeyGuardPanicWindow)
+	switch policy := config.GetString(ConfigKeyGuardPolicy); policy {
+	case FilterGuardPolicyDisable, FilterGuardPolicyFail:
+		g.policy = policy
+	default:
+		return fmt.Errorf("unknown filter guard policy: %s", policy)
+	}
+	logger.Infow("Filter guard init", "disabled", g.disabled,
+		"panic-threshold", g.threshold, "panic-window", g.window, "policy", g.policy)
+	return nil
+}
+
+// AddAlertFunc 添加Filter熔断告警的处理函数
+func (g *FilterGuards) AddAlertFunc(f FilterAlertFunc) {
+	g.alerts = append(g.alerts, f)
+}
+
+// Wrap 包装Filter的执行，捕获Filter自身产生的Panic；关闭熔断时仍捕获Panic，但不熔断Filter
+func (g *FilterGuards) Wrap(filter flux.Filter, next flux.FilterInvoker) flux.FilterInvoker {
+	guard := g.guardOf(filter.FilterId())
+	if !g.disabled && guard.isTripped() {
+		if g.policy == FilterGuardPolicyFail {
+			return func(ctx *flux.Context) *flux.ServeError {
+				return &flux.ServeError{
+					StatusCode: http.StatusServiceUnavailable,
+					ErrorCode:  flux.ErrorCodeGatewayInternal,
+					Message:    "FILTER:TRIPPED:" + guard.filterId,
+				}
+			}
+		}
+		return next
+	}
+	downstream := func(ctx *flux.Context) *flux.ServeError {
+		defer func() {
+			if rvr := recover(); nil != rvr {
+				if _, ok := rvr.(downstreamPanic); ok {
+					panic(rvr)
+				}
+				panic(downstreamPanic{value: rvr})
+			}
+		}()
+		return next(ctx)
+	}
+	return func(ctx *flux.Context) (serr *flux.ServeError) {
+		defer func() {
+			if rvr := recover(); nil != rvr {
+				if _, ok := rvr.(downstreamPanic); ok {
+					panic(rvr)
+				}
+				serr = g.onPanic(ctx, guard, rvr)
+			}
+		}()
+		return filter.DoFilter(downstream)(ctx)
+	}
+}
+
+func (g *FilterGuards) onPanic(ctx *flux.Context, guard *filterGuard, rvr interface{}) *flux.ServeError {
+	logger.ModuleOf(logger.ModuleFilter, logger.TraceContext(ctx)).Errorw("SERVER:FILTER:PANIC", "filter-id", guard.filterId,
+		"error", rvr, "error.trace", string(debug.Stack()))
+	if nil != g.counter {
+		g.counter.WithLabelValues(guard.filterId).Inc()
+	}
+	now := time.Now()
+	threshold := g.threshold
+	if g.disabled {
+		threshold = 0
+	}
+	if panics, tripped := guard.record(now, fmt.Sprintf("%v", rvr), g.window, threshold); tripped {
+		alert := FilterAlert{
+			FilterId: guard.filterId, Policy: g.policy, Panics: panics,
+			Window: g.window, Error: fmt.Sprintf("%v", rvr), Time: now,
+		}
+		logger.Errorw("SERVER:FILTER:TRIPPED", "filter-id", guard.filterId, "policy", g.policy, "panics", panics)
+		for _, alertf := range g.alerts {
+			alertf(alert)
+		}
+	}
+	return &flux.ServeError{
+		StatusCode: flux.StatusServerError,
+		ErrorCode:  flux.ErrorCodeGatewayInternal,
+		Message:    "FILTER:PANIC:" + guard.filterId,
+		CauseError: fmt.Errorf("filter panic: %v", rvr),
+	}
+}
+
+// Reset 重置Filter的熔断状态
+func (g *FilterGuards) Reset(filterId string) bool {
+	g.mu.RLock()
+	guard, ok := g.guards[filterId]
+	g.mu.RUnlock()
+	if ok {
+		guard.reset()
+	}
+	return ok
+}
+
+// Statuses 返回全部Filter的运行状态
+func (g *FilterGuards) Statuses() []FilterStatus {
+	g.mu.RLock()
+	out := make([]FilterStatus, 0, len(g.guards))
+	for _, guard := range g.guards {
+		out = append(out, guard.status(g.policy))
+	}
+	g.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].FilterId < out[j].FilterId
+	})
+	return out
+}
+
+// StatusHandler 查询Filter运行状态的管理接口
+func (g *FilterGuards) StatusHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, g.Statuses())
+}
+
+// ResetHandler 重置Filter熔断状态的管理接口；参数：filter-id
+func (g *FilterGuards) ResetHandler(webex flux.ServerWebContext) error {
+	id := webex.QueryVar("filter-id")
+	if !g.Reset(id) {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "filter not found: " + id})
+	}
+	logger.Infow("SERVER:FILTER:RESET", "filter-id", id)
+	return writeJSON(webex, flux.StatusOK, map[string]string{"filterId": id, "status": FilterStatusActive})
+}
+
+func (g *FilterGuards) guardOf(filterId string) *filterGuard {
+	g.mu.RLock()
+	guard, ok := g.guards[filterId]
+	g.mu.RUnlock()
+	if ok {
+		return guard
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if guard, ok = g.guards[filterId]; !ok {
+		guard = &filterGuard{filterId: filterId, panics: make([]time.Time, 0, 4)}
+		g.guards[filterId] = guard
+	}
+	return guard
+}
+
+// record 记录Panic，返回窗口内的Panic次数，以及是否本次触发熔断
+func (f *filterGuard) record(now time.Time, errmsg string, window time.Duration, threshold int) (int, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastPanic = errmsg
+	valid := f.panics[:0]
+	for _, at := range f.panics {
+		if now.Sub(at) <= window {
+			valid = append(valid, at)
+		}
+	}
+	f.panics = append(valid, now)
+	if !f.tripped && threshold > 0 && len(f.panics) >= threshold {
+		f.tripped = true
+		f.trippedAt = now
+		return len(f.panics), true
+	}
+	return len(f.panics), false
+}
+
+func (f *filterGuard) isTripped() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.tripped
+}
+
+func (f *filterGuard) reset() {
+	f.mu.Lock()
+	f.tripped = false
+	f.trippedAt = time.Time{}
+	f.panics = f.panics[:0]
+	f.mu.Unlock()
+}
+
+func (f *filterGuard) status(policy string) FilterStatus {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := FilterStatusActive
+	if f.tripped {
+		status = FilterStatusDisabled
+		if policy == FilterGuardPolicyFail {
+			status = FilterStatusTripped
+		}
+	}
+	return FilterStatus{
+		FilterId: f.filterId, Status: status, Panics: len(f.panics),
+		LastPanic: f.lastPanic, TrippedAt: f.trippedAt,
+	}
+}
+
+func writeJSON(webex flux.ServerWebContext, status int, payload interface{}) error {
+	bytes, err := common.SerializeObject(payload)
+	if nil != err {
+		return err
+	}
+	return webex.Write(status, flux.MIMEApplicationJSONCharsetUTF8, bytes)
+}
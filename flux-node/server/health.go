@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// 健康检查配置：timeout，probes
+	ConfigNsHealth = "health"
+)
+
+const (
+	ConfigKeyHealthTimeout = "timeout"
+	ConfigKeyHealthProbes  = "probes"
+)
+
+var _ flux.HealthIndicator = new(TcpProbeIndicator)
+
+// TcpProbeIndicator 通过TCP连接检查上游服务的连通性
+type TcpProbeIndicator struct {
+	Name    string
+	Address string
+	Timeout time.Duration
+}
+
+func (p *TcpProbeIndicator) HealthId() string {
+	return "probe:" + p.Name
+}
+
+func (p *TcpProbeIndicator) Health(ctx context.Context) flux.Health {
+	dialer := net.Dialer{Timeout: p.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", p.Address)
+	if nil != err {
+		return flux.NewHealthDown(map[string]interface{}{"address": p.Address, "error": err.Error()})
+	}
+	_ = conn.Close()
+	return flux.NewHealthUp(map[string]interface{}{"address": p.Address})
+}
+
+// initHealthProbes 加载上游服务连通性检查配置
+func (s *BootstrapServer) initHealthProbes() {
+	config := flux.NewConfigurationOfNS(ConfigNsHealth)
+	config.SetDefault(ConfigKeyHealthTimeout, time.Second*3)
+	s.healthTimeout = config.GetDuration(ConfigKeyHealthTimeout)
+	for _, probe := range config.GetConfigurationSlice(ConfigKeyHealthProbes) {
+		probe.SetDefault("timeout", time.Second)
+		indicator := &TcpProbeIndicator{
+			Name:    probe.GetString("name"),
+			Address: probe.GetString("address"),
+			Timeout: probe.GetDuration("timeout"),
+		}
+		if indicator.Address == "" {
+			logger.Warnw("SERVER:HEALTH:PROBE:IGNORE", "name", indicator.Name)
+			continue
+		}
+		if indicator.Name == "" {
+			indicator.Name = indicator.Address
+		}
+		logger.Infow("SERVER:HEALTH:PROBE:ADD", "name", indicator.Name, "address", indicator.Address)
+		ext.AddHealthIndicator(indicator)
+	}
+}
+
+// CheckHealth 执行全部健康检查项，返回汇总结果
+func (s *BootstrapServer) CheckHealth(ctx context.Context) flux.Health {
+	details := make(map[string]interface{}, 8)
+	up := true
+	// Server started
+	select {
+	case <-s.started:
+		details["server"] = flux.NewHealthUp(nil)
+	default:
+		details["server"] = flux.NewHealthDown(nil)
+		up = false
+	}
+	// Endpoints
+	if count := len(ext.Endpoints()); count > 0 {
+		details["endpoints"] = flux.NewHealthUp(map[string]interface{}{"count": count})
+	} else {
+		details["endpoints"] = flux.NewHealthDown(map[string]interface{}{"count": count})
+		up = false
+	}
+	// Transporters
+	transporters := checkTransporters()
+	details["transporters"] = transporters
+	up = up && transporters.IsUp()
+	// Indicators
+	ctx, cancel := context.WithTimeout(ctx, s.healthTimeout)
+	defer cancel()
+	indicators := ext.HealthIndicators()
+	results := make([]flux.Health, len(indicators))
+	var wg sync.WaitGroup
+	for i, indicator := range indicators {
+		wg.Add(1)
+		go func(i int, indicator flux.HealthIndicator) {
+			defer wg.Done()
+			results[i] = indicator.Health(ctx)
+		}(i, indicator)
+	}
+	wg.Wait()
+	for i, indicator := range indicators {
+		details[indicator.HealthId()] = results[i]
+		up = up && results[i].IsUp()
+	}
+	if up {
+		return flux.NewHealthUp(details)
+	}
+	return flux.NewHealthDown(details)
+}
+
+// checkTransporters 检查各Transporter的初始化状态：初始化失败、已停止，或实现 Initializer 但未完成初始化的Transporter为非就绪状态
+func checkTransporters() flux.Health {
+	transporters := ext.Transporters()
+	details := make(map[string]interface{}, len(transporters))
+	up := true
+	for proto, transporter := range transporters {
+		state := ext.ComponentStateInitialized
+		if rec, ok := ext.ComponentOf(ext.ComponentKindTransporter, proto); ok {
+			state = rec.State
+		}
+		details[proto] = state
+		switch state {
+		case ext.ComponentStateFailed, ext.ComponentStateStopped:
+			up = false
+		case ext.ComponentStateRegistered:
+			if _, ok := transporter.(flux.Initializer); ok {
+				up = false
+			}
+		}
+	}
+	if up {
+		return flux.NewHealthUp(details)
+	}
+	return flux.NewHealthDown(details)
+}
+
+// HealthLiveHandler 存活检查：服务进程可响应请求即为存活
+func (s *BootstrapServer) HealthLiveHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, flux.NewHealthUp(nil))
+}
+
+// HealthReadyHandler 就绪检查：汇总各组件的健康状态；非就绪时返回503
+func (s *BootstrapServer) HealthReadyHandler(webex flux.ServerWebContext) error {
+	health := s.CheckHealth(webex.Context())
+	status := flux.StatusOK
+	if !health.IsUp() {
+		status = flux.StatusUnavailable
+	}
+	return writeJSON(webex, status, health)
+}
@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const protoHealthTest = "HEALTH_TEST"
+
+// staticIndicator 返回固定检查结果的健康检查项
+type staticIndicator struct {
+	id string
+	up bool
+}
+
+func (i *staticIndicator) HealthId() string {
+	return i.id
+}
+
+func (i *staticIndicator) Health(_ context.Context) flux.Health {
+	if i.up {
+		return flux.NewHealthUp(nil)
+	}
+	return flux.NewHealthDown(map[string]interface{}{"error": "connection refused"})
+}
+
+func TestBootstrapServer_CheckHealth(t *testing.T) {
+	assert := assert.New(t)
+	srv := &BootstrapServer{started: make(chan struct{}), healthTimeout: time.Second}
+	close(srv.started)
+	ext.RegisterEndpoint("GET#/health", &flux.Endpoint{HttpMethod: "GET", HttpPattern: "/health"})
+	ext.AddHealthIndicator(&staticIndicator{id: "discovery:health-up", up: true})
+	ext.AddHealthIndicator(&staticIndicator{id: "discovery:health-down", up: false})
+	transporter := new(reloadTransporter)
+	ext.RegisterTransporter(protoHealthTest, transporter)
+
+	// 任意一个检查项失败时，汇总结果为非就绪；其它检查项的结果保持不变
+	health := srv.CheckHealth(context.Background())
+	assert.False(health.IsUp())
+	assert.True(health.Details["server"].(flux.Health).IsUp())
+	assert.True(health.Details["endpoints"].(flux.Health).IsUp())
+	assert.True(health.Details["discovery:health-up"].(flux.Health).IsUp())
+	down := health.Details["discovery:health-down"].(flux.Health)
+	assert.False(down.IsUp())
+	assert.Equal("connection refused", down.Details["error"])
+	// 未完成初始化的Transporter为非就绪状态
+	transporters := health.Details["transporters"].(flux.Health)
+	assert.False(transporters.IsUp())
+	assert.Equal(ext.ComponentStateRegistered, transporters.Details[protoHealthTest])
+
+	ext.MarkInitialized(transporter, nil)
+	transporters = srv.CheckHealth(context.Background()).Details["transporters"].(flux.Health)
+	assert.Equal(ext.ComponentStateInitialized, transporters.Details[protoHealthTest])
+	ext.MarkInitialized(transporter, errors.New("dial timeout"))
+	transporters = srv.CheckHealth(context.Background()).Details["transporters"].(flux.Health)
+	assert.False(transporters.IsUp())
+	assert.Equal(ext.ComponentStateFailed, transporters.Details[protoHealthTest])
+}
@@ -0,0 +1,136 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-pkg"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// hostRoutes 相同WebListener，Method和Pattern的Endpoint按请求Host分组；
+// 匹配顺序：精确Host优先，通配Host按后缀长度优先，未定义Host的Endpoint匹配其它全部Host。
+type hostRoutes struct {
+	routes []hostRoute
+	mu     sync.RWMutex
+}
+
+type hostRoute struct {
+	host string
+	mve  *flux.MVCEndpoint
+}
+
+func newHostRoutes() *hostRoutes {
+	return &hostRoutes{routes: make([]hostRoute, 0, 2)}
+}
+
+// add 添加或替换Host对应的Endpoint
+func (h *hostRoutes) add(host string, mve *flux.MVCEndpoint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range h.routes {
+		if h.routes[i].host == host {
+			h.routes[i].mve = mve
+			return
+		}
+	}
+	h.routes = append(h.routes, hostRoute{host: host, mve: mve})
+	sort.SliceStable(h.routes, func(i, j int) bool {
+		return hostRank(h.routes[i].host) > hostRank(h.routes[j].host)
+	})
+}
+
+// match 按请求Host选择Endpoint
+func (h *hostRoutes) match(host string) (*flux.MVCEndpoint, bool) {
+	if hostname, _, err := net.SplitHostPort(host); nil == err {
+		host = hostname
+	}
+	host = strings.ToLower(host)
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, route := range h.routes {
+		if fluxpkg.MatchHostPattern(route.host, host) && !route.mve.IsEmpty() {
+			return route.mve, true
+		}
+	}
+	return nil, false
+}
+
+func hostRank(host string) int {
+	switch {
+	case host == "":
+		return 0
+	case strings.HasPrefix(host, "*."):
+		return len(host)
+	default:
+		return 1 << 16
+	}
+}
+
+// routeKeyOf 返回Endpoint的路由注册Key：Method#Pattern；定义Host时追加 @host1,host2
+func routeKeyOf(method, pattern string, hosts []string) string {
+	key := method + "#" + pattern
+	if len(hosts) > 0 {
+		key += "@" + strings.Join(hosts, ",")
+	}
+	return key
+}
+
+// splitRouteKey 解析路由注册Key，返回 Method#Pattern 及Host列表
+func splitRouteKey(routeKey string) (string, []string) {
+	if idx := strings.LastIndexByte(routeKey, '@'); idx > 0 {
+		return routeKey[:idx], strings.Split(routeKey[idx+1:], ",")
+	}
+	return routeKey, nil
+}
+
+// bindHostRoute 绑定Endpoint到WebListener；相同Method和Pattern在WebListener中只注册一次路由，按请求Host选择Endpoint
+func (s *BootstrapServer) bindHostRoute(server flux.WebListener, method, pattern string, hosts []string, bind *flux.MVCEndpoint) {
+	key := hostRouteKey(server.ListenerId(), method, pattern)
+	s.hostsmu.Lock()
+	routes, ok := s.hosts[key]
+	if !ok {
+		routes = newHostRoutes()
+		s.hosts[key] = routes
+	}
+	s.hostsmu.Unlock()
+	if !ok {
+		server.AddHandler(method, pattern, s.newHostRouteHandler(server, routes))
+	}
+	if len(hosts) == 0 {
+		routes.add("", bind)
+	}
+	for _, host := range hosts {
+		routes.add(host, bind)
+	}
+}
+
+// lookupHostRoutes 按WebListener路由表匹配的路由模式，查找绑定的Endpoint分组
+func (s *BootstrapServer) lookupHostRoutes(listenerId, method, pattern string) (*hostRoutes, bool) {
+	s.hostsmu.RLock()
+	defer s.hostsmu.RUnlock()
+	routes, ok := s.hosts[hostRouteKey(listenerId, method, pattern)]
+	return routes, ok
+}
+
+// hostRouteKey 返回WebListener路由的Key；路径参数 {name} 与 :name 为相同的路由
+func hostRouteKey(listenerId, method, pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			segments[i] = ":" + seg[1:len(seg)-1]
+		}
+	}
+	return strings.ToLower(listenerId) + "#" + method + "#" + strings.Join(segments, "/")
+}
+
+func (s *BootstrapServer) newHostRouteHandler(server flux.WebListener, routes *hostRoutes) flux.WebHandler {
+	return func(webex flux.ServerWebContext) error {
+		mve, ok := routes.match(webex.Host())
+		if !ok {
+			return server.HandleNotfound(webex)
+		}
+		return s.route(webex, server, mve)
+	}
+}
@@ -18,7 +18,6 @@ func init() {
 	serializer := flux.NewJsonSerializer()
 	ext.RegisterSerializer(ext.TypeNameSerializerDefault, serializer)
 	ext.RegisterSerializer(ext.TypeNameSerializerJson, serializer)
-	// Endpoint discovery
-	ext.RegisterEndpointDiscovery(discovery.NewZookeeperServiceWith(discovery.ZookeeperId))
+	// Endpoint discovery; zookeeper 见 module_zookeeper.go
 	ext.RegisterEndpointDiscovery(discovery.NewResourceServiceWith(discovery.ResourceId))
 }
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/accesslog"
+	"github.com/bytepowered/flux/flux-node/tracing"
+	"io/ioutil"
+)
+
+const (
+	// 审计日志配置：enable，max_pending，wait_timeout，body，body_limit，encrypt_key，fsync，sinks
+	ConfigNsJournal = "journal"
+)
+
+const (
+	// Endpoint属性：审计关键Endpoint，响应客户端之前同步写入审计日志
+	EndpointAttrTagJournal = "journal"
+)
+
+const (
+	ErrorCodeJournalUnavailable = "GATEWAY:JOURNAL_UNAVAILABLE"
+)
+
+// WithJournalSink 添加审计日志的输出目标，例如远程审计存储
+func WithJournalSink(sink accesslog.Sink) Option {
+	return func(bs *BootstrapServer) {
+		bs.journal.AddSink(sink)
+	}
+}
+
+// initJournal 加载审计日志配置；未开启时不创建审计日志组件
+func (s *BootstrapServer) initJournal() error {
+	config := flux.NewConfigurationOfNS(ConfigNsJournal)
+	if !config.GetBool("enable") {
+		s.journal = nil
+		return nil
+	}
+	return s.dispatcher.AddInitHook(s.journal, config)
+}
+
+// journaled 判断Endpoint是否需要写入审计日志
+func (s *BootstrapServer) journaled(endpoint *flux.Endpoint) bool {
+	return nil != s.journal && endpoint.GetAttr(EndpointAttrTagJournal).GetBool()
+}
+
+// journalHook 返回写入审计日志的响应处理函数；写入失败时拒绝响应，返回503错误，保证已响应的请求均有审计记录
+func (s *BootstrapServer) journalHook(listenerId string, written *bool) flux.ResponseHookFunc {
+	return func(ctx *flux.Context, response *flux.ResponseBody) error {
+		*written = true
+		if err := s.appendJournal(ctx, listenerId, response.StatusCode, ""); nil != err {
+			ctx.Logger().Errorw("SERVER:JOURNAL:APPEND/ERROR", "error", err)
+			return &flux.ServeError{
+				StatusCode: flux.StatusUnavailable,
+				ErrorCode:  ErrorCodeJournalUnavailable,
+				Message:    "SERVER:JOURNAL:UNAVAILABLE",
+				CauseError: err,
+			}
+		}
+		return nil
+	}
+}
+
+func (s *BootstrapServer) appendJournal(ctx *flux.Context, listenerId string, status int, errorCode string) error {
+	endpoint := ctx.Endpoint()
+	entry := &accesslog.JournalEntry{
+		Time:       ctx.StartAt(),
+		RequestId:  ctx.RequestId(),
+		ListenerId: listenerId,
+		RemoteAddr: flux.RealIP(ctx),
+		Method:     ctx.Method(),
+		URI:        ctx.URI(),
+		Pattern:    endpoint.HttpPattern,
+		Version:    endpoint.Version,
+		ServiceId:  endpoint.Service.ServiceID(),
+		Status:     status,
+		ErrorCode:  errorCode,
+	}
+	if span := tracing.CurrentSpan(ctx); span != nil {
+		entry.TraceId = span.Context.TraceID.String()
+	}
+	var body []byte
+	if s.journal.RecordBody() {
+		if reader, err := ctx.BodyReader(); nil == err {
+			body, _ = ioutil.ReadAll(reader)
+			_ = reader.Close()
+		}
+	}
+	return s.journal.Append(entry, body)
+}
@@ -0,0 +1,253 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// 生命周期配置：parallel，depends
+	ConfigNsLifecycle = "lifecycle"
+)
+
+// Lifecycle 组件启动与停止的生命周期管理：
+// 1. 按 Orderer 声明的顺序分组，顺序小的组先启动；
+// 2. 组内按 flux.Dependent 及配置 lifecycle.depends 声明的依赖关系分层，依赖的组件先启动，同层组件按注册顺序启动（开启 parallel 时并行启动）；
+// 3. 停止时按启动计划的相反顺序，逐个停止组件。
+type Lifecycle struct {
+	parallel bool
+	depends  map[string][]string
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{
+		parallel: false,
+		depends:  make(map[string][]string, 0),
+	}
+}
+
+func (l *Lifecycle) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		"parallel": false,
+	})
+	l.parallel = config.GetBool("parallel")
+	l.depends = make(map[string][]string, 4)
+	for name, deps := range config.GetStringMap("depends") {
+		l.depends[name] = cast.ToStringSlice(deps)
+	}
+	return nil
+}
+
+type lifecycleNode struct {
+	names []string
+	ref   interface{}
+	order int
+	deps  []*lifecycleNode
+	wave  int
+}
+
+func (n *lifecycleNode) String() string {
+	return n.names[0]
+}
+
+// Startup 按启动计划启动组件；同层组件全部完成后再启动下一层，任一组件启动失败时返回错误
+func (l *Lifecycle) Startup(hooks []flux.Startuper) error {
+	refs := make([]interface{}, len(hooks))
+	for i, v := range hooks {
+		refs[i] = v
+	}
+	waves, err := l.plan(refs)
+	if nil != err {
+		return err
+	}
+	for i, wave := range waves {
+		names := make([]string, len(wave))
+		for idx, node := range wave {
+			names[idx] = node.String()
+		}
+		logger.Infow("SERVER:LIFECYCLE:STARTUP", "wave", i, "components", names)
+		errs := make([]error, len(wave))
+		startup := func(idx int) {
+			node := wave[idx]
+			errs[idx] = node.ref.(flux.Startuper).Startup()
+			ext.MarkStarted(node.ref, errs[idx])
+		}
+		if l.parallel && len(wave) > 1 {
+			var wg sync.WaitGroup
+			for idx := range wave {
+				wg.Add(1)
+				go func(idx int) {
+					defer wg.Done()
+					startup(idx)
+				}(idx)
+			}
+			wg.Wait()
+		} else {
+			for idx := range wave {
+				startup(idx)
+				if nil != errs[idx] {
+					break
+				}
+			}
+		}
+		for idx, err := range errs {
+			if nil != err {
+				return fmt.Errorf("startup component: %s, err: %w", wave[idx], err)
+			}
+		}
+	}
+	return nil
+}
+
+// Shutdown 按启动计划的相反顺序逐个停止组件；停止失败时记录日志并继续停止其它组件，返回第一个错误
+func (l *Lifecycle) Shutdown(ctx context.Context, hooks []flux.Shutdowner) error {
+	refs := make([]interface{}, len(hooks))
+	for i, v := range hooks {
+		refs[i] = v
+	}
+	waves, err := l.plan(refs)
+	if nil != err {
+		return err
+	}
+	var first error
+	for i := len(waves) - 1; i >= 0; i-- {
+		for j := len(waves[i]) - 1; j >= 0; j-- {
+			node := waves[i][j]
+			err := node.ref.(flux.Shutdowner).Shutdown(ctx)
+			ext.MarkStopped(node.ref, err)
+			if nil != err {
+				logger.Warnw("SERVER:LIFECYCLE:SHUTDOWN/ERROR", "component", node, "error", err)
+				if nil == first {
+					first = fmt.Errorf("shutdown component: %s, err: %w", node, err)
+				}
+			}
+		}
+	}
+	return first
+}
+
+// plan 计算启动计划：返回按启动先后排列的组件分层，同层组件按注册顺序排列
+func (l *Lifecycle) plan(refs []interface{}) ([][]*lifecycleNode, error) {
+	nodes := make([]*lifecycleNode, len(refs))
+	for i, ref := range refs {
+		names := ext.ComponentNamesOf(ref)
+		if len(names) == 0 {
+			names = []string{ext.ComponentKindComponent + ":" + reflect.TypeOf(ref).String()}
+		}
+		nodes[i] = &lifecycleNode{names: names, ref: ref, order: orderOf(ref), wave: -1}
+	}
+	// 解析依赖声明
+	for _, node := range nodes {
+		declared := make([]string, 0, 4)
+		if dep, ok := node.ref.(flux.Dependent); ok {
+			declared = append(declared, dep.DependsOn()...)
+		}
+		for pattern, deps := range l.depends {
+			if node.matches(pattern) {
+				declared = append(declared, deps...)
+			}
+		}
+		for _, pattern := range declared {
+			matched := matchNodes(nodes, pattern, node)
+			if len(matched) == 0 {
+				logger.Warnw("SERVER:LIFECYCLE:DEPENDENCY/MISSING", "component", node, "depends-on", pattern)
+				continue
+			}
+			for _, dep := range matched {
+				if dep.order > node.order {
+					return nil, fmt.Errorf("lifecycle dependency conflicts with order, component: %s(order: %d), depends-on: %s(order: %d)",
+						node, node.order, dep, dep.order)
+				}
+				node.deps = append(node.deps, dep)
+			}
+		}
+	}
+	// 按Order分组，组内按依赖分层
+	sorted := make([]*lifecycleNode, len(nodes))
+	copy(sorted, nodes)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].order < sorted[j].order
+	})
+	base, top := 0, -1
+	for i, node := range sorted {
+		if i > 0 && node.order != sorted[i-1].order {
+			base = top + 1
+		}
+		wave, err := node.resolve(base, make(map[*lifecycleNode]bool, 4))
+		if nil != err {
+			return nil, err
+		}
+		if wave > top {
+			top = wave
+		}
+	}
+	waves := make([][]*lifecycleNode, top+1)
+	for _, node := range nodes {
+		waves[node.wave] = append(waves[node.wave], node)
+	}
+	out := make([][]*lifecycleNode, 0, len(waves))
+	for _, wave := range waves {
+		if len(wave) > 0 {
+			out = append(out, wave)
+		}
+	}
+	return out, nil
+}
+
+// resolve 计算组件的启动层级：不小于所在分组的起始层级，且大于全部依赖组件的层级
+func (n *lifecycleNode) resolve(base int, visiting map[*lifecycleNode]bool) (int, error) {
+	if n.wave >= 0 {
+		return n.wave, nil
+	}
+	if visiting[n] {
+		return 0, fmt.Errorf("lifecycle dependency cycle detected, component: %s", n)
+	}
+	visiting[n] = true
+	wave := base
+	for _, dep := range n.deps {
+		// 依赖组件位于之前的分组时已计算层级；同组时按相同的起始层级计算
+		w, err := dep.resolve(base, visiting)
+		if nil != err {
+			return 0, err
+		}
+		if w+1 > wave {
+			wave = w + 1
+		}
+	}
+	delete(visiting, n)
+	n.wave = wave
+	return wave, nil
+}
+
+// matches 判断组件名称是否匹配（不区分大小写）：<kind>:<id>，或 <kind>:* 匹配该类型全部组件
+func (n *lifecycleNode) matches(pattern string) bool {
+	pattern = strings.ToLower(pattern)
+	for _, name := range n.names {
+		name = strings.ToLower(name)
+		if name == pattern {
+			return true
+		}
+		if strings.HasSuffix(pattern, ":*") && strings.HasPrefix(name, pattern[:len(pattern)-1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchNodes(nodes []*lifecycleNode, pattern string, self *lifecycleNode) []*lifecycleNode {
+	out := make([]*lifecycleNode, 0, 2)
+	for _, node := range nodes {
+		if node != self && node.matches(pattern) {
+			out = append(out, node)
+		}
+	}
+	return out
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// lcBase 记录启动及停止顺序的测试组件
+type lcBase struct {
+	name  string
+	order int
+	deps  []string
+	err   error
+	log   *[]string
+}
+
+func (c *lcBase) Order() int {
+	return c.order
+}
+
+func (c *lcBase) DependsOn() []string {
+	return c.deps
+}
+
+func (c *lcBase) Startup() error {
+	*c.log = append(*c.log, "start:"+c.name)
+	return c.err
+}
+
+func (c *lcBase) Shutdown(_ context.Context) error {
+	*c.log = append(*c.log, "stop:"+c.name)
+	return nil
+}
+
+func (c *lcBase) lcName() string {
+	return c.name
+}
+
+type lcNamed interface {
+	lcName() string
+}
+
+type lcA struct{ lcBase }
+type lcB struct{ lcBase }
+type lcC struct{ lcBase }
+type lcD struct{ lcBase }
+
+func newLifecycle(t *testing.T, config map[string]interface{}) *Lifecycle {
+	lc := NewLifecycle()
+	assert.NoError(t, lc.Init(flux.NewConfigurationOfMap(config)))
+	return lc
+}
+
+func waveNames(waves [][]*lifecycleNode) [][]string {
+	out := make([][]string, len(waves))
+	for i, wave := range waves {
+		for _, node := range wave {
+			out[i] = append(out[i], node.ref.(lcNamed).lcName())
+		}
+	}
+	return out
+}
+
+func TestLifecycle_Plan(t *testing.T) {
+	assert := assert.New(t)
+	var log []string
+	a := &lcA{lcBase{name: "a", log: &log}}
+	b := &lcB{lcBase{name: "b", log: &log, deps: []string{"component:*server.lcA"}}}
+	c := &lcC{lcBase{name: "c", log: &log}}
+	d := &lcD{lcBase{name: "d", log: &log, order: 1}}
+
+	// 无依赖声明：同一Order分组为一层，按注册顺序排列
+	waves, err := newLifecycle(t, map[string]interface{}{}).plan([]interface{}{c, d, a})
+	assert.NoError(err)
+	assert.Equal([][]string{{"c", "a"}, {"d"}}, waveNames(waves))
+
+	// flux.Dependent 及配置声明的依赖（不区分大小写，支持 <kind>:* 通配）
+	lc := newLifecycle(t, map[string]interface{}{
+		"depends": map[string]interface{}{"component:*server.lcC": []string{"COMPONENT:*SERVER.LCB"}},
+	})
+	waves, err = lc.plan([]interface{}{c, b, d, a})
+	assert.NoError(err)
+	assert.Equal([][]string{{"a"}, {"b"}, {"c"}, {"d"}}, waveNames(waves))
+	waves, err = newLifecycle(t, map[string]interface{}{
+		"depends": map[string]interface{}{"component:*server.lcC": []string{"component:*"}},
+	}).plan([]interface{}{c, a})
+	assert.NoError(err)
+	assert.Equal([][]string{{"a"}, {"c"}}, waveNames(waves))
+	// 缺失的依赖被忽略
+	waves, err = newLifecycle(t, map[string]interface{}{
+		"depends": map[string]interface{}{"component:*server.lcC": []string{"filter:missing"}},
+	}).plan([]interface{}{c, a})
+	assert.NoError(err)
+	assert.Equal([][]string{{"c", "a"}}, waveNames(waves))
+}
+
+func TestLifecycle_PlanErrors(t *testing.T) {
+	assert := assert.New(t)
+	var log []string
+	a := &lcA{lcBase{name: "a", log: &log, deps: []string{"component:*server.lcB"}}}
+	b := &lcB{lcBase{name: "b", log: &log, deps: []string{"component:*server.lcA"}}}
+	_, err := newLifecycle(t, map[string]interface{}{}).plan([]interface{}{a, b})
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "cycle")
+	}
+	// 依赖Order更大的组件
+	c := &lcC{lcBase{name: "c", log: &log, deps: []string{"component:*server.lcD"}}}
+	d := &lcD{lcBase{name: "d", log: &log, order: 1}}
+	_, err = newLifecycle(t, map[string]interface{}{}).plan([]interface{}{c, d})
+	if assert.Error(err) {
+		assert.Contains(err.Error(), "conflicts with order")
+	}
+}
+
+func TestLifecycle_StartupShutdown(t *testing.T) {
+	assert := assert.New(t)
+	var log []string
+	a := &lcA{lcBase{name: "a", log: &log}}
+	b := &lcB{lcBase{name: "b", log: &log, deps: []string{"component:*server.lcA"}}}
+	c := &lcC{lcBase{name: "c", log: &log}}
+	lc := newLifecycle(t, map[string]interface{}{})
+	assert.False(lc.parallel)
+	assert.NoError(lc.Startup([]flux.Startuper{b, c, a}))
+	assert.Equal([]string{"start:c", "start:a", "start:b"}, log)
+	log = log[:0]
+	assert.NoError(lc.Shutdown(context.TODO(), []flux.Shutdowner{b, c, a}))
+	assert.Equal([]string{"stop:b", "stop:a", "stop:c"}, log)
+
+	// 启动失败时，不再启动同层及之后的组件
+	log = log[:0]
+	c.err = errors.New("failed")
+	assert.Error(lc.Startup([]flux.Startuper{b, c, a}))
+	assert.Equal([]string{"start:c"}, log)
+}
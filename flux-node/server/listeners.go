@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"sort"
+	"time"
+)
+
+const (
+	// WebListener启动配置：bind_retries，bind_retry_interval
+	ConfigNsListenerStartup = "listener_startup"
+)
+
+const (
+	ConfigKeyBindRetries       = "bind_retries"
+	ConfigKeyBindRetryInterval = "bind_retry_interval"
+)
+
+// ListenerAddress WebListener已绑定的监听地址
+type ListenerAddress struct {
+	Id        string   `json:"id"`
+	Addresses []string `json:"addresses"`
+}
+
+// startListeners 先绑定全部WebListener的监听地址，再启动服务；
+// 绑定失败时按配置重试，重试后仍失败则关闭已绑定的地址并返回错误，服务启动中止；
+// 返回的Channel接收各WebListener服务结束的错误。
+func (s *BootstrapServer) startListeners() (<-chan error, error) {
+	config := flux.NewConfigurationOfNS(ConfigNsListenerStartup)
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyBindRetries:       0,
+		ConfigKeyBindRetryInterval: time.Second,
+	})
+	retries, interval := config.GetInt(ConfigKeyBindRetries), config.GetDuration(ConfigKeyBindRetryInterval)
+	bound := make([]flux.WebListener, 0, len(s.listener))
+	for _, id := range s.listenerIds() {
+		wl := s.listener[id]
+		bindable, ok := wl.(flux.BindableListener)
+		if !ok {
+			continue
+		}
+		err := bindable.Bind()
+		for i := 0; nil != err && i < retries; i++ {
+			logger.WithModule(logger.ModuleServer).Warnw("SERVER:START:LISTENER:BIND/RETRY", "listener-id", id, "retry", i+1, "error", err)
+			time.Sleep(interval)
+			err = bindable.Bind()
+		}
+		if nil != err {
+			for _, b := range bound {
+				if cerr := b.Close(context.Background()); nil != cerr {
+					logger.WithModule(logger.ModuleServer).Warnw("SERVER:START:LISTENER:CLOSE/ERROR", "listener-id", b.ListenerId(), "error", cerr)
+				}
+			}
+			return nil, fmt.Errorf("listener bind failed, listener-id: %s, err: %w", id, err)
+		}
+		logger.WithModule(logger.ModuleServer).Infow("SERVER:START:LISTENER:BOUND", "listener-id", id, "addresses", bindable.BoundAddrs())
+		bound = append(bound, wl)
+	}
+	errch := make(chan error, len(s.listener))
+	for lid, wl := range s.listener {
+		logger.WithModule(logger.ModuleServer).Infow("SERVER:START:LISTENER:START", "listener-id", wl.ListenerId())
+		go func(id string, server flux.WebListener) {
+			errch <- server.Listen()
+			logger.WithModule(logger.ModuleServer).Infow("SERVER:START:LISTENER:STOP", "listener-id", id)
+		}(lid, wl)
+	}
+	return errch, nil
+}
+
+// ListenerAddresses 返回各WebListener已绑定的监听地址；用于测试及部署检查绑定端口为0时实际分配的端口
+func (s *BootstrapServer) ListenerAddresses() []ListenerAddress {
+	out := make([]ListenerAddress, 0, len(s.listener))
+	for _, id := range s.listenerIds() {
+		address := ListenerAddress{Id: id, Addresses: []string{}}
+		if bindable, ok := s.listener[id].(flux.BindableListener); ok {
+			address.Addresses = bindable.BoundAddrs()
+		}
+		out = append(out, address)
+	}
+	return out
+}
+
+// ListenerAddressesHandler 查询WebListener监听地址的管理接口
+func (s *BootstrapServer) ListenerAddressesHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.ListenerAddresses())
+}
+
+func (s *BootstrapServer) listenerIds() []string {
+	ids := make([]string, 0, len(s.listener))
+	for id := range s.listener {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
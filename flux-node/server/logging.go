@@ -0,0 +1,81 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"io/ioutil"
+)
+
+const (
+	// 运行时日志级别配置：level，modules
+	ConfigNsLogging = "logging"
+)
+
+const (
+	ConfigKeyLoggingLevel   = "level"
+	ConfigKeyLoggingModules = "modules"
+)
+
+// LoggingLevels 日志级别管理接口的请求及响应：全局日志级别，模块日志级别；模块级别为空时恢复使用全局日志级别
+type LoggingLevels struct {
+	Level   string            `json:"level,omitempty"`
+	Modules map[string]string `json:"modules,omitempty"`
+}
+
+// initLogging 按配置设置全局及模块日志级别；未配置全局日志级别时使用日志配置文件中的级别
+func (s *BootstrapServer) initLogging() error {
+	config := flux.NewConfigurationOfNS(ConfigNsLogging)
+	return applyLoggingLevels(LoggingLevels{
+		Level:   config.GetString(ConfigKeyLoggingLevel),
+		Modules: cast.ToStringMapString(config.Get(ConfigKeyLoggingModules)),
+	})
+}
+
+func applyLoggingLevels(levels LoggingLevels) error {
+	if levels.Level != "" {
+		if err := logger.SetLevel(levels.Level); nil != err {
+			return err
+		}
+	}
+	for module, level := range levels.Modules {
+		if err := logger.SetModuleLevel(module, level); nil != err {
+			return err
+		}
+	}
+	logger.Infow("SERVER:LOGGING:LEVELS", "level", logger.GetLevel(), "modules", logger.ModuleLevels())
+	return nil
+}
+
+// LoggingHandler 查询日志级别的管理接口
+func (s *BootstrapServer) LoggingHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, LoggingLevels{
+		Level:   logger.GetLevel(),
+		Modules: logger.ModuleLevels(),
+	})
+}
+
+// LoggingUpdateHandler 运行时修改日志级别的管理接口；请求Body为JSON格式的 LoggingLevels
+func (s *BootstrapServer) LoggingUpdateHandler(webex flux.ServerWebContext) error {
+	reader, err := webex.BodyReader()
+	if nil != err {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	data, err := ioutil.ReadAll(reader)
+	_ = reader.Close()
+	if nil != err {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	var req LoggingLevels
+	if err := ext.JSONUnmarshal(data, &req); nil != err {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": "invalid request: " + err.Error()})
+	}
+	before := LoggingLevels{Level: logger.GetLevel(), Modules: logger.ModuleLevels()}
+	if err := applyLoggingLevels(req); nil != err {
+		return writeJSON(webex, flux.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	after := LoggingLevels{Level: logger.GetLevel(), Modules: logger.ModuleLevels()}
+	s.auditChanged(webex, AuditActionLoggingChange, "", before, after)
+	return writeJSON(webex, flux.StatusOK, after)
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// verifyContentType 检查请求的Content-Type是否为Endpoint接受的媒体类型；
+// Endpoint未定义 accepts 属性，或者请求没有Body数据时，不作检查。
+func verifyContentType(ctx *flux.Context) *flux.ServeError {
+	accepts := ctx.Endpoint().GetAttr(flux.EndpointAttrTagAccepts).GetStringSlice()
+	if len(accepts) == 0 {
+		return nil
+	}
+	request := ctx.Request()
+	if request.ContentLength == 0 || request.Body == nil || request.Body == http.NoBody {
+		return nil
+	}
+	// 未声明长度的Chunked请求，视为有Body数据
+	contentType := request.Header.Get(flux.HeaderContentType)
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if nil == err && matchMediaTypes(accepts, mediaType) {
+		return nil
+	}
+	logger.TraceContext(ctx).Infow("SERVER:ROUTE:UNSUPPORTED_MEDIA_TYPE", "content-type", contentType, "accepts", accepts)
+	return &flux.ServeError{
+		StatusCode: flux.StatusUnsupported,
+		ErrorCode:  flux.ErrorCodeRequestMediaType,
+		Message:    "ROUTE:UNSUPPORTED_MEDIA_TYPE",
+		Header:     http.Header{flux.HeaderAccept: {strings.Join(accepts, ", ")}},
+	}
+}
+
+// matchMediaTypes 匹配媒体类型；支持 */* 和 type/* 通配
+func matchMediaTypes(accepts []string, mediaType string) bool {
+	mediaType = strings.ToLower(mediaType)
+	for _, accept := range accepts {
+		accept = strings.ToLower(strings.TrimSpace(accept))
+		if accept == "*/*" || accept == mediaType {
+			return true
+		}
+		if strings.HasSuffix(accept, "/*") && strings.HasPrefix(mediaType, accept[:len(accept)-1]) {
+			return true
+		}
+	}
+	return false
+}
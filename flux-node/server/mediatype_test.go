@@ -0,0 +1,131 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/listener"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	bodyNone = iota
+	bodyEmpty
+	bodySized
+	bodyChunked
+)
+
+func newMediaTypeContext(accepts []string, contentType string, body int) (*flux.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(http.MethodPost, "http://gateway/users", nil)
+	switch body {
+	case bodyNone:
+		request.Body, request.ContentLength = http.NoBody, 0
+	case bodyEmpty:
+		request.Body, request.ContentLength = ioutil.NopCloser(strings.NewReader("")), 0
+	case bodySized:
+		request.Body, request.ContentLength = ioutil.NopCloser(strings.NewReader(`{"id":1}`)), 8
+	case bodyChunked:
+		request.Body, request.ContentLength = ioutil.NopCloser(strings.NewReader(`{"id":1}`)), -1
+		request.TransferEncoding = []string{"chunked"}
+	}
+	if contentType != "" {
+		request.Header.Set(flux.HeaderContentType, contentType)
+	}
+	var attrs []flux.Attribute
+	if nil != accepts {
+		attrs = append(attrs, flux.Attribute{Name: flux.EndpointAttrTagAccepts, Value: accepts})
+	}
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("mediatype", request, nil, nil), &flux.Endpoint{
+		HttpPattern:        "/users",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+	})
+	ctx.SetResponseWriter(recorder)
+	return ctx, recorder
+}
+
+func TestMatchMediaTypes(t *testing.T) {
+	cases := []struct {
+		accepts   []string
+		mediaType string
+		match     bool
+	}{
+		{accepts: []string{"application/json"}, mediaType: "application/json", match: true},
+		{accepts: []string{" Application/JSON "}, mediaType: "application/json", match: true},
+		{accepts: []string{"application/json"}, mediaType: "APPLICATION/JSON", match: true},
+		{accepts: []string{"application/json"}, mediaType: "application/xml", match: false},
+		{accepts: []string{"text/*"}, mediaType: "text/plain", match: true},
+		{accepts: []string{"text/*"}, mediaType: "text/csv", match: true},
+		{accepts: []string{"text/*"}, mediaType: "textual/plain", match: false},
+		{accepts: []string{"text/*"}, mediaType: "application/text", match: false},
+		{accepts: []string{"application/json", "multipart/*"}, mediaType: "multipart/form-data", match: true},
+		{accepts: []string{"*/*"}, mediaType: "image/png", match: true},
+		{accepts: []string{}, mediaType: "application/json", match: false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.match, matchMediaTypes(tc.accepts, tc.mediaType), strings.Join(tc.accepts, ",")+" <- "+tc.mediaType)
+	}
+}
+
+func TestVerifyContentType(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	cases := []struct {
+		name        string
+		accepts     []string
+		contentType string
+		body        int
+		rejected    bool
+	}{
+		{name: "no-accepts", contentType: "image/png", body: bodySized},
+		{name: "match", accepts: []string{"application/json"}, contentType: "application/json", body: bodySized},
+		{name: "mismatch", accepts: []string{"application/json"}, contentType: "application/xml", body: bodySized, rejected: true},
+		// Content-Type参数不参与匹配
+		{name: "params", accepts: []string{"application/json"}, contentType: "application/json; charset=UTF-8", body: bodySized},
+		{name: "params-mismatch", accepts: []string{"application/json"}, contentType: "text/plain; charset=utf-8", body: bodySized, rejected: true},
+		{name: "wildcard", accepts: []string{"text/*"}, contentType: "text/csv; header=present", body: bodySized},
+		{name: "wildcard-mismatch", accepts: []string{"text/*"}, contentType: "application/json", body: bodySized, rejected: true},
+		{name: "malformed", accepts: []string{"*/*"}, contentType: "json;;", body: bodySized, rejected: true},
+		// 没有Body数据时不作检查
+		{name: "no-body", accepts: []string{"application/json"}, body: bodyNone},
+		{name: "empty-body", accepts: []string{"application/json"}, body: bodyEmpty},
+		{name: "empty-body-mismatch", accepts: []string{"application/json"}, contentType: "application/xml", body: bodyEmpty},
+		// 有Body数据，但未声明Content-Type
+		{name: "sized-no-type", accepts: []string{"application/json"}, body: bodySized, rejected: true},
+		{name: "chunked-no-type", accepts: []string{"application/json"}, body: bodyChunked, rejected: true},
+		{name: "chunked", accepts: []string{"application/json"}, contentType: "application/json", body: bodyChunked},
+	}
+	for _, tc := range cases {
+		ctx, _ := newMediaTypeContext(tc.accepts, tc.contentType, tc.body)
+		serr := verifyContentType(ctx)
+		if !tc.rejected {
+			assert.Nil(t, serr, tc.name)
+			continue
+		}
+		if assert.NotNil(t, serr, tc.name) {
+			assert.Equal(t, flux.StatusUnsupported, serr.StatusCode, tc.name)
+			assert.Equal(t, flux.ErrorCodeRequestMediaType, serr.ErrorCode, tc.name)
+		}
+	}
+}
+
+func TestVerifyContentType_AcceptHeader(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	assert := assert.New(t)
+	ctx, recorder := newMediaTypeContext([]string{"application/json", "text/*"}, "application/xml", bodySized)
+	serr := verifyContentType(ctx)
+	if !assert.NotNil(serr) {
+		return
+	}
+	// 415响应通过Accept声明Endpoint接受的媒体类型
+	listener.DefaultErrorHandler(ctx, serr)
+	assert.Equal(http.StatusUnsupportedMediaType, recorder.Code)
+	assert.Equal("application/json, text/*", recorder.Header().Get(flux.HeaderAccept))
+}
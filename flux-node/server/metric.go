@@ -1,8 +1,22 @@
 package server
 
 import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	fluxmetrics "github.com/bytepowered/flux/flux-node/metrics"
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// 指标输出配置：sinks，statsd
+	ConfigNsMetrics = "metrics"
+)
+
+const (
+	ConfigKeyMetricsSinks  = "sinks"
+	ConfigKeyMetricsStatsd = "statsd"
 )
 
 var (
@@ -27,34 +41,173 @@ var (
 		20.0,
 		30.0,
 	}
+	// 256B ~ 4MB
+	defaultMetricSizeBuckets = prometheus.ExponentialBuckets(256, 4, 8)
 )
 
 type Metrics struct {
-	EndpointAccess *prometheus.CounterVec
-	EndpointError  *prometheus.CounterVec
-	RouteDuration  *prometheus.HistogramVec
+	hub            *fluxmetrics.Hub
+	prometheus     *fluxmetrics.PrometheusSink
+	EndpointAccess flux.CounterVec
+	EndpointError  flux.CounterVec
+	RouteDuration  flux.HistogramVec
+	RequestSize    flux.HistogramVec
+	ResponseSize   flux.HistogramVec
+	Validation     flux.CounterVec
+	FilterTimeout  flux.CounterVec
+	FilterPanic    flux.CounterVec
+	FilterDuration flux.HistogramVec
+	ShadowAccess   flux.CounterVec
+	UpstreamHealth flux.GaugeVec
+	UpstreamCheck  flux.CounterVec
+	TenantAccess   flux.CounterVec
+	// 并发请求数限制
+	ConcurrencyInflight flux.GaugeVec
+	ConcurrencyRejected flux.CounterVec
 }
 
 func NewMetrics() *Metrics {
+	// 默认输出到Prometheus；其它输出目标在 Init 时按配置绑定
+	prom := fluxmetrics.NewPrometheusSink()
+	hub := fluxmetrics.NewHub(prom)
 	return &Metrics{
-		EndpointAccess: promauto.NewCounterVec(prometheus.CounterOpts{
+		hub:        hub,
+		prometheus: prom,
+		EndpointAccess: hub.NewCounter(flux.MetricOpts{
 			Namespace: defaultMetricNamespace,
 			Subsystem: defaultMetricSubsystem,
 			Name:      "endpoint_access_total",
 			Help:      "Number of endpoint access",
-		}, []string{"ProtoName", "Interface", "Method"}),
-		EndpointError: promauto.NewCounterVec(prometheus.CounterOpts{
+			Labels:    []string{"ProtoName", "Interface", "Method", "HttpPattern", "Version"},
+		}),
+		EndpointError: hub.NewCounter(flux.MetricOpts{
 			Namespace: defaultMetricNamespace,
 			Subsystem: defaultMetricSubsystem,
 			Name:      "endpoint_error_total",
 			Help:      "Number of endpoint access errors",
-		}, []string{"ProtoName", "Interface", "Method", "ErrorCode"}),
-		RouteDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
+			Labels:    []string{"ProtoName", "Interface", "Method", "ErrorCode", "HttpPattern", "Version"},
+		}),
+		RouteDuration: hub.NewHistogram(flux.MetricOpts{
 			Namespace: defaultMetricNamespace,
 			Subsystem: defaultMetricSubsystem,
 			Name:      "endpoint_route_duration",
 			Help:      "Spend time by processing a endpoint",
 			Buckets:   defaultMetricBuckets,
-		}, []string{"ComponentType", "TypeId"}),
+			Labels:    []string{"ComponentType", "TypeId", "HttpPattern", "Version"},
+		}),
+		RequestSize: hub.NewHistogram(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "transport_request_bytes",
+			Help:      "Size of request body transported to backend service",
+			Buckets:   defaultMetricSizeBuckets,
+			Labels:    []string{"ProtoName", "Interface", "Method"},
+		}),
+		ResponseSize: hub.NewHistogram(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "transport_response_bytes",
+			Help:      "Size of response body returned by backend service",
+			Buckets:   defaultMetricSizeBuckets,
+			Labels:    []string{"ProtoName", "Interface", "Method"},
+		}),
+		Validation: hub.NewCounter(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "endpoint_validation_total",
+			Help:      "Number of endpoint registration events validated, by result",
+			Labels:    []string{"Source", "Result"},
+		}),
+		FilterTimeout: hub.NewCounter(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "filter_timeout_total",
+			Help:      "Number of filters exceeded the execution time budget, by policy",
+			Labels:    []string{"FilterId", "Policy"},
+		}),
+		FilterPanic: hub.NewCounter(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "filter_panic_total",
+			Help:      "Number of panics recovered from filters",
+			Labels:    []string{"FilterId"},
+		}),
+		FilterDuration: hub.NewHistogram(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "filter_duration",
+			Help:      "Spend time by a filter itself, excluding the downstream filters and transporter",
+			Buckets:   defaultMetricBuckets,
+			Labels:    []string{"FilterId"},
+		}),
+		ShadowAccess: hub.NewCounter(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "shadow_access_total",
+			Help:      "Number of requests mirrored to shadow services, by result",
+			Labels:    []string{"ServiceId", "Result"},
+		}),
+		UpstreamHealth: hub.NewGauge(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "upstream_instance_healthy",
+			Help:      "Health state of upstream service instances, 1 for healthy",
+			Labels:    []string{"ServiceId", "Instance"},
+		}),
+		UpstreamCheck: hub.NewCounter(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "upstream_health_check_total",
+			Help:      "Number of active health checks on upstream service instances, by result",
+			Labels:    []string{"ServiceId", "Instance", "Result"},
+		}),
+		TenantAccess: hub.NewCounter(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "tenant_access_total",
+			Help:      "Number of tenant requests, by result",
+			Labels:    []string{"Tenant", "Result"},
+		}),
+		ConcurrencyInflight: hub.NewGauge(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "concurrency_inflight",
+			Help:      "Number of in-flight requests, by limit scope",
+			Labels:    []string{"Scope", "Key"},
+		}),
+		ConcurrencyRejected: hub.NewCounter(flux.MetricOpts{
+			Namespace: defaultMetricNamespace,
+			Subsystem: defaultMetricSubsystem,
+			Name:      "concurrency_rejected_total",
+			Help:      "Number of requests rejected by concurrency limits, by limit scope",
+			Labels:    []string{"Scope", "Key"},
+		}),
+	}
+}
+
+// Init 按配置的 sinks 列表绑定指标输出目标：prometheus，statsd
+func (m *Metrics) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyMetricsSinks: []string{fluxmetrics.SinkPrometheus},
+	})
+	names := config.GetStringSlice(ConfigKeyMetricsSinks)
+	sinks := make([]flux.MetricsSink, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case fluxmetrics.SinkPrometheus:
+			sinks = append(sinks, m.prometheus)
+		case fluxmetrics.SinkStatsd:
+			statsd, err := fluxmetrics.NewStatsdSink(flux.NewConfigurationOfNS(ConfigNsMetrics + "." + ConfigKeyMetricsStatsd))
+			if nil != err {
+				return err
+			}
+			ext.AddHookFunc(statsd)
+			sinks = append(sinks, statsd)
+		default:
+			return fmt.Errorf("unknown metrics sink: %s", name)
+		}
 	}
+	logger.Infow("Metrics init", "sinks", names)
+	m.hub.Bind(sinks...)
+	return nil
 }
@@ -0,0 +1,98 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"sync"
+)
+
+const (
+	// Endpoint指标标签配置：endpoint，max_patterns，min_requests
+	ConfigNsMetricLabels = "metric_labels"
+)
+
+const (
+	ConfigKeyLabelsEndpoint    = "endpoint"
+	ConfigKeyLabelsMaxPatterns = "max_patterns"
+	ConfigKeyLabelsMinRequests = "min_requests"
+)
+
+const (
+	// 未获得独立标签的Endpoint，归入此标签值
+	MetricLabelOther = "other"
+)
+
+// EndpointLabels 为访问计数、错误计数及路由耗时指标提供 HttpPattern，Version 标签值；
+// 为保护Prometheus，Endpoint请求次数达到 min_requests 后才使用独立的标签值，且独立标签值的总数不超过 max_patterns，
+// 其余Endpoint的标签值为 other。未开启时标签值为空。
+type EndpointLabels struct {
+	enabled     bool
+	maxPatterns int
+	minRequests int
+	admitted    map[string]struct{}
+	candidates  map[string]int
+	mu          sync.RWMutex
+}
+
+func NewEndpointLabels() *EndpointLabels {
+	return &EndpointLabels{
+		admitted:   make(map[string]struct{}, 16),
+		candidates: make(map[string]int, 16),
+	}
+}
+
+func (l *EndpointLabels) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyLabelsEndpoint:    false,
+		ConfigKeyLabelsMaxPatterns: 500,
+		ConfigKeyLabelsMinRequests: 10,
+	})
+	l.enabled = config.GetBool(ConfigKeyLabelsEndpoint)
+	l.maxPatterns = config.GetInt(ConfigKeyLabelsMaxPatterns)
+	l.minRequests = config.GetInt(ConfigKeyLabelsMinRequests)
+	logger.Infow("Endpoint metric labels init", "enabled", l.enabled,
+		"max-patterns", l.maxPatterns, "min-requests", l.minRequests)
+	return nil
+}
+
+// Labels 返回Endpoint的指标标签值：HttpPattern，Version
+func (l *EndpointLabels) Labels(endpoint *flux.Endpoint) (pattern string, version string) {
+	if !l.enabled || nil == endpoint {
+		return "", ""
+	}
+	key := endpoint.HttpPattern + "@" + endpoint.Version
+	l.mu.RLock()
+	_, ok := l.admitted[key]
+	l.mu.RUnlock()
+	if ok {
+		return endpoint.HttpPattern, endpoint.Version
+	}
+	if l.admit(key) {
+		return endpoint.HttpPattern, endpoint.Version
+	}
+	return MetricLabelOther, MetricLabelOther
+}
+
+// admit 记录Endpoint请求次数，达到 min_requests 且未超过 max_patterns 时分配独立标签值
+func (l *EndpointLabels) admit(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.admitted[key]; ok {
+		return true
+	}
+	if len(l.admitted) >= l.maxPatterns {
+		return false
+	}
+	count := l.candidates[key] + 1
+	if count < l.minRequests {
+		// 候选记录数量有上限；超过时清空计数，较少使用的Endpoint需要重新累计
+		if len(l.candidates) >= l.maxPatterns*4 {
+			l.candidates = make(map[string]int, 16)
+		}
+		l.candidates[key] = count
+		return false
+	}
+	delete(l.candidates, key)
+	l.admitted[key] = struct{}{}
+	return true
+}
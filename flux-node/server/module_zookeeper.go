@@ -0,0 +1,14 @@
+//go:build !no_zookeeper
+// +build !no_zookeeper
+
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node/discovery"
+	"github.com/bytepowered/flux/flux-node/ext"
+)
+
+// 使用构建标签 no_zookeeper 排除ZK注册中心及其依赖
+func init() {
+	ext.RegisterEndpointDiscovery(discovery.NewZookeeperServiceWith(discovery.ZookeeperId))
+}
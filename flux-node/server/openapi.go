@@ -0,0 +1,354 @@
+package server
+
+import (
+	"bytes"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"html/template"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// OpenAPI文档配置：title，description，version，servers，swagger_ui_assets
+	ConfigNsOpenAPI = "openapi"
+)
+
+const (
+	ConfigKeyOpenAPITitle       = "title"
+	ConfigKeyOpenAPIDescription = "description"
+	ConfigKeyOpenAPIVersion     = "version"
+	ConfigKeyOpenAPIServers     = "servers"
+	ConfigKeyOpenAPIAssets      = "swagger_ui_assets"
+)
+
+const (
+	OpenAPIVersion = "3.0.3"
+)
+
+// OpenAPIDocument OpenAPI 3 文档
+type OpenAPIDocument struct {
+	OpenAPI string                                  `json:"openapi"`
+	Info    OpenAPIInfo                             `json:"info"`
+	Servers []OpenAPIServer                         `json:"servers,omitempty"`
+	Paths   map[string]map[string]*OpenAPIOperation `json:"paths"`
+}
+
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+type OpenAPIServer struct {
+	URL string `json:"url"`
+}
+
+// OpenAPIOperation 接口定义；多个版本的Endpoint合并为一个接口，参数取各版本的并集
+type OpenAPIOperation struct {
+	OperationId string                      `json:"operationId"`
+	Summary     string                      `json:"summary,omitempty"`
+	Tags        []string                    `json:"tags,omitempty"`
+	Parameters  []*OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *OpenAPIRequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*OpenAPIResponse `json:"responses"`
+	Versions    []string                    `json:"x-flux-versions"`
+	Services    []string                    `json:"x-flux-services"`
+}
+
+type OpenAPIParameter struct {
+	Name     string         `json:"name"`
+	In       string         `json:"in"`
+	Required bool           `json:"required"`
+	Schema   *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIRequestBody struct {
+	Content map[string]OpenAPIMediaType `json:"content"`
+}
+
+type OpenAPIMediaType struct {
+	Schema *OpenAPISchema `json:"schema"`
+}
+
+type OpenAPIResponse struct {
+	Description string `json:"description"`
+}
+
+type OpenAPISchema struct {
+	Type       string                    `json:"type,omitempty"`
+	Format     string                    `json:"format,omitempty"`
+	Items      *OpenAPISchema            `json:"items,omitempty"`
+	Properties map[string]*OpenAPISchema `json:"properties,omitempty"`
+	Default    interface{}               `json:"default,omitempty"`
+	JavaClass  string                    `json:"x-java-class,omitempty"`
+}
+
+// OpenAPI 按当前注册的Endpoint及Service元数据生成OpenAPI文档；listenerId 不为空时只包含绑定到指定WebListener的Endpoint
+func (s *BootstrapServer) OpenAPI(listenerId string) *OpenAPIDocument {
+	config := flux.NewConfigurationOfNS(ConfigNsOpenAPI)
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyOpenAPITitle:   "Flux Gateway",
+		ConfigKeyOpenAPIVersion: s.build.Version,
+	})
+	doc := &OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Info: OpenAPIInfo{
+			Title:       config.GetString(ConfigKeyOpenAPITitle),
+			Description: config.GetString(ConfigKeyOpenAPIDescription),
+			Version:     config.GetString(ConfigKeyOpenAPIVersion),
+		},
+		Paths: make(map[string]map[string]*OpenAPIOperation, 32),
+	}
+	for _, url := range config.GetStringSlice(ConfigKeyOpenAPIServers) {
+		doc.Servers = append(doc.Servers, OpenAPIServer{URL: url})
+	}
+	for _, mve := range ext.Endpoints() {
+		endpoints := mve.Endpoints()
+		if len(endpoints) == 0 {
+			continue
+		}
+		// 按版本号排序，保证生成的文档稳定
+		sort.Slice(endpoints, func(i, j int) bool {
+			return endpoints[i].Version < endpoints[j].Version
+		})
+		for _, endpoint := range endpoints {
+			if listenerId != "" && !s.servedBy(endpoint, listenerId) {
+				continue
+			}
+			addOpenAPIOperation(doc, endpoint)
+		}
+	}
+	return doc
+}
+
+// OpenAPIHandler 查询OpenAPI文档的管理接口；支持 listener 参数过滤WebListener
+func (s *BootstrapServer) OpenAPIHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.OpenAPI(webex.QueryVar("listener")))
+}
+
+// SwaggerUIHandler 基于OpenAPI文档的Swagger UI页面；页面资源从 swagger_ui_assets 配置的地址加载，未配置时不可用
+func (s *BootstrapServer) SwaggerUIHandler(webex flux.ServerWebContext) error {
+	config := flux.NewConfigurationOfNS(ConfigNsOpenAPI)
+	assets := strings.TrimSuffix(config.GetString(ConfigKeyOpenAPIAssets), "/")
+	if assets == "" {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "swagger ui assets not configured: " + ConfigNsOpenAPI + "." + ConfigKeyOpenAPIAssets})
+	}
+	spec := "openapi.json"
+	if listener := webex.QueryVar("listener"); listener != "" {
+		spec += "?listener=" + url.QueryEscape(listener)
+	}
+	var page bytes.Buffer
+	// 模板按HTML及JS上下文转义参数
+	if err := swaggerUITemplate.Execute(&page, map[string]string{"Assets": assets, "Spec": spec}); nil != err {
+		return err
+	}
+	return webex.Write(flux.StatusOK, flux.MIMETextHTMLCharsetUTF8, page.Bytes())
+}
+
+func endpointListenerId(endpoint *flux.Endpoint) string {
+	if id := endpoint.GetAttr(flux.EndpointAttrTagListenerId).GetString(); id != "" {
+		return id
+	}
+	return ListenerIdDefault
+}
+
+func addOpenAPIOperation(doc *OpenAPIDocument, endpoint *flux.Endpoint) {
+	path := toOpenAPIPath(endpoint.HttpPattern)
+	method := strings.ToLower(endpoint.HttpMethod)
+	operations, ok := doc.Paths[path]
+	if !ok {
+		operations = make(map[string]*OpenAPIOperation, 2)
+		doc.Paths[path] = operations
+	}
+	service := endpoint.Service
+	// Endpoint只引用ServiceId时，使用注册的Service元数据
+	if registered, ok := ext.TransporterServiceById(service.ServiceID()); ok && len(service.Arguments) == 0 {
+		service = registered
+	}
+	op, ok := operations[method]
+	if !ok {
+		op = &OpenAPIOperation{
+			OperationId: method + "_" + strings.Trim(strings.NewReplacer("/", "_", "{", "", "}", "").Replace(path), "_"),
+			Summary:     service.Interface + "." + service.Method,
+			Responses: map[string]*OpenAPIResponse{
+				"200":     {Description: "OK"},
+				"default": {Description: "Gateway or upstream error"},
+			},
+		}
+		if endpoint.Application != "" {
+			op.Tags = []string{endpoint.Application}
+		}
+		operations[method] = op
+	}
+	op.Versions = appendUnique(op.Versions, endpoint.Version)
+	op.Services = appendUnique(op.Services, service.ServiceID())
+	for _, arg := range service.Arguments {
+		addOpenAPIArgument(op, arg)
+	}
+	// 动态路径参数未在参数列表中定义时，按字符串类型补充
+	for _, name := range pathVarNames(path) {
+		addOpenAPIParameter(op, &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: &OpenAPISchema{Type: "string"}})
+	}
+}
+
+func addOpenAPIArgument(op *OpenAPIOperation, arg flux.Argument) {
+	name := arg.HttpName
+	if name == "" {
+		name = arg.Name
+	}
+	switch strings.ToUpper(arg.HttpScope) {
+	case flux.ScopePath:
+		addOpenAPIParameter(op, &OpenAPIParameter{Name: name, In: "path", Required: true, Schema: argumentSchema(arg)})
+	case flux.ScopeQuery, flux.ScopeQueryMulti, flux.ScopeParam, flux.ScopeAuto, "":
+		if arg.Type == flux.ArgumentTypeComplex && len(arg.Fields) > 0 {
+			for _, field := range arg.Fields {
+				addOpenAPIArgument(op, field)
+			}
+			return
+		}
+		schema := argumentSchema(arg)
+		if strings.ToUpper(arg.HttpScope) == flux.ScopeQueryMulti {
+			schema = &OpenAPISchema{Type: "array", Items: schema}
+		}
+		addOpenAPIParameter(op, &OpenAPIParameter{Name: name, In: "query", Schema: schema})
+	case flux.ScopeHeader:
+		addOpenAPIParameter(op, &OpenAPIParameter{Name: name, In: "header", Schema: argumentSchema(arg)})
+	case flux.ScopeForm, flux.ScopeFormMulti:
+		schema := argumentSchema(arg)
+		if strings.ToUpper(arg.HttpScope) == flux.ScopeFormMulti {
+			schema = &OpenAPISchema{Type: "array", Items: schema}
+		}
+		requestBodySchema(op, flux.MIMEApplicationForm).Properties[name] = schema
+	case flux.ScopeBody:
+		// Body内的路径参数（JSONPath/JSON Pointer）按参数名称生成文档
+		if common.IsBodyPath(name) {
+			name = arg.Name
+		}
+		body := requestBodySchema(op, flux.MIMEApplicationJSON)
+		if len(arg.Fields) == 0 {
+			body.Properties[name] = argumentSchema(arg)
+		}
+		for _, field := range arg.Fields {
+			fname := field.HttpName
+			if fname == "" || common.IsBodyPath(fname) {
+				fname = field.Name
+			}
+			body.Properties[fname] = argumentSchema(field)
+		}
+	}
+	// 其它值域（Header/Query/Form Map，Attr，Request等）不由客户端直接传递，不生成文档
+}
+
+func addOpenAPIParameter(op *OpenAPIOperation, param *OpenAPIParameter) {
+	for _, p := range op.Parameters {
+		if p.Name == param.Name && p.In == param.In {
+			return
+		}
+	}
+	op.Parameters = append(op.Parameters, param)
+}
+
+func requestBodySchema(op *OpenAPIOperation, mediaType string) *OpenAPISchema {
+	if nil == op.RequestBody {
+		op.RequestBody = &OpenAPIRequestBody{Content: make(map[string]OpenAPIMediaType, 1)}
+	}
+	media, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		media = OpenAPIMediaType{Schema: &OpenAPISchema{Type: "object", Properties: make(map[string]*OpenAPISchema, 4)}}
+		op.RequestBody.Content[mediaType] = media
+	}
+	return media.Schema
+}
+
+// argumentSchema 按参数的Java类型生成Schema
+func argumentSchema(arg flux.Argument) *OpenAPISchema {
+	schema := &OpenAPISchema{}
+	switch arg.Class {
+	case flux.JavaLangStringClassName, "string", "":
+		schema.Type = "string"
+	case flux.JavaLangIntegerClassName, "int":
+		schema.Type, schema.Format = "integer", "int32"
+	case flux.JavaLangLongClassName, "long":
+		schema.Type, schema.Format = "integer", "int64"
+	case flux.JavaLangFloatClassName, "float":
+		schema.Type, schema.Format = "number", "float"
+	case flux.JavaLangDoubleClassName, "double":
+		schema.Type, schema.Format = "number", "double"
+	case flux.JavaLangBooleanClassName, "boolean":
+		schema.Type = "boolean"
+	case flux.JavaUtilListClassName:
+		schema.Type, schema.Items = "array", &OpenAPISchema{Type: "string"}
+		if len(arg.Generic) > 0 {
+			schema.Items = argumentSchema(flux.Argument{Class: arg.Generic[0]})
+		}
+	case flux.JavaUtilMapClassName:
+		schema.Type = "object"
+	default:
+		schema.Type, schema.JavaClass = "object", arg.Class
+	}
+	if len(arg.Fields) > 0 {
+		schema.Type, schema.Properties = "object", make(map[string]*OpenAPISchema, len(arg.Fields))
+		for _, field := range arg.Fields {
+			schema.Properties[field.Name] = argumentSchema(field)
+		}
+	}
+	if value, ok := arg.GetAttrEx(flux.ArgumentAttributeTagDefault); ok {
+		schema.Default = value.Value
+	}
+	return schema
+}
+
+// toOpenAPIPath 将路由模式转换为OpenAPI路径格式：:name 转换为 {name}，* 转换为 {wildcard}
+func toOpenAPIPath(pattern string) string {
+	segments := strings.Split(pattern, "/")
+	for i, seg := range segments {
+		switch {
+		case strings.HasPrefix(seg, ":"):
+			segments[i] = "{" + seg[1:] + "}"
+		case seg == "*":
+			segments[i] = "{wildcard}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func pathVarNames(path string) []string {
+	out := make([]string, 0, 2)
+	for _, seg := range strings.Split(path, "/") {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			out = append(out, seg[1:len(seg)-1])
+		}
+	}
+	return out
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, v := range values {
+		if v == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
+
+var swaggerUITemplate = template.Must(template.New("swagger-ui").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="UTF-8">
+  <title>Flux OpenAPI</title>
+  <link rel="stylesheet" href="{{.Assets}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.Assets}}/swagger-ui-bundle.js"></script>
+<script>
+  window.onload = function () {
+    window.ui = SwaggerUIBundle({url: {{.Spec}}, dom_id: "#swagger-ui", deepLinking: true});
+  };
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"io/ioutil"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+)
+
+const (
+	// 外部Go插件配置：directory，files，optional
+	ConfigNsPlugins = "plugins"
+)
+
+const (
+	// 插件导出的注册函数名称：func Register(*ext.Registry) 或 func Register(*ext.Registry) error
+	PluginSymbolRegister = "Register"
+)
+
+// loadPlugins 加载配置的外部Go插件（.so），调用插件导出的 Register 函数注册扩展组件；
+// 需在初始化组件之前执行。插件必须与网关使用相同的Go版本及依赖版本构建，且网关必须以 CGO_ENABLED=1 构建。
+func (s *BootstrapServer) loadPlugins() error {
+	return loadPluginsOf(flux.NewConfigurationOfNS(ConfigNsPlugins))
+}
+
+func loadPluginsOf(config *flux.Configuration) error {
+	files, err := pluginFiles(config)
+	if nil != err {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	// 不支持插件的构建，配置了插件时直接返回错误，不受 optional 影响
+	if !pluginSupported {
+		return fmt.Errorf("plugins configured but gateway is built without plugin support, rebuild with CGO_ENABLED=1, files: %s", files)
+	}
+	optional := config.GetBool("optional")
+	for _, file := range files {
+		if err := LoadPlugin(file, ext.DefaultRegistry().WithOrigin(ext.OriginPluginPrefix+file)); nil != err {
+			if optional {
+				logger.Warnw("SERVER:PLUGIN:LOAD/ERROR", "file", file, "error", err)
+				continue
+			}
+			return err
+		}
+		logger.Infow("SERVER:PLUGIN:LOADED", "file", file)
+	}
+	return nil
+}
+
+// pluginFiles 返回配置的插件文件列表：files 指定的文件，以及 directory 目录下按文件名排序的全部 .so 文件
+func pluginFiles(config *flux.Configuration) ([]string, error) {
+	files := config.GetStringSlice("files")
+	if dir := config.GetString("directory"); dir != "" {
+		infos, err := ioutil.ReadDir(dir)
+		if nil != err {
+			return nil, fmt.Errorf("read plugins directory: %s, err: %w", dir, err)
+		}
+		names := make([]string, 0, len(infos))
+		for _, info := range infos {
+			if !info.IsDir() && strings.HasSuffix(info.Name(), ".so") {
+				names = append(names, filepath.Join(dir, info.Name()))
+			}
+		}
+		// 按文件名顺序加载，保证注册顺序稳定
+		sort.Strings(names)
+		files = append(files, names...)
+	}
+	return files, nil
+}
+
+// LoadPlugin 加载Go插件，并调用插件导出的 Register 函数
+func LoadPlugin(file string, registry *ext.Registry) error {
+	p, err := plugin.Open(file)
+	if nil != err {
+		return fmt.Errorf("open plugin: %s, err: %w", file, err)
+	}
+	symbol, err := p.Lookup(PluginSymbolRegister)
+	if nil != err {
+		return fmt.Errorf("lookup plugin symbol(%s): %s, err: %w", PluginSymbolRegister, file, err)
+	}
+	switch register := symbol.(type) {
+	case func(*ext.Registry):
+		register(registry)
+	case func(*ext.Registry) error:
+		if err := register(registry); nil != err {
+			return fmt.Errorf("register plugin: %s, err: %w", file, err)
+		}
+	default:
+		return fmt.Errorf("plugin symbol(%s) must be func(*ext.Registry) [error], was: %T, file: %s", PluginSymbolRegister, symbol, file)
+	}
+	return nil
+}
@@ -0,0 +1,8 @@
+//go:build cgo && (linux || darwin || freebsd)
+// +build cgo
+// +build linux darwin freebsd
+
+package server
+
+// Go插件依赖cgo，只在启用cgo的 linux，darwin，freebsd 构建中可用
+const pluginSupported = true
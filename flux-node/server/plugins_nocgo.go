@@ -0,0 +1,7 @@
+//go:build !cgo || !(linux || darwin || freebsd)
+// +build !cgo !linux,!darwin,!freebsd
+
+package server
+
+// 未启用cgo（例如 Makefile 默认的 CGO_ENABLED=0 构建）时，plugin.Open 总是失败；配置了插件时启动失败
+const pluginSupported = false
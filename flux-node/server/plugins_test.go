@@ -0,0 +1,47 @@
+package server
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPluginFiles(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "flux-plugins")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	for _, name := range []string{"b.so", "a.so", "readme.txt"} {
+		assert.NoError(ioutil.WriteFile(filepath.Join(dir, name), []byte("plugin"), 0644))
+	}
+	assert.NoError(os.Mkdir(filepath.Join(dir, "sub.so"), 0755))
+	files, err := pluginFiles(flux.NewConfigurationOfMap(map[string]interface{}{
+		"files":     []string{"/opt/flux/x.so"},
+		"directory": dir,
+	}))
+	assert.NoError(err)
+	assert.Equal([]string{"/opt/flux/x.so", filepath.Join(dir, "a.so"), filepath.Join(dir, "b.so")}, files)
+	_, err = pluginFiles(flux.NewConfigurationOfMap(map[string]interface{}{"directory": filepath.Join(dir, "missing")}))
+	assert.Error(err)
+}
+
+func TestLoadPlugins(t *testing.T) {
+	assert := assert.New(t)
+	assert.NoError(loadPluginsOf(flux.NewConfigurationOfMap(map[string]interface{}{})))
+	dir, err := ioutil.TempDir("", "flux-plugins")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "invalid.so")
+	assert.NoError(ioutil.WriteFile(file, []byte("not a plugin"), 0644))
+	assert.Error(loadPluginsOf(flux.NewConfigurationOfMap(map[string]interface{}{"files": []string{file}})))
+	// 不支持插件的构建，optional 不忽略错误
+	err = loadPluginsOf(flux.NewConfigurationOfMap(map[string]interface{}{"files": []string{file}, "optional": true}))
+	if pluginSupported {
+		assert.NoError(err)
+	} else {
+		assert.Error(err)
+	}
+}
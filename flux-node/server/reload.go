@@ -0,0 +1,249 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	// 配置热加载：disabled，debounce，grace
+	ConfigNsConfigReload = "config_reload"
+)
+
+const (
+	ConfigKeyReloadDebounce = "debounce"
+	// 重新加载后，旧实例等待处理中请求完成的时间；超时后停止旧实例
+	ConfigKeyReloadGrace = "grace"
+)
+
+type reloadTarget struct {
+	kind string
+	id   string
+	ns   string
+	ref  interface{}
+	// 创建新实例的工厂函数；未实现 flux.Reloadable 接口的组件，通过新实例重新加载配置
+	factory flux.Factory
+}
+
+// ReloadResult 单个组件的配置重新加载结果
+type ReloadResult struct {
+	Kind     string `json:"kind"`
+	Id       string `json:"id"`
+	ConfigNs string `json:"configNs"`
+	Reloaded bool   `json:"reloaded"`
+	// 配置为停用的Filter不再被选择执行
+	Disabled bool   `json:"disabled,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+var (
+	reloadMu sync.Mutex
+)
+
+// Reload 按当前的全局配置，重新加载全部Filter和Transporter的配置：
+// 实现 flux.Reloadable 接口的组件调用 OnReload 函数；其它组件通过工厂函数创建新实例并初始化，
+// 初始化成功后替换旧实例，旧实例在等待处理中请求完成后停止；没有工厂函数的组件需要重启服务才能加载配置。
+// 配置为停用（disabled）的Filter不再被选择执行；单个组件加载失败不影响其它组件。
+func (r *Dispatcher) Reload() []ReloadResult {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	results := make([]ReloadResult, 0, len(r.reloads))
+	for i := range r.reloads {
+		target := &r.reloads[i]
+		result := r.reload(target)
+		if result.Error != "" {
+			logger.Errorw("SERVER:CONFIG:RELOAD/ERROR", "kind", target.kind, "id", target.id,
+				"type", reflect.TypeOf(target.ref), "error", result.Error)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (r *Dispatcher) reload(target *reloadTarget) ReloadResult {
+	result := ReloadResult{Kind: target.kind, Id: target.id, ConfigNs: target.ns}
+	config := flux.NewConfigurationOfNS(target.ns)
+	if target.kind == ComponentKindFilter {
+		filterId := target.id
+		if filter, ok := target.ref.(flux.Filter); ok {
+			filterId = filter.FilterId()
+		}
+		disabled := IsDisabled(config)
+		r.filters.reconfigure(filterId, disabled)
+		if disabled {
+			result.Disabled = true
+			return result
+		}
+	}
+	var err error
+	if reloadable, ok := target.ref.(flux.Reloadable); ok {
+		err = reloadable.OnReload(config)
+	} else if nil != target.factory {
+		err = r.rebuild(target, config)
+	} else if _, ok := target.ref.(flux.Initializer); ok {
+		err = errors.New("component has no factory to rebuild instance, restart required")
+	} else {
+		return result
+	}
+	if nil != err {
+		result.Error = err.Error()
+	} else {
+		result.Reloaded = true
+	}
+	return result
+}
+
+// rebuild 创建并初始化新实例，成功后替换已注册的旧实例
+func (r *Dispatcher) rebuild(target *reloadTarget, config *flux.Configuration) error {
+	ref := target.factory()
+	if init, ok := ref.(flux.Initializer); ok {
+		if err := init.Init(config); nil != err {
+			r.retire(ref, 0)
+			return err
+		}
+	}
+	// 服务已启动，直接执行启动Hook
+	if startup, ok := ref.(flux.Startuper); ok {
+		if err := startup.Startup(); nil != err {
+			r.retire(ref, 0)
+			return fmt.Errorf("startup, err: %w", err)
+		}
+	}
+	switch target.kind {
+	case ComponentKindTransporter:
+		transporter, ok := ref.(flux.Transporter)
+		if !ok {
+			r.retire(ref, 0)
+			return fmt.Errorf("factory returns non-transporter: %s", reflect.TypeOf(ref))
+		}
+		ext.RegisterTransporter(target.id, transporter)
+	case ComponentKindFilter:
+		filter, ok := ref.(flux.Filter)
+		if !ok || !ext.ReplaceFilter(filter) {
+			r.retire(ref, 0)
+			return fmt.Errorf("factory returns unregistered filter: %s", reflect.TypeOf(ref))
+		}
+	}
+	ext.MarkInitialized(ref, nil)
+	if _, ok := ref.(flux.Startuper); ok {
+		ext.MarkStarted(ref, nil)
+	}
+	ext.ReplaceHookFunc(target.ref, ref)
+	old := target.ref
+	target.ref = ref
+	r.retire(old, r.reloadGrace)
+	return nil
+}
+
+// retire 等待处理中的请求完成后，停止旧实例
+func (r *Dispatcher) retire(ref interface{}, grace time.Duration) {
+	shutdown, ok := ref.(flux.Shutdowner)
+	if !ok {
+		return
+	}
+	time.AfterFunc(grace, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+		if err := shutdown.Shutdown(ctx); nil != err {
+			logger.Warnw("SERVER:CONFIG:RELOAD/RETIRE_ERROR", "type", reflect.TypeOf(ref), "error", err)
+		}
+	})
+}
+
+// ReloadConfig 重新加载组件配置；远程配置源更新全局配置后，调用此函数使配置生效
+func (s *BootstrapServer) ReloadConfig(source string) []ReloadResult {
+	logger.Infow("SERVER:CONFIG:RELOAD", "source", source)
+	results := s.dispatcher.Reload()
+	// 管理接口触发的重载由管理审计拦截器记录
+	if source != reloadSourceAdmin {
+		s.auditReload(source, results)
+	}
+	return results
+}
+
+// ConfigReloadHandler 手动触发重新加载组件配置
+func (s *BootstrapServer) ConfigReloadHandler(webex flux.ServerWebContext) error {
+	if err := flux.ReadInConfig(); nil != err {
+		logger.Warnw("SERVER:CONFIG:RELOAD/READ_ERROR", "error", err)
+	}
+	results := s.ReloadConfig(reloadSourceAdmin)
+	s.auditChanged(webex, AuditActionConfigReload, viper.ConfigFileUsed(), nil, results)
+	return writeJSON(webex, flux.StatusOK, results)
+}
+
+// initConfigReload 监听配置文件变更；文件短时间内多次变更时，只在最后一次变更后重新读取配置文件并重新加载
+func (s *BootstrapServer) initConfigReload() {
+	config := flux.NewConfigurationOfNS(ConfigNsConfigReload)
+	config.SetDefaults(map[string]interface{}{
+		"disabled":              true,
+		ConfigKeyReloadDebounce: time.Second,
+		ConfigKeyReloadGrace:    time.Second * 30,
+	})
+	s.dispatcher.reloadGrace = config.GetDuration(ConfigKeyReloadGrace)
+	if IsDisabled(config) {
+		return
+	}
+	file := viper.ConfigFileUsed()
+	if file == "" {
+		logger.Warnw("SERVER:CONFIG:RELOAD/NO_CONFIG_FILE")
+		return
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if nil != err {
+		logger.Warnw("SERVER:CONFIG:RELOAD/WATCH_ERROR", "error", err)
+		return
+	}
+	// 监听配置文件所在目录，兼容以重命名方式替换配置文件
+	if err := watcher.Add(filepath.Dir(file)); nil != err {
+		_ = watcher.Close()
+		logger.Warnw("SERVER:CONFIG:RELOAD/WATCH_ERROR", "file", file, "error", err)
+		return
+	}
+	debounce := config.GetDuration(ConfigKeyReloadDebounce)
+	go func() {
+		defer watcher.Close()
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != filepath.Clean(file) || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				if nil != timer {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(debounce, func() {
+					if err := flux.ReadInConfig(); nil != err {
+						logger.Warnw("SERVER:CONFIG:RELOAD/READ_ERROR", "file", file, "error", err)
+						return
+					}
+					s.ReloadConfig("file:" + file)
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warnw("SERVER:CONFIG:RELOAD/WATCH_ERROR", "file", file, "error", err)
+			case <-s.stopped:
+				if nil != timer {
+					timer.Stop()
+				}
+				return
+			}
+		}
+	}()
+	logger.Infow("SERVER:CONFIG:RELOAD/WATCHING", "file", file, "debounce", debounce)
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const protoReloadTest = "RELOAD_TEST"
+
+// reloadTransporter 记录初始化配置及停止状态的Transporter
+type reloadTransporter struct {
+	timeout time.Duration
+	stopped int32
+}
+
+func (t *reloadTransporter) Init(config *flux.Configuration) error {
+	config.SetDefault("timeout", time.Second)
+	t.timeout = config.GetDuration("timeout")
+	return nil
+}
+
+func (t *reloadTransporter) Shutdown(_ context.Context) error {
+	atomic.StoreInt32(&t.stopped, 1)
+	return nil
+}
+
+func (t *reloadTransporter) Invoke(_ *flux.Context, _ flux.TransporterService) (interface{}, *flux.ServeError) {
+	return nil, nil
+}
+
+func (t *reloadTransporter) InvokeCodec(_ *flux.Context, _ flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	return nil, nil
+}
+
+func (t *reloadTransporter) Transport(_ *flux.Context) {}
+
+func (t *reloadTransporter) Writer() flux.TransportWriter {
+	return nil
+}
+
+// reloadableComponent 实现 flux.Reloadable 接口的组件
+type reloadableComponent struct {
+	reloads int
+}
+
+func (c *reloadableComponent) OnReload(_ *flux.Configuration) error {
+	c.reloads++
+	return nil
+}
+
+// initOnlyComponent 只实现 flux.Initializer 接口的组件
+type initOnlyComponent struct{}
+
+func (initOnlyComponent) Init(_ *flux.Configuration) error {
+	return nil
+}
+
+// reloadFilter 只实现 flux.Filter 接口的Filter
+type reloadFilter struct{}
+
+func (reloadFilter) FilterId() string {
+	return "reload_test_filter"
+}
+
+func (reloadFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return next
+}
+
+func setGlobalConfig(t *testing.T, key string, value interface{}) {
+	viper.Set(key, value)
+	t.Cleanup(func() {
+		viper.Set(key, nil)
+	})
+}
+
+func TestDispatcher_ReloadRebuild(t *testing.T) {
+	assert := assert.New(t)
+	ext.RegisterTransporterFactory(protoReloadTest, func() interface{} {
+		return new(reloadTransporter)
+	})
+	old, _ := ext.TransporterBy(protoReloadTest)
+	factory, ok := ext.TransporterFactoryBy(protoReloadTest)
+	assert.True(ok)
+	assert.NoError(old.(flux.Initializer).Init(flux.NewEmptyConfiguration()))
+	ext.AddHookFunc(old)
+
+	component := new(reloadableComponent)
+	r := NewDispatcher()
+	r.reloadGrace = 0
+	r.reloads = []reloadTarget{
+		{kind: ComponentKindTransporter, id: protoReloadTest, ns: "transporters.reload_test", ref: old, factory: factory},
+		{kind: ComponentKindServer, id: "reloadable", ns: "reloadable", ref: component},
+		{kind: ComponentKindServer, id: "init_only", ns: "init_only", ref: initOnlyComponent{}},
+	}
+	setGlobalConfig(t, "transporters.reload_test.timeout", "5s")
+	results := r.Reload()
+	assert.Len(results, 3)
+	assert.True(results[0].Reloaded, results[0].Error)
+	assert.True(results[1].Reloaded)
+	assert.Equal(1, component.reloads)
+	assert.False(results[2].Reloaded)
+	assert.NotEmpty(results[2].Error)
+
+	// 新实例替换旧实例；旧实例不被修改，并被停止
+	current, _ := ext.TransporterBy(protoReloadTest)
+	assert.NotEqual(old, current)
+	assert.Equal(time.Second*5, current.(*reloadTransporter).timeout)
+	assert.Equal(time.Second, old.(*reloadTransporter).timeout)
+	assert.Eventually(func() bool {
+		return atomic.LoadInt32(&old.(*reloadTransporter).stopped) == 1
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(current, r.reloads[0].ref)
+	for _, hook := range ext.ShutdownHooks() {
+		assert.NotEqual(old, hook)
+	}
+}
+
+func TestDispatcher_ReloadDisabledFilter(t *testing.T) {
+	assert := assert.New(t)
+	filter := reloadFilter{}
+	r := NewDispatcher()
+	r.filters.register(filter.FilterId(), filter.FilterId(), false)
+	r.reloads = []reloadTarget{{kind: ComponentKindFilter, id: filter.FilterId(), ns: filter.FilterId(), ref: filter}}
+
+	setGlobalConfig(t, filter.FilterId()+".disabled", true)
+	results := r.Reload()
+	assert.True(results[0].Disabled)
+	assert.False(r.filters.enabled(filter.FilterId()))
+	// 配置恢复启用
+	viper.Set(filter.FilterId()+".disabled", false)
+	results = r.Reload()
+	assert.False(results[0].Disabled)
+	assert.True(r.filters.enabled(filter.FilterId()))
+	// 通过管理接口停用的Filter，不因配置恢复而启用
+	r.filters.mu.Lock()
+	r.filters.disabled[filter.FilterId()] = true
+	r.filters.mu.Unlock()
+	r.Reload()
+	assert.False(r.filters.enabled(filter.FilterId()))
+}
+
+// lazyFilter 配置为停用，通过管理接口启用时初始化的Filter
+type lazyFilter struct {
+	dispatcher *Dispatcher
+	inits      int
+}
+
+func (*lazyFilter) FilterId() string {
+	return "lazy_test_filter"
+}
+
+func (f *lazyFilter) Init(_ *flux.Configuration) error {
+	f.inits++
+	// 初始化期间，路由请求仍可读取Filter状态
+	f.dispatcher.filters.isDisabled(f.FilterId())
+	return nil
+}
+
+func (*lazyFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return next
+}
+
+func TestDispatcher_SetFilterEnabled(t *testing.T) {
+	assert := assert.New(t)
+	r := NewDispatcher()
+	filter := &lazyFilter{dispatcher: r}
+	ext.AddSelectiveFilter(filter)
+	r.filters.register(filter.FilterId(), filter.FilterId(), true)
+	done := make(chan error, 1)
+	go func() {
+		done <- r.SetFilterEnabled(filter.FilterId(), true)
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(err)
+	case <-time.After(time.Second):
+		t.Fatal("filter init must not hold the filter state lock")
+	}
+	assert.True(r.filters.enabled(filter.FilterId()))
+	assert.Equal(1, filter.inits)
+	if assert.Len(r.reloads, 1) {
+		assert.Equal(filter, r.reloads[0].ref)
+	}
+	// 已初始化的Filter再次启用时不重复初始化
+	assert.NoError(r.SetFilterEnabled(filter.FilterId(), false))
+	assert.NoError(r.SetFilterEnabled(filter.FilterId(), true))
+	assert.Equal(1, filter.inits)
+	assert.Len(r.reloads, 1)
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 启动时路由表检查配置：disabled，fail_fast
+	ConfigNsRouteReport = "route_report"
+)
+
+const (
+	ConfigKeyRouteReportFailFast = "fail_fast"
+)
+
+const (
+	// RouteIssueConflict 相同Method的多个Pattern映射到同一个路由，只有一个生效
+	RouteIssueConflict = "conflict"
+	// RouteIssueShadowed 多个Pattern可以匹配相同的请求路径，重叠部分只由优先级高的路由处理
+	RouteIssueShadowed = "shadowed"
+	// RouteIssueDuplicateService 相同的ServiceId注册了不同的服务定义
+	RouteIssueDuplicateService = "duplicate_service"
+	// RouteIssueMissingService Endpoint引用的服务未注册
+	RouteIssueMissingService = "missing_service"
+	// RouteIssueUnknownProtocol Endpoint的服务协议没有对应的Transporter
+	RouteIssueUnknownProtocol = "unknown_protocol"
+	// RouteIssueMissingListener Endpoint绑定的WebListener不存在
+	RouteIssueMissingListener = "missing_listener"
+)
+
+const (
+	RouteSeverityError = "error"
+	RouteSeverityWarn  = "warn"
+)
+
+// RouteIssue 路由表检查发现的问题
+type RouteIssue struct {
+	Kind       string   `json:"kind"`
+	Severity   string   `json:"severity"`
+	ListenerId string   `json:"listenerId,omitempty"`
+	Method     string   `json:"method,omitempty"`
+	Patterns   []string `json:"patterns,omitempty"`
+	ServiceId  string   `json:"serviceId,omitempty"`
+	Message    string   `json:"message"`
+}
+
+// RouteReport 路由表检查报告
+type RouteReport struct {
+	Time      time.Time    `json:"time"`
+	Endpoints int          `json:"endpoints"`
+	Services  int          `json:"services"`
+	Errors    int          `json:"errors"`
+	Warnings  int          `json:"warnings"`
+	Issues    []RouteIssue `json:"issues"`
+}
+
+func (r *RouteReport) add(issue RouteIssue) {
+	if issue.Severity == RouteSeverityError {
+		r.Errors++
+	} else {
+		r.Warnings++
+	}
+	r.Issues = append(r.Issues, issue)
+}
+
+// serviceDuplicates 记录服务注册过程中发现的重复ServiceId
+type serviceDuplicates struct {
+	issues map[string]RouteIssue
+	mu     sync.Mutex
+}
+
+func newServiceDuplicates() *serviceDuplicates {
+	return &serviceDuplicates{issues: make(map[string]RouteIssue, 4)}
+}
+
+// check 检查服务ID是否已注册为不同的服务定义
+func (d *serviceDuplicates) check(id string, service flux.TransporterService) {
+	prev, ok := ext.TransporterServiceById(id)
+	if !ok || (prev.ServiceId == service.ServiceId && prev.ServiceID() == service.ServiceID()) {
+		return
+	}
+	d.mu.Lock()
+	d.issues[id] = RouteIssue{
+		Kind: RouteIssueDuplicateService, Severity: RouteSeverityWarn, ServiceId: id,
+		Message: fmt.Sprintf("service id registered by different services: %s(%s), %s(%s)",
+			prev.ServiceId, prev.ServiceID(), service.ServiceId, service.ServiceID()),
+	}
+	d.mu.Unlock()
+}
+
+func (d *serviceDuplicates) list() []RouteIssue {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]RouteIssue, 0, len(d.issues))
+	for _, issue := range d.issues {
+		out = append(out, issue)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ServiceId < out[j].ServiceId
+	})
+	return out
+}
+
+type routeEntry struct {
+	listenerId string
+	hosts      string
+	method     string
+	pattern    string
+	segments   []string
+}
+
+// AnalyzeRoutes 检查当前全部路由表：路由冲突、路由覆盖、重复ServiceId、引用缺失的服务等问题
+func (s *BootstrapServer) AnalyzeRoutes() RouteReport {
+	report := RouteReport{Time: time.Now(), Issues: make([]RouteIssue, 0, 8)}
+	entries := make([]routeEntry, 0, 64)
+	keys := make([]string, 0, 64)
+	mvcs := ext.Endpoints()
+	for key := range mvcs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		versions := mvcs[key].Endpoints()
+		if len(versions) == 0 {
+			continue
+		}
+		report.Endpoints += len(versions)
+		sort.Slice(versions, func(i, j int) bool {
+			return versions[i].Version < versions[j].Version
+		})
+		method, pattern := strings.ToUpper(versions[0].HttpMethod), versions[0].HttpPattern
+		hosts := strings.Join(versions[0].Hosts(), ",")
+		for _, lid := range s.endpointListenerIds(versions[0]) {
+			if _, ok := s.WebListenerById(lid); !ok {
+				report.add(RouteIssue{
+					Kind: RouteIssueMissingListener, Severity: RouteSeverityError, ListenerId: lid,
+					Method: method, Patterns: []string{pattern}, Message: "web listener not found: " + lid,
+				})
+			}
+			for _, ep := range versions {
+				s.checkEndpointServices(&report, lid, method, ep)
+			}
+			entries = append(entries, routeEntry{
+				listenerId: lid, hosts: hosts, method: method, pattern: pattern, segments: routeSegments(pattern),
+			})
+		}
+	}
+	report.Services = len(ext.TransporterServices())
+	for i := 0; i < len(entries); i++ {
+		for j := i + 1; j < len(entries); j++ {
+			a, b := entries[i], entries[j]
+			if a.listenerId != b.listenerId || a.hosts != b.hosts || a.method != b.method {
+				continue
+			}
+			if issue, ok := compareRoutes(a, b); ok {
+				report.add(issue)
+			}
+		}
+	}
+	for _, issue := range s.duplicates.list() {
+		report.add(issue)
+	}
+	return report
+}
+
+func (s *BootstrapServer) checkEndpointServices(report *RouteReport, lid, method string, ep *flux.Endpoint) {
+	if ep.Service.IsValid() {
+		if proto := ep.Service.RpcProto(); proto != "" {
+			if _, ok := ext.TransporterBy(proto); !ok {
+				report.add(RouteIssue{
+					Kind: RouteIssueUnknownProtocol, Severity: RouteSeverityError, ListenerId: lid,
+					Method: method, Patterns: []string{ep.HttpPattern}, ServiceId: ep.Service.ServiceId,
+					Message: fmt.Sprintf("transporter not found, version: %s, proto: %s", ep.Version, proto),
+				})
+			}
+		}
+	} else if ep.Service.ServiceId != "" && !ext.HasTransporterService(ep.Service.ServiceId) {
+		report.add(RouteIssue{
+			Kind: RouteIssueMissingService, Severity: RouteSeverityError, ListenerId: lid,
+			Method: method, Patterns: []string{ep.HttpPattern}, ServiceId: ep.Service.ServiceId,
+			Message: "upstream service not found, version: " + ep.Version,
+		})
+	}
+	for _, id := range ep.Permissions {
+		if !ext.HasTransporterService(id) {
+			report.add(RouteIssue{
+				Kind: RouteIssueMissingService, Severity: RouteSeverityError, ListenerId: lid,
+				Method: method, Patterns: []string{ep.HttpPattern}, ServiceId: id,
+				Message: "permission service not found, version: " + ep.Version,
+			})
+		}
+	}
+}
+
+// compareRoutes 比较两个路由：规范化后相同为冲突；可匹配相同请求路径为覆盖
+func compareRoutes(a, b routeEntry) (RouteIssue, bool) {
+	issue := RouteIssue{ListenerId: a.listenerId, Method: a.method, Patterns: []string{a.pattern, b.pattern}}
+	if strings.Join(normalizeSegments(a.segments), "/") == strings.Join(normalizeSegments(b.segments), "/") {
+		issue.Kind, issue.Severity = RouteIssueConflict, RouteSeverityError
+		issue.Message = "patterns are mapped to the same route, only one of them takes effect"
+		return issue, true
+	}
+	if overlapSegments(a.segments, b.segments) {
+		issue.Kind, issue.Severity = RouteIssueShadowed, RouteSeverityWarn
+		issue.Message = "patterns overlap, overlapped requests are served by the more specific route"
+		return issue, true
+	}
+	return issue, false
+}
+
+// routeSegments 拆分路由Pattern；兼容 {param} 与 :param 两种参数格式
+func routeSegments(pattern string) []string {
+	return strings.Split(strings.Trim(toRoutePattern(pattern), "/"), "/")
+}
+
+func toRoutePattern(pattern string) string {
+	if strings.Contains(pattern, "}") {
+		return strings.Replace(strings.Replace(pattern, "}", "", -1), "{", ":", -1)
+	}
+	return pattern
+}
+
+func normalizeSegments(segments []string) []string {
+	out := make([]string, len(segments))
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") {
+			out[i] = ":"
+		} else {
+			out[i] = seg
+		}
+	}
+	return out
+}
+
+func overlapSegments(a, b []string) bool {
+	for i := 0; ; i++ {
+		if i >= len(a) || i >= len(b) {
+			return len(a) == len(b)
+		}
+		sa, sb := a[i], b[i]
+		if sa == "*" || sb == "*" {
+			return true
+		}
+		if strings.HasPrefix(sa, ":") || strings.HasPrefix(sb, ":") || sa == sb {
+			continue
+		}
+		return false
+	}
+}
+
+// reportRoutes 启动时输出路由表检查报告；配置 fail_fast 时，存在错误级别的问题则终止启动
+func (s *BootstrapServer) reportRoutes() error {
+	config := flux.NewConfigurationOfNS(ConfigNsRouteReport)
+	if IsDisabled(config) {
+		return nil
+	}
+	report := s.AnalyzeRoutes()
+	for _, issue := range report.Issues {
+		fields := []interface{}{"kind", issue.Kind, "listener-id", issue.ListenerId, "method", issue.Method,
+			"patterns", issue.Patterns, "service-id", issue.ServiceId, "message", issue.Message}
+		if issue.Severity == RouteSeverityError {
+			logger.WithModule(logger.ModuleServer).Errorw("SERVER:START:ROUTE_REPORT:ISSUE", fields...)
+		} else {
+			logger.WithModule(logger.ModuleServer).Warnw("SERVER:START:ROUTE_REPORT:ISSUE", fields...)
+		}
+	}
+	logger.WithModule(logger.ModuleServer).Infow("SERVER:START:ROUTE_REPORT", "endpoints", report.Endpoints, "services", report.Services,
+		"errors", report.Errors, "warnings", report.Warnings)
+	if report.Errors > 0 && config.GetBool(ConfigKeyRouteReportFailFast) {
+		return errors.New("SERVER:START:ROUTE_REPORT:FAIL_FAST: route table has errors")
+	}
+	return nil
+}
+
+// RouteReportHandler 查询路由表检查报告的管理接口
+func (s *BootstrapServer) RouteReportHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.AnalyzeRoutes())
+}
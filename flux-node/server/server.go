@@ -3,12 +3,14 @@ package server
 import (
 	goctx "context"
 	"fmt"
-	dubgo "github.com/apache/dubbo-go/config"
 	"github.com/bytepowered/flux/flux-inspect"
 	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/accesslog"
+	"github.com/bytepowered/flux/flux-node/discovery"
 	"github.com/bytepowered/flux/flux-node/ext"
 	"github.com/bytepowered/flux/flux-node/listener"
 	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
 	"github.com/bytepowered/flux/flux-pkg"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"golang.org/x/net/context"
@@ -16,7 +18,10 @@ import (
 	_ "net/http/pprof"
 	"os"
 	"os/signal"
+	"sort"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -25,6 +30,11 @@ const (
 	VersionFormat = "Version // git.commit=%s, build.version=%s, build.date=%s"
 )
 
+var (
+	// 服务停止信号
+	shutdownSignals = []os.Signal{os.Interrupt, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGTERM}
+)
+
 const (
 	DefaultHttpHeaderVersion = "X-Version"
 
@@ -32,6 +42,21 @@ const (
 	ListenServerIdAdmin = "admin"
 )
 
+const (
+	// 元数据模板变量配置：enable，strict
+	ConfigNsMetadataTemplate = "metadata_template"
+	// 启动时等待注册中心首次同步配置：disabled，timeout
+	ConfigNsDiscoverySync = "discovery_sync"
+	// 请求链路追踪配置：enable，service_name，sample_ratio，propagators，endpoint，headers
+	ConfigNsTracing = "tracing"
+	// 重复告警日志限流配置：burst，period
+	ConfigNsLimitedLogging = "limited_logging"
+	// 上游服务实例发现及健康检查配置：enable，refresh_interval，health_check，consul，nacos
+	ConfigNsUpstreamInstances = "upstream_instances"
+	// 客户端IP解析配置：trusted_proxies，headers
+	ConfigNsRealIP = "real_ip"
+)
+
 type (
 	// Option 配置HttpServeEngine函数
 	Option func(bs *BootstrapServer)
@@ -41,13 +66,36 @@ type (
 
 // BootstrapServer
 type BootstrapServer struct {
-	listener    map[string]flux.WebListener
-	hookFunc    []flux.ContextHookFunc
-	versionFunc VersionLookupFunc
-	dispatcher  *Dispatcher
-	started     chan struct{}
-	stopped     chan struct{}
-	banner      string
+	listener      map[string]flux.WebListener
+	hookFunc      []flux.ContextHookFunc
+	versionFunc   VersionLookupFunc
+	dispatcher    *Dispatcher
+	accessLog     flux.AccessLogWriter
+	analytics     *accesslog.AccessLogger
+	journal       *accesslog.Journal
+	audit         *accesslog.AuditTrail
+	stats         *EndpointStats
+	captures      *Captures
+	switches      *endpointSwitches
+	expander      *discovery.TemplateExpander
+	duplicates    *serviceDuplicates
+	changes       *changeHistory
+	compressor    *Compressor
+	build         flux.Build
+	notfound      *listener.ScopedHandler
+	notallowed    *listener.ScopedHandler
+	healthTimeout time.Duration
+	auditOpts     auditOptions
+	interceptors  map[string]flux.WebInterceptor
+	groups        []ServingGroup
+	tenancy       *Tenancy
+	cors          *CORSEngine
+	concurrency   *ConcurrencyLimiter
+	hosts         map[string]*hostRoutes
+	hostsmu       sync.RWMutex
+	started       chan struct{}
+	stopped       chan struct{}
+	banner        string
 }
 
 // WithContextHooks 配置请求Hook函数列表
@@ -78,6 +126,22 @@ func WithPrepareHooks(hooks ...flux.PrepareHookFunc) Option {
 	}
 }
 
+// WithFilterAlertFuncs 配置Filter熔断告警处理函数列表
+func WithFilterAlertFuncs(funcs ...FilterAlertFunc) Option {
+	return func(bs *BootstrapServer) {
+		for _, f := range funcs {
+			bs.dispatcher.guards.AddAlertFunc(f)
+		}
+	}
+}
+
+// WithAccessLogWriter 配置访问日志输出实现；默认使用 accesslog.AccessLogger
+func WithAccessLogWriter(writer flux.AccessLogWriter) Option {
+	return func(bs *BootstrapServer) {
+		bs.accessLog = writer
+	}
+}
+
 func WithWebListener(server flux.WebListener) Option {
 	return func(bs *BootstrapServer) {
 		bs.AddWebListener(server.ListenerId(), server)
@@ -103,23 +167,93 @@ func NewDefaultBootstrapServer(options ...Option) *BootstrapServer {
 				// Http Inspect
 				{Method: "GET", Pattern: "/inspect/endpoints", Handler: fluxinspect.EndpointsHandler},
 				{Method: "GET", Pattern: "/inspect/services", Handler: fluxinspect.ServicesHandler},
+				// Debug
+				{Method: "GET", Pattern: "/debug/orphans", Handler: fluxinspect.OrphansHandler},
 				// Metrics
 				{Method: "GET", Pattern: "/inspect/metrics", Handler: flux.WrapHttpHandler(promhttp.Handler())},
 			}),
 		)),
 	}
-	return NewBootstrapServerWith(append(opts, options...)...)
+	srv := NewBootstrapServerWith(append(opts, options...)...)
+	if admin, ok := srv.WebListenerById(ListenServerIdAdmin); ok {
+		// Audit
+		admin.AddInterceptor(srv.AuditInterceptor)
+		// Filter guard
+		admin.AddHandler("GET", "/inspect/filters", srv.dispatcher.guards.StatusHandler)
+		admin.AddHandler("POST", "/inspect/filters/reset", srv.dispatcher.guards.ResetHandler)
+		// Health
+		admin.AddHandler("GET", "/health/live", srv.HealthLiveHandler)
+		admin.AddHandler("GET", "/health/ready", srv.HealthReadyHandler)
+		// Route report
+		admin.AddHandler("GET", "/inspect/routes/report", srv.RouteReportHandler)
+		// Route changes
+		admin.AddHandler("GET", "/debug/changes", srv.ChangesHandler)
+		// Components
+		admin.AddHandler("GET", "/debug/components", srv.ComponentsHandler)
+		// Config reload
+		admin.AddHandler("POST", "/debug/config/reload", srv.ConfigReloadHandler)
+		// Startup summary
+		admin.AddHandler("GET", "/debug/startup", srv.StartupSummaryHandler)
+		// Adaptive timeout
+		admin.AddHandler("GET", "/debug/timeouts", srv.dispatcher.timeout.StatusHandler)
+		// Route explain
+		admin.AddHandler("POST", "/debug/route/explain", srv.RouteExplainHandler)
+		// Filter switches
+		admin.AddHandler("GET", "/admin/filters", srv.FilterListHandler)
+		admin.AddHandler("POST", "/admin/filters/{id}/enable", srv.FilterEnableHandler)
+		admin.AddHandler("POST", "/admin/filters/{id}/disable", srv.FilterDisableHandler)
+		// Endpoint switches
+		admin.AddHandler("GET", "/admin/endpoints", srv.EndpointListHandler)
+		admin.AddHandler("POST", "/admin/endpoints/{id}/disable", srv.EndpointDisableHandler)
+		admin.AddHandler("POST", "/admin/endpoints/{id}/enable", srv.EndpointEnableHandler)
+		// Listener addresses
+		admin.AddHandler("GET", "/debug/listeners", srv.ListenerAddressesHandler)
+		// Serving groups
+		admin.AddHandler("GET", "/debug/groups", srv.ServingGroupsHandler)
+		// Tenants
+		admin.AddHandler("GET", "/debug/tenants", srv.TenantsHandler)
+		// Endpoint stats
+		admin.AddHandler("GET", "/debug/stats", srv.EndpointStatsHandler)
+		// Analytics
+		admin.AddHandler("GET", "/debug/analytics", srv.AnalyticsHandler)
+		// OpenAPI
+		admin.AddHandler("GET", "/debug/openapi.json", srv.OpenAPIHandler)
+		admin.AddHandler("GET", "/debug/swagger", srv.SwaggerUIHandler)
+		// Logging levels
+		admin.AddHandler("GET", "/admin/logging", srv.LoggingHandler)
+		admin.AddHandler("POST", "/admin/logging", srv.LoggingUpdateHandler)
+		// Captures
+		admin.AddHandler("GET", "/debug/captures", srv.CapturesHandler)
+		admin.AddHandler("DELETE", "/debug/captures", srv.CapturesClearHandler)
+		admin.AddHandler("GET", "/debug/captures/{id}", srv.CaptureHandler)
+		admin.AddHandler("POST", "/debug/captures/{id}/replay", srv.CaptureReplayHandler)
+	}
+	return srv
 }
 
 func NewBootstrapServerWith(opts ...Option) *BootstrapServer {
 	srv := &BootstrapServer{
-		dispatcher: NewDispatcher(),
-		listener:   make(map[string]flux.WebListener, 2),
-		hookFunc:   make([]flux.ContextHookFunc, 0, 4),
-		started:    make(chan struct{}),
-		stopped:    make(chan struct{}),
-		banner:     defaultBanner,
+		dispatcher:   NewDispatcher(),
+		listener:     make(map[string]flux.WebListener, 2),
+		hookFunc:     make([]flux.ContextHookFunc, 0, 4),
+		duplicates:   newServiceDuplicates(),
+		changes:      newChangeHistory(defaultChangeHistoryCapacity),
+		compressor:   NewCompressor(),
+		analytics:    accesslog.NewAccessLogger(),
+		journal:      accesslog.NewJournal(),
+		audit:        accesslog.NewAuditTrail(),
+		stats:        NewEndpointStats(),
+		captures:     NewCaptures(),
+		switches:     newEndpointSwitches(),
+		interceptors: make(map[string]flux.WebInterceptor, 4),
+		hosts:        make(map[string]*hostRoutes, 64),
+		cors:         NewCORSEngine(),
+		started:      make(chan struct{}),
+		stopped:      make(chan struct{}),
+		banner:       defaultBanner,
 	}
+	srv.tenancy = NewTenancy(srv.dispatcher.metrics.TenantAccess)
+	srv.concurrency = NewConcurrencyLimiter(srv.dispatcher.metrics.ConcurrencyInflight, srv.dispatcher.metrics.ConcurrencyRejected)
 	for _, opt := range opts {
 		opt(srv)
 	}
@@ -128,34 +262,210 @@ func NewBootstrapServerWith(opts ...Option) *BootstrapServer {
 
 // Prepare Call before init and startup
 func (s *BootstrapServer) Prepare() error {
+	// 外部插件注册的组件需参与后续的初始化
+	if err := s.loadPlugins(); nil != err {
+		return err
+	}
 	return s.dispatcher.Prepare()
 }
 
 // Initial
 func (s *BootstrapServer) Initial() error {
+	// Serving groups
+	if err := s.initServingGroups(); nil != err {
+		return err
+	}
 	// Listen Server
 	for id, webListener := range s.listener {
 		if err := webListener.Init(LoadWebListenerConfig(id)); nil != err {
 			return err
 		}
 	}
+	// ACME HTTP-01 challenge
+	s.initACMEChallenge()
+	// Client IP
+	if err := s.initRealIP(); nil != err {
+		return err
+	}
+	// CORS
+	if err := s.initCORS(); nil != err {
+		return err
+	}
+	// Logging levels
+	if err := s.initLogging(); nil != err {
+		return err
+	}
+	// Limited logging
+	if lc := flux.NewConfigurationOfNS(ConfigNsLimitedLogging); IsDisabled(lc) {
+		logger.SetLimitedLogging(0, time.Minute)
+	} else {
+		lc.SetDefaults(map[string]interface{}{
+			"burst":  5,
+			"period": time.Minute,
+		})
+		logger.SetLimitedLogging(lc.GetInt("burst"), lc.GetDuration("period"))
+	}
+	// Tenancy
+	if err := s.tenancy.Init(flux.NewConfigurationOfNS(ConfigNsTenancy)); nil != err {
+		return err
+	}
+	if s.tenancy.Enabled() {
+		s.hookFunc = append(s.hookFunc, s.tenancy.Resolve)
+	}
+	if s.tenancy.ClaimEnabled() {
+		s.dispatcher.verifiers = append(s.dispatcher.verifiers, s.tenancy.VerifyClaim)
+	}
+	// Concurrency limits
+	if err := s.concurrency.Init(flux.NewConfigurationOfNS(ConfigNsConcurrency)); nil != err {
+		return err
+	}
+	s.dispatcher.reloads = append(s.dispatcher.reloads, reloadTarget{kind: ComponentKindServer, id: ConfigNsConcurrency, ns: ConfigNsConcurrency, ref: s.concurrency})
+	// Health probes
+	s.initHealthProbes()
+	// Endpoint validation
+	s.initEndpointValidation()
+	// Change history
+	s.initChangeHistory()
+	// Config reload
+	s.initConfigReload()
+	// Compression
+	if err := s.compressor.Init(flux.NewConfigurationOfNS(ConfigNsCompression)); nil != err {
+		return err
+	}
+	// Metadata template
+	if tc := flux.NewConfigurationOfNS(ConfigNsMetadataTemplate); !IsDisabled(tc) {
+		s.expander = discovery.NewTemplateExpander(tc.GetBool("strict"))
+	}
+	// Access log
+	if alc := flux.NewConfigurationOfNS(ConfigNsAccessLog); IsDisabled(alc) {
+		s.accessLog = nil
+	} else {
+		if s.accessLog == nil {
+			s.accessLog = accesslog.NewAccessLogger()
+		}
+		if err := s.dispatcher.AddInitHook(s.accessLog, alc); nil != err {
+			return err
+		}
+	}
+	// Upstream instances
+	if uc := flux.NewConfigurationOfNS(ConfigNsUpstreamInstances); uc.GetBool("enable") {
+		registry := transporter.NewInstanceRegistry(s.dispatcher.metrics.UpstreamHealth, s.dispatcher.metrics.UpstreamCheck)
+		if err := s.dispatcher.AddInitHook(registry, uc); nil != err {
+			return err
+		}
+		transporter.SetInstanceRegistry(registry)
+	}
+	// Analytics
+	if err := s.initAnalytics(); nil != err {
+		return err
+	}
+	// Journal
+	if err := s.initJournal(); nil != err {
+		return err
+	}
+	// Audit trail
+	if err := s.initAudit(); nil != err {
+		return err
+	}
+	// Endpoint stats
+	if err := s.initEndpointStats(); nil != err {
+		return err
+	}
+	// Captures
+	if err := s.initCaptures(); nil != err {
+		return err
+	}
 	// Discovery
 	for _, dis := range ext.EndpointDiscoveries() {
 		if err := s.dispatcher.AddInitHook(dis, LoadEndpointDiscoveryConfig(dis.Id())); nil != err {
 			return err
 		}
 	}
-	return s.dispatcher.Initial()
+	if err := s.dispatcher.Initial(); nil != err {
+		return err
+	}
+	// Filter handlers
+	s.initFilterHandlers()
+	return nil
+}
+
+// initFilterHandlers 由实现 WebHandlerRegistrar 的Filter及EndpointSelector向各WebListener注册处理接口
+func (s *BootstrapServer) initFilterHandlers() {
+	ids := make([]string, 0, len(s.listener))
+	for id := range s.listener {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	register := func(registrar flux.WebHandlerRegistrar, kind string) {
+		logger.Infow("SERVER:FILTER:HANDLERS", "registrar", kind, "listener-ids", ids)
+		for _, id := range ids {
+			registrar.RegisterWebHandlers(id, s.listener[id])
+		}
+	}
+	for _, filter := range append(ext.GlobalFilters(), ext.SelectiveFilters()...) {
+		if registrar, ok := filter.(flux.WebHandlerRegistrar); ok {
+			register(registrar, filter.FilterId())
+		}
+	}
+	for _, selector := range ext.EndpointSelectors() {
+		if registrar, ok := selector.(flux.WebHandlerRegistrar); ok {
+			register(registrar, fmt.Sprintf("%T", selector))
+		}
+	}
 }
 
 func (s *BootstrapServer) Startup(build flux.Build) error {
 	logger.Infof(VersionFormat, build.CommitId, build.Version, build.Date)
+	s.build = build
 	if s.banner != "" {
 		logger.Info(s.banner)
 	}
 	return s.start()
 }
 
+// initACMEChallenge 由管理服务处理开启ACME的WebListener的HTTP-01验证请求；
+// 每个开启ACME的WebListener处理各自证书域名的验证请求，均不匹配时返回404。
+func (s *BootstrapServer) initACMEChallenge() {
+	admin, ok := s.WebListenerById(ListenServerIdAdmin)
+	if !ok {
+		return
+	}
+	ids := make([]string, 0, len(s.listener))
+	for id := range s.listener {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	var handler http.Handler = http.NotFoundHandler()
+	var challengers []string
+	for _, id := range ids {
+		challenger, ok := s.listener[id].(flux.ACMEChallenger)
+		if !ok {
+			continue
+		}
+		if next, ok := challenger.ACMEChallengeHandler(handler); ok {
+			handler = next
+			challengers = append(challengers, id)
+		}
+	}
+	if len(challengers) > 0 {
+		logger.Infow("SERVER:ACME:CHALLENGE", "listener-ids", challengers)
+		admin.AddHttpHandler(http.MethodGet, "/.well-known/acme-challenge/*", handler)
+	}
+}
+
+// initRealIP 配置全局的客户端IP解析器；未配置可信代理时，不读取代理Header，使用对端地址
+func (s *BootstrapServer) initRealIP() error {
+	config := flux.NewConfigurationOfNS(ConfigNsRealIP)
+	trusted, err := flux.ParseTrustedProxies(config.GetStringSlice("trusted_proxies"))
+	if nil != err {
+		return err
+	}
+	headers := config.GetStringSlice("headers")
+	flux.SetRealIPResolver(flux.NewRealIPResolver(trusted, headers))
+	logger.Infow("SERVER:REAL_IP:INIT", "trusted-proxies", len(trusted), "headers", headers)
+	return nil
+}
+
 func (s *BootstrapServer) start() error {
 	dl := s.defaultListener()
 	fluxpkg.Assert(nil != dl, "<default listener> is required")
@@ -175,25 +485,33 @@ func (s *BootstrapServer) start() error {
 	logger.Info("SERVER:START:DISCOVERY:START")
 	ctx, canceled := context.WithCancel(context.Background())
 	defer canceled()
-	go s.startEventLoop(ctx, endpoints, services)
+	barrier := make(chan chan struct{})
+	validated, validatedBarrier := s.startEndpointValidation(ctx, endpoints, barrier)
+	go s.startEventLoop(ctx, validated, services, validatedBarrier)
 	if err := s.startEventWatch(ctx, endpoints, services); nil != err {
 		return err
 	}
+	s.awaitDiscoverySync(barrier)
 	logger.Info("SERVER:START:DISCOVERY:OK")
-	// Listeners
-	var errch chan error
-	for lid, wl := range s.listener {
-		logger.Infow("SERVER:START:LISTENER:START", "listener-id", wl.ListenerId())
-		go func(id string, server flux.WebListener) {
-			errch <- server.Listen()
-			logger.Infow("SERVER:START:LISTENER:STOP", "listener-id", id)
-		}(lid, wl)
+	// Route report
+	if err := s.reportRoutes(); nil != err {
+		return err
+	}
+	// Startup summary
+	if err := s.emitStartupSummary(); nil != err {
+		logger.WithModule(logger.ModuleServer).Warnw("SERVER:START:SUMMARY/ERROR", "error", err)
+	}
+	// Listeners：全部地址绑定成功后，服务才进入已启动状态
+	errch, err := s.startListeners()
+	if nil != err {
+		return err
 	}
 	close(s.started)
 	return <-errch
 }
 
-func (s *BootstrapServer) startEventLoop(ctx context.Context, endpoints chan flux.EndpointEvent, services chan flux.ServiceEvent) {
+func (s *BootstrapServer) startEventLoop(ctx context.Context, endpoints <-chan flux.EndpointEvent, services chan flux.ServiceEvent,
+	barrier <-chan chan struct{}) {
 	logger.Info("SERVER:START:DISCOVERY:EVENT_LOOP:START")
 	defer logger.Info("SERVER:START:DISCOVERY:EVENT_LOOP:STOP")
 	for {
@@ -208,6 +526,20 @@ func (s *BootstrapServer) startEventLoop(ctx context.Context, endpoints chan flu
 				s.onServiceEvent(esEvt)
 			}
 
+		case ack := <-barrier:
+			// 处理完已接收的事件后，通知等待方
+			for drained := false; !drained; {
+				select {
+				case epEvt := <-endpoints:
+					s.onEndpointEvent(epEvt)
+				case esEvt := <-services:
+					s.onServiceEvent(esEvt)
+				default:
+					drained = true
+				}
+			}
+			close(ack)
+
 		case <-ctx.Done():
 			return
 		}
@@ -216,25 +548,60 @@ func (s *BootstrapServer) startEventLoop(ctx context.Context, endpoints chan flu
 
 func (s *BootstrapServer) startEventWatch(ctx context.Context, endpoints chan flux.EndpointEvent, services chan flux.ServiceEvent) error {
 	for _, discovery := range ext.EndpointDiscoveries() {
-		logger.Infow("SERVER:START:DISCOVERY:WATCH", "discovery-id", discovery.Id())
+		logger.WithModule(logger.ModuleServer).Infow("SERVER:START:DISCOVERY:WATCH", "discovery-id", discovery.Id())
 		if err := discovery.WatchEndpoints(ctx, endpoints); nil != err {
 			return err
 		}
 		if err := discovery.WatchServices(ctx, services); nil != err {
 			return err
 		}
-		logger.Infow("SERVER:START:DISCOVERY:WATCH/OK", "discovery-id", discovery.Id())
+		logger.WithModule(logger.ModuleServer).Infow("SERVER:START:DISCOVERY:WATCH/OK", "discovery-id", discovery.Id())
 	}
 	return nil
 }
 
+// awaitDiscoverySync 等待各注册中心首次全量数据同步完成，并且事件已被处理；超时后继续启动。
+func (s *BootstrapServer) awaitDiscoverySync(barrier chan chan struct{}) {
+	config := flux.NewConfigurationOfNS(ConfigNsDiscoverySync)
+	if IsDisabled(config) {
+		return
+	}
+	config.SetDefault("timeout", time.Second*30)
+	timeout := time.After(config.GetDuration("timeout"))
+	for _, dis := range ext.EndpointDiscoveries() {
+		syncer, ok := dis.(flux.EndpointDiscoverySyncer)
+		if !ok {
+			continue
+		}
+		logger.WithModule(logger.ModuleServer).Infow("SERVER:START:DISCOVERY:SYNC:WAIT", "discovery-id", dis.Id())
+		select {
+		case <-syncer.Synced():
+		case <-timeout:
+			logger.WithModule(logger.ModuleServer).Warnw("SERVER:START:DISCOVERY:SYNC:TIMEOUT", "discovery-id", dis.Id())
+			return
+		}
+	}
+	ack := make(chan struct{})
+	select {
+	case barrier <- ack:
+		select {
+		case <-ack:
+			logger.WithModule(logger.ModuleServer).Infow("SERVER:START:DISCOVERY:SYNC:OK", "endpoints", len(ext.Endpoints()))
+		case <-timeout:
+			logger.Warn("SERVER:START:DISCOVERY:SYNC:TIMEOUT")
+		}
+	case <-timeout:
+		logger.Warn("SERVER:START:DISCOVERY:SYNC:TIMEOUT")
+	}
+}
+
 func (s *BootstrapServer) route(webex flux.ServerWebContext, server flux.WebListener, endpoints *flux.MVCEndpoint) (err error) {
 	defer func(id string) {
 		if rvr := recover(); rvr != nil {
-			err = fmt.Errorf("SERVER:ROUTE:CRITICAL_PANIC:%w", rvr)
+			err = fmt.Errorf("SERVER:ROUTE:CRITICAL_PANIC:%v", rvr)
 		}
 	}(webex.RequestId())
-	endpoint, found := endpoints.Lookup(s.versionFunc(webex))
+	endpoint, found := endpoints.Select(webex, s.versionFunc(webex))
 	// 实现动态Endpoint版本选择
 	for _, selector := range ext.EndpointSelectors() {
 		if selector.Active(webex, server.ListenerId()) {
@@ -245,7 +612,7 @@ func (s *BootstrapServer) route(webex flux.ServerWebContext, server flux.WebList
 		}
 	}
 	if !found {
-		logger.Trace(webex.RequestId()).Infow("SERVER:ROUTE:NOT_FOUND",
+		logger.ModuleOf(logger.ModuleRoute, logger.Trace(webex.RequestId())).Infow("SERVER:ROUTE:NOT_FOUND",
 			"http-pattern", []string{webex.Method(), webex.URI(), webex.URL().Path},
 		)
 		// Endpoint节点版本被删除，需要重新路由到NotFound处理函数
@@ -253,6 +620,14 @@ func (s *BootstrapServer) route(webex flux.ServerWebContext, server flux.WebList
 	} else {
 		fluxpkg.Assert(endpoint.IsValid(), "<endpoint> must valid when routing")
 	}
+	// 同一路由的各版本可属于不同的服务分组；选中的版本不由当前WebListener服务时，按NotFound处理
+	if !s.servedBy(&endpoint, server.ListenerId()) {
+		logger.ModuleOf(logger.ModuleRoute, logger.Trace(webex.RequestId())).Infow("SERVER:ROUTE:NOT_SERVED",
+			"listener-id", server.ListenerId(), "version", endpoint.Version,
+			"http-pattern", []string{webex.Method(), webex.URI(), webex.URL().Path},
+		)
+		return server.HandleNotfound(webex)
+	}
 	ctxw := flux.NewContext()
 	ctxw.Reset(webex, &endpoint)
 	ctxw.SetAttribute(flux.XRequestTime, ctxw.StartAt().Unix())
@@ -265,41 +640,132 @@ func (s *BootstrapServer) route(webex flux.ServerWebContext, server flux.WebList
 	for _, hook := range s.hookFunc {
 		hook(webex, ctxw)
 	}
+	s.cors.ApplyEndpoint(webex, &endpoint)
+	span := s.dispatcher.tracer.StartServerSpan(ctxw, webex.Method()+" "+endpoint.HttpPattern)
+	span.SetAttribute("http.method", webex.Method())
+	span.SetAttribute("http.target", webex.URI())
+	span.SetAttribute("http.route", endpoint.HttpPattern)
+	span.SetAttribute("flux.request_id", webex.RequestId())
 	defer func(start time.Time) {
+		span.End()
 		trace.Infow("SERVER:ROUTE:END", "metric", ctxw.Metrics(), "elapses", time.Since(start).String())
 	}(ctxw.StartAt())
-	// route
-	if serr := s.dispatcher.Route(ctxw); nil != serr {
+	var rw *responseRecorder
+	mirror, journaled := s.analyticsSampled(&endpoint), s.journaled(&endpoint)
+	if mirror || journaled || (nil != s.accessLog && accessLogMode(&endpoint) != flux.AccessLogModeOff) {
+		rw = &responseRecorder{ResponseWriter: webex.ResponseWriter()}
+		webex.SetResponseWriter(rw)
+	}
+	// 审计日志：后端响应在写入客户端之前同步记录；未到达响应阶段的错误请求在路由结束后记录
+	written := false
+	if journaled {
+		ctxw.AddResponseHook(s.journalHook(server.ListenerId(), &written))
+	}
+	// 响应压缩，访问日志记录压缩后的响应数据大小
+	cw, compress := s.compressor.Wrap(webex, &endpoint)
+	// 请求抓取，记录未压缩的响应数据
+	var capture *captureWriter
+	if s.captureSampled(webex, &endpoint) {
+		capture = s.captures.Wrap(webex)
+	}
+	// route；运行时停用的Endpoint直接返回错误
+	serr := s.verifyEndpointEnabled(ctxw)
+	if nil == serr {
+		serr = s.tenancy.Verify(ctxw)
+	}
+	if nil == serr {
+		serr = s.routeConcurrency(ctxw)
+	}
+	if journaled && !written {
+		status, code := rw.status, ""
+		if nil != serr {
+			status, code = serr.StatusCode, serr.GetErrorCode()
+		}
+		if err := s.appendJournal(ctxw, server.ListenerId(), status, code); nil != err {
+			logger.TraceContext(ctxw).Errorw("SERVER:JOURNAL:APPEND/ERROR", "error", err)
+		}
+	}
+	s.recordEndpointStats(ctxw, serr)
+	s.auditDenial(ctxw, serr)
+	if nil != serr {
+		span.SetAttribute("http.status_code", serr.StatusCode)
+		span.SetError(serr.Message)
 		server.HandleError(webex, serr)
 	}
+	if compress {
+		if err := cw.Close(); nil != err {
+			logger.TraceContext(ctxw).Warnw("SERVER:COMPRESS:CLOSE/ERROR", "error", err)
+		}
+	}
+	if nil != s.accessLog && accessLogMode(&endpoint) != flux.AccessLogModeOff {
+		s.accessLog.WriteAccessLog(newAccessLog(ctxw, server.ListenerId(), rw, serr))
+	}
+	if mirror {
+		s.analytics.WriteAccessLog(newAccessLog(ctxw, server.ListenerId(), rw, serr))
+	}
+	if nil != capture {
+		s.captures.Record(ctxw, server.ListenerId(), capture, serr)
+	}
 	return nil
 }
 
+// routeConcurrency 占用并发信号量后路由请求；请求处理结束（包括发生panic）时释放信号量
+func (s *BootstrapServer) routeConcurrency(ctx *flux.Context) *flux.ServeError {
+	release, serr := s.concurrency.Acquire(ctx)
+	if nil != serr {
+		return serr
+	}
+	defer release()
+	return s.dispatcher.Route(ctx)
+}
+
 func (s *BootstrapServer) onServiceEvent(event flux.ServiceEvent) {
 	service := event.Service
+	if nil != s.expander && event.EventType != flux.EventTypeRemoved {
+		if err := s.expander.ExpandService(&service); nil != err {
+			logger.WithModule(logger.ModuleDiscovery).Errorw("SERVER:EVENT:SERVICE:TEMPLATE/ERROR",
+				"service-id", service.ServiceId, "error", err)
+			return
+		}
+	}
+	if event.EventType != flux.EventTypeRemoved {
+		if err := validateService(service); nil != err {
+			logger.WithModule(logger.ModuleDiscovery).Errorw("SERVER:EVENT:SERVICE:INVALID",
+				"service-id", service.ServiceId, "error", err)
+			return
+		}
+	}
 	initArguments(service.Arguments)
+	s.changes.record(ChangeKindService, event.EventType, event.Source, service.ServiceId, service)
 	switch event.EventType {
 	case flux.EventTypeAdded:
-		logger.Infow("SERVER:EVENT:SERVICE:ADD",
+		logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:SERVICE:ADD",
 			"service-id", service.ServiceId, "alias-id", service.AliasId)
+		s.duplicates.check(service.ServiceId, service)
 		ext.RegisterTransporterService(service)
 		if service.AliasId != "" {
+			s.duplicates.check(service.AliasId, service)
 			ext.RegisterTransporterServiceById(service.AliasId, service)
 		}
 	case flux.EventTypeUpdated:
-		logger.Infow("SERVER:EVENT:SERVICE:UPDATE",
+		logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:SERVICE:UPDATE",
 			"service-id", service.ServiceId, "alias-id", service.AliasId)
 		ext.RegisterTransporterService(service)
 		if service.AliasId != "" {
 			ext.RegisterTransporterServiceById(service.AliasId, service)
 		}
 	case flux.EventTypeRemoved:
-		logger.Infow("SERVER:EVENT:SERVICE:REMOVE",
+		logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:SERVICE:REMOVE",
 			"service-id", service.ServiceId, "alias-id", service.AliasId)
 		ext.RemoveTransporterService(service.ServiceId)
 		if service.AliasId != "" {
 			ext.RemoveTransporterService(service.AliasId)
 		}
+		for _, id := range []string{service.ServiceId, service.AliasId} {
+			if refs := ext.ServiceRefs(id); id != "" && len(refs) > 0 {
+				logger.LimitedWarnw("SERVER:EVENT:SERVICE:ORPHANED", "service-id", id, "refs", refs)
+			}
+		}
 	}
 }
 
@@ -307,42 +773,113 @@ func (s *BootstrapServer) onEndpointEvent(event flux.EndpointEvent) {
 	method := strings.ToUpper(event.Endpoint.HttpMethod)
 	// Check http method
 	if !isAllowedHttpMethod(method) {
-		logger.Warnw("SERVER:EVENT:ENDPOINT:METHOD/IGNORE", "method", method, "pattern", event.Endpoint.HttpPattern)
+		logger.LimitedWarnw("SERVER:EVENT:ENDPOINT:METHOD/IGNORE", "method", method, "pattern", event.Endpoint.HttpPattern)
 		return
 	}
 	pattern := event.Endpoint.HttpPattern
-	routeKey := fmt.Sprintf("%s#%s", method, pattern)
 	endpoint := event.Endpoint
+	hosts := endpoint.Hosts()
+	routeKey := routeKeyOf(method, pattern, hosts)
+	if nil != s.expander && event.EventType != flux.EventTypeRemoved {
+		if err := s.expander.ExpandEndpoint(&endpoint); nil != err {
+			logger.WithModule(logger.ModuleDiscovery).Errorw("SERVER:EVENT:ENDPOINT:TEMPLATE/ERROR", "method", method, "pattern", pattern, "error", err)
+			return
+		}
+	}
+	if event.EventType != flux.EventTypeRemoved {
+		if err := validateService(endpoint.Service); nil != err {
+			logger.WithModule(logger.ModuleDiscovery).Errorw("SERVER:EVENT:ENDPOINT:SERVICE:INVALID", "method", method, "pattern", pattern, "error", err)
+			return
+		}
+		if err := validateEndpoint(&endpoint); nil != err {
+			logger.WithModule(logger.ModuleDiscovery).Errorw("SERVER:EVENT:ENDPOINT:INVALID", "method", method, "pattern", pattern, "error", err)
+			return
+		}
+	}
 	initArguments(endpoint.Service.Arguments)
 	initArguments(endpoint.Permission.Arguments)
-	bind, isreg := s.selectMultiEndpoint(routeKey, &endpoint)
+	s.changes.record(ChangeKindEndpoint, event.EventType, event.Source, routeKey+"#"+endpoint.Version, endpoint)
+	bind, _ := s.selectMultiEndpoint(routeKey, &endpoint)
+	refOwner := routeKey + "#" + endpoint.Version
+	if event.EventType == flux.EventTypeRemoved {
+		ext.RemoveServiceRefs(refOwner)
+	} else {
+		s.updateServiceRefs(refOwner, &endpoint)
+	}
 	switch event.EventType {
 	case flux.EventTypeAdded:
-		logger.Infow("SERVER:EVENT:ENDPOINT:ADD", "version", endpoint.Version, "method", method, "pattern", pattern)
+		logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:ENDPOINT:ADD", "version", endpoint.Version, "method", method, "pattern", pattern)
 		bind.Update(endpoint.Version, &endpoint)
-		// 根据Endpoint属性，选择ListenServer来绑定
-		if isreg {
-			id := endpoint.GetAttr(flux.EndpointAttrTagListenerId).GetString()
-			if id == "" {
-				id = ListenerIdDefault
-			}
-			server, ok := s.WebListenerById(id)
-			if ok {
-				logger.Infow("SERVER:EVENT:ENDPOINT:HTTP_HANDLER/"+id, "method", method, "pattern", pattern)
-				server.AddHandler(method, pattern, s.newEndpointHandler(server, bind))
-			} else {
-				logger.Errorw("SERVER:EVENT:ENDPOINT:LISTENER_MISSED/"+id, "method", method, "pattern", pattern)
-			}
-		}
+		s.bindEndpointListeners(bind, &endpoint, method, pattern, hosts)
 	case flux.EventTypeUpdated:
-		logger.Infow("SERVER:EVENT:ENDPOINT:UPDATE", "version", endpoint.Version, "method", method, "pattern", pattern)
+		logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:ENDPOINT:UPDATE", "version", endpoint.Version, "method", method, "pattern", pattern)
 		bind.Update(endpoint.Version, &endpoint)
+		s.bindEndpointListeners(bind, &endpoint, method, pattern, hosts)
 	case flux.EventTypeRemoved:
-		logger.Infow("SERVER:EVENT:ENDPOINT:REMOVE", "method", method, "pattern", pattern)
+		logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:ENDPOINT:REMOVE", "method", method, "pattern", pattern)
 		bind.Delete(endpoint.Version)
 	}
 }
 
+// validateService 由后端服务协议对应的Transporter校验Service属性
+func validateService(service flux.TransporterService) error {
+	if transporter, ok := ext.TransporterBy(service.RpcProto()); ok {
+		if validator, ok := transporter.(flux.ServiceValidator); ok {
+			return validator.ValidateService(service)
+		}
+	}
+	return nil
+}
+
+// validateEndpoint 由后端服务协议对应的Transporter校验Endpoint属性
+func validateEndpoint(endpoint *flux.Endpoint) error {
+	if transporter, ok := ext.TransporterBy(endpoint.Service.RpcProto()); ok {
+		if validator, ok := transporter.(flux.EndpointValidator); ok {
+			return validator.ValidateEndpoint(endpoint)
+		}
+	}
+	return nil
+}
+
+// bindEndpointListeners 根据Endpoint属性及服务分组，选择WebListener来绑定；同一路由的各版本可属于不同的服务分组，
+// 重复绑定不会重复注册路由，请求时按选中版本校验是否由当前WebListener服务。
+func (s *BootstrapServer) bindEndpointListeners(bind *flux.MVCEndpoint, endpoint *flux.Endpoint, method, pattern string, hosts []string) {
+	ids := s.endpointListenerIds(endpoint)
+	if len(ids) == 0 {
+		logger.WithModule(logger.ModuleDiscovery).Warnw("SERVER:EVENT:ENDPOINT:NO_SERVING_GROUP", "version", endpoint.Version, "method", method, "pattern", pattern)
+	}
+	for _, id := range ids {
+		server, ok := s.WebListenerById(id)
+		if ok {
+			logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:ENDPOINT:HTTP_HANDLER/"+id, "method", method, "pattern", pattern, "hosts", hosts)
+			s.bindHostRoute(server, method, pattern, hosts, bind)
+		} else {
+			logger.WithModule(logger.ModuleDiscovery).Errorw("SERVER:EVENT:ENDPOINT:LISTENER_MISSED/"+id, "method", method, "pattern", pattern)
+		}
+	}
+}
+
+// updateServiceRefs 更新Endpoint对服务的引用关系；服务启动后，引用未注册的服务时输出告警
+func (s *BootstrapServer) updateServiceRefs(owner string, endpoint *flux.Endpoint) {
+	refs := make([]string, 0, 1+len(endpoint.Permissions))
+	// Endpoint只定义ServiceId时，为引用注册中心的服务
+	if !endpoint.Service.IsValid() && endpoint.Service.ServiceId != "" {
+		refs = append(refs, endpoint.Service.ServiceId)
+	}
+	refs = append(refs, endpoint.Permissions...)
+	ext.SetServiceRefs(owner, refs)
+	select {
+	case <-s.started:
+		for _, id := range refs {
+			if !ext.HasTransporterService(id) {
+				logger.LimitedWarnw("SERVER:EVENT:ENDPOINT:SERVICE_MISSING", "endpoint", owner, "service-id", id)
+			}
+		}
+	default:
+		// 启动阶段由路由表检查报告
+	}
+}
+
 // Shutdown to cleanup resources
 func (s *BootstrapServer) Shutdown(ctx goctx.Context) error {
 	logger.Info("Server shutdown...")
@@ -358,7 +895,7 @@ func (s *BootstrapServer) Shutdown(ctx goctx.Context) error {
 // GracefulShutdown
 func (s *BootstrapServer) OnSignalShutdown(quit chan os.Signal, to time.Duration) {
 	// 接收停止信号
-	signal.Notify(quit, dubgo.ShutdownSignals...)
+	signal.Notify(quit, shutdownSignals...)
 	<-quit
 	logger.Infof("Server received shutdown signal, shutdown...")
 	ctx, cancel := goctx.WithTimeout(goctx.Background(), to)
@@ -395,7 +932,39 @@ func (s *BootstrapServer) AddWebHttpHandler(method, pattern string, h http.Handl
 
 // SetWebNotfoundHandler 设置Http路由失败的处理接口到默认ListenerServer
 func (s *BootstrapServer) SetWebNotfoundHandler(nfh flux.WebHandler) {
-	s.defaultListener().SetNotfoundHandler(nfh)
+	s.scopedNotfound().SetFallback(nfh)
+}
+
+// AddWebNotfoundHandler 添加指定Host和Path前缀的Http路由失败处理接口到默认ListenerServer；
+// host为空时匹配全部Host，支持 *.example.com 通配
+func (s *BootstrapServer) AddWebNotfoundHandler(host, prefix string, nfh flux.WebHandler) {
+	s.scopedNotfound().AddScope(host, prefix, nfh)
+}
+
+// SetWebMethodNotAllowedHandler 设置Http路由Method不匹配的处理接口到默认ListenerServer
+func (s *BootstrapServer) SetWebMethodNotAllowedHandler(h flux.WebHandler) {
+	s.scopedNotAllowed().SetFallback(h)
+}
+
+// AddWebMethodNotAllowedHandler 添加指定Host和Path前缀的Http路由Method不匹配处理接口到默认ListenerServer
+func (s *BootstrapServer) AddWebMethodNotAllowedHandler(host, prefix string, h flux.WebHandler) {
+	s.scopedNotAllowed().AddScope(host, prefix, h)
+}
+
+func (s *BootstrapServer) scopedNotfound() *listener.ScopedHandler {
+	if nil == s.notfound {
+		s.notfound = listener.NewScopedHandler(listener.DefaultNotfoundHandler)
+		s.defaultListener().SetNotfoundHandler(s.notfound.Handle)
+	}
+	return s.notfound
+}
+
+func (s *BootstrapServer) scopedNotAllowed() *listener.ScopedHandler {
+	if nil == s.notallowed {
+		s.notallowed = listener.NewScopedHandler(listener.DefaultMethodNotAllowedHandler)
+		s.defaultListener().SetMethodNotAllowedHandler(s.notallowed.Handle)
+	}
+	return s.notallowed
 }
 
 // AddWebListener 添加指定ID
@@ -414,12 +983,6 @@ func (s *BootstrapServer) AddContextHookFunc(f flux.ContextHookFunc) {
 	s.hookFunc = append(s.hookFunc, f)
 }
 
-func (s *BootstrapServer) newEndpointHandler(server flux.WebListener, endpoint *flux.MVCEndpoint) flux.WebHandler {
-	return func(webex flux.ServerWebContext) error {
-		return s.route(webex, server, endpoint)
-	}
-}
-
 func (s *BootstrapServer) selectMultiEndpoint(routeKey string, endpoint *flux.Endpoint) (*flux.MVCEndpoint, bool) {
 	if mve, ok := ext.EndpointByKey(routeKey); ok {
 		return mve, false
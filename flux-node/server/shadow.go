@@ -0,0 +1,149 @@
+package server
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"time"
+)
+
+const (
+	// 影子流量配置：disabled，timeout，max_inflight，max_body
+	ConfigNsShadowTraffic = "shadow_traffic"
+)
+
+const (
+	ConfigKeyShadowTimeout     = "timeout"
+	ConfigKeyShadowMaxInflight = "max_inflight"
+	ConfigKeyShadowMaxBody     = "max_body"
+)
+
+const (
+	ShadowResultSuccess = "success"
+	ShadowResultError   = "error"
+	ShadowResultTimeout = "timeout"
+	ShadowResultDropped = "dropped"
+)
+
+// ShadowTraffic 按Endpoint定义的比例，将请求复制到影子服务执行，影子服务的响应被丢弃，不影响客户端响应；
+// 影子请求使用独立的请求副本（方法，URL，Header，Body）在后台执行，不访问主请求的Context及响应，主请求不等待影子请求完成。
+type ShadowTraffic struct {
+	disabled bool
+	timeout  time.Duration
+	maxBody  int64
+	inflight chan struct{}
+	counter  flux.CounterVec
+	duration flux.HistogramVec
+	labels   *EndpointLabels
+}
+
+func NewShadowTraffic(counter flux.CounterVec, duration flux.HistogramVec, labels *EndpointLabels) *ShadowTraffic {
+	return &ShadowTraffic{
+		counter:  counter,
+		duration: duration,
+		labels:   labels,
+	}
+}
+
+func (s *ShadowTraffic) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		"disabled":                 true,
+		ConfigKeyShadowTimeout:     time.Second * 3,
+		ConfigKeyShadowMaxInflight: 64,
+		ConfigKeyShadowMaxBody:     1024 * 1024,
+	})
+	s.disabled = IsDisabled(config)
+	s.timeout = config.GetDuration(ConfigKeyShadowTimeout)
+	s.maxBody = config.GetInt64(ConfigKeyShadowMaxBody)
+	s.inflight = make(chan struct{}, config.GetInt(ConfigKeyShadowMaxInflight))
+	logger.Infow("Shadow traffic init", "disabled", s.disabled, "timeout", s.timeout, "max-body", s.maxBody,
+		"max-inflight", cap(s.inflight))
+	return nil
+}
+
+// Start 按比例复制请求，在后台执行影子请求；必须在请求处理协程中调用
+func (s *ShadowTraffic) Start(ctx *flux.Context) {
+	if s.disabled {
+		return
+	}
+	endpoint := ctx.Endpoint()
+	id := endpoint.GetAttr(flux.EndpointAttrTagShadowService).GetString()
+	if id == "" {
+		return
+	}
+	// 未定义比例时，复制全部请求
+	if ratio, ok := endpoint.GetAttrEx(flux.EndpointAttrTagShadowRatio); ok && rand.Intn(100) >= ratio.GetInt() {
+		return
+	}
+	service, ok := ext.TransporterServiceById(id)
+	if !ok {
+		logger.LimitedWarnw("SERVER:SHADOW:SERVICE_MISSING", "service-id", id, "pattern", endpoint.HttpPattern)
+		return
+	}
+	body, ok := s.readBody(ctx)
+	if !ok {
+		s.counter.WithLabelValues(id, ShadowResultDropped).Inc()
+		return
+	}
+	select {
+	case s.inflight <- struct{}{}:
+	default:
+		s.counter.WithLabelValues(id, ShadowResultDropped).Inc()
+		return
+	}
+	shadow := flux.NewContext()
+	shadow.Reset(internal.NewDetachedWebContext(ctx.ServerWebContext, body), endpoint)
+	shadow.SetLogger(ctx.Logger())
+	for key, value := range ctx.Attributes() {
+		shadow.SetAttribute(key, value)
+	}
+	pattern, version := s.labels.Labels(endpoint)
+	go func() {
+		defer func() {
+			<-s.inflight
+			if rvr := recover(); nil != rvr {
+				logger.TraceContext(shadow).Errorw("SERVER:SHADOW:PANIC", "service-id", id, "error", rvr)
+			}
+		}()
+		cancel := shadow.WithTimeout(s.timeout)
+		defer cancel()
+		timer := prometheus.NewTimer(s.duration.WithLabelValues("Shadow", service.RpcProto(), pattern, version))
+		response, serr := transporter.DoInvokeCodec(shadow, service)
+		timer.ObserveDuration()
+		if nil != response {
+			if closer, ok := response.Body.(io.Closer); ok {
+				_ = closer.Close()
+			}
+		}
+		switch {
+		case nil == serr:
+			s.counter.WithLabelValues(id, ShadowResultSuccess).Inc()
+		case shadow.Context().Err() == context.DeadlineExceeded:
+			s.counter.WithLabelValues(id, ShadowResultTimeout).Inc()
+		default:
+			s.counter.WithLabelValues(id, ShadowResultError).Inc()
+			logger.TraceContext(shadow).Infow("SERVER:SHADOW:INVOKE/ERROR", "service-id", id, "error", serr)
+		}
+	}()
+}
+
+// readBody 读取请求Body数据用于复制请求；Body超过 max_body 时不复制
+func (s *ShadowTraffic) readBody(ctx *flux.Context) ([]byte, bool) {
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return nil, false
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(reader, s.maxBody+1))
+	if nil != err || int64(len(data)) > s.maxBody {
+		return nil, false
+	}
+	return data, true
+}
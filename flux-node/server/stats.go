@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// Endpoint累计请求统计配置：enable，store，save_interval
+	ConfigNsEndpointStats = "endpoint_stats"
+)
+
+const (
+	ConfigKeyStatsStore        = "store"
+	ConfigKeyStatsSaveInterval = "save_interval"
+)
+
+var (
+	_ prometheus.Collector = new(EndpointStats)
+)
+
+var (
+	statsTotalDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(defaultMetricNamespace, defaultMetricSubsystem, "endpoint_cumulative_total"),
+		"Cumulative number of endpoint requests, persisted across restarts", []string{"Endpoint"}, nil)
+	statsErrorsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(defaultMetricNamespace, defaultMetricSubsystem, "endpoint_cumulative_errors_total"),
+		"Cumulative number of endpoint request errors, persisted across restarts", []string{"Endpoint"}, nil)
+)
+
+// EndpointStat Endpoint的累计请求统计
+type EndpointStat struct {
+	Key    string    `json:"key"`
+	Total  int64     `json:"total"`
+	Errors int64     `json:"errors"`
+	Since  time.Time `json:"since"`
+}
+
+type endpointCounter struct {
+	total  int64
+	errors int64
+	since  time.Time
+}
+
+// EndpointStats 按Endpoint（Method#Pattern#Version）累计请求数及错误数；
+// 服务停止时及按 save_interval 周期保存到本地文件，启动时加载，使统计在重启后延续。
+// 累计统计同时作为Prometheus指标输出，指标值在重启后不归零。
+type EndpointStats struct {
+	store    string
+	interval time.Duration
+	counters map[string]*endpointCounter
+	cancel   context.CancelFunc
+	mu       sync.RWMutex
+}
+
+func NewEndpointStats() *EndpointStats {
+	return &EndpointStats{
+		counters: make(map[string]*endpointCounter, 64),
+	}
+}
+
+func (s *EndpointStats) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyStatsStore:        "./endpoint-stats.json",
+		ConfigKeyStatsSaveInterval: time.Minute,
+	})
+	s.store = config.GetString(ConfigKeyStatsStore)
+	s.interval = config.GetDuration(ConfigKeyStatsSaveInterval)
+	return s.load()
+}
+
+func (s *EndpointStats) Startup() error {
+	if s.interval <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	go func() {
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.save(); nil != err {
+					logger.Warnw("SERVER:STATS:SAVE/ERROR", "store", s.store, "error", err)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+func (s *EndpointStats) Shutdown(_ context.Context) error {
+	if nil != s.cancel {
+		s.cancel()
+	}
+	return s.save()
+}
+
+// Record 记录一次Endpoint请求
+func (s *EndpointStats) Record(key string, failed bool) {
+	s.mu.RLock()
+	counter, ok := s.counters[key]
+	s.mu.RUnlock()
+	if !ok {
+		s.mu.Lock()
+		if counter, ok = s.counters[key]; !ok {
+			counter = &endpointCounter{since: time.Now()}
+			s.counters[key] = counter
+		}
+		s.mu.Unlock()
+	}
+	atomic.AddInt64(&counter.total, 1)
+	if failed {
+		atomic.AddInt64(&counter.errors, 1)
+	}
+}
+
+// Snapshot 返回按Key排序的统计快照
+func (s *EndpointStats) Snapshot() []EndpointStat {
+	s.mu.RLock()
+	out := make([]EndpointStat, 0, len(s.counters))
+	for key, c := range s.counters {
+		out = append(out, EndpointStat{
+			Key:    key,
+			Total:  atomic.LoadInt64(&c.total),
+			Errors: atomic.LoadInt64(&c.errors),
+			Since:  c.since,
+		})
+	}
+	s.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func (s *EndpointStats) Describe(ch chan<- *prometheus.Desc) {
+	ch <- statsTotalDesc
+	ch <- statsErrorsDesc
+}
+
+func (s *EndpointStats) Collect(ch chan<- prometheus.Metric) {
+	for _, stat := range s.Snapshot() {
+		ch <- prometheus.MustNewConstMetric(statsTotalDesc, prometheus.CounterValue, float64(stat.Total), stat.Key)
+		ch <- prometheus.MustNewConstMetric(statsErrorsDesc, prometheus.CounterValue, float64(stat.Errors), stat.Key)
+	}
+}
+
+// load 加载已保存的统计；文件不存在时从零开始
+func (s *EndpointStats) load() error {
+	data, err := ioutil.ReadFile(s.store)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if nil != err {
+		return err
+	}
+	stats := make([]EndpointStat, 0, 64)
+	if err := json.Unmarshal(data, &stats); nil != err {
+		// 文件损坏时不阻止启动，统计从零开始
+		logger.Warnw("SERVER:STATS:LOAD/ERROR", "store", s.store, "error", err)
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stat := range stats {
+		s.counters[stat.Key] = &endpointCounter{total: stat.Total, errors: stat.Errors, since: stat.Since}
+	}
+	logger.Infow("SERVER:STATS:LOADED", "store", s.store, "endpoints", len(stats))
+	return nil
+}
+
+// save 写入临时文件后重命名，避免进程中断时留下不完整的文件
+func (s *EndpointStats) save() error {
+	data, err := json.Marshal(s.Snapshot())
+	if nil != err {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.store), filepath.Base(s.store)+".*")
+	if nil != err {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); nil != err {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); nil != err {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.store)
+}
+
+// initEndpointStats 加载Endpoint累计请求统计配置；未开启时不记录统计
+func (s *BootstrapServer) initEndpointStats() error {
+	config := flux.NewConfigurationOfNS(ConfigNsEndpointStats)
+	if !config.GetBool("enable") {
+		s.stats = nil
+		return nil
+	}
+	if err := s.dispatcher.AddInitHook(s.stats, config); nil != err {
+		return err
+	}
+	// 替换已注册的累计统计指标，例如同一进程内重新创建的Server
+	if err := prometheus.Register(s.stats); nil != err {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			prometheus.Unregister(are.ExistingCollector)
+			return prometheus.Register(s.stats)
+		}
+		return err
+	}
+	return nil
+}
+
+// EndpointStatsHandler 查询Endpoint累计请求统计的管理接口；未开启时返回空列表
+func (s *BootstrapServer) EndpointStatsHandler(webex flux.ServerWebContext) error {
+	if nil == s.stats {
+		return writeJSON(webex, flux.StatusOK, []EndpointStat{})
+	}
+	return writeJSON(webex, flux.StatusOK, s.stats.Snapshot())
+}
+
+// recordEndpointStats 记录Endpoint请求统计
+func (s *BootstrapServer) recordEndpointStats(ctx *flux.Context, serr *flux.ServeError) {
+	if nil == s.stats {
+		return
+	}
+	endpoint := ctx.Endpoint()
+	s.stats.Record(ctx.Method()+"#"+endpoint.HttpPattern+"#"+endpoint.Version, nil != serr)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newEndpointStats(t *testing.T, store string) *EndpointStats {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	stats := NewEndpointStats()
+	assert.NoError(t, stats.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyStatsStore:        store,
+		ConfigKeyStatsSaveInterval: 0,
+	})))
+	return stats
+}
+
+func TestEndpointStats_SaveLoad(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "flux-stats")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	store := filepath.Join(dir, "endpoint-stats.json")
+	// 文件不存在时从零开始
+	stats := newEndpointStats(t, store)
+	assert.Empty(stats.Snapshot())
+	stats.Record("GET#/users#v1", false)
+	stats.Record("GET#/users#v1", true)
+	stats.Record("POST#/orders#v1", false)
+	assert.NoError(stats.Startup())
+	assert.NoError(stats.Shutdown(context.Background()))
+
+	// 重启后加载已保存的统计，并继续累计
+	reloaded := newEndpointStats(t, store)
+	snapshot := reloaded.Snapshot()
+	if assert.Len(snapshot, 2) {
+		assert.Equal("GET#/users#v1", snapshot[0].Key)
+		assert.Equal(int64(2), snapshot[0].Total)
+		assert.Equal(int64(1), snapshot[0].Errors)
+		assert.True(snapshot[0].Since.Equal(stats.Snapshot()[0].Since))
+		assert.Equal(int64(1), snapshot[1].Total)
+	}
+	reloaded.Record("GET#/users#v1", false)
+	assert.Equal(int64(3), reloaded.Snapshot()[0].Total)
+	// 保存时不留下临时文件
+	files, _ := ioutil.ReadDir(dir)
+	assert.Len(files, 1)
+}
+
+func TestEndpointStats_LoadCorrupted(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "flux-stats")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	store := filepath.Join(dir, "endpoint-stats.json")
+	assert.NoError(ioutil.WriteFile(store, []byte(`[{"key":"GET#/users#v1","total":`), 0644))
+	// 文件损坏时不阻止启动，统计从零开始，并在保存时覆盖损坏的文件
+	stats := newEndpointStats(t, store)
+	assert.Empty(stats.Snapshot())
+	stats.Record("GET#/users#v1", false)
+	assert.NoError(stats.save())
+	assert.Len(newEndpointStats(t, store).Snapshot(), 1)
+	// 无法读取的文件返回错误
+	assert.Error(NewEndpointStats().Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyStatsStore: dir,
+	})))
+}
+
+func TestEndpointStats_Analytics(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "flux-stats")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	store := filepath.Join(dir, "endpoint-stats.json")
+	stats := newEndpointStats(t, store)
+	stats.Record("GET#/users#v1", true)
+	stats.Record("POST#/orders#v1", false)
+	assert.NoError(stats.save())
+	// 数据分析汇总及Prometheus指标由持久化的统计延续
+	srv := &BootstrapServer{stats: newEndpointStats(t, store)}
+	summary := srv.Analytics()
+	assert.Equal(int64(2), summary.Total)
+	assert.Equal(int64(1), summary.Errors)
+	assert.Len(summary.Endpoints, 2)
+	expected := `
+# HELP flux_http_endpoint_cumulative_errors_total Cumulative number of endpoint request errors, persisted across restarts
+# TYPE flux_http_endpoint_cumulative_errors_total counter
+flux_http_endpoint_cumulative_errors_total{Endpoint="GET#/users#v1"} 1
+flux_http_endpoint_cumulative_errors_total{Endpoint="POST#/orders#v1"} 0
+`
+	assert.NoError(testutil.CollectAndCompare(srv.stats, strings.NewReader(expected), "flux_http_endpoint_cumulative_errors_total"))
+	// 未开启时返回空的汇总
+	summary = (&BootstrapServer{}).Analytics()
+	assert.Equal(int64(0), summary.Total)
+	assert.Empty(summary.Endpoints)
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// 启动摘要配置：enable，output
+	ConfigNsStartupSummary = "startup_summary"
+)
+
+const (
+	// 启动摘要输出位置：stdout，stderr，或文件路径
+	ConfigKeySummaryOutput = "output"
+)
+
+const (
+	SummaryOutputStdout = "stdout"
+	SummaryOutputStderr = "stderr"
+)
+
+// StartupSummary 机器可读的启动摘要，供部署工具校验发布配置
+type StartupSummary struct {
+	Time         time.Time         `json:"time"`
+	Build        SummaryBuild      `json:"build"`
+	Listeners    []SummaryListener `json:"listeners"`
+	Discoveries  []string          `json:"discoveries"`
+	Filters      []string          `json:"filters"`
+	Transporters []string          `json:"transporters"`
+	Endpoints    int               `json:"endpoints"`
+	Services     int               `json:"services"`
+	Components   []ComponentInfo   `json:"components"`
+}
+
+type SummaryBuild struct {
+	CommitId string `json:"commitId"`
+	Version  string `json:"version"`
+	Date     string `json:"date"`
+}
+
+type SummaryListener struct {
+	Id      string `json:"id"`
+	Address string `json:"address"`
+	TLS     bool   `json:"tls"`
+}
+
+// StartupSummary 返回当前服务的启动摘要
+func (s *BootstrapServer) StartupSummary() StartupSummary {
+	summary := StartupSummary{
+		Time:         time.Now(),
+		Build:        SummaryBuild{CommitId: s.build.CommitId, Version: s.build.Version, Date: s.build.Date},
+		Listeners:    make([]SummaryListener, 0, len(s.listener)),
+		Discoveries:  make([]string, 0, 2),
+		Filters:      make([]string, 0, 8),
+		Transporters: make([]string, 0, 4),
+		Endpoints:    len(ext.Endpoints()),
+		Services:     len(ext.TransporterServices()),
+		Components:   s.Components(),
+	}
+	for id := range s.listener {
+		config := flux.NewConfigurationOfNS(flux.NamespaceWebListeners + "." + id)
+		address, err := common.JoinListenAddress(config.GetString("address"), config.GetString("bind_port"))
+		if nil != err {
+			address = config.GetString("address")
+		}
+		summary.Listeners = append(summary.Listeners, SummaryListener{
+			Id: id, Address: address, TLS: config.GetString("tls_cert_file") != "" || config.IsSet("tls_cert"),
+		})
+	}
+	sort.Slice(summary.Listeners, func(i, j int) bool {
+		return summary.Listeners[i].Id < summary.Listeners[j].Id
+	})
+	for _, c := range summary.Components {
+		if !c.Enabled {
+			continue
+		}
+		switch c.Kind {
+		case ComponentKindDiscovery:
+			summary.Discoveries = append(summary.Discoveries, c.Id)
+		case ComponentKindFilter:
+			summary.Filters = append(summary.Filters, c.Id)
+		case ComponentKindTransporter:
+			summary.Transporters = append(summary.Transporters, c.Id)
+		}
+	}
+	return summary
+}
+
+// StartupSummaryHandler 查询启动摘要的管理接口
+func (s *BootstrapServer) StartupSummaryHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.StartupSummary())
+}
+
+// emitStartupSummary 按配置将启动摘要以JSON格式输出到标准输出或文件
+func (s *BootstrapServer) emitStartupSummary() error {
+	config := flux.NewConfigurationOfNS(ConfigNsStartupSummary)
+	if !config.GetBool("enable") {
+		return nil
+	}
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeySummaryOutput: SummaryOutputStdout,
+	})
+	data, err := json.Marshal(s.StartupSummary())
+	if nil != err {
+		return err
+	}
+	data = append(data, '\n')
+	switch output := config.GetString(ConfigKeySummaryOutput); output {
+	case SummaryOutputStdout:
+		_, err = os.Stdout.Write(data)
+	case SummaryOutputStderr:
+		_, err = os.Stderr.Write(data)
+	default:
+		err = ioutil.WriteFile(output, data, 0644)
+	}
+	return err
+}
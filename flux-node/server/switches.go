@@ -0,0 +1,198 @@
+package server
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ErrorCodeEndpointDisabled = "GATEWAY:ENDPOINT_DISABLED"
+)
+
+// EndpointInfo 管理接口返回的Endpoint信息；Id 由 Method，Pattern，Version 计算，用于启用/停用操作
+type EndpointInfo struct {
+	Id         string     `json:"id"`
+	Method     string     `json:"method"`
+	Pattern    string     `json:"pattern"`
+	Version    string     `json:"version"`
+	Proto      string     `json:"proto"`
+	ServiceId  string     `json:"serviceId"`
+	Disabled   bool       `json:"disabled"`
+	DisabledAt *time.Time `json:"disabledAt,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+}
+
+type endpointSwitch struct {
+	at     time.Time
+	reason string
+}
+
+// endpointSwitches 运行时停用的Endpoint；停用状态只保存在本节点内存中，不修改注册中心的元数据，
+// Endpoint被注册中心删除后重新注册时，仍保持停用状态，直到通过管理接口启用。
+type endpointSwitches struct {
+	disabled map[string]endpointSwitch
+	mu       sync.RWMutex
+}
+
+func newEndpointSwitches() *endpointSwitches {
+	return &endpointSwitches{disabled: make(map[string]endpointSwitch, 4)}
+}
+
+// EndpointId 返回Endpoint的管理标识；定义Host的Endpoint，标识包含Host列表
+func EndpointId(endpoint *flux.Endpoint) string {
+	key := endpoint.HttpMethod + "#" + endpoint.HttpPattern + "#" + endpoint.Version
+	if hosts := endpoint.Hosts(); len(hosts) > 0 {
+		key += "@" + strings.Join(hosts, ",")
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (w *endpointSwitches) isDisabled(endpoint *flux.Endpoint) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if len(w.disabled) == 0 {
+		return false
+	}
+	_, ok := w.disabled[EndpointId(endpoint)]
+	return ok
+}
+
+func (w *endpointSwitches) lookup(id string) (endpointSwitch, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	sw, ok := w.disabled[id]
+	return sw, ok
+}
+
+func (w *endpointSwitches) disable(id, reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.disabled[id] = endpointSwitch{at: time.Now(), reason: reason}
+}
+
+func (w *endpointSwitches) enable(id string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.disabled, id)
+}
+
+// verifyEndpointEnabled 停用的Endpoint返回503错误
+func (s *BootstrapServer) verifyEndpointEnabled(ctx *flux.Context) *flux.ServeError {
+	if !s.switches.isDisabled(ctx.Endpoint()) {
+		return nil
+	}
+	return &flux.ServeError{
+		StatusCode: flux.StatusUnavailable,
+		ErrorCode:  ErrorCodeEndpointDisabled,
+		Message:    "ROUTE:ENDPOINT_DISABLED",
+	}
+}
+
+// EndpointInfos 返回已注册的Endpoint列表；按 method，pattern，version，proto 过滤，pattern 支持 * 结尾的前缀匹配
+func (s *BootstrapServer) EndpointInfos(method, pattern, version, proto string) []EndpointInfo {
+	out := make([]EndpointInfo, 0, 64)
+	for _, mve := range ext.Endpoints() {
+		for _, ep := range mve.Endpoints() {
+			if !matchEndpointFilter(ep, method, pattern, version, proto) {
+				continue
+			}
+			info := EndpointInfo{
+				Id:        EndpointId(ep),
+				Method:    ep.HttpMethod,
+				Pattern:   ep.HttpPattern,
+				Version:   ep.Version,
+				Proto:     ep.Service.RpcProto(),
+				ServiceId: ep.Service.ServiceID(),
+			}
+			if sw, ok := s.switches.lookup(info.Id); ok {
+				at := sw.at
+				info.Disabled, info.DisabledAt, info.Reason = true, &at, sw.reason
+			}
+			out = append(out, info)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Pattern != out[j].Pattern {
+			return out[i].Pattern < out[j].Pattern
+		}
+		if out[i].Method != out[j].Method {
+			return out[i].Method < out[j].Method
+		}
+		return out[i].Version < out[j].Version
+	})
+	return out
+}
+
+// EndpointListHandler 查询Endpoint列表的管理接口；参数：method，pattern，version，proto
+func (s *BootstrapServer) EndpointListHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.EndpointInfos(webex.QueryVar("method"), webex.QueryVar("pattern"),
+		webex.QueryVar("version"), webex.QueryVar("proto")))
+}
+
+// EndpointDisableHandler 停用Endpoint的管理接口；路径参数：id；参数：reason
+func (s *BootstrapServer) EndpointDisableHandler(webex flux.ServerWebContext) error {
+	id := webex.PathVar("id")
+	info, ok := s.endpointInfoById(id)
+	if !ok {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "endpoint not found: " + id})
+	}
+	reason := webex.QueryVar("reason")
+	s.switches.disable(id, reason)
+	logger.Infow("SERVER:ENDPOINT:DISABLED", "endpoint-id", id, "method", info.Method, "pattern", info.Pattern,
+		"version", info.Version, "reason", reason)
+	after, _ := s.endpointInfoById(id)
+	s.auditChanged(webex, AuditActionEndpointOff, id, info, after)
+	return writeJSON(webex, flux.StatusOK, after)
+}
+
+// EndpointEnableHandler 启用Endpoint的管理接口；路径参数：id
+func (s *BootstrapServer) EndpointEnableHandler(webex flux.ServerWebContext) error {
+	id := webex.PathVar("id")
+	info, ok := s.endpointInfoById(id)
+	if !ok {
+		return writeJSON(webex, flux.StatusNotFound, map[string]string{"error": "endpoint not found: " + id})
+	}
+	s.switches.enable(id)
+	logger.Infow("SERVER:ENDPOINT:ENABLED", "endpoint-id", id, "method", info.Method, "pattern", info.Pattern,
+		"version", info.Version)
+	after := info
+	after.Disabled, after.DisabledAt, after.Reason = false, nil, ""
+	s.auditChanged(webex, AuditActionEndpointOn, id, info, after)
+	return writeJSON(webex, flux.StatusOK, after)
+}
+
+func (s *BootstrapServer) endpointInfoById(id string) (EndpointInfo, bool) {
+	for _, info := range s.EndpointInfos("", "", "", "") {
+		if info.Id == id {
+			return info, true
+		}
+	}
+	return EndpointInfo{}, false
+}
+
+func matchEndpointFilter(ep *flux.Endpoint, method, pattern, version, proto string) bool {
+	if method != "" && !strings.EqualFold(method, ep.HttpMethod) {
+		return false
+	}
+	if version != "" && version != ep.Version {
+		return false
+	}
+	if proto != "" && !strings.EqualFold(proto, ep.Service.RpcProto()) {
+		return false
+	}
+	if pattern == "" {
+		return true
+	}
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(ep.HttpPattern, pattern[:len(pattern)-1])
+	}
+	return pattern == ep.HttpPattern
+}
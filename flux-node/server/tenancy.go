@@ -0,0 +1,405 @@
+package server
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/spf13/cast"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 多租户配置：disabled，sources，header，claim，default，required，tenants
+	ConfigNsTenancy = "tenancy"
+)
+
+const (
+	ConfigKeyTenancySources  = "sources"
+	ConfigKeyTenancyHeader   = "header"
+	ConfigKeyTenancyClaim    = "claim"
+	ConfigKeyTenancyDefault  = "default"
+	ConfigKeyTenancyRequired = "required"
+	ConfigKeyTenancyTenants  = "tenants"
+)
+
+const (
+	ConfigKeyTenantHosts           = "hosts"
+	ConfigKeyTenantRate            = "rate"
+	ConfigKeyTenantQuota           = "quota"
+	ConfigKeyTenantQuotaPeriod     = "quota_period"
+	ConfigKeyTenantEndpoints       = "endpoints"
+	ConfigKeyTenantDisabledFilters = "disabled_filters"
+	ConfigKeyTenantFilters         = "filters"
+	ConfigKeyTenantAttributes      = "attributes"
+)
+
+const (
+	// 租户识别来源：Host，Header，JWT Claim；默认只按Host识别，Header及Claim来源须显式配置
+	TenantSourceHost   = "host"
+	TenantSourceHeader = "header"
+	TenantSourceClaim  = "claim"
+)
+
+const (
+	ErrorCodeTenantUnknown       = "TENANT:UNKNOWN"
+	ErrorCodeTenantForbidden     = "TENANT:ENDPOINT_FORBIDDEN"
+	ErrorCodeTenantQuotaExceeded = "TENANT:QUOTA_EXCEEDED"
+)
+
+const (
+	tenantResultAccepted  = "accepted"
+	tenantResultUnknown   = "unknown"
+	tenantResultForbidden = "forbidden"
+	tenantResultLimited   = "rate_limited"
+	tenantResultExhausted = "quota_exceeded"
+)
+
+// TenantUsage 租户的配额使用情况
+type TenantUsage struct {
+	Id          string    `json:"id"`
+	Rate        float64   `json:"rate"`
+	Quota       int64     `json:"quota"`
+	QuotaUsed   int64     `json:"quotaUsed"`
+	QuotaPeriod string    `json:"quotaPeriod,omitempty"`
+	QuotaSince  time.Time `json:"quotaSince"`
+}
+
+// tenantState 租户定义及运行时的限流，配额状态
+type tenantState struct {
+	tenant    *flux.Tenant
+	hosts     []string
+	endpoints []string
+	rate      float64
+	quota     int64
+	period    time.Duration
+	// 令牌桶：容量为每秒速率
+	tokens float64
+	refill time.Time
+	used   int64
+	since  time.Time
+	mu     sync.Mutex
+}
+
+// Tenancy 多租户隔离：在ContextHook中按 Host，Header 识别请求所属租户，
+// 按租户限制请求速率及周期配额，检查Endpoint可见性，并按租户统计请求指标；
+// Filter可通过 flux.TenantOf 读取租户的覆盖配置，租户停用的Filter在选择Filter时被跳过。
+// JWT Claim 来源读取JWT Filter校验通过的Token声明，在Filter执行之后识别租户，只在其它来源未识别租户时使用；
+// 按Claim识别的租户，其停用的Filter不生效。
+type Tenancy struct {
+	enabled  bool
+	claimed  bool
+	sources  []string
+	header   string
+	claim    string
+	fallback string
+	required bool
+	tenants  map[string]*tenantState
+	counter  flux.CounterVec
+}
+
+func NewTenancy(counter flux.CounterVec) *Tenancy {
+	return &Tenancy{
+		tenants: make(map[string]*tenantState, 4),
+		counter: counter,
+	}
+}
+
+func (t *Tenancy) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		"disabled":              true,
+		ConfigKeyTenancySources: []string{TenantSourceHost},
+		ConfigKeyTenancyHeader:  "X-Tenant-Id",
+		ConfigKeyTenancyClaim:   "tenant",
+	})
+	if IsDisabled(config) {
+		return nil
+	}
+	t.sources = config.GetStringSlice(ConfigKeyTenancySources)
+	for _, source := range t.sources {
+		switch source {
+		case TenantSourceClaim:
+			t.claimed = true
+		case TenantSourceHost, TenantSourceHeader:
+		default:
+			return fmt.Errorf("unknown tenant source: %s", source)
+		}
+	}
+	t.header = config.GetString(ConfigKeyTenancyHeader)
+	t.claim = config.GetString(ConfigKeyTenancyClaim)
+	t.fallback = config.GetString(ConfigKeyTenancyDefault)
+	t.required = config.GetBool(ConfigKeyTenancyRequired)
+	for id := range config.GetStringMap(ConfigKeyTenancyTenants) {
+		tc := config.Sub(ConfigKeyTenancyTenants + "." + id)
+		tc.SetDefault(ConfigKeyTenantQuotaPeriod, time.Hour*24)
+		state := &tenantState{
+			tenant: &flux.Tenant{
+				Id:              id,
+				DisabledFilters: tc.GetStringSlice(ConfigKeyTenantDisabledFilters),
+				Attributes:      tc.GetStringMap(ConfigKeyTenantAttributes),
+				FilterConfigs:   make(map[string]*flux.Configuration, 2),
+			},
+			hosts:     tc.GetStringSlice(ConfigKeyTenantHosts),
+			endpoints: tc.GetStringSlice(ConfigKeyTenantEndpoints),
+			rate:      tc.GetFloat64(ConfigKeyTenantRate),
+			quota:     tc.GetInt64(ConfigKeyTenantQuota),
+			period:    tc.GetDuration(ConfigKeyTenantQuotaPeriod),
+		}
+		for filterId := range tc.GetStringMap(ConfigKeyTenantFilters) {
+			state.tenant.FilterConfigs[filterId] = tc.Sub(ConfigKeyTenantFilters + "." + filterId)
+		}
+		now := time.Now()
+		state.tokens, state.refill, state.since = state.rate, now, now
+		t.tenants[id] = state
+		logger.Infow("SERVER:TENANCY:TENANT", "tenant-id", id, "hosts", state.hosts, "rate", state.rate,
+			"quota", state.quota, "quota-period", state.period, "endpoints", state.endpoints)
+	}
+	if t.fallback != "" {
+		if _, ok := t.tenants[t.fallback]; !ok {
+			return fmt.Errorf("default tenant not defined: %s", t.fallback)
+		}
+	}
+	t.enabled = true
+	logger.Infow("SERVER:TENANCY:INIT", "sources", t.sources, "header", t.header, "claim", t.claim,
+		"default", t.fallback, "required", t.required, "tenants", len(t.tenants))
+	return nil
+}
+
+// Enabled 返回是否启用多租户
+func (t *Tenancy) Enabled() bool {
+	return t.enabled
+}
+
+// ClaimEnabled 返回是否按JWT Claim识别租户
+func (t *Tenancy) ClaimEnabled() bool {
+	return t.enabled && t.claimed
+}
+
+// Resolve 识别请求所属租户，设置到Context的Attribute；按 sources 顺序，使用第一个识别到的已定义租户。
+// 启用Claim来源时，未识别的请求在Filter执行之后由 VerifyClaim 识别，默认租户也在其中设置。
+func (t *Tenancy) Resolve(webex flux.ServerWebContext, ctx *flux.Context) {
+	if !t.enabled {
+		return
+	}
+	for _, source := range t.sources {
+		if state, ok := t.lookup(t.resolveId(source, webex)); ok {
+			t.bind(ctx, state)
+			return
+		}
+	}
+	if t.claimed {
+		return
+	}
+	if state, ok := t.lookup(t.fallback); ok {
+		t.bind(ctx, state)
+	}
+}
+
+func (t *Tenancy) resolveId(source string, webex flux.ServerWebContext) string {
+	switch source {
+	case TenantSourceHeader:
+		return webex.HeaderVar(t.header)
+	case TenantSourceHost:
+		host := webex.Host()
+		if h, _, err := net.SplitHostPort(host); nil == err {
+			host = h
+		}
+		for id, state := range t.tenants {
+			for _, pattern := range state.hosts {
+				if fluxpkg.MatchHostPattern(pattern, host) {
+					return id
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func (t *Tenancy) lookup(id string) (*tenantState, bool) {
+	if id == "" {
+		return nil, false
+	}
+	state, ok := t.tenants[id]
+	return state, ok
+}
+
+// bind 设置请求所属租户，以及租户定义的Attributes
+func (t *Tenancy) bind(ctx *flux.Context, state *tenantState) {
+	for key, value := range state.tenant.Attributes {
+		ctx.SetAttribute(key, value)
+	}
+	ctx.SetAttribute(flux.XTenantId, state.tenant.Id)
+	ctx.SetAttribute(flux.XTenant, state.tenant)
+}
+
+// Verify 检查租户的Endpoint可见性，请求速率及配额；启用Claim来源时，未识别租户的请求由 VerifyClaim 检查
+func (t *Tenancy) Verify(ctx *flux.Context) *flux.ServeError {
+	if !t.enabled {
+		return nil
+	}
+	tenant, ok := flux.TenantOf(ctx)
+	if !ok {
+		if t.claimed {
+			return nil
+		}
+		return t.unknown()
+	}
+	return t.verify(ctx, tenant)
+}
+
+// VerifyClaim 在Filter执行之后，按JWT Filter校验通过的Token声明识别租户并检查；已识别租户的请求不再处理
+func (t *Tenancy) VerifyClaim(ctx *flux.Context) *flux.ServeError {
+	if !t.ClaimEnabled() {
+		return nil
+	}
+	if _, ok := flux.TenantOf(ctx); ok {
+		return nil
+	}
+	state, ok := t.lookup(jwtClaim(ctx, t.claim))
+	if !ok {
+		state, ok = t.lookup(t.fallback)
+	}
+	if !ok {
+		return t.unknown()
+	}
+	t.bind(ctx, state)
+	return t.verify(ctx, state.tenant)
+}
+
+func (t *Tenancy) unknown() *flux.ServeError {
+	if !t.required {
+		return nil
+	}
+	t.counter.WithLabelValues("", tenantResultUnknown).Inc()
+	return &flux.ServeError{
+		StatusCode: flux.StatusAccessDenied,
+		ErrorCode:  ErrorCodeTenantUnknown,
+		Message:    "TENANT:UNKNOWN",
+	}
+}
+
+func (t *Tenancy) verify(ctx *flux.Context, tenant *flux.Tenant) *flux.ServeError {
+	state := t.tenants[tenant.Id]
+	if !state.visible(ctx.Endpoint()) {
+		t.counter.WithLabelValues(tenant.Id, tenantResultForbidden).Inc()
+		return &flux.ServeError{
+			StatusCode: flux.StatusNotFound,
+			ErrorCode:  ErrorCodeTenantForbidden,
+			Message:    "TENANT:ENDPOINT_FORBIDDEN",
+		}
+	}
+	if result, wait := state.acquire(time.Now()); result != tenantResultAccepted {
+		t.counter.WithLabelValues(tenant.Id, result).Inc()
+		code := flux.ErrorCodeGatewayRateLimited
+		if result == tenantResultExhausted {
+			code = ErrorCodeTenantQuotaExceeded
+		}
+		return &flux.ServeError{
+			StatusCode: flux.StatusTooMany,
+			ErrorCode:  code,
+			Message:    "TENANT:" + strings.ToUpper(result),
+			Header:     map[string][]string{flux.HeaderRetryAfter: {strconv.FormatInt(int64(wait/time.Second)+1, 10)}},
+		}
+	}
+	t.counter.WithLabelValues(tenant.Id, tenantResultAccepted).Inc()
+	return nil
+}
+
+// Usages 返回各租户的配额使用情况
+func (t *Tenancy) Usages() []TenantUsage {
+	out := make([]TenantUsage, 0, len(t.tenants))
+	for id, state := range t.tenants {
+		state.mu.Lock()
+		usage := TenantUsage{Id: id, Rate: state.rate, Quota: state.quota, QuotaUsed: state.used, QuotaSince: state.since}
+		state.mu.Unlock()
+		if state.quota > 0 {
+			usage.QuotaPeriod = state.period.String()
+		}
+		out = append(out, usage)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Id < out[j].Id
+	})
+	return out
+}
+
+// visible 判断Endpoint对租户是否可见：Endpoint属性 tenants 限定的租户，以及租户 endpoints 限定的Endpoint模式
+func (s *tenantState) visible(endpoint *flux.Endpoint) bool {
+	if attrs := endpoint.GetAttrs(flux.EndpointAttrTagTenants); len(attrs) > 0 {
+		allowed := false
+		for _, attr := range attrs {
+			if fluxpkg.StringSliceContains(attr.GetStringSlice(), s.tenant.Id) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	if len(s.endpoints) == 0 {
+		return true
+	}
+	for _, pattern := range s.endpoints {
+		if matchEndpointFilter(endpoint, "", pattern, "", "") {
+			return true
+		}
+	}
+	return false
+}
+
+// acquire 按令牌桶限制请求速率，按固定周期窗口限制请求配额；返回拒绝结果及建议的重试等待时间
+func (s *tenantState) acquire(now time.Time) (string, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.quota > 0 {
+		if now.Sub(s.since) >= s.period {
+			s.since, s.used = now, 0
+		}
+		if s.used >= s.quota {
+			return tenantResultExhausted, s.since.Add(s.period).Sub(now)
+		}
+	}
+	if s.rate > 0 {
+		s.tokens += now.Sub(s.refill).Seconds() * s.rate
+		if s.tokens > s.rate {
+			s.tokens = s.rate
+		}
+		s.refill = now
+		if s.tokens < 1 {
+			return tenantResultLimited, time.Duration((1 - s.tokens) / s.rate * float64(time.Second))
+		}
+		s.tokens--
+	}
+	s.used++
+	return tenantResultAccepted, 0
+}
+
+// jwtClaim 读取JWT Filter校验通过的Token声明中的字符串Claim
+func jwtClaim(ctx *flux.Context, claim string) string {
+	v, ok := ctx.GetVariable(flux.VariableKeyJWTClaims)
+	if !ok {
+		return ""
+	}
+	// 声明的值类型为 jwt.MapClaims 等以字符串为Key的Map
+	claims := reflect.ValueOf(v)
+	if claims.Kind() != reflect.Map || claims.Type().Key().Kind() != reflect.String {
+		return ""
+	}
+	value := claims.MapIndex(reflect.ValueOf(claim).Convert(claims.Type().Key()))
+	if !value.IsValid() {
+		return ""
+	}
+	return cast.ToString(value.Interface())
+}
+
+// TenantsHandler 查询租户配额使用情况的管理接口
+func (s *BootstrapServer) TenantsHandler(webex flux.ServerWebContext) error {
+	return writeJSON(webex, flux.StatusOK, s.tenancy.Usages())
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	// Endpoint注册校验配置：disabled，webhook，timeout，fail_open
+	ConfigNsEndpointValidation = "endpoint_validation"
+)
+
+const (
+	ConfigKeyValidationWebhook  = "webhook"
+	ConfigKeyValidationTimeout  = "timeout"
+	ConfigKeyValidationFailOpen = "fail_open"
+)
+
+const (
+	ValidationResultAccepted = "accepted"
+	ValidationResultMutated  = "mutated"
+	ValidationResultRejected = "rejected"
+)
+
+// ValidationWebhookRequest 校验Webhook的请求数据
+type ValidationWebhookRequest struct {
+	EventType string        `json:"eventType"`
+	Source    string        `json:"source"`
+	Endpoint  flux.Endpoint `json:"endpoint"`
+}
+
+// ValidationWebhookResponse 校验Webhook的响应数据；Endpoint不为空时，替换事件中的Endpoint定义
+type ValidationWebhookResponse struct {
+	Allowed  bool           `json:"allowed"`
+	Message  string         `json:"message"`
+	Endpoint *flux.Endpoint `json:"endpoint,omitempty"`
+}
+
+// NewWebhookEndpointValidator 创建通过外部Webhook校验Endpoint定义的校验函数；
+// failOpen 为true时，Webhook请求失败（网络错误，非200状态码）视为通过。
+func NewWebhookEndpointValidator(url string, timeout time.Duration, failOpen bool) flux.EndpointValidateFunc {
+	client := &http.Client{Timeout: timeout}
+	return func(event *flux.EndpointEvent) error {
+		resp, err := postValidationWebhook(client, url, event)
+		if nil != err {
+			if failOpen {
+				logger.WithModule(logger.ModuleDiscovery).Warnw("SERVER:EVENT:ENDPOINT:VALIDATE/WEBHOOK_ERROR", "webhook", url, "error", err)
+				return nil
+			}
+			return err
+		}
+		if !resp.Allowed {
+			return errors.New(resp.Message)
+		}
+		if nil != resp.Endpoint {
+			event.Endpoint = *resp.Endpoint
+		}
+		return nil
+	}
+}
+
+func postValidationWebhook(client *http.Client, url string, event *flux.EndpointEvent) (*ValidationWebhookResponse, error) {
+	data, err := ext.JSONMarshal(ValidationWebhookRequest{
+		EventType: eventTypeName(event.EventType),
+		Source:    event.Source,
+		Endpoint:  event.Endpoint,
+	})
+	if nil != err {
+		return nil, err
+	}
+	resp, err := client.Post(url, flux.MIMEApplicationJSONCharsetUTF8, bytes.NewReader(data))
+	if nil != err {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("validation webhook returns status: %d", resp.StatusCode)
+	}
+	out := new(ValidationWebhookResponse)
+	if err := ext.JSONUnmarshal(body, out); nil != err {
+		return nil, fmt.Errorf("decode validation webhook response, err: %w", err)
+	}
+	return out, nil
+}
+
+// initEndpointValidation 加载Endpoint注册校验Webhook配置
+func (s *BootstrapServer) initEndpointValidation() {
+	config := flux.NewConfigurationOfNS(ConfigNsEndpointValidation)
+	if IsDisabled(config) {
+		return
+	}
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyValidationTimeout:  time.Second * 3,
+		ConfigKeyValidationFailOpen: false,
+	})
+	if url := config.GetString(ConfigKeyValidationWebhook); url != "" {
+		logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:ENDPOINT:VALIDATE/WEBHOOK", "webhook", url)
+		ext.AddEndpointValidator(NewWebhookEndpointValidator(url,
+			config.GetDuration(ConfigKeyValidationTimeout), config.GetBool(ConfigKeyValidationFailOpen)))
+	}
+}
+
+// startEndpointValidation 在独立协程中按接收顺序校验Endpoint事件，校验通过的事件转发到事件循环；
+// Webhook校验不阻塞事件循环处理服务事件。同步屏障在已接收的事件校验并转发后，再转发到事件循环。
+func (s *BootstrapServer) startEndpointValidation(ctx context.Context, in <-chan flux.EndpointEvent,
+	barrier <-chan chan struct{}) (<-chan flux.EndpointEvent, <-chan chan struct{}) {
+	out := make(chan flux.EndpointEvent, cap(in))
+	next := make(chan chan struct{})
+	forward := func(event flux.EndpointEvent) bool {
+		if !s.validateEndpointEvent(&event) {
+			return true
+		}
+		select {
+		case out <- event:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	go func() {
+		for {
+			select {
+			case event, ok := <-in:
+				if !ok || !forward(event) {
+					return
+				}
+
+			case ack := <-barrier:
+				for drained := false; !drained; {
+					select {
+					case event := <-in:
+						if !forward(event) {
+							return
+						}
+					default:
+						drained = true
+					}
+				}
+				select {
+				case next <- ack:
+				case <-ctx.Done():
+					return
+				}
+
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, next
+}
+
+// validateEndpointEvent 按顺序执行全部校验函数；删除事件不作校验
+func (s *BootstrapServer) validateEndpointEvent(event *flux.EndpointEvent) bool {
+	validators := ext.EndpointValidators()
+	if event.EventType == flux.EventTypeRemoved || len(validators) == 0 {
+		return true
+	}
+	origin, _ := flattenDefinition(event.Endpoint)
+	for _, validate := range validators {
+		if err := validate(event); nil != err {
+			s.dispatcher.metrics.Validation.WithLabelValues(event.Source, ValidationResultRejected).Inc()
+			logger.WithModule(logger.ModuleDiscovery).Warnw("SERVER:EVENT:ENDPOINT:VALIDATE/REJECTED", "source", event.Source,
+				"method", event.Endpoint.HttpMethod, "pattern", event.Endpoint.HttpPattern, "version", event.Endpoint.Version,
+				"error", err)
+			return false
+		}
+	}
+	// 按字段比较校验前后的Endpoint定义
+	mutated, _ := flattenDefinition(event.Endpoint)
+	if changes := diffFields(origin, mutated); len(changes) > 0 {
+		fields := make([]string, len(changes))
+		for i, change := range changes {
+			fields[i] = change.Field
+		}
+		s.dispatcher.metrics.Validation.WithLabelValues(event.Source, ValidationResultMutated).Inc()
+		logger.WithModule(logger.ModuleDiscovery).Infow("SERVER:EVENT:ENDPOINT:VALIDATE/MUTATED", "source", event.Source,
+			"method", event.Endpoint.HttpMethod, "pattern", event.Endpoint.HttpPattern, "fields", fields)
+	} else {
+		s.dispatcher.metrics.Validation.WithLabelValues(event.Source, ValidationResultAccepted).Inc()
+	}
+	return true
+}
+
+func eventTypeName(t flux.EventType) string {
+	switch t {
+	case flux.EventTypeAdded:
+		return "added"
+	case flux.EventTypeUpdated:
+		return "updated"
+	default:
+		return "removed"
+	}
+}
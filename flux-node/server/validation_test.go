@@ -0,0 +1,108 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const validationTestSource = "validation-test"
+
+func init() {
+	ext.AddEndpointValidator(func(event *flux.EndpointEvent) error {
+		if event.Source != validationTestSource {
+			return nil
+		}
+		switch event.Endpoint.HttpPattern {
+		case "/reject":
+			return errors.New("rejected")
+		case "/mutate":
+			// 原地修改属性值
+			event.Endpoint.Attributes[0].Value = "mutated"
+		case "/slow":
+			time.Sleep(time.Millisecond * 100)
+		}
+		return nil
+	})
+}
+
+// resultCounterVec 记录校验结果标签
+type resultCounterVec struct {
+	mu      sync.Mutex
+	results []string
+}
+
+func (c *resultCounterVec) WithLabelValues(values ...string) flux.Counter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, strings.Join(values, "/"))
+	return nopCounter{}
+}
+
+func newValidationServer() (*BootstrapServer, *resultCounterVec) {
+	counter := new(resultCounterVec)
+	return &BootstrapServer{dispatcher: &Dispatcher{metrics: &Metrics{Validation: counter}}}, counter
+}
+
+func newValidationEvent(pattern string) flux.EndpointEvent {
+	endpoint := flux.Endpoint{HttpMethod: "GET", HttpPattern: pattern}
+	endpoint.Attributes = []flux.Attribute{{Name: "owner", Value: "team"}}
+	return flux.EndpointEvent{EventType: flux.EventTypeAdded, Endpoint: endpoint, Source: validationTestSource}
+}
+
+func TestBootstrapServer_ValidateEndpointEvent(t *testing.T) {
+	assert := assert.New(t)
+	s, counter := newValidationServer()
+	for _, pattern := range []string{"/accept", "/mutate", "/reject"} {
+		event := newValidationEvent(pattern)
+		s.validateEndpointEvent(&event)
+	}
+	assert.Equal([]string{
+		validationTestSource + "/" + ValidationResultAccepted,
+		validationTestSource + "/" + ValidationResultMutated,
+		validationTestSource + "/" + ValidationResultRejected,
+	}, counter.results)
+}
+
+func TestBootstrapServer_EndpointValidationStage(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := newValidationServer()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	in := make(chan flux.EndpointEvent, 4)
+	barrier := make(chan chan struct{})
+	out, next := s.startEndpointValidation(ctx, in, barrier)
+	in <- newValidationEvent("/slow")
+	in <- newValidationEvent("/reject")
+	in <- newValidationEvent("/accept")
+	ack := make(chan struct{})
+	barrier <- ack
+	// 同步屏障在已接收的事件校验并转发后转发，被拒绝的事件不转发
+	patterns := make([]string, 0, 2)
+	for forwarded := false; !forwarded; {
+		select {
+		case event := <-out:
+			patterns = append(patterns, event.Endpoint.HttpPattern)
+		case acked := <-next:
+			assert.Equal(ack, acked)
+			forwarded = true
+		case <-time.After(time.Second):
+			t.Fatal("barrier must be forwarded after validation")
+		}
+	}
+	for drained := false; !drained; {
+		select {
+		case event := <-out:
+			patterns = append(patterns, event.Endpoint.HttpPattern)
+		default:
+			drained = true
+		}
+	}
+	assert.Equal([]string{"/slow", "/accept"}, patterns)
+}
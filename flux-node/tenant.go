@@ -0,0 +1,50 @@
+package flux
+
+import (
+	"github.com/bytepowered/flux/flux-pkg"
+)
+
+const (
+	// XTenantId 请求所属租户ID的Attribute键
+	XTenantId = "X-Tenant-Id"
+	// XTenant 请求所属租户的Attribute键，值类型为 *Tenant
+	XTenant = "X-Tenant"
+)
+
+const (
+	// VariableKeyJWTClaims 请求变量：JWT Filter校验通过的Token声明，值类型为以字符串为Key的Map；可用于按Claim识别租户
+	VariableKeyJWTClaims = "__flux.jwt.claims"
+)
+
+const (
+	// Endpoint属性：允许访问的租户ID，可定义多个；未定义时全部租户可见
+	EndpointAttrTagTenants = "tenants"
+)
+
+// Tenant 租户定义：租户可覆盖Filter配置，停用指定的Filter
+type Tenant struct {
+	Id              string                    `json:"id"`
+	DisabledFilters []string                  `json:"disabledFilters,omitempty"`
+	Attributes      map[string]interface{}    `json:"attributes,omitempty"`
+	FilterConfigs   map[string]*Configuration `json:"-"`
+}
+
+// FilterConfig 返回租户对指定Filter的覆盖配置
+func (t *Tenant) FilterConfig(filterId string) (*Configuration, bool) {
+	config, ok := t.FilterConfigs[filterId]
+	return config, ok
+}
+
+// IsFilterDisabled 判断租户是否停用指定的Filter
+func (t *Tenant) IsFilterDisabled(filterId string) bool {
+	return fluxpkg.StringSliceContains(t.DisabledFilters, filterId)
+}
+
+// TenantOf 返回请求所属的租户；未启用多租户或未识别租户时返回false
+func TenantOf(ctx *Context) (*Tenant, bool) {
+	if v, ok := ctx.GetAttribute(XTenant); ok {
+		tenant, ok := v.(*Tenant)
+		return tenant, ok && nil != tenant
+	}
+	return nil, false
+}
@@ -0,0 +1,122 @@
+package tracing
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node/common"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// SpanExporter 上报已结束的Span
+type SpanExporter interface {
+	Export(spans []*Span) error
+}
+
+// OTLPHttpExporter 以OTLP/HTTP(JSON)协议上报Span
+type OTLPHttpExporter struct {
+	endpoint    string
+	serviceName string
+	headers     map[string]string
+	client      *http.Client
+}
+
+func NewOTLPHttpExporter(endpoint, serviceName string, headers map[string]string, timeout time.Duration) *OTLPHttpExporter {
+	return &OTLPHttpExporter{
+		endpoint:    endpoint,
+		serviceName: serviceName,
+		headers:     headers,
+		client:      &http.Client{Timeout: timeout},
+	}
+}
+
+func (e *OTLPHttpExporter) Export(spans []*Span) error {
+	data, err := common.SerializeObject(e.encode(spans))
+	if nil != err {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(data))
+	if nil != err {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if nil != err {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("otlp export, endpoint: %s, status: %d", e.endpoint, resp.StatusCode)
+	}
+	return nil
+}
+
+func (e *OTLPHttpExporter) encode(spans []*Span) map[string]interface{} {
+	out := make([]map[string]interface{}, len(spans))
+	for i, span := range spans {
+		span.mu.Lock()
+		item := map[string]interface{}{
+			"traceId":           span.Context.TraceID.String(),
+			"spanId":            span.Context.SpanID.String(),
+			"name":              span.Name,
+			"kind":              int(span.Kind),
+			"startTimeUnixNano": strconv.FormatInt(span.StartTime.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+			"attributes":        encodeAttributes(span.Attributes),
+		}
+		if span.Parent.IsValid() {
+			item["parentSpanId"] = span.Parent.String()
+		}
+		if span.Error {
+			item["status"] = map[string]interface{}{"code": 2, "message": span.Message}
+		}
+		span.mu.Unlock()
+		out[i] = item
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": encodeAttributes(map[string]interface{}{"service.name": e.serviceName}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "flux"},
+						"spans": out,
+					},
+				},
+			},
+		},
+	}
+}
+
+func encodeAttributes(attrs map[string]interface{}) []interface{} {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]interface{}, 0, len(attrs))
+	for _, k := range keys {
+		var value map[string]interface{}
+		switch v := attrs[k].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprintf("%v", v)}
+		}
+		out = append(out, map[string]interface{}{"key": k, "value": value})
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package tracing
+
+import (
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	PropagatorW3C = "w3c"
+	PropagatorB3  = "b3"
+)
+
+const (
+	HeaderTraceparent = "traceparent"
+	HeaderB3          = "b3"
+	HeaderB3TraceId   = "X-B3-TraceId"
+	HeaderB3SpanId    = "X-B3-SpanId"
+	HeaderB3Sampled   = "X-B3-Sampled"
+)
+
+// Extract 从请求Header中解析上游链路上下文；支持 W3C traceparent 和 B3（单Header与多Header）格式
+func Extract(header http.Header) (SpanContext, bool) {
+	if sc, ok := parseTraceparent(header.Get(HeaderTraceparent)); ok {
+		return sc, true
+	}
+	if sc, ok := parseB3Single(header.Get(HeaderB3)); ok {
+		return sc, true
+	}
+	return parseB3Multi(header.Get(HeaderB3TraceId), header.Get(HeaderB3SpanId), header.Get(HeaderB3Sampled))
+}
+
+// Inject 按指定的传播格式，将链路上下文写入到上游请求
+func Inject(sc SpanContext, propagators []string, setter func(key, value string)) {
+	if !sc.IsValid() {
+		return
+	}
+	for _, p := range propagators {
+		switch strings.ToLower(p) {
+		case PropagatorW3C:
+			flags := "00"
+			if sc.Sampled {
+				flags = "01"
+			}
+			setter(HeaderTraceparent, "00-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+flags)
+		case PropagatorB3:
+			sampled := "0"
+			if sc.Sampled {
+				sampled = "1"
+			}
+			setter(HeaderB3, sc.TraceID.String()+"-"+sc.SpanID.String()+"-"+sampled)
+		}
+	}
+}
+
+// traceparent: version-traceid-spanid-flags
+func parseTraceparent(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[3]) != 2 {
+		return SpanContext{}, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if nil != err {
+		return SpanContext{}, false
+	}
+	sc, ok := decodeIds(parts[1], parts[2])
+	sc.Sampled = flags[0]&0x01 == 0x01
+	return sc, ok
+}
+
+// b3: traceid-spanid[-sampled[-parentspanid]]
+func parseB3Single(value string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 2 {
+		return SpanContext{}, false
+	}
+	sampled := ""
+	if len(parts) > 2 {
+		sampled = parts[2]
+	}
+	return parseB3Multi(parts[0], parts[1], sampled)
+}
+
+func parseB3Multi(traceId, spanId, sampled string) (SpanContext, bool) {
+	// B3 支持64位TraceId，左侧补零
+	if len(traceId) == 16 {
+		traceId = strings.Repeat("0", 16) + traceId
+	}
+	sc, ok := decodeIds(traceId, spanId)
+	sc.Sampled = sampled == "1" || sampled == "d" || strings.EqualFold(sampled, "true") || sampled == ""
+	return sc, ok
+}
+
+func decodeIds(traceId, spanId string) (SpanContext, bool) {
+	var sc SpanContext
+	if len(traceId) != 32 || len(spanId) != 16 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(traceId)); nil != err {
+		return SpanContext{}, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(spanId)); nil != err {
+		return SpanContext{}, false
+	}
+	return sc, sc.IsValid()
+}
@@ -0,0 +1,58 @@
+package tracing
+
+import (
+	assert2 "github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+)
+
+func TestExtract_Traceparent(t *testing.T) {
+	assert := assert2.New(t)
+	header := http.Header{}
+	header.Set(HeaderTraceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	sc, ok := Extract(header)
+	assert.True(ok)
+	assert.Equal("4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal("00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(sc.Sampled)
+}
+
+func TestExtract_B3(t *testing.T) {
+	assert := assert2.New(t)
+	single := http.Header{}
+	single.Set(HeaderB3, "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-0")
+	sc, ok := Extract(single)
+	assert.True(ok)
+	assert.Equal("80f198ee56343ba864fe8b2a57d3eff7", sc.TraceID.String())
+	assert.False(sc.Sampled)
+	multi := http.Header{}
+	multi.Set(HeaderB3TraceId, "64fe8b2a57d3eff7")
+	multi.Set(HeaderB3SpanId, "e457b5a2e4d86bd1")
+	multi.Set(HeaderB3Sampled, "1")
+	sc, ok = Extract(multi)
+	assert.True(ok)
+	assert.Equal("000000000000000064fe8b2a57d3eff7", sc.TraceID.String())
+	assert.True(sc.Sampled)
+}
+
+func TestExtract_Invalid(t *testing.T) {
+	assert := assert2.New(t)
+	header := http.Header{}
+	header.Set(HeaderTraceparent, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	_, ok := Extract(header)
+	assert.False(ok)
+	_, ok = Extract(http.Header{})
+	assert.False(ok)
+}
+
+func TestInject(t *testing.T) {
+	assert := assert2.New(t)
+	sc := SpanContext{TraceID: newTraceID(), SpanID: newSpanID(), Sampled: true}
+	header := http.Header{}
+	Inject(sc, []string{PropagatorW3C, PropagatorB3}, header.Set)
+	assert.Equal("00-"+sc.TraceID.String()+"-"+sc.SpanID.String()+"-01", header.Get(HeaderTraceparent))
+	assert.Equal(sc.TraceID.String()+"-"+sc.SpanID.String()+"-1", header.Get(HeaderB3))
+	out, ok := Extract(header)
+	assert.True(ok)
+	assert.Equal(sc, out)
+}
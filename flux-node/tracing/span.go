@@ -0,0 +1,135 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/bytepowered/flux/flux-node"
+	"sync"
+	"time"
+)
+
+const (
+	// 当前请求的活动Span，保存在请求Context的Variable中
+	variableKeyCurrentSpan = "__flux.tracing.current_span"
+)
+
+// SpanKind 与OTLP协议的SpanKind定义一致
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) IsValid() bool {
+	return t != TraceID{}
+}
+
+func (t TraceID) String() string {
+	return hex.EncodeToString(t[:])
+}
+
+func (s SpanID) IsValid() bool {
+	return s != SpanID{}
+}
+
+func (s SpanID) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+// SpanContext 跨进程传递的链路上下文
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+}
+
+func (c SpanContext) IsValid() bool {
+	return c.TraceID.IsValid() && c.SpanID.IsValid()
+}
+
+// Span 链路中的单个调用节点
+type Span struct {
+	Name       string
+	Kind       SpanKind
+	Context    SpanContext
+	Parent     SpanID
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+	Error      bool
+	Message    string
+	tracer     *Tracer
+	prev       *Span
+	fctx       *flux.Context
+	ended      bool
+	mu         sync.Mutex
+}
+
+// SetAttribute 设置Span的属性；Span为nil时忽略
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Attributes[key] = value
+	s.mu.Unlock()
+}
+
+// SetError 标记Span的错误状态；Span为nil时忽略
+func (s *Span) SetError(message string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.Error = true
+	s.Message = message
+	s.mu.Unlock()
+}
+
+// End 结束Span；如果Span关联了请求Context，恢复其上级Span为当前活动Span
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+	if s.fctx != nil {
+		s.fctx.SetVariable(variableKeyCurrentSpan, s.prev)
+	}
+	if s.Context.Sampled && s.tracer != nil {
+		s.tracer.enqueue(s)
+	}
+}
+
+// CurrentSpan 返回请求Context当前活动的Span；不存在时返回nil
+func CurrentSpan(ctx *flux.Context) *Span {
+	v, ok := ctx.GetVariable(variableKeyCurrentSpan)
+	if !ok {
+		return nil
+	}
+	span, _ := v.(*Span)
+	return span
+}
+
+func newTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+func newSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,216 @@
+package tracing
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	ConfigKeyEnable        = "enable"
+	ConfigKeyServiceName   = "service_name"
+	ConfigKeySampleRatio   = "sample_ratio"
+	ConfigKeyPropagators   = "propagators"
+	ConfigKeyEndpoint      = "endpoint"
+	ConfigKeyHeaders       = "headers"
+	ConfigKeyTimeout       = "timeout"
+	ConfigKeyBatchSize     = "batch_size"
+	ConfigKeyQueueSize     = "queue_size"
+	ConfigKeyFlushInterval = "flush_interval"
+)
+
+var (
+	_ flux.Initializer = new(Tracer)
+	_ flux.Startuper   = new(Tracer)
+	_ flux.Shutdowner  = new(Tracer)
+)
+
+// Tracer 请求链路追踪；为每个请求创建Span，并以OTLP协议批量上报
+type Tracer struct {
+	enabled     bool
+	ratio       float64
+	propagators []string
+	exporter    SpanExporter
+	batchSize   int
+	interval    time.Duration
+	queue       chan *Span
+	done        chan struct{}
+	random      *rand.Rand
+	randmu      sync.Mutex
+}
+
+func NewTracer() *Tracer {
+	return &Tracer{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (t *Tracer) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyEnable:        false,
+		ConfigKeyServiceName:   "flux",
+		ConfigKeySampleRatio:   1.0,
+		ConfigKeyPropagators:   []string{PropagatorW3C, PropagatorB3},
+		ConfigKeyEndpoint:      "http://127.0.0.1:4318/v1/traces",
+		ConfigKeyTimeout:       time.Second * 5,
+		ConfigKeyBatchSize:     256,
+		ConfigKeyQueueSize:     4096,
+		ConfigKeyFlushInterval: time.Second * 5,
+	})
+	t.enabled = config.GetBool(ConfigKeyEnable)
+	t.ratio = config.GetFloat64(ConfigKeySampleRatio)
+	t.propagators = config.GetStringSlice(ConfigKeyPropagators)
+	t.batchSize = config.GetInt(ConfigKeyBatchSize)
+	t.interval = config.GetDuration(ConfigKeyFlushInterval)
+	t.queue = make(chan *Span, config.GetInt(ConfigKeyQueueSize))
+	t.done = make(chan struct{})
+	if t.exporter == nil {
+		t.exporter = NewOTLPHttpExporter(config.GetString(ConfigKeyEndpoint), config.GetString(ConfigKeyServiceName),
+			config.GetStringMapString(ConfigKeyHeaders), config.GetDuration(ConfigKeyTimeout))
+	}
+	logger.Infow("Tracing init", "enable", t.enabled, "sample-ratio", t.ratio,
+		"propagators", t.propagators, "endpoint", config.GetString(ConfigKeyEndpoint))
+	return nil
+}
+
+// SetExporter 设置Span上报实现；需要在Init之前设置
+func (t *Tracer) SetExporter(exporter SpanExporter) {
+	t.exporter = exporter
+}
+
+// Enabled 返回是否启用链路追踪
+func (t *Tracer) Enabled() bool {
+	return t.enabled
+}
+
+func (t *Tracer) Startup() error {
+	if t.enabled {
+		go t.loop()
+	}
+	return nil
+}
+
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if !t.enabled {
+		return nil
+	}
+	close(t.queue)
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// StartServerSpan 根据请求Header中的上游链路上下文，创建请求的根Span，并设置为请求的当前Span；
+// 未启用时返回nil。
+func (t *Tracer) StartServerSpan(ctx *flux.Context, name string) *Span {
+	if !t.enabled {
+		return nil
+	}
+	var parent SpanContext
+	if sc, ok := Extract(ctx.HeaderVars()); ok {
+		parent = sc
+	} else {
+		parent = SpanContext{TraceID: newTraceID(), Sampled: t.sample()}
+	}
+	return t.start(ctx, name, SpanKindServer, parent, nil)
+}
+
+// StartSpan 创建当前Span的子Span，并设置为请求的当前Span；请求未关联Span时返回nil。
+func (t *Tracer) StartSpan(ctx *flux.Context, name string, kind SpanKind) *Span {
+	if !t.enabled {
+		return nil
+	}
+	current := CurrentSpan(ctx)
+	if current == nil {
+		return nil
+	}
+	return t.start(ctx, name, kind, current.Context, current)
+}
+
+// Inject 将请求当前Span的链路上下文写入到Context的Attributes；Transporter将Attributes传递到上游服务。
+func (t *Tracer) Inject(ctx *flux.Context) {
+	if span := CurrentSpan(ctx); span != nil {
+		Inject(span.Context, t.propagators, func(key, value string) {
+			ctx.SetAttribute(key, value)
+		})
+	}
+}
+
+func (t *Tracer) start(ctx *flux.Context, name string, kind SpanKind, parent SpanContext, prev *Span) *Span {
+	span := &Span{
+		Name: name,
+		Kind: kind,
+		Context: SpanContext{
+			TraceID: parent.TraceID, SpanID: newSpanID(), Sampled: parent.Sampled,
+		},
+		Parent:     parent.SpanID,
+		StartTime:  time.Now(),
+		Attributes: make(map[string]interface{}, 8),
+		tracer:     t,
+		prev:       prev,
+		fctx:       ctx,
+	}
+	ctx.SetVariable(variableKeyCurrentSpan, span)
+	return span
+}
+
+func (t *Tracer) sample() bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	if t.ratio <= 0 {
+		return false
+	}
+	t.randmu.Lock()
+	defer t.randmu.Unlock()
+	return t.random.Float64() < t.ratio
+}
+
+func (t *Tracer) enqueue(span *Span) {
+	defer func() {
+		// 关闭后到达的Span直接丢弃
+		_ = recover()
+	}()
+	select {
+	case t.queue <- span:
+	default:
+		logger.Warnw("TRACING:QUEUE:FULL", "trace-id", span.Context.TraceID.String(), "span", span.Name)
+	}
+}
+
+func (t *Tracer) loop() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	batch := make([]*Span, 0, t.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := t.exporter.Export(batch); nil != err {
+			logger.Warnw("TRACING:EXPORT:ERROR", "spans", len(batch), "error", err)
+		}
+		batch = make([]*Span, 0, t.batchSize)
+	}
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= t.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
@@ -17,6 +17,14 @@ type (
 		// Writer
 		Writer() TransportWriter
 	}
+	// ServiceValidator 注册后端服务时校验Service属性的Transporter实现此接口；校验失败的服务不会被注册
+	ServiceValidator interface {
+		ValidateService(TransporterService) error
+	}
+	// EndpointValidator 注册Endpoint时校验Endpoint属性的Transporter实现此接口；校验失败的Endpoint不会被注册
+	EndpointValidator interface {
+		ValidateEndpoint(*Endpoint) error
+	}
 	// TransportCodec 解析 Transporter 返回的原始数据，生成响应对象
 	TransportCodec func(ctx *Context, packet interface{}) (*ResponseBody, error)
 	// TransportWriter
@@ -30,5 +38,6 @@ type (
 		Headers     http.Header            // Header
 		Attachments map[string]interface{} // Attachment
 		Body        interface{}            // 响应数据体
+		Trailers    http.Header            // Trailer；客户端声明支持（TE: trailers）时输出
 	}
 )
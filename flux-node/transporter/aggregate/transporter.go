@@ -0,0 +1,258 @@
+package aggregate
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/spf13/cast"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 单个后端服务调用的默认超时时间
+	ConfigKeySectionTimeout = "section_timeout"
+)
+
+const (
+	// Service属性：聚合的后端服务列表；每项为 name=serviceId 格式的字符串，
+	// 或包含 name，service，path，timeout，required 字段的对象
+	ServiceAttrTagAggregateSections = "aggregate"
+)
+
+const (
+	// 部分后端服务调用失败时，响应包含此Header
+	HeaderXAggregatePartial = "X-Aggregate-Partial"
+)
+
+const (
+	ErrorCodeAggregateSpecInvalid   = "AGGREGATE:SPEC_INVALID"
+	ErrorCodeAggregateSectionFailed = "AGGREGATE:SECTION_FAILED"
+)
+
+func init() {
+	ext.RegisterTransporterFactory(flux.ProtoAggregate, func() interface{} {
+		return NewTransporter()
+	})
+}
+
+var (
+	_ flux.Transporter = new(RpcTransporter)
+)
+
+// Section 聚合响应中的一个数据段
+type Section struct {
+	// 数据段在聚合响应中的字段名
+	Name      string
+	ServiceId string
+	// 从后端服务响应中提取数据的路径，例如 data.items[0]；为空时使用完整的响应数据
+	Path    string
+	Timeout time.Duration
+	// 必需的数据段调用失败时，整个请求失败；否则该数据段返回错误对象
+	Required bool
+}
+
+// RpcTransporter 响应聚合网关服务（BFF）：并行调用多个后端服务，按聚合规则将响应数据合并为一个JSON对象；
+// 非必需的数据段调用失败时，该数据段返回 {"error": {...}} 错误对象，其它数据段正常返回。
+type RpcTransporter struct {
+	timeout time.Duration
+	writer  flux.TransportWriter
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		timeout: time.Second * 3,
+		writer:  new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Init(config *flux.Configuration) error {
+	config.SetDefault(ConfigKeySectionTimeout, time.Second*3)
+	b.timeout = config.GetDuration(ConfigKeySectionTimeout)
+	logger.Infow("Aggregate transporter init", "section-timeout", b.timeout)
+	return nil
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	transporter.DoTransport(ctx, b)
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	value, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	result := value.(*aggregateResult)
+	header := make(http.Header, 1)
+	if result.partial {
+		header.Set(HeaderXAggregatePartial, "true")
+	}
+	return &flux.ResponseBody{
+		StatusCode: flux.StatusOK,
+		Headers:    header,
+		Body:       result.body,
+	}, nil
+}
+
+type aggregateResult struct {
+	body    map[string]interface{}
+	partial bool
+}
+
+// Invoke 并行调用全部数据段的后端服务，返回聚合结果；数据段的超时时间从当前请求的截止时间派生
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	sections, err := ParseSections(service.GetAttr(ServiceAttrTagAggregateSections).Value, b.timeout)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodeAggregateSpecInvalid,
+			Message:    "AGGREGATE:SPEC:INVALID",
+			CauseError: err,
+		}
+	}
+	values := make([]interface{}, len(sections))
+	failures := make([]*flux.ServeError, len(sections))
+	var wg sync.WaitGroup
+	for i := range sections {
+		// 在请求处理协程中创建子请求，各数据段不共享当前请求的WebContext
+		fork, serr := b.forkSection(ctx, sections[i])
+		if nil != serr {
+			failures[i] = serr
+			continue
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() {
+				if rvr := recover(); nil != rvr {
+					logger.TraceContext(ctx).Errorw("TRANSPORTER:AGGREGATE:SECTION:PANIC", "section", sections[i].Name, "error", rvr)
+					failures[i] = &flux.ServeError{
+						StatusCode: flux.StatusServerError,
+						ErrorCode:  flux.ErrorCodeGatewayInternal,
+						Message:    fmt.Sprintf("section panic: %v", rvr),
+					}
+				}
+			}()
+			values[i], failures[i] = b.invokeSection(fork, sections[i])
+		}(i)
+	}
+	wg.Wait()
+	result := &aggregateResult{body: make(map[string]interface{}, len(sections))}
+	for i, section := range sections {
+		serr := failures[i]
+		if nil == serr {
+			result.body[section.Name] = values[i]
+			continue
+		}
+		logger.TraceContext(ctx).Infow("TRANSPORTER:AGGREGATE:SECTION/ERROR", "section", section.Name,
+			"service-id", section.ServiceId, "error", serr)
+		if section.Required {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusBadGateway,
+				ErrorCode:  ErrorCodeAggregateSectionFailed,
+				Message:    "AGGREGATE:SECTION:FAILED",
+				CauseError: serr,
+				Extras:     map[string]interface{}{"section": section.Name},
+			}
+		}
+		result.partial = true
+		result.body[section.Name] = map[string]interface{}{
+			"error": map[string]interface{}{
+				"status":  serr.StatusCode,
+				"code":    serr.GetErrorCode(),
+				"message": serr.Message,
+			},
+		}
+	}
+	return result, nil
+}
+
+func (b *RpcTransporter) forkSection(ctx *flux.Context, section Section) (*flux.Context, *flux.ServeError) {
+	service, ok := ext.TransporterServiceById(section.ServiceId)
+	if !ok {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    "AGGREGATE:SERVICE:NOT_FOUND",
+			CauseError: fmt.Errorf("service not found, id: %s", section.ServiceId),
+		}
+	}
+	return transporter.ForkContext(ctx, service)
+}
+
+func (b *RpcTransporter) invokeSection(ctx *flux.Context, section Section) (interface{}, *flux.ServeError) {
+	value, serr := transporter.InvokeForked(ctx, section.Timeout)
+	if nil != serr {
+		return nil, serr
+	}
+	if section.Path == "" {
+		return value, nil
+	}
+	selected, _, err := common.LookupPath(value, section.Path)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    "AGGREGATE:SECTION:PATH",
+			CauseError: err,
+		}
+	}
+	return selected, nil
+}
+
+// ParseSections 解析聚合规则
+func ParseSections(spec interface{}, timeout time.Duration) ([]Section, error) {
+	items := cast.ToSlice(spec)
+	if len(items) == 0 {
+		if text := cast.ToString(spec); text != "" {
+			items = []interface{}{text}
+		}
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("service attribute(%s) is required", ServiceAttrTagAggregateSections)
+	}
+	out := make([]Section, 0, len(items))
+	names := make(map[string]bool, len(items))
+	for _, item := range items {
+		section := Section{Timeout: timeout}
+		if text, ok := item.(string); ok {
+			kv := strings.SplitN(text, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid aggregate section: %s, requires name=serviceId", text)
+			}
+			section.Name, section.ServiceId = strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		} else {
+			fields, err := cast.ToStringMapE(item)
+			if nil != err {
+				return nil, fmt.Errorf("invalid aggregate section: %v", item)
+			}
+			section.Name = cast.ToString(fields["name"])
+			section.ServiceId = cast.ToString(fields["service"])
+			section.Path = cast.ToString(fields["path"])
+			section.Required = cast.ToBool(fields["required"])
+			if v, ok := fields["timeout"]; ok {
+				if section.Timeout, err = cast.ToDurationE(v); nil != err {
+					return nil, fmt.Errorf("invalid aggregate section timeout: %v, section: %s", v, section.Name)
+				}
+			}
+		}
+		if section.Name == "" || section.ServiceId == "" {
+			return nil, fmt.Errorf("aggregate section requires name and service: %v", item)
+		}
+		if names[section.Name] {
+			return nil, fmt.Errorf("duplicated aggregate section: %s", section.Name)
+		}
+		names[section.Name] = true
+		out = append(out, section)
+	}
+	return out, nil
+}
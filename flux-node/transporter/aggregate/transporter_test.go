@@ -0,0 +1,127 @@
+package aggregate
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const protoAggregateTest = "AGGREGATE_TEST"
+
+// sectionTransporter 按服务ID返回固定的响应数据
+type sectionTransporter struct{}
+
+func (sectionTransporter) Invoke(_ *flux.Context, _ flux.TransporterService) (interface{}, *flux.ServeError) {
+	return nil, nil
+}
+
+func (sectionTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	if service.ServiceId == "failed" {
+		return nil, &flux.ServeError{StatusCode: flux.StatusBadGateway, ErrorCode: flux.ErrorCodeGatewayTransporter, Message: "failed"}
+	}
+	// 数据段之间不共享变量
+	if _, ok := ctx.GetVariable("aggregate.section"); ok {
+		return nil, &flux.ServeError{StatusCode: flux.StatusServerError, Message: "variable shared"}
+	}
+	ctx.SetVariable("aggregate.section", service.ServiceId)
+	return &flux.ResponseBody{
+		StatusCode: flux.StatusOK,
+		Body:       `{"data":{"items":[{"id":"` + service.ServiceId + `"}]}}`,
+	}, nil
+}
+
+func (sectionTransporter) Transport(_ *flux.Context) {}
+
+func (sectionTransporter) Writer() flux.TransportWriter {
+	return new(transporter.DefaultTransportWriter)
+}
+
+func TestParseSections(t *testing.T) {
+	assert := assert.New(t)
+	sections, err := ParseSections([]interface{}{
+		"user=users.get",
+		map[string]interface{}{"name": "orders", "service": "orders.list", "path": "$.data", "timeout": "500ms", "required": true},
+	}, time.Second)
+	assert.NoError(err)
+	assert.Equal([]Section{
+		{Name: "user", ServiceId: "users.get", Timeout: time.Second},
+		{Name: "orders", ServiceId: "orders.list", Path: "$.data", Timeout: time.Millisecond * 500, Required: true},
+	}, sections)
+	sections, err = ParseSections("user=users.get", time.Second)
+	assert.NoError(err)
+	assert.Len(sections, 1)
+	for _, spec := range []interface{}{
+		nil,
+		"users.get",
+		[]interface{}{"user=users.get", "user=users.list"},
+		[]interface{}{map[string]interface{}{"name": "user"}},
+		[]interface{}{map[string]interface{}{"name": "user", "service": "users.get", "timeout": "x"}},
+	} {
+		_, err := ParseSections(spec, time.Second)
+		assert.Error(err, "spec: %v", spec)
+	}
+}
+
+func TestRpcTransporter_Invoke(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	assert := assert.New(t)
+	ext.RegisterTransporter(protoAggregateTest, sectionTransporter{})
+	for _, id := range []string{"users", "orders", "items", "failed"} {
+		ext.RegisterTransporterService(flux.TransporterService{
+			ServiceId: id,
+			EmbeddedAttributes: flux.EmbeddedAttributes{
+				Attributes: []flux.Attribute{{Name: flux.ServiceAttrTagRpcProto, Value: protoAggregateTest}},
+			},
+		})
+	}
+	newContext := func() *flux.Context {
+		request := httptest.NewRequest(http.MethodGet, "http://gateway/aggregate", nil)
+		// 模拟WebListener缓存的请求Body
+		request.GetBody = func() (io.ReadCloser, error) {
+			return http.NoBody, nil
+		}
+		ctx := flux.NewContext()
+		ctx.Reset(internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "aggregate", nil), &flux.Endpoint{})
+		return ctx
+	}
+	newService := func(sections ...interface{}) flux.TransporterService {
+		return flux.TransporterService{EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: ServiceAttrTagAggregateSections, Value: sections}},
+		}}
+	}
+	aggregate := NewTransporter().(*RpcTransporter)
+	value, serr := aggregate.Invoke(newContext(), newService(
+		"users=users",
+		map[string]interface{}{"name": "orders", "service": "orders", "path": "$.data.items[0].id"},
+		map[string]interface{}{"name": "items", "service": "items", "path": "$.data.items[1]"},
+		"failed=failed",
+		"missing=missing",
+	))
+	assert.Nil(serr)
+	result := value.(*aggregateResult)
+	assert.True(result.partial)
+	assert.Equal(map[string]interface{}{"data": map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"id": "users"}},
+	}}, result.body["users"])
+	assert.Equal("orders", result.body["orders"])
+	assert.Nil(result.body["items"])
+	assert.Equal(flux.ErrorCodeGatewayTransporter, result.body["failed"].(map[string]interface{})["error"].(map[string]interface{})["code"])
+	assert.Equal(flux.ErrorCodeGatewayEndpoint, result.body["missing"].(map[string]interface{})["error"].(map[string]interface{})["code"])
+	// 必需的数据段失败时，整个请求失败
+	_, serr = aggregate.Invoke(newContext(), newService(
+		"users=users",
+		map[string]interface{}{"name": "failed", "service": "failed", "required": true},
+	))
+	assert.NotNil(serr)
+	assert.Equal(ErrorCodeAggregateSectionFailed, serr.ErrorCode)
+}
@@ -0,0 +1,170 @@
+package transporter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-pkg"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// 一致性哈希环中每个实例的虚拟节点数量
+	hashVirtualNodes = 64
+	// 会话绑定Cookie的默认名称
+	defaultStickyCookie = "FLUXSTICKY"
+)
+
+func init() {
+	ext.RegisterLoadBalancer(flux.LoadBalanceRoundRobin, new(RoundRobinBalancer))
+	ext.RegisterLoadBalancer(flux.LoadBalanceLeastConn, new(LeastConnBalancer))
+	ext.RegisterLoadBalancer(flux.LoadBalanceHash, new(HashBalancer))
+	ext.RegisterLoadBalancer(flux.LoadBalanceSticky, new(StickyBalancer))
+}
+
+// SelectInstance 按Service定义的负载均衡策略，在可用的实例地址中选择本次请求的实例，返回以选中地址替换RemoteHost的Service；
+// Service只有一个实例地址时，直接返回。
+func SelectInstance(ctx *flux.Context, service flux.TransporterService) (flux.TransporterService, func(), error) {
+	instances := instanceRegistry.Instances(service)
+	switch len(instances) {
+	case 0:
+		return service, nil, fmt.Errorf("no available instance, service: %s", service.ServiceID())
+	case 1:
+		service.RemoteHost = instances[0]
+		return service, func() {}, nil
+	}
+	name := service.GetAttr(flux.ServiceAttrTagLoadBalance).GetString()
+	if name == "" {
+		name = flux.LoadBalanceRoundRobin
+	}
+	balancer, ok := ext.LoadBalancerByName(name)
+	if !ok {
+		return service, nil, fmt.Errorf("unknown load balancer: %s, service: %s", name, service.ServiceID())
+	}
+	instance, done := balancer.Select(ctx, service, instances)
+	service.RemoteHost = instance
+	var once sync.Once
+	return service, func() { once.Do(done) }, nil
+}
+
+var _ flux.LoadBalancer = new(RoundRobinBalancer)
+
+// RoundRobinBalancer 按Service轮询选择实例
+type RoundRobinBalancer struct {
+	counters sync.Map
+}
+
+func (b *RoundRobinBalancer) Select(_ *flux.Context, service flux.TransporterService, instances []string) (string, func()) {
+	v, _ := b.counters.LoadOrStore(service.ServiceID(), new(uint32))
+	next := atomic.AddUint32(v.(*uint32), 1)
+	return instances[int(next-1)%len(instances)], func() {}
+}
+
+var _ flux.LoadBalancer = new(LeastConnBalancer)
+
+// LeastConnBalancer 选择活跃请求数最少的实例；活跃请求数相同时，选择靠前的实例
+type LeastConnBalancer struct {
+	actives sync.Map
+}
+
+func (b *LeastConnBalancer) Select(_ *flux.Context, _ flux.TransporterService, instances []string) (string, func()) {
+	var selected *int32
+	var instance string
+	for _, host := range instances {
+		v, _ := b.actives.LoadOrStore(host, new(int32))
+		if c := v.(*int32); nil == selected || atomic.LoadInt32(c) < atomic.LoadInt32(selected) {
+			selected, instance = c, host
+		}
+	}
+	atomic.AddInt32(selected, 1)
+	return instance, func() {
+		atomic.AddInt32(selected, -1)
+	}
+}
+
+var _ flux.LoadBalancer = new(HashBalancer)
+
+// HashBalancer 按请求Header值的一致性哈希选择实例，实例增减时只影响少量请求的映射；
+// Header由Service属性 hashheader 指定，默认为用户标识Header；请求没有此Header时，按轮询选择。
+type HashBalancer struct {
+	rings    sync.Map
+	fallback RoundRobinBalancer
+}
+
+type hashRing struct {
+	id string
+	*fluxpkg.HashRing
+}
+
+func (b *HashBalancer) Select(ctx *flux.Context, service flux.TransporterService, instances []string) (string, func()) {
+	header := service.GetAttr(flux.ServiceAttrTagHashHeader).GetString()
+	if header == "" {
+		header = flux.CanaryUserHeader
+	}
+	key := ctx.HeaderVar(header)
+	if key == "" {
+		return b.fallback.Select(ctx, service, instances)
+	}
+	return b.ringOf(service.ServiceID(), instances).Get(key), func() {}
+}
+
+// ringOf 按Service缓存哈希环；实例地址列表变化时重建
+func (b *HashBalancer) ringOf(serviceId string, instances []string) *hashRing {
+	id := strings.Join(instances, ",")
+	if v, ok := b.rings.Load(serviceId); ok && v.(*hashRing).id == id {
+		return v.(*hashRing)
+	}
+	ring := &hashRing{id: id, HashRing: fluxpkg.NewHashRing(instances, hashVirtualNodes)}
+	b.rings.Store(serviceId, ring)
+	return ring
+}
+
+var _ flux.LoadBalancer = new(StickyBalancer)
+
+// StickyBalancer 会话绑定：请求携带的Cookie指向的实例可用时，总是选择此实例；否则按客户端地址的一致性哈希选择实例。
+// 选择实例时不写入响应；由 StickyCookie 返回记录选中实例的Cookie，Cookie值为实例地址的摘要，不暴露实例地址。
+type StickyBalancer struct {
+	hash HashBalancer
+}
+
+func (b *StickyBalancer) Select(ctx *flux.Context, service flux.TransporterService, instances []string) (string, func()) {
+	if cookie, err := ctx.CookieVar(stickyCookieName(service)); nil == err {
+		for _, inst := range instances {
+			if stickyId(inst) == cookie.Value {
+				return inst, func() {}
+			}
+		}
+	}
+	return b.hash.ringOf(service.ServiceID(), instances).Get(flux.RealIP(ctx)), func() {}
+}
+
+// StickyCookie 返回记录会话绑定实例的Cookie；service 为已选择实例的Service（RemoteHost为选中的实例地址）。
+// Service未使用会话绑定，或请求已携带指向此实例的Cookie时，返回false。
+// 返回的Cookie应作为上游响应的Header，由请求协程写入客户端响应；不可在负载均衡选择时直接写入。
+func StickyCookie(ctx *flux.Context, service flux.TransporterService) (*http.Cookie, bool) {
+	if service.GetAttr(flux.ServiceAttrTagLoadBalance).GetString() != flux.LoadBalanceSticky {
+		return nil, false
+	}
+	name := stickyCookieName(service)
+	value := stickyId(service.RemoteHost)
+	if cookie, err := ctx.CookieVar(name); nil == err && cookie.Value == value {
+		return nil, false
+	}
+	return &http.Cookie{Name: name, Value: value, Path: "/", HttpOnly: true}, true
+}
+
+func stickyCookieName(service flux.TransporterService) string {
+	if name := service.GetAttr(flux.ServiceAttrTagStickyCookie).GetString(); name != "" {
+		return name
+	}
+	return defaultStickyCookie
+}
+
+func stickyId(instance string) string {
+	return strconv.FormatUint(uint64(crc32.ChecksumIEEE([]byte(instance))), 36)
+}
@@ -0,0 +1,88 @@
+package transporter
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newBalancerContext(cookies ...*http.Cookie) (*flux.Context, *httptest.ResponseRecorder) {
+	request := httptest.NewRequest(http.MethodGet, "http://gateway/users", nil)
+	request.RemoteAddr = "10.0.0.1:5678"
+	for _, cookie := range cookies {
+		request.AddCookie(cookie)
+	}
+	recorder := httptest.NewRecorder()
+	ctx := flux.NewContext()
+	ctx.Reset(internal.NewServeWebContext(echo.New().NewContext(request, recorder), "test", nil), &flux.Endpoint{})
+	return ctx, recorder
+}
+
+func newStickyService(host string) flux.TransporterService {
+	return flux.TransporterService{
+		ServiceId:  "users",
+		RemoteHost: host,
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: flux.ServiceAttrTagLoadBalance, Value: flux.LoadBalanceSticky}},
+		},
+	}
+}
+
+func TestStickyBalancer_SelectDoesNotWriteResponse(t *testing.T) {
+	assert := assert.New(t)
+	instances := []string{"10.1.0.1:80", "10.1.0.2:80", "10.1.0.3:80"}
+	ctx, recorder := newBalancerContext()
+	balancer := new(StickyBalancer)
+	selected, done := balancer.Select(ctx, newStickyService(""), instances)
+	done()
+	assert.Contains(instances, selected)
+	assert.Empty(recorder.Header().Values(flux.HeaderSetCookie), "select must not write response headers")
+	// 相同客户端地址选择相同实例
+	again, _ := balancer.Select(ctx, newStickyService(""), instances)
+	assert.Equal(selected, again)
+}
+
+func TestStickyBalancer_SelectByCookie(t *testing.T) {
+	assert := assert.New(t)
+	instances := []string{"10.1.0.1:80", "10.1.0.2:80", "10.1.0.3:80"}
+	balancer := new(StickyBalancer)
+	for _, instance := range instances {
+		ctx, _ := newBalancerContext(&http.Cookie{Name: defaultStickyCookie, Value: stickyId(instance)})
+		selected, _ := balancer.Select(ctx, newStickyService(""), instances)
+		assert.Equal(instance, selected)
+	}
+	// Cookie指向的实例不可用时，重新选择
+	ctx, _ := newBalancerContext(&http.Cookie{Name: defaultStickyCookie, Value: stickyId("10.1.0.9:80")})
+	selected, _ := balancer.Select(ctx, newStickyService(""), instances)
+	assert.Contains(instances, selected)
+}
+
+func TestStickyCookie(t *testing.T) {
+	assert := assert.New(t)
+	ctx, _ := newBalancerContext()
+	cookie, ok := StickyCookie(ctx, newStickyService("10.1.0.1:80"))
+	assert.True(ok)
+	assert.Equal(defaultStickyCookie, cookie.Name)
+	assert.Equal(stickyId("10.1.0.1:80"), cookie.Value)
+	assert.True(cookie.HttpOnly)
+	// 请求已携带指向选中实例的Cookie
+	ctx, _ = newBalancerContext(cookie)
+	_, ok = StickyCookie(ctx, newStickyService("10.1.0.1:80"))
+	assert.False(ok)
+	// 请求Cookie指向其它实例
+	_, ok = StickyCookie(ctx, newStickyService("10.1.0.2:80"))
+	assert.True(ok)
+	// 非会话绑定的Service
+	_, ok = StickyCookie(ctx, flux.TransporterService{ServiceId: "users", RemoteHost: "10.1.0.2:80"})
+	assert.False(ok)
+	// 自定义Cookie名称
+	service := newStickyService("10.1.0.2:80")
+	service.Attributes = append(service.Attributes, flux.Attribute{Name: flux.ServiceAttrTagStickyCookie, Value: "SID"})
+	cookie, ok = StickyCookie(ctx, service)
+	assert.True(ok)
+	assert.Equal("SID", cookie.Name)
+}
@@ -0,0 +1,133 @@
+package transporter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// InvokeService 在当前请求中调用另一个后端服务，用于组合多个后端服务的网关服务（GraphQL，响应聚合等）；
+// 子请求继承当前请求的Endpoint及属性（例如认证信息），使用独立的超时时间；gRPC错误状态及4xx/5xx响应状态返回错误。
+// 必须在当前请求的处理协程中调用；并行调用时使用 ForkContext 及 InvokeForked。
+func InvokeService(parent *flux.Context, service flux.TransporterService, timeout time.Duration) (interface{}, *flux.ServeError) {
+	ctx, serr := ForkContext(parent, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return InvokeForked(ctx, timeout)
+}
+
+// ForkContext 创建调用指定后端服务的子请求Context：复制当前请求的方法，URL，Header，路径参数及Body数据，
+// 继承当前请求的Endpoint，属性，日志及截止时间；子请求不共享当前请求的WebContext，可在其它协程中执行。
+// 必须在当前请求的处理协程中调用。
+func ForkContext(parent *flux.Context, service flux.TransporterService) (*flux.Context, *flux.ServeError) {
+	var body []byte
+	if reader, err := parent.BodyReader(); nil == err {
+		body, err = ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if nil != err {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusBadRequest,
+				ErrorCode:  flux.ErrorCodeRequestInvalid,
+				Message:    "COMPOSE:READ_BODY",
+				CauseError: err,
+			}
+		}
+	}
+	endpoint := *parent.Endpoint()
+	endpoint.Service = service
+	ctx := flux.NewContext()
+	ctx.Reset(internal.NewForkedWebContext(parent.ServerWebContext, parent.Context(), body), &endpoint)
+	ctx.SetLogger(parent.Logger())
+	for k, v := range parent.Attributes() {
+		ctx.SetAttribute(k, v)
+	}
+	return ctx, nil
+}
+
+// InvokeForked 以 ForkContext 创建的子请求Context调用后端服务；超时时间从当前请求的截止时间派生
+func InvokeForked(ctx *flux.Context, timeout time.Duration) (interface{}, *flux.ServeError) {
+	service := ctx.Endpoint().Service
+	if timeout > 0 {
+		cancel := ctx.WithTimeout(timeout)
+		defer cancel()
+	}
+	response, serr := DoInvokeCodec(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	if serr := GrpcStatusError(response); nil != serr {
+		return nil, serr
+	}
+	value, err := DecodeResponseBody(response.Body)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayTransporter,
+			Message:    flux.ErrorMessageTransportDecodeResponse,
+			CauseError: err,
+		}
+	}
+	if response.StatusCode >= http.StatusBadRequest {
+		return nil, &flux.ServeError{
+			StatusCode: response.StatusCode,
+			ErrorCode:  flux.ErrorCodeGatewayTransporter,
+			Message:    fmt.Sprintf("service %s responses status: %d", service.ServiceID(), response.StatusCode),
+			Extras:     map[string]interface{}{"body": value},
+		}
+	}
+	return value, nil
+}
+
+// DecodeResponseBody 解析后端服务的响应数据；JSON格式的数据解析为对象，其它数据返回字符串
+func DecodeResponseBody(body interface{}) (interface{}, error) {
+	var data []byte
+	switch v := body.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	case io.Reader:
+		bs, err := ioutil.ReadAll(v)
+		if closer, ok := v.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		if nil != err {
+			return nil, fmt.Errorf("read service response, err: %w", err)
+		}
+		data = bs
+	default:
+		return v, nil
+	}
+	var value interface{}
+	if err := ext.JSONUnmarshal(data, &value); nil == err {
+		return value, nil
+	}
+	return string(data), nil
+}
+
+// BindArgumentValues 复制参数定义，指定的参数值替换从请求中查找的参数值
+func BindArgumentValues(arguments []flux.Argument, values map[string]interface{}) []flux.Argument {
+	out := make([]flux.Argument, len(arguments))
+	copy(out, arguments)
+	for i := range out {
+		value, ok := values[out[i].Name]
+		if !ok {
+			continue
+		}
+		out[i].ValueLoader = func() flux.MTValue {
+			return flux.WrapObjectMTValue(value)
+		}
+		if nil == out[i].ValueResolver {
+			out[i].ValueResolver = ext.MTValueResolverByType(out[i].Class)
+		}
+	}
+	return out
+}
@@ -0,0 +1,130 @@
+package transporter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const protoComposeTest = "COMPOSE_TEST"
+
+// composeTestTransporter 读取子请求的Body及变量，返回JSON响应
+type composeTestTransporter struct{}
+
+func (composeTestTransporter) Invoke(ctx *flux.Context, _ flux.TransporterService) (interface{}, *flux.ServeError) {
+	return nil, nil
+}
+
+func (composeTestTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return nil, &flux.ServeError{StatusCode: flux.StatusServerError, CauseError: err}
+	}
+	body, _ := ioutil.ReadAll(reader)
+	_ = reader.Close()
+	// 子请求之间不共享变量
+	if _, ok := ctx.GetVariable("compose.service"); ok {
+		return nil, &flux.ServeError{StatusCode: flux.StatusServerError, Message: "variable shared"}
+	}
+	ctx.SetVariable("compose.service", service.ServiceId)
+	_, deadline := ctx.Context().Deadline()
+	return &flux.ResponseBody{
+		StatusCode: flux.StatusOK,
+		Body:       fmt.Sprintf(`{"service":%q,"body":%q,"deadline":%t}`, service.ServiceId, string(body), deadline),
+	}, nil
+}
+
+func (composeTestTransporter) Transport(_ *flux.Context) {}
+
+func (composeTestTransporter) Writer() flux.TransportWriter {
+	return new(DefaultTransportWriter)
+}
+
+func newComposeContext(body string) *flux.Context {
+	request := httptest.NewRequest(http.MethodPost, "http://gateway/compose/1", strings.NewReader(body))
+	ctx := flux.NewContext()
+	ctx.Reset(internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "compose", nil), &flux.Endpoint{})
+	// 模拟WebListener缓存的请求Body
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(body)), nil
+	}
+	return ctx
+}
+
+func newComposeService(id string) flux.TransporterService {
+	return flux.TransporterService{
+		ServiceId: id,
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: flux.ServiceAttrTagRpcProto, Value: protoComposeTest}},
+		},
+	}
+}
+
+func TestForkContext(t *testing.T) {
+	assert := assert.New(t)
+	parent := newComposeContext(`{"id":1}`)
+	parent.SetAttribute("user", "u1")
+	parent.SetVariable("parent.var", "v")
+	cancel := parent.WithTimeout(time.Second)
+	defer cancel()
+	ctx, serr := ForkContext(parent, newComposeService("users"))
+	assert.Nil(serr)
+	assert.Equal("users", ctx.Endpoint().Service.ServiceId)
+	assert.Equal("", parent.Endpoint().Service.ServiceId)
+	user, ok := ctx.GetAttribute("user")
+	assert.True(ok)
+	assert.Equal("u1", user)
+	assert.Equal("/compose/1", ctx.URL().Path)
+	// 不共享WebContext的变量
+	_, ok = ctx.GetVariable("parent.var")
+	assert.False(ok)
+	ctx.SetVariable("child.var", "v")
+	_, ok = parent.GetVariable("child.var")
+	assert.False(ok)
+	// 继承父请求的截止时间
+	expected, _ := parent.Context().Deadline()
+	deadline, ok := ctx.Context().Deadline()
+	assert.True(ok)
+	assert.Equal(expected, deadline)
+	cancel()
+	<-ctx.Context().Done()
+}
+
+func TestInvokeForked_Parallel(t *testing.T) {
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	ext.RegisterTransporter(protoComposeTest, composeTestTransporter{})
+	parent := newComposeContext(`{"id":1}`)
+	services := []string{"users", "orders", "items", "coupons", "address", "points"}
+	forks := make([]*flux.Context, len(services))
+	for i, id := range services {
+		ctx, serr := ForkContext(parent, newComposeService(id))
+		assert.Nil(t, serr)
+		forks[i] = ctx
+	}
+	values := make([]interface{}, len(services))
+	errs := make([]*flux.ServeError, len(services))
+	var wg sync.WaitGroup
+	for i := range forks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], errs[i] = InvokeForked(forks[i], time.Second)
+		}(i)
+	}
+	wg.Wait()
+	for i, id := range services {
+		assert.Nil(t, errs[i], id)
+		assert.Equal(t, map[string]interface{}{"service": id, "body": `{"id":1}`, "deadline": true}, values[i])
+	}
+}
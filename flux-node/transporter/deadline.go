@@ -0,0 +1,59 @@
+package transporter
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// HeaderXDeadlineMillis 请求剩余的处理时间（毫秒），后端服务可据此放弃无法按时完成的请求
+	HeaderXDeadlineMillis = "X-Deadline-Millis"
+	// HeaderGrpcTimeout gRPC协议的超时时间：数值（最多8位）+单位（H，M，S，m，u，n）
+	HeaderGrpcTimeout = "Grpc-Timeout"
+)
+
+var grpcTimeoutUnits = []struct {
+	unit string
+	size time.Duration
+}{
+	{"n", time.Nanosecond},
+	{"u", time.Microsecond},
+	{"m", time.Millisecond},
+	{"S", time.Second},
+	{"M", time.Minute},
+	{"H", time.Hour},
+}
+
+// SetDeadlineHeaders 按网关请求的剩余超时时间，设置后端请求的截止时间Header；
+// 请求未设置截止时间时，不修改Header；gRPC请求同时设置 grpc-timeout。
+func SetDeadlineHeaders(ctx *flux.Context, header http.Header) {
+	remaining, ok := ctx.RemainingTimeout()
+	if !ok {
+		return
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	// 覆盖客户端传递的值，后端只信任网关计算的截止时间
+	header.Set(HeaderXDeadlineMillis, strconv.FormatInt(remaining.Milliseconds(), 10))
+	if IsGrpcContentType(header.Get(flux.HeaderContentType)) {
+		header.Set(HeaderGrpcTimeout, FormatGrpcTimeout(remaining))
+	}
+}
+
+// FormatGrpcTimeout 按gRPC协议格式化超时时间；选择数值不超过8位的最小单位
+func FormatGrpcTimeout(timeout time.Duration) string {
+	const maxValue = 99999999
+	for _, u := range grpcTimeoutUnits {
+		if value := int64(timeout / u.size); value < maxValue {
+			// 向上取整，避免截断后的超时时间为0
+			if timeout%u.size != 0 {
+				value++
+			}
+			return strconv.FormatInt(value, 10) + u.unit
+		}
+	}
+	return strconv.FormatInt(maxValue, 10) + "H"
+}
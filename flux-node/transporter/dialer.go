@@ -0,0 +1,44 @@
+package transporter
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node/common"
+	"net"
+	"strings"
+)
+
+// NewPreferredDialContext 返回按IP版本偏好连接上游地址的Dial函数：ipv4，ipv6 优先连接指定版本的地址，
+// 失败时依次尝试其它地址；any 或空值时使用系统默认行为（双栈，Happy Eyeballs）。
+func NewPreferredDialContext(prefer string, dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	prefer = strings.ToLower(prefer)
+	if prefer != common.IPPreferenceIPv4 && prefer != common.IPPreferenceIPv6 {
+		return dialer.DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if nil != err || nil != net.ParseIP(host) {
+			return dialer.DialContext(ctx, network, addr)
+		}
+		ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if nil != err {
+			return nil, err
+		}
+		preferred, others := make([]net.IPAddr, 0, len(ips)), make([]net.IPAddr, 0, len(ips))
+		for _, ip := range ips {
+			if (nil != ip.IP.To4()) == (prefer == common.IPPreferenceIPv4) {
+				preferred = append(preferred, ip)
+			} else {
+				others = append(others, ip)
+			}
+		}
+		var lastErr error
+		for _, ip := range append(preferred, others...) {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+			if nil == err {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
@@ -37,7 +37,7 @@ func (b BodyValues) ReadBodyValue(bodyKey string) interface{} {
 func (b BodyValues) ReadStatusValue(statusKey string) (int, error) {
 	if status, ok := b[statusKey]; ok {
 		if code, err := cast.ToIntE(status); nil != err {
-			logger.Warnw("Invalid rpc response status",
+			logger.LimitedWarnw("Invalid rpc response status",
 				"type", reflect.TypeOf(status), "status", status)
 			return 0, ErrDecodeInvalidStatus
 		} else {
@@ -70,7 +70,7 @@ func (b BodyValues) ReadHeaderValue(headerKey string) (http.Header, error) {
 		}
 		return omap, nil
 	}
-	logger.Warnw("Invalid rpc response headers", "type", reflect.TypeOf(hkv), "value", hkv)
+	logger.LimitedWarnw("Invalid rpc response headers", "type", reflect.TypeOf(hkv), "value", hkv)
 	return nil, ErrDecodeInvalidHeaders
 }
 
@@ -0,0 +1,179 @@
+package dubbo
+
+import (
+	"errors"
+	"fmt"
+	"github.com/apache/dubbo-go-hessian2/java_exception"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/spf13/cast"
+	"regexp"
+	"strings"
+)
+
+const (
+	// 异常映射规则列表：class，message_pattern，services，status，code，message
+	ConfigKeyExceptionMappings = "exception_mappings"
+)
+
+const (
+	ErrorCodeDubboProviderException = "DUBBO:PROVIDER_EXCEPTION"
+)
+
+// JavaException 后端Dubbo服务抛出的Java异常
+type JavaException struct {
+	ClassName  string
+	Message    string
+	StackTrace []string
+}
+
+func (e *JavaException) Error() string {
+	return e.ClassName + ": " + e.Message
+}
+
+// SimpleName 返回不含包名的异常类名
+func (e *JavaException) SimpleName() string {
+	return e.ClassName[strings.LastIndexByte(e.ClassName, '.')+1:]
+}
+
+// ParseJavaException 从Dubbo调用错误中解析Hessian序列化的Java异常（类名，消息，堆栈）
+func ParseJavaException(err error) (*JavaException, bool) {
+	if nil == err {
+		return nil, false
+	}
+	var throwable java_exception.Throwabler
+	if errors.As(err, &throwable) {
+		out := &JavaException{ClassName: throwable.JavaClassName(), Message: throwable.Error()}
+		for _, st := range throwable.GetStackTrace() {
+			out.StackTrace = append(out.StackTrace, fmt.Sprintf("%s.%s(%s:%d)", st.DeclaringClass, st.MethodName, st.FileName, st.LineNumber))
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// ParseJavaExceptionValue 从泛化调用的返回值中解析以Map表示的Java异常（未注册Go类型的异常类）：
+// 包含 class，stackTrace 字段，以及 detailMessage 或 message 字段。
+func ParseJavaExceptionValue(value interface{}) (*JavaException, bool) {
+	var fields map[string]interface{}
+	switch m := value.(type) {
+	case map[string]interface{}:
+		fields = m
+	case map[interface{}]interface{}:
+		fields = cast.ToStringMap(m)
+	default:
+		return nil, false
+	}
+	class := cast.ToString(fields["class"])
+	if _, ok := fields["stackTrace"]; !ok || class == "" {
+		return nil, false
+	}
+	message := cast.ToString(fields["detailMessage"])
+	if message == "" {
+		message = cast.ToString(fields["message"])
+	}
+	return &JavaException{ClassName: class, Message: message}, true
+}
+
+// ExceptionMapping Java异常到网关错误的映射规则
+type ExceptionMapping struct {
+	// 异常类名；支持 * 结尾的前缀匹配，以及不含包名的简单类名
+	Class string
+	// 异常消息的正则匹配，为空时不检查
+	MessagePattern *regexp.Regexp
+	// 适用的服务接口或ServiceId，为空时适用全部服务
+	Services   []string
+	StatusCode int
+	ErrorCode  string
+	// 错误消息；支持 {class}，{message} 变量；为空时使用异常消息
+	Message string
+}
+
+// Match 判断规则是否匹配指定服务的异常
+func (m *ExceptionMapping) Match(service *flux.TransporterService, exception *JavaException) bool {
+	if len(m.Services) > 0 {
+		matched := false
+		for _, s := range m.Services {
+			if s == service.Interface || s == service.ServiceID() {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	switch {
+	case m.Class == "*":
+	case strings.HasSuffix(m.Class, "*"):
+		if !strings.HasPrefix(exception.ClassName, m.Class[:len(m.Class)-1]) {
+			return false
+		}
+	case m.Class != exception.ClassName && m.Class != exception.SimpleName():
+		return false
+	}
+	return nil == m.MessagePattern || m.MessagePattern.MatchString(exception.Message)
+}
+
+// ToServeError 按规则将异常转换为网关错误
+func (m *ExceptionMapping) ToServeError(exception *JavaException) *flux.ServeError {
+	message := exception.Message
+	if m.Message != "" {
+		message = strings.NewReplacer("{class}", exception.ClassName, "{message}", exception.Message).Replace(m.Message)
+	}
+	return &flux.ServeError{
+		StatusCode: m.StatusCode,
+		ErrorCode:  m.ErrorCode,
+		Message:    message,
+		CauseError: exception,
+		Extras:     map[string]interface{}{"exception.class": exception.ClassName},
+	}
+}
+
+// ExceptionMapper 按顺序匹配映射规则；没有匹配的规则时，返回通用的服务异常错误，不向客户端输出异常消息
+type ExceptionMapper []*ExceptionMapping
+
+// NewExceptionMapper 从配置加载异常映射规则
+func NewExceptionMapper(configs []*flux.Configuration) (ExceptionMapper, error) {
+	out := make(ExceptionMapper, 0, len(configs))
+	for _, c := range configs {
+		c.SetDefaults(map[string]interface{}{
+			"status": flux.StatusServerError,
+			"code":   ErrorCodeDubboProviderException,
+		})
+		mapping := &ExceptionMapping{
+			Class:      c.GetString("class"),
+			Services:   c.GetStringSlice("services"),
+			StatusCode: c.GetInt("status"),
+			ErrorCode:  c.GetString("code"),
+			Message:    c.GetString("message"),
+		}
+		if mapping.Class == "" {
+			return nil, errors.New("dubbo exception mapping config(class) is required")
+		}
+		if pattern := c.GetString("message_pattern"); pattern != "" {
+			re, err := regexp.Compile(pattern)
+			if nil != err {
+				return nil, fmt.Errorf("dubbo exception mapping config(message_pattern) is invalid, class: %s, err: %w", mapping.Class, err)
+			}
+			mapping.MessagePattern = re
+		}
+		out = append(out, mapping)
+	}
+	return out, nil
+}
+
+// Map 将Java异常转换为网关错误
+func (m ExceptionMapper) Map(service *flux.TransporterService, exception *JavaException) *flux.ServeError {
+	for _, mapping := range m {
+		if mapping.Match(service, exception) {
+			return mapping.ToServeError(exception)
+		}
+	}
+	return &flux.ServeError{
+		StatusCode: flux.StatusServerError,
+		ErrorCode:  ErrorCodeDubboProviderException,
+		Message:    flux.ErrorMessageDubboProviderException,
+		CauseError: exception,
+		Extras:     map[string]interface{}{"exception.class": exception.ClassName},
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/bytepowered/flux/flux-node"
 	jsoniter "github.com/json-iterator/go"
 	"reflect"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -39,7 +40,9 @@ const (
 )
 
 func init() {
-	ext.RegisterTransporter(flux.ProtoDubbo, NewTransporter())
+	ext.RegisterTransporterFactory(flux.ProtoDubbo, func() interface{} {
+		return NewTransporter()
+	})
 }
 
 var (
@@ -84,6 +87,7 @@ type RpcTransporter struct {
 	writer    flux.TransportWriter   // Writer
 	// 内部私有
 	trace         bool
+	exceptions    ExceptionMapper
 	configuration *flux.Configuration
 	servmx        sync.RWMutex
 }
@@ -219,6 +223,12 @@ func (b *RpcTransporter) Init(config *flux.Configuration) error {
 	b.configuration = config
 	b.trace = config.GetBool(ConfigKeyTraceEnable)
 	logger.Infow("Dubbo transporter transporter request trace", "enable", b.trace)
+	// 后端服务Java异常的映射规则
+	exceptions, err := NewExceptionMapper(config.GetConfigurationSlice(ConfigKeyExceptionMappings))
+	if nil != err {
+		return err
+	}
+	b.exceptions = exceptions
 	// Set default impl if not present
 	if nil == b.optionsf {
 		b.optionsf = make([]GenericOptionsFunc, 0)
@@ -288,6 +298,9 @@ func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.Transporter
 	// decode response
 	result, err := b.codec(ctx, raw)
 	if nil != err {
+		if exception, ok := ParseJavaException(err); ok {
+			return nil, b.mapException(ctx, service, exception)
+		}
 		return nil, &flux.ServeError{
 			StatusCode: flux.StatusServerError,
 			ErrorCode:  flux.ErrorCodeGatewayInternal,
@@ -296,6 +309,10 @@ func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.Transporter
 		}
 	}
 	fluxpkg.AssertNotNil(result, "dubbo: <result> must not nil, request.id: "+ctx.RequestId())
+	// 泛化调用以Map返回的异常对象
+	if exception, ok := ParseJavaExceptionValue(result.Body); ok {
+		return nil, b.mapException(ctx, service, exception)
+	}
 	return result, nil
 }
 
@@ -316,10 +333,19 @@ func (b *RpcTransporter) DoInvoke(types []string, values interface{}, service fl
 		logger.TraceContext(ctx).Infow("TRANSPORTER:DUBBO:INVOKE",
 			"transporter-service", service.ServiceID(), "arg-values", values, "arg-types", types, "attrs", att)
 	}
+	// 传递请求剩余的超时时间
+	if remaining, ok := ctx.RemainingTimeout(); ok {
+		if m, ok := att.(map[string]string); ok {
+			m[constant.TIMEOUT_KEY] = strconv.FormatInt(remaining.Milliseconds(), 10)
+		}
+	}
 	generic := b.LoadGenericService(&service)
 	goctx := context.WithValue(ctx.Context(), constant.AttachmentKey, att)
 	resultW := b.invokef(goctx, []interface{}{service.Method, types, values}, generic)
 	if cause := resultW.Error(); cause != nil {
+		if exception, ok := ParseJavaException(cause); ok {
+			return nil, b.mapException(ctx, service, exception)
+		}
 		return nil, &flux.ServeError{
 			StatusCode: flux.StatusBadGateway,
 			ErrorCode:  flux.ErrorCodeGatewayTransporter,
@@ -342,6 +368,17 @@ func (b *RpcTransporter) DoInvoke(types []string, values interface{}, service fl
 	}
 }
 
+// mapException 按映射规则将后端服务抛出的Java异常转换为网关错误；异常堆栈只输出到日志
+func (b *RpcTransporter) mapException(ctx *flux.Context, service flux.TransporterService, exception *JavaException) *flux.ServeError {
+	stack := exception.StackTrace
+	if len(stack) > 10 {
+		stack = stack[:10]
+	}
+	logger.TraceContext(ctx).Warnw("TRANSPORTER:DUBBO:PROVIDER_EXCEPTION", "transporter-service", service.ServiceID(),
+		"exception.class", exception.ClassName, "exception.message", exception.Message, "exception.stack", stack)
+	return b.exceptions.Map(&service, exception)
+}
+
 // LoadGenericService create and cache dubbo generic service
 func (b *RpcTransporter) LoadGenericService(service *flux.TransporterService) common.RPCService {
 	b.servmx.Lock()
@@ -9,7 +9,9 @@ import (
 )
 
 func init() {
-	ext.RegisterTransporter(flux.ProtoEcho, NewTransporter())
+	ext.RegisterTransporterFactory(flux.ProtoEcho, func() interface{} {
+		return NewTransporter()
+	})
 }
 
 var (
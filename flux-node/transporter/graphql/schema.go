@@ -0,0 +1,205 @@
+package graphql
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// Service属性：映射为GraphQL字段的名称；未定义时Service不参与GraphQL Schema
+	ServiceAttrTagGraphQLField = "graphqlfield"
+	// Service属性：字段所属的操作类型：query，mutation；默认为 query
+	ServiceAttrTagGraphQLType = "graphqltype"
+	// Service属性：字段所属的Schema名称；默认为 default；GraphQL网关服务的 Interface 指定其使用的Schema
+	ServiceAttrTagGraphQLSchema = "graphqlschema"
+	// Service属性：字段描述
+	ServiceAttrTagGraphQLDesc = "graphqldesc"
+)
+
+const (
+	OperationQuery    = "query"
+	OperationMutation = "mutation"
+	DefaultSchemaName = "default"
+)
+
+// JSONScalarType 任意JSON数据；用于后端服务的响应数据及复杂类型参数
+var JSONScalarType = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "任意JSON数据",
+	Serialize: func(value interface{}) interface{} {
+		return value
+	},
+	ParseValue: func(value interface{}) interface{} {
+		return value
+	},
+	ParseLiteral: parseLiteral,
+})
+
+// schemaFields Schema中的字段及其映射的Service
+type schemaFields struct {
+	name      string
+	queries   []flux.TransporterService
+	mutations []flux.TransporterService
+}
+
+// signature 字段定义的签名；签名变化时重建Schema
+func (f *schemaFields) signature() string {
+	var sb strings.Builder
+	for _, list := range [][]flux.TransporterService{f.queries, f.mutations} {
+		for _, srv := range list {
+			sb.WriteString(srv.GetAttr(ServiceAttrTagGraphQLField).GetString())
+			sb.WriteByte('=')
+			sb.WriteString(srv.ServiceID())
+			for _, arg := range srv.Arguments {
+				sb.WriteByte(',')
+				sb.WriteString(arg.Name + ":" + arg.Class + ":" + arg.HttpScope)
+			}
+			sb.WriteByte(';')
+		}
+		sb.WriteByte('|')
+	}
+	return sb.String()
+}
+
+// lookupSchemaFields 从注册的Service元数据中查找指定Schema的字段；按字段名排序
+func lookupSchemaFields(name string) *schemaFields {
+	out := &schemaFields{name: name}
+	for _, srv := range ext.TransporterServices() {
+		field := srv.GetAttr(ServiceAttrTagGraphQLField).GetString()
+		if field == "" {
+			continue
+		}
+		schema := srv.GetAttr(ServiceAttrTagGraphQLSchema).GetString()
+		if schema == "" {
+			schema = DefaultSchemaName
+		}
+		if schema != name {
+			continue
+		}
+		if strings.ToLower(srv.GetAttr(ServiceAttrTagGraphQLType).GetString()) == OperationMutation {
+			out.mutations = append(out.mutations, srv)
+		} else {
+			out.queries = append(out.queries, srv)
+		}
+	}
+	for _, list := range [][]flux.TransporterService{out.queries, out.mutations} {
+		sort.Slice(list, func(i, j int) bool {
+			return list[i].GetAttr(ServiceAttrTagGraphQLField).GetString() < list[j].GetAttr(ServiceAttrTagGraphQLField).GetString()
+		})
+	}
+	return out
+}
+
+// buildSchema 按字段定义构建GraphQL Schema；每个字段的参数来自Service的参数定义，返回值为JSON类型
+func buildSchema(fields *schemaFields, resolve func(flux.TransporterService) graphql.FieldResolveFn) (*graphql.Schema, error) {
+	build := func(list []flux.TransporterService) graphql.Fields {
+		out := make(graphql.Fields, len(list))
+		for _, srv := range list {
+			name := srv.GetAttr(ServiceAttrTagGraphQLField).GetString()
+			args := make(graphql.FieldConfigArgument, len(srv.Arguments))
+			for _, arg := range exposedArguments(srv.Arguments) {
+				args[arg.Name] = &graphql.ArgumentConfig{Type: argumentType(arg)}
+			}
+			out[name] = &graphql.Field{
+				Name:        name,
+				Description: srv.GetAttr(ServiceAttrTagGraphQLDesc).GetString(),
+				Type:        JSONScalarType,
+				Args:        args,
+				Resolve:     resolve(srv),
+			}
+		}
+		return out
+	}
+	config := graphql.SchemaConfig{}
+	queries := build(fields.queries)
+	if len(queries) == 0 {
+		// GraphQL要求Query类型至少包含一个字段
+		queries["_services"] = &graphql.Field{
+			Type: graphql.Int,
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return len(fields.queries) + len(fields.mutations), nil
+			},
+		}
+	}
+	config.Query = graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: queries})
+	if len(fields.mutations) > 0 {
+		config.Mutation = graphql.NewObject(graphql.ObjectConfig{Name: "Mutation", Fields: build(fields.mutations)})
+	}
+	schema, err := graphql.NewSchema(config)
+	if nil != err {
+		return nil, fmt.Errorf("build graphql schema: %s, err: %w", fields.name, err)
+	}
+	return &schema, nil
+}
+
+// exposedArguments 返回由GraphQL参数传递的Service参数；Header，Attribute及Request值域的参数仍从网关请求中解析，客户端不能覆盖
+func exposedArguments(args []flux.Argument) []flux.Argument {
+	out := make([]flux.Argument, 0, len(args))
+	for _, arg := range args {
+		if isExposed(arg) {
+			out = append(out, arg)
+		}
+	}
+	return out
+}
+
+func isExposed(arg flux.Argument) bool {
+	switch strings.ToUpper(arg.HttpScope) {
+	case flux.ScopeHeader, flux.ScopeHeaderMap, flux.ScopeAttr, flux.ScopeAttrs, flux.ScopeRequest:
+		return false
+	default:
+		return true
+	}
+}
+
+func argumentType(arg flux.Argument) graphql.Input {
+	switch arg.Class {
+	case flux.JavaLangStringClassName:
+		return graphql.String
+	case flux.JavaLangIntegerClassName, flux.JavaLangLongClassName:
+		return graphql.Int
+	case flux.JavaLangFloatClassName, flux.JavaLangDoubleClassName:
+		return graphql.Float
+	case flux.JavaLangBooleanClassName:
+		return graphql.Boolean
+	default:
+		return JSONScalarType
+	}
+}
+
+func parseLiteral(value ast.Value) interface{} {
+	switch v := value.(type) {
+	case *ast.StringValue:
+		return v.Value
+	case *ast.BooleanValue:
+		return v.Value
+	case *ast.IntValue:
+		n, _ := strconv.ParseInt(v.Value, 10, 64)
+		return n
+	case *ast.FloatValue:
+		n, _ := strconv.ParseFloat(v.Value, 64)
+		return n
+	case *ast.EnumValue:
+		return v.Value
+	case *ast.ListValue:
+		out := make([]interface{}, 0, len(v.Values))
+		for _, item := range v.Values {
+			out = append(out, parseLiteral(item))
+		}
+		return out
+	case *ast.ObjectValue:
+		out := make(map[string]interface{}, len(v.Fields))
+		for _, field := range v.Fields {
+			out[field.Name.Value] = parseLiteral(field.Value)
+		}
+		return out
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,274 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/graphql-go/graphql"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 单个字段调用后端服务的超时时间
+	ConfigKeyFieldTimeout = "field_timeout"
+	// 单次请求最多解析的字段数量（调用后端服务的次数）
+	ConfigKeyMaxFields = "max_fields"
+)
+
+const (
+	MIMEApplicationGraphQL = "application/graphql"
+)
+
+func init() {
+	ext.RegisterTransporterFactory(flux.ProtoGraphQL, func() interface{} {
+		return NewTransporter()
+	})
+}
+
+var (
+	_ flux.Transporter = new(RpcTransporter)
+)
+
+// RpcTransporter GraphQL网关服务：执行客户端的GraphQL查询，查询字段映射到已注册的后端服务（Dubbo/Http/gRPC），
+// 多个字段的后端服务并行调用，响应数据聚合后返回；GraphQL网关服务作为普通Endpoint的后端服务，经过Endpoint的Filter处理。
+// 字段由后端Service的 graphqlfield 属性定义，网关服务的 Interface 指定使用的Schema名称。
+type RpcTransporter struct {
+	timeout   time.Duration
+	maxFields int
+	writer    flux.TransportWriter
+	schemas   map[string]*cachedSchema
+	mu        sync.Mutex
+}
+
+type cachedSchema struct {
+	signature string
+	schema    *graphql.Schema
+}
+
+type graphqlRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type fieldKey struct{}
+
+type fieldCounter struct {
+	count int
+	max   int
+	mu    sync.Mutex
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		writer:  new(transporter.DefaultTransportWriter),
+		schemas: make(map[string]*cachedSchema, 2),
+	}
+}
+
+func (b *RpcTransporter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyFieldTimeout: time.Second * 10,
+		ConfigKeyMaxFields:    32,
+	})
+	b.timeout = config.GetDuration(ConfigKeyFieldTimeout)
+	b.maxFields = config.GetInt(ConfigKeyMaxFields)
+	logger.Infow("GraphQL transporter init", "field-timeout", b.timeout, "max-fields", b.maxFields)
+	return nil
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	transporter.DoTransport(ctx, b)
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	result, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return &flux.ResponseBody{
+		StatusCode: flux.StatusOK,
+		Headers:    make(http.Header, 0),
+		Body:       result,
+	}, nil
+}
+
+// Invoke 执行GraphQL查询；查询及字段错误按GraphQL规范在响应的 errors 中返回
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	request, err := parseRequest(ctx)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    "GRAPHQL:REQUEST:INVALID",
+			CauseError: err,
+		}
+	}
+	name := service.Interface
+	if name == "" {
+		name = DefaultSchemaName
+	}
+	schema, err := b.schema(name)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    "GRAPHQL:SCHEMA:INVALID",
+			CauseError: err,
+		}
+	}
+	counter := &fieldCounter{max: b.maxFields}
+	return graphql.Do(graphql.Params{
+		Schema:         *schema,
+		RequestString:  request.Query,
+		VariableValues: request.Variables,
+		OperationName:  request.OperationName,
+		Context:        context.WithValue(ctx.Context(), fieldKey{}, counter),
+		RootObject:     map[string]interface{}{"context": ctx},
+	}), nil
+}
+
+// schema 返回指定名称的Schema；字段定义变化时重建
+func (b *RpcTransporter) schema(name string) (*graphql.Schema, error) {
+	fields := lookupSchemaFields(name)
+	signature := fields.signature()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if cached, ok := b.schemas[name]; ok && cached.signature == signature {
+		return cached.schema, nil
+	}
+	schema, err := buildSchema(fields, b.resolve)
+	if nil != err {
+		return nil, err
+	}
+	logger.Infow("TRANSPORTER:GRAPHQL:SCHEMA:BUILD", "schema", name,
+		"queries", len(fields.queries), "mutations", len(fields.mutations))
+	b.schemas[name] = &cachedSchema{signature: signature, schema: schema}
+	return schema, nil
+}
+
+// resolve 返回调用后端服务的字段解析函数；后端服务异步调用，多个字段并行执行；
+// 每个字段使用独立的子请求Context，不共享GraphQL网关请求的WebContext
+func (b *RpcTransporter) resolve(service flux.TransporterService) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		root, _ := p.Info.RootValue.(map[string]interface{})
+		parent, ok := root["context"].(*flux.Context)
+		if !ok {
+			return nil, errors.New("graphql: request context not found")
+		}
+		if counter, ok := p.Context.Value(fieldKey{}).(*fieldCounter); ok && !counter.acquire() {
+			return nil, fmt.Errorf("graphql: too many fields, max: %d", counter.max)
+		}
+		// 在请求处理协程中创建子请求
+		ctx, serr := transporter.ForkContext(parent, b.fieldService(service, p.Args))
+		if nil != serr {
+			return nil, fmt.Errorf("%s: %s", serr.GetErrorCode(), serr.Message)
+		}
+		type result struct {
+			value interface{}
+			err   error
+		}
+		done := make(chan result, 1)
+		go func() {
+			defer func() {
+				if rvr := recover(); nil != rvr {
+					logger.TraceContext(parent).Errorw("TRANSPORTER:GRAPHQL:FIELD:PANIC", "field", p.Info.FieldName, "error", rvr)
+					done <- result{err: fmt.Errorf("graphql: field %s panic: %v", p.Info.FieldName, rvr)}
+				}
+			}()
+			value, err := b.invokeField(ctx)
+			done <- result{value: value, err: err}
+		}()
+		return func() (interface{}, error) {
+			r := <-done
+			return r.value, r.err
+		}, nil
+	}
+}
+
+// fieldService 返回字段映射的后端服务，GraphQL参数替换从请求中查找的参数值
+func (b *RpcTransporter) fieldService(service flux.TransporterService, args map[string]interface{}) flux.TransporterService {
+	// 使用最新注册的Service元数据
+	if latest, ok := ext.TransporterServiceById(service.ServiceID()); ok {
+		service = latest
+	}
+	service.Arguments = bindArguments(service.Arguments, args)
+	return service
+}
+
+// invokeField 以子请求Context调用字段映射的后端服务；请求属性（例如认证信息）从GraphQL网关请求中继承
+func (b *RpcTransporter) invokeField(ctx *flux.Context) (interface{}, error) {
+	value, serr := transporter.InvokeForked(ctx, b.timeout)
+	if nil != serr {
+		logger.TraceContext(ctx).Infow("TRANSPORTER:GRAPHQL:FIELD:INVOKE/ERROR", "service-id", ctx.Endpoint().Service.ServiceID(), "error", serr)
+		return nil, fmt.Errorf("%s: %s", serr.GetErrorCode(), serr.Message)
+	}
+	return value, nil
+}
+
+// bindArguments GraphQL传递的参数值替换从请求中查找的参数值；不开放给客户端的参数仍从请求中解析
+func bindArguments(arguments []flux.Argument, args map[string]interface{}) []flux.Argument {
+	values := make(map[string]interface{}, len(args))
+	for _, arg := range exposedArguments(arguments) {
+		if value, ok := args[arg.Name]; ok {
+			values[arg.Name] = value
+		}
+	}
+	return transporter.BindArgumentValues(arguments, values)
+}
+
+// parseRequest 解析GraphQL请求：GET请求从Query参数读取，POST请求支持 application/json 及 application/graphql 格式
+func parseRequest(ctx *flux.Context) (*graphqlRequest, error) {
+	out := new(graphqlRequest)
+	if ctx.Method() == http.MethodGet {
+		out.Query = ctx.QueryVar("query")
+		out.OperationName = ctx.QueryVar("operationName")
+		if vars := ctx.QueryVar("variables"); vars != "" {
+			if err := ext.JSONUnmarshal([]byte(vars), &out.Variables); nil != err {
+				return nil, fmt.Errorf("invalid variables, err: %w", err)
+			}
+		}
+	} else {
+		reader, err := ctx.BodyReader()
+		if nil != err {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(reader)
+		_ = reader.Close()
+		if nil != err {
+			return nil, err
+		}
+		if strings.HasPrefix(ctx.HeaderVar(flux.HeaderContentType), MIMEApplicationGraphQL) {
+			out.Query = string(data)
+		} else if err := ext.JSONUnmarshal(bytes.TrimSpace(data), out); nil != err {
+			return nil, fmt.Errorf("invalid graphql request body, err: %w", err)
+		}
+	}
+	if out.Query == "" {
+		return nil, errors.New("graphql query is required")
+	}
+	return out, nil
+}
+
+func (c *fieldCounter) acquire() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.max > 0 && c.count >= c.max {
+		return false
+	}
+	c.count++
+	return true
+}
@@ -0,0 +1,90 @@
+package transporter
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	HeaderGrpcStatus  = "Grpc-Status"
+	HeaderGrpcMessage = "Grpc-Message"
+	HeaderTE          = "TE"
+	HeaderTrailer     = "Trailer"
+)
+
+const (
+	MIMEApplicationGrpc = "application/grpc"
+)
+
+// grpcStatuses gRPC状态码对应的名称及Http状态码
+var grpcStatuses = []struct {
+	name   string
+	status int
+}{
+	{"OK", http.StatusOK},
+	{"CANCELED", 499},
+	{"UNKNOWN", http.StatusInternalServerError},
+	{"INVALID_ARGUMENT", http.StatusBadRequest},
+	{"DEADLINE_EXCEEDED", http.StatusGatewayTimeout},
+	{"NOT_FOUND", http.StatusNotFound},
+	{"ALREADY_EXISTS", http.StatusConflict},
+	{"PERMISSION_DENIED", http.StatusForbidden},
+	{"RESOURCE_EXHAUSTED", http.StatusTooManyRequests},
+	{"FAILED_PRECONDITION", http.StatusBadRequest},
+	{"ABORTED", http.StatusConflict},
+	{"OUT_OF_RANGE", http.StatusBadRequest},
+	{"UNIMPLEMENTED", http.StatusNotImplemented},
+	{"INTERNAL", http.StatusInternalServerError},
+	{"UNAVAILABLE", http.StatusServiceUnavailable},
+	{"DATA_LOSS", http.StatusInternalServerError},
+	{"UNAUTHENTICATED", http.StatusUnauthorized},
+}
+
+// IsGrpcContentType 判断响应是否为gRPC协议数据（application/grpc，application/grpc+proto，application/grpc-web等）
+func IsGrpcContentType(contentType string) bool {
+	return strings.HasPrefix(strings.ToLower(contentType), MIMEApplicationGrpc)
+}
+
+// GrpcStatusError 从响应的Trailer（或Trailers-Only响应的Header）中读取grpc-status/grpc-message，
+// 非OK状态转换为网关错误：Http状态码按gRPC状态码映射，ErrorCode为 GRPC:<状态名称>；没有gRPC状态或状态为OK时返回nil。
+func GrpcStatusError(response *flux.ResponseBody) *flux.ServeError {
+	from := response.Trailers
+	if from.Get(HeaderGrpcStatus) == "" {
+		from = response.Headers
+	}
+	value := from.Get(HeaderGrpcStatus)
+	if value == "" {
+		return nil
+	}
+	code, err := strconv.Atoi(value)
+	if nil != err || code < 0 || code >= len(grpcStatuses) {
+		code = 2 // UNKNOWN
+	}
+	if code == 0 {
+		return nil
+	}
+	message := from.Get(HeaderGrpcMessage)
+	if unescaped, err := url.PathUnescape(message); nil == err {
+		message = unescaped
+	}
+	return &flux.ServeError{
+		StatusCode: grpcStatuses[code].status,
+		ErrorCode:  "GRPC:" + grpcStatuses[code].name,
+		Message:    message,
+		CauseError: fmt.Errorf("grpc-status: %s, grpc-message: %s", value, message),
+	}
+}
+
+// acceptTrailers 判断客户端是否声明支持Trailer
+func acceptTrailers(ctx *flux.Context) bool {
+	for _, te := range strings.Split(ctx.HeaderVar(HeaderTE), ",") {
+		if strings.EqualFold(strings.TrimSpace(te), "trailers") {
+			return true
+		}
+	}
+	return false
+}
@@ -42,7 +42,7 @@ func DefaultArgumentResolver(service *flux.TransporterService, inURL *url.URL, b
 	// 未定义参数，即透传Http请求：Rewrite inRequest path
 	newUrl := &url.URL{
 		Host:       service.RemoteHost,
-		Path:       service.Interface,
+		Path:       upstreamPath(service, inURL.Path, ctx),
 		Scheme:     service.Scheme,
 		Opaque:     inURL.Opaque,
 		User:       inURL.User,
@@ -54,7 +54,7 @@ func DefaultArgumentResolver(service *flux.TransporterService, inURL *url.URL, b
 	to := service.RpcTimeout()
 	timeout, err := time.ParseDuration(to)
 	if err != nil {
-		logger.Warnf("Illegal endpoint rpc-timeout: ", to)
+		logger.LimitedWarnw("TRANSPORTER:HTTP:ILLEGAL_RPC_TIMEOUT", "rpc-timeout", to, "service-id", service.ServiceID())
 		timeout = time.Second * 10
 	}
 	toctx, _ := context.WithTimeout(ctx.Context(), timeout)
@@ -81,3 +81,17 @@ func AssembleHttpValues(arguments []flux.Argument, ctx *flux.Context) (url.Value
 	}
 	return values, nil
 }
+
+// expandPathVars 使用请求的动态路径参数替换 Interface 中的 {name} 变量
+func expandPathVars(path string, ctx *flux.Context) string {
+	if !strings.Contains(path, "{") {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			segments[i] = ctx.PathVar(seg[1 : len(seg)-1])
+		}
+	}
+	return strings.Join(segments, "/")
+}
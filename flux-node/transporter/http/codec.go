@@ -3,6 +3,8 @@ package http
 import (
 	"errors"
 	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"io/ioutil"
 	"net/http"
 )
 
@@ -20,6 +22,9 @@ func NewTransportCodecFunc() flux.TransportCodec {
 				Body:       nil,
 			}, ErrUnknownHttpResponse
 		}
+		if transporter.IsGrpcContentType(resp.Header.Get(flux.HeaderContentType)) {
+			return decodeGrpcResponse(resp)
+		}
 		return &flux.ResponseBody{
 			StatusCode: resp.StatusCode,
 			Headers:    resp.Header,
@@ -27,3 +32,18 @@ func NewTransportCodecFunc() flux.TransportCodec {
 		}, nil
 	}
 }
+
+// decodeGrpcResponse gRPC响应的状态在Trailer中，需要读取全部Body数据后才可获取Trailer
+func decodeGrpcResponse(resp *http.Response) (*flux.ResponseBody, error) {
+	data, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if nil != err {
+		return nil, err
+	}
+	return &flux.ResponseBody{
+		StatusCode: resp.StatusCode,
+		Headers:    resp.Header,
+		Body:       data,
+		Trailers:   resp.Trailer,
+	}, nil
+}
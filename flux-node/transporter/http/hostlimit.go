@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ConfigKeyMaxConnsPerHost   = "max_conns_per_host"
+	ConfigKeyMaxPendingPerHost = "max_pending_per_host"
+	ConfigKeyOverflowPolicy    = "overflow_policy"
+	ConfigKeyQueueTimeout      = "queue_timeout"
+)
+
+const (
+	// OverflowPolicyQueue 超出连接数限制时排队等待，等待超时后拒绝
+	OverflowPolicyQueue = "queue"
+	// OverflowPolicyFail 超出连接数限制时立即拒绝
+	OverflowPolicyFail = "fail"
+)
+
+var (
+	ErrHostConnsOverflow   = errors.New("TRANSPORTER:HTTP:HOST_CONNS_OVERFLOW")
+	ErrHostPendingOverflow = errors.New("TRANSPORTER:HTTP:HOST_PENDING_OVERFLOW")
+	ErrHostQueueTimeout    = errors.New("TRANSPORTER:HTTP:HOST_QUEUE_TIMEOUT")
+)
+
+// HostLimiter 限制每个上游Host的并发请求数及排队请求数，保护处理能力较小的后端服务；
+// 请求占用的连接在响应Body关闭后释放。
+type HostLimiter struct {
+	maxConns   int
+	maxPending int
+	policy     string
+	timeout    time.Duration
+	hosts      map[string]*hostSlots
+	mu         sync.Mutex
+}
+
+type hostSlots struct {
+	slots   chan struct{}
+	pending int32
+}
+
+func NewHostLimiter(maxConns, maxPending int, policy string, timeout time.Duration) *HostLimiter {
+	return &HostLimiter{
+		maxConns:   maxConns,
+		maxPending: maxPending,
+		policy:     policy,
+		timeout:    timeout,
+		hosts:      make(map[string]*hostSlots, 16),
+	}
+}
+
+// Acquire 获取指定Host的连接许可；返回的释放函数可重复执行
+func (l *HostLimiter) Acquire(ctx context.Context, host string) (func(), error) {
+	if nil == l || l.maxConns <= 0 {
+		return func() {}, nil
+	}
+	hs := l.slotsOf(host)
+	select {
+	case hs.slots <- struct{}{}:
+		return hs.releaser(), nil
+	default:
+	}
+	if l.policy == OverflowPolicyFail {
+		return nil, ErrHostConnsOverflow
+	}
+	if pending := atomic.AddInt32(&hs.pending, 1); l.maxPending > 0 && int(pending) > l.maxPending {
+		atomic.AddInt32(&hs.pending, -1)
+		return nil, ErrHostPendingOverflow
+	}
+	defer atomic.AddInt32(&hs.pending, -1)
+	timer := time.NewTimer(l.timeout)
+	defer timer.Stop()
+	select {
+	case hs.slots <- struct{}{}:
+		return hs.releaser(), nil
+	case <-timer.C:
+		return nil, ErrHostQueueTimeout
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (l *HostLimiter) slotsOf(host string) *hostSlots {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	hs, ok := l.hosts[host]
+	if !ok {
+		hs = &hostSlots{slots: make(chan struct{}, l.maxConns)}
+		l.hosts[host] = hs
+	}
+	return hs
+}
+
+func (s *hostSlots) releaser() func() {
+	once := new(sync.Once)
+	return func() {
+		once.Do(func() {
+			<-s.slots
+		})
+	}
+}
+
+// releaseBody 响应Body读取完成或关闭时释放连接许可
+type releaseBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releaseBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.release()
+	}
+	return n, err
+}
+
+func (b *releaseBody) Close() error {
+	defer b.release()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 上游路径重写规则：定义重写规则时，按规则重写请求路径，并拼接在 Interface 路径之后；
+// 例如 Interface 为 /v1，rewritestrip 为 /api 时，请求路径 /api/users 的上游路径为 /v1/users；Interface 为 / 时不拼接前缀。
+const (
+	// Endpoint属性：重写上游路径时，删除请求路径的前缀；按路径段匹配，/api 不匹配 /apiv2
+	EndpointAttrTagRewriteStrip = "rewritestrip"
+	// Endpoint属性：重写上游路径的正则表达式，与 rewritereplace 配合使用，支持 $1 捕获组引用
+	EndpointAttrTagRewriteRegex = "rewriteregex"
+	// Endpoint属性：正则表达式匹配时的替换内容
+	EndpointAttrTagRewriteReplace = "rewritereplace"
+	// Endpoint属性：重写上游路径时，追加的路径后缀
+	EndpointAttrTagRewriteSuffix = "rewritesuffix"
+)
+
+var (
+	rewriteRegexps = new(sync.Map)
+)
+
+// PathRewrite 上游路径重写规则；按删除前缀，正则替换，追加后缀的顺序重写请求路径
+type PathRewrite struct {
+	Strip   string
+	Regex   *regexp.Regexp
+	Replace string
+	Suffix  string
+}
+
+// Rewrite 重写请求路径；删除前缀时，前缀必须匹配完整的路径段
+func (r PathRewrite) Rewrite(path string) string {
+	if strip := strings.TrimSuffix(r.Strip, "/"); strip != "" {
+		if path == strip {
+			path = "/"
+		} else if strings.HasPrefix(path, strip+"/") {
+			path = path[len(strip):]
+		}
+	}
+	if nil != r.Regex {
+		path = r.Regex.ReplaceAllString(path, r.Replace)
+	}
+	return path + r.Suffix
+}
+
+// EndpointPathRewrite 读取Endpoint定义的上游路径重写规则；未定义任何规则时返回false，正则表达式错误时返回错误
+func EndpointPathRewrite(endpoint *flux.Endpoint) (PathRewrite, bool, error) {
+	rewrite := PathRewrite{
+		Strip:   strings.TrimSpace(endpoint.GetAttr(EndpointAttrTagRewriteStrip).GetString()),
+		Replace: endpoint.GetAttr(EndpointAttrTagRewriteReplace).GetString(),
+		Suffix:  strings.TrimSpace(endpoint.GetAttr(EndpointAttrTagRewriteSuffix).GetString()),
+	}
+	if rewrite.Strip != "" && !strings.HasPrefix(rewrite.Strip, "/") {
+		return rewrite, false, fmt.Errorf("endpoint attribute(%s) must start with '/', was: %s", EndpointAttrTagRewriteStrip, rewrite.Strip)
+	}
+	if expr := strings.TrimSpace(endpoint.GetAttr(EndpointAttrTagRewriteRegex).GetString()); expr != "" {
+		re, err := compileRewriteRegex(expr)
+		if nil != err {
+			return rewrite, false, fmt.Errorf("endpoint attribute(%s) is invalid, regex: %s, err: %w", EndpointAttrTagRewriteRegex, expr, err)
+		}
+		rewrite.Regex = re
+	}
+	return rewrite, rewrite.Strip != "" || nil != rewrite.Regex || rewrite.Suffix != "", nil
+}
+
+// ValidateEndpoint 注册Endpoint时校验上游路径重写规则，规则错误的Endpoint不注册
+func (b *RpcTransporter) ValidateEndpoint(endpoint *flux.Endpoint) error {
+	_, _, err := EndpointPathRewrite(endpoint)
+	return err
+}
+
+func compileRewriteRegex(expr string) (*regexp.Regexp, error) {
+	if v, ok := rewriteRegexps.Load(expr); ok {
+		return v.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if nil != err {
+		return nil, err
+	}
+	rewriteRegexps.Store(expr, re)
+	return re, nil
+}
+
+// upstreamPath 返回上游请求路径：Interface 替换动态路径参数；Endpoint定义重写规则时，拼接按规则重写的请求路径
+func upstreamPath(service *flux.TransporterService, path string, ctx *flux.Context) string {
+	base := expandPathVars(service.Interface, ctx)
+	rewrite, ok, err := EndpointPathRewrite(ctx.Endpoint())
+	if nil != err {
+		// 注册时已校验；未经校验的Endpoint不重写路径
+		logger.LimitedWarnw("TRANSPORTER:HTTP:ILLEGAL_REWRITE", "http-pattern", ctx.Endpoint().HttpPattern, "error", err)
+		return base
+	}
+	if !ok {
+		return base
+	}
+	return joinUpstreamPath(base, rewrite.Rewrite(path))
+}
+
+func joinUpstreamPath(base, path string) string {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
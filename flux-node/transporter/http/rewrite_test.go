@@ -0,0 +1,77 @@
+package http
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+func newRewriteEndpoint(attrs map[string]interface{}) *flux.Endpoint {
+	endpoint := &flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/api/users/:id"}
+	for name, value := range attrs {
+		endpoint.Attributes = append(endpoint.Attributes, flux.Attribute{Name: name, Value: value})
+	}
+	return endpoint
+}
+
+func TestPathRewrite_Rewrite(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		rewrite  PathRewrite
+		path     string
+		expected string
+	}{
+		{PathRewrite{Strip: "/api"}, "/api/users/1", "/users/1"},
+		{PathRewrite{Strip: "/api/"}, "/api/users/1", "/users/1"},
+		{PathRewrite{Strip: "/api"}, "/api", "/"},
+		// 前缀按路径段匹配
+		{PathRewrite{Strip: "/api"}, "/apiv2/users", "/apiv2/users"},
+		{PathRewrite{Regex: regexp.MustCompile(`^/users/(\d+)$`), Replace: "/profiles/$1"}, "/users/7", "/profiles/7"},
+		{PathRewrite{Regex: regexp.MustCompile(`^/users/(\d+)$`), Replace: "/profiles/$1"}, "/orders/7", "/orders/7"},
+		{PathRewrite{Strip: "/api", Regex: regexp.MustCompile(`^/users`), Replace: "/members", Suffix: ".json"}, "/api/users/1", "/members/1.json"},
+	}
+	for _, c := range cases {
+		assert.Equal(c.expected, c.rewrite.Rewrite(c.path), c.path)
+	}
+}
+
+func TestEndpointPathRewrite(t *testing.T) {
+	assert := assert.New(t)
+	_, ok, err := EndpointPathRewrite(newRewriteEndpoint(nil))
+	assert.NoError(err)
+	assert.False(ok)
+	rewrite, ok, err := EndpointPathRewrite(newRewriteEndpoint(map[string]interface{}{
+		EndpointAttrTagRewriteRegex:   `^/users/(\d+)$`,
+		EndpointAttrTagRewriteReplace: "/profiles/$1",
+	}))
+	assert.NoError(err)
+	assert.True(ok)
+	assert.NotNil(rewrite.Regex)
+
+	// 规则错误的Endpoint注册时被拒绝
+	transporter := NewRpcHttpTransporter()
+	assert.Error(transporter.ValidateEndpoint(newRewriteEndpoint(map[string]interface{}{EndpointAttrTagRewriteRegex: `^/users/(\d+$`})))
+	assert.Error(transporter.ValidateEndpoint(newRewriteEndpoint(map[string]interface{}{EndpointAttrTagRewriteStrip: "api"})))
+	assert.NoError(transporter.ValidateEndpoint(newRewriteEndpoint(map[string]interface{}{EndpointAttrTagRewriteStrip: "/api"})))
+}
+
+func TestUpstreamPath(t *testing.T) {
+	assert := assert.New(t)
+	newContext := func(endpoint *flux.Endpoint) *flux.Context {
+		ctx := flux.NewContext()
+		ctx.Reset(common.MockRequestContext("rewrite", httptest.NewRequest(http.MethodGet, "/api/users/7", nil),
+			map[string]string{"id": "7"}, nil), endpoint)
+		return ctx
+	}
+	// 未定义重写规则：使用 Interface 并替换动态路径参数
+	ctx := newContext(newRewriteEndpoint(nil))
+	assert.Equal("/users/7", upstreamPath(&flux.TransporterService{Interface: "/users/{id}"}, "/api/users/7", ctx))
+	// 重写后的路径拼接在 Interface 之后
+	ctx = newContext(newRewriteEndpoint(map[string]interface{}{EndpointAttrTagRewriteStrip: "/api"}))
+	assert.Equal("/v1/users/7", upstreamPath(&flux.TransporterService{Interface: "/v1/"}, "/api/users/7", ctx))
+	assert.Equal("/users/7", upstreamPath(&flux.TransporterService{Interface: "/"}, "/api/users/7", ctx))
+}
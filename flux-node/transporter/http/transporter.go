@@ -3,20 +3,32 @@ package http
 import (
 	"fmt"
 	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
 	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
 	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/bytepowered/flux/flux-pkg"
 	"github.com/spf13/cast"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"time"
 )
 
+const (
+	// 解析上游地址时的IP版本偏好：any，ipv4，ipv6
+	ConfigKeyIPPreference = "ip_preference"
+)
+
 func init() {
-	ext.RegisterTransporter(flux.ProtoHttp, NewRpcHttpTransporter())
+	ext.RegisterTransporterFactory(flux.ProtoHttp, func() interface{} {
+		return NewRpcHttpTransporter()
+	})
 }
 
 var _ flux.Transporter = new(RpcTransporter)
+var _ flux.EndpointValidator = new(RpcTransporter)
 
 type (
 	// Option 配置函数
@@ -30,6 +42,7 @@ type RpcTransporter struct {
 	codec       flux.TransportCodec
 	writer      flux.TransportWriter
 	argResolver ArgumentResolver
+	limiter     *HostLimiter
 }
 
 func (b *RpcTransporter) Writer() flux.TransportWriter {
@@ -80,6 +93,13 @@ func WithArgumentResolver(fun ArgumentResolver) Option {
 	}
 }
 
+// WithHostLimiter 用于配置上游Host的连接数限制
+func WithHostLimiter(limiter *HostLimiter) Option {
+	return func(service *RpcTransporter) {
+		service.limiter = limiter
+	}
+}
+
 // WithTransportWriter 用于配置响应数据解析实现函数
 func WithTransportWriter(fun flux.TransportWriter) Option {
 	return func(service *RpcTransporter) {
@@ -87,6 +107,45 @@ func WithTransportWriter(fun flux.TransportWriter) Option {
 	}
 }
 
+// Init 加载上游Host连接数限制配置
+func (b *RpcTransporter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyMaxConnsPerHost:   0,
+		ConfigKeyMaxPendingPerHost: 0,
+		ConfigKeyOverflowPolicy:    OverflowPolicyQueue,
+		ConfigKeyQueueTimeout:      time.Second,
+	})
+	if fluxpkg.IsNil(b.argResolver) {
+		b.argResolver = DefaultArgumentResolver
+	}
+	// 解析上游地址时的IP版本偏好；自定义HttpClient的Transport时不作修改
+	prefer := config.GetString(ConfigKeyIPPreference)
+	if err := common.ValidateIPPreference(prefer); nil != err {
+		return fmt.Errorf("http transporter config(%s): %w", ConfigKeyIPPreference, err)
+	}
+	if prefer != "" && nil == b.httpClient.Transport {
+		rt := http.DefaultTransport.(*http.Transport).Clone()
+		rt.DialContext = transporter.NewPreferredDialContext(prefer, &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		})
+		b.httpClient.Transport = rt
+		logger.Infow("Http transporter ip preference", "prefer", prefer)
+	}
+	policy := config.GetString(ConfigKeyOverflowPolicy)
+	if policy != OverflowPolicyQueue && policy != OverflowPolicyFail {
+		return fmt.Errorf("unknown http transporter overflow policy: %s", policy)
+	}
+	if nil == b.limiter {
+		if conns := config.GetInt(ConfigKeyMaxConnsPerHost); conns > 0 {
+			b.limiter = NewHostLimiter(conns, config.GetInt(ConfigKeyMaxPendingPerHost), policy, config.GetDuration(ConfigKeyQueueTimeout))
+			logger.Infow("Http transporter host limit", "max-conns", conns,
+				"max-pending", b.limiter.maxPending, "policy", policy, "queue-timeout", b.limiter.timeout)
+		}
+	}
+	return nil
+}
+
 func (b *RpcTransporter) Transport(ctx *flux.Context) {
 	transporter.DoTransport(ctx, b)
 }
@@ -110,9 +169,20 @@ func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.Transporter
 }
 
 func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	// 多实例地址时，按负载均衡策略选择实例
+	service, done, err := transporter.SelectInstance(ctx, service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageHttpAssembleFailed,
+			CauseError: err,
+		}
+	}
 	body, _ := ctx.BodyReader()
 	newRequest, err := b.argResolver(&service, ctx.URL(), body, ctx)
 	if nil != err {
+		done()
 		return nil, &flux.ServeError{
 			StatusCode: flux.StatusServerError,
 			ErrorCode:  flux.ErrorCodeGatewayInternal,
@@ -120,17 +190,44 @@ func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterServi
 			CauseError: err,
 		}
 	}
-	return b.ExecuteRequest(newRequest, service, ctx)
+	resp, serr := b.ExecuteRequest(newRequest, service, ctx)
+	if nil != serr {
+		done()
+		return nil, serr
+	}
+	if r, ok := resp.(*http.Response); ok {
+		r.Body = &releaseBody{ReadCloser: r.Body, release: done}
+		// 会话绑定Cookie随上游响应Header输出，由请求协程写入客户端响应
+		if cookie, ok := transporter.StickyCookie(ctx, service); ok {
+			r.Header.Add(flux.HeaderSetCookie, cookie.String())
+		}
+	} else {
+		done()
+	}
+	return resp, nil
 }
 
 func (b *RpcTransporter) ExecuteRequest(newRequest *http.Request, _ flux.TransporterService, ctx *flux.Context) (interface{}, *flux.ServeError) {
 	// Header透传以及传递AttrValues
-	newRequest.Header = ctx.HeaderVars()
+	newRequest.Header = ctx.HeaderVars().Clone()
 	for k, v := range ctx.Attributes() {
 		newRequest.Header.Set(k, cast.ToString(v))
 	}
+	// 传递请求剩余的超时时间
+	transporter.SetDeadlineHeaders(ctx, newRequest.Header)
+	release, err := b.limiter.Acquire(ctx.Context(), newRequest.URL.Host)
+	if nil != err {
+		logger.ModuleOf(logger.ModuleTransporter, logger.TraceContext(ctx)).Warnw("TRANSPORTER:HTTP:HOST_LIMIT", "host", newRequest.URL.Host, "error", err)
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusUnavailable,
+			ErrorCode:  flux.ErrorCodeGatewayOverloaded,
+			Message:    err.Error(),
+			CauseError: err,
+		}
+	}
 	resp, err := b.httpClient.Do(newRequest)
 	if nil != err {
+		release()
 		msg := flux.ErrorMessageHttpInvokeFailed
 		if uErr, ok := err.(*url.Error); ok {
 			msg = fmt.Sprintf("HTTPEX:REMOTE_ERROR:%s", uErr.Error())
@@ -142,5 +239,6 @@ func (b *RpcTransporter) ExecuteRequest(newRequest *http.Request, _ flux.Transpo
 			CauseError: err,
 		}
 	}
+	resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
 	return resp, nil
 }
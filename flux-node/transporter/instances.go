@@ -0,0 +1,369 @@
+package transporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConfigKeyInstanceRefreshInterval = "refresh_interval"
+	// 查询实例地址失败后，重试查询的最小间隔
+	ConfigKeyInstanceRetryInterval = "retry_interval"
+	ConfigKeyHealthCheckDisabled   = "health_check.disabled"
+	ConfigKeyHealthCheckInterval   = "health_check.interval"
+	ConfigKeyHealthCheckTimeout    = "health_check.timeout"
+	ConfigKeyHealthCheckPath       = "health_check.path"
+	// 同时执行健康检查的最大数量
+	ConfigKeyHealthCheckConcurrency = "health_check.concurrency"
+	ConfigKeyUnhealthyThreshold     = "health_check.unhealthy_threshold"
+	ConfigKeyHealthyThreshold       = "health_check.healthy_threshold"
+	ConfigKeyConsulAddress          = "consul.address"
+	ConfigKeyNacosAddress           = "nacos.address"
+	ConfigKeyNacosNamespace         = "nacos.namespace"
+	ConfigKeyNacosContextPath       = "nacos.context_path"
+	ConfigKeyNacosUsername          = "nacos.username"
+	ConfigKeyNacosPassword          = "nacos.password"
+)
+
+const (
+	HealthCheckResultPass = "pass"
+	HealthCheckResultFail = "fail"
+)
+
+var (
+	_ flux.Initializer = new(InstanceRegistry)
+	_ flux.Startuper   = new(InstanceRegistry)
+	_ flux.Shutdowner  = new(InstanceRegistry)
+)
+
+var (
+	instanceRegistry *InstanceRegistry
+)
+
+// SetInstanceRegistry 设置负载均衡使用的实例注册表；未设置时，使用Service定义的静态实例地址
+func SetInstanceRegistry(registry *InstanceRegistry) {
+	instanceRegistry = registry
+}
+
+// InstanceRegistry 按Service属性 instancesource 定期从注册中心/DNS查询实例地址，
+// 并对多实例Service的全部实例执行主动健康检查；连续失败达到阈值的实例从负载均衡中移除，连续成功达到阈值后恢复。
+// 全部实例均不健康时，不作过滤，避免因健康检查异常导致服务完全不可用。
+// 实例地址只在后台查询：请求时尚未查询到实例地址的Service，触发后台查询并使用Service定义的静态实例地址；
+// 查询失败后在 retry_interval 内不再触发查询。
+type InstanceRegistry struct {
+	refresh     time.Duration
+	retry       time.Duration
+	interval    time.Duration
+	timeout     time.Duration
+	path        string
+	checkOff    bool
+	concurrency int
+	unhealthyAt int
+	healthyAt   int
+	client      *http.Client
+	resolved    sync.Map // serviceId -> []string
+	failures    sync.Map // serviceId -> time.Time，最近一次查询失败的时间
+	pending     sync.Map // serviceId -> bool，正在后台查询
+	states      sync.Map // instance -> *instanceState
+	healthy     flux.GaugeVec
+	checks      flux.CounterVec
+	stop        chan struct{}
+}
+
+type instanceState struct {
+	unhealthy bool
+	fails     int
+	passes    int
+	mu        sync.Mutex
+}
+
+func NewInstanceRegistry(healthy flux.GaugeVec, checks flux.CounterVec) *InstanceRegistry {
+	return &InstanceRegistry{
+		healthy: healthy,
+		checks:  checks,
+		stop:    make(chan struct{}),
+	}
+}
+
+func (r *InstanceRegistry) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyInstanceRefreshInterval: time.Second * 30,
+		ConfigKeyInstanceRetryInterval:   time.Second * 5,
+		ConfigKeyHealthCheckInterval:     time.Second * 10,
+		ConfigKeyHealthCheckTimeout:      time.Second * 2,
+		ConfigKeyHealthCheckConcurrency:  16,
+		ConfigKeyUnhealthyThreshold:      3,
+		ConfigKeyHealthyThreshold:        2,
+		ConfigKeyNacosContextPath:        "/nacos",
+	})
+	r.refresh = config.GetDuration(ConfigKeyInstanceRefreshInterval)
+	r.retry = config.GetDuration(ConfigKeyInstanceRetryInterval)
+	r.interval = config.GetDuration(ConfigKeyHealthCheckInterval)
+	r.timeout = config.GetDuration(ConfigKeyHealthCheckTimeout)
+	r.path = config.GetString(ConfigKeyHealthCheckPath)
+	r.checkOff = config.GetBool(ConfigKeyHealthCheckDisabled)
+	r.concurrency = config.GetInt(ConfigKeyHealthCheckConcurrency)
+	if r.concurrency < 1 {
+		r.concurrency = 1
+	}
+	r.unhealthyAt = config.GetInt(ConfigKeyUnhealthyThreshold)
+	r.healthyAt = config.GetInt(ConfigKeyHealthyThreshold)
+	r.client = &http.Client{Timeout: r.timeout}
+	if addr := config.GetString(ConfigKeyConsulAddress); addr != "" {
+		ext.RegisterInstanceResolver(InstanceResolverConsul, &ConsulResolver{Address: addr, Client: r.client})
+	}
+	if addr := config.GetString(ConfigKeyNacosAddress); addr != "" {
+		resolver, err := NewNacosResolver(addr, config.GetString(ConfigKeyNacosContextPath), constant.ClientConfig{
+			TimeoutMs:           uint64(r.timeout.Milliseconds()),
+			NamespaceId:         config.GetString(ConfigKeyNacosNamespace),
+			Username:            config.GetString(ConfigKeyNacosUsername),
+			Password:            config.GetString(ConfigKeyNacosPassword),
+			NotLoadCacheAtStart: true,
+		})
+		if nil != err {
+			return err
+		}
+		ext.RegisterInstanceResolver(InstanceResolverNacos, resolver)
+	}
+	logger.Infow("Instance registry init", "refresh-interval", r.refresh, "health-check-disabled", r.checkOff,
+		"health-check-interval", r.interval, "health-check-path", r.path, "health-check-concurrency", r.concurrency)
+	return nil
+}
+
+func (r *InstanceRegistry) Startup() error {
+	go r.loop()
+	return nil
+}
+
+func (r *InstanceRegistry) Shutdown(_ context.Context) error {
+	close(r.stop)
+	return nil
+}
+
+// Instances 返回Service当前可用的实例地址列表
+func (r *InstanceRegistry) Instances(service flux.TransporterService) []string {
+	if nil == r {
+		return service.Instances()
+	}
+	instances := service.Instances()
+	if source := service.GetAttr(flux.ServiceAttrTagInstanceSource).GetString(); source != "" {
+		if v, ok := r.resolved.Load(service.ServiceID()); ok {
+			instances = v.([]string)
+		} else {
+			r.resolveAsync(service)
+		}
+	}
+	healthy := make([]string, 0, len(instances))
+	for _, inst := range instances {
+		if v, ok := r.states.Load(inst); !ok || !v.(*instanceState).isUnhealthy() {
+			healthy = append(healthy, inst)
+		}
+	}
+	if len(healthy) == 0 {
+		return instances
+	}
+	return healthy
+}
+
+func (r *InstanceRegistry) loop() {
+	refresh := time.NewTicker(r.refresh)
+	defer refresh.Stop()
+	check := time.NewTicker(r.interval)
+	defer check.Stop()
+	r.refreshAll()
+	for {
+		select {
+		case <-refresh.C:
+			r.refreshAll()
+		case <-check.C:
+			if !r.checkOff {
+				r.checkAll()
+			}
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+func (r *InstanceRegistry) refreshAll() {
+	services := ext.TransporterServices()
+	for _, service := range services {
+		if service.GetAttr(flux.ServiceAttrTagInstanceSource).GetString() != "" {
+			r.resolve(service)
+		}
+	}
+	// 删除已注销Service的查询结果
+	prune := func(key, _ interface{}) bool {
+		if service, ok := services[key.(string)]; !ok || service.GetAttr(flux.ServiceAttrTagInstanceSource).GetString() == "" {
+			r.resolved.Delete(key)
+			r.failures.Delete(key)
+		}
+		return true
+	}
+	r.resolved.Range(prune)
+	r.failures.Range(prune)
+}
+
+// resolveAsync 在后台查询Service的实例地址；正在查询，或者在重试间隔内查询失败过的Service不重复查询
+func (r *InstanceRegistry) resolveAsync(service flux.TransporterService) {
+	id := service.ServiceID()
+	if v, ok := r.failures.Load(id); ok && time.Since(v.(time.Time)) < r.retry {
+		return
+	}
+	if _, running := r.pending.LoadOrStore(id, true); running {
+		return
+	}
+	go func() {
+		defer r.pending.Delete(id)
+		r.resolve(service)
+	}()
+}
+
+// resolve 查询Service的实例地址；查询失败时保留上次的结果，并记录失败时间
+func (r *InstanceRegistry) resolve(service flux.TransporterService) []string {
+	id, source := service.ServiceID(), service.GetAttr(flux.ServiceAttrTagInstanceSource).GetString()
+	instances, err := resolveInstances(source, r.timeout)
+	if nil != err {
+		r.failures.Store(id, time.Now())
+		logger.LimitedWarnw("TRANSPORTER:INSTANCES:RESOLVE/ERROR", "service-id", id, "source", source, "error", err)
+		if v, ok := r.resolved.Load(id); ok {
+			return v.([]string)
+		}
+		return nil
+	}
+	r.failures.Delete(id)
+	r.resolved.Store(id, instances)
+	return instances
+}
+
+type instanceCheck struct {
+	service  flux.TransporterService
+	instance string
+	pass     bool
+}
+
+// checkAll 并行检查全部实例，同时执行的检查数量不超过 concurrency；检查后删除已不存在的实例的健康状态
+func (r *InstanceRegistry) checkAll() {
+	checks := make(map[string]*instanceCheck, 16)
+	owners := make(map[string][]string, 16)
+	for _, service := range ext.TransporterServices() {
+		instances := service.Instances()
+		if service.GetAttr(flux.ServiceAttrTagInstanceSource).GetString() != "" {
+			if v, ok := r.resolved.Load(service.ServiceID()); ok {
+				instances = v.([]string)
+			}
+		} else if len(instances) <= 1 {
+			continue
+		}
+		for _, inst := range instances {
+			if _, ok := checks[inst]; !ok {
+				checks[inst] = &instanceCheck{service: service, instance: inst}
+			}
+			owners[inst] = append(owners[inst], service.ServiceID())
+		}
+	}
+	tokens := make(chan struct{}, r.concurrency)
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		tokens <- struct{}{}
+		go func(c *instanceCheck) {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			c.pass = r.check(c.service, c.instance)
+		}(c)
+	}
+	wg.Wait()
+	for inst, c := range checks {
+		r.observe(owners[inst], inst, c.pass)
+	}
+	r.states.Range(func(key, _ interface{}) bool {
+		if _, ok := checks[key.(string)]; !ok {
+			r.states.Delete(key)
+		}
+		return true
+	})
+}
+
+// check 执行单个实例的健康检查：配置了检查路径的http(s)服务请求此路径，状态码小于500为健康；其它以TCP连接检查
+func (r *InstanceRegistry) check(service flux.TransporterService, instance string) bool {
+	scheme := strings.ToLower(service.Scheme)
+	if r.path != "" && (scheme == "http" || scheme == "https") {
+		resp, err := r.client.Get(scheme + "://" + instance + r.path)
+		if nil != err {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode < http.StatusInternalServerError
+	}
+	conn, err := net.DialTimeout("tcp", instance, r.timeout)
+	if nil != err {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+// observe 按检查结果更新实例的健康状态；多个Service共用的实例只计数一次
+func (r *InstanceRegistry) observe(serviceIds []string, instance string, pass bool) {
+	v, _ := r.states.LoadOrStore(instance, new(instanceState))
+	state := v.(*instanceState)
+	state.mu.Lock()
+	if pass {
+		state.fails, state.passes = 0, state.passes+1
+		if state.unhealthy && state.passes >= r.healthyAt {
+			state.unhealthy = false
+			logger.WithModule(logger.ModuleTransporter).Infow("TRANSPORTER:INSTANCES:HEALTHY", "service-ids", serviceIds, "instance", instance)
+		}
+	} else {
+		state.passes, state.fails = 0, state.fails+1
+		if !state.unhealthy && state.fails >= r.unhealthyAt {
+			state.unhealthy = true
+			logger.WithModule(logger.ModuleTransporter).Warnw("TRANSPORTER:INSTANCES:UNHEALTHY", "service-ids", serviceIds, "instance", instance)
+		}
+	}
+	unhealthy := state.unhealthy
+	state.mu.Unlock()
+	result, value := HealthCheckResultPass, 1.0
+	if !pass {
+		result = HealthCheckResultFail
+	}
+	if unhealthy {
+		value = 0
+	}
+	for _, serviceId := range serviceIds {
+		r.checks.WithLabelValues(serviceId, instance, result).Inc()
+		r.healthy.WithLabelValues(serviceId, instance).Set(value)
+	}
+}
+
+func (s *instanceState) isUnhealthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.unhealthy
+}
+
+func resolveInstances(source string, timeout time.Duration) ([]string, error) {
+	parts := strings.SplitN(source, ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid instance source: %s", source)
+	}
+	resolver, ok := ext.InstanceResolverByName(parts[0])
+	if !ok {
+		return nil, fmt.Errorf("unknown instance resolver: %s", parts[0])
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return resolver.Resolve(ctx, parts[1])
+}
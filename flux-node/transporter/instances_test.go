@@ -0,0 +1,130 @@
+package transporter
+
+import (
+	"context"
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type nopInstanceMetric struct{}
+
+func (nopInstanceMetric) WithLabelValues(_ ...string) flux.Counter { return nopInstanceMetric{} }
+func (nopInstanceMetric) Inc()                                     {}
+func (nopInstanceMetric) Dec()                                     {}
+func (nopInstanceMetric) Add(_ float64)                            {}
+func (nopInstanceMetric) Set(_ float64)                            {}
+
+type nopInstanceGaugeVec struct{}
+
+func (nopInstanceGaugeVec) WithLabelValues(_ ...string) flux.Gauge { return nopInstanceMetric{} }
+
+// countingResolver 记录查询次数的实例地址查询实现
+type countingResolver struct {
+	calls     int32
+	delay     time.Duration
+	instances []string
+	err       error
+}
+
+func (r *countingResolver) Resolve(_ context.Context, _ string) ([]string, error) {
+	atomic.AddInt32(&r.calls, 1)
+	time.Sleep(r.delay)
+	return r.instances, r.err
+}
+
+func newInstanceRegistry(t *testing.T) *InstanceRegistry {
+	registry := NewInstanceRegistry(nopInstanceGaugeVec{}, nopInstanceMetric{})
+	assert.NoError(t, registry.Init(flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyInstanceRetryInterval:  "1h",
+		ConfigKeyHealthCheckTimeout:     "1s",
+		ConfigKeyHealthCheckPath:        "/health",
+		ConfigKeyHealthCheckConcurrency: 4,
+		ConfigKeyUnhealthyThreshold:     1,
+	})))
+	return registry
+}
+
+func newSourceService(id, source, host string) flux.TransporterService {
+	return flux.TransporterService{
+		ServiceId:  id,
+		Scheme:     "http",
+		RemoteHost: host,
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: flux.ServiceAttrTagInstanceSource, Value: source}},
+		},
+	}
+}
+
+func TestInstanceRegistry_ResolveOffRequestPath(t *testing.T) {
+	assert := assert.New(t)
+	resolver := &countingResolver{delay: time.Millisecond * 200, instances: []string{"10.2.0.1:80"}}
+	ext.RegisterInstanceResolver("slowtest", resolver)
+	registry := newInstanceRegistry(t)
+	service := newSourceService("slow.service", "slowtest:users", "10.9.0.1:80")
+	// 尚未查询到实例地址时，不等待查询，使用静态实例地址
+	start := time.Now()
+	assert.Equal([]string{"10.9.0.1:80"}, registry.Instances(service))
+	assert.Equal([]string{"10.9.0.1:80"}, registry.Instances(service))
+	assert.True(time.Since(start) < time.Millisecond*100, "resolve must not run on the request path")
+	assert.Eventually(func() bool {
+		instances := registry.Instances(service)
+		return len(instances) == 1 && instances[0] == "10.2.0.1:80"
+	}, time.Second, time.Millisecond*10)
+	assert.Equal(int32(1), atomic.LoadInt32(&resolver.calls))
+}
+
+func TestInstanceRegistry_NegativeCache(t *testing.T) {
+	assert := assert.New(t)
+	resolver := &countingResolver{err: errors.New("registry unavailable")}
+	ext.RegisterInstanceResolver("failtest", resolver)
+	registry := newInstanceRegistry(t)
+	service := newSourceService("fail.service", "failtest:users", "10.9.0.2:80")
+	registry.Instances(service)
+	assert.Eventually(func() bool {
+		_, running := registry.pending.Load(service.ServiceID())
+		return !running
+	}, time.Second, time.Millisecond*10)
+	// 查询失败后，在重试间隔内不再查询
+	for i := 0; i < 10; i++ {
+		assert.Equal([]string{"10.9.0.2:80"}, registry.Instances(service))
+	}
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(int32(1), atomic.LoadInt32(&resolver.calls))
+}
+
+func TestInstanceRegistry_CheckAll(t *testing.T) {
+	assert := assert.New(t)
+	slow := func(status int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			time.Sleep(time.Millisecond * 200)
+			w.WriteHeader(status)
+		}))
+	}
+	servers := []*httptest.Server{slow(http.StatusOK), slow(http.StatusOK), slow(http.StatusServiceUnavailable)}
+	hosts := make([]string, 0, len(servers))
+	for _, server := range servers {
+		defer server.Close()
+		hosts = append(hosts, strings.TrimPrefix(server.URL, "http://"))
+	}
+	service := flux.TransporterService{ServiceId: "check.service", Scheme: "http", RemoteHost: strings.Join(hosts, ",")}
+	ext.RegisterTransporterServiceById(service.ServiceID(), service)
+	registry := newInstanceRegistry(t)
+	registry.states.Store("10.9.9.9:80", new(instanceState))
+
+	// 并行执行健康检查
+	start := time.Now()
+	registry.checkAll()
+	assert.True(time.Since(start) < time.Millisecond*500, "health checks must run in parallel")
+	assert.Equal(hosts[:2], registry.Instances(service))
+	// 已不存在的实例的健康状态被删除
+	_, ok := registry.states.Load("10.9.9.9:80")
+	assert.False(ok)
+}
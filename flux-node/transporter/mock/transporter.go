@@ -0,0 +1,243 @@
+package mock
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/spf13/cast"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// 模拟延迟的上限，避免配置错误导致请求长时间挂起
+	ConfigKeyMaxLatency = "max_latency"
+)
+
+const (
+	// Service属性：响应状态码，默认200
+	ServiceAttrTagMockStatus = "mockstatus"
+	// Service属性：响应数据；字符串原样输出，其它类型输出为JSON
+	ServiceAttrTagMockBody = "mockbody"
+	// Service属性：响应Header；Map，或 Name: value 格式的字符串列表
+	ServiceAttrTagMockHeaders = "mockheaders"
+	// Service属性：模拟延迟；固定值（例如 200ms），或范围（例如 100ms-500ms）
+	ServiceAttrTagMockLatency = "mocklatency"
+	// Service属性：模拟失败的比例，0 - 1
+	ServiceAttrTagMockFailRate = "mockfailrate"
+	// Service属性：模拟失败的响应状态码，默认503
+	ServiceAttrTagMockFailStatus = "mockfailstatus"
+)
+
+const (
+	ErrorCodeMockInjectedFailure = "MOCK:INJECTED_FAILURE"
+	ErrorCodeMockSpecInvalid     = "MOCK:SPEC_INVALID"
+)
+
+func init() {
+	ext.RegisterTransporterFactory(flux.ProtoMock, func() interface{} {
+		return NewTransporter()
+	})
+}
+
+var (
+	_ flux.Transporter      = new(RpcTransporter)
+	_ flux.ServiceValidator = new(RpcTransporter)
+)
+
+// RpcTransporter 静态模拟响应网关服务：按Service属性返回配置的状态码，Header及响应数据，
+// 支持注入延迟和按比例失败；用于后端服务就绪之前的前端联调。
+type RpcTransporter struct {
+	maxLatency time.Duration
+	writer     flux.TransportWriter
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		maxLatency: time.Second * 10,
+		writer:     new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Init(config *flux.Configuration) error {
+	config.SetDefault(ConfigKeyMaxLatency, time.Second*10)
+	b.maxLatency = config.GetDuration(ConfigKeyMaxLatency)
+	logger.Infow("Mock transporter init", "max-latency", b.maxLatency)
+	return nil
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	transporter.DoTransport(ctx, b)
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	value, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	header, err := ParseHeaders(service.GetAttr(ServiceAttrTagMockHeaders).Value)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodeMockSpecInvalid,
+			Message:    "MOCK:HEADERS:INVALID",
+			CauseError: err,
+		}
+	}
+	status, err := ParseStatus(service, ServiceAttrTagMockStatus, flux.StatusOK)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodeMockSpecInvalid,
+			Message:    "MOCK:STATUS:INVALID",
+			CauseError: err,
+		}
+	}
+	return &flux.ResponseBody{
+		StatusCode: status,
+		Headers:    header,
+		Body:       value,
+	}, nil
+}
+
+// Invoke 按配置模拟延迟和失败，返回配置的响应数据
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	min, max, err := ParseLatency(service.GetAttr(ServiceAttrTagMockLatency).GetString())
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodeMockSpecInvalid,
+			Message:    "MOCK:LATENCY:INVALID",
+			CauseError: err,
+		}
+	}
+	if latency := b.latency(min, max); latency > 0 {
+		timer := time.NewTimer(latency)
+		select {
+		case <-timer.C:
+		case <-ctx.Context().Done():
+			timer.Stop()
+			return nil, flux.NewTimeoutServeError(ctx.Context().Err())
+		}
+	}
+	if rate := cast.ToFloat64(service.GetAttr(ServiceAttrTagMockFailRate).Value); rate > 0 && rand.Float64() < rate {
+		status, err := ParseStatus(service, ServiceAttrTagMockFailStatus, flux.StatusUnavailable)
+		if nil != err {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  ErrorCodeMockSpecInvalid,
+				Message:    "MOCK:STATUS:INVALID",
+				CauseError: err,
+			}
+		}
+		return nil, &flux.ServeError{
+			StatusCode: status,
+			ErrorCode:  ErrorCodeMockInjectedFailure,
+			Message:    "MOCK:INJECTED:FAILURE",
+		}
+	}
+	return common.ToJSONValue(service.GetAttr(ServiceAttrTagMockBody).Value), nil
+}
+
+// ValidateService 注册后端服务时校验模拟响应的配置
+func (b *RpcTransporter) ValidateService(service flux.TransporterService) error {
+	if _, _, err := ParseLatency(service.GetAttr(ServiceAttrTagMockLatency).GetString()); nil != err {
+		return err
+	}
+	if _, err := ParseHeaders(service.GetAttr(ServiceAttrTagMockHeaders).Value); nil != err {
+		return err
+	}
+	if _, err := ParseStatus(service, ServiceAttrTagMockStatus, flux.StatusOK); nil != err {
+		return err
+	}
+	if _, err := ParseStatus(service, ServiceAttrTagMockFailStatus, flux.StatusUnavailable); nil != err {
+		return err
+	}
+	if attr, ok := service.GetAttrEx(ServiceAttrTagMockFailRate); ok {
+		rate, err := cast.ToFloat64E(attr.Value)
+		if nil != err || rate < 0 || rate > 1 {
+			return fmt.Errorf("invalid mock fail rate: %v, requires 0 - 1", attr.Value)
+		}
+	}
+	return nil
+}
+
+// ParseStatus 解析响应状态码属性；未配置时返回默认值，状态码必须在 100 - 599 之间
+func ParseStatus(service flux.TransporterService, name string, defaults int) (int, error) {
+	attr, ok := service.GetAttrEx(name)
+	if !ok {
+		return defaults, nil
+	}
+	status, err := cast.ToIntE(attr.Value)
+	if nil != err || status < 100 || status > 599 {
+		return 0, fmt.Errorf("invalid mock status: %v, attr: %s", attr.Value, name)
+	}
+	return status, nil
+}
+
+func (b *RpcTransporter) latency(min, max time.Duration) time.Duration {
+	latency := min
+	if max > min {
+		latency += time.Duration(rand.Int63n(int64(max - min)))
+	}
+	if latency > b.maxLatency {
+		latency = b.maxLatency
+	}
+	return latency
+}
+
+// ParseLatency 解析模拟延迟配置：固定值（例如 200ms），或范围（例如 100ms-500ms）
+func ParseLatency(expr string) (min, max time.Duration, err error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return 0, 0, nil
+	}
+	parts := strings.SplitN(expr, "-", 2)
+	if min, err = time.ParseDuration(strings.TrimSpace(parts[0])); nil != err {
+		return 0, 0, fmt.Errorf("invalid mock latency: %s, err: %w", expr, err)
+	}
+	max = min
+	if len(parts) == 2 {
+		if max, err = time.ParseDuration(strings.TrimSpace(parts[1])); nil != err {
+			return 0, 0, fmt.Errorf("invalid mock latency: %s, err: %w", expr, err)
+		}
+	}
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("invalid mock latency range: %s", expr)
+	}
+	return min, max, nil
+}
+
+// ParseHeaders 解析响应Header配置：Map，或 Name: value 格式的字符串列表
+func ParseHeaders(value interface{}) (http.Header, error) {
+	header := make(http.Header, 2)
+	switch v := value.(type) {
+	case nil:
+	case []interface{}, []string:
+		for _, line := range cast.ToStringSlice(v) {
+			kv := strings.SplitN(line, ":", 2)
+			if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+				return nil, fmt.Errorf("invalid mock header: %s", line)
+			}
+			header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	default:
+		values, err := cast.ToStringMapStringE(v)
+		if nil != err {
+			return nil, fmt.Errorf("invalid mock headers: %v", v)
+		}
+		for name, value := range values {
+			header.Set(name, value)
+		}
+	}
+	return header, nil
+}
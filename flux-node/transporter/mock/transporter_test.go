@@ -0,0 +1,116 @@
+package mock
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newMockService(attrs ...flux.Attribute) flux.TransporterService {
+	return flux.TransporterService{
+		ServiceId:          "mock.users",
+		EmbeddedAttributes: flux.EmbeddedAttributes{Attributes: attrs},
+	}
+}
+
+func newMockContext() *flux.Context {
+	request := httptest.NewRequest(http.MethodGet, "http://gateway/users", nil)
+	ctx := flux.NewContext()
+	ctx.Reset(internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "mock", nil), &flux.Endpoint{})
+	return ctx
+}
+
+func TestParseLatency(t *testing.T) {
+	assert := assert.New(t)
+	min, max, err := ParseLatency("")
+	assert.NoError(err)
+	assert.Equal(time.Duration(0), min+max)
+	min, max, err = ParseLatency("200ms")
+	assert.NoError(err)
+	assert.Equal(time.Millisecond*200, min)
+	assert.Equal(time.Millisecond*200, max)
+	min, max, err = ParseLatency("100ms - 1s")
+	assert.NoError(err)
+	assert.Equal(time.Millisecond*100, min)
+	assert.Equal(time.Second, max)
+	for _, expr := range []string{"x", "1s-x", "1s-100ms"} {
+		_, _, err := ParseLatency(expr)
+		assert.Error(err, expr)
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	assert := assert.New(t)
+	header, err := ParseHeaders([]interface{}{"X-Mock: true", "Set-Cookie: a=1", "Set-Cookie: b=2"})
+	assert.NoError(err)
+	assert.Equal("true", header.Get("X-Mock"))
+	assert.Equal([]string{"a=1", "b=2"}, header.Values("Set-Cookie"))
+	header, err = ParseHeaders(map[interface{}]interface{}{"Content-Type": "text/plain"})
+	assert.NoError(err)
+	assert.Equal("text/plain", header.Get("Content-Type"))
+	_, err = ParseHeaders([]string{"X-Mock"})
+	assert.Error(err)
+}
+
+func TestRpcTransporter_ValidateService(t *testing.T) {
+	mock := NewTransporter().(*RpcTransporter)
+	assert.NoError(t, mock.ValidateService(newMockService(
+		flux.Attribute{Name: ServiceAttrTagMockStatus, Value: 201},
+		flux.Attribute{Name: ServiceAttrTagMockFailStatus, Value: "502"},
+		flux.Attribute{Name: ServiceAttrTagMockFailRate, Value: 0.5},
+		flux.Attribute{Name: ServiceAttrTagMockLatency, Value: "10ms-20ms"},
+	)))
+	for _, attr := range []flux.Attribute{
+		{Name: ServiceAttrTagMockStatus, Value: 0},
+		{Name: ServiceAttrTagMockStatus, Value: 600},
+		{Name: ServiceAttrTagMockStatus, Value: "ok"},
+		{Name: ServiceAttrTagMockFailStatus, Value: 99},
+		{Name: ServiceAttrTagMockFailRate, Value: 1.5},
+		{Name: ServiceAttrTagMockLatency, Value: "1s-10ms"},
+		{Name: ServiceAttrTagMockHeaders, Value: []string{"X-Mock"}},
+	} {
+		assert.Error(t, mock.ValidateService(newMockService(attr)), "attr: %+v", attr)
+	}
+}
+
+func TestRpcTransporter_InvokeCodec(t *testing.T) {
+	assert := assert.New(t)
+	mock := NewTransporter().(*RpcTransporter)
+	response, serr := mock.InvokeCodec(newMockContext(), newMockService(
+		flux.Attribute{Name: ServiceAttrTagMockStatus, Value: 201},
+		flux.Attribute{Name: ServiceAttrTagMockHeaders, Value: map[string]interface{}{"X-Mock": "true"}},
+		flux.Attribute{Name: ServiceAttrTagMockBody, Value: map[interface{}]interface{}{"id": 1, "tags": []interface{}{map[interface{}]interface{}{"name": "a"}}}},
+	))
+	assert.Nil(serr)
+	assert.Equal(201, response.StatusCode)
+	assert.Equal("true", response.Headers.Get("X-Mock"))
+	assert.Equal(map[string]interface{}{"id": 1, "tags": []interface{}{map[string]interface{}{"name": "a"}}}, response.Body)
+	// 注入失败
+	_, serr = mock.InvokeCodec(newMockContext(), newMockService(
+		flux.Attribute{Name: ServiceAttrTagMockFailRate, Value: 1},
+		flux.Attribute{Name: ServiceAttrTagMockFailStatus, Value: 502},
+	))
+	assert.NotNil(serr)
+	assert.Equal(502, serr.StatusCode)
+	assert.Equal(ErrorCodeMockInjectedFailure, serr.ErrorCode)
+	// 无效的状态码
+	_, serr = mock.InvokeCodec(newMockContext(), newMockService(flux.Attribute{Name: ServiceAttrTagMockStatus, Value: 1000}))
+	assert.NotNil(serr)
+	assert.Equal(ErrorCodeMockSpecInvalid, serr.ErrorCode)
+}
+
+func TestRpcTransporter_InvokeCanceled(t *testing.T) {
+	mock := NewTransporter().(*RpcTransporter)
+	ctx := newMockContext()
+	cancel := ctx.WithTimeout(time.Millisecond * 10)
+	defer cancel()
+	start := time.Now()
+	_, serr := mock.Invoke(ctx, newMockService(flux.Attribute{Name: ServiceAttrTagMockLatency, Value: "5s"}))
+	assert.NotNil(t, serr)
+	assert.True(t, time.Since(start) < time.Second)
+}
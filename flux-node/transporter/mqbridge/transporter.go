@@ -0,0 +1,253 @@
+package mqbridge
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/bytepowered/flux/flux-pkg"
+	"github.com/spf13/cast"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// 发送消息的超时时间
+	ConfigKeyPublishTimeout = "publish_timeout"
+	// 默认复制为消息Header的请求Header列表
+	ConfigKeyHeaders = "headers"
+)
+
+const (
+	// Service属性：消息Key的查找表达式（scope:name，例如 header:X-Order-Id），或固定值；
+	// RocketMQ 为消息Keys，AMQP 为 RoutingKey
+	ServiceAttrTagMessageKey = "mqkey"
+	// Service属性：RocketMQ 消息Tag
+	ServiceAttrTagMessageTag = "mqtag"
+	// Service属性：复制为消息Header的请求Header列表；覆盖全局配置
+	ServiceAttrTagMessageHeaders = "mqheaders"
+)
+
+const (
+	ErrorCodeMessagePublisherUnavailable = "MQBRIDGE:PUBLISHER_UNAVAILABLE"
+	ErrorCodeMessagePublishFailed        = "MQBRIDGE:PUBLISH_FAILED"
+	ErrorCodeMessageBuildFailed          = "MQBRIDGE:BUILD_FAILED"
+)
+
+var (
+	_ flux.Transporter = new(MessageBridgeTransporter)
+)
+
+var (
+	rocketmq = NewMessageBridgeTransporter(flux.ProtoRocketMQ)
+	amqp     = NewMessageBridgeTransporter(flux.ProtoAMQP)
+)
+
+func init() {
+	ext.RegisterTransporter(flux.ProtoRocketMQ, rocketmq)
+	ext.RegisterTransporter(flux.ProtoAMQP, amqp)
+}
+
+// SetRocketMQPublisher 设置RocketMQ的消息发送客户端
+func SetRocketMQPublisher(publisher MessagePublisher) {
+	rocketmq.SetPublisher(publisher)
+}
+
+// SetAMQPPublisher 设置AMQP（RabbitMQ）的消息发送客户端
+func SetAMQPPublisher(publisher MessagePublisher) {
+	amqp.SetPublisher(publisher)
+}
+
+// Message 由网关请求转换的消息
+type Message struct {
+	// RocketMQ 为Topic；AMQP 为Exchange
+	Destination string
+	// RocketMQ 为消息Keys；AMQP 为RoutingKey
+	Key string
+	// RocketMQ 消息Tag；AMQP 不使用
+	Tag     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// MessagePublisher 发送消息的客户端接口；由使用方基于具体的RocketMQ，AMQP客户端实现，返回消息ID
+type MessagePublisher interface {
+	Publish(ctx context.Context, message *Message) (string, error)
+}
+
+// MessageBridgeTransporter 消息桥接网关服务：将请求转换为消息发送到消息队列，返回 202 Accepted，不等待消费结果；
+// Service的 Interface 为 Topic/Exchange；定义了参数时，消息内容为参数组成的JSON对象，否则为原始请求Body。
+type MessageBridgeTransporter struct {
+	proto     string
+	timeout   time.Duration
+	headers   []string
+	publisher MessagePublisher
+	writer    flux.TransportWriter
+	mu        sync.RWMutex
+}
+
+func NewMessageBridgeTransporter(proto string) *MessageBridgeTransporter {
+	return &MessageBridgeTransporter{
+		proto:   proto,
+		timeout: time.Second * 3,
+		writer:  new(transporter.DefaultTransportWriter),
+	}
+}
+
+// SetPublisher 设置消息发送客户端
+func (b *MessageBridgeTransporter) SetPublisher(publisher MessagePublisher) {
+	b.mu.Lock()
+	b.publisher = publisher
+	b.mu.Unlock()
+}
+
+func (b *MessageBridgeTransporter) Publisher() MessagePublisher {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.publisher
+}
+
+func (b *MessageBridgeTransporter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyPublishTimeout: time.Second * 3,
+		ConfigKeyHeaders:        []string{flux.HeaderXRequestId},
+	})
+	b.timeout = config.GetDuration(ConfigKeyPublishTimeout)
+	b.headers = config.GetStringSlice(ConfigKeyHeaders)
+	if nil == b.Publisher() {
+		logger.Warnw("MessageBridge transporter has no publisher", "proto", b.proto)
+	}
+	logger.Infow("MessageBridge transporter init", "proto", b.proto, "publish-timeout", b.timeout, "headers", b.headers)
+	return nil
+}
+
+func (b *MessageBridgeTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *MessageBridgeTransporter) Transport(ctx *flux.Context) {
+	transporter.DoTransport(ctx, b)
+}
+
+func (b *MessageBridgeTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	value, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return &flux.ResponseBody{
+		StatusCode: http.StatusAccepted,
+		Headers:    make(http.Header, 0),
+		Body: map[string]interface{}{
+			"messageId":   value,
+			"destination": service.Interface,
+		},
+	}, nil
+}
+
+// Invoke 发送消息，返回消息ID
+func (b *MessageBridgeTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	publisher := b.Publisher()
+	if nil == publisher {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusUnavailable,
+			ErrorCode:  ErrorCodeMessagePublisherUnavailable,
+			Message:    "MQBRIDGE:PUBLISHER:UNAVAILABLE",
+			CauseError: fmt.Errorf("no publisher of proto: %s", b.proto),
+		}
+	}
+	message, err := b.NewMessage(ctx, service)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  ErrorCodeMessageBuildFailed,
+			Message:    "MQBRIDGE:MESSAGE:BUILD",
+			CauseError: err,
+		}
+	}
+	toctx, cancel := context.WithTimeout(ctx.Context(), b.timeout)
+	defer cancel()
+	id, err := publisher.Publish(toctx, message)
+	if nil != err {
+		logger.TraceContext(ctx).Warnw("TRANSPORTER:MQBRIDGE:PUBLISH/ERROR", "proto", b.proto,
+			"destination", message.Destination, "key", message.Key, "error", err)
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  ErrorCodeMessagePublishFailed,
+			Message:    "MQBRIDGE:PUBLISH:FAILED",
+			CauseError: err,
+		}
+	}
+	return id, nil
+}
+
+// NewMessage 按Service定义将请求转换为消息
+func (b *MessageBridgeTransporter) NewMessage(ctx *flux.Context, service flux.TransporterService) (*Message, error) {
+	if service.Interface == "" {
+		return nil, fmt.Errorf("service(interface) is required as %s destination, service-id: %s", b.proto, service.ServiceID())
+	}
+	message := &Message{
+		Destination: service.Interface,
+		Tag:         service.GetAttr(ServiceAttrTagMessageTag).GetString(),
+		Headers:     make(map[string]string, 4),
+	}
+	if expr := service.GetAttr(ServiceAttrTagMessageKey).GetString(); expr != "" {
+		key, err := lookupMessageKey(expr, ctx)
+		if nil != err {
+			return nil, err
+		}
+		message.Key = key
+	}
+	headers := b.headers
+	if attr, ok := service.GetAttrEx(ServiceAttrTagMessageHeaders); ok {
+		headers = attr.GetStringSlice()
+	}
+	for _, name := range headers {
+		if value := ctx.HeaderVar(name); value != "" {
+			message.Headers[name] = value
+		}
+	}
+	body, err := messageBody(ctx, service)
+	if nil != err {
+		return nil, err
+	}
+	message.Body = body
+	return message, nil
+}
+
+// lookupMessageKey 按 scope:name 表达式从请求中查找消息Key；非表达式时为固定值
+func lookupMessageKey(expr string, ctx *flux.Context) (string, error) {
+	if _, _, ok := fluxpkg.LookupParseExpr(expr); !ok {
+		return expr, nil
+	}
+	value, err := common.LookupMTValueByExpr(expr, ctx)
+	if nil != err {
+		return "", fmt.Errorf("lookup message key, expr: %s, err: %w", expr, err)
+	}
+	return strings.TrimSpace(cast.ToString(value)), nil
+}
+
+func messageBody(ctx *flux.Context, service flux.TransporterService) ([]byte, error) {
+	if len(service.Arguments) == 0 {
+		reader, err := ctx.BodyReader()
+		if nil != err {
+			return nil, err
+		}
+		defer reader.Close()
+		return ioutil.ReadAll(reader)
+	}
+	values := make(map[string]interface{}, len(service.Arguments))
+	for _, arg := range service.Arguments {
+		value, err := arg.Resolve(ctx)
+		if nil != err {
+			return nil, err
+		}
+		values[arg.Name] = value
+	}
+	return ext.JSONMarshal(values)
+}
@@ -0,0 +1,248 @@
+package pipeline
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/spf13/cast"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// 单个步骤调用后端服务的默认超时时间
+	ConfigKeyStepTimeout = "step_timeout"
+)
+
+const (
+	// Service属性：编排步骤列表；每项为包含 name，service，args，timeout 字段的对象；
+	// args 为参数名到取值表达式的映射：以 $ 开头的JSONPath表达式从已执行步骤的输出中提取，其它为常量值；
+	// JSONPath支持成员，数组下标及通配符，例如 $.user.data.id，$.orders[0].id，$.orders[*].id；提取的数据不存在时中止执行
+	ServiceAttrTagPipelineSteps = "pipeline"
+	// Service属性：响应数据的取值表达式，例如 $.order；默认为最后一个步骤的输出
+	ServiceAttrTagPipelineOutput = "pipelineoutput"
+)
+
+const (
+	ErrorCodePipelineSpecInvalid = "PIPELINE:SPEC_INVALID"
+	ErrorCodePipelineStepFailed  = "PIPELINE:STEP_FAILED"
+	ErrorCodePipelineValueAbsent = "PIPELINE:VALUE_ABSENT"
+)
+
+func init() {
+	ext.RegisterTransporterFactory(flux.ProtoPipeline, func() interface{} {
+		return NewTransporter()
+	})
+}
+
+var (
+	_ flux.Transporter = new(RpcTransporter)
+)
+
+// Step 编排步骤
+type Step struct {
+	Name      string
+	ServiceId string
+	// 参数名到取值表达式的映射
+	Args    map[string]string
+	Timeout time.Duration
+}
+
+// RpcTransporter 顺序编排网关服务：按步骤依次调用后端服务，前序步骤的输出按表达式提取后作为后续步骤的参数；
+// 任一步骤失败时中止执行，返回该步骤的错误。未通过表达式指定的参数，仍按参数定义从请求中解析。
+type RpcTransporter struct {
+	timeout time.Duration
+	writer  flux.TransportWriter
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		timeout: time.Second * 3,
+		writer:  new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Init(config *flux.Configuration) error {
+	config.SetDefault(ConfigKeyStepTimeout, time.Second*3)
+	b.timeout = config.GetDuration(ConfigKeyStepTimeout)
+	logger.Infow("Pipeline transporter init", "step-timeout", b.timeout)
+	return nil
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	transporter.DoTransport(ctx, b)
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	value, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return &flux.ResponseBody{
+		StatusCode: flux.StatusOK,
+		Headers:    make(http.Header, 0),
+		Body:       value,
+	}, nil
+}
+
+// Invoke 按顺序执行编排步骤，返回按输出表达式提取的响应数据
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	steps, err := ParseSteps(service.GetAttr(ServiceAttrTagPipelineSteps).Value, b.timeout)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodePipelineSpecInvalid,
+			Message:    "PIPELINE:SPEC:INVALID",
+			CauseError: err,
+		}
+	}
+	outputs := make(map[string]interface{}, len(steps))
+	var last interface{}
+	for _, step := range steps {
+		target, ok := ext.TransporterServiceById(step.ServiceId)
+		if !ok {
+			return nil, stepError(step, &flux.ServeError{
+				StatusCode: flux.StatusBadGateway,
+				ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+				Message:    "PIPELINE:SERVICE:NOT_FOUND",
+				CauseError: fmt.Errorf("service not found, id: %s", step.ServiceId),
+			})
+		}
+		values := make(map[string]interface{}, len(step.Args))
+		for name, expr := range step.Args {
+			value, err := evaluate(expr, outputs)
+			if nil != err {
+				logger.TraceContext(ctx).Infow("TRANSPORTER:PIPELINE:STEP/ARGUMENT", "step", step.Name,
+					"argument", name, "error", err)
+				return nil, stepError(step, valueAbsentError(err))
+			}
+			values[name] = value
+		}
+		target.Arguments = transporter.BindArgumentValues(target.Arguments, values)
+		value, serr := transporter.InvokeService(ctx, target, step.Timeout)
+		if nil != serr {
+			logger.TraceContext(ctx).Infow("TRANSPORTER:PIPELINE:STEP/ERROR", "step", step.Name,
+				"service-id", step.ServiceId, "error", serr)
+			return nil, stepError(step, serr)
+		}
+		outputs[step.Name] = value
+		last = value
+	}
+	if expr := service.GetAttr(ServiceAttrTagPipelineOutput).GetString(); expr != "" {
+		value, err := evaluate(expr, outputs)
+		if nil != err {
+			return nil, valueAbsentError(err)
+		}
+		return value, nil
+	}
+	return last, nil
+}
+
+func valueAbsentError(err error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusBadGateway,
+		ErrorCode:  ErrorCodePipelineValueAbsent,
+		Message:    "PIPELINE:VALUE:ABSENT",
+		CauseError: err,
+	}
+}
+
+// stepError 步骤失败时，保留后端服务的错误状态码及错误码，并标记失败的步骤
+func stepError(step Step, serr *flux.ServeError) *flux.ServeError {
+	out := &flux.ServeError{
+		StatusCode: serr.StatusCode,
+		ErrorCode:  serr.ErrorCode,
+		Message:    serr.Message,
+		CauseError: serr,
+	}
+	if out.GetErrorCode() == "" {
+		out.ErrorCode = ErrorCodePipelineStepFailed
+	}
+	out.SetExtra("step", step.Name)
+	return out
+}
+
+// evaluate 计算取值表达式：以 $ 开头时从步骤输出中提取，否则为常量值；提取的数据不存在时返回错误
+func evaluate(expr string, outputs map[string]interface{}) (interface{}, error) {
+	if !strings.HasPrefix(expr, "$") {
+		return expr, nil
+	}
+	value, ok, err := common.LookupPath(outputs, expr)
+	if nil != err {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("value not found by expression: %s", expr)
+	}
+	return value, nil
+}
+
+// ParseSteps 解析编排步骤
+func ParseSteps(spec interface{}, timeout time.Duration) ([]Step, error) {
+	items := cast.ToSlice(spec)
+	if len(items) == 0 {
+		return nil, fmt.Errorf("service attribute(%s) is required", ServiceAttrTagPipelineSteps)
+	}
+	out := make([]Step, 0, len(items))
+	names := make(map[string]bool, len(items))
+	for i, item := range items {
+		fields, err := cast.ToStringMapE(item)
+		if nil != err {
+			return nil, fmt.Errorf("invalid pipeline step: %v", item)
+		}
+		step := Step{
+			Name:      cast.ToString(fields["name"]),
+			ServiceId: cast.ToString(fields["service"]),
+			Args:      cast.ToStringMapString(fields["args"]),
+			Timeout:   timeout,
+		}
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step%d", i)
+		}
+		if step.ServiceId == "" {
+			return nil, fmt.Errorf("pipeline step requires service: %s", step.Name)
+		}
+		if names[step.Name] {
+			return nil, fmt.Errorf("duplicated pipeline step: %s", step.Name)
+		}
+		if v, ok := fields["timeout"]; ok {
+			if step.Timeout, err = cast.ToDurationE(v); nil != err {
+				return nil, fmt.Errorf("invalid pipeline step timeout: %v, step: %s", v, step.Name)
+			}
+		}
+		for name, expr := range step.Args {
+			if err := checkExpression(expr, names); nil != err {
+				return nil, fmt.Errorf("invalid pipeline step: %s, arg: %s, err: %w", step.Name, name, err)
+			}
+		}
+		names[step.Name] = true
+		out = append(out, step)
+	}
+	return out, nil
+}
+
+// checkExpression 检查表达式的语法，并且只引用已执行的步骤
+func checkExpression(expr string, executed map[string]bool) error {
+	if !strings.HasPrefix(expr, "$") {
+		return nil
+	}
+	if _, _, err := common.SelectPath(nil, expr); nil != err {
+		return err
+	}
+	ref := strings.TrimPrefix(strings.TrimPrefix(expr, "$"), ".")
+	if idx := strings.IndexAny(ref, ".["); idx >= 0 {
+		ref = ref[:idx]
+	}
+	if !executed[ref] {
+		return fmt.Errorf("expression %s references unknown or later step: %s", expr, ref)
+	}
+	return nil
+}
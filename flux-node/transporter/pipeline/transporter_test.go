@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const protoPipelineTest = "PIPELINE_TEST"
+
+// stepTransporter 按服务ID返回固定响应数据的Transporter，并记录调用的服务
+type stepTransporter struct {
+	responses map[string]interface{}
+	invoked   []string
+}
+
+func (t *stepTransporter) Transport(_ *flux.Context) {}
+
+func (t *stepTransporter) Writer() flux.TransportWriter {
+	return nil
+}
+
+func (t *stepTransporter) Invoke(_ *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	t.invoked = append(t.invoked, service.ServiceId)
+	return t.responses[service.ServiceId], nil
+}
+
+func (t *stepTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	value, serr := t.Invoke(ctx, service)
+	return &flux.ResponseBody{StatusCode: flux.StatusOK, Headers: make(http.Header), Body: value}, serr
+}
+
+func newPipelineService(steps []interface{}, output string) flux.TransporterService {
+	service := flux.TransporterService{ServiceId: "pipeline"}
+	service.Attributes = append(service.Attributes, flux.Attribute{Name: ServiceAttrTagPipelineSteps, Value: steps})
+	if output != "" {
+		service.Attributes = append(service.Attributes, flux.Attribute{Name: ServiceAttrTagPipelineOutput, Value: output})
+	}
+	return service
+}
+
+func newPipelineContext() *flux.Context {
+	request := httptest.NewRequest(http.MethodGet, "http://gateway/orders", nil)
+	// 模拟WebListener缓存的请求Body
+	request.GetBody = func() (io.ReadCloser, error) {
+		return http.NoBody, nil
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("pipeline", request, nil, nil),
+		&flux.Endpoint{HttpMethod: http.MethodGet, HttpPattern: "/orders"})
+	return ctx
+}
+
+func TestEvaluate(t *testing.T) {
+	assert := assert.New(t)
+	outputs := map[string]interface{}{
+		"user":   map[string]interface{}{"data": map[string]interface{}{"id": "u1"}},
+		"orders": []interface{}{map[string]interface{}{"id": "o1"}, map[string]interface{}{"id": "o2"}},
+	}
+	cases := map[string]interface{}{
+		"constant":          "constant",
+		"$.user.data.id":    "u1",
+		"$.orders[1].id":    "o2",
+		"$.orders[*].id":    []interface{}{"o1", "o2"},
+		"$['user'].data":    map[string]interface{}{"id": "u1"},
+		"$.orders[0]['id']": "o1",
+	}
+	for expr, expected := range cases {
+		value, err := evaluate(expr, outputs)
+		assert.NoError(err, expr)
+		assert.Equal(expected, value, expr)
+	}
+	// 提取的数据不存在
+	for _, expr := range []string{"$.user.data.name", "$.orders[5].id", "$.unknown"} {
+		_, err := evaluate(expr, outputs)
+		assert.Error(err, expr)
+	}
+}
+
+func TestParseSteps(t *testing.T) {
+	assert := assert.New(t)
+	steps, err := ParseSteps([]interface{}{
+		map[string]interface{}{"name": "user", "service": "user.get"},
+		map[string]interface{}{"service": "order.list", "timeout": "1s", "args": map[string]interface{}{"userId": "$.user.id"}},
+	}, time.Second*3)
+	assert.NoError(err)
+	if assert.Len(steps, 2) {
+		assert.Equal("step1", steps[1].Name)
+		assert.Equal(time.Second, steps[1].Timeout)
+		assert.Equal(time.Second*3, steps[0].Timeout)
+	}
+	invalids := [][]interface{}{
+		nil,
+		{map[string]interface{}{"name": "user"}},
+		{map[string]interface{}{"name": "a", "service": "s"}, map[string]interface{}{"name": "a", "service": "s"}},
+		// 引用后续步骤
+		{map[string]interface{}{"name": "a", "service": "s", "args": map[string]interface{}{"id": "$.b.id"}},
+			map[string]interface{}{"name": "b", "service": "s"}},
+		// 表达式语法错误
+		{map[string]interface{}{"name": "a", "service": "s"},
+			map[string]interface{}{"name": "b", "service": "s", "args": map[string]interface{}{"id": "$.a[x"}}},
+	}
+	for _, spec := range invalids {
+		_, err := ParseSteps(spec, time.Second)
+		assert.Error(err, spec)
+	}
+}
+
+func TestRpcTransporter_Invoke(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	assert := assert.New(t)
+	backend := &stepTransporter{responses: map[string]interface{}{
+		"user.get":   map[string]interface{}{"id": "u1"},
+		"order.list": []interface{}{map[string]interface{}{"id": "o1"}},
+	}}
+	ext.RegisterTransporter(protoPipelineTest, backend)
+	for _, id := range []string{"user.get", "order.list"} {
+		service := flux.TransporterService{ServiceId: id}
+		service.Attributes = append(service.Attributes, flux.Attribute{Name: flux.ServiceAttrTagRpcProto, Value: protoPipelineTest})
+		ext.RegisterTransporterServiceById(id, service)
+	}
+	pipeline := NewTransporter()
+
+	value, serr := pipeline.Invoke(newPipelineContext(), newPipelineService([]interface{}{
+		map[string]interface{}{"name": "user", "service": "user.get"},
+		map[string]interface{}{"name": "orders", "service": "order.list", "args": map[string]interface{}{"userId": "$.user.id"}},
+	}, "$.orders[0].id"))
+	assert.Nil(serr)
+	assert.Equal("o1", value)
+	assert.Equal([]string{"user.get", "order.list"}, backend.invoked)
+
+	// 前序步骤的输出中不存在提取的数据：中止执行，不调用后续步骤
+	backend.invoked = nil
+	_, serr = pipeline.Invoke(newPipelineContext(), newPipelineService([]interface{}{
+		map[string]interface{}{"name": "user", "service": "user.get"},
+		map[string]interface{}{"name": "orders", "service": "order.list", "args": map[string]interface{}{"userId": "$.user.uid"}},
+	}, ""))
+	if assert.NotNil(serr) {
+		assert.Equal(flux.StatusBadGateway, serr.StatusCode)
+		assert.Equal(ErrorCodePipelineValueAbsent, serr.ErrorCode)
+		assert.Equal("orders", serr.ExtraByKey("step"))
+	}
+	assert.Equal([]string{"user.get"}, backend.invoked)
+
+	// 输出表达式提取的数据不存在
+	_, serr = pipeline.Invoke(newPipelineContext(), newPipelineService([]interface{}{
+		map[string]interface{}{"name": "user", "service": "user.get"},
+	}, "$.user.name"))
+	if assert.NotNil(serr) {
+		assert.Equal(ErrorCodePipelineValueAbsent, serr.ErrorCode)
+	}
+}
@@ -0,0 +1,235 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errPoolClosed = errors.New("redis client is closed")
+)
+
+// ErrorReply Redis返回的错误响应
+type ErrorReply string
+
+func (e ErrorReply) Error() string {
+	return string(e)
+}
+
+// Client 基于RESP协议的Redis客户端，维护固定上限的连接池；仅支持单节点（或代理）模式
+type Client struct {
+	address     string
+	password    string
+	database    int
+	dialTimeout time.Duration
+	ioTimeout   time.Duration
+	conns       chan *conn
+	tokens      chan struct{}
+	closed      chan struct{}
+}
+
+type conn struct {
+	net.Conn
+	reader *bufio.Reader
+	writer *bufio.Writer
+}
+
+func NewClient(address, password string, database, poolSize int, dialTimeout, ioTimeout time.Duration) *Client {
+	if poolSize <= 0 {
+		poolSize = 1
+	}
+	return &Client{
+		address:     address,
+		password:    password,
+		database:    database,
+		dialTimeout: dialTimeout,
+		ioTimeout:   ioTimeout,
+		conns:       make(chan *conn, poolSize),
+		tokens:      make(chan struct{}, poolSize),
+		closed:      make(chan struct{}),
+	}
+}
+
+// Do 执行Redis命令；返回值为 string，int64，[]interface{}，或nil（不存在）；Redis错误响应返回 ErrorReply
+func (c *Client) Do(ctx context.Context, args ...interface{}) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if nil != err {
+		return nil, err
+	}
+	reply, err := cn.do(ctx, c.ioTimeout, args)
+	c.put(cn, err)
+	return reply, err
+}
+
+func (c *Client) Close() error {
+	select {
+	case <-c.closed:
+		return nil
+	default:
+		close(c.closed)
+	}
+	for {
+		select {
+		case cn := <-c.conns:
+			_ = cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case <-c.closed:
+		return nil, errPoolClosed
+	case cn := <-c.conns:
+		return cn, nil
+	case c.tokens <- struct{}{}:
+		cn, err := c.dial(ctx)
+		if nil != err {
+			<-c.tokens
+		}
+		return cn, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// put 归还连接；网络错误的连接直接关闭
+func (c *Client) put(cn *conn, err error) {
+	var reply ErrorReply
+	if nil != err && !errors.As(err, &reply) {
+		_ = cn.Close()
+		<-c.tokens
+		return
+	}
+	select {
+	case <-c.closed:
+		_ = cn.Close()
+		<-c.tokens
+	case c.conns <- cn:
+	}
+}
+
+func (c *Client) dial(ctx context.Context) (*conn, error) {
+	dialer := &net.Dialer{Timeout: c.dialTimeout}
+	nc, err := dialer.DialContext(ctx, "tcp", c.address)
+	if nil != err {
+		return nil, fmt.Errorf("dial redis, address: %s, err: %w", c.address, err)
+	}
+	cn := &conn{Conn: nc, reader: bufio.NewReader(nc), writer: bufio.NewWriter(nc)}
+	if c.password != "" {
+		if _, err := cn.do(ctx, c.ioTimeout, []interface{}{"AUTH", c.password}); nil != err {
+			_ = cn.Close()
+			return nil, fmt.Errorf("redis auth, err: %w", err)
+		}
+	}
+	if c.database > 0 {
+		if _, err := cn.do(ctx, c.ioTimeout, []interface{}{"SELECT", c.database}); nil != err {
+			_ = cn.Close()
+			return nil, fmt.Errorf("redis select db: %d, err: %w", c.database, err)
+		}
+	}
+	return cn, nil
+}
+
+func (cn *conn) do(ctx context.Context, timeout time.Duration, args []interface{}) (interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := cn.SetDeadline(deadline); nil != err {
+		return nil, err
+	}
+	if err := cn.write(args); nil != err {
+		return nil, err
+	}
+	return cn.read()
+}
+
+// write 以RESP数组格式发送命令
+func (cn *conn) write(args []interface{}) error {
+	cn.writer.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, arg := range args {
+		var s string
+		switch v := arg.(type) {
+		case string:
+			s = v
+		case []byte:
+			s = string(v)
+		case int:
+			s = strconv.Itoa(v)
+		case int64:
+			s = strconv.FormatInt(v, 10)
+		default:
+			s = fmt.Sprint(v)
+		}
+		cn.writer.WriteString("$" + strconv.Itoa(len(s)) + "\r\n")
+		cn.writer.WriteString(s)
+		cn.writer.WriteString("\r\n")
+	}
+	return cn.writer.Flush()
+}
+
+func (cn *conn) read() (interface{}, error) {
+	line, err := cn.reader.ReadString('\n')
+	if nil != err {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if len(line) == 0 {
+		return nil, errors.New("redis protocol error: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, ErrorReply(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if nil != err {
+			return nil, fmt.Errorf("redis protocol error: %s", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(cn.reader, buf); nil != err {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		size, err := strconv.Atoi(line[1:])
+		if nil != err {
+			return nil, fmt.Errorf("redis protocol error: %s", line)
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		out := make([]interface{}, size)
+		for i := range out {
+			// 数组中的错误响应作为元素值返回
+			value, err := cn.read()
+			var reply ErrorReply
+			if nil != err && !errors.As(err, &reply) {
+				return nil, err
+			}
+			if nil != err {
+				value = reply
+			}
+			out[i] = value
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("redis protocol error: %s", line)
+	}
+}
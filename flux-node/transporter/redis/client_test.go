@@ -0,0 +1,210 @@
+package redis
+
+import (
+	"bufio"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// respServer 解析RESP数组格式的命令，按handler返回原始响应数据
+type respServer struct {
+	listener net.Listener
+	handler  func(args []string) string
+	mu       sync.Mutex
+	commands [][]string
+	accepts  int
+}
+
+func newRespServer(t *testing.T, handler func(args []string) string) *respServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if nil != err {
+		t.Fatal(err)
+	}
+	s := &respServer{listener: listener, handler: handler}
+	go s.serve()
+	t.Cleanup(func() {
+		_ = listener.Close()
+	})
+	return s
+}
+
+func (s *respServer) serve() {
+	for {
+		nc, err := s.listener.Accept()
+		if nil != err {
+			return
+		}
+		s.mu.Lock()
+		s.accepts++
+		s.mu.Unlock()
+		go s.handle(nc)
+	}
+}
+
+func (s *respServer) handle(nc net.Conn) {
+	defer nc.Close()
+	reader := bufio.NewReader(nc)
+	for {
+		args, err := readCommand(reader)
+		if nil != err {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		s.mu.Unlock()
+		reply := s.handler(args)
+		if reply == "" {
+			return
+		}
+		if _, err := io.WriteString(nc, reply); nil != err {
+			return
+		}
+	}
+}
+
+func (s *respServer) received() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.commands...)
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if nil != err {
+		return nil, err
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if nil != err {
+		return nil, err
+	}
+	args := make([]string, size)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); nil != err {
+			return nil, err
+		}
+		length, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if nil != err {
+			return nil, err
+		}
+		buf := make([]byte, length+2)
+		if _, err := io.ReadFull(reader, buf); nil != err {
+			return nil, err
+		}
+		args[i] = string(buf[:length])
+	}
+	return args, nil
+}
+
+func TestClient_Replies(t *testing.T) {
+	assert := assert.New(t)
+	server := newRespServer(t, func(args []string) string {
+		switch args[0] {
+		case "AUTH", "SELECT":
+			return "+OK\r\n"
+		case "PING":
+			return "+PONG\r\n"
+		case "INCR":
+			return ":42\r\n"
+		case "GET":
+			if args[1] == "missing" {
+				return "$-1\r\n"
+			}
+			return "$12\r\nhello\r\nworld\r\n"
+		case "HGETALL":
+			return "*3\r\n$1\r\na\r\n-ERR inner\r\n*1\r\n:1\r\n"
+		default:
+			return "-ERR unknown command\r\n"
+		}
+	})
+	client := NewClient(server.listener.Addr().String(), "secret", 2, 1, time.Second, time.Second)
+	defer client.Close()
+	ctx := context.Background()
+
+	reply, err := client.Do(ctx, "PING")
+	assert.NoError(err)
+	assert.Equal("PONG", reply)
+	reply, err = client.Do(ctx, "INCR", "counter")
+	assert.NoError(err)
+	assert.Equal(int64(42), reply)
+	reply, err = client.Do(ctx, "GET", "greeting")
+	assert.NoError(err)
+	assert.Equal("hello\r\nworld", reply)
+	reply, err = client.Do(ctx, "GET", "missing")
+	assert.NoError(err)
+	assert.Nil(reply)
+	reply, err = client.Do(ctx, "HGETALL", "hash")
+	assert.NoError(err)
+	assert.Equal([]interface{}{"a", ErrorReply("ERR inner"), []interface{}{int64(1)}}, reply)
+	_, err = client.Do(ctx, "FLUSHALL")
+	assert.Equal(ErrorReply("ERR unknown command"), err)
+	// 错误响应不关闭连接；建立连接时认证并选择数据库
+	_, err = client.Do(ctx, "SET", "k", []byte("v"), int64(10))
+	assert.Error(err)
+	commands := server.received()
+	assert.Equal([]string{"AUTH", "secret"}, commands[0])
+	assert.Equal([]string{"SELECT", "2"}, commands[1])
+	assert.Equal([]string{"SET", "k", "v", "10"}, commands[len(commands)-1])
+	server.mu.Lock()
+	assert.Equal(1, server.accepts)
+	server.mu.Unlock()
+}
+
+func TestClient_NetworkError(t *testing.T) {
+	assert := assert.New(t)
+	var mu sync.Mutex
+	drop := true
+	server := newRespServer(t, func(args []string) string {
+		mu.Lock()
+		defer mu.Unlock()
+		if drop {
+			drop = false
+			// 不响应，关闭连接
+			return ""
+		}
+		return "+PONG\r\n"
+	})
+	client := NewClient(server.listener.Addr().String(), "", 0, 1, time.Second, time.Second)
+	defer client.Close()
+	_, err := client.Do(context.Background(), "PING")
+	assert.Error(err)
+	// 网络错误的连接被关闭，下次请求重新建立连接
+	reply, err := client.Do(context.Background(), "PING")
+	assert.NoError(err)
+	assert.Equal("PONG", reply)
+	server.mu.Lock()
+	assert.Equal(2, server.accepts)
+	server.mu.Unlock()
+}
+
+func TestClient_PoolLimit(t *testing.T) {
+	assert := assert.New(t)
+	release := make(chan struct{})
+	server := newRespServer(t, func(args []string) string {
+		<-release
+		return "+PONG\r\n"
+	})
+	client := NewClient(server.listener.Addr().String(), "", 0, 1, time.Second, time.Second)
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.Do(context.Background(), "PING")
+		done <- err
+	}()
+	time.Sleep(time.Millisecond * 50)
+	// 连接池已满时，等待至Context超时
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err := client.Do(ctx, "PING")
+	assert.Equal(context.DeadlineExceeded, err)
+	close(release)
+	assert.NoError(<-done)
+	assert.NoError(client.Close())
+	_, err = client.Do(context.Background(), "PING")
+	assert.Equal(errPoolClosed, err)
+}
@@ -0,0 +1,389 @@
+package redis
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/spf13/cast"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// Redis服务地址 host:port；为空时不连接Redis，请求返回503错误
+	ConfigKeyAddress     = "address"
+	ConfigKeyPassword    = "password"
+	ConfigKeyDatabase    = "database"
+	ConfigKeyPoolSize    = "pool_size"
+	ConfigKeyDialTimeout = "dial_timeout"
+	ConfigKeyIOTimeout   = "io_timeout"
+	// 注册的Lua脚本：name: script；EVAL命令只能执行已注册的脚本
+	ConfigKeyScripts = "scripts"
+)
+
+const (
+	// Service属性：EVAL命令执行的脚本名称
+	ServiceAttrTagRedisScript = "redisscript"
+	// Service属性：SET命令的值参数名称，默认为 value
+	ServiceAttrTagRedisValue = "redisvalue"
+	// Service属性：SET命令的过期时间，例如 10m
+	ServiceAttrTagRedisTTL = "redisttl"
+	// Service属性：HGET命令的字段名模板
+	ServiceAttrTagRedisField = "redisfield"
+)
+
+const (
+	CommandGet     = "GET"
+	CommandSet     = "SET"
+	CommandHGet    = "HGET"
+	CommandHGetAll = "HGETALL"
+	CommandEval    = "EVAL"
+)
+
+const (
+	ErrorCodeRedisUnavailable  = "REDIS:UNAVAILABLE"
+	ErrorCodeRedisSpecInvalid  = "REDIS:SPEC_INVALID"
+	ErrorCodeRedisCommandError = "REDIS:COMMAND_ERROR"
+	ErrorCodeRedisKeyNotFound  = "REDIS:KEY_NOT_FOUND"
+)
+
+var (
+	keyVarPattern = regexp.MustCompile(`\{([^{}]+)\}`)
+)
+
+func init() {
+	ext.RegisterTransporterFactory(flux.ProtoRedis, func() interface{} {
+		return NewTransporter()
+	})
+}
+
+var (
+	_ flux.Transporter = new(RpcTransporter)
+	_ flux.Shutdowner  = new(RpcTransporter)
+)
+
+type script struct {
+	source string
+	sha    string
+}
+
+// RpcTransporter Redis命令网关服务：按Service定义将请求映射为Redis命令，直接从Redis读写数据；
+// Service的 Method 为命令（GET，SET，HGET，HGETALL，EVAL），Interface 为Key模板，{name} 替换为同名参数的值；
+// EVAL命令的 Interface 为逗号分隔的Key模板列表，全部参数值按定义顺序作为脚本的ARGV。
+type RpcTransporter struct {
+	client  *Client
+	scripts map[string]script
+	writer  flux.TransportWriter
+	mu      sync.RWMutex
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		scripts: make(map[string]script, 0),
+		writer:  new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyPoolSize:    16,
+		ConfigKeyDialTimeout: time.Second * 3,
+		ConfigKeyIOTimeout:   time.Second * 3,
+	})
+	scripts := make(map[string]script, 4)
+	for name, source := range config.GetStringMapString(ConfigKeyScripts) {
+		sum := sha1.Sum([]byte(source))
+		scripts[name] = script{source: source, sha: hex.EncodeToString(sum[:])}
+	}
+	var client *Client
+	address := config.GetString(ConfigKeyAddress)
+	if address != "" {
+		password, err := config.GetStringE(ConfigKeyPassword)
+		if nil != err {
+			return err
+		}
+		client = NewClient(address, password, config.GetInt(ConfigKeyDatabase),
+			config.GetInt(ConfigKeyPoolSize), config.GetDuration(ConfigKeyDialTimeout), config.GetDuration(ConfigKeyIOTimeout))
+	} else {
+		logger.Warnw("Redis transporter has no address")
+	}
+	b.mu.Lock()
+	prev := b.client
+	b.client, b.scripts = client, scripts
+	b.mu.Unlock()
+	if nil != prev {
+		_ = prev.Close()
+	}
+	logger.Infow("Redis transporter init", "address", address, "database", config.GetInt(ConfigKeyDatabase),
+		"pool-size", config.GetInt(ConfigKeyPoolSize), "scripts", len(scripts))
+	return nil
+}
+
+func (b *RpcTransporter) Shutdown(_ context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if nil != b.client {
+		return b.client.Close()
+	}
+	return nil
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	transporter.DoTransport(ctx, b)
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	value, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return &flux.ResponseBody{
+		StatusCode: flux.StatusOK,
+		Headers:    make(http.Header, 0),
+		Body:       value,
+	}, nil
+}
+
+// Invoke 执行Service定义的Redis命令，返回解析后的数据
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	b.mu.RLock()
+	client, scripts := b.client, b.scripts
+	b.mu.RUnlock()
+	if nil == client {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusUnavailable,
+			ErrorCode:  ErrorCodeRedisUnavailable,
+			Message:    "REDIS:CLIENT:UNAVAILABLE",
+		}
+	}
+	command := strings.ToUpper(service.Method)
+	args, err := b.assemble(ctx, service, command, scripts)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  ErrorCodeRedisSpecInvalid,
+			Message:    "REDIS:COMMAND:ASSEMBLE",
+			CauseError: err,
+		}
+	}
+	reply, err := client.Do(ctx.Context(), args...)
+	// 脚本未缓存时，使用脚本内容重新执行
+	if rerr, ok := err.(ErrorReply); ok && strings.HasPrefix(string(rerr), "NOSCRIPT") {
+		eval := append([]interface{}{CommandEval, scripts[service.GetAttr(ServiceAttrTagRedisScript).GetString()].source}, args[2:]...)
+		reply, err = client.Do(ctx.Context(), eval...)
+	}
+	if nil != err {
+		return nil, toServeError(ctx, command, err)
+	}
+	switch command {
+	case CommandGet, CommandHGet:
+		if nil == reply {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusNotFound,
+				ErrorCode:  ErrorCodeRedisKeyNotFound,
+				Message:    "REDIS:KEY:NOT_FOUND",
+			}
+		}
+		value, _ := transporter.DecodeResponseBody(reply)
+		return value, nil
+	case CommandHGetAll:
+		pairs, _ := reply.([]interface{})
+		if len(pairs) == 0 {
+			return nil, &flux.ServeError{
+				StatusCode: flux.StatusNotFound,
+				ErrorCode:  ErrorCodeRedisKeyNotFound,
+				Message:    "REDIS:KEY:NOT_FOUND",
+			}
+		}
+		out := make(map[string]interface{}, len(pairs)/2)
+		for i := 0; i+1 < len(pairs); i += 2 {
+			out[cast.ToString(pairs[i])], _ = transporter.DecodeResponseBody(pairs[i+1])
+		}
+		return out, nil
+	default:
+		return toValue(reply), nil
+	}
+}
+
+// assemble 按Service定义组装Redis命令参数
+func (b *RpcTransporter) assemble(ctx *flux.Context, service flux.TransporterService, command string, scripts map[string]script) ([]interface{}, error) {
+	values := make(map[string]interface{}, len(service.Arguments))
+	ordered := make([]interface{}, 0, len(service.Arguments))
+	for _, arg := range service.Arguments {
+		value, err := arg.Resolve(ctx)
+		if nil != err {
+			return nil, err
+		}
+		values[arg.Name] = value
+		ordered = append(ordered, toArg(value))
+	}
+	switch command {
+	case CommandGet, CommandHGetAll:
+		key, err := expandTemplate(service.Interface, values)
+		if nil != err {
+			return nil, err
+		}
+		return []interface{}{command, key}, nil
+	case CommandHGet:
+		key, err := expandTemplate(service.Interface, values)
+		if nil != err {
+			return nil, err
+		}
+		field, err := expandTemplate(service.GetAttr(ServiceAttrTagRedisField).GetString(), values)
+		if nil != err {
+			return nil, err
+		}
+		return []interface{}{command, key, field}, nil
+	case CommandSet:
+		key, err := expandTemplate(service.Interface, values)
+		if nil != err {
+			return nil, err
+		}
+		name := service.GetAttr(ServiceAttrTagRedisValue).GetString()
+		if name == "" {
+			name = "value"
+		}
+		value, ok := values[name]
+		if !ok {
+			return nil, fmt.Errorf("redis SET value argument not found, name: %s", name)
+		}
+		args := []interface{}{command, key, toArg(value)}
+		if ttl := service.GetAttr(ServiceAttrTagRedisTTL).GetString(); ttl != "" {
+			d, err := time.ParseDuration(ttl)
+			if nil != err || d <= 0 {
+				return nil, fmt.Errorf("redis SET ttl is invalid: %s", ttl)
+			}
+			args = append(args, "PX", d.Milliseconds())
+		}
+		return args, nil
+	case CommandEval:
+		name := service.GetAttr(ServiceAttrTagRedisScript).GetString()
+		s, ok := scripts[name]
+		if !ok {
+			return nil, fmt.Errorf("redis script not registered, name: %s", name)
+		}
+		keys := make([]interface{}, 0, 2)
+		for _, tpl := range strings.Split(service.Interface, ",") {
+			if tpl = strings.TrimSpace(tpl); tpl == "" {
+				continue
+			}
+			key, err := expandTemplate(tpl, values)
+			if nil != err {
+				return nil, err
+			}
+			keys = append(keys, key)
+		}
+		args := append([]interface{}{"EVALSHA", s.sha, len(keys)}, keys...)
+		return append(args, ordered...), nil
+	default:
+		return nil, fmt.Errorf("redis command not supported: %s", service.Method)
+	}
+}
+
+// expandTemplate 将模板中的 {name} 替换为同名参数的值；参数值不能为空，不能包含Key分隔符，
+// 哈希标签及通配符等字符，避免请求参数改变Key的命名空间
+func expandTemplate(tpl string, values map[string]interface{}) (string, error) {
+	if tpl == "" {
+		return "", errors.New("redis key/field template is empty")
+	}
+	var missing, invalid string
+	out := keyVarPattern.ReplaceAllStringFunc(tpl, func(v string) string {
+		name := v[1 : len(v)-1]
+		value, ok := values[name]
+		if !ok || nil == value {
+			missing = name
+			return v
+		}
+		text := cast.ToString(value)
+		if text == "" || strings.IndexFunc(text, isKeySeparator) >= 0 {
+			invalid = name
+			return v
+		}
+		return text
+	})
+	if missing != "" {
+		return "", fmt.Errorf("redis template argument not found, template: %s, name: %s", tpl, missing)
+	}
+	if invalid != "" {
+		return "", fmt.Errorf("redis template argument is empty or contains separators, template: %s, name: %s", tpl, invalid)
+	}
+	return out, nil
+}
+
+func isKeySeparator(r rune) bool {
+	switch r {
+	case ':', '{', '}', '*', '?', '[', ']', ',':
+		return true
+	default:
+		return unicode.IsSpace(r) || unicode.IsControl(r)
+	}
+}
+
+// toArg 将参数值转换为命令参数；复杂对象编码为JSON
+func toArg(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string, []byte, int, int64:
+		return v
+	case map[string]interface{}, []interface{}:
+		bytes, err := ext.JSONMarshal(v)
+		if nil != err {
+			return cast.ToString(v)
+		}
+		return bytes
+	default:
+		return cast.ToString(v)
+	}
+}
+
+func toValue(reply interface{}) interface{} {
+	switch v := reply.(type) {
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i := range v {
+			out[i] = toValue(v[i])
+		}
+		return out
+	case ErrorReply:
+		return string(v)
+	default:
+		return v
+	}
+}
+
+func toServeError(ctx *flux.Context, command string, err error) *flux.ServeError {
+	logger.TraceContext(ctx).Warnw("TRANSPORTER:REDIS:COMMAND/ERROR", "command", command, "error", err)
+	if _, ok := err.(ErrorReply); ok {
+		return &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodeRedisCommandError,
+			Message:    "REDIS:COMMAND:ERROR",
+			CauseError: err,
+		}
+	}
+	if nerr, ok := err.(net.Error); (ok && nerr.Timeout()) || errors.Is(err, context.DeadlineExceeded) {
+		return flux.NewTimeoutServeError(err)
+	}
+	return &flux.ServeError{
+		StatusCode: flux.StatusBadGateway,
+		ErrorCode:  ErrorCodeRedisUnavailable,
+		Message:    "REDIS:CONNECTION:ERROR",
+		CauseError: err,
+	}
+}
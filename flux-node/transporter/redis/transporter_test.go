@@ -0,0 +1,25 @@
+package redis
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	assert := assert.New(t)
+	key, err := expandTemplate("user:{id}:profile", map[string]interface{}{"id": 1001})
+	assert.NoError(err)
+	assert.Equal("user:1001:profile", key)
+	key, err = expandTemplate("user:{id}", map[string]interface{}{"id": "a-b_c.d"})
+	assert.NoError(err)
+	assert.Equal("user:a-b_c.d", key)
+	_, err = expandTemplate("", map[string]interface{}{})
+	assert.Error(err)
+	_, err = expandTemplate("user:{id}", map[string]interface{}{})
+	assert.Error(err)
+	// 参数值不能改变Key的命名空间
+	for _, value := range []string{"", "1:admin", "{admin}", "*", "a?", "[a]", "a b", "a\r\nDEL", "a,b"} {
+		_, err = expandTemplate("user:{id}", map[string]interface{}{"id": value})
+		assert.Error(err, "value: %q", value)
+	}
+}
@@ -0,0 +1,138 @@
+package transporter
+
+import (
+	"context"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/discovery"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/nacos-group/nacos-sdk-go/clients"
+	"github.com/nacos-group/nacos-sdk-go/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/common/constant"
+	"github.com/nacos-group/nacos-sdk-go/vo"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	InstanceResolverDNSSRV = "dnssrv"
+	InstanceResolverConsul = "consul"
+	InstanceResolverNacos  = "nacos"
+)
+
+func init() {
+	ext.RegisterInstanceResolver(InstanceResolverDNSSRV, new(DNSSRVResolver))
+}
+
+var (
+	_ flux.InstanceResolver = new(DNSSRVResolver)
+	_ flux.InstanceResolver = new(ConsulResolver)
+	_ flux.InstanceResolver = new(NacosResolver)
+)
+
+// DNSSRVResolver 通过DNS SRV记录查询实例地址；name 为完整的SRV记录名称，例如：_http._tcp.users.local
+type DNSSRVResolver struct{}
+
+func (*DNSSRVResolver) Resolve(ctx context.Context, name string) ([]string, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+	if nil != err {
+		return nil, err
+	}
+	out := make([]string, 0, len(records))
+	for _, r := range records {
+		out = append(out, net.JoinHostPort(strings.TrimSuffix(r.Target, "."), strconv.Itoa(int(r.Port))))
+	}
+	return out, nil
+}
+
+// ConsulResolver 通过Consul Health API查询健康的实例地址
+type ConsulResolver struct {
+	Address string
+	Client  *http.Client
+}
+
+func (r *ConsulResolver) Resolve(ctx context.Context, name string) ([]string, error) {
+	var entries []struct {
+		Node struct {
+			Address string
+		}
+		Service struct {
+			Address string
+			Port    int
+		}
+	}
+	api := strings.TrimSuffix(r.Address, "/") + "/v1/health/service/" + url.PathEscape(name) + "?passing=true"
+	if err := getJSON(ctx, r.Client, api, &entries); nil != err {
+		return nil, err
+	}
+	out := make([]string, 0, len(entries))
+	for _, e := range entries {
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		out = append(out, net.JoinHostPort(host, strconv.Itoa(e.Service.Port)))
+	}
+	return out, nil
+}
+
+// NacosResolver 通过Nacos命名服务查询健康的实例地址；name 为服务名称，可使用 <group>@@<service> 指定分组
+type NacosResolver struct {
+	client naming_client.INamingClient
+}
+
+// NewNacosResolver 创建Nacos命名服务客户端；address 为以逗号分隔的Nacos服务地址列表（host:port）
+func NewNacosResolver(address, contextPath string, clientConfig constant.ClientConfig) (*NacosResolver, error) {
+	servers, err := discovery.ParseNacosServerConfigs(address, contextPath)
+	if nil != err {
+		return nil, err
+	}
+	client, err := clients.CreateNamingClient(map[string]interface{}{
+		constant.KEY_SERVER_CONFIGS: servers,
+		constant.KEY_CLIENT_CONFIG:  clientConfig,
+	})
+	if nil != err {
+		return nil, fmt.Errorf("nacos naming client create failed, err: %w", err)
+	}
+	return &NacosResolver{client: client}, nil
+}
+
+func (r *NacosResolver) Resolve(_ context.Context, name string) ([]string, error) {
+	param := vo.SelectInstancesParam{ServiceName: name, HealthyOnly: true}
+	if idx := strings.Index(name, "@@"); idx > 0 {
+		param.GroupName, param.ServiceName = name[:idx], name[idx+2:]
+	}
+	hosts, err := r.client.SelectInstances(param)
+	if nil != err {
+		return nil, err
+	}
+	out := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		out = append(out, net.JoinHostPort(h.Ip, strconv.FormatUint(h.Port, 10)))
+	}
+	return out, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, api string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, api, nil)
+	if nil != err {
+		return err
+	}
+	resp, err := client.Do(req)
+	if nil != err {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if nil != err {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request %s returns status: %d", api, resp.StatusCode)
+	}
+	return ext.JSONUnmarshal(data, out)
+}
@@ -0,0 +1,304 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	"github.com/bytepowered/flux/flux-script"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// 单次脚本执行的超时时间
+	ConfigKeyTimeout = "timeout"
+	// 单次脚本执行中最多调用的后端服务数量
+	ConfigKeyMaxInvokes = "max_invokes"
+	// 脚本中调用后端服务的超时时间
+	ConfigKeyInvokeTimeout = "invoke_timeout"
+	// 最大并行执行的脚本数量；goja不支持限制单个运行时的内存，通过限制并行数量及执行时间限制内存占用
+	ConfigKeyMaxConcurrency = "max_concurrency"
+	// 注入脚本的请求Body的最大长度
+	ConfigKeyMaxBodySize = "max_body_size"
+	// 缓存的已编译脚本数量
+	ConfigKeyMaxScripts = "max_scripts"
+	// 脚本的调用栈深度
+	ConfigKeyMaxCallStack = "max_call_stack"
+)
+
+const (
+	// Service属性：JavaScript脚本内容
+	ServiceAttrTagScript = "script"
+	// Service属性：脚本的入口函数名，默认为 entry
+	ServiceAttrTagScriptEntry = "scriptentry"
+)
+
+const (
+	ErrorCodeScriptSpecInvalid  = "SCRIPT:SPEC_INVALID"
+	ErrorCodeScriptExecuteError = "SCRIPT:EXECUTE_ERROR"
+)
+
+func init() {
+	ext.RegisterTransporterFactory(flux.ProtoScript, func() interface{} {
+		return NewTransporter()
+	})
+}
+
+var (
+	_ flux.Transporter      = new(RpcTransporter)
+	_ flux.ServiceValidator = new(RpcTransporter)
+)
+
+// ScriptContext 注入到脚本入口函数的上下文：请求数据，设置响应状态码及Header的函数，以及调用后端服务的函数
+type ScriptContext struct {
+	fluxscript.ScriptContext
+	RequestId string `json:"requestId"`
+	// 请求Body；JSON格式的数据解析为对象，其它数据为字符串
+	Body          interface{}                                                     `json:"body"`
+	SetStatusFunc func(status int) error                                          `json:"setStatus"`
+	SetHeaderFunc func(name, value string)                                        `json:"setHeader"`
+	InvokeFunc    func(serviceId string, args map[string]interface{}) interface{} `json:"invoke"`
+}
+
+// RpcTransporter 脚本网关服务：执行Service属性中定义的JavaScript脚本，脚本入口函数的返回值作为响应数据；
+// 脚本在独立的运行时中执行，超时后中断；脚本可调用已注册的后端服务，调用失败时返回 {"error": {...}} 错误对象。
+type RpcTransporter struct {
+	engine        *fluxscript.Engine
+	timeout       time.Duration
+	invokeTimeout time.Duration
+	maxInvokes    int
+	maxBodySize   int64
+	slots         chan struct{}
+	writer        flux.TransportWriter
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		engine:        fluxscript.NewBoundedEngine(fluxscript.DefaultMaxScripts, fluxscript.DefaultMaxCallStackSize),
+		timeout:       time.Second,
+		invokeTimeout: time.Second * 3,
+		maxInvokes:    8,
+		maxBodySize:   1024 * 1024,
+		slots:         make(chan struct{}, 64),
+		writer:        new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Init(config *flux.Configuration) error {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyTimeout:        time.Second,
+		ConfigKeyInvokeTimeout:  time.Second * 3,
+		ConfigKeyMaxInvokes:     8,
+		ConfigKeyMaxConcurrency: 64,
+		ConfigKeyMaxBodySize:    1024 * 1024,
+		ConfigKeyMaxScripts:     fluxscript.DefaultMaxScripts,
+		ConfigKeyMaxCallStack:   fluxscript.DefaultMaxCallStackSize,
+	})
+	b.timeout = config.GetDuration(ConfigKeyTimeout)
+	b.invokeTimeout = config.GetDuration(ConfigKeyInvokeTimeout)
+	b.maxInvokes = config.GetInt(ConfigKeyMaxInvokes)
+	b.maxBodySize = config.GetInt64(ConfigKeyMaxBodySize)
+	concurrency := config.GetInt(ConfigKeyMaxConcurrency)
+	if concurrency <= 0 {
+		return fmt.Errorf("script transporter requires positive %s, was: %d", ConfigKeyMaxConcurrency, concurrency)
+	}
+	b.slots = make(chan struct{}, concurrency)
+	b.engine = fluxscript.NewBoundedEngine(config.GetInt(ConfigKeyMaxScripts), config.GetInt(ConfigKeyMaxCallStack))
+	logger.Infow("Script transporter init", "timeout", b.timeout, "invoke-timeout", b.invokeTimeout, "max-invokes", b.maxInvokes,
+		"max-concurrency", concurrency, "max-body-size", b.maxBodySize)
+	return nil
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	transporter.DoTransport(ctx, b)
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	value, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	return value.(*flux.ResponseBody), nil
+}
+
+// Invoke 执行脚本，返回包含状态码，Header及响应数据的 *flux.ResponseBody
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	source := service.GetAttr(ServiceAttrTagScript).GetString()
+	if source == "" {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodeScriptSpecInvalid,
+			Message:    "SCRIPT:SOURCE:REQUIRED",
+			CauseError: fmt.Errorf("service attribute(%s) is required, service-id: %s", ServiceAttrTagScript, service.ServiceID()),
+		}
+	}
+	if _, err := b.engine.Load(source); nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodeScriptSpecInvalid,
+			Message:    "SCRIPT:SOURCE:INVALID",
+			CauseError: err,
+		}
+	}
+	entry := service.GetAttr(ServiceAttrTagScriptEntry).GetString()
+	if entry == "" {
+		entry = fluxscript.ScriptEntryFunName
+	}
+	response := &flux.ResponseBody{
+		StatusCode: flux.StatusOK,
+		Headers:    make(http.Header, 2),
+	}
+	body, serr := b.readBody(ctx)
+	if nil != serr {
+		return nil, serr
+	}
+	toctx, cancel := context.WithTimeout(ctx.Context(), b.timeout)
+	defer cancel()
+	select {
+	case b.slots <- struct{}{}:
+		defer func() { <-b.slots }()
+	case <-toctx.Done():
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusUnavailable,
+			ErrorCode:  flux.ErrorCodeGatewayOverloaded,
+			Message:    "SCRIPT:CONCURRENCY:EXCEEDED",
+			CauseError: toctx.Err(),
+		}
+	}
+	sctx := b.newScriptContext(ctx, body, response)
+	value, err := b.engine.EvalContext(toctx, source, entry, sctx)
+	if nil != err {
+		logger.TraceContext(ctx).Warnw("TRANSPORTER:SCRIPT:EXECUTE/ERROR", "service-id", service.ServiceID(), "error", err)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, flux.NewTimeoutServeError(err)
+		}
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  ErrorCodeScriptExecuteError,
+			Message:    "SCRIPT:EXECUTE:ERROR",
+			CauseError: err,
+		}
+	}
+	response.Body = value
+	return response, nil
+}
+
+// ValidateService 注册后端服务时编译脚本，脚本语法错误的服务不注册
+func (b *RpcTransporter) ValidateService(service flux.TransporterService) error {
+	source := service.GetAttr(ServiceAttrTagScript).GetString()
+	if source == "" {
+		return fmt.Errorf("service attribute(%s) is required, service-id: %s", ServiceAttrTagScript, service.ServiceID())
+	}
+	_, err := b.engine.Load(source)
+	return err
+}
+
+func (b *RpcTransporter) readBody(ctx *flux.Context) (interface{}, *flux.ServeError) {
+	reader, err := ctx.BodyReader()
+	if nil != err {
+		return nil, nil
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(io.LimitReader(reader, b.maxBodySize+1))
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadRequest,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    "SCRIPT:BODY:READ_ERROR",
+			CauseError: err,
+		}
+	}
+	if int64(len(data)) > b.maxBodySize {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusTooLarge,
+			ErrorCode:  flux.ErrorCodeRequestInvalid,
+			Message:    "SCRIPT:BODY:TOO_LARGE",
+		}
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	body, _ := transporter.DecodeResponseBody(data)
+	return body, nil
+}
+
+func (b *RpcTransporter) newScriptContext(ctx *flux.Context, body interface{}, response *flux.ResponseBody) ScriptContext {
+	var mu sync.Mutex
+	invokes := 0
+	return ScriptContext{
+		ScriptContext: fluxscript.NewScriptContext(ctx, ctx.Endpoint().HttpPattern),
+		RequestId:     ctx.RequestId(),
+		Body:          body,
+		SetStatusFunc: func(status int) error {
+			// 返回的错误在脚本中抛出异常
+			if status < 100 || status > 599 {
+				return fmt.Errorf("invalid response status: %d", status)
+			}
+			mu.Lock()
+			response.StatusCode = status
+			mu.Unlock()
+			return nil
+		},
+		SetHeaderFunc: func(name, value string) {
+			mu.Lock()
+			response.Headers.Set(name, value)
+			mu.Unlock()
+		},
+		InvokeFunc: func(serviceId string, args map[string]interface{}) interface{} {
+			mu.Lock()
+			invokes++
+			count := invokes
+			mu.Unlock()
+			if count > b.maxInvokes {
+				return scriptError(&flux.ServeError{
+					StatusCode: flux.StatusServerError,
+					ErrorCode:  ErrorCodeScriptExecuteError,
+					Message:    fmt.Sprintf("SCRIPT:INVOKE:LIMIT_EXCEEDED: %d", b.maxInvokes),
+				})
+			}
+			value, serr := b.invoke(ctx, serviceId, args)
+			if nil != serr {
+				logger.TraceContext(ctx).Infow("TRANSPORTER:SCRIPT:INVOKE/ERROR", "service-id", serviceId, "error", serr)
+				return scriptError(serr)
+			}
+			return value
+		},
+	}
+}
+
+func (b *RpcTransporter) invoke(ctx *flux.Context, serviceId string, args map[string]interface{}) (interface{}, *flux.ServeError) {
+	service, ok := ext.TransporterServiceById(serviceId)
+	if !ok {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  flux.ErrorCodeGatewayEndpoint,
+			Message:    "SCRIPT:SERVICE:NOT_FOUND",
+			CauseError: fmt.Errorf("service not found, id: %s", serviceId),
+		}
+	}
+	if len(args) > 0 {
+		service.Arguments = transporter.BindArgumentValues(service.Arguments, args)
+	}
+	return transporter.InvokeService(ctx, service, b.invokeTimeout)
+}
+
+func scriptError(serr *flux.ServeError) map[string]interface{} {
+	return map[string]interface{}{
+		"error": map[string]interface{}{
+			"status":  serr.StatusCode,
+			"code":    serr.GetErrorCode(),
+			"message": serr.Message,
+		},
+	}
+}
@@ -0,0 +1,104 @@
+package script
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/internal"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/labstack/echo/v4"
+	"github.com/spf13/cast"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newScriptTransporter(t *testing.T, config map[string]interface{}) *RpcTransporter {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	ext.RegisterSerializer(ext.TypeNameSerializerJson, flux.NewJsonSerializer())
+	script := NewTransporter().(*RpcTransporter)
+	assert.NoError(t, script.Init(flux.NewConfigurationOfMap(config)))
+	return script
+}
+
+func newScriptService(source string) flux.TransporterService {
+	return flux.TransporterService{
+		ServiceId: "script.test",
+		EmbeddedAttributes: flux.EmbeddedAttributes{
+			Attributes: []flux.Attribute{{Name: ServiceAttrTagScript, Value: source}},
+		},
+	}
+}
+
+func newScriptContext(body string) *flux.Context {
+	request := httptest.NewRequest(http.MethodPost, "http://gateway/script", strings.NewReader(body))
+	request.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(body)), nil
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(internal.NewServeWebContext(echo.New().NewContext(request, httptest.NewRecorder()), "script", nil), &flux.Endpoint{})
+	return ctx
+}
+
+func TestRpcTransporter_Invoke(t *testing.T) {
+	assert := assert.New(t)
+	script := newScriptTransporter(t, map[string]interface{}{})
+	response, serr := script.InvokeCodec(newScriptContext(`{"id":1}`), newScriptService(`
+function entry(ctx) {
+	ctx.setStatus(201);
+	ctx.setHeader("X-Script", "true");
+	return {id: ctx.body.id};
+}`))
+	assert.Nil(serr)
+	assert.Equal(201, response.StatusCode)
+	assert.Equal("true", response.Headers.Get("X-Script"))
+	assert.Equal(1, cast.ToInt(response.Body.(map[string]interface{})["id"]))
+}
+
+func TestRpcTransporter_InvalidStatus(t *testing.T) {
+	assert := assert.New(t)
+	script := newScriptTransporter(t, map[string]interface{}{})
+	_, serr := script.InvokeCodec(newScriptContext(""), newScriptService(`
+function entry(ctx) {
+	ctx.setStatus(1000);
+	return "ok";
+}`))
+	assert.NotNil(serr)
+	assert.Equal(ErrorCodeScriptExecuteError, serr.ErrorCode)
+	// 脚本可捕获无效状态码的异常
+	response, serr := script.InvokeCodec(newScriptContext(""), newScriptService(`
+function entry(ctx) {
+	try { ctx.setStatus(0); } catch (e) { return "caught"; }
+	return "ok";
+}`))
+	assert.Nil(serr)
+	assert.Equal(flux.StatusOK, response.StatusCode)
+	assert.Equal("caught", response.Body)
+}
+
+func TestRpcTransporter_Limits(t *testing.T) {
+	assert := assert.New(t)
+	script := newScriptTransporter(t, map[string]interface{}{
+		ConfigKeyTimeout:     "50ms",
+		ConfigKeyMaxBodySize: 8,
+	})
+	_, serr := script.InvokeCodec(newScriptContext(`{"id":"0123456789"}`), newScriptService(`function entry(ctx) { return "ok"; }`))
+	assert.NotNil(serr)
+	assert.Equal(flux.StatusTooLarge, serr.StatusCode)
+	start := time.Now()
+	_, serr = script.InvokeCodec(newScriptContext(""), newScriptService(`function entry(ctx) { while (true) {} }`))
+	assert.NotNil(serr)
+	assert.Equal(flux.StatusTimeout, serr.StatusCode)
+	assert.True(time.Since(start) < time.Second)
+}
+
+func TestRpcTransporter_ValidateService(t *testing.T) {
+	script := newScriptTransporter(t, map[string]interface{}{})
+	assert.NoError(t, script.ValidateService(newScriptService(`function entry(ctx) { return 1; }`)))
+	assert.Error(t, script.ValidateService(newScriptService(`function entry(ctx) { return `)))
+	assert.Error(t, script.ValidateService(newScriptService("")))
+}
@@ -1,6 +1,7 @@
 package transporter
 
 import (
+	"context"
 	"fmt"
 	"github.com/bytepowered/flux/flux-node"
 	"github.com/bytepowered/flux/flux-node/common"
@@ -13,6 +14,11 @@ func DoTransport(ctx *flux.Context, transport flux.Transporter) {
 	response, serr := transport.InvokeCodec(ctx, ctx.Transporter())
 	select {
 	case <-ctx.Context().Done():
+		if ctx.Context().Err() == context.DeadlineExceeded {
+			ctx.Logger().Warnw("TRANSPORTER:TIMEOUT", "error", serr)
+			transport.Writer().WriteError(ctx, flux.NewTimeoutServeError(ctx.Context().Err()))
+			return
+		}
 		ctx.Logger().Warnw("TRANSPORTER:CANCELED/BYCLIENT")
 		return
 	default:
@@ -23,9 +29,31 @@ func DoTransport(ctx *flux.Context, transport flux.Transporter) {
 		transport.Writer().WriteError(ctx, serr)
 	} else {
 		fluxpkg.AssertNotNil(response, "exchange: <response> must-not nil, request-id: "+ctx.RequestId())
+		// gRPC后端的错误状态，按gRPC状态码映射为网关错误
+		if serr := GrpcStatusError(response); nil != serr {
+			ctx.Logger().Warnw("TRANSPORTER:GRPC_STATUS/ERROR", "error", serr)
+			transport.Writer().WriteError(ctx, serr)
+			return
+		}
 		for k, v := range response.Attachments {
 			ctx.SetAttribute(k, v)
 		}
+		for _, hook := range ctx.ResponseHooks() {
+			if err := hook(ctx, response); nil != err {
+				ctx.Logger().Errorw("TRANSPORTER:RESPONSE_HOOK/ERROR", "error", err)
+				serr, ok := err.(*flux.ServeError)
+				if !ok {
+					serr = &flux.ServeError{
+						StatusCode: flux.StatusServerError,
+						ErrorCode:  flux.ErrorCodeGatewayInternal,
+						Message:    flux.ErrorMessageTransportResponseHook,
+						CauseError: err,
+					}
+				}
+				transport.Writer().WriteError(ctx, serr)
+				return
+			}
+		}
 		transport.Writer().Write(ctx, response)
 	}
 }
@@ -65,7 +93,31 @@ func (r *DefaultTransportWriter) Write(ctx *flux.Context, response *flux.Respons
 			CauseError: err,
 		})
 	} else {
+		trailers := len(response.Trailers) > 0 && acceptTrailers(ctx)
+		if trailers {
+			// Trailer需要在写入响应Header前声明
+			for k := range response.Trailers {
+				header.Add(HeaderTrailer, k)
+			}
+		}
+		if bytes, err = DoWriteHooks(ctx, response.StatusCode, bytes); nil != err {
+			// 响应写入处理失败（例如签名失败）时，不输出未处理的数据
+			r.WriteError(ctx, &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  flux.ErrorCodeGatewayInternal,
+				Message:    flux.ErrorMessageTransportWriteHook,
+				CauseError: err,
+			})
+			return
+		}
 		r.write(ctx, response.StatusCode, bytes)
+		if trailers {
+			for k, tv := range response.Trailers {
+				for _, v := range tv {
+					header.Add(k, v)
+				}
+			}
+		}
 	}
 }
 
@@ -76,6 +128,11 @@ func (r *DefaultTransportWriter) WriteError(ctx *flux.Context, err *flux.ServeEr
 		"message": err.Message,
 		"error":   cast.ToString(err.CauseError),
 	})
+	if out, herr := DoWriteHooks(ctx, err.StatusCode, bytes); nil == herr {
+		bytes = out
+	} else {
+		ctx.Logger().Errorw("TRANSPORTER:WRITE_HOOK/ERROR", "error", herr)
+	}
 	r.write(ctx, err.StatusCode, bytes)
 }
 
@@ -88,3 +145,15 @@ func (r *DefaultTransportWriter) write(ctx *flux.Context, status int, body []byt
 		ctx.Logger().Infow("TRANSPORT:WRITE:COMPLETED", "body", string(body))
 	}
 }
+
+// DoWriteHooks 按顺序执行请求范围的响应写入处理函数，返回实际写入客户端的数据；处理函数返回错误时终止执行
+func DoWriteHooks(ctx *flux.Context, status int, body []byte) ([]byte, error) {
+	for _, hook := range ctx.WriteHooks() {
+		out, err := hook(ctx, status, body)
+		if nil != err {
+			return nil, err
+		}
+		body = out
+	}
+	return body, nil
+}
@@ -0,0 +1,97 @@
+package wasm
+
+import (
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/bytepowered/flux/flux-node/transporter"
+	fluxwasm "github.com/bytepowered/flux/flux-node/wasm"
+)
+
+const (
+	// Service属性：处理请求的插件名称，对应 wasm.plugins.<name>；未设置时使用 Interface
+	ServiceAttrTagWasmPlugin = "wasmplugin"
+)
+
+const (
+	ErrorCodeWasmNoResponse = "WASM:NO_RESPONSE"
+)
+
+func init() {
+	ext.RegisterTransporterFactory(flux.ProtoWasm, func() interface{} {
+		return NewTransporter()
+	})
+}
+
+var (
+	_ flux.Transporter = new(RpcTransporter)
+)
+
+// RpcTransporter Wasm插件网关服务：由Wasm插件处理请求，插件通过 Host.SendResponse 设置响应
+type RpcTransporter struct {
+	writer flux.TransportWriter
+}
+
+func NewTransporter() flux.Transporter {
+	return &RpcTransporter{
+		writer: new(transporter.DefaultTransportWriter),
+	}
+}
+
+func (b *RpcTransporter) Writer() flux.TransportWriter {
+	return b.writer
+}
+
+func (b *RpcTransporter) Transport(ctx *flux.Context) {
+	transporter.DoTransport(ctx, b)
+}
+
+func (b *RpcTransporter) InvokeCodec(ctx *flux.Context, service flux.TransporterService) (*flux.ResponseBody, *flux.ServeError) {
+	value, serr := b.Invoke(ctx, service)
+	if nil != serr {
+		return nil, serr
+	}
+	response := value.(*fluxwasm.LocalResponse)
+	return &flux.ResponseBody{
+		StatusCode: response.StatusCode,
+		Headers:    response.Header,
+		Body:       response.Body,
+	}, nil
+}
+
+// Invoke 使用插件处理请求，返回插件设置的 *wasm.LocalResponse
+func (b *RpcTransporter) Invoke(ctx *flux.Context, service flux.TransporterService) (interface{}, *flux.ServeError) {
+	name := service.GetAttr(ServiceAttrTagWasmPlugin).GetString()
+	if name == "" {
+		name = service.Interface
+	}
+	plugin, err := fluxwasm.LookupPlugin(name)
+	if nil != err {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  fluxwasm.ErrorCodeWasmPluginError,
+			Message:    "WASM:PLUGIN:UNAVAILABLE",
+			CauseError: err,
+		}
+	}
+	_, response, err := plugin.Handle(ctx)
+	if nil != err {
+		logger.TraceContext(ctx).Warnw("TRANSPORTER:WASM:PLUGIN/ERROR", "plugin", name, "error", err)
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  fluxwasm.ErrorCodeWasmPluginError,
+			Message:    "WASM:PLUGIN:ERROR",
+			CauseError: err,
+		}
+	}
+	if nil == response {
+		return nil, &flux.ServeError{
+			StatusCode: flux.StatusBadGateway,
+			ErrorCode:  ErrorCodeWasmNoResponse,
+			Message:    "WASM:PLUGIN:NO_RESPONSE",
+			CauseError: fmt.Errorf("wasm plugin sent no response, plugin: %s", name),
+		}
+	}
+	return response, nil
+}
@@ -0,0 +1,61 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Action 插件处理请求后的动作
+type Action int
+
+const (
+	// ActionContinue 继续执行后续的处理
+	ActionContinue Action = iota
+	// ActionStop 停止后续处理；插件已通过 Host.SendResponse 设置本地响应
+	ActionStop
+)
+
+var (
+	ErrEngineNotSet = errors.New("wasm engine not set, use wasm.SetEngine to set a runtime")
+)
+
+var (
+	engine   Engine
+	engineMu sync.RWMutex
+)
+
+// Engine WebAssembly运行时；由使用方基于具体的运行时（例如 wazero，wasmtime）及插件ABI实现
+type Engine interface {
+	// Compile 编译Wasm模块
+	Compile(name string, binary []byte) (Module, error)
+}
+
+// Module 已编译的Wasm模块
+type Module interface {
+	// NewInstance 创建隔离内存的模块实例；config 为插件配置（JSON），对应 proxy-wasm 的 on_configure
+	NewInstance(config []byte) (Instance, error)
+	Close() error
+}
+
+// Instance Wasm模块实例；同一实例不会被并发调用
+type Instance interface {
+	// OnRequest 处理请求，对应 proxy-wasm 的 on_http_request_headers/body；插件通过Host宿主函数访问请求上下文；
+	// ctx 超时或取消时，运行时应中断执行
+	OnRequest(ctx context.Context, host Host) (Action, error)
+	Close() error
+}
+
+// SetEngine 设置WebAssembly运行时
+func SetEngine(e Engine) {
+	engineMu.Lock()
+	engine = e
+	engineMu.Unlock()
+}
+
+// GetEngine 返回WebAssembly运行时；未设置时返回nil
+func GetEngine() Engine {
+	engineMu.RLock()
+	defer engineMu.RUnlock()
+	return engine
+}
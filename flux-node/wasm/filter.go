@@ -0,0 +1,116 @@
+package wasm
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+)
+
+const (
+	// 动态Filter的类型标识：filter.<id>.typeId
+	TypeIdWasmFilter = "WasmFilter"
+)
+
+const (
+	// Filter配置：插件名称，对应 wasm.plugins.<name>
+	ConfigKeyPlugin = "plugin"
+	// Filter配置：FilterId，默认为 wasm:<plugin>
+	ConfigKeyFilterId = "filter_id"
+	// Filter配置：插件执行失败时继续处理请求，默认拒绝请求
+	ConfigKeyFailOpen = "fail_open"
+)
+
+const (
+	ErrorCodeWasmPluginError = "WASM:PLUGIN_ERROR"
+)
+
+func init() {
+	ext.RegisterFactory(TypeIdWasmFilter, func() interface{} {
+		return new(WasmFilter)
+	})
+	ext.AddHookFunc(plugins)
+}
+
+var (
+	_ flux.Filter      = new(WasmFilter)
+	_ flux.Initializer = new(WasmFilter)
+)
+
+// WasmFilter 使用Wasm插件处理请求的动态Filter；插件返回 ActionStop 时输出插件设置的本地响应，不再执行后续处理
+type WasmFilter struct {
+	id       string
+	plugin   *Plugin
+	failOpen bool
+}
+
+func (f *WasmFilter) Init(config *flux.Configuration) error {
+	name := config.GetString(ConfigKeyPlugin)
+	plugin, err := LookupPlugin(name)
+	if nil != err {
+		return err
+	}
+	f.plugin = plugin
+	f.failOpen = config.GetBool(ConfigKeyFailOpen)
+	f.id = config.GetString(ConfigKeyFilterId)
+	if f.id == "" {
+		f.id = "wasm:" + name
+	}
+	logger.Infow("WasmFilter init", "filter-id", f.id, "plugin", name, "fail-open", f.failOpen)
+	return nil
+}
+
+func (f *WasmFilter) FilterId() string {
+	return f.id
+}
+
+func (f *WasmFilter) DoFilter(next flux.FilterInvoker) flux.FilterInvoker {
+	return func(ctx *flux.Context) *flux.ServeError {
+		action, response, err := f.plugin.Handle(ctx)
+		if nil != err {
+			logger.TraceContext(ctx).Warnw("FILTER:WASM:PLUGIN/ERROR", "plugin", f.plugin.Name(), "error", err)
+			if f.failOpen {
+				return next(ctx)
+			}
+			return &flux.ServeError{
+				StatusCode: flux.StatusServerError,
+				ErrorCode:  ErrorCodeWasmPluginError,
+				Message:    "WASM:PLUGIN:ERROR",
+				CauseError: err,
+			}
+		}
+		if action == ActionContinue {
+			return next(ctx)
+		}
+		if nil == response {
+			return &flux.ServeError{
+				StatusCode: flux.StatusAccessDenied,
+				ErrorCode:  flux.ErrorCodePermissionDenied,
+				Message:    "WASM:PLUGIN:REJECTED",
+			}
+		}
+		return WriteLocalResponse(ctx, response)
+	}
+}
+
+// WriteLocalResponse 输出插件设置的本地响应
+func WriteLocalResponse(ctx *flux.Context, response *LocalResponse) *flux.ServeError {
+	header := ctx.ResponseWriter().Header()
+	for name, values := range response.Header {
+		for _, v := range values {
+			header.Add(name, v)
+		}
+	}
+	contentType := response.Header.Get(flux.HeaderContentType)
+	if contentType == "" {
+		contentType = flux.MIMEApplicationJSONCharsetUTF8
+	}
+	if err := ctx.Write(response.StatusCode, contentType, response.Body); nil != err {
+		return &flux.ServeError{
+			StatusCode: flux.StatusServerError,
+			ErrorCode:  flux.ErrorCodeGatewayInternal,
+			Message:    flux.ErrorMessageTransportWriteResponse,
+			CauseError: err,
+		}
+	}
+	return nil
+}
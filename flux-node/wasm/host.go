@@ -0,0 +1,107 @@
+package wasm
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/spf13/cast"
+	"io/ioutil"
+	"net/http"
+)
+
+// Host 插件可调用的宿主函数，对应 proxy-wasm 的 get/set_header_map_value，get_buffer_bytes，send_local_response 等
+type Host interface {
+	RequestMethod() string
+	RequestPath() string
+	RequestHeader(name string) string
+	SetRequestHeader(name, value string)
+	RemoveRequestHeader(name string)
+	QueryVar(name string) string
+	PathVar(name string) string
+	RequestBody() ([]byte, error)
+	// Attribute 读取请求上下文的属性，例如认证过滤器设置的用户信息
+	Attribute(name string) string
+	SetAttribute(name, value string)
+	// SendResponse 设置本地响应；过滤器阶段需同时返回 ActionStop
+	SendResponse(status int, header http.Header, body []byte)
+	Log(level, message string)
+}
+
+// LocalResponse 插件设置的本地响应
+type LocalResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+var _ Host = new(contextHost)
+
+type contextHost struct {
+	plugin   string
+	ctx      *flux.Context
+	response *LocalResponse
+}
+
+func (h *contextHost) RequestMethod() string {
+	return h.ctx.Method()
+}
+
+func (h *contextHost) RequestPath() string {
+	return h.ctx.URL().Path
+}
+
+func (h *contextHost) RequestHeader(name string) string {
+	return h.ctx.HeaderVar(name)
+}
+
+func (h *contextHost) SetRequestHeader(name, value string) {
+	h.ctx.Request().Header.Set(name, value)
+}
+
+func (h *contextHost) RemoveRequestHeader(name string) {
+	h.ctx.Request().Header.Del(name)
+}
+
+func (h *contextHost) QueryVar(name string) string {
+	return h.ctx.QueryVar(name)
+}
+
+func (h *contextHost) PathVar(name string) string {
+	return h.ctx.PathVar(name)
+}
+
+func (h *contextHost) RequestBody() ([]byte, error) {
+	reader, err := h.ctx.BodyReader()
+	if nil != err {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+func (h *contextHost) Attribute(name string) string {
+	value, _ := h.ctx.GetAttribute(name)
+	return cast.ToString(value)
+}
+
+func (h *contextHost) SetAttribute(name, value string) {
+	h.ctx.SetAttribute(name, value)
+}
+
+func (h *contextHost) SendResponse(status int, header http.Header, body []byte) {
+	if nil == header {
+		header = make(http.Header, 0)
+	}
+	h.response = &LocalResponse{StatusCode: status, Header: header, Body: body}
+}
+
+func (h *contextHost) Log(level, message string) {
+	log := logger.TraceContext(h.ctx)
+	switch level {
+	case "error":
+		log.Errorw("WASM:PLUGIN:LOG", "plugin", h.plugin, "message", message)
+	case "warn":
+		log.Warnw("WASM:PLUGIN:LOG", "plugin", h.plugin, "message", message)
+	default:
+		log.Infow("WASM:PLUGIN:LOG", "plugin", h.plugin, "message", message)
+	}
+}
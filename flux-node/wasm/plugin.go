@@ -0,0 +1,336 @@
+package wasm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/fsnotify/fsnotify"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// Wasm插件配置：plugins.<name>：module，pool_size，timeout，config，watch
+	ConfigNsWasm = "wasm"
+)
+
+const (
+	ConfigKeyModule   = "module"
+	ConfigKeyPoolSize = "pool_size"
+	ConfigKeyTimeout  = "timeout"
+	ConfigKeyConfig   = "config"
+	ConfigKeyWatch    = "watch"
+)
+
+var (
+	plugins = &registry{plugins: make(map[string]*Plugin, 4)}
+)
+
+// LookupPlugin 返回指定名称的插件；首次查找时按配置 wasm.plugins.<name> 加载插件
+func LookupPlugin(name string) (*Plugin, error) {
+	return plugins.lookup(name)
+}
+
+type registry struct {
+	plugins map[string]*Plugin
+	mu      sync.Mutex
+}
+
+// Shutdown 关闭全部已加载的插件
+func (r *registry) Shutdown(_ context.Context) error {
+	r.close()
+	return nil
+}
+
+func (r *registry) lookup(name string) (*Plugin, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if p, ok := r.plugins[name]; ok {
+		return p, nil
+	}
+	p, err := NewPlugin(name, flux.NewConfigurationOfNS(ConfigNsWasm+".plugins."+name))
+	if nil != err {
+		return nil, err
+	}
+	r.plugins[name] = p
+	return p, nil
+}
+
+func (r *registry) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, p := range r.plugins {
+		p.Close()
+		delete(r.plugins, name)
+	}
+}
+
+// Plugin Wasm插件：维护模块实例池；开启 watch 时，模块文件更新后重新编译并替换模块，
+// 处理中的请求继续使用旧模块的实例，旧模块在全部实例归还后关闭。
+type Plugin struct {
+	name     string
+	path     string
+	config   []byte
+	poolSize int
+	timeout  time.Duration
+	current  *generation
+	slots    chan struct{}
+	watcher  *fsnotify.Watcher
+	mu       sync.RWMutex
+}
+
+// generation 一个版本的模块及其实例池
+type generation struct {
+	module   Module
+	pool     chan Instance
+	inflight int
+	retired  bool
+	mu       sync.Mutex
+}
+
+func NewPlugin(name string, config *flux.Configuration) (*Plugin, error) {
+	config.SetDefaults(map[string]interface{}{
+		ConfigKeyPoolSize: 4,
+		ConfigKeyTimeout:  time.Millisecond * 100,
+	})
+	path := config.GetString(ConfigKeyModule)
+	if path == "" {
+		return nil, fmt.Errorf("wasm plugin config(module) is required, plugin: %s", name)
+	}
+	conf, err := json.Marshal(common.ToJSONValue(config.Get(ConfigKeyConfig)))
+	if nil != err {
+		return nil, fmt.Errorf("wasm plugin config(config) is invalid, plugin: %s, err: %w", name, err)
+	}
+	size := config.GetInt(ConfigKeyPoolSize)
+	if size <= 0 {
+		return nil, fmt.Errorf("wasm plugin config(pool_size) must be positive, plugin: %s, pool-size: %d", name, size)
+	}
+	p := &Plugin{
+		name:     name,
+		path:     path,
+		config:   conf,
+		poolSize: size,
+		timeout:  config.GetDuration(ConfigKeyTimeout),
+		slots:    make(chan struct{}, size),
+	}
+	if err := p.Reload(); nil != err {
+		return nil, err
+	}
+	if config.GetBool(ConfigKeyWatch) {
+		if err := p.watch(); nil != err {
+			p.Close()
+			return nil, err
+		}
+	}
+	logger.Infow("WASM:PLUGIN:LOADED", "plugin", name, "module", path, "pool-size", p.poolSize, "timeout", p.timeout)
+	return p, nil
+}
+
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+// Reload 重新读取和编译模块文件，替换当前模块
+func (p *Plugin) Reload() error {
+	engine := GetEngine()
+	if nil == engine {
+		return ErrEngineNotSet
+	}
+	binary, err := ioutil.ReadFile(p.path)
+	if nil != err {
+		return fmt.Errorf("read wasm module, plugin: %s, path: %s, err: %w", p.name, p.path, err)
+	}
+	module, err := engine.Compile(p.name, binary)
+	if nil != err {
+		return fmt.Errorf("compile wasm module, plugin: %s, path: %s, err: %w", p.name, p.path, err)
+	}
+	// 创建实例以校验模块及插件配置
+	instance, err := module.NewInstance(p.config)
+	if nil != err {
+		_ = module.Close()
+		return fmt.Errorf("instantiate wasm module, plugin: %s, err: %w", p.name, err)
+	}
+	next := &generation{module: module, pool: make(chan Instance, p.poolSize)}
+	next.pool <- instance
+	p.mu.Lock()
+	prev := p.current
+	p.current = next
+	p.mu.Unlock()
+	if nil != prev {
+		prev.retire()
+	}
+	return nil
+}
+
+// Handle 使用插件处理请求；返回插件设置的本地响应
+func (p *Plugin) Handle(ctx *flux.Context) (Action, *LocalResponse, error) {
+	toctx, cancel := context.WithTimeout(ctx.Context(), p.timeout)
+	defer cancel()
+	gen, instance, err := p.acquire(toctx)
+	if nil != err {
+		return ActionContinue, nil, err
+	}
+	host := &contextHost{plugin: p.name, ctx: ctx}
+	action, err := p.call(toctx, instance, host)
+	// 执行失败的实例状态未知，不再复用
+	gen.release(instance, nil == err)
+	<-p.slots
+	if nil != err {
+		return ActionContinue, nil, err
+	}
+	return action, host.response, nil
+}
+
+func (p *Plugin) call(ctx context.Context, instance Instance, host Host) (action Action, err error) {
+	defer func() {
+		if rvr := recover(); nil != rvr {
+			err = fmt.Errorf("wasm plugin panic: %v", rvr)
+		}
+	}()
+	action, err = instance.OnRequest(ctx, host)
+	if nil == err && nil != ctx.Err() {
+		err = ctx.Err()
+	}
+	return action, err
+}
+
+// acquire 获取模块实例；同时使用的实例数量不超过 pool_size，实例全部被占用时等待直至超时
+func (p *Plugin) acquire(ctx context.Context) (*generation, Instance, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, nil, fmt.Errorf("wasm plugin busy, plugin: %s, pool-size: %d, err: %w", p.name, p.poolSize, ctx.Err())
+	}
+	var gen *generation
+	for {
+		p.mu.RLock()
+		gen = p.current
+		p.mu.RUnlock()
+		if nil == gen {
+			<-p.slots
+			return nil, nil, fmt.Errorf("wasm plugin closed, plugin: %s", p.name)
+		}
+		gen.mu.Lock()
+		// 模块已被替换时，重新获取当前模块
+		if !gen.retired {
+			gen.inflight++
+			gen.mu.Unlock()
+			break
+		}
+		gen.mu.Unlock()
+	}
+	select {
+	case instance := <-gen.pool:
+		return gen, instance, nil
+	default:
+		instance, err := gen.module.NewInstance(p.config)
+		if nil != err {
+			gen.release(nil, false)
+			<-p.slots
+			return nil, nil, fmt.Errorf("instantiate wasm module, plugin: %s, err: %w", p.name, err)
+		}
+		return gen, instance, nil
+	}
+}
+
+// release 归还实例；已替换的模块在最后一个实例归还后关闭
+func (g *generation) release(instance Instance, reuse bool) {
+	g.mu.Lock()
+	g.inflight--
+	retired, idle := g.retired, g.inflight == 0
+	g.mu.Unlock()
+	if nil != instance {
+		if !reuse || retired {
+			_ = instance.Close()
+		} else {
+			select {
+			case g.pool <- instance:
+			default:
+				_ = instance.Close()
+			}
+		}
+	}
+	if retired && idle {
+		g.close()
+	}
+}
+
+func (g *generation) retire() {
+	g.mu.Lock()
+	g.retired = true
+	idle := g.inflight == 0
+	g.mu.Unlock()
+	if idle {
+		g.close()
+	}
+}
+
+func (g *generation) close() {
+	for {
+		select {
+		case instance := <-g.pool:
+			_ = instance.Close()
+		default:
+			_ = g.module.Close()
+			return
+		}
+	}
+}
+
+// watch 监听模块文件的变更，重新加载模块；加载失败时继续使用当前模块
+func (p *Plugin) watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if nil != err {
+		return err
+	}
+	// 监听目录，支持以替换文件的方式更新模块
+	if err := watcher.Add(filepath.Dir(p.path)); nil != err {
+		_ = watcher.Close()
+		return fmt.Errorf("watch wasm module, plugin: %s, path: %s, err: %w", p.name, p.path, err)
+	}
+	p.watcher = watcher
+	target := filepath.Clean(p.path)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != target || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+					continue
+				}
+				if err := p.Reload(); nil != err {
+					logger.Warnw("WASM:PLUGIN:RELOAD/ERROR", "plugin", p.name, "error", err)
+				} else {
+					logger.Infow("WASM:PLUGIN:RELOADED", "plugin", p.name, "module", p.path)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warnw("WASM:PLUGIN:WATCH/ERROR", "plugin", p.name, "error", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// Close 停止监听模块文件，关闭当前模块
+func (p *Plugin) Close() {
+	if nil != p.watcher {
+		_ = p.watcher.Close()
+	}
+	p.mu.Lock()
+	gen := p.current
+	p.current = nil
+	p.mu.Unlock()
+	if nil != gen {
+		gen.retire()
+	}
+}
@@ -0,0 +1,169 @@
+package wasm
+
+import (
+	"context"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	assert2 "github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeEngine struct {
+	compiled int32
+}
+
+func (e *fakeEngine) Compile(name string, binary []byte) (Module, error) {
+	atomic.AddInt32(&e.compiled, 1)
+	return &fakeModule{version: string(binary)}, nil
+}
+
+type fakeModule struct {
+	version   string
+	closed    int32
+	instances int32
+}
+
+func (m *fakeModule) NewInstance(config []byte) (Instance, error) {
+	atomic.AddInt32(&m.instances, 1)
+	return &fakeInstance{module: m}, nil
+}
+
+func (m *fakeModule) Close() error {
+	atomic.StoreInt32(&m.closed, 1)
+	return nil
+}
+
+type fakeInstance struct {
+	module *fakeModule
+}
+
+func (i *fakeInstance) OnRequest(ctx context.Context, host Host) (Action, error) {
+	if host.RequestHeader("X-Deny") != "" {
+		host.SendResponse(http.StatusForbidden, nil, []byte(i.module.version))
+		return ActionStop, nil
+	}
+	host.SetRequestHeader("X-Plugin-Version", i.module.version)
+	return ActionContinue, nil
+}
+
+func (i *fakeInstance) Close() error {
+	return nil
+}
+
+func newPluginContext(header string) *flux.Context {
+	req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+	if header != "" {
+		req.Header.Set("X-Deny", header)
+	}
+	ctx := flux.NewContext()
+	ctx.Reset(common.MockRequestContext("r1", req, nil, nil), &flux.Endpoint{})
+	return ctx
+}
+
+func TestPlugin_HandleAndReload(t *testing.T) {
+	assert := assert2.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	engine := new(fakeEngine)
+	SetEngine(engine)
+	defer SetEngine(nil)
+	dir, err := ioutil.TempDir("", "wasm")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "plugin.wasm")
+	assert.NoError(ioutil.WriteFile(path, []byte("v1"), 0644))
+
+	config := flux.NewConfigurationOfMap(map[string]interface{}{ConfigKeyModule: path})
+	plugin, err := NewPlugin("test", config)
+	assert.NoError(err)
+	defer plugin.Close()
+
+	ctx := newPluginContext("")
+	action, response, err := plugin.Handle(ctx)
+	assert.NoError(err)
+	assert.Equal(ActionContinue, action)
+	assert.Nil(response)
+	assert.Equal("v1", ctx.Request().Header.Get("X-Plugin-Version"))
+
+	first := plugin.current.module.(*fakeModule)
+	assert.NoError(ioutil.WriteFile(path, []byte("v2"), 0644))
+	assert.NoError(plugin.Reload())
+	assert.Equal(int32(1), atomic.LoadInt32(&first.closed), "retired idle module must be closed")
+
+	action, response, err = plugin.Handle(newPluginContext("yes"))
+	assert.NoError(err)
+	assert.Equal(ActionStop, action)
+	assert.Equal(http.StatusForbidden, response.StatusCode)
+	assert.Equal("v2", string(response.Body))
+	assert.Equal(int32(2), atomic.LoadInt32(&engine.compiled))
+}
+
+func TestPlugin_EngineNotSet(t *testing.T) {
+	assert := assert2.New(t)
+	SetEngine(nil)
+	_, err := NewPlugin("test", flux.NewConfigurationOfMap(map[string]interface{}{ConfigKeyModule: "none.wasm"}))
+	assert.Equal(ErrEngineNotSet, err)
+}
+
+func TestPlugin_PoolSizeBound(t *testing.T) {
+	assert := assert2.New(t)
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	SetEngine(new(fakeEngine))
+	defer SetEngine(nil)
+	dir, err := ioutil.TempDir("", "wasm")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "plugin.wasm")
+	assert.NoError(ioutil.WriteFile(path, []byte("v1"), 0644))
+
+	plugin, err := NewPlugin("test", flux.NewConfigurationOfMap(map[string]interface{}{
+		ConfigKeyModule:   path,
+		ConfigKeyPoolSize: 2,
+		ConfigKeyTimeout:  "20ms",
+	}))
+	assert.NoError(err)
+	defer plugin.Close()
+
+	// 实例全部被占用时，等待直至超时，不创建新实例
+	first, i1, err := plugin.acquire(context.Background())
+	assert.NoError(err)
+	second, i2, err := plugin.acquire(context.Background())
+	assert.NoError(err)
+	_, _, err = plugin.Handle(newPluginContext(""))
+	assert.Error(err)
+	module := plugin.current.module.(*fakeModule)
+	assert.Equal(int32(2), atomic.LoadInt32(&module.instances))
+
+	// 归还实例后，等待中的请求获取实例
+	done := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		gen, instance, err := plugin.acquire(ctx)
+		if nil == err {
+			gen.release(instance, true)
+			<-plugin.slots
+		}
+		done <- err
+	}()
+	time.Sleep(time.Millisecond * 5)
+	first.release(i1, true)
+	<-plugin.slots
+	assert.NoError(<-done)
+	second.release(i2, true)
+	<-plugin.slots
+	_, _, err = plugin.Handle(newPluginContext(""))
+	assert.NoError(err)
+	assert.Equal(int32(2), atomic.LoadInt32(&module.instances))
+
+	_, err = NewPlugin("test", flux.NewConfigurationOfMap(map[string]interface{}{ConfigKeyModule: path, ConfigKeyPoolSize: 0}))
+	assert.Error(err)
+}
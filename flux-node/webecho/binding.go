@@ -0,0 +1,119 @@
+package webecho
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+const (
+	// 附加监听地址列表：address，bind_port 或 unix，unix_mode，以及与WebListener相同的TLS配置
+	ConfigKeyListeners = "listeners"
+	ConfigKeyUnix      = "unix"
+	ConfigKeyUnixMode  = "unix_mode"
+)
+
+// binding WebListener的附加监听地址（TCP端口或Unix Socket），与主地址服务相同的路由
+type binding struct {
+	network  string
+	address  string
+	unixMode os.FileMode
+	tls      *tlsSettings
+	listener net.Listener
+	server   *http.Server
+}
+
+func newBinding(config *flux.Configuration) (*binding, error) {
+	b := &binding{network: "tcp"}
+	if path := config.GetString(ConfigKeyUnix); path != "" {
+		b.network, b.address = "unix", path
+		if mode := config.GetString(ConfigKeyUnixMode); mode != "" {
+			perm, err := strconv.ParseUint(mode, 8, 32)
+			if nil != err {
+				return nil, fmt.Errorf("config.unix_mode is invalid: %s", mode)
+			}
+			b.unixMode = os.FileMode(perm)
+		}
+	} else {
+		addr, port := config.GetString(ConfigKeyAddress), config.GetString(ConfigKeyBindPort)
+		if addr == "" && port == "" {
+			return nil, errors.New("config.address or config.unix is required")
+		}
+		address, err := common.JoinListenAddress(addr, port)
+		if nil != err {
+			return nil, fmt.Errorf("config.address is invalid: %w", err)
+		}
+		b.address = address
+	}
+	settings, err := loadTLSSettings(config)
+	if nil != err {
+		return nil, err
+	}
+	if nil != settings && nil != settings.acme {
+		return nil, errors.New("config.acme is only supported by the primary address")
+	}
+	b.tls = settings
+	return b, nil
+}
+
+// listen 绑定监听地址并创建服务；Unix Socket文件已存在时（上次进程未正常退出）先删除
+func (b *binding) listen() error {
+	if b.network == "unix" {
+		if info, err := os.Stat(b.address); nil == err && info.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(b.address)
+		}
+	}
+	listener, err := net.Listen(b.network, b.address)
+	if nil != err {
+		return err
+	}
+	if b.network == "unix" && b.unixMode != 0 {
+		if err := os.Chmod(b.address, b.unixMode); nil != err {
+			_ = listener.Close()
+			return err
+		}
+	}
+	b.listener, b.server = listener, &http.Server{}
+	return nil
+}
+
+// serve 使用独立的http.Server服务请求；TLS及HTTP/2配置与主地址一致
+func (b *binding) serve(handler http.Handler, h2 *http2Options) error {
+	listener := b.listener
+	b.server.Handler = handler
+	if nil == b.tls {
+		if h2.h2cEnabled() {
+			return h2.serveH2C(b.server, listener, handler)
+		}
+		return b.server.Serve(listener)
+	}
+	b.server.TLSConfig = b.tls.config()
+	if err := h2.configureTLS(b.server); nil != err {
+		return err
+	}
+	return b.server.ServeTLS(listener, "", "")
+}
+
+func (b *binding) close(ctx context.Context) error {
+	if nil == b.server {
+		return nil
+	}
+	err := b.server.Shutdown(ctx)
+	// 已绑定但未启动服务时，Shutdown不会关闭监听地址
+	_ = b.listener.Close()
+	b.listener, b.server = nil, nil
+	return err
+}
+
+func (b *binding) String() string {
+	if nil != b.listener {
+		return b.network + "://" + b.listener.Addr().String()
+	}
+	return b.network + "://" + b.address
+}
@@ -0,0 +1,119 @@
+package webecho
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/labstack/echo/v4"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// BodyGuard 请求Body读取限制；在读取Body时检查长度，超出限制的请求返回413，不进入路由及参数解析
+type BodyGuard struct {
+	// 原始Body最大长度，0为不限制
+	MaxSize int64
+	// 是否解码 gzip/deflate 编码的请求Body；解码后移除Content-Encoding
+	Decompress bool
+	// 解码后Body最大长度，0为不限制
+	MaxDecompressedSize int64
+	// 解码后与解码前的最大长度比例，用于拒绝压缩炸弹，0为不限制
+	MaxRatio int64
+}
+
+// NewRepeatableReader 创建按BodyGuard限制读取并缓存Body的中间件，允许通过 GetBody 多次读取Body
+func NewRepeatableReader(guard BodyGuard) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(echo echo.Context) error {
+			request := echo.Request()
+			data, err := guard.read(request)
+			if nil != err {
+				return err
+			}
+			request.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewBuffer(data)), nil
+			}
+			// 恢复Body，但ParseForm解析后，request.Body无法重读，需要通过GetBody
+			request.Body = ioutil.NopCloser(bytes.NewBuffer(data))
+			request.ContentLength = int64(len(data))
+			return next(echo)
+		}
+	}
+}
+
+func (g BodyGuard) read(request *http.Request) ([]byte, error) {
+	if g.MaxSize > 0 && request.ContentLength > g.MaxSize {
+		return nil, newTooLargeError(request, fmt.Errorf("content-length: %d, limit: %d", request.ContentLength, g.MaxSize))
+	}
+	data, err := readLimited(request.Body, g.MaxSize)
+	if nil != err {
+		return nil, newPrepareError(request, err)
+	}
+	if g.MaxSize > 0 && int64(len(data)) > g.MaxSize {
+		return nil, newTooLargeError(request, fmt.Errorf("body size exceeds limit: %d", g.MaxSize))
+	}
+	encoding := strings.ToLower(strings.TrimSpace(request.Header.Get(flux.HeaderContentEncoding)))
+	if !g.Decompress || len(data) == 0 || (encoding != "gzip" && encoding != "deflate") {
+		return data, nil
+	}
+	limit := g.MaxDecompressedSize
+	if g.MaxRatio > 0 && (limit <= 0 || int64(len(data))*g.MaxRatio < limit) {
+		limit = int64(len(data)) * g.MaxRatio
+	}
+	decoded, err := decompress(encoding, data, limit)
+	if nil != err {
+		return nil, newPrepareError(request, err)
+	}
+	if limit > 0 && int64(len(decoded)) > limit {
+		return nil, newTooLargeError(request, fmt.Errorf("decompressed body exceeds limit: %d, compressed: %d", limit, len(data)))
+	}
+	request.Header.Del(flux.HeaderContentEncoding)
+	request.Header.Set(flux.HeaderContentLength, strconv.Itoa(len(decoded)))
+	return decoded, nil
+}
+
+// readLimited 最多读取 limit+1 字节，用于判断是否超出限制
+func readLimited(reader io.Reader, limit int64) ([]byte, error) {
+	if limit > 0 {
+		reader = io.LimitReader(reader, limit+1)
+	}
+	return ioutil.ReadAll(reader)
+}
+
+func decompress(encoding string, data []byte, limit int64) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	if encoding == "gzip" {
+		reader, err = gzip.NewReader(bytes.NewReader(data))
+	} else {
+		reader, err = zlib.NewReader(bytes.NewReader(data))
+	}
+	if nil != err {
+		return nil, err
+	}
+	defer reader.Close()
+	return readLimited(reader, limit)
+}
+
+func newPrepareError(request *http.Request, err error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusBadRequest,
+		ErrorCode:  flux.ErrorCodeGatewayInternal,
+		Message:    flux.ErrorMessageRequestPrepare,
+		CauseError: fmt.Errorf("read request body, method: %s, uri:%s, err: %w", request.Method, request.RequestURI, err),
+	}
+}
+
+func newTooLargeError(request *http.Request, err error) *flux.ServeError {
+	return &flux.ServeError{
+		StatusCode: flux.StatusTooLarge,
+		ErrorCode:  flux.ErrorCodeRequestTooLarge,
+		Message:    flux.ErrorMessageRequestTooLarge,
+		CauseError: fmt.Errorf("method: %s, uri:%s, err: %w", request.Method, request.RequestURI, err),
+	}
+}
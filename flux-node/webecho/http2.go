@@ -0,0 +1,64 @@
+package webecho
+
+import (
+	"errors"
+	"github.com/bytepowered/flux/flux-node"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"net"
+	"net/http"
+)
+
+const (
+	// HTTP/2配置：enable，h2c，max_concurrent_streams，max_read_frame_size，idle_timeout
+	ConfigKeyHTTP2 = "http2"
+)
+
+// http2Options WebListener的HTTP/2配置；TLS监听时通过ALPN协商h2，非TLS监听时开启h2c（明文HTTP/2）
+type http2Options struct {
+	Enable bool
+	H2C    bool
+	Server *http2.Server
+}
+
+func newHTTP2Options(config *flux.Configuration) (*http2Options, error) {
+	config.SetDefaults(map[string]interface{}{
+		"max_concurrent_streams": 250,
+		"max_read_frame_size":    1 << 20,
+	})
+	opts := &http2Options{
+		Enable: config.GetBool("enable"),
+		H2C:    config.GetBool("h2c"),
+		Server: &http2.Server{
+			MaxConcurrentStreams: uint32(config.GetInt64("max_concurrent_streams")),
+			MaxReadFrameSize:     uint32(config.GetInt64("max_read_frame_size")),
+			IdleTimeout:          config.GetDuration("idle_timeout"),
+		},
+	}
+	// HTTP/2协议规定的帧大小范围：16K - 16M
+	if size := opts.Server.MaxReadFrameSize; size < 1<<14 || size > 1<<24-1 {
+		return nil, errors.New("http2.max_read_frame_size must be in range [16384, 16777215]")
+	}
+	return opts, nil
+}
+
+// configureTLS 为TLS服务开启HTTP/2协商
+func (o *http2Options) configureTLS(server *http.Server) error {
+	if nil == o || !o.Enable {
+		return nil
+	}
+	return http2.ConfigureServer(server, o.Server)
+}
+
+// serveH2C 以h2c方式启动非TLS服务；同时支持HTTP/1.1，HTTP/1.1 Upgrade及Prior Knowledge方式的h2c请求
+func (o *http2Options) serveH2C(server *http.Server, listener net.Listener, handler http.Handler) error {
+	if o.Server.IdleTimeout == 0 {
+		o.Server.IdleTimeout = server.IdleTimeout
+	}
+	server.Handler = h2c.NewHandler(handler, o.Server)
+	return server.Serve(listener)
+}
+
+func (o *http2Options) h2cEnabled() bool {
+	return nil != o && o.Enable && o.H2C
+}
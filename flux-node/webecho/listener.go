@@ -1,20 +1,21 @@
 package webecho
 
 import (
-	"bytes"
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/common"
 	"github.com/bytepowered/flux/flux-node/ext"
 	"github.com/bytepowered/flux/flux-node/internal"
 	"github.com/bytepowered/flux/flux-node/logger"
 	"github.com/bytepowered/flux/flux-pkg"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	gbytes "github.com/labstack/gommon/bytes"
 	"github.com/labstack/gommon/random"
-	"io"
-	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"runtime/debug"
@@ -22,24 +23,52 @@ import (
 )
 
 const (
-	ConfigKeyAddress     = "address"
-	ConfigKeyBindPort    = "bind_port"
-	ConfigKeyTLSCertFile = "tls_cert_file"
-	ConfigKeyTLSKeyFile  = "tls_key_file"
-	ConfigKeyBodyLimit   = "body_limit"
-	ConfigKeyCORSEnable  = "cors_enable"
-	ConfigKeyCSRFEnable  = "csrf_enable"
-	ConfigKeyFeatures    = "features"
+	ConfigKeyAddress             = "address"
+	ConfigKeyBindPort            = "bind_port"
+	ConfigKeyTLSCertFile         = "tls_cert_file"
+	ConfigKeyTLSKeyFile          = "tls_key_file"
+	ConfigKeyTLSCert             = "tls_cert"
+	ConfigKeyTLSKey              = "tls_key"
+	ConfigKeyTLSReloadInterval   = "tls_reload_interval"
+	ConfigKeyTLSClientCA         = "tls_client_ca"
+	ConfigKeyTLSClientAuth       = "tls_client_auth"
+	ConfigKeyACME                = "acme"
+	ConfigKeyBodyLimit           = "body_limit"
+	ConfigKeyBodyDecompress      = "body_decompress"
+	ConfigKeyBodyDecompressLimit = "body_decompress_limit"
+	ConfigKeyBodyDecompressRatio = "body_decompress_ratio"
+	ConfigKeyCORSEnable          = "cors_enable"
+	ConfigKeyCSRFEnable          = "csrf_enable"
+	ConfigKeyFeatures            = "features"
 )
 
 const (
 	__interContextKeyWebContext = "__server.core.adapted.context#890b1fa9-93ad-4b44-af24-85bcbfe646b4"
 )
 
-var _ flux.WebListener = new(EchoWebListener)
+var (
+	_ flux.WebListener      = new(EchoWebListener)
+	_ flux.ACMEChallenger   = new(EchoWebListener)
+	_ flux.BindableListener = new(EchoWebListener)
+)
 
 func init() {
 	ext.SetWebListenerFactory(NewWebListener)
+	// echo的NotFound，MethodNotAllowed处理函数为全局变量，按请求所属的WebListener分发
+	echo.NotFoundHandler = func(c echo.Context) error {
+		if webex, ok := c.Get(__interContextKeyWebContext).(flux.ServerWebContext); ok {
+			return webex.WebListener().HandleNotfound(webex)
+		}
+		return echo.ErrNotFound
+	}
+	echo.MethodNotAllowedHandler = func(c echo.Context) error {
+		if webex, ok := c.Get(__interContextKeyWebContext).(flux.ServerWebContext); ok {
+			if l, ok := webex.WebListener().(*EchoWebListener); ok && nil != l.notallowed {
+				return l.notallowed(webex)
+			}
+		}
+		return echo.ErrMethodNotAllowed
+	}
 }
 
 func NewWebListener(listenerId string, config *flux.Configuration) flux.WebListener {
@@ -49,7 +78,8 @@ func NewWebListener(listenerId string, config *flux.Configuration) flux.WebListe
 func NewWebListenerWith(listenerId string, options *flux.Configuration, identifier flux.WebRequestIdentifier, mws *AdaptMiddleware) flux.WebListener {
 	fluxpkg.Assert("" != listenerId, "empty <listener-id> in web listener configuration")
 	server := echo.New()
-	server.Pre(RepeatableReader)
+	features := options.Sub(ConfigKeyFeatures)
+	server.Pre(NewRepeatableReader(newBodyGuard(listenerId, features)))
 	server.HideBanner = true
 	server.HidePort = true
 	webListener := &EchoWebListener{
@@ -86,17 +116,7 @@ func NewWebListenerWith(listenerId string, options *flux.Configuration, identifi
 	}
 
 	// Feature
-	features := options.Sub(ConfigKeyFeatures)
-	// 是否设置BodyLimit
-	if limit := features.GetString(ConfigKeyBodyLimit); "" != limit {
-		logger.Infof("WebListener(id:%s), feature BODY-LIMIT: enabled, size= %s", webListener.id, limit)
-		server.Pre(middleware.BodyLimit(limit))
-	}
-	// CORS
-	if enabled := features.GetBool(ConfigKeyCORSEnable); enabled {
-		logger.Infof("WebListener(id:%s), feature CORS: enabled", webListener.id)
-		server.Pre(middleware.CORS())
-	}
+	// CORS：由 server.CORSEngine 按全局配置及Endpoint属性处理，在WebListener初始化后注册为拦截器
 	// CSRF
 	if enabled := features.GetBool(ConfigKeyCSRFEnable); enabled {
 		logger.Infof("WebListener(id:%s), feature CSRF: enabled", webListener.id)
@@ -115,9 +135,13 @@ type EchoWebListener struct {
 	id           string
 	server       *echo.Echo
 	bodyResolver flux.WebBodyResolver
-	tlsCertFile  string
-	tlsKeyFile   string
+	notfound     flux.WebHandler
+	notallowed   flux.WebHandler
+	tls          *tlsSettings
+	http2        *http2Options
 	address      string
+	bound        net.Listener
+	bindings     []*binding
 	isstarted    bool
 }
 
@@ -126,29 +150,144 @@ func (s *EchoWebListener) ListenerId() string {
 }
 
 func (s *EchoWebListener) Init(opts *flux.Configuration) error {
-	s.tlsCertFile = opts.GetString(ConfigKeyTLSCertFile)
-	s.tlsKeyFile = opts.GetString(ConfigKeyTLSKeyFile)
-	addr, port := opts.GetString(ConfigKeyAddress), opts.GetString(ConfigKeyBindPort)
-	if strings.Contains(addr, ":") {
-		s.address = addr
-	} else {
-		s.address = addr + ":" + port
+	settings, err := loadTLSSettings(opts)
+	if nil != err {
+		return fmt.Errorf("web server %w, listener-id: %s", err, s.id)
 	}
-	if s.address == ":" {
+	s.tls = settings
+	if nil != s.tls && nil != s.tls.clientCAs {
+		logger.Infow("WebListener mTLS enabled", "listener-id", s.id, "mode", opts.GetString(ConfigKeyTLSClientAuth))
+	}
+	// HTTP/2
+	h2, err := newHTTP2Options(opts.Sub(ConfigKeyHTTP2))
+	if nil != err {
+		return fmt.Errorf("web server config.http2 is invalid, listener-id: %s, err: %w", s.id, err)
+	}
+	s.http2 = h2
+	if s.http2.Enable {
+		logger.Infow("WebListener HTTP/2 enabled", "listener-id", s.id, "h2c", s.http2.H2C,
+			"max-concurrent-streams", s.http2.Server.MaxConcurrentStreams, "max-read-frame-size", s.http2.Server.MaxReadFrameSize)
+	}
+	// 附加监听地址：多个端口及Unix Socket，服务相同的路由
+	s.bindings = s.bindings[:0]
+	for i, bc := range opts.GetConfigurationSlice(ConfigKeyListeners) {
+		b, err := newBinding(bc)
+		if nil != err {
+			return fmt.Errorf("web server config.listeners[%d] is invalid, listener-id: %s, err: %w", i, s.id, err)
+		}
+		s.bindings = append(s.bindings, b)
+	}
+	addr, port := opts.GetString(ConfigKeyAddress), opts.GetString(ConfigKeyBindPort)
+	if addr == "" && port == "" {
+		if len(s.bindings) > 0 {
+			// 只监听附加地址，例如Sidecar部署只监听Unix Socket
+			s.address = ""
+			fluxpkg.AssertNotNil(s.bodyResolver, "<body-resolver> is required, listener-id: "+s.id)
+			return nil
+		}
 		return errors.New("web server config.address is required, was empty, listener-id: " + s.id)
 	}
+	// 支持IPv4，IPv6及双栈监听地址：0.0.0.0，::，[::1]:8080
+	address, err := common.JoinListenAddress(addr, port)
+	if nil != err {
+		return fmt.Errorf("web server config.address is invalid, listener-id: %s, err: %w", s.id, err)
+	}
+	s.address = address
 	fluxpkg.AssertNotNil(s.bodyResolver, "<body-resolver> is required, listener-id: "+s.id)
 	return nil
 }
 
+// Bind 绑定主地址及附加地址；任一地址绑定失败时，关闭已绑定的地址
+func (s *EchoWebListener) Bind() error {
+	if nil != s.bound {
+		return nil
+	}
+	for _, b := range s.bindings {
+		if nil != b.listener {
+			continue
+		}
+		if err := b.listen(); nil != err {
+			_ = s.closeBindings(context.Background())
+			return fmt.Errorf("web server bind: %s, listener-id: %s, err: %w", b, s.id, err)
+		}
+	}
+	if s.address == "" {
+		return nil
+	}
+	listener, err := net.Listen("tcp", s.address)
+	if nil != err {
+		_ = s.closeBindings(context.Background())
+		return fmt.Errorf("web server bind: %s, listener-id: %s, err: %w", s.address, s.id, err)
+	}
+	s.bound = listener
+	return nil
+}
+
+// BoundAddrs 返回已绑定的监听地址
+func (s *EchoWebListener) BoundAddrs() []string {
+	out := make([]string, 0, len(s.bindings)+1)
+	if nil != s.bound {
+		out = append(out, s.bound.Addr().String())
+	}
+	for _, b := range s.bindings {
+		if nil != b.listener {
+			out = append(out, b.String())
+		}
+	}
+	return out
+}
+
 func (s *EchoWebListener) Listen() error {
-	logger.Infof("WebListener(id:%s) start listen: %s", s.id, s.address)
+	if err := s.Bind(); nil != err {
+		return err
+	}
 	s.isstarted = true
-	if "" != s.tlsCertFile && "" != s.tlsKeyFile {
-		return s.server.StartTLS(s.address, s.tlsCertFile, s.tlsKeyFile)
-	} else {
+	errch := make(chan error, len(s.bindings))
+	for _, b := range s.bindings {
+		logger.Infof("WebListener(id:%s) start listen: %s", s.id, b)
+		go func(b *binding) {
+			errch <- b.serve(s.server, s.http2)
+		}(b)
+	}
+	if nil == s.bound {
+		return <-errch
+	}
+	logger.Infof("WebListener(id:%s) start listen: %s", s.id, s.bound.Addr())
+	if nil == s.tls {
+		if s.http2.h2cEnabled() {
+			return s.http2.serveH2C(s.server.Server, s.bound, s.server)
+		}
+		s.server.Listener = s.bound
 		return s.server.Start(s.address)
 	}
+	s.server.TLSServer.Addr = s.address
+	s.server.TLSServer.TLSConfig = s.tls.config()
+	if err := s.http2.configureTLS(s.server.TLSServer); nil != err {
+		return err
+	}
+	s.server.TLSListener = tls.NewListener(s.bound, s.server.TLSServer.TLSConfig)
+	return s.server.StartServer(s.server.TLSServer)
+}
+
+// ACMEChallengeHandler 返回ACME HTTP-01验证请求的处理函数；请求域名不属于本WebListener的证书域名时，交由 next 处理；
+// 未开启ACME时返回false
+func (s *EchoWebListener) ACMEChallengeHandler(next http.Handler) (http.Handler, bool) {
+	if nil == s.tls || nil == s.tls.acme {
+		return next, false
+	}
+	manager := s.tls.acme
+	challenge := manager.HTTPHandler(nil)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); nil == err {
+			host = h
+		}
+		if nil == manager.HostPolicy || nil == manager.HostPolicy(r.Context(), host) {
+			challenge.ServeHTTP(w, r)
+		} else {
+			next.ServeHTTP(w, r)
+		}
+	}), true
 }
 
 func (s *EchoWebListener) SetBodyResolver(r flux.WebBodyResolver) {
@@ -158,12 +297,19 @@ func (s *EchoWebListener) SetBodyResolver(r flux.WebBodyResolver) {
 
 func (s *EchoWebListener) SetNotfoundHandler(f flux.WebHandler) {
 	fluxpkg.AssertNotNil(f, "NotfoundHandler must not nil, listener-id: "+s.id)
-	s.mustNotStarted()
-	echo.NotFoundHandler = EchoWebHandler(f).AdaptFunc
+	s.mustNotStarted().notfound = f
 }
 
 func (s *EchoWebListener) HandleNotfound(webex flux.ServerWebContext) error {
-	return echo.NotFoundHandler(webex.(*internal.EchoWebContext).ShadowContext())
+	if nil == s.notfound {
+		return echo.ErrNotFound
+	}
+	return s.notfound(webex)
+}
+
+func (s *EchoWebListener) SetMethodNotAllowedHandler(f flux.WebHandler) {
+	fluxpkg.AssertNotNil(f, "MethodNotAllowedHandler must not nil, listener-id: "+s.id)
+	s.mustNotStarted().notallowed = f
 }
 
 func (s *EchoWebListener) SetErrorHandler(handler flux.WebErrorHandler) {
@@ -230,7 +376,26 @@ func (s *EchoWebListener) ShadowServer() interface{} {
 
 func (s *EchoWebListener) Close(ctx context.Context) error {
 	s.isstarted = false
-	return s.server.Shutdown(ctx)
+	if err := s.closeBindings(ctx); nil != err {
+		logger.Warnw("WebListener close bindings", "listener-id", s.id, "error", err)
+	}
+	err := s.server.Shutdown(ctx)
+	if nil != s.bound {
+		// 已绑定但未启动服务时，Shutdown不会关闭监听地址
+		_ = s.bound.Close()
+		s.bound = nil
+	}
+	return err
+}
+
+func (s *EchoWebListener) closeBindings(ctx context.Context) error {
+	var last error
+	for _, b := range s.bindings {
+		if err := b.close(ctx); nil != err {
+			last = err
+		}
+	}
+	return last
 }
 
 func (s *EchoWebListener) mustNotStarted() *EchoWebListener {
@@ -266,25 +431,33 @@ func DefaultIdentifier(ctx interface{}) string {
 
 // Body缓存，允许通过 GetBody 多次读取Body
 func RepeatableReader(next echo.HandlerFunc) echo.HandlerFunc {
-	// 包装Http处理错误，统一由HttpErrorHandler处理
-	return func(echo echo.Context) error {
-		request := echo.Request()
-		data, err := ioutil.ReadAll(request.Body)
-		if nil != err {
-			return &flux.ServeError{
-				StatusCode: flux.StatusBadRequest,
-				ErrorCode:  flux.ErrorCodeGatewayInternal,
-				Message:    flux.ErrorMessageRequestPrepare,
-				CauseError: fmt.Errorf("read request body, method: %s, uri:%s, err: %w", request.Method, request.RequestURI, err),
-			}
-		}
-		request.GetBody = func() (io.ReadCloser, error) {
-			return ioutil.NopCloser(bytes.NewBuffer(data)), nil
-		}
-		// 恢复Body，但ParseForm解析后，request.Body无法重读，需要通过GetBody
-		request.Body = ioutil.NopCloser(bytes.NewBuffer(data))
-		return next(echo)
+	return NewRepeatableReader(BodyGuard{})(next)
+}
+
+// newBodyGuard 按Feature配置创建Body读取限制；BodyLimit在读取Body时检查，避免读取超大Body到内存
+func newBodyGuard(listenerId string, features *flux.Configuration) BodyGuard {
+	features.SetDefaults(map[string]interface{}{
+		ConfigKeyBodyDecompressLimit: "32M",
+		ConfigKeyBodyDecompressRatio: 100,
+	})
+	guard := BodyGuard{
+		Decompress: features.GetBool(ConfigKeyBodyDecompress),
+		MaxRatio:   features.GetInt64(ConfigKeyBodyDecompressRatio),
+	}
+	if limit := features.GetString(ConfigKeyBodyLimit); "" != limit {
+		size, err := gbytes.Parse(limit)
+		fluxpkg.AssertNil(err, "invalid feature <body_limit>: "+limit+", listener-id: "+listenerId)
+		guard.MaxSize = size
+		logger.Infof("WebListener(id:%s), feature BODY-LIMIT: enabled, size= %s", listenerId, limit)
+	}
+	if guard.Decompress {
+		limit := features.GetString(ConfigKeyBodyDecompressLimit)
+		size, err := gbytes.Parse(limit)
+		fluxpkg.AssertNil(err, "invalid feature <body_decompress_limit>: "+limit+", listener-id: "+listenerId)
+		guard.MaxDecompressedSize = size
+		logger.Infof("WebListener(id:%s), feature BODY-DECOMPRESS: enabled, limit= %s, ratio= %d", listenerId, limit, guard.MaxRatio)
 	}
+	return guard
 }
 
 type AdaptMiddleware struct {
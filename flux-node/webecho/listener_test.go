@@ -0,0 +1,61 @@
+package webecho
+
+import (
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/ext"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestWebListener(id string) *EchoWebListener {
+	l := NewWebListener(id, flux.NewConfigurationOfMap(map[string]interface{}{})).(*EchoWebListener)
+	l.AddHandler(http.MethodGet, "/users", func(webex flux.ServerWebContext) error {
+		return webex.Write(http.StatusOK, flux.MIMEApplicationJSON, []byte(`{}`))
+	})
+	return l
+}
+
+func writeHandler(status int, body string) flux.WebHandler {
+	return func(webex flux.ServerWebContext) error {
+		return webex.Write(status, flux.MIMEApplicationJSON, []byte(body))
+	}
+}
+
+func TestEchoWebListener_NotfoundDispatch(t *testing.T) {
+	ext.SetLoggerFactory(logger.DefaultFactory)
+	// echo的NotFound，MethodNotAllowed处理函数为全局变量，由请求所属的WebListener处理
+	first, second := newTestWebListener("first"), newTestWebListener("second")
+	first.SetNotfoundHandler(writeHandler(http.StatusNotFound, `{"listener":"first"}`))
+	first.SetMethodNotAllowedHandler(writeHandler(http.StatusMethodNotAllowed, `{"listener":"first"}`))
+	second.SetNotfoundHandler(writeHandler(http.StatusNotFound, `{"listener":"second"}`))
+	second.SetMethodNotAllowedHandler(writeHandler(http.StatusMethodNotAllowed, `{"listener":"second"}`))
+	// 未设置处理函数时，返回echo的默认错误
+	unset := newTestWebListener("unset")
+	cases := []struct {
+		listener *EchoWebListener
+		method   string
+		target   string
+		status   int
+		body     string
+	}{
+		{listener: first, method: http.MethodGet, target: "/users", status: http.StatusOK, body: `{}`},
+		{listener: first, method: http.MethodGet, target: "/missing", status: http.StatusNotFound, body: `{"listener":"first"}`},
+		{listener: first, method: http.MethodPost, target: "/users", status: http.StatusMethodNotAllowed, body: `{"listener":"first"}`},
+		{listener: second, method: http.MethodGet, target: "/missing", status: http.StatusNotFound, body: `{"listener":"second"}`},
+		{listener: second, method: http.MethodDelete, target: "/users", status: http.StatusMethodNotAllowed, body: `{"listener":"second"}`},
+		{listener: unset, method: http.MethodGet, target: "/missing", status: http.StatusNotFound},
+		{listener: unset, method: http.MethodPost, target: "/users", status: http.StatusMethodNotAllowed},
+	}
+	for _, tc := range cases {
+		recorder := httptest.NewRecorder()
+		tc.listener.ServeHTTP(recorder, httptest.NewRequest(tc.method, "http://gateway"+tc.target, nil))
+		name := tc.listener.ListenerId() + " " + tc.method + " " + tc.target
+		assert.Equal(t, tc.status, recorder.Code, name)
+		if tc.body != "" {
+			assert.Equal(t, tc.body, recorder.Body.String(), name)
+		}
+	}
+}
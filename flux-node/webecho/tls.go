@@ -0,0 +1,253 @@
+package webecho
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"github.com/bytepowered/flux/flux-node"
+	"github.com/bytepowered/flux/flux-node/logger"
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pemCertificates 从配置读取PEM格式的证书及私钥；每次TLS握手时读取配置，内容变化时重新解析，
+// 配置值引用密钥提供者（${$secret:path#field}）时，证书随密钥轮换自动更新。
+type pemCertificates struct {
+	config  *flux.Configuration
+	certKey string
+	keyKey  string
+	pem     string
+	cert    *tls.Certificate
+	// 最近一次无法使用的配置内容，同一内容只输出一次日志
+	rejected string
+	mu       sync.Mutex
+}
+
+func newPemCertificates(config *flux.Configuration, certKey, keyKey string) *pemCertificates {
+	return &pemCertificates{config: config, certKey: certKey, keyKey: keyKey}
+}
+
+func (p *pemCertificates) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certPEM, cerr := p.config.GetStringE(p.certKey)
+	keyPEM, kerr := p.config.GetStringE(p.keyKey)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if nil != p.cert && p.pem == certPEM+keyPEM {
+		return p.cert, nil
+	}
+	var err error
+	switch {
+	case nil != cerr:
+		err = cerr
+	case nil != kerr:
+		err = kerr
+	case certPEM == "" || keyPEM == "":
+		err = errors.New("tls certificate is empty, config: " + p.certKey)
+	default:
+		cert, perr := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		if nil == perr {
+			p.cert, p.pem = &cert, certPEM+keyPEM
+			return p.cert, nil
+		}
+		err = perr
+	}
+	// 配置暂时为空，读取失败或新证书无效时，继续使用已加载的证书
+	if nil != p.cert {
+		if rejected := certPEM + keyPEM; rejected != p.rejected {
+			p.rejected = rejected
+			logger.Warnw("WEBLISTENER:TLS:PEM/ERROR", "config", p.certKey, "error", err)
+		}
+		return p.cert, nil
+	}
+	return nil, err
+}
+
+// fileCertificates 从文件读取证书及私钥；TLS握手时按检查间隔比较文件修改时间，文件变化时重新加载，证书轮换无需重启服务。
+type fileCertificates struct {
+	certFile  string
+	keyFile   string
+	interval  time.Duration
+	checkedAt time.Time
+	modTime   time.Time
+	cert      *tls.Certificate
+	mu        sync.Mutex
+}
+
+func newFileCertificates(certFile, keyFile string, interval time.Duration) (*fileCertificates, error) {
+	f := &fileCertificates{certFile: certFile, keyFile: keyFile, interval: interval}
+	if err := f.reload(); nil != err {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *fileCertificates) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if now := time.Now(); now.Sub(f.checkedAt) >= f.interval {
+		f.checkedAt = now
+		if modTime, err := f.lastModified(); nil == err && !modTime.Equal(f.modTime) {
+			if err := f.reload(); nil != err {
+				// 新证书无效（例如证书与私钥只更新了其中一个）时，继续使用已加载的证书
+				logger.Warnw("WEBLISTENER:TLS:RELOAD/ERROR", "cert-file", f.certFile, "error", err)
+			} else {
+				logger.Infow("WEBLISTENER:TLS:RELOADED", "cert-file", f.certFile)
+			}
+		}
+	}
+	return f.cert, nil
+}
+
+func (f *fileCertificates) reload() error {
+	modTime, err := f.lastModified()
+	if nil != err {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if nil != err {
+		return err
+	}
+	f.cert, f.modTime = &cert, modTime
+	return nil
+}
+
+// lastModified 返回证书和私钥文件中较新的修改时间
+func (f *fileCertificates) lastModified() (time.Time, error) {
+	var latest time.Time
+	for _, file := range []string{f.certFile, f.keyFile} {
+		info, err := os.Stat(file)
+		if nil != err {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
+
+// newACMEManager 创建ACME证书管理器，通过HTTP-01验证自动申请及续期证书；验证请求由管理服务处理
+func newACMEManager(config *flux.Configuration) (*autocert.Manager, error) {
+	config.SetDefaults(map[string]interface{}{
+		"cache_dir": "./acme",
+	})
+	domains := config.GetStringSlice("domains")
+	if len(domains) == 0 {
+		return nil, errors.New("config(acme.domains) is required")
+	}
+	manager := &autocert.Manager{
+		Prompt:     autocert.AcceptTOS,
+		Cache:      autocert.DirCache(config.GetString("cache_dir")),
+		HostPolicy: autocert.HostWhitelist(domains...),
+		Email:      config.GetString("email"),
+	}
+	if directory := config.GetString("directory_url"); directory != "" {
+		manager.Client = &acme.Client{DirectoryURL: directory}
+	}
+	return manager, nil
+}
+
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// loadClientAuth 加载客户端证书认证模式及CA证书；ca 为文件路径或PEM内容；
+// optional 模式下客户端可不提供证书，由Endpoint属性决定是否必须；提供的证书均需通过CA验证。
+func loadClientAuth(mode, ca string) (tls.ClientAuthType, *x509.CertPool, error) {
+	var auth tls.ClientAuthType
+	switch mode {
+	case ClientAuthOptional:
+		auth = tls.VerifyClientCertIfGiven
+	case ClientAuthRequire:
+		auth = tls.RequireAndVerifyClientCert
+	default:
+		return tls.NoClientCert, nil, errors.New("unknown client auth mode: " + mode)
+	}
+	if ca == "" {
+		return auth, nil, errors.New("client ca is required")
+	}
+	data := []byte(ca)
+	if !strings.Contains(ca, "-----BEGIN") {
+		bytes, err := ioutil.ReadFile(ca)
+		if nil != err {
+			return auth, nil, err
+		}
+		data = bytes
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return auth, nil, errors.New("no valid certificate in client ca")
+	}
+	return auth, pool, nil
+}
+
+// tlsSettings 监听地址的TLS配置：证书来源（ACME，PEM配置，证书文件）及客户端证书认证
+type tlsSettings struct {
+	getCertificate func(*tls.ClientHelloInfo) (*tls.Certificate, error)
+	acme           *autocert.Manager
+	clientAuth     tls.ClientAuthType
+	clientCAs      *x509.CertPool
+}
+
+// loadTLSSettings 按配置加载TLS设置；未配置证书时返回nil
+func loadTLSSettings(opts *flux.Configuration) (*tlsSettings, error) {
+	opts.SetDefault(ConfigKeyTLSReloadInterval, time.Second*10)
+	settings := new(tlsSettings)
+	certFile, keyFile := opts.GetString(ConfigKeyTLSCertFile), opts.GetString(ConfigKeyTLSKeyFile)
+	switch {
+	case opts.GetBool(ConfigKeyACME + ".enable"):
+		// ACME自动申请及续期证书
+		manager, err := newACMEManager(opts.Sub(ConfigKeyACME))
+		if nil != err {
+			return nil, fmt.Errorf("config.acme is invalid: %w", err)
+		}
+		settings.acme, settings.getCertificate = manager, manager.GetCertificate
+	case opts.IsSet(ConfigKeyTLSCert) && opts.IsSet(ConfigKeyTLSKey):
+		// PEM内容格式的证书，通常引用密钥提供者：tls_cert: "${$secret:pki/data/flux#cert}"
+		certs := newPemCertificates(opts, ConfigKeyTLSCert, ConfigKeyTLSKey)
+		if _, err := certs.GetCertificate(nil); nil != err {
+			return nil, fmt.Errorf("config.tls_cert is invalid: %w", err)
+		}
+		settings.getCertificate = certs.GetCertificate
+	case "" != certFile && "" != keyFile:
+		// 证书文件变更时自动重新加载
+		certs, err := newFileCertificates(certFile, keyFile, opts.GetDuration(ConfigKeyTLSReloadInterval))
+		if nil != err {
+			return nil, fmt.Errorf("config.tls_cert_file is invalid: %w", err)
+		}
+		settings.getCertificate = certs.GetCertificate
+	}
+	// mTLS：客户端证书认证
+	if mode := opts.GetString(ConfigKeyTLSClientAuth); mode != "" && mode != ClientAuthNone {
+		if nil == settings.getCertificate {
+			return nil, errors.New("config.tls_client_auth requires tls")
+		}
+		auth, pool, err := loadClientAuth(mode, opts.GetString(ConfigKeyTLSClientCA))
+		if nil != err {
+			return nil, fmt.Errorf("config.tls_client_auth is invalid: %w", err)
+		}
+		settings.clientAuth, settings.clientCAs = auth, pool
+	}
+	if nil == settings.getCertificate {
+		return nil, nil
+	}
+	return settings, nil
+}
+
+// config 返回监听地址的TLS配置；默认通过ALPN协商h2，与 echo.StartTLS 的行为一致
+func (t *tlsSettings) config() *tls.Config {
+	return &tls.Config{
+		GetCertificate: t.getCertificate,
+		ClientAuth:     t.clientAuth,
+		ClientCAs:      t.clientCAs,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+}
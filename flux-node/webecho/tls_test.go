@@ -0,0 +1,141 @@
+package webecho
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA 测试用的CA证书，用于签发客户端证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  string
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "flux-test-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))}
+}
+
+// issue 签发客户端证书
+func (ca *testCA) issue(t *testing.T, cn string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestLoadClientAuth(t *testing.T) {
+	ca := newTestCA(t)
+	dir, err := ioutil.TempDir("", "flux-tls")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caFile := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(caFile, []byte(ca.pem), 0600))
+	invalidFile := filepath.Join(dir, "invalid.pem")
+	assert.NoError(t, ioutil.WriteFile(invalidFile, []byte("not a certificate"), 0600))
+	cases := []struct {
+		name string
+		mode string
+		ca   string
+		auth tls.ClientAuthType
+		ok   bool
+	}{
+		{name: "optional pem", mode: ClientAuthOptional, ca: ca.pem, auth: tls.VerifyClientCertIfGiven, ok: true},
+		{name: "require pem", mode: ClientAuthRequire, ca: ca.pem, auth: tls.RequireAndVerifyClientCert, ok: true},
+		{name: "require file", mode: ClientAuthRequire, ca: caFile, auth: tls.RequireAndVerifyClientCert, ok: true},
+		{name: "unknown mode", mode: "request", ca: ca.pem},
+		{name: "missing ca", mode: ClientAuthRequire, ca: ""},
+		{name: "invalid pem", mode: ClientAuthRequire, ca: "-----BEGIN CERTIFICATE-----\ninvalid\n-----END CERTIFICATE-----\n"},
+		{name: "invalid file", mode: ClientAuthOptional, ca: invalidFile},
+		{name: "missing file", mode: ClientAuthOptional, ca: filepath.Join(dir, "missing.pem")},
+	}
+	for _, c := range cases {
+		auth, pool, err := loadClientAuth(c.mode, c.ca)
+		if !c.ok {
+			assert.Error(t, err, c.name)
+			assert.Nil(t, pool, c.name)
+			continue
+		}
+		assert.NoError(t, err, c.name)
+		assert.Equal(t, c.auth, auth, c.name)
+		assert.NotNil(t, pool, c.name)
+	}
+}
+
+func TestLoadClientAuth_Handshake(t *testing.T) {
+	ca, other := newTestCA(t), newTestCA(t)
+	trusted, untrusted := ca.issue(t, "api.example.com"), other.issue(t, "api.example.com")
+	cases := []struct {
+		name   string
+		mode   string
+		client []tls.Certificate
+		ok     bool
+	}{
+		{name: "require, no cert", mode: ClientAuthRequire},
+		{name: "require, trusted", mode: ClientAuthRequire, client: []tls.Certificate{trusted}, ok: true},
+		{name: "require, untrusted", mode: ClientAuthRequire, client: []tls.Certificate{untrusted}},
+		// optional 模式下可不提供证书，由Endpoint属性决定是否必须；提供的证书仍需通过CA验证
+		{name: "optional, no cert", mode: ClientAuthOptional, ok: true},
+		{name: "optional, trusted", mode: ClientAuthOptional, client: []tls.Certificate{trusted}, ok: true},
+		{name: "optional, untrusted", mode: ClientAuthOptional, client: []tls.Certificate{untrusted}},
+	}
+	for _, c := range cases {
+		auth, pool, err := loadClientAuth(c.mode, ca.pem)
+		assert.NoError(t, err)
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		server.TLS = &tls.Config{ClientAuth: auth, ClientCAs: pool}
+		server.StartTLS()
+		client := server.Client()
+		client.Transport.(*http.Transport).TLSClientConfig.Certificates = c.client
+		resp, err := client.Get(server.URL)
+		if c.ok {
+			if assert.NoError(t, err, c.name) {
+				assert.Equal(t, http.StatusOK, resp.StatusCode, c.name)
+				_ = resp.Body.Close()
+			}
+		} else {
+			assert.Error(t, err, c.name)
+		}
+		server.Close()
+	}
+}
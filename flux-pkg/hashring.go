@@ -0,0 +1,50 @@
+package fluxpkg
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// HashRing 基于虚拟节点的一致性哈希环；节点增减时只影响少量Key的映射，用于在多个缓存分片、多个实例间分布Key。
+// HashRing 创建后只读，可被并发访问；节点列表变化时应创建新的哈希环。
+type HashRing struct {
+	hashes []uint32
+	nodes  map[uint32]string
+}
+
+// NewHashRing 创建一致性哈希环；replicas 为每个节点的虚拟节点数量，小于1时为1
+func NewHashRing(nodes []string, replicas int) *HashRing {
+	if replicas < 1 {
+		replicas = 1
+	}
+	ring := &HashRing{
+		hashes: make([]uint32, 0, len(nodes)*replicas),
+		nodes:  make(map[uint32]string, len(nodes)*replicas),
+	}
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			h := crc32.ChecksumIEEE([]byte(node + "#" + strconv.Itoa(i)))
+			if _, ok := ring.nodes[h]; ok {
+				continue
+			}
+			ring.hashes = append(ring.hashes, h)
+			ring.nodes[h] = node
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+	return ring
+}
+
+// Get 返回Key映射的节点；哈希环为空时返回空字符串
+func (r *HashRing) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+	h := crc32.ChecksumIEEE([]byte(key))
+	idx := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if idx == len(r.hashes) {
+		idx = 0
+	}
+	return r.nodes[r.hashes[idx]]
+}
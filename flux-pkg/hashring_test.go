@@ -0,0 +1,29 @@
+package fluxpkg
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"testing"
+)
+
+func TestHashRing_Get(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("", NewHashRing(nil, 16).Get("key"))
+	ring := NewHashRing([]string{"a", "b", "c"}, 64)
+	for i := 0; i < 100; i++ {
+		key := "key" + strconv.Itoa(i)
+		assert.Equal(ring.Get(key), ring.Get(key), "mapping must be stable")
+	}
+}
+
+func TestHashRing_RemoveNodeKeepsOtherKeys(t *testing.T) {
+	assert := assert.New(t)
+	full := NewHashRing([]string{"a", "b", "c"}, 64)
+	less := NewHashRing([]string{"a", "b"}, 64)
+	for i := 0; i < 1000; i++ {
+		key := "key" + strconv.Itoa(i)
+		if node := full.Get(key); node != "c" {
+			assert.Equal(node, less.Get(key), "keys of remaining nodes must not move")
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package fluxpkg
 
+import "strings"
+
 // StringSliceContains 字符串列表，是否包括指定字符串
 func StringSliceContains(elements []string, ele string) bool {
 	for _, v := range elements {
@@ -9,3 +11,12 @@ func StringSliceContains(elements []string, ele string) bool {
 	}
 	return false
 }
+
+// MatchHostPattern Host是否匹配指定模式；模式为空时匹配全部Host，支持 *.example.com 通配子域名，不区分大小写
+func MatchHostPattern(pattern, host string) bool {
+	pattern, host = strings.ToLower(pattern), strings.ToLower(host)
+	if pattern == "" || pattern == host {
+		return true
+	}
+	return strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:])
+}
@@ -23,3 +23,23 @@ func TestStringSliceContains(t *testing.T) {
 		assert.Equal(tcase.has, has, "has: not match")
 	}
 }
+
+func TestMatchHostPattern(t *testing.T) {
+	cases := []struct {
+		pattern string
+		host    string
+		match   bool
+	}{
+		{pattern: "", host: "api.example.com", match: true},
+		{pattern: "api.example.com", host: "API.example.com", match: true},
+		{pattern: "api.example.com", host: "web.example.com", match: false},
+		{pattern: "*.example.com", host: "api.example.com", match: true},
+		{pattern: "*.example.com", host: "a.b.example.com", match: true},
+		{pattern: "*.example.com", host: "example.com", match: false},
+		{pattern: "*.example.com", host: "api.example.org", match: false},
+	}
+	assert := assert.New(t)
+	for _, tcase := range cases {
+		assert.Equal(tcase.match, MatchHostPattern(tcase.pattern, tcase.host), "pattern: %s, host: %s", tcase.pattern, tcase.host)
+	}
+}
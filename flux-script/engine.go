@@ -1,6 +1,8 @@
 package fluxscript
 
 import (
+	"container/list"
+	"context"
 	"fmt"
 	"github.com/dop251/goja"
 	"reflect"
@@ -12,56 +14,168 @@ const (
 	ScriptEntryFunName = "entry"
 )
 
-var engine = &Engine{
-	scripts: sync.Map{},
-}
+const (
+	// 默认缓存的已编译脚本数量
+	DefaultMaxScripts = 1024
+	// 默认的脚本调用栈深度
+	DefaultMaxCallStackSize = 1024
+)
 
+var engine = NewBoundedEngine(DefaultMaxScripts, DefaultMaxCallStackSize)
+
+// Engine 缓存已编译的脚本；缓存数量超过上限时，淘汰最久未使用的脚本
 type Engine struct {
-	scripts sync.Map
+	mu           sync.Mutex
+	scripts      map[string]*list.Element
+	lru          *list.List
+	maxScripts   int
+	maxCallStack int
+}
+
+type compiled struct {
+	id      string
+	program *goja.Program
 }
 
 func NewEngine() *Engine {
 	return engine
 }
 
+// NewBoundedEngine 创建限制脚本缓存数量及调用栈深度的Engine
+func NewBoundedEngine(maxScripts, maxCallStack int) *Engine {
+	return &Engine{
+		scripts:      make(map[string]*list.Element, 16),
+		lru:          list.New(),
+		maxScripts:   maxScripts,
+		maxCallStack: maxCallStack,
+	}
+}
+
 // Load 将JavaScript脚本编译并缓存；返回执行此脚本的ScriptId；
 func (se *Engine) Load(source string) (string, error) {
+	id, _, err := se.load(source)
+	return id, err
+}
+
+func (se *Engine) load(source string) (string, *goja.Program, error) {
 	id := se.scriptId([]byte(source))
-	if _, ok := se.scripts.Load(id); !ok {
-		pro, err := goja.Compile(id, source, true)
-		if nil != err {
-			return "", fmt.Errorf("load to compile script, error: %w", err)
-		}
-		se.scripts.Store(id, pro)
+	if pro, ok := se.program(id); ok {
+		return id, pro, nil
+	}
+	pro, err := goja.Compile(id, source, true)
+	if nil != err {
+		return "", nil, fmt.Errorf("load to compile script, error: %w", err)
 	}
-	return id, nil
+	se.store(id, pro)
+	return id, pro, nil
 }
 
 // Exist 判断ScriptId是否存在。
 func (se *Engine) Exist(scriptId string) bool {
-	_, ok := se.scripts.Load(scriptId)
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	_, ok := se.scripts[scriptId]
 	return ok
 }
 
 // Remove 删除指定ScriptId的脚本
 func (se *Engine) Remove(scriptId string) {
-	se.scripts.Delete(scriptId)
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	if elem, ok := se.scripts[scriptId]; ok {
+		se.lru.Remove(elem)
+		delete(se.scripts, scriptId)
+	}
+}
+
+func (se *Engine) program(scriptId string) (*goja.Program, bool) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	elem, ok := se.scripts[scriptId]
+	if !ok {
+		return nil, false
+	}
+	se.lru.MoveToFront(elem)
+	return elem.Value.(*compiled).program, true
+}
+
+func (se *Engine) store(scriptId string, program *goja.Program) {
+	se.mu.Lock()
+	defer se.mu.Unlock()
+	if elem, ok := se.scripts[scriptId]; ok {
+		se.lru.MoveToFront(elem)
+		return
+	}
+	se.scripts[scriptId] = se.lru.PushFront(&compiled{id: scriptId, program: program})
+	for se.maxScripts > 0 && se.lru.Len() > se.maxScripts {
+		oldest := se.lru.Back()
+		se.lru.Remove(oldest)
+		delete(se.scripts, oldest.Value.(*compiled).id)
+	}
+}
+
+func (se *Engine) newRuntime() *goja.Runtime {
+	runtime := goja.New()
+	if se.maxCallStack > 0 {
+		runtime.SetMaxCallStackSize(se.maxCallStack)
+	}
+	return runtime
 }
 
 // EvalScriptId 执行指定ScriptId的脚本，执行指定函数；
 func (se *Engine) EvalScriptId(scriptId string, entryFun string, context interface{}) (v interface{}, err error) {
-	prop, ok := se.scripts.Load(scriptId)
-	if !ok || prop == nil {
+	prop, ok := se.program(scriptId)
+	if !ok {
 		return nil, fmt.Errorf("script not found, script-id: %s", scriptId)
 	}
-	runtime := goja.New()
-	_, rerr := runtime.RunProgram(prop.(*goja.Program))
+	runtime := se.newRuntime()
+	_, rerr := runtime.RunProgram(prop)
 	if nil != rerr {
 		return nil, fmt.Errorf("compile script, error: %w", rerr)
 	}
 	return se.entry(runtime, entryFun, context)
 }
 
+// EvalScriptIdContext 执行指定ScriptId的脚本，执行指定函数；Context超时或取消时中断脚本执行，返回Context的错误；
+func (se *Engine) EvalScriptIdContext(ctx context.Context, scriptId string, entryFun string, entryContext interface{}) (v interface{}, err error) {
+	prop, ok := se.program(scriptId)
+	if !ok {
+		return nil, fmt.Errorf("script not found, script-id: %s", scriptId)
+	}
+	return se.evalContext(ctx, prop, entryFun, entryContext)
+}
+
+// EvalContext 编译并缓存JavaScript脚本，执行指定函数；Context超时或取消时中断脚本执行，返回Context的错误；
+func (se *Engine) EvalContext(ctx context.Context, source string, entryFun string, entryContext interface{}) (v interface{}, err error) {
+	_, prop, err := se.load(source)
+	if nil != err {
+		return nil, err
+	}
+	return se.evalContext(ctx, prop, entryFun, entryContext)
+}
+
+func (se *Engine) evalContext(ctx context.Context, prop *goja.Program, entryFun string, entryContext interface{}) (v interface{}, err error) {
+	runtime := se.newRuntime()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			runtime.Interrupt(ctx.Err())
+		case <-done:
+		}
+	}()
+	if _, rerr := runtime.RunProgram(prop); nil != rerr {
+		err = fmt.Errorf("compile script, error: %w", rerr)
+	} else {
+		v, err = se.entry(runtime, entryFun, entryContext)
+	}
+	if nil != err && nil != ctx.Err() {
+		return nil, fmt.Errorf("interrupt script, error: %w", ctx.Err())
+	}
+	return v, err
+}
+
 // EvalEntryScriptId 执行指定ScriptId的脚本，执行默认entry函数；
 func (se *Engine) EvalEntryScriptId(scriptId string, context interface{}) (v interface{}, err error) {
 	return se.EvalScriptId(scriptId, ScriptEntryFunName, context)
@@ -74,7 +188,7 @@ func (se *Engine) EvalEntry(source string, context interface{}) (v interface{},
 
 // Eval 执行JavaScript脚本，指定执行函数。脚本被立即执行；
 func (se *Engine) Eval(src string, entryFun string, context interface{}) (v interface{}, err error) {
-	runtime := goja.New()
+	runtime := se.newRuntime()
 	_, rerr := runtime.RunScript("dynamic.eval.fun:"+entryFun, src)
 	if nil != rerr {
 		return nil, fmt.Errorf("compile script, error: %w", rerr)
@@ -99,7 +213,11 @@ func (se *Engine) entry(runtime *goja.Runtime, entryFun string, entryContext int
 	}
 	defer func() {
 		if r := recover(); nil != r {
-			err = fmt.Errorf("executing script, error: %s", r)
+			if rerr, ok := r.(error); ok {
+				err = fmt.Errorf("executing script, error: %w", rerr)
+			} else {
+				err = fmt.Errorf("executing script, error: %s", r)
+			}
 		}
 	}()
 	runtime.SetFieldNameMapper(goja.TagFieldNameMapper("json", true))
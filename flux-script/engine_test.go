@@ -1,8 +1,11 @@
 package fluxscript
 
 import (
+	"context"
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"testing"
+	"time"
 )
 
 func TestEvalScript(t *testing.T) {
@@ -77,3 +80,40 @@ function entry(ctx) {
 		asserter.Equal("HelloWorld", v, "eval: return value must match")
 	}
 }
+
+func TestEngine_EvictScripts(t *testing.T) {
+	se := NewBoundedEngine(2, DefaultMaxCallStackSize)
+	asserter := assert.New(t)
+	ids := make([]string, 3)
+	for i, value := range []string{"a", "b", "c"} {
+		id, err := se.Load(`function entry(ctx) { return "` + value + `"; }`)
+		asserter.Nil(err)
+		ids[i] = id
+	}
+	asserter.False(se.Exist(ids[0]), "oldest script must be evicted")
+	asserter.True(se.Exist(ids[1]))
+	asserter.True(se.Exist(ids[2]))
+	// 执行过的脚本不被淘汰
+	_, err := se.EvalEntryScriptId(ids[1], ScriptContext{})
+	asserter.Nil(err)
+	_, err = se.Load(`function entry(ctx) { return "d"; }`)
+	asserter.Nil(err)
+	asserter.True(se.Exist(ids[1]))
+	asserter.False(se.Exist(ids[2]))
+}
+
+func TestEngine_EvalContext(t *testing.T) {
+	se := NewBoundedEngine(DefaultMaxScripts, 64)
+	asserter := assert.New(t)
+	v, err := se.EvalContext(context.Background(), `function entry(ctx) { return "HelloWorld"; }`, ScriptEntryFunName, ScriptContext{})
+	asserter.Nil(err)
+	asserter.Equal("HelloWorld", v)
+	// 超时中断
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+	_, err = se.EvalContext(ctx, `function entry(ctx) { while (true) {} }`, ScriptEntryFunName, ScriptContext{})
+	asserter.True(errors.Is(err, context.DeadlineExceeded))
+	// 调用栈深度
+	_, err = se.EvalContext(context.Background(), `function f(n) { return f(n + 1); } function entry(ctx) { return f(0); }`, ScriptEntryFunName, ScriptContext{})
+	asserter.Error(err)
+}